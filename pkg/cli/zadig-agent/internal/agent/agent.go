@@ -19,6 +19,7 @@ package agent
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/koderover/zadig/pkg/cli/zadig-agent/config"
@@ -49,12 +50,14 @@ type AgentController struct {
 	ConcurrencyBlockTime int
 	CurrentJobNum        int
 	WorkingDirectory     string
+	// runningJobs are the executors of the running jobs by job id, the job stream cancels them by id
+	runningJobs sync.Map
 }
 
 func (c *AgentController) Start(ctx context.Context) {
 	c.JobChan = make(chan *types.ZadigJobTask, c.Concurrency)
 
-	go c.PollingJob(ctx)
+	go c.ReceiveJob(ctx)
 
 	go c.RunJob(ctx)
 }
@@ -141,6 +144,8 @@ func (c *AgentController) RunSingleJob(ctx context.Context, job *types.ZadigJobT
 	var err error
 	jobCtx, cancel := context.WithCancel(ctx)
 	executor := jobexecutor.NewJobExecutor(ctx, job, c.Client, cancel)
+	c.runningJobs.Store(job.ID, executor)
+	defer c.runningJobs.Delete(job.ID)
 
 	// execute some init job before execute zadig job
 	err = executor.BeforeExecute()
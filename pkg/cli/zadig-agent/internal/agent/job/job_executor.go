@@ -329,31 +329,24 @@ func (e *JobExecutor) AfterExecute() error {
 
 	// ------------------------------------------------ report all job log ----------------------------------------------
 	for {
-		logStr, EOFErr, err := e.Reporter.GetJobLog()
-		if err == nil {
-			resp, err := e.Reporter.ReportWithData(
-				&types.JobExecuteResult{
-					JobInfo: e.JobResult.JobInfo,
-					Status:  e.JobResult.Status,
-					Log:     logStr,
-					Error:   e.JobResult.Error,
-				})
-			if err != nil {
-				log.Errorf("report workflow %s job %s log error: %v", e.Job.WorkflowName, e.Job.JobName, err)
-				return nil
-			}
+		resp, done, err := e.Reporter.ReportLog(
+			&types.JobExecuteResult{
+				JobInfo: e.JobResult.JobInfo,
+				Status:  e.JobResult.Status,
+				Error:   e.JobResult.Error,
+			})
+		if err != nil {
+			log.Errorf("report workflow %s job %s log error: %v", e.Job.WorkflowName, e.Job.JobName, err)
+			return nil
+		}
 
-			if resp != nil && (resp.JobStatus == common.StatusCancelled.String() || resp.JobStatus == common.StatusTimeout.String()) {
-				*e.Cancel = true
-				return nil
-			}
+		if resp != nil && (resp.JobStatus == common.StatusCancelled.String() || resp.JobStatus == common.StatusTimeout.String()) {
+			*e.Cancel = true
+			return nil
+		}
 
-			if EOFErr {
-				log.Infof("report workflow %s job %s log finished", e.Job.WorkflowName, e.Job.JobName)
-				break
-			}
-		} else {
-			log.Errorf("failed to get job log, error: %s", err)
+		if done {
+			log.Infof("report workflow %s job %s log finished", e.Job.WorkflowName, e.Job.JobName)
 			break
 		}
 	}
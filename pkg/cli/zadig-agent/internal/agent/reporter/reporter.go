@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	errhelper "github.com/koderover/zadig/pkg/cli/zadig-agent/helper/error"
@@ -35,10 +36,10 @@ type JobReporter struct {
 	Client    *network.ZadigClient
 	Logger    *log.JobLogger
 	Offset    int64
-	CurLogNum int64
 	Log       string
 	JobCancel *bool
 	Result    *types.JobExecuteResult
+	logLock   sync.Mutex
 }
 
 func NewJobReporter(result *types.JobExecuteResult, client *network.ZadigClient, cancel *bool) *JobReporter {
@@ -69,14 +70,15 @@ func (r *JobReporter) Start(ctx context.Context) {
 	}
 }
 
-func (r *JobReporter) GetJobLog() (string, bool, error) {
-	buffer, newOffset, num, EOFErr, err := r.Logger.ReadByRowNum(r.Offset, r.CurLogNum, common.DefaultJobLogReadNum)
+// GetJobLog reads the next chunk of the job log, it returns the offset the chunk starts at as well.
+func (r *JobReporter) GetJobLog() (string, int64, bool, error) {
+	start := r.Offset
+	buffer, newOffset, _, EOFErr, err := r.Logger.ReadByRowNum(r.Offset, 0, common.DefaultJobLogReadNum)
 	if err != nil {
-		return "", EOFErr, err
+		return "", start, EOFErr, err
 	}
 	r.Offset = newOffset
-	r.CurLogNum = num
-	return string(buffer), EOFErr, nil
+	return string(buffer), start, EOFErr, nil
 }
 
 func (r *JobReporter) Report() error {
@@ -84,29 +86,48 @@ func (r *JobReporter) Report() error {
 		return fmt.Errorf("reporter result is nil")
 	}
 
-	// get log from job log file
-	err := r.SetLog()
+	resp, _, err := r.ReportLog(r.Result)
 	if err != nil {
-		log.Errorf("failed to set job log, error: %s", err)
+		return fmt.Errorf("%s-%s SEQ: %d failed to report status, error: %s", r.Result.JobInfo.WorkflowName, r.Result.JobInfo.JobName, r.Seq, err)
+	}
+
+	if resp.JobID == r.Result.JobInfo.JobID && (resp.JobStatus == common.StatusTimeout.String() || resp.JobStatus == common.StatusCancelled.String()) {
+		*r.JobCancel = true
 	}
+	return nil
+}
+
+// ReportLog reports the result with the next chunk of the job log, it returns done once the whole log is saved by zadig server.
+func (r *JobReporter) ReportLog(result *types.JobExecuteResult) (*types.ReportAgentJobResp, bool, error) {
+	r.logLock.Lock()
+	defer r.logLock.Unlock()
+
+	logStr, start, EOFErr, err := r.GetJobLog()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get job log, error: %s", err)
+	}
+	end := r.Offset
 
 	resp, err := r.Client.ReportJob(&types.ReportJobParameters{
 		Seq:       r.Seq,
-		JobID:     r.Result.JobInfo.JobID,
-		JobStatus: r.Result.Status,
-		JobError:  errhelper.ErrHandler(r.Result.Error),
-		JobLog:    r.Result.Log,
-		JobOutput: r.Result.OutputsJsonBytes,
+		JobID:     result.JobInfo.JobID,
+		JobStatus: result.Status,
+		JobError:  errhelper.ErrHandler(result.Error),
+		JobLog:    logStr,
+		JobOutput: result.OutputsJsonBytes,
+		LogOffset: &start,
 	})
 	if err != nil {
-		return fmt.Errorf("%s-%s SEQ: %d failed to report status, error: %s", r.Result.JobInfo.WorkflowName, r.Result.JobInfo.JobName, r.Seq, err)
+		// send the chunk again in the next report
+		r.Offset = start
+		return nil, false, err
 	}
-	r.Result.Log = ""
 
-	if resp.JobID == r.Result.JobInfo.JobID && (resp.JobStatus == common.StatusTimeout.String() || resp.JobStatus == common.StatusCancelled.String()) {
-		*r.JobCancel = true
+	// zadig server tells the offset it has saved, the agent resends from there if some log is lost after reconnecting
+	if resp.LogOffset != nil {
+		r.Offset = *resp.LogOffset
 	}
-	return nil
+	return resp, EOFErr && r.Offset == end, nil
 }
 
 func (r *JobReporter) ReportWithData(result *types.JobExecuteResult) (*types.ReportAgentJobResp, error) {
@@ -127,20 +148,6 @@ func (r *JobReporter) ReportWithData(result *types.JobExecuteResult) (*types.Rep
 	return resp, err
 }
 
-func (r *JobReporter) SetLog() error {
-	if r.Result == nil {
-		return fmt.Errorf("reporter result is nil")
-	}
-
-	logStr, _, err := r.GetJobLog()
-	if err != nil {
-		return fmt.Errorf("failed to get job log, error: %s", err)
-	}
-	r.Result.Log = logStr
-
-	return nil
-}
-
 func (r *JobReporter) FinishedJobReport(status common.Status, err error) error {
 	r.Result.SetError(errors.New(errhelper.ErrHandler(err)))
 	r.Result.SetStatus(status)
@@ -0,0 +1,149 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/helper/log"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/common/types"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+type DistributeImageStep struct {
+	spec       *step.StepImageDistributeSpec
+	envs       []string
+	secretEnvs []string
+	logger     *log.JobLogger
+	dirs       *types.AgentWorkDirs
+}
+
+func NewDistributeImageStep(spec interface{}, dirs *types.AgentWorkDirs, envs, secretEnvs []string, logger *log.JobLogger) (*DistributeImageStep, error) {
+	distributeImageStep := &DistributeImageStep{dirs: dirs, envs: envs, secretEnvs: secretEnvs, logger: logger}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return distributeImageStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &distributeImageStep.spec); err != nil {
+		return distributeImageStep, fmt.Errorf("unmarshal spec %s to distribute image spec failed", yamlBytes)
+	}
+	return distributeImageStep, nil
+}
+
+func (s *DistributeImageStep) Run(ctx context.Context) error {
+	start := time.Now()
+	s.logger.Infof("Start distribute images.")
+	defer func() {
+		s.logger.Infof(fmt.Sprintf("Distribute images ended. Duration: %.2f seconds.", time.Since(start).Seconds()))
+	}()
+
+	if s.spec.SourceRegistry == nil || s.spec.TargetRegistry == nil {
+		return errors.New("image registry infos are missing")
+	}
+
+	if err := s.login("Source", s.spec.SourceRegistry); err != nil {
+		return err
+	}
+	err := s.runParallel(func(target *step.DistributeTaskTarget) error {
+		if err := s.runDockerCmd(dockerPullCmd(target.SourceImage)); err != nil {
+			return fmt.Errorf("failed to pull image: %s", err)
+		}
+		s.logger.Infof(fmt.Sprintf("Pull source image [%s] succeed.", target.SourceImage))
+
+		if err := s.runDockerCmd(dockerTagCmd(target.SourceImage, target.TargetImage)); err != nil {
+			return fmt.Errorf("failed to tag image: %s", err)
+		}
+		s.logger.Infof(fmt.Sprintf("Tag image [%s] to [%s] succeed.", target.SourceImage, target.TargetImage))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("prepare source images error: %v", err)
+	}
+	s.logger.Infof("Finish prepare source images.")
+
+	if err := s.login("Target", s.spec.TargetRegistry); err != nil {
+		return err
+	}
+	err = s.runParallel(func(target *step.DistributeTaskTarget) error {
+		if err := s.runDockerCmd(dockerPush(target.TargetImage)); err != nil {
+			return fmt.Errorf("failed to push image: %s", err)
+		}
+		s.logger.Infof(fmt.Sprintf("Push image [%s] succeed.", target.TargetImage))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("push target images error: %v", err)
+	}
+	return nil
+}
+
+func (s *DistributeImageStep) login(kind string, registry *step.RegistryNamespace) error {
+	s.logger.Infof(fmt.Sprintf("Logining Docker %s Registry: %s.", kind, registry.RegAddr))
+	startTimeDockerLogin := time.Now()
+	if err := s.runDockerCmd(dockerLogin(registry.AccessKey, registry.SecretKey, registry.RegAddr)); err != nil {
+		return fmt.Errorf("failed to login docker registry: %s", err)
+	}
+	s.logger.Infof(fmt.Sprintf("Login ended. Duration: %.2f seconds.", time.Since(startTimeDockerLogin).Seconds()))
+	return nil
+}
+
+func (s *DistributeImageStep) runParallel(fn func(target *step.DistributeTaskTarget) error) error {
+	errList := new(multierror.Error)
+	errLock := sync.Mutex{}
+
+	wg := sync.WaitGroup{}
+	for _, target := range s.spec.DistributeTarget {
+		wg.Add(1)
+		go func(target *step.DistributeTaskTarget) {
+			defer wg.Done()
+			if err := fn(target); err != nil {
+				errLock.Lock()
+				defer errLock.Unlock()
+				errList = multierror.Append(errList, err)
+			}
+		}(target)
+	}
+	wg.Wait()
+	return errList.ErrorOrNil()
+}
+
+func (s *DistributeImageStep) runDockerCmd(cmd *exec.Cmd) error {
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s", err, out.String())
+	}
+	return nil
+}
+
+func dockerPullCmd(fullImage string) *exec.Cmd {
+	return exec.Command(dockerExe, "pull", fullImage)
+}
+
+func dockerTagCmd(sourceImage, targetImage string) *exec.Cmd {
+	return exec.Command(dockerExe, "tag", sourceImage, targetImage)
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/helper/log"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/common/types"
+	jobexecutorstep "github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/step"
+	toollog "github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+// fakeDocker records the pull, tag and push commands and fails on the images named missing.
+const fakeDocker = `#!/bin/sh
+case "$*" in
+  *missing*) echo "image not found"; exit 1 ;;
+esac
+case "$1" in
+  pull|tag|push) echo "$*" >> "$FAKE_DOCKER_LOG" ;;
+esac
+`
+
+// TestDistributeImageConformance runs the same spec through the job executor and the agent against a fake docker.
+func TestDistributeImageConformance(t *testing.T) {
+	toollog.Init(&toollog.Config{Level: "error"})
+
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "docker"), []byte(fakeDocker), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	testcases := []struct {
+		name   string
+		images []string
+		passed bool
+	}{
+		{"distributed", []string{"app", "worker"}, true},
+		{"pull failed", []string{"app", "missing"}, false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &step.StepImageDistributeSpec{
+				SourceRegistry: &step.RegistryNamespace{RegAddr: "source.koderover.io", AccessKey: "user", SecretKey: "password"},
+				TargetRegistry: &step.RegistryNamespace{RegAddr: "target.koderover.io", AccessKey: "user", SecretKey: "password"},
+			}
+			for _, image := range tc.images {
+				spec.DistributeTarget = append(spec.DistributeTarget, &step.DistributeTaskTarget{
+					SourceImage: "source.koderover.io/demo/" + image + ":v1",
+					TargetImage: "target.koderover.io/demo/" + image + ":v1",
+				})
+			}
+
+			run := func(newStep func() (interface{ Run(context.Context) error }, error)) ([]string, error) {
+				logFile := filepath.Join(t.TempDir(), "docker.log")
+				t.Setenv("FAKE_DOCKER_LOG", logFile)
+				s, err := newStep()
+				if err != nil {
+					t.Fatal(err)
+				}
+				runErr := s.Run(context.Background())
+				content, _ := os.ReadFile(logFile)
+				// the images are distributed in parallel
+				cmds := strings.Split(strings.TrimSpace(string(content)), "\n")
+				sort.Strings(cmds)
+				return cmds, runErr
+			}
+
+			executorCmds, executorErr := run(func() (interface{ Run(context.Context) error }, error) {
+				return jobexecutorstep.NewDistributeImageStep(spec, t.TempDir(), nil, nil)
+			})
+			agentCmds, agentErr := run(func() (interface{ Run(context.Context) error }, error) {
+				return NewDistributeImageStep(spec, &types.AgentWorkDirs{Workspace: t.TempDir()}, nil, nil, &log.JobLogger{})
+			})
+
+			if (executorErr == nil) != tc.passed || (agentErr == nil) != tc.passed {
+				t.Errorf("unexpected results, executor: %v, agent: %v", executorErr, agentErr)
+			}
+			if executorErr != nil && agentErr != nil && executorErr.Error() != agentErr.Error() {
+				t.Errorf("errors differ, executor: %v, agent: %v", executorErr, agentErr)
+			}
+			if strings.Join(executorCmds, "\n") != strings.Join(agentCmds, "\n") {
+				t.Errorf("docker commands differ, executor: %q, agent: %q", executorCmds, agentCmds)
+			}
+			if tc.passed && len(agentCmds) != 3*len(tc.images) {
+				t.Errorf("unexpected docker commands %q", agentCmds)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package junit
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/helper/log"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/agent/step/helper"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/common/types"
+	"github.com/koderover/zadig/pkg/microservice/reaper/core/service/meta"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/tool/testreport"
+	zadigtypes "github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const (
+	replaceTestSuite  = "TestSuite"
+	replaceTestSuites = "TestSuites"
+)
+
+type JunitReportStep struct {
+	spec       *step.StepJunitReportSpec
+	envs       []string
+	secretEnvs []string
+	logger     *log.JobLogger
+	dirs       *types.AgentWorkDirs
+}
+
+func NewJunitReportStep(spec interface{}, dirs *types.AgentWorkDirs, envs, secretEnvs []string, logger *log.JobLogger) (*JunitReportStep, error) {
+	junitReportStep := &JunitReportStep{dirs: dirs, envs: envs, secretEnvs: secretEnvs, logger: logger}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return junitReportStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &junitReportStep.spec); err != nil {
+		return junitReportStep, fmt.Errorf("unmarshal spec %s to junit report spec failed", yamlBytes)
+	}
+	return junitReportStep, nil
+}
+
+func (s *JunitReportStep) Run(ctx context.Context) error {
+	start := time.Now()
+	s.logger.Infof("Start merge test results.")
+	defer func() {
+		s.logger.Infof(fmt.Sprintf("Junit report ended. Duration: %.2f seconds.", time.Since(start).Seconds()))
+	}()
+
+	if err := os.MkdirAll(s.spec.DestDir, os.ModePerm); err != nil {
+		return fmt.Errorf("create dest dir: %s error: %s", s.spec.DestDir, err)
+	}
+
+	envMap := helper.MakeEnvMap(s.envs, s.secretEnvs)
+	s.spec.ReportDir = helper.ReplaceEnvWithValue(s.spec.ReportDir, envMap)
+	s.spec.CoveragePath = helper.ReplaceEnvWithValue(s.spec.CoveragePath, envMap)
+
+	reportDir := filepath.Join(s.dirs.Workspace, s.spec.ReportDir)
+	var (
+		summaryResult *meta.TestSuite
+		err           error
+	)
+	switch s.spec.ReportFormat {
+	case zadigtypes.TestReportFormatGoTestJSON, zadigtypes.TestReportFormatTestNG:
+		summaryResult, err = s.mergeTestReports(reportDir)
+	default:
+		summaryResult, err = s.mergeJunitResults(reportDir, start)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to merge test result: %s", err)
+	}
+	s.logger.Infof("Finish merge test results.")
+
+	if err := s.writeTestResult(summaryResult); err != nil {
+		return fmt.Errorf("failed to write test result: %s", err)
+	}
+
+	if s.spec.S3DestDir == "" || s.spec.FileName == "" {
+		return nil
+	}
+	s.logger.Infof(fmt.Sprintf("Start archive %s.", s.spec.FileName))
+	if err := s.upload(); err != nil {
+		return err
+	}
+	s.logger.Infof(fmt.Sprintf("Finish archive %s.", s.spec.FileName))
+
+	if summaryResult.Failures > 0 {
+		return fmt.Errorf("%d case(s) failed", summaryResult.Failures)
+	}
+	return nil
+}
+
+func (s *JunitReportStep) upload() error {
+	forcedPathStyle := true
+	if s.spec.S3Storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3.NewClient(s.spec.S3Storage.Endpoint, s.spec.S3Storage.Ak, s.spec.S3Storage.Sk, s.spec.S3Storage.Region, s.spec.S3Storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return fmt.Errorf("failed to create s3 client to upload file, err: %s", err)
+	}
+
+	absFilePath := path.Join(s.spec.DestDir, s.spec.FileName)
+	if len(s.spec.S3Storage.Subfolder) > 0 {
+		s.spec.S3DestDir = strings.TrimLeft(path.Join(s.spec.S3Storage.Subfolder, s.spec.S3DestDir), "/")
+	}
+
+	info, err := os.Stat(absFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to upload file path [%s] to destination [%s], the error is: %s", absFilePath, s.spec.S3DestDir, err)
+	}
+	// if the given path is a directory
+	if info.IsDir() {
+		if err := client.UploadDir(s.spec.S3Storage.Bucket, absFilePath, s.spec.S3DestDir); err != nil {
+			return err
+		}
+	} else {
+		key := filepath.Join(s.spec.S3DestDir, info.Name())
+		if err := client.Upload(s.spec.S3Storage.Bucket, absFilePath, key); err != nil {
+			return err
+		}
+	}
+	resultKey := filepath.Join(s.spec.S3DestDir, step.TestResultFileName)
+	return client.Upload(s.spec.S3Storage.Bucket, path.Join(s.spec.DestDir, step.TestResultFileName), resultKey)
+}
+
+// writeTestResult writes the per case results and the coverage into the dest dir to be uploaded with the merged report.
+func (s *JunitReportStep) writeTestResult(summaryResult *meta.TestSuite) error {
+	commitID, err := testreport.ResolveCommitID(s.spec, s.dirs.Workspace)
+	if err != nil {
+		s.logger.Warnf(fmt.Sprintf("Failed to get the commit of %s: %s", s.spec.RepoDir, err))
+	}
+	result := testreport.NewTestResultReport(commitID, summaryResult.TestCases)
+	if result.Coverage, err = testreport.ResolveCoverage(s.spec, s.dirs.Workspace); err != nil {
+		s.logger.Warnf(fmt.Sprintf("Failed to parse coverage report %s: %s", s.spec.CoveragePath, err))
+	} else if result.Coverage != nil {
+		s.logger.Infof(fmt.Sprintf("Line coverage: %.2f%%, branch coverage: %.2f%%.", result.Coverage.LineRate*100, result.Coverage.BranchRate*100))
+	}
+	return testreport.WriteTestResultReport(s.spec.DestDir, result)
+}
+
+func (s *JunitReportStep) mergeJunitResults(testResultPath string, startTime time.Time) (*meta.TestSuite, error) {
+	summaryResult := &meta.TestSuite{
+		TestCases: []meta.TestCase{},
+	}
+
+	if len(testResultPath) == 0 {
+		return summaryResult, nil
+	}
+
+	files, err := os.ReadDir(testResultPath)
+	if err != nil || len(files) == 0 {
+		return summaryResult, fmt.Errorf("test result files not found in path %s", testResultPath)
+	}
+	infos := make([]os.FileInfo, 0, len(files))
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+
+	// sort and process xml files by modified time
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	for _, file := range infos {
+		if filepath.Ext(file.Name()) != ".xml" {
+			continue
+		}
+		filePath := path.Join(testResultPath, file.Name())
+		xmlBytes, err := os.ReadFile(filePath)
+		if err != nil {
+			s.logger.Warnf(fmt.Sprintf("Read file [%s], error: %v", filePath, err))
+			continue
+		}
+
+		xmlContent := string(xmlBytes)
+		if strings.Contains(xmlContent, replaceTestSuites) || strings.Contains(xmlContent, strings.ToLower(replaceTestSuites)) {
+			var results *meta.TestSuites
+			if err := xml.Unmarshal(xmlBytes, &results); err != nil {
+				s.logger.Warnf(fmt.Sprintf("Unmarshal xml file [%s], error: %v", filePath, err))
+				continue
+			}
+			for _, testSuite := range results.TestSuites {
+				addTestSuite(summaryResult, testSuite)
+			}
+			summaryResult.SuiteType = replaceTestSuites
+		} else {
+			var result *meta.TestSuite
+			if err := xml.Unmarshal(xmlBytes, &result); err != nil {
+				s.logger.Warnf(fmt.Sprintf("Unmarshal xml file [%s], error: %v", filePath, err))
+				continue
+			}
+			addTestSuite(summaryResult, result)
+			summaryResult.SuiteType = replaceTestSuite
+		}
+	}
+	summaryResult.Time = float64(time.Since(startTime).Round(time.Millisecond).Nanoseconds()) / float64(time.Second)
+	return summaryResult, s.writeTestSuite(summaryResult)
+}
+
+// mergeTestReports converts the go test -json or testng reports in the path into a merged junit report.
+func (s *JunitReportStep) mergeTestReports(testResultPath string) (*meta.TestSuite, error) {
+	summaryResult := &meta.TestSuite{
+		TestCases: []meta.TestCase{},
+		SuiteType: replaceTestSuite,
+	}
+
+	files, err := os.ReadDir(testResultPath)
+	if err != nil || len(files) == 0 {
+		return summaryResult, fmt.Errorf("test result files not found in path %s", testResultPath)
+	}
+
+	ext := ".xml"
+	if s.spec.ReportFormat == zadigtypes.TestReportFormatGoTestJSON {
+		ext = ".json"
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ext {
+			continue
+		}
+		filePath := path.Join(testResultPath, file.Name())
+		reportBytes, err := os.ReadFile(filePath)
+		if err != nil {
+			s.logger.Warnf(fmt.Sprintf("Read file [%s], error: %v", filePath, err))
+			continue
+		}
+		results, err := testreport.ParseTestCases(s.spec.ReportFormat, reportBytes)
+		if err != nil {
+			s.logger.Warnf(fmt.Sprintf("Parse file [%s], error: %v", filePath, err))
+			continue
+		}
+
+		for _, result := range results {
+			testCase := meta.TestCase{
+				Name:      result.Name,
+				ClassName: result.Suite,
+				Time:      result.Duration,
+			}
+			switch result.Status {
+			case zadigtypes.TestCaseStatusFailed:
+				testCase.Failure = &meta.Failure{Message: result.FailureMessage}
+				summaryResult.Failures++
+			case zadigtypes.TestCaseStatusError:
+				testCase.Error = &meta.Error{Message: result.FailureMessage}
+				summaryResult.Errors++
+			case zadigtypes.TestCaseStatusSkipped:
+				testCase.Skipped = &meta.Skipped{}
+				summaryResult.Skips++
+			}
+			if testCase.Skipped == nil {
+				summaryResult.Tests++
+			}
+			summaryResult.Time += result.Duration
+			summaryResult.TestCases = append(summaryResult.TestCases, testCase)
+		}
+	}
+	return summaryResult, s.writeTestSuite(summaryResult)
+}
+
+func (s *JunitReportStep) writeTestSuite(summaryResult *meta.TestSuite) error {
+	if summaryResult.SuiteType == replaceTestSuite {
+		summaryResult.Successes = summaryResult.Tests - summaryResult.Failures - summaryResult.Errors
+		summaryResult.Tests = summaryResult.Tests + summaryResult.Skips
+	} else {
+		summaryResult.Successes = summaryResult.Tests - summaryResult.Failures - summaryResult.Errors - summaryResult.Skips
+	}
+	newXMLBytes, err := xml.MarshalIndent(summaryResult, "  ", "    ")
+	if err != nil {
+		return err
+	}
+	newXMLBytes = append([]byte(xml.Header), newXMLBytes...)
+	newXMLStr := strings.Replace(string(newXMLBytes), replaceTestSuite, strings.ToLower(replaceTestSuite), -1)
+
+	if err := os.WriteFile(path.Join(s.spec.DestDir, s.spec.FileName), []byte(newXMLStr), 0644); err != nil {
+		return err
+	}
+	s.logger.Infof(fmt.Sprintf("Merge test results files %s succeeded.", s.spec.FileName))
+	return nil
+}
+
+func addTestSuite(summaryResult, testSuite *meta.TestSuite) {
+	summaryResult.Tests += testSuite.Tests
+	summaryResult.Failures += testSuite.Failures
+	summaryResult.Errors += testSuite.Errors
+
+	for _, tc := range testSuite.TestCases {
+		if tc.Skipped != nil {
+			summaryResult.Skips++
+		}
+	}
+	summaryResult.TestCases = append(summaryResult.TestCases, testSuite.TestCases...)
+}
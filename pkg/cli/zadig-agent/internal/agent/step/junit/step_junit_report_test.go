@@ -0,0 +1,134 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package junit
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/helper/log"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/common/types"
+	jobexecutorstep "github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/step"
+	"github.com/koderover/zadig/pkg/microservice/reaper/core/service/meta"
+	toollog "github.com/koderover/zadig/pkg/tool/log"
+	zadigtypes "github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const junitReport = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="api" tests="3" failures="1" errors="0">
+  <testcase classname="api" name="TestCreate" time="0.5"></testcase>
+  <testcase classname="api" name="TestDelete" time="1.5"><failure message="expected 200">stack</failure></testcase>
+  <testcase classname="api" name="TestSkip" time="0"><skipped></skipped></testcase>
+</testsuite>`
+
+const goTestJSONReport = `{"Action":"run","Package":"pkg/api","Test":"TestCreate"}
+{"Action":"pass","Package":"pkg/api","Test":"TestCreate","Elapsed":0.5}
+{"Action":"run","Package":"pkg/api","Test":"TestDelete"}
+{"Action":"output","Package":"pkg/api","Test":"TestDelete","Output":"expected 200\n"}
+{"Action":"fail","Package":"pkg/api","Test":"TestDelete","Elapsed":1.5}
+`
+
+// TestJunitReportConformance runs the same spec through the job executor and the agent and compares the outputs.
+func TestJunitReportConformance(t *testing.T) {
+	toollog.Init(&toollog.Config{Level: "error"})
+
+	testcases := []struct {
+		format   string
+		fileName string
+		content  string
+	}{
+		{"", "report.xml", junitReport},
+		{zadigtypes.TestReportFormatGoTestJSON, "report.json", goTestJSONReport},
+	}
+
+	for _, tc := range testcases {
+		spec := &step.StepJunitReportSpec{
+			ReportDir:    "reports",
+			FileName:     "merged.xml",
+			ReportFormat: tc.format,
+			CommitID:     "abc",
+		}
+
+		runExecutor := func(workspace string, spec *step.StepJunitReportSpec) error {
+			s, err := jobexecutorstep.NewJunitReportStep(spec, workspace, nil, nil)
+			if err != nil {
+				return err
+			}
+			return s.Run(context.Background())
+		}
+		runAgent := func(workspace string, spec *step.StepJunitReportSpec) error {
+			s, err := NewJunitReportStep(spec, &types.AgentWorkDirs{Workspace: workspace}, nil, nil, &log.JobLogger{})
+			if err != nil {
+				return err
+			}
+			return s.Run(context.Background())
+		}
+
+		executorSuite, executorResult := runJunitReport(t, runExecutor, *spec, tc.fileName, tc.content)
+		agentSuite, agentResult := runJunitReport(t, runAgent, *spec, tc.fileName, tc.content)
+
+		if !reflect.DeepEqual(executorResult, agentResult) {
+			t.Errorf("format %q: test results differ, executor: %+v, agent: %+v", tc.format, executorResult, agentResult)
+		}
+		if len(agentResult.Cases) == 0 || agentResult.Commit != "abc" {
+			t.Errorf("format %q: unexpected test result %+v", tc.format, agentResult)
+		}
+		if executorSuite.Tests != agentSuite.Tests || executorSuite.Failures != agentSuite.Failures ||
+			executorSuite.Skips != agentSuite.Skips || executorSuite.Successes != agentSuite.Successes {
+			t.Errorf("format %q: merged reports differ, executor: %+v, agent: %+v", tc.format, executorSuite, agentSuite)
+		}
+	}
+}
+
+func runJunitReport(t *testing.T, run func(string, *step.StepJunitReportSpec) error, spec step.StepJunitReportSpec, fileName, content string) (*meta.TestSuite, *zadigtypes.TestResultReport) {
+	workspace := t.TempDir()
+	spec.DestDir = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workspace, spec.ReportDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, spec.ReportDir, fileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(workspace, &spec); err != nil {
+		t.Fatalf("run junit report step error: %v", err)
+	}
+
+	suite := &meta.TestSuite{}
+	xmlBytes, err := os.ReadFile(filepath.Join(spec.DestDir, spec.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(xmlBytes, suite); err != nil {
+		t.Fatal(err)
+	}
+
+	result := &zadigtypes.TestResultReport{}
+	resultBytes, err := os.ReadFile(filepath.Join(spec.DestDir, step.TestResultFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(resultBytes, result); err != nil {
+		t.Fatal(err)
+	}
+	return suite, result
+}
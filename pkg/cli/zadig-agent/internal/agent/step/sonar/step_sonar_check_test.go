@@ -0,0 +1,97 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sonar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/helper/log"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/common/types"
+	jobexecutorstep "github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/step"
+	toollog "github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+// TestSonarCheckConformance runs the same spec through the job executor and the agent against a fake sonar server.
+func TestSonarCheckConformance(t *testing.T) {
+	toollog.Init(&toollog.Config{Level: "error"})
+
+	testcases := []struct {
+		gateStatus string
+		passed     bool
+	}{
+		{"OK", true},
+		{"ERROR", false},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.gateStatus, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch r.URL.Path {
+				case "/api/ce/task":
+					fmt.Fprint(w, `{"task":{"id":"task-1","analysisId":"analysis-1","status":"SUCCESS"}}`)
+				case "/api/qualitygates/project_status":
+					fmt.Fprintf(w, `{"projectStatus":{"status":%q,"conditions":[{"status":%q,"metricKey":"coverage","comparator":"LT","errorThreshold":"80","actualValue":"60"}]}}`, tc.gateStatus, tc.gateStatus)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			workspace := t.TempDir()
+			reportDir := filepath.Join(workspace, "src", ".scannerwork")
+			if err := os.MkdirAll(reportDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(reportDir, "report-task.txt"), []byte("projectKey=demo\nceTaskId=task-1\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			spec := &step.StepSonarCheckSpec{SonarServer: server.URL, SonarToken: "token", CheckDir: "src"}
+
+			executorStep, err := jobexecutorstep.NewSonarCheckStep(spec, workspace, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			agentStep, err := NewSonarCheckStep(spec, &types.AgentWorkDirs{Workspace: workspace}, nil, nil, &log.JobLogger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			executorErr, agentErr := make(chan error, 1), make(chan error, 1)
+			go func() { executorErr <- executorStep.Run(context.Background()) }()
+			go func() { agentErr <- agentStep.Run(context.Background()) }()
+
+			executorResult, agentResult := <-executorErr, <-agentErr
+			if (executorResult == nil) != tc.passed || (agentResult == nil) != tc.passed {
+				t.Errorf("unexpected results for gate status %s, executor: %v, agent: %v", tc.gateStatus, executorResult, agentResult)
+			}
+			if executorResult != nil && agentResult != nil && executorResult.Error() != agentResult.Error() {
+				t.Errorf("errors differ, executor: %v, agent: %v", executorResult, agentResult)
+			}
+		})
+	}
+}
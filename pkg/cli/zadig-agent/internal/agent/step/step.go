@@ -18,12 +18,16 @@ package step
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/koderover/zadig/pkg/cli/zadig-agent/helper/log"
 	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/agent/step/archive"
 	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/agent/step/docker"
 	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/agent/step/git"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/agent/step/junit"
 	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/agent/step/script"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/agent/step/sonar"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/agent/step/tools"
 	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/common/types"
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	jobctl "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller/jobcontroller"
@@ -71,16 +75,33 @@ func RunStep(ctx context.Context, jobCtx *jobctl.JobContext, step *commonmodels.
 			return err
 		}
 	case "tools":
-		return nil
+		stepInstance, err = tools.NewToolInstallStep(step.Spec, dirs, envs, secretEnvs, logger)
+		if err != nil {
+			return err
+		}
+	case "junit_report":
+		stepInstance, err = junit.NewJunitReportStep(step.Spec, dirs, envs, secretEnvs, logger)
+		if err != nil {
+			return err
+		}
+	case "sonar_check":
+		stepInstance, err = sonar.NewSonarCheckStep(step.Spec, dirs, envs, secretEnvs, logger)
+		if err != nil {
+			return err
+		}
+	case "distribute_image":
+		stepInstance, err = docker.NewDistributeImageStep(step.Spec, dirs, envs, secretEnvs, logger)
+		if err != nil {
+			return err
+		}
 	case "debug_before":
 		return nil
 	case "debug_after":
 		return nil
 	default:
-		//err := fmt.Errorf("step type: %s does not match any known type", step.StepType)
-		//log.Error(err)
-		//return err
-		logger.Infof("step type: %s does not match any known type", step.StepType)
+		err := fmt.Errorf("step type: %s does not match any known type", step.StepType)
+		logger.Errorf(err.Error())
+		return err
 	}
 	if err := stepInstance.Run(ctx); err != nil {
 		return err
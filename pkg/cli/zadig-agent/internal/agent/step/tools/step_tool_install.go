@@ -0,0 +1,214 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/helper/log"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/agent/step/helper"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/common/types"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/httpclient"
+	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const (
+	constructCachePath = "cache"
+	filepathParam      = "${FILEPATH}"
+)
+
+type ToolInstallStep struct {
+	spec       *step.StepToolInstallSpec
+	envs       []string
+	secretEnvs []string
+	logger     *log.JobLogger
+	dirs       *types.AgentWorkDirs
+}
+
+func NewToolInstallStep(spec interface{}, dirs *types.AgentWorkDirs, envs, secretEnvs []string, logger *log.JobLogger) (*ToolInstallStep, error) {
+	toolInstallStep := &ToolInstallStep{dirs: dirs, envs: envs, secretEnvs: secretEnvs, logger: logger}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return toolInstallStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &toolInstallStep.spec); err != nil {
+		return toolInstallStep, fmt.Errorf("unmarshal spec %s to tool install spec failed", yamlBytes)
+	}
+	return toolInstallStep, nil
+}
+
+func (s *ToolInstallStep) Run(ctx context.Context) error {
+	start := time.Now()
+	s.logger.Infof("Installing tools.")
+	defer func() {
+		s.logger.Infof(fmt.Sprintf("Install tools ended. Duration: %.2f seconds.", time.Since(start).Seconds()))
+	}()
+
+	for _, tool := range s.spec.Installs {
+		s.logger.Infof(fmt.Sprintf("Installing %s %s.", tool.Name, tool.Version))
+		if err := s.runInstallationScripts(tool); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *ToolInstallStep) runInstallationScripts(tool *step.Tool) error {
+	if tool == nil {
+		return nil
+	}
+
+	var tmpPath string
+	scripts := []string{"set -ex"}
+
+	// 获取用户指定环境变量
+	s.envs = append(s.envs, s.environs(tool.Envs)...)
+
+	// 如果应用有配置下载路径
+	if tool.Download != "" {
+		fileName := path.Base(tool.Download)
+		tmpPath = filepath.Join(os.TempDir(), fileName)
+		if err := s.download(tool, fileName, tmpPath); err != nil {
+			return err
+		}
+	}
+
+	for _, command := range tool.Scripts {
+		scripts = append(scripts, strings.ReplaceAll(command, filepathParam, tmpPath))
+	}
+
+	uid, _ := uuid.NewUUID()
+	file := filepath.Join(os.TempDir(), fmt.Sprintf("install_script_%s.sh", uid))
+	if err := os.WriteFile(file, []byte(strings.Join(scripts, "\n")), 0700); err != nil {
+		return fmt.Errorf("write script file error: %v", err)
+	}
+	defer os.Remove(file)
+
+	cmd := exec.Command("/bin/bash", file)
+	cmd.Dir = s.dirs.Workspace
+	cmd.Env = s.envs
+
+	fileName := s.logger.GetLogfilePath()
+	needPersistentLog := fileName != ""
+
+	var wg sync.WaitGroup
+	cmdStdoutReader, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		helper.HandleCmdOutput(cmdStdoutReader, needPersistentLog, fileName, s.secretEnvs, log.GetSimpleLogger())
+	}()
+
+	cmdStdErrReader, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		helper.HandleCmdOutput(cmdStdErrReader, needPersistentLog, fileName, s.secretEnvs, log.GetSimpleLogger())
+	}()
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	wg.Wait()
+
+	return cmd.Wait()
+}
+
+// download fetches the tool package from the s3 cache, falls back to the download url and fills the cache on a miss.
+func (s *ToolInstallStep) download(tool *step.Tool, fileName, tmpPath string) error {
+	if s.spec.S3Storage == nil {
+		return httpclient.Download(tool.Download, tmpPath)
+	}
+
+	s.spec.S3Storage.Subfolder = fmt.Sprintf("%s/%s-v%s", constructCachePath, tool.Name, tool.Version)
+	forcedPathStyle := true
+	if s.spec.S3Storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	s3client, err := s3tool.NewClient(s.spec.S3Storage.Endpoint, s.spec.S3Storage.Ak, s.spec.S3Storage.Sk, s.spec.S3Storage.Region, s.spec.S3Storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return httpclient.Download(tool.Download, tmpPath)
+	}
+
+	objectKey := getObjectPath(fileName, s.spec.S3Storage.Subfolder)
+	if err := s3client.Download(s.spec.S3Storage.Bucket, objectKey, tmpPath); err == nil {
+		return nil
+	}
+
+	// 缓存不存在
+	if err := httpclient.Download(tool.Download, tmpPath); err != nil {
+		return err
+	}
+	if err := s3client.Upload(s.spec.S3Storage.Bucket, tmpPath, objectKey); err != nil {
+		s.logger.Warnf(fmt.Sprintf("Failed to cache package %s: %s", fileName, err))
+	}
+	s.logger.Infof(fmt.Sprintf("Package loaded from url: %s", tool.Download))
+	return nil
+}
+
+// environs keeps the well formed envs of the tool and expands $HOME to the home directory of the agent user.
+func (s *ToolInstallStep) environs(envs []string) []string {
+	home := helper.MakeEnvMap(s.envs)["HOME"]
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+
+	resp := []string{}
+	for _, val := range envs {
+		if val == "" {
+			continue
+		}
+
+		if len(strings.Split(val, "=")) != 2 {
+			continue
+		}
+
+		resp = append(resp, strings.Replace(val, "$HOME", home, -1))
+	}
+	return resp
+}
+
+func getObjectPath(name, subFolder string) string {
+	// target should not be started with /
+	if subFolder != "" {
+		return strings.TrimLeft(filepath.Join(subFolder, name), "/")
+	}
+
+	return strings.TrimLeft(name, "/")
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/helper/log"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/common/types"
+	jobexecutorstep "github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/step"
+	toollog "github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+// TestToolInstallConformance runs the same spec through the job executor and the agent and compares the outputs.
+func TestToolInstallConformance(t *testing.T) {
+	toollog.Init(&toollog.Config{Level: "error"})
+
+	newSpec := func() *step.StepToolInstallSpec {
+		return &step.StepToolInstallSpec{
+			Installs: []*step.Tool{
+				{
+					Name:    "go",
+					Version: "1.21",
+					Envs:    []string{"GOVERSION=1.21", "INVALID"},
+					Scripts: []string{`echo "$GOVERSION ${FILEPATH}" > installed.txt`},
+				},
+			},
+		}
+	}
+
+	executorWorkspace := t.TempDir()
+	executorStep, err := jobexecutorstep.NewToolInstallStep(newSpec(), executorWorkspace, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := executorStep.Run(context.Background()); err != nil {
+		t.Fatalf("run executor tool install step error: %v", err)
+	}
+
+	agentWorkspace := t.TempDir()
+	agentStep, err := NewToolInstallStep(newSpec(), &types.AgentWorkDirs{Workspace: agentWorkspace}, nil, nil, &log.JobLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := agentStep.Run(context.Background()); err != nil {
+		t.Fatalf("run agent tool install step error: %v", err)
+	}
+
+	executorOutput, err := os.ReadFile(filepath.Join(executorWorkspace, "installed.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentOutput, err := os.ReadFile(filepath.Join(agentWorkspace, "installed.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(agentOutput) != "1.21 \n" || string(executorOutput) != string(agentOutput) {
+		t.Errorf("tool install outputs differ, executor: %q, agent: %q", executorOutput, agentOutput)
+	}
+}
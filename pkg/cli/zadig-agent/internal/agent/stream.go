@@ -0,0 +1,168 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/config"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/helper/log"
+	jobexecutor "github.com/koderover/zadig/pkg/cli/zadig-agent/internal/agent/job"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/common"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/network"
+)
+
+const (
+	streamMinBackoff = time.Second
+	streamMaxBackoff = 60 * time.Second
+)
+
+var errStreamStopped = errors.New("job stream stopped")
+
+// ReceiveJob receives jobs over the job stream of zadig server, it reconnects with backoff when the stream is broken
+// and falls back to polling when the server does not support the job stream.
+func (c *AgentController) ReceiveJob(ctx context.Context) {
+	backoff := streamMinBackoff
+	for {
+		stream, err := c.Client.DialJobStream(ctx)
+		if err == network.ErrJobStreamNotSupported {
+			log.Infof("zadig server does not support the job stream, fall back to polling job.")
+			c.PollingJob(ctx)
+			return
+		}
+
+		if err == nil {
+			log.Infof("connected to the job stream.")
+			backoff = streamMinBackoff
+
+			c.Client.SetJobStream(stream)
+			err = c.serveJobStream(ctx, stream)
+			c.Client.SetJobStream(nil)
+			stream.Close()
+			if err == errStreamStopped {
+				close(c.JobChan)
+				return
+			}
+		}
+		log.Errorf("job stream is broken, will reconnect in %s, error: %s", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			log.Infof("stop receiving job, received context cancel signal.")
+			close(c.JobChan)
+			return
+		case <-c.StopPollingJobChan:
+			log.Infof("stop receiving job, received stop signal.")
+			close(c.JobChan)
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > streamMaxBackoff {
+			backoff = streamMaxBackoff
+		}
+	}
+}
+
+func (c *AgentController) serveJobStream(ctx context.Context, stream *network.JobStream) error {
+	msgCh := make(chan *network.StreamMessage)
+	errCh := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			msg, err := stream.Receive()
+			if err != nil {
+				errCh <- err
+				return
+			}
+			select {
+			case msgCh <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(common.DefaultJobReportInterval * time.Second)
+	defer ticker.Stop()
+
+	lastFreeSlots := -1
+	for {
+		select {
+		case <-ctx.Done():
+			log.Infof("stop receiving job, received context cancel signal.")
+			return errStreamStopped
+		case <-c.StopPollingJobChan:
+			log.Infof("stop receiving job, received stop signal.")
+			return errStreamStopped
+		case err := <-errCh:
+			return err
+		case msg := <-msgCh:
+			c.handleStreamMessage(msg)
+			// the server takes a slot for every job it pushes, resync on the next tick even if the job has
+			// finished by then and the free slots look unchanged, or the server would never push jobs again
+			if msg.Type == network.StreamMessageJob {
+				lastFreeSlots = -1
+			}
+		case <-ticker.C:
+			// tell the server how many jobs the agent can take whenever it changes
+			freeSlots := c.freeSlots()
+			if freeSlots == lastFreeSlots {
+				continue
+			}
+			if err := stream.Ready(freeSlots); err != nil {
+				return err
+			}
+			lastFreeSlots = freeSlots
+		}
+	}
+}
+
+func (c *AgentController) handleStreamMessage(msg *network.StreamMessage) {
+	switch msg.Type {
+	case network.StreamMessageJob:
+		if msg.Job == nil || msg.Job.ID == "" {
+			return
+		}
+		log.Infof("received workflow %s job %s from the job stream.", msg.Job.WorkflowName, msg.Job.JobName)
+		c.JobChan <- msg.Job
+		c.CurrentJobNum++
+	case network.StreamMessageCancel:
+		value, ok := c.runningJobs.Load(msg.JobID)
+		if !ok {
+			return
+		}
+		executor := value.(*jobexecutor.JobExecutor)
+		log.Infof("workflow %s job %s is canceled by zadig server.", executor.Job.WorkflowName, executor.Job.JobName)
+		*executor.Cancel = true
+	default:
+		log.Warnf("unknown job stream message type %s", msg.Type)
+	}
+}
+
+func (c *AgentController) freeSlots() int {
+	if config.GetAgentStatus() != common.AGENT_STATUS_RUNNING || !config.GetScheduleWorkflow() {
+		return 0
+	}
+	if freeSlots := config.GetConcurrency() - c.CurrentJobNum; freeSlots > 0 {
+		return freeSlots
+	}
+	return 0
+}
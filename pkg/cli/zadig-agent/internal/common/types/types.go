@@ -57,6 +57,7 @@ type ReportJobParameters struct {
 	JobLog    string `json:"job_log"`
 	JobError  string `json:"job_error"`
 	JobOutput []byte `json:"job_output"`
+	LogOffset *int64 `json:"log_offset,omitempty"`
 }
 
 func GetJobOutputKey(key, outputName string) string {
@@ -66,6 +67,8 @@ func GetJobOutputKey(key, outputName string) string {
 type ReportAgentJobResp struct {
 	JobID     string `json:"job_id"`
 	JobStatus string `json:"job_status"`
+	// LogOffset is the offset of the job log saved by zadig server, it is empty for the servers before the job stream
+	LogOffset *int64 `json:"log_offset,omitempty"`
 }
 
 type AgentWorkDirs struct {
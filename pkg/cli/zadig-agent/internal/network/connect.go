@@ -29,6 +29,7 @@ const (
 	heartbeatBaseUrl  = "/api/aslan/vm/agents/heartbeat"
 	RequestJobBaseUrl = "/api/aslan/vm/agents/job/request"
 	ReportJobBaseUrl  = "/api/aslan/vm/agents/job/report"
+	StreamJobBaseUrl  = "/api/aslan/vm/agents/job/stream"
 )
 
 type RegisterAgentParameters struct {
@@ -18,8 +18,10 @@ package network
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/koderover/zadig/pkg/cli/zadig-agent/config"
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/helper/log"
 	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/common/types"
 	httpclient "github.com/koderover/zadig/pkg/cli/zadig-agent/util/client"
 )
@@ -36,6 +38,9 @@ func GetFullURL(host, base string) string {
 type ZadigClient struct {
 	AgentConfig *AgentConfig
 	Config      *config.AgentConfig
+
+	streamLock sync.RWMutex
+	stream     *JobStream
 }
 
 func NewZadigClient() *ZadigClient {
@@ -74,6 +79,7 @@ type ReportJobRequest struct {
 	JobError  string `json:"job_error"`
 	JobOutput []byte `json:"job_output"`
 	Seq       int    `json:"seq"`
+	LogOffset *int64 `json:"log_offset,omitempty"`
 }
 
 func (c *ZadigClient) ReportJob(parameters *types.ReportJobParameters) (*types.ReportAgentJobResp, error) {
@@ -87,7 +93,18 @@ func (c *ZadigClient) ReportJob(parameters *types.ReportJobParameters) (*types.R
 		request.JobError = parameters.JobError
 		request.JobOutput = parameters.JobOutput
 		request.Seq = parameters.Seq
+		request.LogOffset = parameters.LogOffset
+	}
+
+	// report over the job stream if connected, fall back to http when the stream is broken
+	if stream := c.GetJobStream(); stream != nil {
+		resp, err := stream.ReportJob(request)
+		if err == nil {
+			return resp, nil
+		}
+		log.Warnf("failed to report job %s over the job stream, fall back to http, error: %s", request.JobID, err)
 	}
+
 	resp := new(types.ReportAgentJobResp)
 
 	body, err := httpclient.Post(GetFullURL(c.AgentConfig.URL, ReportJobBaseUrl), httpclient.SetBody(request), httpclient.SetResult(resp))
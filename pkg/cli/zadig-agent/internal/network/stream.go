@@ -0,0 +1,219 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/common/types"
+)
+
+const (
+	StreamMessageReady  = "ready"
+	StreamMessageReport = "report"
+	StreamMessageAck    = "ack"
+	StreamMessageJob    = "job"
+	StreamMessageCancel = "cancel"
+
+	streamAckTimeout   = 10 * time.Second
+	streamWriteTimeout = 10 * time.Second
+	// the server pings every 30 seconds, the stream is considered broken if nothing arrives in time
+	streamReadTimeout = 90 * time.Second
+)
+
+// ErrJobStreamNotSupported is returned when the zadig server is older than the job stream, the agent polls jobs instead.
+var ErrJobStreamNotSupported = errors.New("zadig server does not support the job stream")
+
+type StreamMessage struct {
+	Type      string                    `json:"type"`
+	Seq       int64                     `json:"seq,omitempty"`
+	FreeSlots int                       `json:"free_slots,omitempty"`
+	Report    *ReportJobRequest         `json:"report,omitempty"`
+	Resp      *types.ReportAgentJobResp `json:"resp,omitempty"`
+	Error     string                    `json:"error,omitempty"`
+	Job       *types.ZadigJobTask       `json:"job,omitempty"`
+	JobID     string                    `json:"job_id,omitempty"`
+}
+
+// JobStream is the websocket kept with zadig server, the server pushes jobs and cancel signals over it
+// and the agent reports the job status and log.
+type JobStream struct {
+	conn      *websocket.Conn
+	writeLock sync.Mutex
+	seqLock   sync.Mutex
+	seq       int64
+	pending   map[int64]chan *StreamMessage
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *ZadigClient) DialJobStream(ctx context.Context) (*JobStream, error) {
+	streamURL, err := getStreamURL(c.AgentConfig.URL, c.AgentConfig.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, streamURL, nil)
+	if err != nil {
+		// old servers respond to the unknown route with not found, or unauthorized by the authn of the gateway
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed ||
+			resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return nil, ErrJobStreamNotSupported
+		}
+		return nil, fmt.Errorf("failed to dial the job stream, error: %s", err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(streamReadTimeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetPingHandler(func(data string) error {
+		if err := conn.SetReadDeadline(time.Now().Add(streamReadTimeout)); err != nil {
+			return err
+		}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(streamWriteTimeout))
+	})
+
+	return &JobStream{
+		conn:    conn,
+		pending: make(map[int64]chan *StreamMessage),
+		closed:  make(chan struct{}),
+	}, nil
+}
+
+func (c *ZadigClient) SetJobStream(stream *JobStream) {
+	c.streamLock.Lock()
+	defer c.streamLock.Unlock()
+
+	c.stream = stream
+}
+
+func (c *ZadigClient) GetJobStream() *JobStream {
+	c.streamLock.RLock()
+	defer c.streamLock.RUnlock()
+
+	return c.stream
+}
+
+// Receive reads the next job or cancel message from the server, the acks are delivered to the waiting reports.
+func (s *JobStream) Receive() (*StreamMessage, error) {
+	for {
+		msg := new(StreamMessage)
+		if err := s.conn.ReadJSON(msg); err != nil {
+			s.Close()
+			return nil, err
+		}
+		if err := s.conn.SetReadDeadline(time.Now().Add(streamReadTimeout)); err != nil {
+			s.Close()
+			return nil, err
+		}
+		if msg.Type != StreamMessageAck {
+			return msg, nil
+		}
+
+		s.seqLock.Lock()
+		ch, ok := s.pending[msg.Seq]
+		delete(s.pending, msg.Seq)
+		s.seqLock.Unlock()
+		if ok {
+			ch <- msg
+		}
+	}
+}
+
+// Ready tells the server how many jobs the agent can run now.
+func (s *JobStream) Ready(freeSlots int) error {
+	return s.write(&StreamMessage{Type: StreamMessageReady, FreeSlots: freeSlots})
+}
+
+// ReportJob sends the report and waits for the ack of the server.
+func (s *JobStream) ReportJob(request *ReportJobRequest) (*types.ReportAgentJobResp, error) {
+	ch := make(chan *StreamMessage, 1)
+	s.seqLock.Lock()
+	s.seq++
+	seq := s.seq
+	s.pending[seq] = ch
+	s.seqLock.Unlock()
+
+	defer func() {
+		s.seqLock.Lock()
+		delete(s.pending, seq)
+		s.seqLock.Unlock()
+	}()
+
+	if err := s.write(&StreamMessage{Type: StreamMessageReport, Seq: seq, Report: request}); err != nil {
+		return nil, err
+	}
+
+	select {
+	case ack := <-ch:
+		if ack.Error != "" {
+			return nil, errors.New(ack.Error)
+		}
+		if ack.Resp == nil {
+			return new(types.ReportAgentJobResp), nil
+		}
+		return ack.Resp, nil
+	case <-s.closed:
+		return nil, fmt.Errorf("job stream closed")
+	case <-time.After(streamAckTimeout):
+		return nil, fmt.Errorf("wait for the report ack timeout")
+	}
+}
+
+func (s *JobStream) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.conn.Close()
+	})
+}
+
+func (s *JobStream) write(msg *StreamMessage) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	if err := s.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil {
+		return err
+	}
+	return s.conn.WriteJSON(msg)
+}
+
+func getStreamURL(serverURL, token string) (string, error) {
+	u, err := url.Parse(GetFullURL(strings.TrimSuffix(serverURL, "/"), StreamJobBaseUrl))
+	if err != nil {
+		return "", fmt.Errorf("invalid zadig server url %s, error: %s", serverURL, err)
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package network
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/koderover/zadig/pkg/cli/zadig-agent/internal/common/types"
+)
+
+// TestJobStream runs the agent side of the job stream against a fake server, which acks the reports
+// in reverse order and pushes a job after the agent is ready.
+func TestJobStream(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != StreamJobBaseUrl || r.URL.Query().Get("token") != "token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		reports := make([]*StreamMessage, 0)
+		for {
+			msg := new(StreamMessage)
+			if err := conn.ReadJSON(msg); err != nil {
+				return
+			}
+			switch msg.Type {
+			case StreamMessageReady:
+				if msg.FreeSlots != 2 {
+					t.Errorf("unexpected free slots %d", msg.FreeSlots)
+				}
+				conn.WriteJSON(&StreamMessage{Type: StreamMessageJob, Job: &types.ZadigJobTask{ID: "job-1"}})
+			case StreamMessageReport:
+				reports = append(reports, msg)
+				if len(reports) < 2 {
+					continue
+				}
+				for i := len(reports) - 1; i >= 0; i-- {
+					conn.WriteJSON(&StreamMessage{Type: StreamMessageAck, Seq: reports[i].Seq, Resp: &types.ReportAgentJobResp{JobID: reports[i].Report.JobID}})
+				}
+				reports = reports[:0]
+			}
+		}
+	}))
+	defer server.Close()
+
+	notFound := &ZadigClient{AgentConfig: &AgentConfig{URL: server.URL, Token: "unknown"}}
+	if _, err := notFound.DialJobStream(context.Background()); err != ErrJobStreamNotSupported {
+		t.Fatalf("unexpected error %v for an unknown route", err)
+	}
+
+	client := &ZadigClient{AgentConfig: &AgentConfig{URL: server.URL, Token: "token"}}
+	stream, err := client.DialJobStream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	// the acks are only delivered while receiving
+	received := make(chan *StreamMessage, 1)
+	go func() {
+		for {
+			msg, err := stream.Receive()
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- msg
+		}
+	}()
+
+	if err := stream.Ready(2); err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-received; msg == nil || msg.Type != StreamMessageJob || msg.Job.ID != "job-1" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	results := make(chan string, 2)
+	for _, jobID := range []string{"job-1", "job-2"} {
+		go func(jobID string) {
+			resp, err := stream.ReportJob(&ReportJobRequest{JobID: jobID})
+			if err != nil {
+				results <- err.Error()
+				return
+			}
+			if resp.JobID != jobID {
+				results <- "job " + jobID + " got the ack of " + resp.JobID
+				return
+			}
+			results <- ""
+		}(jobID)
+	}
+	for i := 0; i < 2; i++ {
+		if result := <-results; result != "" {
+			t.Error(result)
+		}
+	}
+
+	stream.Close()
+	if _, err := stream.ReportJob(&ReportJobRequest{JobID: "job-3"}); err == nil {
+		t.Error("expected an error reporting over a closed stream")
+	}
+}
@@ -98,6 +98,30 @@ func ExecutorImage() string {
 	return viper.GetString(setting.ENVExecutorImage)
 }
 
+// BuildKitImage is the rootless buildkitd image of the sidecar running buildkit builds.
+func BuildKitImage() string {
+	if image := viper.GetString(setting.ENVBuildKitImage); image != "" {
+		return image
+	}
+	return "moby/buildkit:v0.12.3-rootless"
+}
+
+// KanikoImage is the kaniko debug image of the sidecar running kaniko builds, the debug image has a shell.
+func KanikoImage() string {
+	if image := viper.GetString(setting.ENVKanikoImage); image != "" {
+		return image
+	}
+	return "gcr.io/kaniko-project/executor:v1.19.2-debug"
+}
+
+// WakeProxyImage is the nginx image serving the waking up page in front of the ingresses of a sleeping environment.
+func WakeProxyImage() string {
+	if image := viper.GetString(setting.ENVWakeProxyImage); image != "" {
+		return image
+	}
+	return "nginx:1.25-alpine"
+}
+
 func KodespaceVersion() string {
 	return viper.GetString(setting.ENVKodespaceVersion)
 }
@@ -48,6 +48,7 @@ const (
 	TestingCronjob     = "test"
 	EnvAnalysisCronjob = "env_analysis"
 	EnvSleepCronjob    = "env_sleep"
+	EnvDriftCronjob    = "env_drift"
 )
 
 var (
@@ -136,6 +137,55 @@ func InCompletedStatus() []Status {
 	return []Status{StatusCreated, StatusRunning, StatusWaiting, StatusQueued, StatusBlocked, QueueItemPending, StatusPrepare, StatusWaitingApprove}
 }
 
+// PriorityClass decides the order in which the waiting workflow tasks are scheduled.
+type PriorityClass string
+
+const (
+	PriorityClassRelease PriorityClass = "release"
+	PriorityClassManual  PriorityClass = "manual"
+	PriorityClassWebhook PriorityClass = "webhook"
+	PriorityClassCron    PriorityClass = "cron"
+)
+
+// Weight returns the scheduling weight of the priority class, the task with a higher weight is scheduled first.
+func (p PriorityClass) Weight() int {
+	switch p {
+	case PriorityClassRelease:
+		return 400
+	case PriorityClassManual:
+		return 300
+	case PriorityClassWebhook:
+		return 200
+	case PriorityClassCron:
+		return 100
+	default:
+		return 0
+	}
+}
+
+func (p PriorityClass) Valid() bool {
+	return p.Weight() > 0
+}
+
+// ConcurrencyGroupPolicy decides what happens to the older tasks of a concurrency group when a new task is created.
+type ConcurrencyGroupPolicy string
+
+const (
+	// ConcurrencyGroupCancel cancels the older waiting and running tasks
+	ConcurrencyGroupCancel ConcurrencyGroupPolicy = "cancel"
+	// ConcurrencyGroupReplaceQueued only cancels the older tasks waiting in the queue, the running ones go on
+	ConcurrencyGroupReplaceQueued ConcurrencyGroupPolicy = "replace_queued"
+)
+
+// QueueBlockedReason tells why a waiting workflow task can not be run yet.
+type QueueBlockedReason string
+
+const (
+	QueueBlockedByGlobalLimit   QueueBlockedReason = "global_limit"
+	QueueBlockedByProjectQuota  QueueBlockedReason = "project_quota"
+	QueueBlockedByWorkflowLimit QueueBlockedReason = "workflow_limit"
+)
+
 type TaskStatus string
 
 const (
@@ -192,6 +242,10 @@ const (
 	StepDistributeImage   StepType = "distribute_image"
 	StepDebugBefore       StepType = "debug_before"
 	StepDebugAfter        StepType = "debug_after"
+	StepCacheRestore      StepType = "cache_restore"
+	StepCacheSave         StepType = "cache_save"
+	StepSupplyChain       StepType = "supply_chain"
+	StepVulnerabilityScan StepType = "vulnerability_scan"
 )
 
 type JobType string
@@ -230,6 +284,8 @@ const (
 	JobMseGrayOffline       JobType = "mse-gray-offline"
 	JobGuanceyunCheck       JobType = "guanceyun-check"
 	JobGrafana              JobType = "grafana"
+	JobApproval             JobType = "approval"
+	JobManualInput          JobType = "manual-input"
 )
 
 const (
@@ -258,6 +314,8 @@ const (
 	NativeApproval   ApprovalType = "native"
 	LarkApproval     ApprovalType = "lark"
 	DingTalkApproval ApprovalType = "dingtalk"
+	WeComApproval    ApprovalType = "wecom"
+	WebhookApproval  ApprovalType = "webhook"
 )
 
 type ApproveOrReject string
@@ -370,6 +428,16 @@ const (
 	CommonEnvCfgTypePvc       CommonEnvCfgType = "PVC"
 )
 
+// DriftDetectionMode decides what to do when the live cluster state of an environment drifts from its definition.
+type DriftDetectionMode string
+
+const (
+	// DriftDetectionModeNotify only records the drift report and notifies the environment's notification configs
+	DriftDetectionModeNotify DriftDetectionMode = "notify"
+	// DriftDetectionModeAutoHeal re-applies the expected manifests of the drifted resources as well
+	DriftDetectionModeAutoHeal DriftDetectionMode = "auto_heal"
+)
+
 // for custom blue-green release job
 const (
 	BlueGreenVerionLabelName = "zadig-blue-green-version"
@@ -469,6 +537,16 @@ const (
 	ForceRun      JobRunPolicy = "force_run"       // force run this job
 )
 
+// StageRunOn marks a stage as a final stage, which runs after the main stages finished.
+type StageRunOn string
+
+const (
+	StageRunOnMain    StageRunOn = ""        // main stage
+	StageRunOnFailure StageRunOn = "failure" // run when the workflow failed, timed out or was rejected
+	StageRunOnCancel  StageRunOn = "cancel"  // run when the workflow was cancelled
+	StageRunOnAlways  StageRunOn = "always"  // always run
+)
+
 const DefaultDeleteDeploymentTimeout = 10 * time.Minute
 
 // Service creation source for openAPI
@@ -107,6 +107,21 @@ type PostBuild struct {
 	ObjectStorageUpload *ObjectStorageUpload `bson:"object_storage_upload"  json:"object_storage_upload"`
 	FileArchive         *FileArchive         `bson:"file_archive,omitempty" json:"file_archive,omitempty"`
 	Scripts             string               `bson:"scripts"                json:"scripts"`
+	SupplyChain         *SupplyChain         `bson:"supply_chain,omitempty" json:"supply_chain,omitempty"`
+}
+
+// SupplyChain generates the SBOM, the signature and the provenance attestation of the image pushed by the docker build.
+type SupplyChain struct {
+	// SBOMFormat is spdx or cyclonedx, no SBOM is generated if it is empty
+	SBOMFormat string `bson:"sbom_format"            json:"sbom_format"`
+	Sign       bool   `bson:"sign"                   json:"sign"`
+	Provenance bool   `bson:"provenance"             json:"provenance"`
+	// SigningKeyID is the key to sign the image and the attestations, it is required by sign and provenance
+	SigningKeyID string `bson:"signing_key_id"         json:"signing_key_id"`
+}
+
+func (s *SupplyChain) Enabled() bool {
+	return s != nil && (s.SBOMFormat != "" || s.Sign || s.Provenance)
 }
 
 type FileArchive struct {
@@ -132,6 +147,14 @@ type DockerBuild struct {
 	TemplateID string `bson:"template_id"            json:"template_id"`
 	// TemplateName is the name of the template dockerfile
 	TemplateName string `bson:"template_name"        json:"template_name"`
+	// Builder is one of docker, buildkit and kaniko, buildkit and kaniko build images without a docker daemon
+	Builder string `bson:"builder"                    json:"builder"`
+	// Platform is the comma separated target platforms, e.g. linux/amd64,linux/arm64
+	Platform string `bson:"platform"                   json:"platform"`
+	// EnableRegistryCache saves the build cache to the registry
+	EnableRegistryCache bool `bson:"enable_registry_cache"   json:"enable_registry_cache"`
+	// CacheImage is the image the build cache is saved to, <image repo>:buildcache by default
+	CacheImage string `bson:"cache_image"              json:"cache_image"`
 }
 
 type JenkinsBuild struct {
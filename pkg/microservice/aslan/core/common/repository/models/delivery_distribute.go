@@ -44,6 +44,8 @@ type DeliveryDistribute struct {
 	EndTime        int64                 `bson:"end_time,omitempty"     json:"end_time,omitempty"`
 	CreatedAt      int64                 `bson:"created_at"             json:"created_at"`
 	DeletedAt      int64                 `bson:"deleted_at"             json:"deleted_at"`
+	// the supply chain artifacts recorded when the image was built
+	SupplyChain *SupplyChainArtifact `bson:"supply_chain,omitempty" json:"supply_chain,omitempty"`
 }
 
 func (DeliveryDistribute) TableName() string {
@@ -0,0 +1,53 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// EnvDriftReport records how the live objects of a service in an environment differ from the manifests
+// rendered from the environment definition, one report is kept per service and replaced on every check.
+type EnvDriftReport struct {
+	ProductName string `bson:"product_name"           json:"product_name"`
+	EnvName     string `bson:"env_name"               json:"env_name"`
+	Production  bool   `bson:"production"             json:"production"`
+	ServiceName string `bson:"service_name"           json:"service_name"`
+	// ReleaseName is set for the services deployed by helm
+	ReleaseName string           `bson:"release_name,omitempty" json:"release_name,omitempty"`
+	Drifted     bool             `bson:"drifted"                json:"drifted"`
+	Resources   []*DriftResource `bson:"resources"              json:"resources"`
+	// Healed is true if the drifted resources have been re-applied in auto heal mode
+	Healed    bool   `bson:"healed"                 json:"healed"`
+	Error     string `bson:"error,omitempty"        json:"error,omitempty"`
+	CheckTime int64  `bson:"check_time"             json:"check_time"`
+}
+
+type DriftResource struct {
+	Kind string `bson:"kind"    json:"kind"`
+	Name string `bson:"name"    json:"name"`
+	// Missing is true if the resource is defined but not found in the cluster
+	Missing bool          `bson:"missing" json:"missing"`
+	Fields  []*DriftField `bson:"fields"  json:"fields"`
+}
+
+type DriftField struct {
+	// Path is the dot separated path of the field, e.g. spec.template.spec.containers[0].image
+	Path     string `bson:"path"     json:"path"`
+	Expected string `bson:"expected" json:"expected"`
+	Live     string `bson:"live"     json:"live"`
+}
+
+func (EnvDriftReport) TableName() string {
+	return "env_drift_report"
+}
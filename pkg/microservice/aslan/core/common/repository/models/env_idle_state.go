@@ -0,0 +1,32 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// EnvIdleState tracks the activity of an environment with an idle policy, it is updated by the idle check.
+type EnvIdleState struct {
+	ProductName string `bson:"product_name"     json:"product_name"`
+	EnvName     string `bson:"env_name"         json:"env_name"`
+	// RequestCount is the sum of the ingress controller request counters of the environment's namespace
+	// seen by the last check, any change of it means there was traffic
+	RequestCount   float64 `bson:"request_count"    json:"request_count"`
+	LastActiveTime int64   `bson:"last_active_time" json:"last_active_time"`
+	UpdateTime     int64   `bson:"update_time"      json:"update_time"`
+}
+
+func (EnvIdleState) TableName() string {
+	return "env_idle_state"
+}
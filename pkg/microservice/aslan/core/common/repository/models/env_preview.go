@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EnvPreviewPolicy is the project level policy of creating a preview environment for each pull request.
+// The preview environments are the sub environments of the base environment, the services changed in the pull request
+// are built and deployed to them by the workflow.
+type EnvPreviewPolicy struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ProductName  string             `bson:"product_name"  json:"product_name"`
+	Enabled      bool               `bson:"enabled"       json:"enabled"`
+	BaseEnv      string             `bson:"base_env"      json:"base_env"`
+	WorkflowName string             `bson:"workflow_name" json:"workflow_name"`
+	MaxEnvs      int                `bson:"max_envs"      json:"max_envs"`
+	// ServiceFolders are the folders of the services in the repository, a service is previewed when the files changed in
+	// the pull request match its folders, or any file is changed if its folders are not given
+	ServiceFolders []*EnvPreviewServiceFolders `bson:"service_folders" json:"service_folders"`
+	// the entrance of the base environment, posted to the pull request with the routing header
+	AccessURL  string `bson:"access_url"    json:"access_url"`
+	UpdateBy   string `bson:"update_by"     json:"update_by"`
+	UpdateTime int64  `bson:"update_time"   json:"update_time"`
+}
+
+// EnvPreviewServiceFolders has the same syntax as the match folders of the webhooks, "/" matches all the files and the
+// folders or the extensions starting with "!" are excluded
+type EnvPreviewServiceFolders struct {
+	ServiceName string   `bson:"service_name" json:"service_name"`
+	Folders     []string `bson:"folders"      json:"folders"`
+}
+
+func (EnvPreviewPolicy) TableName() string {
+	return "env_preview_policy"
+}
+
+// EnvPreview records the preview environment created for a pull request.
+type EnvPreview struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"   json:"id"`
+	ProductName    string             `bson:"product_name"    json:"product_name"`
+	EnvName        string             `bson:"env_name"        json:"env_name"`
+	BaseEnv        string             `bson:"base_env"        json:"base_env"`
+	CodehostID     int                `bson:"codehost_id"     json:"codehost_id"`
+	Source         string             `bson:"source"          json:"source"`
+	RepoOwner      string             `bson:"repo_owner"      json:"repo_owner"`
+	RepoNamespace  string             `bson:"repo_namespace"  json:"repo_namespace"`
+	RepoName       string             `bson:"repo_name"       json:"repo_name"`
+	PrID           int                `bson:"pr_id"           json:"pr_id"`
+	CommitID       string             `bson:"commit_id"       json:"commit_id"`
+	Services       []string           `bson:"services"        json:"services"`
+	NotificationID string             `bson:"notification_id" json:"notification_id"`
+	CreateTime     int64              `bson:"create_time"     json:"create_time"`
+	UpdateTime     int64              `bson:"update_time"     json:"update_time"`
+	// Slot is unique in the project and less than the max envs of the policy, so that the limit holds when the
+	// preview environments are created concurrently
+	Slot int `bson:"slot" json:"-"`
+}
+
+func (EnvPreview) TableName() string {
+	return "env_preview"
+}
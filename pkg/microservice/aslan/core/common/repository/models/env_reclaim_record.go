@@ -0,0 +1,37 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// EnvReclaimRecord is saved when an expired environment is deleted by the TTL reclaim.
+type EnvReclaimRecord struct {
+	ProductName string `bson:"product_name" json:"product_name"`
+	EnvName     string `bson:"env_name"     json:"env_name"`
+	ClusterID   string `bson:"cluster_id"   json:"cluster_id"`
+	Namespace   string `bson:"namespace"    json:"namespace"`
+	Owner       string `bson:"owner"        json:"owner"`
+	ExpireTime  int64  `bson:"expire_time"  json:"expire_time"`
+	SleepTime   int64  `bson:"sleep_time"   json:"sleep_time"`
+	DeleteTime  int64  `bson:"delete_time"  json:"delete_time"`
+	// CPURequest in millicores and MemoryRequest in bytes are the resources requested by the workloads
+	// of the environment before it was put to sleep
+	CPURequest    int64 `bson:"cpu_request"    json:"cpu_request"`
+	MemoryRequest int64 `bson:"memory_request" json:"memory_request"`
+}
+
+func (EnvReclaimRecord) TableName() string {
+	return "env_reclaim_record"
+}
@@ -42,6 +42,20 @@ type IMApp struct {
 	DingTalkAesKey                  string `json:"dingtalk_aes_key" bson:"dingtalk_aes_key"`
 	DingTalkToken                   string `json:"dingtalk_token" bson:"dingtalk_token"`
 	DingTalkDefaultApprovalFormCode string `json:"-" bson:"dingtalk_default_approval_form_code"`
+
+	// WeCom fields
+	WeComCorpID string `json:"wecom_corp_id" bson:"wecom_corp_id"`
+	// WeComAgentSecret is the secret of the approval app
+	WeComAgentSecret string `json:"wecom_agent_secret" bson:"wecom_agent_secret"`
+	// WeComTemplateID is the approval template, the approval form is filled into its first text control
+	WeComTemplateID string `json:"wecom_template_id" bson:"wecom_template_id"`
+
+	// Webhook fields
+	WebhookURL string `json:"webhook_url" bson:"webhook_url"`
+	// WebhookStatusURL is polled for the approval result if the approval system can not call back
+	WebhookStatusURL string `json:"webhook_status_url" bson:"webhook_status_url"`
+	// WebhookSecret signs the requests to and the callbacks from the approval system
+	WebhookSecret string `json:"webhook_secret" bson:"webhook_secret"`
 }
 
 func (IMApp) TableName() string {
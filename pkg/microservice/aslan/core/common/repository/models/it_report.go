@@ -53,6 +53,8 @@ type TestReport struct {
 	FunctionTestSuite     *TestSuite                `bson:"function_test_suite,omitempty"         json:"functionTestSuite,omitempty"`
 	PerformanceTestSuites []*PerformanceTestSuite   `bson:"performance_test_suite,omitempty"      json:"performanceTestSuite,omitempty"`
 	Security              map[string]map[string]int `bson:"security,omitempty"                    json:"security,omitempty"`
+	// Coverage is only available for the testing jobs of workflow v4
+	Coverage *TestCoverage `bson:"-" json:"coverage,omitempty"`
 }
 
 type TestSuite struct {
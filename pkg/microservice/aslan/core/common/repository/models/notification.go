@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"net/url"
+	"strings"
 	"text/template"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -46,6 +47,8 @@ type Notification struct {
 	Revision     string              `bson:"revision"                     json:"revision"`
 	RepoOwner    string              `bson:"repo_owner"                   json:"repo_owner"`
 	RepoName     string              `bson:"repo_name"                    json:"repo_name"`
+
+	EnvPreview *EnvPreviewInfo `bson:"env_preview,omitempty" json:"env_preview,omitempty"`
 }
 
 type PrTaskInfo struct {
@@ -55,6 +58,15 @@ type PrTaskInfo struct {
 	ProductName      string `bson:"product_name,omitempty"              json:"product_name,omitempty"`
 }
 
+type EnvPreviewInfo struct {
+	ProductName string   `bson:"product_name"  json:"product_name"`
+	EnvName     string   `bson:"env_name"      json:"env_name"`
+	Services    []string `bson:"services"      json:"services"`
+	AccessURL   string   `bson:"access_url"    json:"access_url"`
+	Header      string   `bson:"header"        json:"header"`
+	Deleted     bool     `bson:"deleted"       json:"deleted"`
+}
+
 type NotificationTask struct {
 	ProductName         string            `bson:"product_name"            json:"product_name"`
 	WorkflowName        string            `bson:"workflow_name"           json:"workflow_name"`
@@ -171,6 +183,21 @@ func (n *Notification) CreateCommentBody() (comment string, err error) {
 		}
 	}
 
+	if n.EnvPreview != nil {
+		var content string
+		if n.EnvPreview.Deleted {
+			content = fmt.Sprintf("预览环境：%s 已清理 \n\n", n.EnvPreview.EnvName)
+		} else {
+			content = fmt.Sprintf("预览环境：[%s]({{$.BaseURI}}/v1/projects/detail/%s/envs/detail?envName=%s) 服务：%s \n\n", n.EnvPreview.EnvName, n.EnvPreview.ProductName, n.EnvPreview.EnvName, strings.Join(n.EnvPreview.Services, ", "))
+			if n.EnvPreview.AccessURL != "" {
+				content += fmt.Sprintf("访问地址：%s 请求头：`%s: %s` \n\n", n.EnvPreview.AccessURL, n.EnvPreview.Header, n.EnvPreview.EnvName)
+			} else {
+				content += fmt.Sprintf("请求头：`%s: %s` \n\n", n.EnvPreview.Header, n.EnvPreview.EnvName)
+			}
+		}
+		tmplSource = fmt.Sprintf("%s%s", content, tmplSource)
+	}
+
 	tmpl := template.Must(template.New("comment").Parse(tmplSource))
 	buffer := bytes.NewBufferString("")
 
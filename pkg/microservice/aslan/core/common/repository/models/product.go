@@ -78,6 +78,15 @@ type Product struct {
 	// GlobalValues for k8s projects
 	GlobalVariables []*commontypes.GlobalVariableKV `bson:"global_variables,omitempty"     json:"global_variables,omitempty"`
 
+	// drift detection between the environment definition and the live cluster state
+	DriftDetectionConfig *DriftDetectionConfig `bson:"drift_detection_config,omitempty" json:"drift_detection_config,omitempty"`
+
+	// put the environment to sleep when there is no ingress traffic and no deployment for a while
+	IdlePolicy *EnvIdlePolicy `bson:"idle_policy,omitempty" json:"idle_policy,omitempty"`
+
+	// reclaim the environment when it expires, it is put to sleep first and deleted after a grace period
+	TTLPolicy *EnvTTLPolicy `bson:"ttl_policy,omitempty" json:"ttl_policy,omitempty"`
+
 	// For production environment
 	Production bool   `json:"production" bson:"production"`
 	Alias      string `json:"alias" bson:"alias"`
@@ -88,6 +97,8 @@ type NotificationEvent string
 const (
 	NotificationEventAnalyzerNoraml   NotificationEvent = "notification_event_analyzer_normal"
 	NotificationEventAnalyzerAbnormal NotificationEvent = "notification_event_analyzer_abnormal"
+	NotificationEventDriftDetected    NotificationEvent = "notification_event_drift_detected"
+	NotificationEventEnvExpiring      NotificationEvent = "notification_event_env_expiring"
 )
 
 type WebHookType string
@@ -124,6 +135,36 @@ type AnalysisConfig struct {
 	ResourceTypes []ResourceType `bson:"resource_types" json:"resource_types"`
 }
 
+type DriftDetectionConfig struct {
+	Mode config.DriftDetectionMode `bson:"mode" json:"mode"`
+}
+
+type EnvIdlePolicy struct {
+	Enable    bool `bson:"enable"     json:"enable"`
+	IdleHours int  `bson:"idle_hours" json:"idle_hours"`
+	// WakeOnRequest puts a wake proxy in front of the ingresses while the environment is sleeping
+	WakeOnRequest bool `bson:"wake_on_request" json:"wake_on_request"`
+	// WakeToken identifies the environment in the requests sent by the wake proxy
+	WakeToken string `bson:"wake_token" json:"-"`
+}
+
+type EnvTTLPolicy struct {
+	TTLHours int `bson:"ttl_hours" json:"ttl_hours"`
+	// RemindHours is how many hours before the expiry the owner is reminded
+	RemindHours int `bson:"remind_hours" json:"remind_hours"`
+	// GraceHours is how many hours the environment stays asleep after the expiry before it is deleted
+	GraceHours int    `bson:"grace_hours"  json:"grace_hours"`
+	Owner      string `bson:"owner"        json:"owner"`
+	// OwnerIMAppID is the lark or dingtalk app the reminders are sent to the owner with, no direct message if empty
+	OwnerIMAppID string `bson:"owner_im_app_id" json:"owner_im_app_id"`
+	ExpireTime   int64  `bson:"expire_time"  json:"expire_time"`
+	Reminded     bool   `bson:"reminded"     json:"reminded"`
+	// SleepTime is when the environment was put to sleep at the expiry, 0 if it has not expired yet
+	SleepTime int64 `bson:"sleep_time" json:"sleep_time"`
+	// ExtendToken identifies the environment in the extend link sent with the reminders, it is rotated on every reminder
+	ExtendToken string `bson:"extend_token" json:"-"`
+}
+
 type CreateUpdateCommonEnvCfgArgs struct {
 	EnvName              string                        `json:"env_name"`
 	ProductName          string                        `json:"product_name"`
@@ -17,11 +17,14 @@
 package models
 
 import (
+	"fmt"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
 )
 
 type Scanning struct {
@@ -45,6 +48,8 @@ type Scanning struct {
 	AdvancedSetting  *ScanningAdvancedSetting `bson:"advanced_setting"      json:"advanced_setting"`
 	CheckQualityGate bool                     `bson:"check_quality_gate"    json:"check_quality_gate"`
 	Outputs          []*Output                `bson:"outputs"               json:"outputs"`
+	// TrivySetting is for trivy type only
+	TrivySetting *TrivySetting `bson:"trivy_setting" json:"trivy_setting"`
 
 	CreatedAt int64  `bson:"created_at" json:"created_at"`
 	UpdatedAt int64  `bson:"updated_at" json:"updated_at"`
@@ -83,6 +88,38 @@ type ScanningHook struct {
 	IsManual     bool                   `bson:"is_manual"     json:"is_manual"`
 }
 
+// TrivySetting scans the image or a directory of the workspace for vulnerabilities after the script of the scanning.
+type TrivySetting struct {
+	// Target is image or filesystem
+	Target string `bson:"target"         json:"target"`
+	// Image supports variables, e.g. {{.job.build.service.output.IMAGE}} in workflows
+	Image         string                          `bson:"image"          json:"image"`
+	ScanDir       string                          `bson:"scan_dir"       json:"scan_dir"`
+	Severities    []string                        `bson:"severities"     json:"severities"`
+	IgnoreUnfixed bool                            `bson:"ignore_unfixed" json:"ignore_unfixed"`
+	QualityGate   *types.VulnerabilityQualityGate `bson:"quality_gate"   json:"quality_gate"`
+}
+
+func (s *TrivySetting) Validate() error {
+	switch s.Target {
+	case step.VulnerabilityScanTargetImage:
+		if s.Image == "" {
+			return fmt.Errorf("image is required to scan an image")
+		}
+	case step.VulnerabilityScanTargetFilesystem:
+	default:
+		return fmt.Errorf("unknown scanning target %s", s.Target)
+	}
+	if s.QualityGate != nil {
+		for _, rule := range s.QualityGate.Rules {
+			if rule.MaxCount < 0 {
+				return fmt.Errorf("max count of the quality gate rule cannot be negative")
+			}
+		}
+	}
+	return nil
+}
+
 type SonarInfo struct {
 	ServerAddress string `bson:"server_address" json:"server_address"`
 	Token         string `bson:"token"          json:"token"`
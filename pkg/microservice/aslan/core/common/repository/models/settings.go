@@ -16,7 +16,11 @@ limitations under the License.
 
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/koderover/zadig/pkg/types"
+)
 
 type SystemSetting struct {
 	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
@@ -27,6 +31,19 @@ type SystemSetting struct {
 	Security            *SecuritySettings  `bson:"security" json:"security"`
 	Privacy             *PrivacySettings   `bson:"privacy"  json:"privacy"`
 	UpdateTime          int64              `bson:"update_time" json:"update_time"`
+	// VulnerabilityDB is the mirror of the vulnerability database used by the trivy scanner
+	VulnerabilityDB *types.VulnerabilityDBSettings `bson:"vulnerability_db" json:"vulnerability_db"`
+	// ProjectConcurrency limits the active workflow tasks of a project, a project not listed is limited by DefaultProjectConcurrency
+	ProjectConcurrency []*ProjectConcurrency `bson:"project_concurrency" json:"project_concurrency"`
+	// DefaultProjectConcurrency 0 means no limit
+	DefaultProjectConcurrency int64 `bson:"default_project_concurrency" json:"default_project_concurrency"`
+	// ReleasePriorityProjects are the projects whose workflows and triggers can use the release priority class
+	ReleasePriorityProjects []string `bson:"release_priority_projects" json:"release_priority_projects"`
+}
+
+type ProjectConcurrency struct {
+	ProjectName         string `bson:"project_name" json:"project_name"`
+	WorkflowConcurrency int64  `bson:"workflow_concurrency" json:"workflow_concurrency"`
 }
 
 type Theme struct {
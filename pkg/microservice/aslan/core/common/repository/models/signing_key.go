@@ -0,0 +1,39 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// SigningKey is a cosign key pair used by the build jobs to sign images and attestations.
+type SigningKey struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"       json:"id,omitempty"`
+	Name        string             `bson:"name"                json:"name"`
+	Description string             `bson:"description"         json:"description"`
+	// PrivateKey is the PEM encoded encrypted private key, it is never returned by the APIs.
+	PrivateKey string `bson:"-"                   json:"private_key,omitempty"`
+	Password   string `bson:"-"                   json:"password,omitempty"`
+	PublicKey  string `bson:"public_key"          json:"public_key"`
+	UpdateBy   string `bson:"update_by"           json:"update_by"`
+	UpdateTime int64  `bson:"update_time"         json:"update_time"`
+	// the private key and its password are saved encrypted by the system aes key
+	EncryptedPrivateKey string `bson:"encrypted_private_key" json:"-"`
+	EncryptedPassword   string `bson:"encrypted_password"    json:"-"`
+}
+
+func (SigningKey) TableName() string {
+	return "signing_key"
+}
@@ -25,6 +25,10 @@ const (
 	// 工作流任务的留存
 	WorkflowTaskRetention     CapacityTarget = "WorkflowTaskRetention"
 	DefaultWorkflowRemainDays int            = 365
+	// 构建缓存的留存，超过天数未使用或者超过总大小时按最近最少使用的顺序清理
+	WorkflowCacheRetention CapacityTarget = "WorkflowCacheRetention"
+	DefaultCacheRemainDays int            = 7
+	DefaultCacheMaxSizeMB  int64          = 50 * 1024
 )
 
 var DefaultWorkflowTaskRetention = &CapacityStrategy{
@@ -34,10 +38,20 @@ var DefaultWorkflowTaskRetention = &CapacityStrategy{
 	},
 }
 
+var DefaultWorkflowCacheRetention = &CapacityStrategy{
+	Target: WorkflowCacheRetention,
+	Retention: &RetentionConfig{
+		MaxDays:   DefaultCacheRemainDays,
+		MaxSizeMB: DefaultCacheMaxSizeMB,
+	},
+}
+
 // RetentionConfig 资源留存相关的配置
 type RetentionConfig struct {
 	MaxDays  int `bson:"max_days"      json:"max_days"`  // 最多几天
 	MaxItems int `bson:"max_items"     json:"max_items"` // 最多几条
+	// MaxSizeMB is only used by WorkflowCacheRetention
+	MaxSizeMB int64 `bson:"max_size_mb,omitempty" json:"max_size_mb,omitempty"` // 最大总大小
 }
 
 // CapacityStrategy 系统配额策略
@@ -0,0 +1,43 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// SupplyChainArtifact records the SBOM, signature and provenance attestation generated for an image by a build job,
+// the references are the cosign tags of the image digest in the registry.
+type SupplyChainArtifact struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"       json:"id,omitempty"`
+	Image         string             `bson:"image"               json:"image"`
+	Digest        string             `bson:"digest"              json:"digest"`
+	Signature     string             `bson:"signature"           json:"signature,omitempty"`
+	SBOM          string             `bson:"sbom"                json:"sbom,omitempty"`
+	SBOMFormat    string             `bson:"sbom_format"         json:"sbom_format,omitempty"`
+	Attestation   string             `bson:"attestation"         json:"attestation,omitempty"`
+	SigningKeyID  string             `bson:"signing_key_id"      json:"signing_key_id,omitempty"`
+	ProjectName   string             `bson:"project_name"        json:"project_name"`
+	WorkflowName  string             `bson:"workflow_name"       json:"workflow_name"`
+	TaskID        int64              `bson:"task_id"             json:"task_id"`
+	JobName       string             `bson:"job_name"            json:"job_name"`
+	ServiceName   string             `bson:"service_name"        json:"service_name"`
+	ServiceModule string             `bson:"service_module"      json:"service_module"`
+	CreateTime    int64              `bson:"create_time"         json:"create_time"`
+}
+
+func (SupplyChainArtifact) TableName() string {
+	return "image_supply_chain"
+}
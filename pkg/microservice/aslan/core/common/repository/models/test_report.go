@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// TestCaseResult is the result of a test case in a testing job of a workflow task.
+type TestCaseResult struct {
+	ID                   primitive.ObjectID `bson:"_id,omitempty"   json:"id,omitempty"`
+	ProjectName          string             `bson:"project_name"    json:"project_name"`
+	WorkflowName         string             `bson:"workflow_name"   json:"workflow_name"`
+	TaskID               int64              `bson:"task_id"         json:"task_id"`
+	JobName              string             `bson:"job_name"        json:"job_name"`
+	TestName             string             `bson:"test_name"       json:"test_name"`
+	RepoName             string             `bson:"repo_name"       json:"repo_name"`
+	Branch               string             `bson:"branch"          json:"branch"`
+	PR                   int                `bson:"pr"              json:"pr"`
+	Commit               string             `bson:"commit"          json:"commit"`
+	types.TestCaseResult `bson:",inline"     json:",inline"`
+	// Flaky is set when the case both passed and failed on the same commit
+	Flaky      bool  `bson:"flaky"       json:"flaky"`
+	CreateTime int64 `bson:"create_time" json:"create_time"`
+}
+
+func (TestCaseResult) TableName() string {
+	return "test_case_result"
+}
+
+// TestCoverage is the coverage of a testing job, the delta is compared with the latest coverage on the target branch.
+type TestCoverage struct {
+	ID           primitive.ObjectID     `bson:"_id,omitempty"   json:"id,omitempty"`
+	ProjectName  string                 `bson:"project_name"    json:"project_name"`
+	WorkflowName string                 `bson:"workflow_name"   json:"workflow_name"`
+	TaskID       int64                  `bson:"task_id"         json:"task_id"`
+	JobName      string                 `bson:"job_name"        json:"job_name"`
+	TestName     string                 `bson:"test_name"       json:"test_name"`
+	RepoName     string                 `bson:"repo_name"       json:"repo_name"`
+	Branch       string                 `bson:"branch"          json:"branch"`
+	PR           int                    `bson:"pr"              json:"pr"`
+	Commit       string                 `bson:"commit"          json:"commit"`
+	Coverage     *types.CoverageSummary `bson:"coverage"        json:"coverage"`
+	// the task the coverage is compared with, empty if there is no coverage on the target branch yet
+	BaseWorkflowName string  `bson:"base_workflow_name" json:"base_workflow_name"`
+	BaseTaskID       int64   `bson:"base_task_id"       json:"base_task_id"`
+	BaseLineRate     float64 `bson:"base_line_rate"     json:"base_line_rate"`
+	LineRateDelta    float64 `bson:"line_rate_delta"    json:"line_rate_delta"`
+	BaseBranchRate   float64 `bson:"base_branch_rate"   json:"base_branch_rate"`
+	BranchRateDelta  float64 `bson:"branch_rate_delta"  json:"branch_rate_delta"`
+	CreateTime       int64   `bson:"create_time"        json:"create_time"`
+}
+
+func (TestCoverage) TableName() string {
+	return "test_coverage"
+}
@@ -42,6 +42,11 @@ type Testing struct {
 	TestReportPath string `bson:"test_report_path"         json:"test_report_path"`
 	Threshold      int    `bson:"threshold"                json:"threshold"`
 	TestType       string `bson:"test_type"                json:"test_type"`
+	// TestResultFormat is the format of the files in TestResultPath, junit by default
+	TestResultFormat string `bson:"test_result_format"       json:"test_result_format"`
+	// CoverageReportPath is collected together with the test results
+	CoverageReportPath string `bson:"coverage_report_path"     json:"coverage_report_path"`
+	CoverageFormat     string `bson:"coverage_format"          json:"coverage_format"`
 
 	// TODO: Deprecated.
 	Caches []string `bson:"caches"                   json:"caches"`
@@ -41,6 +41,7 @@ type VMJob struct {
 	JobCtx        string             `bson:"job_ctx"                json:"job_ctx"`
 	LogFile       string             `bson:"log_file"               json:"log_file"`
 	Outputs       []*job.JobOutput   `bson:"outputs"                json:"outputs"`
+	LogOffset     int64              `bson:"log_offset"             json:"-"`
 }
 
 type ReportJobParameters struct {
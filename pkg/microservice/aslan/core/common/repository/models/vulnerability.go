@@ -0,0 +1,44 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// VulnerabilityScanResult is the result of a trivy scanning, it is kept per job of the task.
+// the task of a standalone scanning is saved with the scanning task name as the workflow name and the scanning name as the job name.
+type VulnerabilityScanResult struct {
+	ID                primitive.ObjectID            `bson:"_id,omitempty"         json:"id,omitempty"`
+	ProjectName       string                        `bson:"project_name"          json:"project_name"`
+	WorkflowName      string                        `bson:"workflow_name"         json:"workflow_name"`
+	TaskID            int64                         `bson:"task_id"               json:"task_id"`
+	JobName           string                        `bson:"job_name"              json:"job_name"`
+	Target            string                        `bson:"target"                json:"target"`
+	Image             string                        `bson:"image"                 json:"image,omitempty"`
+	Summary           *types.VulnerabilitySummary   `bson:"summary"               json:"summary"`
+	Findings          []*types.VulnerabilityFinding `bson:"findings"              json:"findings"`
+	QualityGatePassed bool                          `bson:"quality_gate_passed"   json:"quality_gate_passed"`
+	Violations        []string                      `bson:"violations"            json:"violations"`
+	CreateTime        int64                         `bson:"create_time"           json:"create_time"`
+}
+
+func (VulnerabilityScanResult) TableName() string {
+	return "vulnerability_scan_result"
+}
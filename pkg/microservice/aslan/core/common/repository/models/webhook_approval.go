@@ -0,0 +1,33 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+// WebhookApprovalResult is the latest result reported by a webhook approval system, it is saved by the callback
+// and read by the aslan replica waiting for the approval.
+type WebhookApprovalResult struct {
+	ApprovalID string `json:"approval_id" bson:"approval_id"`
+	// IMAppID is the webhook im app which the approval was sent to
+	IMAppID string `json:"im_app_id" bson:"im_app_id"`
+	// Status: pending, approved or rejected
+	Status     string                 `json:"status"      bson:"status"`
+	Approvers  []*WebhookApprovalUser `json:"approvers"   bson:"approvers"`
+	UpdateTime int64                  `json:"update_time" bson:"update_time"`
+}
+
+func (WebhookApprovalResult) TableName() string {
+	return "webhook_approval_result"
+}
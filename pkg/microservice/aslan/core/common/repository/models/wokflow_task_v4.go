@@ -17,6 +17,7 @@ limitations under the License.
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -31,6 +32,7 @@ type WorkflowTask struct {
 	TaskID              int64              `bson:"task_id"                   json:"task_id"`
 	WorkflowName        string             `bson:"workflow_name"             json:"workflow_name"`
 	WorkflowHash        string             `bson:"workflow_hash"             json:"workflow_hash"`
+	WorkflowRevision    int64              `bson:"workflow_revision"         json:"workflow_revision"`
 	WorkflowDisplayName string             `bson:"workflow_display_name"     json:"workflow_display_name"`
 	Params              []*Param           `bson:"params"                    json:"params"`
 	WorkflowArgs        *WorkflowV4        `bson:"workflow_args"             json:"workflow_args"`
@@ -54,6 +56,15 @@ type WorkflowTask struct {
 	IsRestart           bool               `bson:"is_restart"                json:"is_restart"`
 	IsDebug             bool               `bson:"is_debug"                  json:"is_debug"`
 	ShareStorages       []*ShareStorage    `bson:"share_storages"            json:"share_storages"`
+	// SupplyChainArtifacts are the SBOMs, signatures and provenance attestations generated by the build jobs
+	SupplyChainArtifacts []*SupplyChainArtifact `bson:"supply_chain_artifacts"    json:"supply_chain_artifacts,omitempty"`
+	// PriorityClass is resolved from the workflow, the trigger and the task creator when the task is created
+	PriorityClass config.PriorityClass `bson:"priority_class,omitempty"  json:"priority_class,omitempty"`
+	// ConcurrencyGroupKey is the rendered key of the concurrency group of the workflow
+	ConcurrencyGroupKey string `bson:"concurrency_group_key,omitempty" json:"concurrency_group_key,omitempty"`
+	// SupersededBy is the id of the task in the same concurrency group which cancelled this task
+	SupersededBy int64  `bson:"superseded_by,omitempty"         json:"superseded_by,omitempty"`
+	CancelReason string `bson:"cancel_reason,omitempty"         json:"cancel_reason,omitempty"`
 }
 
 func (WorkflowTask) TableName() string {
@@ -69,6 +80,10 @@ type StageTask struct {
 	Approval  *Approval     `bson:"approval"      json:"approval,omitempty"`
 	Jobs      []*JobTask    `bson:"jobs"          json:"jobs,omitempty"`
 	Error     string        `bson:"error"         json:"error"`
+	When      string        `bson:"when"          json:"when,omitempty"`
+	// SkipReason explains why the stage was skipped by its condition.
+	SkipReason string            `bson:"skip_reason"   json:"skip_reason,omitempty"`
+	RunOn      config.StageRunOn `bson:"run_on"        json:"run_on,omitempty"`
 }
 
 type JobTask struct {
@@ -94,6 +109,41 @@ type JobTask struct {
 	ServiceModules   []*WorkflowServiceModule `bson:"service_modules"     json:"service_modules"`
 	Infrastructure   string                   `bson:"infrastructure"      json:"infrastructure"`
 	VMLabels         []string                 `bson:"vm_labels"           json:"vm_labels"`
+	RetryPolicy      *JobRetryPolicy          `bson:"retry_policy"        json:"retry_policy"`
+	// OriginName is the name of the job in the workflow definition which the job task is created from.
+	OriginName string   `bson:"origin_name"         json:"origin_name"`
+	Needs      []string `bson:"needs"               json:"needs"`
+	When       string   `bson:"when"                json:"when,omitempty"`
+	// Matrix is set on the job tasks expanded from a job matrix, they share the MaxParallel and FailFast settings.
+	Matrix *JobTaskMatrix `bson:"matrix,omitempty"    json:"matrix,omitempty"`
+	// SkipReason explains why the job was skipped by its condition or the condition of its stage.
+	SkipReason string `bson:"skip_reason"         json:"skip_reason,omitempty"`
+	// Attempts keeps the result of the previous failed runs when the job is retried, the current run is the job itself.
+	Attempts []*JobTaskAttempt `bson:"attempts"            json:"attempts"`
+}
+
+type JobTaskMatrix struct {
+	// Group is the key of the job the combination is expanded from.
+	Group       string            `bson:"group"               json:"group"`
+	Values      map[string]string `bson:"values"              json:"values"`
+	MaxParallel int64             `bson:"max_parallel"        json:"max_parallel"`
+	FailFast    bool              `bson:"fail_fast"           json:"fail_fast"`
+}
+
+type JobTaskAttempt struct {
+	Attempt    int64         `bson:"attempt"             json:"attempt"`
+	K8sJobName string        `bson:"k8s_job_name"        json:"k8s_job_name"`
+	Status     config.Status `bson:"status"              json:"status"`
+	Error      string        `bson:"error"               json:"error"`
+	StartTime  int64         `bson:"start_time"          json:"start_time"`
+	EndTime    int64         `bson:"end_time"            json:"end_time"`
+	// LogFile is the name of the log saved for this attempt, empty if the log was not saved.
+	LogFile string `bson:"log_file"            json:"log_file"`
+}
+
+// JobAttemptLogName returns the name of the log of the given attempt of a job, attempt starts from 1.
+func JobAttemptLogName(jobName string, attempt int64) string {
+	return fmt.Sprintf("%s-attempt-%d", jobName, attempt)
 }
 
 type TaskJobInfo struct {
@@ -192,6 +242,8 @@ type JobTaskDeploySpec struct {
 	// for compatibility
 	ServiceModule string `bson:"service_module"                   json:"service_module"                      yaml:"-"`
 	Image         string `bson:"image"                            json:"image"                               yaml:"-"`
+	// only the images signed by zadig builds can be deployed to production environments when it is set
+	VerifyImageSignature bool `bson:"verify_image_signature"           json:"verify_image_signature"              yaml:"verify_image_signature"`
 }
 
 type DeployServiceModule struct {
@@ -502,6 +554,19 @@ type JobTaskOfflineServiceEvent struct {
 	Error       string        `bson:"error" json:"error" yaml:"error"`
 }
 
+type JobTaskApprovalSpec struct {
+	Approval *Approval `bson:"approval" json:"approval" yaml:"approval"`
+}
+
+type JobTaskManualInputSpec struct {
+	Description string   `bson:"description" json:"description" yaml:"description"`
+	Timeout     int      `bson:"timeout"     json:"timeout"     yaml:"timeout"`
+	Users       []*User  `bson:"users"       json:"users"       yaml:"users"`
+	Fields      []*Param `bson:"fields"      json:"fields"      yaml:"fields"`
+	// Submitter records who submitted the inputs and when, it is empty before the inputs are submitted
+	Submitter *User `bson:"submitter,omitempty" json:"submitter,omitempty" yaml:"submitter,omitempty"`
+}
+
 type JobTaskGrafanaSpec struct {
 	ID   string `bson:"id" json:"id" yaml:"id"`
 	Name string `bson:"name" json:"name" yaml:"name"`
@@ -614,4 +679,12 @@ type WorkflowTaskCtx struct {
 	GlobalContextEach           func(f func(k, v string) bool)
 	ClusterIDAdd                func(clusterID string)
 	SetStatus                   func(status config.Status)
+	// EvaluateCondition evaluates the when expression of a job or stage against the running task.
+	EvaluateCondition func(when string) (bool, error)
+	// Resumed is true when the task is re-adopted after aslan restarted
+	Resumed bool
+	// Lost returns true if the task has been taken over by another aslan replica.
+	Lost func() bool
+	// SupplyChainArtifactAdd records the supply chain artifacts of an image built by the task.
+	SupplyChainArtifactAdd func(artifact *SupplyChainArtifact)
 }
@@ -0,0 +1,49 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// WorkflowCodeSetting points a project at the dir of a git repo its workflow v4 definitions are synced from.
+type WorkflowCodeSetting struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"       json:"id,omitempty"`
+	ProjectName   string             `bson:"project_name"        json:"project_name"`
+	Enabled       bool               `bson:"enabled"             json:"enabled"`
+	CodehostID    int                `bson:"codehost_id"         json:"codehost_id"`
+	RepoOwner     string             `bson:"repo_owner"          json:"repo_owner"`
+	RepoNamespace string             `bson:"repo_namespace"      json:"repo_namespace"`
+	RepoName      string             `bson:"repo_name"           json:"repo_name"`
+	Branch        string             `bson:"branch"              json:"branch"`
+	// Path is the dir of the workflow yaml files in the repo, .zadig by default.
+	Path          string `bson:"path"                json:"path"`
+	LastCommitSHA string `bson:"last_commit_sha"     json:"last_commit_sha"`
+	LastSyncTime  int64  `bson:"last_sync_time"      json:"last_sync_time"`
+	LastSyncError string `bson:"last_sync_error"     json:"last_sync_error"`
+	UpdatedBy     string `bson:"updated_by"          json:"updated_by"`
+	UpdateTime    int64  `bson:"update_time"         json:"update_time"`
+}
+
+func (WorkflowCodeSetting) TableName() string {
+	return "workflow_code_setting"
+}
+
+func (s *WorkflowCodeSetting) GetNamespace() string {
+	if s.RepoNamespace != "" {
+		return s.RepoNamespace
+	}
+	return s.RepoOwner
+}
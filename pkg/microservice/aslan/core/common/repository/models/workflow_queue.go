@@ -33,6 +33,12 @@ type WorkflowQueue struct {
 	TaskCreator         string             `bson:"task_creator"                               json:"task_creator,omitempty"`
 	TaskRevoker         string             `bson:"task_revoker,omitempty"                     json:"task_revoker,omitempty"`
 	CreateTime          int64              `bson:"create_time"                                json:"create_time,omitempty"`
+	// Owner is the aslan replica running the task, it renews HeartbeatTime periodically.
+	// Tasks whose heartbeat expired are taken over by another replica.
+	Owner         string `bson:"owner,omitempty"                            json:"owner,omitempty"`
+	HeartbeatTime int64  `bson:"heartbeat_time,omitempty"                   json:"heartbeat_time,omitempty"`
+	// PriorityClass can be changed while the task is waiting
+	PriorityClass config.PriorityClass `bson:"priority_class,omitempty"                   json:"priority_class,omitempty"`
 }
 
 func (WorkflowQueue) TableName() string {
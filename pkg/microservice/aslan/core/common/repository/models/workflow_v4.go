@@ -65,6 +65,37 @@ type WorkflowV4 struct {
 	// -1 means no limit
 	ConcurrencyLimit int          `bson:"concurrency_limit"   yaml:"concurrency_limit"   json:"concurrency_limit"`
 	CustomField      *CustomField `bson:"custom_field"        yaml:"-"                   json:"custom_field"`
+	// CodeSource is set on the workflows synced from the git repo of the project, they can only be changed in the repo.
+	CodeSource *WorkflowV4CodeSource `bson:"code_source,omitempty" yaml:"-"                   json:"code_source,omitempty"`
+	// Revision is the latest revision of the workflow, each create or update is saved as a WorkflowV4Revision.
+	Revision int64 `bson:"revision"            yaml:"-"                   json:"revision"`
+	// PriorityClass overrides the priority class derived from the task creator,
+	// a trigger overrides it again by the priority class of its workflow args.
+	PriorityClass config.PriorityClass `bson:"priority_class,omitempty" yaml:"priority_class,omitempty" json:"priority_class,omitempty"`
+	// ConcurrencyGroup makes a new task supersede the older tasks with the same group key
+	ConcurrencyGroup *ConcurrencyGroup `bson:"concurrency_group,omitempty" yaml:"concurrency_group,omitempty" json:"concurrency_group,omitempty"`
+}
+
+type ConcurrencyGroup struct {
+	// Key is rendered with the workflow variables such as {{.workflow.params.branch}}, {{.workflow.hook.branch}}
+	// and {{.workflow.hook.pr}}, the tasks with the same rendered key are in the same group
+	Key    string                        `bson:"key"            yaml:"key"            json:"key"`
+	Policy config.ConcurrencyGroupPolicy `bson:"policy"         yaml:"policy"         json:"policy"`
+	// ProtectDeploy keeps the older task running if one of its deploy jobs is running
+	ProtectDeploy bool `bson:"protect_deploy" yaml:"protect_deploy" json:"protect_deploy"`
+}
+
+type WorkflowV4CodeSource struct {
+	CodehostID    int    `bson:"codehost_id"         json:"codehost_id"`
+	RepoOwner     string `bson:"repo_owner"          json:"repo_owner"`
+	RepoNamespace string `bson:"repo_namespace"      json:"repo_namespace"`
+	RepoName      string `bson:"repo_name"           json:"repo_name"`
+	Branch        string `bson:"branch"              json:"branch"`
+	// Path is the path of the workflow file in the repo.
+	Path string `bson:"path"                json:"path"`
+	// CommitSHA is the commit which produced the current version of the workflow.
+	CommitSHA string `bson:"commit_sha"          json:"commit_sha"`
+	SyncTime  int64  `bson:"sync_time"           json:"sync_time"`
 }
 
 func (w *WorkflowV4) UpdateHash() {
@@ -73,7 +104,7 @@ func (w *WorkflowV4) UpdateHash() {
 
 func (w *WorkflowV4) CalculateHash() [md5.Size]byte {
 	fieldList := make(map[string]interface{})
-	ignoringFieldList := []string{"CreatedBy", "CreateTime", "UpdatedBy", "UpdateTime", "Description", "Hash"}
+	ignoringFieldList := []string{"CreatedBy", "CreateTime", "UpdatedBy", "UpdateTime", "Description", "Hash", "CodeSource", "Revision"}
 	ignoringFields := sets.NewString(ignoringFieldList...)
 
 	val := reflect.ValueOf(*w)
@@ -95,6 +126,10 @@ type WorkflowStage struct {
 	Parallel bool      `bson:"parallel"      yaml:"parallel"     json:"parallel"`
 	Approval *Approval `bson:"approval"      yaml:"approval"     json:"approval"`
 	Jobs     []*Job    `bson:"jobs"          yaml:"jobs"         json:"jobs"`
+	// When is a govaluate expression evaluated before the stage runs, see Job.When.
+	When string `bson:"when,omitempty" yaml:"when,omitempty" json:"when,omitempty"`
+	// RunOn makes the stage a final stage, it runs after all the main stages according to the workflow status.
+	RunOn config.StageRunOn `bson:"run_on,omitempty" yaml:"run_on,omitempty" json:"run_on,omitempty"`
 }
 
 type Approval struct {
@@ -107,6 +142,8 @@ type Approval struct {
 	NativeApproval   *NativeApproval     `bson:"native_approval"             yaml:"native_approval,omitempty"     json:"native_approval,omitempty"`
 	LarkApproval     *LarkApproval       `bson:"lark_approval"               yaml:"lark_approval,omitempty"       json:"lark_approval,omitempty"`
 	DingTalkApproval *DingTalkApproval   `bson:"dingtalk_approval"           yaml:"dingtalk_approval,omitempty"   json:"dingtalk_approval,omitempty"`
+	WeComApproval    *WeComApproval      `bson:"wecom_approval"              yaml:"wecom_approval,omitempty"      json:"wecom_approval,omitempty"`
+	WebhookApproval  *WebhookApproval    `bson:"webhook_approval"            yaml:"webhook_approval,omitempty"    json:"webhook_approval,omitempty"`
 }
 
 type NativeApproval struct {
@@ -144,6 +181,52 @@ type DingTalkApprovalUser struct {
 	OperationTime   int64                  `bson:"operation_time,omitempty"              yaml:"-"                          json:"operation_time,omitempty"`
 }
 
+type WeComApproval struct {
+	Timeout int `bson:"timeout"                     yaml:"timeout"                    json:"timeout"`
+	// ID: wecom im app mongodb id
+	ID string `bson:"approval_id"                 yaml:"approval_id"                json:"approval_id"`
+	// DefaultApprovalInitiator if not set, use workflow task creator as approval initiator
+	DefaultApprovalInitiator *WeComApprovalUser   `bson:"default_approval_initiator" yaml:"default_approval_initiator" json:"default_approval_initiator"`
+	ApprovalNodes            []*WeComApprovalNode `bson:"approval_nodes"             yaml:"approval_nodes"             json:"approval_nodes"`
+	// InstanceCode: wecom approval sp_no
+	InstanceCode string `bson:"instance_code"              yaml:"instance_code"              json:"instance_code"`
+}
+
+type WeComApprovalNode struct {
+	ApproveUsers []*WeComApprovalUser `bson:"approve_users"               yaml:"approve_users"              json:"approve_users"`
+	// Type: AND or OR
+	Type            string                 `bson:"type"                        yaml:"type"                       json:"type"`
+	RejectOrApprove config.ApproveOrReject `bson:"reject_or_approve"           yaml:"-"                          json:"reject_or_approve"`
+}
+
+type WeComApprovalUser struct {
+	ID              string                 `bson:"id"                          yaml:"id"                         json:"id"`
+	Name            string                 `bson:"name"                        yaml:"name"                       json:"name"`
+	RejectOrApprove config.ApproveOrReject `bson:"reject_or_approve,omitempty"           yaml:"-"                          json:"reject_or_approve,omitempty"`
+	Comment         string                 `bson:"comment,omitempty"                     yaml:"-"                          json:"comment,omitempty"`
+	OperationTime   int64                  `bson:"operation_time,omitempty"              yaml:"-"                          json:"operation_time,omitempty"`
+}
+
+// WebhookApproval sends a signed approval request to the approval system configured in the im app,
+// the approvers are decided by that system and recorded when they make decisions.
+type WebhookApproval struct {
+	Timeout int `bson:"timeout"                     yaml:"timeout"                    json:"timeout"`
+	// ID: webhook im app mongodb id
+	ID string `bson:"approval_id"                 yaml:"approval_id"                json:"approval_id"`
+	// InstanceCode: the approval id sent to the approval system
+	InstanceCode    string                 `bson:"instance_code"               yaml:"instance_code"              json:"instance_code"`
+	ApproveUsers    []*WebhookApprovalUser `bson:"approve_users"               yaml:"-"                          json:"approve_users"`
+	RejectOrApprove config.ApproveOrReject `bson:"reject_or_approve"           yaml:"-"                          json:"reject_or_approve"`
+}
+
+type WebhookApprovalUser struct {
+	ID              string                 `bson:"id"                          yaml:"id"                         json:"id"`
+	Name            string                 `bson:"name"                        yaml:"name"                       json:"name"`
+	RejectOrApprove config.ApproveOrReject `bson:"reject_or_approve,omitempty" yaml:"-"                          json:"reject_or_approve,omitempty"`
+	Comment         string                 `bson:"comment,omitempty"           yaml:"-"                          json:"comment,omitempty"`
+	OperationTime   int64                  `bson:"operation_time,omitempty"    yaml:"-"                          json:"operation_time,omitempty"`
+}
+
 type LarkApproval struct {
 	Timeout int `bson:"timeout"                     yaml:"timeout"                    json:"timeout"`
 	// ID: lark im app mongodb id
@@ -214,6 +297,34 @@ type Job struct {
 	Spec           interface{}              `bson:"spec"           yaml:"spec"       json:"spec"`
 	RunPolicy      config.JobRunPolicy      `bson:"run_policy"     yaml:"run_policy" json:"run_policy"`
 	ServiceModules []*WorkflowServiceModule `bson:"service_modules"                  json:"service_modules"`
+	// RetryPolicy reruns the job automatically when it failed, nil means no retry.
+	RetryPolicy *JobRetryPolicy `bson:"retry_policy,omitempty" yaml:"retry_policy,omitempty" json:"retry_policy,omitempty"`
+	// Needs is the names of the jobs, in any stage, that must pass before this job starts.
+	// A job without needs waits for all the jobs in previous stages as before.
+	Needs []string `bson:"needs,omitempty"        yaml:"needs,omitempty"        json:"needs,omitempty"`
+	// When is a govaluate expression evaluated before the job runs, the job is skipped if it is false.
+	// An empty expression means the job runs only when all the previous jobs have passed.
+	When string `bson:"when,omitempty"         yaml:"when,omitempty"         json:"when,omitempty"`
+}
+
+const (
+	// MaxJobRetryAttempts is the max number of runs of a job, including the first one.
+	MaxJobRetryAttempts = 10
+	// MaxJobRetryBackoff is the max multiplier of the retry interval.
+	MaxJobRetryBackoff = 10
+	// MaxJobRetryInterval is the max seconds to wait before a retry, a longer backoff interval is clamped to it.
+	MaxJobRetryInterval = 3600
+)
+
+type JobRetryPolicy struct {
+	// MaxAttempts is the max number of runs including the first one.
+	MaxAttempts int64 `bson:"max_attempts"       yaml:"max_attempts"       json:"max_attempts"`
+	// Interval is the seconds to wait before the first retry.
+	Interval int64 `bson:"interval"           yaml:"interval"           json:"interval"`
+	// Backoff multiplies the interval after every retry, value less than 1 means a fixed interval.
+	Backoff float64 `bson:"backoff"            yaml:"backoff"            json:"backoff"`
+	// RetryOn is the job status that triggers a retry, only failed and timeout are supported, empty means both.
+	RetryOn []config.Status `bson:"retry_on,omitempty" yaml:"retry_on,omitempty" json:"retry_on,omitempty"`
 }
 
 type WorkflowServiceModule struct {
@@ -250,11 +361,26 @@ type FreestyleJobSpec struct {
 	Properties *JobProperties `bson:"properties"     yaml:"properties"    json:"properties"`
 	Steps      []*Step        `bson:"steps"          yaml:"steps"         json:"steps"`
 	Outputs    []*Output      `bson:"outputs"        yaml:"outputs"       json:"outputs"`
+	Matrix     *JobMatrix     `bson:"matrix,omitempty" yaml:"matrix,omitempty" json:"matrix,omitempty"`
+}
+
+// JobMatrix runs a job once for every combination of the axis values, the values are passed to the job as env vars.
+type JobMatrix struct {
+	// Axes maps the env var name to its values, e.g. GO_VERSION: [1.20, 1.21].
+	Axes map[string][]string `bson:"axes"                   yaml:"axes"                   json:"axes"`
+	// Include adds extra combinations, Exclude removes the combinations matching all of its values.
+	Include []map[string]string `bson:"include,omitempty"      yaml:"include,omitempty"      json:"include,omitempty"`
+	Exclude []map[string]string `bson:"exclude,omitempty"      yaml:"exclude,omitempty"      json:"exclude,omitempty"`
+	// MaxParallel limits the number of combinations running at the same time, 0 means no limit.
+	MaxParallel int64 `bson:"max_parallel"           yaml:"max_parallel"           json:"max_parallel"`
+	// FailFast stops the other combinations when one of them failed.
+	FailFast bool `bson:"fail_fast"              yaml:"fail_fast"              json:"fail_fast"`
 }
 
 type ZadigBuildJobSpec struct {
 	DockerRegistryID string             `bson:"docker_registry_id"     yaml:"docker_registry_id"     json:"docker_registry_id"`
 	ServiceAndBuilds []*ServiceAndBuild `bson:"service_and_builds"     yaml:"service_and_builds"     json:"service_and_builds"`
+	Matrix           *JobMatrix         `bson:"matrix,omitempty"       yaml:"matrix,omitempty"       json:"matrix,omitempty"`
 }
 
 type ServiceAndBuild struct {
@@ -283,6 +409,8 @@ type ZadigDeployJobSpec struct {
 	OriginJobName    string             `bson:"origin_job_name"      yaml:"origin_job_name"      json:"origin_job_name"`
 	ServiceAndImages []*ServiceAndImage `bson:"service_and_images"   yaml:"service_and_images"   json:"service_and_images"`
 	Services         []*DeployService   `bson:"services"             yaml:"services"             json:"services"`
+	// only the images signed by zadig builds can be deployed to production environments when it is set
+	VerifyImageSignature bool `bson:"verify_image_signature"   yaml:"verify_image_signature"   json:"verify_image_signature"`
 }
 
 type ZadigHelmChartDeployJobSpec struct {
@@ -375,6 +503,7 @@ type ZadigTestingJobSpec struct {
 	TargetServices  []*ServiceTestTarget    `bson:"target_services"  yaml:"target_services"  json:"target_services"`
 	TestModules     []*TestModule           `bson:"test_modules"     yaml:"test_modules"     json:"test_modules"`
 	ServiceAndTests []*ServiceAndTest       `bson:"service_and_tests" yaml:"service_and_tests" json:"service_and_tests"`
+	Matrix          *JobMatrix              `bson:"matrix,omitempty" yaml:"matrix,omitempty" json:"matrix,omitempty"`
 }
 
 type ServiceAndTest struct {
@@ -629,6 +758,40 @@ type IstioJobTarget struct {
 	TargetReplica      int    `bson:"target_replica,omitempty"  json:"target_replica,omitempty"  yaml:"target_replica,omitempty"`
 }
 
+type ApprovalJobSpec struct {
+	Type             config.ApprovalType `bson:"type"              json:"type"                        yaml:"type"`
+	Description      string              `bson:"description"       json:"description"                 yaml:"description"`
+	NativeApproval   *NativeApproval     `bson:"native_approval"   json:"native_approval,omitempty"   yaml:"native_approval,omitempty"`
+	LarkApproval     *LarkApproval       `bson:"lark_approval"     json:"lark_approval,omitempty"     yaml:"lark_approval,omitempty"`
+	DingTalkApproval *DingTalkApproval   `bson:"dingtalk_approval" json:"dingtalk_approval,omitempty" yaml:"dingtalk_approval,omitempty"`
+	WeComApproval    *WeComApproval      `bson:"wecom_approval"    json:"wecom_approval,omitempty"    yaml:"wecom_approval,omitempty"`
+	WebhookApproval  *WebhookApproval    `bson:"webhook_approval"  json:"webhook_approval,omitempty"  yaml:"webhook_approval,omitempty"`
+}
+
+// ToApproval converts the approval job spec to an enabled approval, so that it is checked and run like a stage approval.
+func (s *ApprovalJobSpec) ToApproval() *Approval {
+	return &Approval{
+		Enabled:          true,
+		Type:             s.Type,
+		Description:      s.Description,
+		NativeApproval:   s.NativeApproval,
+		LarkApproval:     s.LarkApproval,
+		DingTalkApproval: s.DingTalkApproval,
+		WeComApproval:    s.WeComApproval,
+		WebhookApproval:  s.WebhookApproval,
+	}
+}
+
+type ManualInputJobSpec struct {
+	Description string `bson:"description" json:"description" yaml:"description"`
+	// Timeout minute, default 60
+	Timeout int `bson:"timeout" json:"timeout" yaml:"timeout"`
+	// Users who can submit the inputs, user groups are expanded when the task is created
+	Users []*User `bson:"users" json:"users" yaml:"users"`
+	// Fields support string/choice/bool type, the submitted values are outputs of the job
+	Fields []*Param `bson:"fields" json:"fields" yaml:"fields"`
+}
+
 type GrafanaJobSpec struct {
 	ID   string `bson:"id" json:"id" yaml:"id"`
 	Name string `bson:"name" json:"name" yaml:"name"`
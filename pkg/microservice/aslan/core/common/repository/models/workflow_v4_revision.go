@@ -0,0 +1,36 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// WorkflowV4Revision is an immutable snapshot of a workflow v4 definition saved on every create or update.
+type WorkflowV4Revision struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"       json:"id,omitempty"`
+	WorkflowName string             `bson:"workflow_name"       json:"workflow_name"`
+	ProjectName  string             `bson:"project_name"        json:"project_name"`
+	Revision     int64              `bson:"revision"            json:"revision"`
+	Hash         string             `bson:"hash"                json:"hash"`
+	Comment      string             `bson:"comment"             json:"comment"`
+	CreatedBy    string             `bson:"created_by"          json:"created_by"`
+	CreateTime   int64              `bson:"create_time"         json:"create_time"`
+	Workflow     *WorkflowV4        `bson:"workflow"            json:"workflow,omitempty"`
+}
+
+func (WorkflowV4Revision) TableName() string {
+	return "workflow_v4_revision"
+}
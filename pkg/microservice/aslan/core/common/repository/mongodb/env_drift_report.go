@@ -0,0 +1,99 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type EnvDriftReportColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewEnvDriftReportColl() *EnvDriftReportColl {
+	name := models.EnvDriftReport{}.TableName()
+	return &EnvDriftReportColl{
+		Collection: mongotool.Database(config.MongoDatabase()).Collection(name),
+		coll:       name,
+	}
+}
+
+func (c *EnvDriftReportColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *EnvDriftReportColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys: bson.D{
+			bson.E{Key: "product_name", Value: 1},
+			bson.E{Key: "env_name", Value: 1},
+			bson.E{Key: "production", Value: 1},
+			bson.E{Key: "service_name", Value: 1},
+		},
+		Options: options.Index().SetUnique(false),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+	return err
+}
+
+// ReplaceByEnv replaces all the drift reports of an environment with the reports of the latest check
+func (c *EnvDriftReportColl) ReplaceByEnv(productName, envName string, production bool, reports []*models.EnvDriftReport) error {
+	if err := c.DeleteByEnv(productName, envName, production); err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, 0, len(reports))
+	for _, report := range reports {
+		docs = append(docs, report)
+	}
+	_, err := c.InsertMany(context.TODO(), docs)
+	return err
+}
+
+func (c *EnvDriftReportColl) ListByEnv(productName, envName string, production bool) ([]*models.EnvDriftReport, error) {
+	query := bson.M{"product_name": productName, "env_name": envName, "production": production}
+	opts := options.Find().SetSort(bson.D{{"service_name", 1}})
+
+	cursor, err := c.Find(context.TODO(), query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]*models.EnvDriftReport, 0)
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
+
+func (c *EnvDriftReportColl) DeleteByEnv(productName, envName string, production bool) error {
+	query := bson.M{"product_name": productName, "env_name": envName, "production": production}
+	_, err := c.DeleteMany(context.TODO(), query)
+	return err
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type EnvPreviewFindOption struct {
+	ProductName   string
+	CodehostID    int
+	Source        string
+	RepoNamespace string
+	RepoName      string
+	PrID          int
+}
+
+type EnvPreviewColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewEnvPreviewColl() *EnvPreviewColl {
+	name := models.EnvPreview{}.TableName()
+	return &EnvPreviewColl{
+		Collection: mongotool.Database(config.MongoDatabase()).Collection(name),
+		coll:       name,
+	}
+}
+
+func (c *EnvPreviewColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *EnvPreviewColl) EnsureIndex(ctx context.Context) error {
+	mod := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				bson.E{Key: "product_name", Value: 1},
+				bson.E{Key: "source", Value: 1},
+				bson.E{Key: "repo_namespace", Value: 1},
+				bson.E{Key: "repo_name", Value: 1},
+				bson.E{Key: "pr_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "product_name", Value: 1},
+				bson.E{Key: "env_name", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "product_name", Value: 1},
+				bson.E{Key: "slot", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	_, err := c.Indexes().CreateMany(ctx, mod)
+	return err
+}
+
+func (c *EnvPreviewColl) Create(preview *models.EnvPreview) error {
+	res, err := c.InsertOne(context.TODO(), preview)
+	if err != nil {
+		return err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		preview.ID = oid
+	}
+	return nil
+}
+
+func (c *EnvPreviewColl) Find(opt *EnvPreviewFindOption) (*models.EnvPreview, error) {
+	query := bson.M{
+		"product_name":   opt.ProductName,
+		"source":         opt.Source,
+		"repo_namespace": opt.RepoNamespace,
+		"repo_name":      opt.RepoName,
+		"pr_id":          opt.PrID,
+	}
+
+	resp := new(models.EnvPreview)
+	err := c.FindOne(context.TODO(), query).Decode(resp)
+	return resp, err
+}
+
+func (c *EnvPreviewColl) Count(productName string) (int64, error) {
+	return c.CountDocuments(context.TODO(), bson.M{"product_name": productName})
+}
+
+// List returns the preview environments of a project or a pull request, the empty fields of the option are ignored
+func (c *EnvPreviewColl) List(opt *EnvPreviewFindOption) ([]*models.EnvPreview, error) {
+	query := bson.M{}
+	if opt.ProductName != "" {
+		query["product_name"] = opt.ProductName
+	}
+	if opt.CodehostID != 0 {
+		query["codehost_id"] = opt.CodehostID
+	}
+	if opt.Source != "" {
+		query["source"] = opt.Source
+	}
+	if opt.RepoNamespace != "" {
+		query["repo_namespace"] = opt.RepoNamespace
+	}
+	if opt.RepoName != "" {
+		query["repo_name"] = opt.RepoName
+	}
+	if opt.PrID != 0 {
+		query["pr_id"] = opt.PrID
+	}
+
+	opts := options.Find().SetSort(bson.D{{"create_time", -1}})
+	cursor, err := c.Collection.Find(context.TODO(), query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]*models.EnvPreview, 0)
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
+
+func (c *EnvPreviewColl) Update(preview *models.EnvPreview) error {
+	change := bson.M{"$set": bson.M{
+		"commit_id":       preview.CommitID,
+		"services":        preview.Services,
+		"notification_id": preview.NotificationID,
+		"update_time":     preview.UpdateTime,
+	}}
+
+	_, err := c.UpdateByID(context.TODO(), preview.ID, change)
+	return err
+}
+
+func (c *EnvPreviewColl) Delete(id primitive.ObjectID) error {
+	_, err := c.DeleteOne(context.TODO(), bson.M{"_id": id})
+	return err
+}
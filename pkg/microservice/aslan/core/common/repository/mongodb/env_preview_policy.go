@@ -0,0 +1,90 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type EnvPreviewPolicyColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewEnvPreviewPolicyColl() *EnvPreviewPolicyColl {
+	name := models.EnvPreviewPolicy{}.TableName()
+	return &EnvPreviewPolicyColl{
+		Collection: mongotool.Database(config.MongoDatabase()).Collection(name),
+		coll:       name,
+	}
+}
+
+func (c *EnvPreviewPolicyColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *EnvPreviewPolicyColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys:    bson.D{bson.E{Key: "product_name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+	return err
+}
+
+func (c *EnvPreviewPolicyColl) Find(productName string) (*models.EnvPreviewPolicy, error) {
+	resp := new(models.EnvPreviewPolicy)
+	err := c.FindOne(context.TODO(), bson.M{"product_name": productName}).Decode(resp)
+	return resp, err
+}
+
+func (c *EnvPreviewPolicyColl) Upsert(policy *models.EnvPreviewPolicy) error {
+	query := bson.M{"product_name": policy.ProductName}
+	change := bson.M{"$set": bson.M{
+		"enabled":       policy.Enabled,
+		"base_env":      policy.BaseEnv,
+		"workflow_name": policy.WorkflowName,
+		"max_envs":      policy.MaxEnvs,
+		"access_url":    policy.AccessURL,
+		"update_by":     policy.UpdateBy,
+		"update_time":   policy.UpdateTime,
+	}}
+
+	_, err := c.UpdateOne(context.TODO(), query, change, options.Update().SetUpsert(true))
+	return err
+}
+
+func (c *EnvPreviewPolicyColl) ListEnabled() ([]*models.EnvPreviewPolicy, error) {
+	cursor, err := c.Collection.Find(context.TODO(), bson.M{"enabled": true})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]*models.EnvPreviewPolicy, 0)
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type EnvReclaimRecordListOption struct {
+	ProductName string
+	StartTime   int64
+	EndTime     int64
+}
+
+type EnvReclaimRecordColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewEnvReclaimRecordColl() *EnvReclaimRecordColl {
+	name := models.EnvReclaimRecord{}.TableName()
+	return &EnvReclaimRecordColl{
+		Collection: mongotool.Database(config.MongoDatabase()).Collection(name),
+		coll:       name,
+	}
+}
+
+func (c *EnvReclaimRecordColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *EnvReclaimRecordColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys: bson.D{
+			bson.E{Key: "product_name", Value: 1},
+			bson.E{Key: "delete_time", Value: -1},
+		},
+		Options: options.Index().SetUnique(false),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+	return err
+}
+
+func (c *EnvReclaimRecordColl) Create(record *models.EnvReclaimRecord) error {
+	_, err := c.InsertOne(context.TODO(), record)
+	return err
+}
+
+func (c *EnvReclaimRecordColl) List(opt *EnvReclaimRecordListOption) ([]*models.EnvReclaimRecord, error) {
+	query := bson.M{}
+	if opt.ProductName != "" {
+		query["product_name"] = opt.ProductName
+	}
+	timeRange := bson.M{}
+	if opt.StartTime > 0 {
+		timeRange["$gte"] = opt.StartTime
+	}
+	if opt.EndTime > 0 {
+		timeRange["$lte"] = opt.EndTime
+	}
+	if len(timeRange) > 0 {
+		query["delete_time"] = timeRange
+	}
+	opts := options.Find().SetSort(bson.D{{"delete_time", -1}})
+
+	cursor, err := c.Find(context.TODO(), query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]*models.EnvReclaimRecord, 0)
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type EnvSnapshotColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewEnvSnapshotColl() *EnvSnapshotColl {
+	name := models.EnvSnapshot{}.TableName()
+	return &EnvSnapshotColl{
+		Collection: mongotool.Database(config.MongoDatabase()).Collection(name),
+		coll:       name,
+	}
+}
+
+func (c *EnvSnapshotColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *EnvSnapshotColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys: bson.D{
+			bson.E{Key: "product_name", Value: 1},
+			bson.E{Key: "env_name", Value: 1},
+			bson.E{Key: "production", Value: 1},
+			bson.E{Key: "version", Value: -1},
+		},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+	return err
+}
+
+func (c *EnvSnapshotColl) Create(snapshot *models.EnvSnapshot) error {
+	res, err := c.InsertOne(context.TODO(), snapshot)
+	if err != nil {
+		return err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		snapshot.ID = oid
+	}
+	return nil
+}
+
+func (c *EnvSnapshotColl) GetByID(id string) (*models.EnvSnapshot, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(models.EnvSnapshot)
+	err = c.FindOne(context.TODO(), bson.M{"_id": oid}).Decode(resp)
+	return resp, err
+}
+
+// GetLatestVersion returns 0 if the environment has no snapshot yet
+func (c *EnvSnapshotColl) GetLatestVersion(productName, envName string, production bool) (int64, error) {
+	query := bson.M{"product_name": productName, "env_name": envName, "production": production}
+	opts := options.FindOne().SetSort(bson.D{{"version", -1}})
+
+	resp := new(models.EnvSnapshot)
+	err := c.FindOne(context.TODO(), query, opts).Decode(resp)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return resp.Version, nil
+}
+
+func (c *EnvSnapshotColl) List(productName, envName string, production bool) ([]*models.EnvSnapshot, error) {
+	query := bson.M{"product_name": productName, "env_name": envName, "production": production}
+	opts := options.Find().SetSort(bson.D{{"version", -1}})
+
+	cursor, err := c.Find(context.TODO(), query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]*models.EnvSnapshot, 0)
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
@@ -615,3 +615,53 @@ func (c *ProductColl) UpdateConfigs(envName, productName string, analysisConfig
 
 	return err
 }
+
+func (c *ProductColl) UpdateDriftDetectionConfig(envName, productName string, driftDetectionConfig *models.DriftDetectionConfig) error {
+	query := bson.M{"env_name": envName, "product_name": productName}
+
+	change := bson.M{"$set": bson.M{
+		"drift_detection_config": driftDetectionConfig,
+		"update_time":            time.Now().Unix(),
+	}}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+
+	return err
+}
+
+func (c *ProductColl) UpdateIdlePolicy(envName, productName string, idlePolicy *models.EnvIdlePolicy) error {
+	query := bson.M{"env_name": envName, "product_name": productName}
+
+	change := bson.M{"$set": bson.M{
+		"idle_policy": idlePolicy,
+		"update_time": time.Now().Unix(),
+	}}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+
+	return err
+}
+
+func (c *ProductColl) FindByWakeToken(wakeToken string) (*models.Product, error) {
+	res := &models.Product{}
+	query := bson.M{"idle_policy.wake_token": wakeToken}
+	err := c.FindOne(context.TODO(), query).Decode(res)
+	return res, err
+}
+
+// UpdateTTLPolicy does not touch the update time, since the reminder and the reclaim update the policy as well
+func (c *ProductColl) UpdateTTLPolicy(envName, productName string, ttlPolicy *models.EnvTTLPolicy) error {
+	query := bson.M{"env_name": envName, "product_name": productName}
+
+	change := bson.M{"$set": bson.M{
+		"ttl_policy": ttlPolicy,
+	}}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+
+	return err
+}
+
+func (c *ProductColl) FindByExtendToken(extendToken string) (*models.Product, error) {
+	res := &models.Product{}
+	query := bson.M{"ttl_policy.extend_token": extendToken}
+	err := c.FindOne(context.TODO(), query).Decode(res)
+	return res, err
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	"github.com/koderover/zadig/pkg/setting"
 	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+	"github.com/koderover/zadig/pkg/types"
 )
 
 type SystemSettingColl struct {
@@ -80,6 +81,18 @@ func (c *SystemSettingColl) UpdateConcurrencySetting(workflowConcurrency, buildC
 	return err
 }
 
+func (c *SystemSettingColl) UpdateProjectConcurrencySetting(defaultConcurrency int64, projectConcurrency []*models.ProjectConcurrency, releasePriorityProjects []string) error {
+	id, _ := primitive.ObjectIDFromHex(setting.LocalClusterID)
+	change := bson.M{"$set": bson.M{
+		"default_project_concurrency": defaultConcurrency,
+		"project_concurrency":         projectConcurrency,
+		"release_priority_projects":   releasePriorityProjects,
+	}}
+	query := bson.M{"_id": id}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+	return err
+}
+
 func (c *SystemSettingColl) UpdateSecuritySetting(tokenExpirationTime int64) error {
 	id, _ := primitive.ObjectIDFromHex(setting.LocalClusterID)
 	change := bson.M{"$set": bson.M{
@@ -100,6 +113,16 @@ func (c *SystemSettingColl) UpdatePrivacySetting(improvementPlan bool) error {
 	return err
 }
 
+func (c *SystemSettingColl) UpdateVulnerabilityDBSetting(settings *types.VulnerabilityDBSettings) error {
+	id, _ := primitive.ObjectIDFromHex(setting.LocalClusterID)
+	change := bson.M{"$set": bson.M{
+		"vulnerability_db": settings,
+	}}
+	query := bson.M{"_id": id}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+	return err
+}
+
 func (c *SystemSettingColl) InitSystemSettings() error {
 	_, err := c.Get()
 	// if we didn't find anything
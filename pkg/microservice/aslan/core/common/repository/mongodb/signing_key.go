@@ -0,0 +1,136 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/tool/crypto"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type SigningKeyColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewSigningKeyColl() *SigningKeyColl {
+	name := models.SigningKey{}.TableName()
+	return &SigningKeyColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *SigningKeyColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *SigningKeyColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys:    bson.M{"name": 1},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err := c.Indexes().CreateOne(ctx, mod)
+	return err
+}
+
+func (c *SigningKeyColl) Create(obj *models.SigningKey) error {
+	if obj == nil {
+		return fmt.Errorf("nil object")
+	}
+	if err := encryptSigningKey(obj); err != nil {
+		return err
+	}
+	_, err := c.InsertOne(context.TODO(), obj)
+	return err
+}
+
+func (c *SigningKeyColl) Update(idString string, obj *models.SigningKey) error {
+	if obj == nil {
+		return fmt.Errorf("nil object")
+	}
+	id, err := primitive.ObjectIDFromHex(idString)
+	if err != nil {
+		return fmt.Errorf("invalid id")
+	}
+	if err := encryptSigningKey(obj); err != nil {
+		return err
+	}
+	_, err = c.UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": obj})
+	return err
+}
+
+func (c *SigningKeyColl) Find(idString string) (*models.SigningKey, error) {
+	id, err := primitive.ObjectIDFromHex(idString)
+	if err != nil {
+		return nil, err
+	}
+	resp := new(models.SigningKey)
+	if err := c.FindOne(context.TODO(), bson.M{"_id": id}).Decode(resp); err != nil {
+		return nil, err
+	}
+	return resp, decryptSigningKey(resp)
+}
+
+func (c *SigningKeyColl) List() ([]*models.SigningKey, error) {
+	resp := make([]*models.SigningKey, 0)
+	cursor, err := c.Collection.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.TODO(), &resp); err != nil {
+		return nil, err
+	}
+	for _, key := range resp {
+		if err := decryptSigningKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (c *SigningKeyColl) Delete(idString string) error {
+	id, err := primitive.ObjectIDFromHex(idString)
+	if err != nil {
+		return err
+	}
+	_, err = c.DeleteOne(context.TODO(), bson.M{"_id": id})
+	return err
+}
+
+func encryptSigningKey(key *models.SigningKey) (err error) {
+	if key.EncryptedPrivateKey, err = crypto.AesEncrypt(key.PrivateKey); err != nil {
+		return err
+	}
+	key.EncryptedPassword, err = crypto.AesEncrypt(key.Password)
+	return err
+}
+
+func decryptSigningKey(key *models.SigningKey) (err error) {
+	if key.PrivateKey, err = crypto.AesDecrypt(key.EncryptedPrivateKey); err != nil {
+		return err
+	}
+	key.Password, err = crypto.AesDecrypt(key.EncryptedPassword)
+	return err
+}
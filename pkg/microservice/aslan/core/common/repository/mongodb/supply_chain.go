@@ -0,0 +1,76 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type SupplyChainArtifactColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewSupplyChainArtifactColl() *SupplyChainArtifactColl {
+	name := models.SupplyChainArtifact{}.TableName()
+	return &SupplyChainArtifactColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *SupplyChainArtifactColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *SupplyChainArtifactColl) EnsureIndex(ctx context.Context) error {
+	mod := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				bson.E{Key: "image", Value: 1},
+				bson.E{Key: "create_time", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "workflow_name", Value: 1},
+				bson.E{Key: "task_id", Value: 1},
+			},
+		},
+	}
+	_, err := c.Indexes().CreateMany(ctx, mod)
+	return err
+}
+
+func (c *SupplyChainArtifactColl) Create(obj *models.SupplyChainArtifact) error {
+	_, err := c.InsertOne(context.TODO(), obj)
+	return err
+}
+
+// FindLatestByImage finds the artifacts of the last build of the image, a tag may be pushed by more than one build.
+func (c *SupplyChainArtifactColl) FindLatestByImage(image string) (*models.SupplyChainArtifact, error) {
+	resp := new(models.SupplyChainArtifact)
+	opts := options.FindOne().SetSort(bson.D{{"create_time", -1}})
+	err := c.FindOne(context.TODO(), bson.M{"image": image}, opts).Decode(resp)
+	return resp, err
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+const (
+	TestCaseStatSortByDuration = "average_duration"
+	TestCaseStatSortByFailure  = "failure_count"
+	TestCaseStatSortByFlaky    = "flaky_count"
+)
+
+type TestCaseStatOption struct {
+	ProjectNames []string
+	StartTime    int64
+	EndTime      int64
+	SortBy       string
+	Limit        int
+}
+
+// TestCaseStat is the aggregated results of a test case in a period.
+type TestCaseStat struct {
+	ProjectName     string  `bson:"project_name"     json:"project_name"`
+	TestName        string  `bson:"test_name"        json:"test_name"`
+	Suite           string  `bson:"suite"            json:"suite"`
+	Name            string  `bson:"name"             json:"name"`
+	ExecCount       int     `bson:"exec_count"       json:"exec_count"`
+	FailureCount    int     `bson:"failure_count"    json:"failure_count"`
+	FlakyCount      int     `bson:"flaky_count"      json:"flaky_count"`
+	AverageDuration float64 `bson:"average_duration" json:"average_duration"`
+}
+
+type TestCaseResultColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewTestCaseResultColl() *TestCaseResultColl {
+	name := models.TestCaseResult{}.TableName()
+	return &TestCaseResultColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *TestCaseResultColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *TestCaseResultColl) EnsureIndex(ctx context.Context) error {
+	mod := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				bson.E{Key: "project_name", Value: 1},
+				bson.E{Key: "create_time", Value: 1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "test_name", Value: 1},
+				bson.E{Key: "commit", Value: 1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "workflow_name", Value: 1},
+				bson.E{Key: "task_id", Value: 1},
+				bson.E{Key: "job_name", Value: 1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+	}
+	_, err := c.Indexes().CreateMany(ctx, mod)
+	return err
+}
+
+// ReplaceByJob replaces the results of the job, a job may be retried.
+func (c *TestCaseResultColl) ReplaceByJob(workflowName string, taskID int64, jobName string, results []*models.TestCaseResult) error {
+	query := bson.M{"workflow_name": workflowName, "task_id": taskID, "job_name": jobName}
+	if _, err := c.DeleteMany(context.TODO(), query); err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, 0, len(results))
+	for _, result := range results {
+		docs = append(docs, result)
+	}
+	_, err := c.InsertMany(context.TODO(), docs)
+	return err
+}
+
+func (c *TestCaseResultColl) ListByJob(workflowName string, taskID int64, jobName string) ([]*models.TestCaseResult, error) {
+	return c.list(bson.M{"workflow_name": workflowName, "task_id": taskID, "job_name": jobName})
+}
+
+func (c *TestCaseResultColl) ListByCommit(testName, commit string) ([]*models.TestCaseResult, error) {
+	return c.list(bson.M{"test_name": testName, "commit": commit})
+}
+
+func (c *TestCaseResultColl) list(query bson.M) ([]*models.TestCaseResult, error) {
+	resp := make([]*models.TestCaseResult, 0)
+	cursor, err := c.Collection.Find(context.TODO(), query)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
+
+// MarkFlaky marks all the results of the case on the commit as flaky.
+func (c *TestCaseResultColl) MarkFlaky(testName, commit, suite, name string) error {
+	query := bson.M{"test_name": testName, "commit": commit, "suite": suite, "name": name}
+	_, err := c.UpdateMany(context.TODO(), query, bson.M{"$set": bson.M{"flaky": true}})
+	return err
+}
+
+func (c *TestCaseResultColl) ListTestCaseStats(opt *TestCaseStatOption) ([]*TestCaseStat, error) {
+	pipeline := []bson.M{
+		{"$match": buildTestCaseQuery(opt)},
+		{
+			"$group": bson.M{
+				"_id": bson.D{
+					{"test_name", "$test_name"},
+					{"suite", "$suite"},
+					{"name", "$name"},
+				},
+				"project_name": bson.M{"$first": "$project_name"},
+				"test_name":    bson.M{"$first": "$test_name"},
+				"suite":        bson.M{"$first": "$suite"},
+				"name":         bson.M{"$first": "$name"},
+				"exec_count":   bson.M{"$sum": 1},
+				"failure_count": bson.M{"$sum": bson.M{
+					"$cond": bson.A{bson.M{"$in": bson.A{"$status", bson.A{types.TestCaseStatusFailed, types.TestCaseStatusError}}}, 1, 0},
+				}},
+				"flaky_count": bson.M{"$sum": bson.M{
+					"$cond": bson.A{"$flaky", 1, 0},
+				}},
+				"average_duration": bson.M{"$avg": "$duration"},
+			},
+		},
+	}
+	switch opt.SortBy {
+	case TestCaseStatSortByFailure:
+		pipeline = append(pipeline, bson.M{"$match": bson.M{"failure_count": bson.M{"$gt": 0}}})
+	case TestCaseStatSortByFlaky:
+		pipeline = append(pipeline, bson.M{"$match": bson.M{"flaky_count": bson.M{"$gt": 0}}})
+	}
+	if opt.SortBy != "" {
+		pipeline = append(pipeline, bson.M{"$sort": bson.D{{opt.SortBy, -1}, {"exec_count", -1}}})
+	}
+	if opt.Limit > 0 {
+		pipeline = append(pipeline, bson.M{"$limit": opt.Limit})
+	}
+
+	resp := make([]*TestCaseStat, 0)
+	cursor, err := c.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
+
+// CountFailedAndFlaky counts the failed and the flaky case executions in the period.
+func (c *TestCaseResultColl) CountFailedAndFlaky(opt *TestCaseStatOption) (int64, int64, error) {
+	query := buildTestCaseQuery(opt)
+	query["status"] = bson.M{"$in": bson.A{types.TestCaseStatusFailed, types.TestCaseStatusError}}
+	failed, err := c.CountDocuments(context.TODO(), query)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	query = buildTestCaseQuery(opt)
+	query["flaky"] = true
+	flaky, err := c.CountDocuments(context.TODO(), query)
+	return failed, flaky, err
+}
+
+func buildTestCaseQuery(opt *TestCaseStatOption) bson.M {
+	query := bson.M{}
+	if len(opt.ProjectNames) > 0 {
+		query["project_name"] = bson.M{"$in": opt.ProjectNames}
+	}
+	createTime := bson.M{}
+	if opt.StartTime > 0 {
+		createTime["$gte"] = opt.StartTime
+	}
+	if opt.EndTime > 0 {
+		createTime["$lte"] = opt.EndTime
+	}
+	if len(createTime) > 0 {
+		query["create_time"] = createTime
+	}
+	return query
+}
+
+type TestCoverageColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewTestCoverageColl() *TestCoverageColl {
+	name := models.TestCoverage{}.TableName()
+	return &TestCoverageColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *TestCoverageColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *TestCoverageColl) EnsureIndex(ctx context.Context) error {
+	mod := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				bson.E{Key: "workflow_name", Value: 1},
+				bson.E{Key: "task_id", Value: 1},
+				bson.E{Key: "job_name", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "test_name", Value: 1},
+				bson.E{Key: "repo_name", Value: 1},
+				bson.E{Key: "branch", Value: 1},
+				bson.E{Key: "create_time", Value: -1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+	}
+	_, err := c.Indexes().CreateMany(ctx, mod)
+	return err
+}
+
+func (c *TestCoverageColl) Upsert(obj *models.TestCoverage) error {
+	query := bson.M{"workflow_name": obj.WorkflowName, "task_id": obj.TaskID, "job_name": obj.JobName}
+	obj.ID = primitive.NilObjectID
+	_, err := c.ReplaceOne(context.TODO(), query, obj, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (c *TestCoverageColl) FindByJob(workflowName string, taskID int64, jobName string) (*models.TestCoverage, error) {
+	resp := new(models.TestCoverage)
+	query := bson.M{"workflow_name": workflowName, "task_id": taskID, "job_name": jobName}
+	err := c.FindOne(context.TODO(), query).Decode(resp)
+	return resp, err
+}
+
+// FindLatestOnBranch finds the latest coverage of the test on the branch itself, the coverages of pull requests are skipped.
+func (c *TestCoverageColl) FindLatestOnBranch(testName, repoName, branch string, before int64) (*models.TestCoverage, error) {
+	resp := new(models.TestCoverage)
+	query := bson.M{
+		"test_name":   testName,
+		"repo_name":   repoName,
+		"branch":      branch,
+		"pr":          0,
+		"create_time": bson.M{"$lt": before},
+	}
+	opts := options.FindOne().SetSort(bson.D{{"create_time", -1}})
+	err := c.FindOne(context.TODO(), query, opts).Decode(resp)
+	return resp, err
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type VulnerabilityScanResultColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewVulnerabilityScanResultColl() *VulnerabilityScanResultColl {
+	name := models.VulnerabilityScanResult{}.TableName()
+	return &VulnerabilityScanResultColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *VulnerabilityScanResultColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *VulnerabilityScanResultColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys: bson.D{
+			bson.E{Key: "workflow_name", Value: 1},
+			bson.E{Key: "task_id", Value: 1},
+			bson.E{Key: "job_name", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err := c.Indexes().CreateOne(ctx, mod)
+	return err
+}
+
+// Upsert replaces the result of the job, a job may be retried.
+func (c *VulnerabilityScanResultColl) Upsert(obj *models.VulnerabilityScanResult) error {
+	query := bson.M{"workflow_name": obj.WorkflowName, "task_id": obj.TaskID, "job_name": obj.JobName}
+	obj.ID = primitive.NilObjectID
+	_, err := c.ReplaceOne(context.TODO(), query, obj, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (c *VulnerabilityScanResultColl) FindByJob(workflowName string, taskID int64, jobName string) (*models.VulnerabilityScanResult, error) {
+	resp := new(models.VulnerabilityScanResult)
+	query := bson.M{"workflow_name": workflowName, "task_id": taskID, "job_name": jobName}
+	err := c.FindOne(context.TODO(), query).Decode(resp)
+	return resp, err
+}
+
+func (c *VulnerabilityScanResultColl) List(workflowName string, taskID int64) ([]*models.VulnerabilityScanResult, error) {
+	resp := make([]*models.VulnerabilityScanResult, 0)
+	query := bson.M{"workflow_name": workflowName, "task_id": taskID}
+	cursor, err := c.Collection.Find(context.TODO(), query)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type WebhookApprovalResultColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewWebhookApprovalResultColl() *WebhookApprovalResultColl {
+	name := models.WebhookApprovalResult{}.TableName()
+	return &WebhookApprovalResultColl{
+		Collection: mongotool.Database(config.MongoDatabase()).Collection(name),
+		coll:       name,
+	}
+}
+
+func (c *WebhookApprovalResultColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *WebhookApprovalResultColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys:    bson.D{bson.E{Key: "approval_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+	return err
+}
+
+func (c *WebhookApprovalResultColl) Upsert(args *models.WebhookApprovalResult) error {
+	args.UpdateTime = time.Now().Unix()
+	query := bson.M{"approval_id": args.ApprovalID}
+	_, err := c.ReplaceOne(context.TODO(), query, args, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (c *WebhookApprovalResultColl) Find(approvalID string) (*models.WebhookApprovalResult, error) {
+	resp := new(models.WebhookApprovalResult)
+	query := bson.M{"approval_id": approvalID}
+	return resp, c.FindOne(context.TODO(), query).Decode(resp)
+}
+
+func (c *WebhookApprovalResultColl) Delete(approvalID string) error {
+	_, err := c.DeleteOne(context.TODO(), bson.M{"approval_id": approvalID})
+	return err
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type WorkflowCodeSettingColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewWorkflowCodeSettingColl() *WorkflowCodeSettingColl {
+	name := models.WorkflowCodeSetting{}.TableName()
+	return &WorkflowCodeSettingColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *WorkflowCodeSettingColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *WorkflowCodeSettingColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys:    bson.M{"project_name": 1},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err := c.Indexes().CreateOne(ctx, mod)
+	return err
+}
+
+func (c *WorkflowCodeSettingColl) Find(projectName string) (*models.WorkflowCodeSetting, error) {
+	resp := new(models.WorkflowCodeSetting)
+	err := c.FindOne(context.TODO(), bson.M{"project_name": projectName}).Decode(resp)
+	return resp, err
+}
+
+// ListEnabledByRepo lists the enabled settings pointing at the branch of the repo, an empty namespace matches any namespace.
+func (c *WorkflowCodeSettingColl) ListEnabledByRepo(namespace, repoName, branch string) ([]*models.WorkflowCodeSetting, error) {
+	query := bson.M{
+		"enabled":   true,
+		"repo_name": repoName,
+		"branch":    branch,
+	}
+	if namespace != "" {
+		query["$or"] = []bson.M{
+			{"repo_namespace": namespace},
+			{"repo_namespace": "", "repo_owner": namespace},
+		}
+	}
+	cursor, err := c.Collection.Find(context.TODO(), query)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]*models.WorkflowCodeSetting, 0)
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
+
+func (c *WorkflowCodeSettingColl) Upsert(setting *models.WorkflowCodeSetting) error {
+	query := bson.M{"project_name": setting.ProjectName}
+	change := bson.M{"$set": bson.M{
+		"enabled":        setting.Enabled,
+		"codehost_id":    setting.CodehostID,
+		"repo_owner":     setting.RepoOwner,
+		"repo_namespace": setting.RepoNamespace,
+		"repo_name":      setting.RepoName,
+		"branch":         setting.Branch,
+		"path":           setting.Path,
+		"updated_by":     setting.UpdatedBy,
+		"update_time":    setting.UpdateTime,
+	}}
+	_, err := c.UpdateOne(context.TODO(), query, change, options.Update().SetUpsert(true))
+	return err
+}
+
+func (c *WorkflowCodeSettingColl) UpdateSyncResult(projectName, commitSHA, syncError string, syncTime int64) error {
+	query := bson.M{"project_name": projectName}
+	change := bson.M{"$set": bson.M{
+		"last_commit_sha": commitSHA,
+		"last_sync_error": syncError,
+		"last_sync_time":  syncTime,
+	}}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+	return err
+}
+
+func (c *WorkflowCodeSettingColl) Delete(projectName string) error {
+	_, err := c.DeleteOne(context.TODO(), bson.M{"project_name": projectName})
+	return err
+}
@@ -124,3 +124,105 @@ func (c *WorkflowQueueColl) Update(args *models.WorkflowQueue) error {
 	_, err := c.UpdateOne(context.TODO(), query, change)
 	return err
 }
+
+// ClaimWaiting marks a waiting queue item as queued and owned by owner, only one replica can claim the item.
+func (c *WorkflowQueueColl) ClaimWaiting(args *models.WorkflowQueue, owner string, heartbeat int64) (bool, error) {
+	if args == nil {
+		return false, errors.New("nil workflow queue")
+	}
+
+	query := bson.M{"task_id": args.TaskID, "workflow_name": args.WorkflowName, "create_time": args.CreateTime, "status": config.StatusWaiting}
+	change := bson.M{"$set": bson.M{
+		"status":         config.StatusQueued,
+		"owner":          owner,
+		"heartbeat_time": heartbeat,
+	}}
+
+	res, err := c.UpdateOne(context.TODO(), query, change)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// UpdateWaitingPriority changes the priority class of a waiting queue item, it returns false if the item is not waiting.
+func (c *WorkflowQueueColl) UpdateWaitingPriority(workflowName string, taskID int64, priority config.PriorityClass) (bool, error) {
+	query := bson.M{"task_id": taskID, "workflow_name": workflowName, "status": config.StatusWaiting}
+	change := bson.M{"$set": bson.M{"priority_class": priority}}
+
+	res, err := c.UpdateOne(context.TODO(), query, change)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount == 1, nil
+}
+
+// RenewLease updates the heartbeat of a queue item, it returns false if the item is no longer owned by owner.
+func (c *WorkflowQueueColl) RenewLease(workflowName string, taskID int64, owner string, heartbeat int64) (bool, error) {
+	query := bson.M{"task_id": taskID, "workflow_name": workflowName, "owner": owner}
+	change := bson.M{"$set": bson.M{"heartbeat_time": heartbeat}}
+
+	res, err := c.UpdateOne(context.TODO(), query, change)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount == 1, nil
+}
+
+// ReleaseLeases expires the leases held by owner, so that the items can be taken over by any replica immediately.
+func (c *WorkflowQueueColl) ReleaseLeases(owner string) error {
+	query := bson.M{"owner": owner}
+	change := bson.M{"$set": bson.M{"heartbeat_time": int64(1)}}
+
+	_, err := c.UpdateMany(context.TODO(), query, change)
+	return err
+}
+
+// ListExpired lists the started queue items whose heartbeat is older than expireBefore.
+// Items created before leases were introduced have no heartbeat and are treated as expired.
+func (c *WorkflowQueueColl) ListExpired(expireBefore int64) ([]*models.WorkflowQueue, error) {
+	query := bson.M{
+		"status": bson.M{"$nin": []config.Status{config.StatusWaiting, config.StatusBlocked, config.QueueItemPending}},
+		"$or": []bson.M{
+			{"heartbeat_time": bson.M{"$lt": expireBefore}},
+			{"heartbeat_time": bson.M{"$exists": false}},
+		},
+	}
+
+	var resp []*models.WorkflowQueue
+	ctx := context.Background()
+	cursor, err := c.Collection.Find(ctx, query, options.Find().SetSort(bson.D{{"create_time", 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// TakeOver transfers an expired queue item to owner, it fails if the item has been renewed or taken over in the meantime.
+func (c *WorkflowQueueColl) TakeOver(args *models.WorkflowQueue, owner string, heartbeat int64) (bool, error) {
+	if args == nil {
+		return false, errors.New("nil workflow queue")
+	}
+
+	query := bson.M{"task_id": args.TaskID, "workflow_name": args.WorkflowName, "create_time": args.CreateTime}
+	if args.HeartbeatTime == 0 {
+		query["heartbeat_time"] = bson.M{"$exists": false}
+	} else {
+		query["heartbeat_time"] = args.HeartbeatTime
+	}
+	change := bson.M{"$set": bson.M{
+		"owner":          owner,
+		"heartbeat_time": heartbeat,
+	}}
+
+	res, err := c.UpdateOne(context.TODO(), query, change)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
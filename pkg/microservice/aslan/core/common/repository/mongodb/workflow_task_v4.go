@@ -214,6 +214,27 @@ func (c *WorkflowTaskv4Coll) Find(workflowName string, taskID int64) (*models.Wo
 	return resp, nil
 }
 
+func (c *WorkflowTaskv4Coll) UpdatePriorityClass(workflowName string, taskID int64, priority config.PriorityClass) error {
+	query := bson.M{"workflow_name": workflowName, "task_id": taskID}
+	change := bson.M{"$set": bson.M{"priority_class": priority}}
+
+	_, err := c.UpdateOne(context.TODO(), query, change)
+	return err
+}
+
+// UpdateSupersededBy only sets the fields of the superseded task, so that they are not overwritten by the
+// workflow controller which saves the whole task.
+func (c *WorkflowTaskv4Coll) UpdateSupersededBy(workflowName string, taskID, supersededBy int64, reason string) error {
+	query := bson.M{"workflow_name": workflowName, "task_id": taskID}
+	change := bson.M{"$set": bson.M{
+		"superseded_by": supersededBy,
+		"cancel_reason": reason,
+	}}
+
+	_, err := c.UpdateOne(context.TODO(), query, change)
+	return err
+}
+
 func (c *WorkflowTaskv4Coll) FindPreviousTask(workflowName, username string) (*models.WorkflowTask, error) {
 	resp := new(models.WorkflowTask)
 	query := bson.M{"workflow_name": workflowName, "task_creator": username}
@@ -196,6 +196,17 @@ func (c *WorkflowV4Coll) SetCustomFields(opts SetCustomFieldsOptions, args *mode
 	return err
 }
 
+// SetCodeSource sets the git source of the workflow, a nil source makes the workflow editable in the UI again.
+func (c *WorkflowV4Coll) SetCodeSource(name string, source *models.WorkflowV4CodeSource) error {
+	query := bson.M{"name": name}
+	change := bson.M{"$set": bson.M{"code_source": source}}
+	if source == nil {
+		change = bson.M{"$unset": bson.M{"code_source": ""}}
+	}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+	return err
+}
+
 type WorkFlowOptions struct {
 	ProjectName  string
 	WorkflowName string
@@ -0,0 +1,92 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type WorkflowV4RevisionColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewWorkflowV4RevisionColl() *WorkflowV4RevisionColl {
+	name := models.WorkflowV4Revision{}.TableName()
+	return &WorkflowV4RevisionColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *WorkflowV4RevisionColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *WorkflowV4RevisionColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys: bson.D{
+			bson.E{Key: "workflow_name", Value: 1},
+			bson.E{Key: "revision", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err := c.Indexes().CreateOne(ctx, mod)
+	return err
+}
+
+func (c *WorkflowV4RevisionColl) Create(obj *models.WorkflowV4Revision) error {
+	_, err := c.InsertOne(context.TODO(), obj)
+	return err
+}
+
+func (c *WorkflowV4RevisionColl) Find(workflowName string, revision int64) (*models.WorkflowV4Revision, error) {
+	resp := new(models.WorkflowV4Revision)
+	query := bson.M{"workflow_name": workflowName, "revision": revision}
+	err := c.FindOne(context.TODO(), query).Decode(resp)
+	return resp, err
+}
+
+// List lists the revisions of the workflow from the latest, the definitions are not returned.
+func (c *WorkflowV4RevisionColl) List(workflowName string, pageNum, pageSize int64) ([]*models.WorkflowV4Revision, int64, error) {
+	query := bson.M{"workflow_name": workflowName}
+	opts := options.Find().SetSort(bson.D{{"revision", -1}}).SetProjection(bson.M{"workflow": 0})
+	if pageNum > 0 && pageSize > 0 {
+		opts.SetSkip((pageNum - 1) * pageSize).SetLimit(pageSize)
+	}
+	cursor, err := c.Collection.Find(context.TODO(), query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp := make([]*models.WorkflowV4Revision, 0)
+	if err := cursor.All(context.TODO(), &resp); err != nil {
+		return nil, 0, err
+	}
+	count, err := c.CountDocuments(context.TODO(), query)
+	return resp, count, err
+}
+
+func (c *WorkflowV4RevisionColl) DeleteByWorkflowName(workflowName string) error {
+	_, err := c.DeleteMany(context.TODO(), bson.M{"workflow_name": workflowName})
+	return err
+}
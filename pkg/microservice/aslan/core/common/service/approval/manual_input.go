@@ -0,0 +1,173 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package approval
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+type ManualInputMap struct {
+	m map[string]*ManualInputWithLock
+	sync.RWMutex
+}
+
+type ManualInputWithLock struct {
+	Input *commonmodels.JobTaskManualInputSpec
+	sync.RWMutex
+}
+
+var GlobalManualInputMap ManualInputMap
+
+func init() {
+	GlobalManualInputMap.m = make(map[string]*ManualInputWithLock, 0)
+}
+
+func (c *ManualInputMap) SetManualInput(key string, value *ManualInputWithLock) {
+	c.Lock()
+	defer c.Unlock()
+	c.m[key] = value
+}
+
+func (c *ManualInputMap) GetManualInput(key string) (*ManualInputWithLock, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	v, existed := c.m[key]
+	return v, existed
+}
+
+func (c *ManualInputMap) DeleteManualInput(key string) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.m, key)
+}
+
+func (c *ManualInputWithLock) IsSubmitted() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.Input.Submitter != nil
+}
+
+// DoInput checks the authority of the user and the submitted values, then writes the values into the fields.
+// The fields not submitted fall back to their default values.
+func (c *ManualInputWithLock) DoInput(userName, userID, comment string, inputs map[string]string) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.Input.Submitter != nil {
+		return fmt.Errorf("inputs have been submitted by %s already", c.Input.Submitter.UserName)
+	}
+	authorized := false
+	for _, user := range c.Input.Users {
+		if user.UserID == userID {
+			authorized = true
+			break
+		}
+	}
+	if !authorized {
+		return fmt.Errorf("user %s has no authority to submit the inputs", userName)
+	}
+
+	values := make(map[string]string, len(c.Input.Fields))
+	for _, field := range c.Input.Fields {
+		value, ok := inputs[field.Name]
+		if !ok {
+			value = field.Default
+		}
+		if err := checkInputValue(field, value); err != nil {
+			return err
+		}
+		values[field.Name] = value
+	}
+	for name := range inputs {
+		if _, ok := values[name]; !ok {
+			return fmt.Errorf("unknown input %s", name)
+		}
+	}
+
+	for _, field := range c.Input.Fields {
+		field.Value = values[field.Name]
+	}
+	c.Input.Submitter = &commonmodels.User{
+		Type:            "user",
+		UserID:          userID,
+		UserName:        userName,
+		RejectOrApprove: config.Approve,
+		Comment:         comment,
+		OperationTime:   time.Now().Unix(),
+	}
+	return nil
+}
+
+func checkInputValue(field *commonmodels.Param, value string) error {
+	switch field.ParamsType {
+	case config.ParamTypeString:
+	case config.ParamTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("input %s should be true or false", field.Name)
+		}
+	case config.ParamTypeChoice:
+		for _, option := range field.ChoiceOption {
+			if option == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("input %s should be one of %v", field.Name, field.ChoiceOption)
+	default:
+		return fmt.Errorf("input %s has unsupported type %s", field.Name, field.ParamsType)
+	}
+	return nil
+}
+
+// LintManualInputFields checks the fields of a manual input job when the workflow is saved.
+func LintManualInputFields(fields []*commonmodels.Param) error {
+	names := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if field.Name == "" {
+			return fmt.Errorf("input name should not be empty")
+		}
+		if names[field.Name] {
+			return fmt.Errorf("duplicated input name %s", field.Name)
+		}
+		names[field.Name] = true
+		switch field.ParamsType {
+		case config.ParamTypeString:
+		case config.ParamTypeBool:
+			if field.Default != "" {
+				if err := checkInputValue(field, field.Default); err != nil {
+					return err
+				}
+			}
+		case config.ParamTypeChoice:
+			if len(field.ChoiceOption) == 0 {
+				return fmt.Errorf("input %s should have choice options", field.Name)
+			}
+			if field.Default != "" {
+				if err := checkInputValue(field, field.Default); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("input %s has unsupported type %s", field.Name, field.ParamsType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package approval
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func newManualInput() *ManualInputWithLock {
+	return &ManualInputWithLock{Input: &commonmodels.JobTaskManualInputSpec{
+		Users: []*commonmodels.User{{UserID: "u1", UserName: "alice"}},
+		Fields: []*commonmodels.Param{
+			{Name: "version", ParamsType: config.ParamTypeString, Default: "v1"},
+			{Name: "canary", ParamsType: config.ParamTypeBool, Default: "false"},
+			{Name: "region", ParamsType: config.ParamTypeChoice, ChoiceOption: []string{"cn", "us"}, Default: "cn"},
+		},
+	}}
+}
+
+func TestDoInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  string
+		inputs  map[string]string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:   "submitted values are written",
+			userID: "u1",
+			inputs: map[string]string{"version": "v2", "canary": "true", "region": "us"},
+			want:   map[string]string{"version": "v2", "canary": "true", "region": "us"},
+		},
+		{
+			name:   "missing values fall back to defaults",
+			userID: "u1",
+			inputs: map[string]string{"version": "v2"},
+			want:   map[string]string{"version": "v2", "canary": "false", "region": "cn"},
+		},
+		{name: "unauthorized user", userID: "u2", inputs: map[string]string{}, wantErr: true},
+		{name: "invalid bool", userID: "u1", inputs: map[string]string{"canary": "yes"}, wantErr: true},
+		{name: "invalid choice", userID: "u1", inputs: map[string]string{"region": "eu"}, wantErr: true},
+		{name: "unknown input", userID: "u1", inputs: map[string]string{"extra": "1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := newManualInput()
+			err := input.DoInput("alice", tt.userID, "ok", tt.inputs)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.False(t, input.IsSubmitted())
+				for _, field := range input.Input.Fields {
+					assert.Empty(t, field.Value)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, input.IsSubmitted())
+			assert.Equal(t, "u1", input.Input.Submitter.UserID)
+			assert.Equal(t, config.Approve, input.Input.Submitter.RejectOrApprove)
+			assert.Equal(t, "ok", input.Input.Submitter.Comment)
+			got := map[string]string{}
+			for _, field := range input.Input.Fields {
+				got[field.Name] = field.Value
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDoInputOnlyOnce(t *testing.T) {
+	input := newManualInput()
+	assert.NoError(t, input.DoInput("alice", "u1", "", map[string]string{"version": "v2"}))
+	assert.Error(t, input.DoInput("alice", "u1", "", map[string]string{"version": "v3"}))
+	assert.Equal(t, "v2", input.Input.Fields[0].Value)
+}
+
+func TestCheckInputValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   *commonmodels.Param
+		value   string
+		wantErr bool
+	}{
+		{name: "any string", field: &commonmodels.Param{Name: "a", ParamsType: config.ParamTypeString}, value: ""},
+		{name: "bool true", field: &commonmodels.Param{Name: "a", ParamsType: config.ParamTypeBool}, value: "true"},
+		{name: "bool invalid", field: &commonmodels.Param{Name: "a", ParamsType: config.ParamTypeBool}, value: "on", wantErr: true},
+		{name: "choice option", field: &commonmodels.Param{Name: "a", ParamsType: config.ParamTypeChoice, ChoiceOption: []string{"x", "y"}}, value: "y"},
+		{name: "choice invalid", field: &commonmodels.Param{Name: "a", ParamsType: config.ParamTypeChoice, ChoiceOption: []string{"x", "y"}}, value: "z", wantErr: true},
+		{name: "unsupported type", field: &commonmodels.Param{Name: "a", ParamsType: "repo"}, value: "x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkInputValue(tt.field, tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLintManualInputFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []*commonmodels.Param
+		wantErr bool
+	}{
+		{name: "valid fields", fields: newManualInput().Input.Fields},
+		{name: "no fields", fields: nil},
+		{name: "empty name", fields: []*commonmodels.Param{{ParamsType: config.ParamTypeString}}, wantErr: true},
+		{
+			name: "duplicated name",
+			fields: []*commonmodels.Param{
+				{Name: "a", ParamsType: config.ParamTypeString},
+				{Name: "a", ParamsType: config.ParamTypeBool},
+			},
+			wantErr: true,
+		},
+		{name: "bool without default", fields: []*commonmodels.Param{{Name: "a", ParamsType: config.ParamTypeBool}}},
+		{name: "invalid bool default", fields: []*commonmodels.Param{{Name: "a", ParamsType: config.ParamTypeBool, Default: "no"}}, wantErr: true},
+		{name: "choice without options", fields: []*commonmodels.Param{{Name: "a", ParamsType: config.ParamTypeChoice}}, wantErr: true},
+		{name: "invalid choice default", fields: []*commonmodels.Param{{Name: "a", ParamsType: config.ParamTypeChoice, ChoiceOption: []string{"x"}, Default: "y"}}, wantErr: true},
+		{name: "unsupported type", fields: []*commonmodels.Param{{Name: "a", ParamsType: "text"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := LintManualInputFields(tt.fields)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package approval
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	configbase "github.com/koderover/zadig/pkg/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/httpclient"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+const (
+	WebhookSignatureHeader = "X-Zadig-Signature"
+	WebhookTimestampHeader = "X-Zadig-Timestamp"
+
+	WebhookApprovalPending  = "pending"
+	WebhookApprovalApproved = "approved"
+	WebhookApprovalRejected = "rejected"
+
+	WebhookApprovalEventCreate = "create"
+	WebhookApprovalEventCancel = "cancel"
+
+	// signed requests older than webhookSignatureExpiration are rejected to avoid replay
+	webhookSignatureExpiration = 5 * time.Minute
+)
+
+// WebhookApprovalRequest is posted to the webhook approval system when an approval starts.
+type WebhookApprovalRequest struct {
+	ApprovalID          string `json:"approval_id"`
+	Event               string `json:"event"`
+	CallbackURL         string `json:"callback_url"`
+	ProjectName         string `json:"project_name"`
+	WorkflowName        string `json:"workflow_name"`
+	WorkflowDisplayName string `json:"workflow_display_name"`
+	TaskID              int64  `json:"task_id"`
+	// Target is the stage or job waiting for the approval
+	Target      string `json:"target"`
+	Description string `json:"description"`
+	Creator     string `json:"creator"`
+	DetailURL   string `json:"detail_url"`
+	// Timeout minute
+	Timeout int `json:"timeout"`
+}
+
+// WebhookApprovalCancelRequest is posted to the webhook approval system when the workflow stops waiting for
+// the approval, the approval system should close the approval instance.
+type WebhookApprovalCancelRequest struct {
+	ApprovalID string `json:"approval_id"`
+	Event      string `json:"event"`
+	Reason     string `json:"reason"`
+}
+
+// WebhookApprovalCallback is the result reported by the webhook approval system, either by calling back
+// or as the response of the status url.
+type WebhookApprovalCallback struct {
+	ApprovalID string             `json:"approval_id"`
+	Status     string             `json:"status"`
+	Approvers  []*WebhookApprover `json:"approvers"`
+}
+
+type WebhookApprover struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Result  string `json:"result"`
+	Comment string `json:"comment"`
+	// OperationTime unix second
+	OperationTime int64 `json:"operation_time"`
+}
+
+// SignWebhookPayload returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>".
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	return util.ComputeHmacSha256(timestamp+"."+string(body), secret)
+}
+
+func verifyWebhookSignature(secret, signature, timestamp string, body []byte) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > webhookSignatureExpiration || d < -webhookSignatureExpiration {
+		return errors.New("timestamp expired")
+	}
+	if !hmac.Equal([]byte(signature), []byte(SignWebhookPayload(secret, timestamp, body))) {
+		return errors.New("check sign failed")
+	}
+	return nil
+}
+
+func getWebhookApp(id string) (*commonmodels.IMApp, error) {
+	app, err := mongodb.NewIMAppColl().GetByID(context.Background(), id)
+	if err != nil {
+		return nil, errors.Wrap(err, "get webhook approval app")
+	}
+	if app.Type != setting.IMWebhook {
+		return nil, errors.Errorf("unexpected imApp type %s", app.Type)
+	}
+	return app, nil
+}
+
+func signedHeaders(secret string, body []byte) map[string]string {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	return map[string]string{
+		WebhookTimestampHeader: timestamp,
+		WebhookSignatureHeader: SignWebhookPayload(secret, timestamp, body),
+		"Content-Type":         "application/json",
+	}
+}
+
+// SendWebhookApproval posts the signed approval request to the approval system of the im app.
+func SendWebhookApproval(appID string, req *WebhookApprovalRequest) error {
+	app, err := getWebhookApp(appID)
+	if err != nil {
+		return err
+	}
+	req.Event = WebhookApprovalEventCreate
+	req.CallbackURL = fmt.Sprintf("%s/api/aslan/system/approval_webhook/%s/callback", configbase.SystemAddress(), appID)
+	return postWebhookApproval(app, req)
+}
+
+// CancelWebhookApproval tells the approval system of the im app that the approval is no longer waited for.
+func CancelWebhookApproval(appID, approvalID, reason string) error {
+	app, err := getWebhookApp(appID)
+	if err != nil {
+		return err
+	}
+	return postWebhookApproval(app, &WebhookApprovalCancelRequest{
+		ApprovalID: approvalID,
+		Event:      WebhookApprovalEventCancel,
+		Reason:     reason,
+	})
+}
+
+func postWebhookApproval(app *commonmodels.IMApp, req interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	_, err = httpclient.Post(app.WebhookURL, httpclient.SetHeaders(signedHeaders(app.WebhookSecret, body)), httpclient.SetBody(body))
+	return err
+}
+
+// GetWebhookApprovalResult returns the result saved by the callbacks, the status url of the im app is polled
+// if it is configured. A nil result means the approval system has reported nothing yet.
+func GetWebhookApprovalResult(appID, approvalID string, poll bool) (*commonmodels.WebhookApprovalResult, error) {
+	if poll {
+		app, err := getWebhookApp(appID)
+		if err != nil {
+			return nil, err
+		}
+		if app.WebhookStatusURL != "" {
+			res, err := httpclient.Get(app.WebhookStatusURL,
+				httpclient.SetHeaders(signedHeaders(app.WebhookSecret, nil)),
+				httpclient.SetQueryParam("approval_id", approvalID))
+			if err != nil {
+				return nil, errors.Wrap(err, "get webhook approval status")
+			}
+			callback := &WebhookApprovalCallback{}
+			if err := json.Unmarshal(res.Body(), callback); err != nil {
+				return nil, errors.Wrap(err, "unmarshal webhook approval status")
+			}
+			callback.ApprovalID = approvalID
+			if err := saveWebhookApprovalResult(appID, callback); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result, err := mongodb.NewWebhookApprovalResultColl().Find(approvalID)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	return result, err
+}
+
+func DeleteWebhookApprovalResult(approvalID string) {
+	if err := mongodb.NewWebhookApprovalResultColl().Delete(approvalID); err != nil {
+		log.Warnf("delete webhook approval result %s error: %v", approvalID, err)
+	}
+}
+
+// WebhookApprovalEventHandler handles the signed callback from the approval system.
+func WebhookApprovalEventHandler(appID, signature, timestamp string, body []byte) error {
+	app, err := getWebhookApp(appID)
+	if err != nil {
+		return err
+	}
+	if err := verifyWebhookSignature(app.WebhookSecret, signature, timestamp, body); err != nil {
+		log.Errorf("webhook approval callback of %s: %v", appID, err)
+		return err
+	}
+	callback := &WebhookApprovalCallback{}
+	if err := json.Unmarshal(body, callback); err != nil {
+		return errors.Wrap(err, "unmarshal")
+	}
+	if callback.ApprovalID == "" {
+		return errors.New("approval_id is empty")
+	}
+	log.Infof("webhook approval callback of %s: approval %s status %s", appID, callback.ApprovalID, callback.Status)
+	return saveWebhookApprovalResult(appID, callback)
+}
+
+func saveWebhookApprovalResult(appID string, callback *WebhookApprovalCallback) error {
+	switch callback.Status {
+	case WebhookApprovalPending, WebhookApprovalApproved, WebhookApprovalRejected:
+	default:
+		return errors.Errorf("unknown approval status %s", callback.Status)
+	}
+	result := &commonmodels.WebhookApprovalResult{
+		ApprovalID: callback.ApprovalID,
+		IMAppID:    appID,
+		Status:     callback.Status,
+		Approvers:  make([]*commonmodels.WebhookApprovalUser, 0),
+	}
+	for _, approver := range callback.Approvers {
+		user := &commonmodels.WebhookApprovalUser{
+			ID:            approver.ID,
+			Name:          approver.Name,
+			Comment:       approver.Comment,
+			OperationTime: approver.OperationTime,
+		}
+		switch approver.Result {
+		case string(config.Approve), WebhookApprovalApproved:
+			user.RejectOrApprove = config.Approve
+		case string(config.Reject), WebhookApprovalRejected:
+			user.RejectOrApprove = config.Reject
+		}
+		result.Approvers = append(result.Approvers, user)
+	}
+	return mongodb.NewWebhookApprovalResultColl().Upsert(result)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package approval
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	body := []byte(`{"approval_id":"1","status":"approved"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), SignWebhookPayload("secret", "1700000000", body))
+	assert.NotEqual(t, SignWebhookPayload("secret", "1700000000", body), SignWebhookPayload("secret", "1700000001", body))
+	assert.NotEqual(t, SignWebhookPayload("secret", "1700000000", body), SignWebhookPayload("other", "1700000000", body))
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"approval_id":"1","status":"approved"}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	expired := strconv.FormatInt(time.Now().Add(-webhookSignatureExpiration-time.Minute).Unix(), 10)
+	future := strconv.FormatInt(time.Now().Add(webhookSignatureExpiration+time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		timestamp string
+		body      []byte
+		wantErr   bool
+	}{
+		{name: "valid signature", secret: "secret", signature: SignWebhookPayload("secret", now, body), timestamp: now, body: body},
+		{name: "wrong secret", secret: "other", signature: SignWebhookPayload("secret", now, body), timestamp: now, body: body, wantErr: true},
+		{name: "tampered body", secret: "secret", signature: SignWebhookPayload("secret", now, body), timestamp: now, body: []byte(`{"approval_id":"1","status":"rejected"}`), wantErr: true},
+		{name: "timestamp not signed", secret: "secret", signature: SignWebhookPayload("secret", now, body), timestamp: strconv.FormatInt(time.Now().Unix()-1, 10), body: body, wantErr: true},
+		{name: "expired timestamp", secret: "secret", signature: SignWebhookPayload("secret", expired, body), timestamp: expired, body: body, wantErr: true},
+		{name: "future timestamp", secret: "secret", signature: SignWebhookPayload("secret", future, body), timestamp: future, body: body, wantErr: true},
+		{name: "invalid timestamp", secret: "secret", signature: SignWebhookPayload("secret", "now", body), timestamp: "now", body: body, wantErr: true},
+		{name: "empty signature", secret: "secret", timestamp: now, body: body, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyWebhookSignature(tt.secret, tt.signature, tt.timestamp, tt.body)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSignedHeaders(t *testing.T) {
+	body := []byte(`{"approval_id":"1"}`)
+	headers := signedHeaders("secret", body)
+
+	assert.Equal(t, "application/json", headers["Content-Type"])
+	assert.NoError(t, verifyWebhookSignature("secret", headers[WebhookSignatureHeader], headers[WebhookTimestampHeader], body))
+}
@@ -75,6 +75,11 @@ type Command struct {
 }
 
 func RunGitCmds(codehostDetail *systemconfig.CodeHost, repoOwner, repoNamespace, repoName, branchName, remoteName string) error {
+	return RunGitCmdsInDir(codehostDetail, repoOwner, repoNamespace, repoName, branchName, remoteName, "")
+}
+
+// RunGitCmdsInDir fetches the branch into workDir, the shared workspace named after the repo is used if workDir is empty.
+func RunGitCmdsInDir(codehostDetail *systemconfig.CodeHost, repoOwner, repoNamespace, repoName, branchName, remoteName, workDir string) error {
 	var (
 		tokens []string
 		repo   *Repo
@@ -109,7 +114,7 @@ func RunGitCmds(codehostDetail *systemconfig.CodeHost, repoOwner, repoNamespace,
 		tokens = append(tokens, repo.Password)
 	}
 	tokens = append(tokens, repo.OauthToken)
-	cmds = append(cmds, buildGitCommands(repo, hostNames)...)
+	cmds = append(cmds, buildGitCommands(repo, hostNames, workDir)...)
 
 	// write ssh key
 	if len(hostNames.List()) > 0 {
@@ -168,7 +173,7 @@ func RunGitCmds(codehostDetail *systemconfig.CodeHost, repoOwner, repoNamespace,
 	return nil
 }
 
-func buildGitCommands(repo *Repo, hostNames sets.String) []*Command {
+func buildGitCommands(repo *Repo, hostNames sets.String, workDir string) []*Command {
 	cmds := make([]*Command, 0)
 
 	if len(repo.Name) == 0 {
@@ -179,7 +184,9 @@ func buildGitCommands(repo *Repo, hostNames sets.String) []*Command {
 	if strings.Contains(repoName, "/") {
 		repoName = strings.Replace(repoName, "/", "-", -1)
 	}
-	workDir := filepath.Join(config.S3StoragePath(), repoName)
+	if workDir == "" {
+		workDir = filepath.Join(config.S3StoragePath(), repoName)
+	}
 	if _, err := os.Stat(workDir); os.IsNotExist(err) {
 		os.MkdirAll(workDir, 0777)
 	}
@@ -214,6 +214,9 @@ func GetSubTaskContent(deliveryVersion *commonmodels.DeliveryVersion, pipelineTa
 					deliveryDeploy.RegistryID = pipelineTask.WorkflowArgs.RegistryID
 				}
 				deliveryDeploy.Image = deployInfo.Image
+				if artifact, err := commonrepo.NewSupplyChainArtifactColl().FindLatestByImage(deployInfo.Image); err == nil {
+					deliveryDeploy.SupplyChain = artifact
+				}
 
 				containers := make([]*commonmodels.Container, 0)
 				container := new(commonmodels.Container)
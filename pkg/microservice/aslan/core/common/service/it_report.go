@@ -238,5 +238,8 @@ func GetWorkflowV4LocalTestSuite(workflowName, jobName string, taskID int64, log
 		log.Error(msg)
 		return testReport, errors.New(msg)
 	}
+	if coverage, err := mongodb.NewTestCoverageColl().FindByJob(workflowName, taskID, jobName); err == nil {
+		testReport.Coverage = coverage
+	}
 	return testReport, nil
 }
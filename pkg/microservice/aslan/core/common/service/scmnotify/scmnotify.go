@@ -121,6 +121,52 @@ func (s *Service) SendErrWebhookComment(
 	return notification, nil
 }
 
+// SendEnvPreviewComment posts the preview environment of the pull request, the comment is updated in place if the
+// notification already exists, so the workflow tasks triggered for the preview environment are listed with it
+func (s *Service) SendEnvPreviewComment(
+	mainRepo *models.MainHookRepo, prID int, baseURI, notificationID string, info *models.EnvPreviewInfo, logger *zap.SugaredLogger,
+) (*models.Notification, error) {
+	if notificationID != "" {
+		notification, err := s.Coll.Find(notificationID)
+		if err != nil {
+			logger.Errorf("failed to find notification %s, err: %s", notificationID, err)
+			return nil, err
+		}
+		notification.EnvPreview = info
+		if err := s.Coll.Upsert(notification); err != nil {
+			logger.Errorf("failed to save %s %v", notification.ToString(), err)
+			return nil, err
+		}
+		if err := s.Client.Comment(notification); err != nil {
+			logger.Errorf("failed to comment to %s %v", notification.ToString(), err)
+			return nil, err
+		}
+		return notification, nil
+	}
+
+	notification := &models.Notification{
+		CodehostID:   mainRepo.CodehostID,
+		PrID:         prID,
+		ProjectID:    strings.TrimLeft(mainRepo.GetRepoNamespace()+"/"+mainRepo.RepoName, "/"),
+		BaseURI:      baseURI,
+		IsWorkflowV4: true,
+		Revision:     mainRepo.Revision,
+		RepoOwner:    mainRepo.RepoOwner,
+		RepoName:     mainRepo.RepoName,
+		EnvPreview:   info,
+	}
+
+	if err := s.Client.Comment(notification); err != nil {
+		logger.Errorf("failed to comment to %s %v", notification.ToString(), err)
+		return nil, err
+	} else if err := s.Coll.Create(notification); err != nil {
+		logger.Errorf("failed to save %s %v", notification.ToString(), err)
+		return nil, err
+	}
+
+	return notification, nil
+}
+
 func convertTaskStatusToNotificationTaskStatus(status config.Status) config.TaskStatus {
 	switch status {
 	case config.StatusWaiting:
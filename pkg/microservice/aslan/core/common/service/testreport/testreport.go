@@ -0,0 +1,205 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/s3"
+	"github.com/koderover/zadig/pkg/setting"
+	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+// TestJobInfo identifies the testing job and the tested commit the results belong to.
+type TestJobInfo struct {
+	ProjectName  string
+	WorkflowName string
+	TaskID       int64
+	JobName      string
+	TestName     string
+	RepoName     string
+	Branch       string
+	PR           int
+	Commit       string
+}
+
+// DownloadTestResult downloads the test result written by the junit report step from the default object storage.
+func DownloadTestResult(s3DestDir string) (*types.TestResultReport, error) {
+	storage, err := s3.FindDefaultS3()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find default s3: %s", err)
+	}
+	forcedPathStyle := true
+	if storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3tool.NewClient(storage.Endpoint, storage.Ak, storage.Sk, storage.Region, storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %s", err)
+	}
+	filename, err := util.GenerateTmpFile()
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(filename)
+	objectKey := storage.GetObjectPath(path.Join(s3DestDir, step.TestResultFileName))
+	if err := client.Download(storage.Bucket, objectKey, filename); err != nil {
+		return nil, fmt.Errorf("failed to download test result %s: %s", objectKey, err)
+	}
+	resultBytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	report := &types.TestResultReport{}
+	if err := json.Unmarshal(resultBytes, report); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal test result: %s", err)
+	}
+	return report, nil
+}
+
+// SaveTestResult saves the case results and the coverage of the job. A case is marked as flaky if it
+// passed in one run and failed in another on the same commit.
+func SaveTestResult(job *TestJobInfo, report *types.TestResultReport) error {
+	if job.Commit == "" {
+		job.Commit = report.Commit
+	}
+	now := time.Now().Unix()
+
+	results := make([]*commonmodels.TestCaseResult, 0, len(report.Cases))
+	for _, testCase := range report.Cases {
+		results = append(results, &commonmodels.TestCaseResult{
+			ProjectName:    job.ProjectName,
+			WorkflowName:   job.WorkflowName,
+			TaskID:         job.TaskID,
+			JobName:        job.JobName,
+			TestName:       job.TestName,
+			RepoName:       job.RepoName,
+			Branch:         job.Branch,
+			PR:             job.PR,
+			Commit:         job.Commit,
+			TestCaseResult: *testCase,
+			CreateTime:     now,
+		})
+	}
+	flakyCases, err := detectFlakyCases(job, results)
+	if err != nil {
+		return fmt.Errorf("failed to detect flaky cases: %s", err)
+	}
+	if err := commonrepo.NewTestCaseResultColl().ReplaceByJob(job.WorkflowName, job.TaskID, job.JobName, results); err != nil {
+		return fmt.Errorf("failed to save test case results: %s", err)
+	}
+	for _, testCase := range flakyCases {
+		if err := commonrepo.NewTestCaseResultColl().MarkFlaky(job.TestName, job.Commit, testCase.Suite, testCase.Name); err != nil {
+			return fmt.Errorf("failed to mark case %s as flaky: %s", testCase.Name, err)
+		}
+	}
+
+	if report.Coverage == nil {
+		return nil
+	}
+	return saveCoverage(job, report.Coverage, now)
+}
+
+// detectFlakyCases compares the results with the results of the other runs on the same commit.
+func detectFlakyCases(job *TestJobInfo, results []*commonmodels.TestCaseResult) ([]*commonmodels.TestCaseResult, error) {
+	flakyCases := make([]*commonmodels.TestCaseResult, 0)
+	if job.Commit == "" {
+		return flakyCases, nil
+	}
+	history, err := commonrepo.NewTestCaseResultColl().ListByCommit(job.TestName, job.Commit)
+	if err != nil {
+		return nil, err
+	}
+
+	outcomes := make(map[string]map[bool]bool)
+	for _, result := range history {
+		if result.WorkflowName == job.WorkflowName && result.TaskID == job.TaskID && result.JobName == job.JobName {
+			continue
+		}
+		passed, ok := outcome(result.Status)
+		if !ok {
+			continue
+		}
+		key := result.Suite + "/" + result.Name
+		if outcomes[key] == nil {
+			outcomes[key] = make(map[bool]bool)
+		}
+		outcomes[key][passed] = true
+	}
+	for _, result := range results {
+		passed, ok := outcome(result.Status)
+		if ok && outcomes[result.Suite+"/"+result.Name][!passed] {
+			result.Flaky = true
+			flakyCases = append(flakyCases, result)
+		}
+	}
+	return flakyCases, nil
+}
+
+// outcome tells whether a case passed, skipped cases have no outcome.
+func outcome(status string) (bool, bool) {
+	switch status {
+	case types.TestCaseStatusPassed:
+		return true, true
+	case types.TestCaseStatusFailed, types.TestCaseStatusError:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+func saveCoverage(job *TestJobInfo, summary *types.CoverageSummary, now int64) error {
+	coverage := &commonmodels.TestCoverage{
+		ProjectName:  job.ProjectName,
+		WorkflowName: job.WorkflowName,
+		TaskID:       job.TaskID,
+		JobName:      job.JobName,
+		TestName:     job.TestName,
+		RepoName:     job.RepoName,
+		Branch:       job.Branch,
+		PR:           job.PR,
+		Commit:       job.Commit,
+		Coverage:     summary,
+		CreateTime:   now,
+	}
+
+	// the branch of a pull request is the target branch, so the coverage is compared with the target branch itself
+	base, err := commonrepo.NewTestCoverageColl().FindLatestOnBranch(job.TestName, job.RepoName, job.Branch, now)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return fmt.Errorf("failed to find the coverage of branch %s: %s", job.Branch, err)
+	}
+	if err == nil && base.Coverage != nil && !(base.WorkflowName == job.WorkflowName && base.TaskID == job.TaskID) {
+		coverage.BaseWorkflowName = base.WorkflowName
+		coverage.BaseTaskID = base.TaskID
+		coverage.BaseLineRate = base.Coverage.LineRate
+		coverage.LineRateDelta = summary.LineRate - base.Coverage.LineRate
+		coverage.BaseBranchRate = base.Coverage.BranchRate
+		coverage.BranchRateDelta = summary.BranchRate - base.Coverage.BranchRate
+	}
+	return commonrepo.NewTestCoverageColl().Upsert(coverage)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vulnerability
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/s3"
+	"github.com/koderover/zadig/pkg/setting"
+	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/tool/trivy"
+	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+// ReportDir is the dir of the raw report in the default object storage.
+func ReportDir(workflowName string, taskID int64, jobName string) string {
+	return path.Join(workflowName, strconv.FormatInt(taskID, 10), jobName, "vulnerability")
+}
+
+// GetDBSettings returns the vulnerability database mirror, nil means the public database is used.
+func GetDBSettings() *types.VulnerabilityDBSettings {
+	configuration, err := commonrepo.NewSystemSettingColl().Get()
+	if err != nil || configuration.VulnerabilityDB == nil || configuration.VulnerabilityDB.DBRepository == "" {
+		return nil
+	}
+	return configuration.VulnerabilityDB
+}
+
+// NewScanSpec builds the scanner step spec from the scanning module, scanning the repo by default.
+func NewScanSpec(trivySetting *commonmodels.TrivySetting, repoName string) *step.StepVulnerabilityScanSpec {
+	scanDir := trivySetting.ScanDir
+	if scanDir == "" {
+		scanDir = repoName
+	}
+	return &step.StepVulnerabilityScanSpec{
+		Target:        trivySetting.Target,
+		Image:         trivySetting.Image,
+		ScanDir:       scanDir,
+		Severities:    trivySetting.Severities,
+		IgnoreUnfixed: trivySetting.IgnoreUnfixed,
+		QualityGate:   trivySetting.QualityGate,
+	}
+}
+
+// FindImageRegistry finds the registry the image belongs to, so that private images can be pulled.
+func FindImageRegistry(image string, registries []*commonmodels.RegistryNamespace) *step.DockerRegistry {
+	for _, registry := range registries {
+		host := strings.TrimPrefix(strings.TrimPrefix(registry.RegAddr, "https://"), "http://")
+		if host == "" || !strings.HasPrefix(image, strings.TrimSuffix(host, "/")+"/") {
+			continue
+		}
+		return &step.DockerRegistry{
+			DockerRegistryID: registry.ID.Hex(),
+			Host:             registry.RegAddr,
+			UserName:         registry.AccessKey,
+			Password:         registry.SecretKey,
+			Namespace:        registry.Namespace,
+		}
+	}
+	return nil
+}
+
+// SaveScanResult parses the report uploaded by the scanner into the result and saves it.
+func SaveScanResult(result *commonmodels.VulnerabilityScanResult, gate *types.VulnerabilityQualityGate) error {
+	storage, err := s3.FindDefaultS3()
+	if err != nil {
+		return fmt.Errorf("failed to find default s3: %s", err)
+	}
+	forcedPathStyle := true
+	if storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3tool.NewClient(storage.Endpoint, storage.Ak, storage.Sk, storage.Region, storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return fmt.Errorf("failed to create s3 client: %s", err)
+	}
+	filename, err := util.GenerateTmpFile()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(filename)
+	objectKey := storage.GetObjectPath(path.Join(ReportDir(result.WorkflowName, result.TaskID, result.JobName), step.VulnerabilityReportFileName))
+	if err := client.Download(storage.Bucket, objectKey, filename); err != nil {
+		return fmt.Errorf("failed to download vulnerability report %s: %s", objectKey, err)
+	}
+	report, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	findings, err := trivy.ParseReport(report)
+	if err != nil {
+		return err
+	}
+	result.Findings = findings
+	result.Summary = trivy.Summarize(findings)
+	result.Violations = trivy.CheckQualityGate(gate, findings)
+	result.QualityGatePassed = len(result.Violations) == 0
+	result.CreateTime = time.Now().Unix()
+	return commonrepo.NewVulnerabilityScanResultColl().Upsert(result)
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Knetic/govaluate"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const manualEventType = "manual"
+
+var (
+	conditionVariableRegexp = regexp.MustCompile(`\{\{[^}]*\}\}`)
+	// a variable may be quoted as a string literal, e.g. "{{.workflow.params.env}}" == "prod"
+	conditionQuotedVariableRegexp = regexp.MustCompile(`"\{\{[^}]*\}\}"|'\{\{[^}]*\}\}'|\{\{[^}]*\}\}`)
+)
+
+// evaluateCondition evaluates the when expression of a job or a stage, the {{.xxx}} variables in global context,
+// like job outputs and workflow params, are passed to the expression as parameters, see bindConditionVariables.
+// Variables:
+//
+//	branch, event, project, workflow
+//
+// Functions:
+//
+//	success()             all the finished jobs have passed
+//	failure()             any finished job failed, timed out or was rejected
+//	always()              always true
+//	status(job)           status of a job, the failed status wins if the job has several job tasks
+//	param(name)           value of a workflow param
+//	output(key, name)     output of a job, key is the job key such as "build.service.module"
+//	vulnerabilities(key[, severity])
+//	                      number of vulnerabilities found by a trivy scanning, key is the job key such as "scan.scanning",
+//	                      severity is critical, high, medium, low or fixable, all vulnerabilities are counted if it is omitted
+func (c *workflowCtl) evaluateCondition(when string) (bool, error) {
+	parameters := c.conditionVariables()
+	rendered := bindConditionVariables(when, c.getGlobalContext, parameters)
+
+	expression, err := govaluate.NewEvaluableExpressionWithFunctions(rendered, c.conditionFunctions())
+	if err != nil {
+		return false, fmt.Errorf("parse condition %s error: %v", when, err)
+	}
+	result, err := expression.Evaluate(parameters)
+	if err != nil {
+		return false, fmt.Errorf("evaluate condition %s error: %v", when, err)
+	}
+	passed, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition %s should return a bool value, got: %v", when, result)
+	}
+	return passed, nil
+}
+
+func (c *workflowCtl) conditionFunctions() map[string]govaluate.ExpressionFunction {
+	return map[string]govaluate.ExpressionFunction{
+		"success": func(args ...interface{}) (interface{}, error) {
+			return !c.anyJobFailed(), nil
+		},
+		"failure": func(args ...interface{}) (interface{}, error) {
+			return c.anyJobFailed(), nil
+		},
+		"always": func(args ...interface{}) (interface{}, error) {
+			return true, nil
+		},
+		"status": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("status() needs 1 argument")
+			}
+			return string(c.jobStatus(fmt.Sprint(args[0]))), nil
+		},
+		"param": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("param() needs 1 argument")
+			}
+			for _, param := range c.workflowTask.Params {
+				if param.Name == fmt.Sprint(args[0]) {
+					return param.Value, nil
+				}
+			}
+			return "", nil
+		},
+		"output": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("output() needs 2 arguments")
+			}
+			value, _ := c.getGlobalContext(job.GetJobOutputKey(fmt.Sprint(args[0]), fmt.Sprint(args[1])))
+			return value, nil
+		},
+		"vulnerabilities": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return nil, fmt.Errorf("vulnerabilities() needs 1 or 2 arguments")
+			}
+			outputName := step.VulnerabilityOutputTotal
+			if len(args) == 2 {
+				outputName = "VULNERABILITY_" + strings.ToUpper(fmt.Sprint(args[1]))
+			}
+			value, ok := c.getGlobalContext(job.GetJobOutputKey(fmt.Sprint(args[0]), outputName))
+			if !ok {
+				return nil, fmt.Errorf("vulnerabilities of %s not found", args[0])
+			}
+			count, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid vulnerability count %s of %s", value, args[0])
+			}
+			return count, nil
+		},
+	}
+}
+
+// bindConditionVariables replaces the {{.xxx}} variables found by lookup with parameters added to parameters,
+// so that their values are never parsed as a part of the expression. A quoted variable is a string, a bare
+// variable is a number if its value is numeric. Unknown variables are kept and fail the parsing.
+func bindConditionVariables(when string, lookup func(key string) (string, bool), parameters map[string]interface{}) string {
+	index := 0
+	return conditionQuotedVariableRegexp.ReplaceAllStringFunc(when, func(match string) string {
+		key, quoted := match, false
+		if strings.HasPrefix(match, `"`) || strings.HasPrefix(match, "'") {
+			key, quoted = match[1:len(match)-1], true
+		}
+		value, ok := lookup(key)
+		if !ok {
+			return match
+		}
+		value = strings.Trim(value, "\n")
+
+		name := fmt.Sprintf("zadig_var_%d", index)
+		index++
+		parameters[name] = value
+		if !quoted {
+			if number, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				parameters[name] = number
+			}
+		}
+		return name
+	})
+}
+
+// LintCondition checks the syntax of a when expression, the {{.xxx}} variables are replaced since they are
+// only known at runtime.
+func LintCondition(when string) error {
+	expression := conditionVariableRegexp.ReplaceAllString(when, "x")
+	if _, err := govaluate.NewEvaluableExpressionWithFunctions(expression, (&workflowCtl{}).conditionFunctions()); err != nil {
+		return fmt.Errorf("invalid condition %s: %v", when, err)
+	}
+	return nil
+}
+
+func (c *workflowCtl) conditionVariables() map[string]interface{} {
+	branch, event := "", manualEventType
+	if c.workflowTask.WorkflowArgs != nil && c.workflowTask.WorkflowArgs.HookPayload != nil {
+		payload := c.workflowTask.WorkflowArgs.HookPayload
+		branch = payload.Branch
+		if branch == "" {
+			branch = strings.TrimPrefix(payload.Ref, "refs/heads/")
+		}
+		event = payload.EventType
+	} else {
+		for _, param := range c.workflowTask.Params {
+			if param.Repo != nil && param.Repo.Branch != "" {
+				branch = param.Repo.Branch
+				break
+			}
+		}
+	}
+	return map[string]interface{}{
+		"branch":   branch,
+		"event":    event,
+		"project":  c.workflowTask.ProjectName,
+		"workflow": c.workflowTask.WorkflowName,
+	}
+}
+
+func (c *workflowCtl) anyJobFailed() bool {
+	for _, stage := range c.workflowTask.Stages {
+		for _, jobTask := range stage.Jobs {
+			if statusFailed(jobTask.Status) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *workflowCtl) jobStatus(name string) config.Status {
+	var status config.Status
+	for _, stage := range c.workflowTask.Stages {
+		for _, jobTask := range stage.Jobs {
+			if jobTask.OriginName != name && jobTask.Name != name {
+				continue
+			}
+			if status == "" || statusFailed(jobTask.Status) {
+				status = jobTask.Status
+			}
+		}
+	}
+	return status
+}
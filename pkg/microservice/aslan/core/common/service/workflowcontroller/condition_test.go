@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"testing"
+
+	"github.com/Knetic/govaluate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindConditionVariables(t *testing.T) {
+	context := map[string]string{
+		"{{.workflow.params.env}}":           "prod",
+		"{{.job.build.svc.output.COUNT}}":    "3\n",
+		"{{.workflow.params.injected}}":      `x" || true || "`,
+		"{{.workflow.params.version}}":       "1.0",
+		"{{.workflow.params.expression}}":    "1 == 1",
+		"{{.workflow.params.quoted_number}}": "42",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := context[key]
+		return v, ok
+	}
+
+	tests := []struct {
+		name    string
+		when    string
+		want    bool
+		wantErr bool
+	}{
+		{name: "bare string variable", when: `{{.workflow.params.env}} == "prod"`, want: true},
+		{name: "quoted string variable", when: `"{{.workflow.params.env}}" == "prod"`, want: true},
+		{name: "single quoted string variable", when: `'{{.workflow.params.env}}' != 'dev'`, want: true},
+		{name: "numeric variable", when: `{{.job.build.svc.output.COUNT}} > 2`, want: true},
+		{name: "quoted numeric variable is a string", when: `"{{.workflow.params.quoted_number}}" == "42"`, want: true},
+		{name: "value is not parsed as expression", when: `"{{.workflow.params.injected}}" == "safe"`, want: false},
+		{name: "bare value is not parsed as expression", when: `{{.workflow.params.expression}} == "1 == 1"`, want: true},
+		{name: "variables are combined", when: `{{.workflow.params.env}} == "prod" && {{.workflow.params.version}} >= 1`, want: true},
+		{name: "unknown variable fails", when: `{{.workflow.params.unknown}} == "prod"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parameters := map[string]interface{}{}
+			rendered := bindConditionVariables(tt.when, lookup, parameters)
+			expression, err := govaluate.NewEvaluableExpression(rendered)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			result, err := expression.Evaluate(parameters)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, result)
+		})
+	}
+}
+
+func TestLintCondition(t *testing.T) {
+	assert.NoError(t, LintCondition(`{{.workflow.params.env}} == "prod" && success()`))
+	assert.NoError(t, LintCondition(`status("build") == "failed" || failure()`))
+	assert.Error(t, LintCondition(`{{.workflow.params.env}} ==`))
+}
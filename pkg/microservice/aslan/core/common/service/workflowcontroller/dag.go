@@ -0,0 +1,324 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller/jobcontroller"
+)
+
+// JobNode is a job in the dependency graph of a workflow.
+// Several nodes share the same name when a job is split into many job tasks.
+type JobNode struct {
+	Name  string
+	Needs []string
+	// Stage is the index of the stage the job belongs to.
+	Stage int
+	// Parallel is true if the jobs of the stage run in parallel.
+	Parallel bool
+}
+
+// JobDependencies returns the indexes of the nodes every node waits for.
+// A node with needs only waits for the nodes of the needed jobs, needs that can not be found are ignored when
+// ignoreMissing is true, e.g. the needed job was skipped. A job can only need the jobs of its own stage or of
+// the previous stages, the stages are still run in order. A node without needs keeps the stage behaviour:
+// it waits for all the nodes of the previous stages, and for the previous node if its stage is not parallel.
+func JobDependencies(nodes []*JobNode, ignoreMissing bool) ([][]int, error) {
+	nameIndexes := make(map[string][]int)
+	for i, node := range nodes {
+		nameIndexes[node.Name] = append(nameIndexes[node.Name], i)
+	}
+
+	deps := make([][]int, len(nodes))
+	for i, node := range nodes {
+		if len(node.Needs) > 0 {
+			for _, need := range node.Needs {
+				indexes, ok := nameIndexes[need]
+				if !ok {
+					if ignoreMissing {
+						continue
+					}
+					return nil, fmt.Errorf("job %s needs job %s which is not found", node.Name, need)
+				}
+				if need == node.Name {
+					return nil, fmt.Errorf("job %s can not need itself", node.Name)
+				}
+				if nodes[indexes[0]].Stage > node.Stage {
+					return nil, fmt.Errorf("job %s needs job %s of a later stage", node.Name, need)
+				}
+				deps[i] = append(deps[i], indexes...)
+			}
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if nodes[j].Stage < node.Stage {
+				deps[i] = append(deps[i], j)
+			}
+		}
+		if !node.Parallel {
+			for j := i - 1; j >= 0 && nodes[j].Stage == node.Stage; j-- {
+				deps[i] = append(deps[i], j)
+				break
+			}
+		}
+	}
+	return deps, nil
+}
+
+// CheckJobCycle returns an error describing the first dependency cycle found in the graph.
+func CheckJobCycle(nodes []*JobNode, deps [][]int) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(nodes))
+	path := make([]string, 0)
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("job dependency cycle found: %s -> %s", strings.Join(path, " -> "), nodes[i].Name)
+		}
+		state[i] = visiting
+		path = append(path, nodes[i].Name)
+		for _, dep := range deps[i] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		return nil
+	}
+
+	for i := range nodes {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workflowHasJobNeeds returns true if any job declares needs, only these workflows are scheduled as a DAG.
+func workflowHasJobNeeds(stages []*commonmodels.StageTask) bool {
+	for _, stage := range stages {
+		for _, job := range stage.Jobs {
+			if len(job.Needs) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type dagStage struct {
+	stage   *commonmodels.StageTask
+	once    sync.Once
+	stopped bool
+	// remaining is the number of jobs of the stage not finished yet
+	remaining int32
+}
+
+type dagJob struct {
+	job   *commonmodels.JobTask
+	stage *dagStage
+	deps  []*dagJob
+	done  chan struct{}
+	// blocked is true if the job was skipped because a job it depends on did not pass
+	blocked bool
+}
+
+// RunDAG runs the jobs of all the stages as soon as the jobs they depend on have passed, at most concurrency
+// jobs run at the same time. No more jobs are started after a job failed, like RunStages does.
+func RunDAG(ctx context.Context, stages []*commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, logger *zap.SugaredLogger, ack func()) {
+	nodes := make([]*JobNode, 0)
+	jobs := make([]*dagJob, 0)
+	for i, stage := range stages {
+		s := &dagStage{stage: stage, remaining: int32(len(stage.Jobs))}
+		for _, job := range stage.Jobs {
+			name := job.OriginName
+			if name == "" {
+				name = job.Name
+			}
+			nodes = append(nodes, &JobNode{Name: name, Needs: job.Needs, Stage: i, Parallel: stage.Parallel})
+			jobs = append(jobs, &dagJob{job: job, stage: s, done: make(chan struct{})})
+		}
+	}
+
+	deps, err := JobDependencies(nodes, true)
+	if err == nil {
+		err = CheckJobCycle(nodes, deps)
+	}
+	if err != nil {
+		logger.Errorf("workflow %s job dependency error: %v", workflowCtx.WorkflowName, err)
+		for _, stage := range stages {
+			if stage.Status != config.StatusPassed {
+				stage.Status = config.StatusFailed
+				stage.Error = err.Error()
+				break
+			}
+		}
+		ack()
+		return
+	}
+	for i, job := range jobs {
+		for _, dep := range deps[i] {
+			job.deps = append(job.deps, jobs[dep])
+		}
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var failed int32
+	var wg sync.WaitGroup
+	wg.Add(len(jobs))
+	for _, job := range jobs {
+		go func(j *dagJob) {
+			defer wg.Done()
+			defer close(j.done)
+			defer j.stage.finishJob(ctx, workflowCtx, logger, ack)
+
+			// should skip passed job when workflow task be restarted
+			if j.job.Status == config.StatusPassed {
+				return
+			}
+			// a resumed task skips all the finished jobs and carries on with the failures recorded before aslan restart
+			if workflowCtx.Resumed && statusCompleted(j.job.Status) {
+				j.blocked = j.job.SkipReason == jobcontroller.FailureSkipReason
+				if statusFailed(j.job.Status) {
+					atomic.StoreInt32(&failed, 1)
+				}
+				return
+			}
+			depsPassed := true
+			for _, dep := range j.deps {
+				select {
+				case <-dep.done:
+				case <-ctx.Done():
+					return
+				}
+				if dep.blocked || (dep.job.Status != config.StatusPassed && dep.job.Status != config.StatusSkipped) {
+					depsPassed = false
+				}
+			}
+			// like RunStages, only the jobs with a when expression are checked after a failure
+			if (!depsPassed || atomic.LoadInt32(&failed) == 1) && j.job.When == "" && j.stage.stage.When == "" {
+				j.skip(ack)
+				return
+			}
+			if !j.stage.start(ctx, workflowCtx, logger, ack) {
+				if statusFailed(j.stage.stage.Status) {
+					atomic.StoreInt32(&failed, 1)
+				}
+				return
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			jobcontroller.RunJob(ctx, j.job, workflowCtx, logger, ack)
+			<-sem
+			if statusFailed(j.job.Status) {
+				atomic.StoreInt32(&failed, 1)
+			}
+		}(job)
+	}
+	wg.Wait()
+	ack()
+}
+
+// skip marks the job skipped because a job it depends on, or a previous job, did not pass.
+func (j *dagJob) skip(ack func()) {
+	j.blocked = true
+	j.job.Status = config.StatusSkipped
+	j.job.SkipReason = jobcontroller.FailureSkipReason
+	j.job.StartTime = time.Now().Unix()
+	j.job.EndTime = j.job.StartTime
+	ack()
+}
+
+// start checks the condition and runs the approval of the stage when its first job is ready,
+// it returns false if the stage was skipped or not approved.
+func (s *dagStage) start(ctx context.Context, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) bool {
+	s.once.Do(func() {
+		if !checkStageCondition(s.stage, workflowCtx, logger, ack) {
+			s.stopped = true
+			return
+		}
+		s.stage.Status = config.StatusRunning
+		if !workflowCtx.Resumed || s.stage.StartTime == 0 {
+			s.stage.StartTime = time.Now().Unix()
+		}
+		ack()
+		logger.Infof("start stage: %s,status: %s", s.stage.Name, s.stage.Status)
+		if err := waitForApprove(ctx, s.stage, workflowCtx, logger, ack); err != nil {
+			s.stage.Error = err.Error()
+			s.stage.EndTime = time.Now().Unix()
+			logger.Errorf("finish stage: %s,status: %s error: %s", s.stage.Name, s.stage.Status, s.stage.Error)
+			s.stopped = true
+			ack()
+		}
+	})
+	return !s.stopped
+}
+
+// finishJob updates the stage when all of its jobs are done, the stage outputs are available to later jobs then.
+func (s *dagStage) finishJob(ctx context.Context, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) {
+	if atomic.AddInt32(&s.remaining, -1) > 0 {
+		return
+	}
+	switch s.stage.Status {
+	case config.StatusRunning:
+	case "":
+		// none of the jobs started the stage, e.g. they were all skipped because a job they depend on failed,
+		// the stage takes the status of its jobs
+		updateStageStatus(ctx, s.stage)
+		if s.stage.Status == config.StatusSkipped {
+			s.stage.SkipReason = jobcontroller.FailureSkipReason
+		}
+		s.stage.StartTime = time.Now().Unix()
+		s.stage.EndTime = s.stage.StartTime
+		logger.Infof("finish stage: %s,status: %s", s.stage.Name, s.stage.Status)
+		ack()
+		return
+	default:
+		return
+	}
+	updateStageStatus(ctx, s.stage)
+	NewCustomStageCtl(s.stage, workflowCtx, nil, logger, ack).AfterRun()
+	s.stage.EndTime = time.Now().Unix()
+	logger.Infof("finish stage: %s,status: %s", s.stage.Name, s.stage.Status)
+	ack()
+}
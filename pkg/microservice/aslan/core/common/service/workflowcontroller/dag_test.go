@@ -0,0 +1,164 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller/jobcontroller"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+func TestJobDependencies(t *testing.T) {
+	tests := []struct {
+		name          string
+		nodes         []*JobNode
+		ignoreMissing bool
+		want          [][]int
+		wantErr       string
+	}{
+		{
+			name: "serial stage waits for the previous job",
+			nodes: []*JobNode{
+				{Name: "a", Stage: 0},
+				{Name: "b", Stage: 0},
+			},
+			want: [][]int{nil, {0}},
+		},
+		{
+			name: "parallel stage waits for the previous stages only",
+			nodes: []*JobNode{
+				{Name: "a", Stage: 0},
+				{Name: "b", Stage: 1, Parallel: true},
+				{Name: "c", Stage: 1, Parallel: true},
+			},
+			want: [][]int{nil, {0}, {0}},
+		},
+		{
+			name: "needs replace the stage order",
+			nodes: []*JobNode{
+				{Name: "a", Stage: 0, Parallel: true},
+				{Name: "b", Stage: 0, Parallel: true},
+				{Name: "c", Stage: 1, Needs: []string{"a"}},
+			},
+			want: [][]int{nil, nil, {0}},
+		},
+		{
+			name: "needs of a split job wait for all of its tasks",
+			nodes: []*JobNode{
+				{Name: "a", Stage: 0, Parallel: true},
+				{Name: "a", Stage: 0, Parallel: true},
+				{Name: "b", Stage: 1, Needs: []string{"a"}},
+			},
+			want: [][]int{nil, nil, {0, 1}},
+		},
+		{
+			name: "missing needs are rejected",
+			nodes: []*JobNode{
+				{Name: "a", Stage: 0, Needs: []string{"x"}},
+			},
+			wantErr: "job a needs job x which is not found",
+		},
+		{
+			name: "missing needs are ignored",
+			nodes: []*JobNode{
+				{Name: "a", Stage: 0, Needs: []string{"x"}},
+			},
+			ignoreMissing: true,
+			want:          [][]int{nil},
+		},
+		{
+			name: "job can not need itself",
+			nodes: []*JobNode{
+				{Name: "a", Stage: 0, Needs: []string{"a"}},
+			},
+			wantErr: "job a can not need itself",
+		},
+		{
+			name: "job can not need a job of a later stage",
+			nodes: []*JobNode{
+				{Name: "a", Stage: 0, Needs: []string{"b"}},
+				{Name: "b", Stage: 1},
+			},
+			wantErr: "job a needs job b of a later stage",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps, err := JobDependencies(tt.nodes, tt.ignoreMissing)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, deps)
+		})
+	}
+}
+
+func TestCheckJobCycle(t *testing.T) {
+	nodes := []*JobNode{
+		{Name: "a", Stage: 0, Parallel: true, Needs: []string{"c"}},
+		{Name: "b", Stage: 0, Parallel: true, Needs: []string{"a"}},
+		{Name: "c", Stage: 0, Parallel: true, Needs: []string{"b"}},
+	}
+	deps, err := JobDependencies(nodes, false)
+	assert.NoError(t, err)
+	assert.EqualError(t, CheckJobCycle(nodes, deps), "job dependency cycle found: a -> c -> b -> a")
+
+	nodes = []*JobNode{
+		{Name: "a", Stage: 0, Parallel: true},
+		{Name: "b", Stage: 0, Parallel: true, Needs: []string{"a"}},
+		{Name: "c", Stage: 1, Needs: []string{"a", "b"}},
+	}
+	deps, err = JobDependencies(nodes, false)
+	assert.NoError(t, err)
+	assert.NoError(t, CheckJobCycle(nodes, deps))
+}
+
+func TestRunDAGSkippedStage(t *testing.T) {
+	build := &commonmodels.StageTask{
+		Name:   "build",
+		Status: config.StatusFailed,
+		Jobs:   []*commonmodels.JobTask{{Name: "build", Status: config.StatusFailed}},
+	}
+	deploy := &commonmodels.StageTask{
+		Name: "deploy",
+		Jobs: []*commonmodels.JobTask{
+			{Name: "deploy-a", Needs: []string{"build"}},
+			{Name: "deploy-b", Needs: []string{"deploy-a"}},
+		},
+	}
+	// the failed job is not run again by the resumed task, the jobs depending on it are skipped
+	workflowCtx := &commonmodels.WorkflowTaskCtx{WorkflowName: "workflow", Resumed: true}
+
+	RunDAG(context.Background(), []*commonmodels.StageTask{build, deploy}, workflowCtx, 2, log.NopSugaredLogger(), func() {})
+
+	assert.Equal(t, config.StatusFailed, build.Status)
+	for _, job := range deploy.Jobs {
+		assert.Equal(t, config.StatusSkipped, job.Status, job.Name)
+	}
+	// the stage never started, it still gets the status of its jobs
+	assert.Equal(t, config.StatusSkipped, deploy.Status)
+	assert.Equal(t, jobcontroller.FailureSkipReason, deploy.SkipReason)
+	assert.NotZero(t, deploy.EndTime)
+}
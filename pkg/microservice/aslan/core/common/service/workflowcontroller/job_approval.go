@@ -0,0 +1,161 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/msg_queue"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	approvalservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/approval"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller/jobcontroller"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+func ApproveJob(workflowName, jobName, userName, userID, comment string, taskID int64, approve bool) error {
+	approveKey := jobcontroller.JobApproveKey(workflowName, taskID, jobName)
+	approveWithL, ok := approvalservice.GlobalApproveMap.GetApproval(approveKey)
+	if !ok {
+		// the task may be run by another aslan replica
+		return forwardJobApproval(&approveMessage{
+			WorkflowName: workflowName,
+			TaskID:       taskID,
+			JobName:      jobName,
+			UserName:     userName,
+			UserID:       userID,
+			Comment:      comment,
+			Approve:      approve,
+		})
+	}
+	return approveWithL.DoApproval(userName, userID, comment, approve)
+}
+
+// SubmitManualInput submits the inputs of a waiting manual input job, the inputs are written into the global context
+// of the task as the outputs of the job.
+func SubmitManualInput(workflowName, jobName, userName, userID, comment string, taskID int64, inputs map[string]string) error {
+	if inputs == nil {
+		inputs = make(map[string]string)
+	}
+	inputKey := jobcontroller.JobApproveKey(workflowName, taskID, jobName)
+	inputWithL, ok := approvalservice.GlobalManualInputMap.GetManualInput(inputKey)
+	if !ok {
+		// the task may be run by another aslan replica
+		return forwardJobApproval(&approveMessage{
+			WorkflowName: workflowName,
+			TaskID:       taskID,
+			JobName:      jobName,
+			Inputs:       inputs,
+			UserName:     userName,
+			UserID:       userID,
+			Comment:      comment,
+		})
+	}
+	return inputWithL.DoInput(userName, userID, comment, inputs)
+}
+
+// forwardJobApproval validates the approval or inputs against the stored task and saves it for the replica running the task.
+func forwardJobApproval(m *approveMessage) error {
+	task, err := commonrepo.NewworkflowTaskv4Coll().Find(m.WorkflowName, m.TaskID)
+	if err != nil {
+		return fmt.Errorf("workflow %s ID %d not found: %v", m.WorkflowName, m.TaskID, err)
+	}
+	if err := checkForwardedApproval(task, m); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return commonrepo.NewMsgQueueCommonColl().Create(&msg_queue.MsgQueueCommon{
+		Payload:   string(payload),
+		QueueType: setting.TopicApprove,
+	})
+}
+
+// checkForwardedApproval checks the approval or inputs against the job of the task loaded from db,
+// the checks run on the copy without changing the running task.
+func checkForwardedApproval(task *commonmodels.WorkflowTask, m *approveMessage) error {
+	var job *commonmodels.JobTask
+	for _, stage := range task.Stages {
+		for _, j := range stage.Jobs {
+			if j.Name == m.JobName {
+				job = j
+			}
+		}
+	}
+	if job == nil || job.Status != config.StatusWaitingApprove {
+		return fmt.Errorf("workflow %s ID %d job %s do not need approve", m.WorkflowName, m.TaskID, m.JobName)
+	}
+
+	switch job.JobType {
+	case string(config.JobApproval):
+		spec := &commonmodels.JobTaskApprovalSpec{}
+		if err := commonmodels.IToi(job.Spec, spec); err != nil {
+			return err
+		}
+		if m.Inputs != nil || spec.Approval == nil || spec.Approval.NativeApproval == nil {
+			return fmt.Errorf("workflow %s ID %d job %s do not need approve", m.WorkflowName, m.TaskID, m.JobName)
+		}
+		if err := (&approvalservice.ApproveWithLock{Approval: spec.Approval.NativeApproval}).DoApproval(m.UserName, m.UserID, m.Comment, m.Approve); err != nil {
+			return err
+		}
+	case string(config.JobManualInput):
+		spec := &commonmodels.JobTaskManualInputSpec{}
+		if err := commonmodels.IToi(job.Spec, spec); err != nil {
+			return err
+		}
+		if m.Inputs == nil {
+			return fmt.Errorf("workflow %s ID %d job %s do not need approve", m.WorkflowName, m.TaskID, m.JobName)
+		}
+		if err := (&approvalservice.ManualInputWithLock{Input: spec}).DoInput(m.UserName, m.UserID, m.Comment, m.Inputs); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("workflow %s ID %d job %s do not need approve", m.WorkflowName, m.TaskID, m.JobName)
+	}
+	return nil
+}
+
+// deliverJobApproval hands the forwarded approval or inputs to the job waiting on this replica.
+func deliverJobApproval(msg *msg_queue.MsgQueueCommon, m *approveMessage) {
+	key := jobcontroller.JobApproveKey(m.WorkflowName, m.TaskID, m.JobName)
+	var err error
+	if inputWithL, ok := approvalservice.GlobalManualInputMap.GetManualInput(key); ok && m.Inputs != nil {
+		err = inputWithL.DoInput(m.UserName, m.UserID, m.Comment, m.Inputs)
+	} else if approveWithL, ok := approvalservice.GlobalApproveMap.GetApproval(key); ok && m.Inputs == nil {
+		err = approveWithL.DoApproval(m.UserName, m.UserID, m.Comment, m.Approve)
+	} else {
+		// drop the message if nobody is waiting for it anymore, otherwise leave it to the owner
+		task, err := commonrepo.NewworkflowTaskv4Coll().Find(m.WorkflowName, m.TaskID)
+		if err == nil && task.Status == config.StatusWaitingApprove {
+			return
+		}
+		_ = commonrepo.NewMsgQueueCommonColl().Delete(msg.ID)
+		return
+	}
+	if err != nil {
+		log.Warnf("deliver approval of %s error: %v", key, err)
+	}
+	if err := commonrepo.NewMsgQueueCommonColl().Delete(msg.ID); err != nil {
+		log.Errorf("delete approve message error: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func TestCheckForwardedApproval(t *testing.T) {
+	newTask := func() *commonmodels.WorkflowTask {
+		return &commonmodels.WorkflowTask{
+			WorkflowName: "wf",
+			TaskID:       1,
+			Stages: []*commonmodels.StageTask{{
+				Name: "stage",
+				Jobs: []*commonmodels.JobTask{
+					{
+						Name:    "approve",
+						JobType: string(config.JobApproval),
+						Status:  config.StatusWaitingApprove,
+						Spec: &commonmodels.JobTaskApprovalSpec{Approval: &commonmodels.Approval{
+							Type: config.NativeApproval,
+							NativeApproval: &commonmodels.NativeApproval{
+								ApproveUsers: []*commonmodels.User{{UserID: "u1", UserName: "alice"}},
+							},
+						}},
+					},
+					{
+						Name:    "input",
+						JobType: string(config.JobManualInput),
+						Status:  config.StatusWaitingApprove,
+						Spec: &commonmodels.JobTaskManualInputSpec{
+							Users:  []*commonmodels.User{{UserID: "u1", UserName: "alice"}},
+							Fields: []*commonmodels.Param{{Name: "version", ParamsType: config.ParamTypeString}},
+						},
+					},
+					{
+						Name:    "build",
+						JobType: string(config.JobZadigBuild),
+						Status:  config.StatusRunning,
+					},
+				},
+			}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		message *approveMessage
+		wantErr bool
+	}{
+		{name: "approval", message: &approveMessage{JobName: "approve", UserID: "u1", Approve: true}},
+		{name: "approval by unauthorized user", message: &approveMessage{JobName: "approve", UserID: "u2", Approve: true}, wantErr: true},
+		{name: "inputs sent to approval job", message: &approveMessage{JobName: "approve", UserID: "u1", Inputs: map[string]string{}}, wantErr: true},
+		{name: "inputs", message: &approveMessage{JobName: "input", UserID: "u1", Inputs: map[string]string{"version": "v2"}}},
+		{name: "unknown inputs", message: &approveMessage{JobName: "input", UserID: "u1", Inputs: map[string]string{"other": "v2"}}, wantErr: true},
+		{name: "approval sent to input job", message: &approveMessage{JobName: "input", UserID: "u1", Approve: true}, wantErr: true},
+		{name: "job not waiting for approval", message: &approveMessage{JobName: "build", UserID: "u1", Approve: true}, wantErr: true},
+		{name: "job not found", message: &approveMessage{JobName: "missing", UserID: "u1", Approve: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := newTask()
+			err := checkForwardedApproval(task, tt.message)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// the task loaded from db is only used for the checks
+			approvalSpec := task.Stages[0].Jobs[0].Spec.(*commonmodels.JobTaskApprovalSpec)
+			assert.Empty(t, approvalSpec.Approval.NativeApproval.ApproveUsers[0].RejectOrApprove)
+			inputSpec := task.Stages[0].Jobs[1].Spec.(*commonmodels.JobTaskManualInputSpec)
+			assert.Nil(t, inputSpec.Submitter)
+			assert.Empty(t, inputSpec.Fields[0].Value)
+		})
+	}
+}
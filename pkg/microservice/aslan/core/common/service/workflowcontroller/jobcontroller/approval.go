@@ -0,0 +1,729 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	configbase "github.com/koderover/zadig/pkg/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	approvalservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/approval"
+	dingservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/dingtalk"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/instantmessage"
+	larkservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/lark"
+	"github.com/koderover/zadig/pkg/tool/dingtalk"
+	"github.com/koderover/zadig/pkg/tool/lark"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/wecom"
+)
+
+// ApprovalTarget is the stage or the job waiting for an approval.
+type ApprovalTarget struct {
+	// Key is the key of the native approval in approvalservice.GlobalApproveMap
+	Key string
+	// Kind and Name are shown in the lark and dingtalk approval form
+	Kind string
+	Name string
+}
+
+func StageApproveKey(workflowName string, taskID int64, stageName string) string {
+	return fmt.Sprintf("%s-%d-%s", workflowName, taskID, stageName)
+}
+
+// JobApproveKey is different from StageApproveKey since a job may have the same name as a stage.
+func JobApproveKey(workflowName string, taskID int64, jobName string) string {
+	return fmt.Sprintf("%s-%d-job-%s", workflowName, taskID, jobName)
+}
+
+// WaitForApproval blocks until the approval is passed, the returned status should be set to the stage or job
+// if the approval is not passed.
+func WaitForApproval(ctx context.Context, spec *commonmodels.Approval, target *ApprovalTarget, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) (config.Status, error) {
+	switch spec.Type {
+	case config.NativeApproval:
+		return waitForNativeApprove(ctx, spec, target, workflowCtx, logger, ack)
+	case config.LarkApproval:
+		return waitForLarkApprove(ctx, spec, target, workflowCtx, logger, ack)
+	case config.DingTalkApproval:
+		return waitForDingTalkApprove(ctx, spec, target, workflowCtx, logger, ack)
+	case config.WeComApproval:
+		return waitForWeComApprove(ctx, spec, target, workflowCtx, logger, ack)
+	case config.WebhookApproval:
+		return waitForWebhookApprove(ctx, spec, target, workflowCtx, logger, ack)
+	default:
+		return config.StatusFailed, errors.New("invalid approval type")
+	}
+}
+
+func waitForNativeApprove(ctx context.Context, spec *commonmodels.Approval, target *ApprovalTarget, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) (config.Status, error) {
+	approval := spec.NativeApproval
+	if approval == nil {
+		return config.StatusFailed, errors.New("waitForApprove: native approval data not found")
+	}
+
+	if approval.Timeout == 0 {
+		approval.Timeout = 60
+	}
+	// the approval restored from db keeps the decisions which were made before aslan restarted
+	approveWithL := &approvalservice.ApproveWithLock{Approval: approval}
+	approvalservice.GlobalApproveMap.SetApproval(target.Key, approveWithL)
+	defer func() {
+		approvalservice.GlobalApproveMap.DeleteApproval(target.Key)
+		ack()
+	}()
+	if !workflowCtx.Resumed {
+		if err := instantmessage.NewWeChatClient().SendWorkflowTaskAproveNotifications(workflowCtx.WorkflowName, workflowCtx.TaskID); err != nil {
+			logger.Errorf("send approve notification failed, error: %v", err)
+		}
+	}
+
+	timeoutDuration := time.Duration(approval.Timeout) * time.Minute
+	if workflowCtx.Resumed {
+		timeoutDuration -= time.Since(time.Unix(spec.StartTime, 0))
+	}
+	timeout := time.After(timeoutDuration)
+	latestApproveCount := 0
+	for {
+		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			return config.StatusCancelled, fmt.Errorf("workflow was canceled")
+
+		case <-timeout:
+			return config.StatusTimeout, fmt.Errorf("workflow timeout")
+		default:
+			approved, approveCount, err := approveWithL.IsApproval()
+			if err != nil {
+				return config.StatusReject, err
+			}
+			if approved {
+				return config.StatusPassed, nil
+			}
+			if approveCount > latestApproveCount {
+				ack()
+				latestApproveCount = approveCount
+			}
+		}
+	}
+}
+
+func waitForLarkApprove(ctx context.Context, spec *commonmodels.Approval, target *ApprovalTarget, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) (config.Status, error) {
+	log.Infof("waitForLarkApprove start")
+	approval := spec.LarkApproval
+	if approval == nil {
+		return config.StatusFailed, errors.New("waitForApprove: lark approval data not found")
+	}
+	if approval.Timeout == 0 {
+		approval.Timeout = 60
+	}
+
+	data, err := mongodb.NewIMAppColl().GetByID(context.Background(), approval.ID)
+	if err != nil {
+		return config.StatusFailed, errors.Wrap(err, "get lark im app data")
+	}
+	approvalCode := data.LarkApprovalCodeList[approval.GetNodeTypeKey()]
+	if approvalCode == "" {
+		log.Errorf("failed to find approval code for node type %s", approval.GetNodeTypeKey())
+		return config.StatusFailed, errors.Errorf("failed to find approval code for node type %s", approval.GetNodeTypeKey())
+	}
+
+	client := lark.NewClient(data.AppID, data.AppSecret)
+
+	detailURL := fmt.Sprintf("%s/v1/projects/detail/%s/pipelines/custom/%s/%d?display_name=%s",
+		configbase.SystemAddress(),
+		workflowCtx.ProjectName,
+		workflowCtx.WorkflowName,
+		workflowCtx.TaskID,
+		url.QueryEscape(workflowCtx.WorkflowDisplayName),
+	)
+	descForm := ""
+	if spec.Description != "" {
+		descForm = fmt.Sprintf("\n描述: %s", spec.Description)
+	}
+	formContent := fmt.Sprintf("项目名称: %s\n工作流名称: %s\n%s: %s%s\n\n更多详见: %s",
+		workflowCtx.ProjectName, workflowCtx.WorkflowDisplayName, target.Kind, target.Name, descForm, detailURL)
+
+	var userID string
+	if approval.DefaultApprovalInitiator == nil {
+		userID, err = client.GetUserOpenIDByEmailOrMobile(lark.QueryTypeMobile, workflowCtx.WorkflowTaskCreatorMobile)
+		if err != nil {
+			return config.StatusFailed, errors.Wrapf(err, "get user lark id by mobile-%s", workflowCtx.WorkflowTaskCreatorMobile)
+		}
+	} else {
+		userID = approval.DefaultApprovalInitiator.ID
+		formContent = fmt.Sprintf("审批发起人: %s\n%s", workflowCtx.WorkflowTaskCreatorUsername, formContent)
+	}
+	log.Infof("waitForLarkApprove: ApproveNodes num %d", len(approval.ApprovalNodes))
+	instance, err := client.CreateApprovalInstance(&lark.CreateApprovalInstanceArgs{
+		ApprovalCode: approvalCode,
+		UserOpenID:   userID,
+		Nodes:        approval.GetLarkApprovalNode(),
+		FormContent:  formContent,
+	})
+	if err != nil {
+		log.Errorf("waitForLarkApprove: create instance failed: %v", err)
+		return config.StatusFailed, errors.Wrap(err, "create approval instance")
+	}
+	log.Infof("waitForLarkApprove: create instance success, id %s", instance)
+
+	if err := instantmessage.NewWeChatClient().SendWorkflowTaskAproveNotifications(workflowCtx.WorkflowName, workflowCtx.TaskID); err != nil {
+		logger.Errorf("send approve notification failed, error: %v", err)
+	}
+
+	cancelApproval := func() {
+		err := client.CancelApprovalInstance(&lark.CancelApprovalInstanceArgs{
+			ApprovalID: approvalCode,
+			InstanceID: instance,
+			UserID:     userID,
+		})
+		if err != nil {
+			log.Errorf("cancel approval %s error: %v", instance, err)
+		}
+	}
+
+	checkNodeStatus := func(node *commonmodels.LarkApprovalNode) (config.ApproveOrReject, error) {
+		switch node.Type {
+		case "AND":
+			result := config.Approve
+			for _, user := range node.ApproveUsers {
+				if user.RejectOrApprove == "" {
+					result = ""
+				}
+				if user.RejectOrApprove == config.Reject {
+					return config.Reject, nil
+				}
+			}
+			return result, nil
+		case "OR":
+			for _, user := range node.ApproveUsers {
+				if user.RejectOrApprove != "" {
+					return user.RejectOrApprove, nil
+				}
+			}
+			return "", nil
+		default:
+			return "", errors.Errorf("unknown node type %s", node.Type)
+		}
+	}
+
+	// approvalUpdate is used to update the approval status
+	approvalUpdate := func(larkApproval *commonmodels.LarkApproval) (done, isApprove bool, err error) {
+		// userUpdated represents whether the user status has been updated
+		userUpdated := false
+		for i, node := range larkApproval.ApprovalNodes {
+			if node.RejectOrApprove != "" {
+				continue
+			}
+			resultMap := larkservice.GetLarkApprovalInstanceManager(instance).GetNodeUserApprovalResults(lark.ApprovalNodeIDKey(i))
+			for _, user := range node.ApproveUsers {
+				if result, ok := resultMap[user.ID]; ok && user.RejectOrApprove == "" {
+					instanceData, err := client.GetApprovalInstance(&lark.GetApprovalInstanceArgs{InstanceID: instance})
+					if err != nil {
+						return false, false, errors.Wrap(err, "get approval instance")
+					}
+
+					comment := ""
+					// nodeKeyMap is used to get the node key from the custom node key
+					nodeKeyMap := larkservice.GetLarkApprovalInstanceManager(instance).GetNodeKeyMap()
+					if nodeData, ok := instanceData.ApproverInfoWithNode[nodeKeyMap[lark.ApprovalNodeIDKey(i)]]; ok {
+						if userData, ok := nodeData[user.ID]; ok {
+							comment = userData.Comment
+						}
+					}
+					user.Comment = comment
+					user.RejectOrApprove = result.ApproveOrReject
+					user.OperationTime = result.OperationTime
+					userUpdated = true
+				}
+			}
+			node.RejectOrApprove, err = checkNodeStatus(node)
+			if err != nil {
+				return false, false, err
+			}
+			if node.RejectOrApprove == config.Approve {
+				ack()
+				break
+			}
+			if node.RejectOrApprove == config.Reject {
+				return true, false, nil
+			}
+			if userUpdated {
+				ack()
+				break
+			}
+		}
+
+		finalResult := larkApproval.ApprovalNodes[len(larkApproval.ApprovalNodes)-1].RejectOrApprove
+		return finalResult != "", finalResult == config.Approve, nil
+	}
+
+	defer func() {
+		larkservice.RemoveLarkApprovalInstanceManager(instance)
+	}()
+	timeout := time.After(time.Duration(approval.Timeout) * time.Minute)
+	for {
+		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			cancelApproval()
+			return config.StatusCancelled, fmt.Errorf("workflow was canceled")
+		case <-timeout:
+			cancelApproval()
+			return config.StatusCancelled, fmt.Errorf("workflow timeout")
+		default:
+			done, isApprove, err := approvalUpdate(approval)
+			if err != nil {
+				cancelApproval()
+				return config.StatusFailed, errors.Wrap(err, "check approval status")
+			}
+			if done {
+				finalInstance, err := client.GetApprovalInstance(&lark.GetApprovalInstanceArgs{InstanceID: instance})
+				if err != nil {
+					return config.StatusFailed, errors.Wrap(err, "get approval final instance")
+				}
+				if finalInstance.ApproveOrReject == config.Approve && isApprove {
+					return config.StatusPassed, nil
+				}
+				if finalInstance.ApproveOrReject == config.Reject && !isApprove {
+					return config.StatusReject, errors.New("Approval has been rejected")
+				}
+				return config.StatusFailed, errors.New("check final approval status failed")
+			}
+		}
+	}
+}
+
+func waitForDingTalkApprove(ctx context.Context, spec *commonmodels.Approval, target *ApprovalTarget, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) (config.Status, error) {
+	log.Infof("waitForDingTalkApprove start")
+	approval := spec.DingTalkApproval
+	if approval == nil {
+		return config.StatusFailed, errors.New("waitForApprove: dingtalk approval data not found")
+	}
+	if approval.Timeout == 0 {
+		approval.Timeout = 60
+	}
+
+	data, err := mongodb.NewIMAppColl().GetByID(context.Background(), approval.ID)
+	if err != nil {
+		return config.StatusFailed, errors.Wrap(err, "get dingtalk im data")
+	}
+
+	client := dingtalk.NewClient(data.DingTalkAppKey, data.DingTalkAppSecret)
+
+	detailURL := fmt.Sprintf("%s/v1/projects/detail/%s/pipelines/custom/%s/%d?display_name=%s",
+		configbase.SystemAddress(),
+		workflowCtx.ProjectName,
+		workflowCtx.WorkflowName,
+		workflowCtx.TaskID,
+		url.QueryEscape(workflowCtx.WorkflowDisplayName),
+	)
+	descForm := ""
+	if spec.Description != "" {
+		descForm = fmt.Sprintf("\n描述: %s", spec.Description)
+	}
+	formContent := fmt.Sprintf("项目名称: %s\n工作流名称: %s\n%s: %s%s\n\n更多详见: %s",
+		workflowCtx.ProjectName, workflowCtx.WorkflowDisplayName, target.Kind, target.Name, descForm, detailURL)
+
+	var userID string
+	if approval.DefaultApprovalInitiator == nil {
+		userIDResp, err := client.GetUserIDByMobile(workflowCtx.WorkflowTaskCreatorMobile)
+		if err != nil {
+			return config.StatusFailed, errors.Wrapf(err, "get user dingtalk id by mobile-%s", workflowCtx.WorkflowTaskCreatorMobile)
+		}
+		userID = userIDResp.UserID
+	} else {
+		userID = approval.DefaultApprovalInitiator.ID
+		formContent = fmt.Sprintf("审批发起人: %s\n%s", workflowCtx.WorkflowTaskCreatorUsername, formContent)
+	}
+
+	log.Infof("waitForDingTalkApprove: ApproveNode num %d", len(approval.ApprovalNodes))
+	instanceResp, err := client.CreateApprovalInstance(&dingtalk.CreateApprovalInstanceArgs{
+		ProcessCode:      data.DingTalkDefaultApprovalFormCode,
+		OriginatorUserID: userID,
+		ApproverNodeList: func() (nodeList []*dingtalk.ApprovalNode) {
+			for _, node := range approval.ApprovalNodes {
+				var userIDList []string
+				for _, user := range node.ApproveUsers {
+					userIDList = append(userIDList, user.ID)
+				}
+				nodeList = append(nodeList, &dingtalk.ApprovalNode{
+					UserIDs:    userIDList,
+					ActionType: node.Type,
+				})
+			}
+			return
+		}(),
+		FormContent: formContent,
+	})
+	if err != nil {
+		log.Errorf("waitForDingTalkApprove: create instance failed: %v", err)
+		return config.StatusFailed, errors.Wrap(err, "create approval instance")
+	}
+	instanceID := instanceResp.InstanceID
+	log.Infof("waitForDingTalkApprove: create instance success, id %s", instanceID)
+
+	if err := instantmessage.NewWeChatClient().SendWorkflowTaskAproveNotifications(workflowCtx.WorkflowName, workflowCtx.TaskID); err != nil {
+		logger.Errorf("send approve notification failed, error: %v", err)
+	}
+	defer func() {
+		dingservice.RemoveDingTalkApprovalManager(instanceID)
+	}()
+
+	resultMap := map[string]config.ApproveOrReject{
+		"agree":  config.Approve,
+		"refuse": config.Reject,
+	}
+
+	checkNodeStatus := func(node *commonmodels.DingTalkApprovalNode) (config.ApproveOrReject, error) {
+		users := node.ApproveUsers
+		switch node.Type {
+		case "AND":
+			result := config.Approve
+			for _, user := range users {
+				if user.RejectOrApprove == "" {
+					result = ""
+				}
+				if user.RejectOrApprove == config.Reject {
+					return config.Reject, nil
+				}
+			}
+			return result, nil
+		case "OR":
+			for _, user := range users {
+				if user.RejectOrApprove != "" {
+					return user.RejectOrApprove, nil
+				}
+			}
+			return "", nil
+		default:
+			return "", errors.Errorf("unknown node type %s", node.Type)
+		}
+	}
+
+	timeout := time.After(time.Duration(approval.Timeout) * time.Minute)
+	for {
+		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			return config.StatusCancelled, fmt.Errorf("workflow was canceled")
+		case <-timeout:
+			return config.StatusCancelled, fmt.Errorf("workflow timeout")
+		default:
+			userApprovalResult := dingservice.GetDingTalkApprovalManager(instanceID).GetAllUserApprovalResults()
+			userUpdated := false
+			for _, node := range approval.ApprovalNodes {
+				if node.RejectOrApprove != "" {
+					continue
+				}
+				for _, user := range node.ApproveUsers {
+					if result := userApprovalResult[user.ID]; result != nil && user.RejectOrApprove == "" {
+						user.RejectOrApprove = resultMap[result.Result]
+						user.Comment = result.Remark
+						user.OperationTime = result.OperationTime
+						userUpdated = true
+					}
+				}
+				node.RejectOrApprove, err = checkNodeStatus(node)
+				if err != nil {
+					log.Errorf("check node failed: %v", err)
+					return config.StatusFailed, errors.Wrap(err, "check node")
+				}
+				switch node.RejectOrApprove {
+				case config.Approve:
+					ack()
+				case config.Reject:
+					return config.StatusReject, errors.New("Approval has been rejected")
+				default:
+					if userUpdated {
+						ack()
+					}
+				}
+				break
+			}
+			if approval.ApprovalNodes[len(approval.ApprovalNodes)-1].RejectOrApprove == config.Approve {
+				instanceInfo, err := client.GetApprovalInstance(instanceID)
+				if err != nil {
+					log.Errorf("get instance final info failed: %v", err)
+					return config.StatusFailed, errors.Wrap(err, "get instance final info")
+				}
+				if instanceInfo.Status == "COMPLETED" && instanceInfo.Result == "agree" {
+					return config.StatusPassed, nil
+				} else {
+					log.Errorf("Unexpect instance final status is %s, result is %s", instanceInfo.Status, instanceInfo.Result)
+					return config.StatusFailed, errors.Wrap(err, "get unexpected instance final info")
+				}
+			}
+		}
+	}
+}
+
+func approvalDetailURL(workflowCtx *commonmodels.WorkflowTaskCtx) string {
+	return fmt.Sprintf("%s/v1/projects/detail/%s/pipelines/custom/%s/%d?display_name=%s",
+		configbase.SystemAddress(),
+		workflowCtx.ProjectName,
+		workflowCtx.WorkflowName,
+		workflowCtx.TaskID,
+		url.QueryEscape(workflowCtx.WorkflowDisplayName),
+	)
+}
+
+func waitForWeComApprove(ctx context.Context, spec *commonmodels.Approval, target *ApprovalTarget, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) (config.Status, error) {
+	log.Infof("waitForWeComApprove start")
+	approval := spec.WeComApproval
+	if approval == nil {
+		return config.StatusFailed, errors.New("waitForApprove: wecom approval data not found")
+	}
+	if len(approval.ApprovalNodes) == 0 {
+		return config.StatusFailed, errors.New("waitForApprove: wecom approval nodes not found")
+	}
+	if approval.Timeout == 0 {
+		approval.Timeout = 60
+	}
+
+	data, err := mongodb.NewIMAppColl().GetByID(context.Background(), approval.ID)
+	if err != nil {
+		return config.StatusFailed, errors.Wrap(err, "get wecom im app data")
+	}
+	client := wecom.NewClient(data.WeComCorpID, data.WeComAgentSecret)
+
+	// the instance created before aslan restarted is still waited for
+	if approval.InstanceCode == "" {
+		descForm := ""
+		if spec.Description != "" {
+			descForm = fmt.Sprintf("\n描述: %s", spec.Description)
+		}
+		formContent := fmt.Sprintf("项目名称: %s\n工作流名称: %s\n%s: %s%s\n\n更多详见: %s",
+			workflowCtx.ProjectName, workflowCtx.WorkflowDisplayName, target.Kind, target.Name, descForm, approvalDetailURL(workflowCtx))
+
+		var userID string
+		if approval.DefaultApprovalInitiator == nil {
+			userIDResp, err := client.GetUserIDByMobile(workflowCtx.WorkflowTaskCreatorMobile)
+			if err != nil {
+				return config.StatusFailed, errors.Wrapf(err, "get user wecom id by mobile-%s", workflowCtx.WorkflowTaskCreatorMobile)
+			}
+			userID = userIDResp.UserID
+		} else {
+			userID = approval.DefaultApprovalInitiator.ID
+			formContent = fmt.Sprintf("审批发起人: %s\n%s", workflowCtx.WorkflowTaskCreatorUsername, formContent)
+		}
+
+		log.Infof("waitForWeComApprove: ApproveNode num %d", len(approval.ApprovalNodes))
+		instanceResp, err := client.CreateApprovalInstance(&wecom.CreateApprovalInstanceArgs{
+			TemplateID:    data.WeComTemplateID,
+			CreatorUserID: userID,
+			ApprovalNodes: func() (nodeList []*wecom.ApprovalNode) {
+				for _, node := range approval.ApprovalNodes {
+					attr := wecom.ApproverAttrOr
+					if node.Type == "AND" {
+						attr = wecom.ApproverAttrAnd
+					}
+					var userIDList []string
+					for _, user := range node.ApproveUsers {
+						userIDList = append(userIDList, user.ID)
+					}
+					nodeList = append(nodeList, &wecom.ApprovalNode{
+						Attr:    attr,
+						UserIDs: userIDList,
+					})
+				}
+				return
+			}(),
+			FormContent: formContent,
+			Summary:     fmt.Sprintf("%s: %s", workflowCtx.WorkflowDisplayName, target.Name),
+		})
+		if err != nil {
+			log.Errorf("waitForWeComApprove: create instance failed: %v", err)
+			return config.StatusFailed, errors.Wrap(err, "create approval instance")
+		}
+		approval.InstanceCode = instanceResp.SpNo
+		log.Infof("waitForWeComApprove: create instance success, id %s", approval.InstanceCode)
+		ack()
+
+		if err := instantmessage.NewWeChatClient().SendWorkflowTaskAproveNotifications(workflowCtx.WorkflowName, workflowCtx.TaskID); err != nil {
+			logger.Errorf("send approve notification failed, error: %v", err)
+		}
+	}
+
+	resultMap := map[int]config.ApproveOrReject{
+		wecom.ApprovalStatusApproved: config.Approve,
+		wecom.ApprovalStatusRejected: config.Reject,
+	}
+
+	// approvalUpdate copies the approver decisions of the instance to the approval nodes,
+	// the records of the instance are in the same order as the nodes.
+	approvalUpdate := func(info *wecom.ApprovalInstanceInfo) bool {
+		updated := false
+		for i, record := range info.SpRecord {
+			if i >= len(approval.ApprovalNodes) {
+				break
+			}
+			node := approval.ApprovalNodes[i]
+			for _, detail := range record.Details {
+				if detail.Approver == nil {
+					continue
+				}
+				for _, user := range node.ApproveUsers {
+					result := resultMap[detail.SpStatus]
+					if user.ID != detail.Approver.UserID || result == "" || user.RejectOrApprove != "" {
+						continue
+					}
+					user.RejectOrApprove = result
+					user.Comment = detail.Speech
+					user.OperationTime = detail.SpTime
+					updated = true
+				}
+			}
+			if result := resultMap[record.SpStatus]; result != "" && node.RejectOrApprove != result {
+				node.RejectOrApprove = result
+				updated = true
+			}
+		}
+		return updated
+	}
+
+	timeoutDuration := time.Duration(approval.Timeout) * time.Minute
+	if workflowCtx.Resumed {
+		timeoutDuration -= time.Since(time.Unix(spec.StartTime, 0))
+	}
+	timeout := time.After(timeoutDuration)
+	for {
+		time.Sleep(5 * time.Second)
+		select {
+		case <-ctx.Done():
+			return config.StatusCancelled, fmt.Errorf("workflow was canceled")
+		case <-timeout:
+			return config.StatusCancelled, fmt.Errorf("workflow timeout")
+		default:
+			info, err := client.GetApprovalInstance(approval.InstanceCode)
+			if err != nil {
+				// keep waiting since the wecom api may fail occasionally
+				log.Errorf("get wecom approval instance %s error: %v", approval.InstanceCode, err)
+				continue
+			}
+			if approvalUpdate(info) {
+				ack()
+			}
+			switch info.SpStatus {
+			case wecom.ApprovalStatusPending:
+			case wecom.ApprovalStatusApproved:
+				return config.StatusPassed, nil
+			case wecom.ApprovalStatusRejected:
+				return config.StatusReject, errors.New("Approval has been rejected")
+			default:
+				return config.StatusFailed, errors.Errorf("approval instance has been closed with status %d", info.SpStatus)
+			}
+		}
+	}
+}
+
+func waitForWebhookApprove(ctx context.Context, spec *commonmodels.Approval, target *ApprovalTarget, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) (config.Status, error) {
+	log.Infof("waitForWebhookApprove start")
+	approval := spec.WebhookApproval
+	if approval == nil {
+		return config.StatusFailed, errors.New("waitForApprove: webhook approval data not found")
+	}
+	if approval.Timeout == 0 {
+		approval.Timeout = 60
+	}
+
+	// the request sent before aslan restarted is still waited for
+	if approval.InstanceCode == "" {
+		approval.InstanceCode = uuid.New().String()
+		err := approvalservice.SendWebhookApproval(approval.ID, &approvalservice.WebhookApprovalRequest{
+			ApprovalID:          approval.InstanceCode,
+			ProjectName:         workflowCtx.ProjectName,
+			WorkflowName:        workflowCtx.WorkflowName,
+			WorkflowDisplayName: workflowCtx.WorkflowDisplayName,
+			TaskID:              workflowCtx.TaskID,
+			Target:              fmt.Sprintf("%s: %s", target.Kind, target.Name),
+			Description:         spec.Description,
+			Creator:             workflowCtx.WorkflowTaskCreatorUsername,
+			DetailURL:           approvalDetailURL(workflowCtx),
+			Timeout:             approval.Timeout,
+		})
+		if err != nil {
+			log.Errorf("waitForWebhookApprove: send approval request failed: %v", err)
+			return config.StatusFailed, errors.Wrap(err, "send approval request")
+		}
+		log.Infof("waitForWebhookApprove: send approval request success, id %s", approval.InstanceCode)
+		ack()
+
+		if err := instantmessage.NewWeChatClient().SendWorkflowTaskAproveNotifications(workflowCtx.WorkflowName, workflowCtx.TaskID); err != nil {
+			logger.Errorf("send approve notification failed, error: %v", err)
+		}
+	}
+	defer approvalservice.DeleteWebhookApprovalResult(approval.InstanceCode)
+
+	timeoutDuration := time.Duration(approval.Timeout) * time.Minute
+	if workflowCtx.Resumed {
+		timeoutDuration -= time.Since(time.Unix(spec.StartTime, 0))
+	}
+	timeout := time.After(timeoutDuration)
+	// the status url is polled less frequently than the callback results
+	pollTicker := time.NewTicker(30 * time.Second)
+	defer pollTicker.Stop()
+	latestApproverCount := len(approval.ApproveUsers)
+	for {
+		time.Sleep(1 * time.Second)
+		poll := false
+		select {
+		case <-ctx.Done():
+			// the approval is kept since ctx is also done when the task is taken over by another replica
+			return config.StatusCancelled, fmt.Errorf("workflow was canceled")
+		case <-timeout:
+			if err := approvalservice.CancelWebhookApproval(approval.ID, approval.InstanceCode, "workflow timeout"); err != nil {
+				log.Errorf("cancel webhook approval %s error: %v", approval.InstanceCode, err)
+			}
+			return config.StatusCancelled, fmt.Errorf("workflow timeout")
+		case <-pollTicker.C:
+			poll = true
+		default:
+		}
+
+		result, err := approvalservice.GetWebhookApprovalResult(approval.ID, approval.InstanceCode, poll)
+		if err != nil {
+			log.Errorf("get webhook approval result %s error: %v", approval.InstanceCode, err)
+			continue
+		}
+		if result == nil {
+			continue
+		}
+		if len(result.Approvers) != latestApproverCount {
+			approval.ApproveUsers = result.Approvers
+			latestApproverCount = len(result.Approvers)
+			ack()
+		}
+		switch result.Status {
+		case approvalservice.WebhookApprovalApproved:
+			approval.ApproveUsers = result.Approvers
+			approval.RejectOrApprove = config.Approve
+			return config.StatusPassed, nil
+		case approvalservice.WebhookApprovalRejected:
+			approval.ApproveUsers = result.Approvers
+			approval.RejectOrApprove = config.Reject
+			return config.StatusReject, errors.New("Approval has been rejected")
+		}
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -42,6 +43,49 @@ type JobCtl interface {
 	SaveInfo(ctx context.Context) error
 }
 
+// JobResumer is implemented by the job controllers whose executors run outside of aslan,
+// they can be re-attached when a running workflow task is resumed after aslan restarted.
+type JobResumer interface {
+	Resume(ctx context.Context) error
+}
+
+// jobResumeMode tells how an in-progress job is handled when its workflow task is resumed after aslan restarted.
+type jobResumeMode int
+
+const (
+	// jobResumeAttach re-attaches the executor which kept running outside of aslan.
+	jobResumeAttach jobResumeMode = iota
+	// jobResumeRerun runs the job again from the beginning.
+	jobResumeRerun
+	// jobResumeFail fails the job.
+	jobResumeFail
+)
+
+// rerunnableJobTypes are the jobs running inside aslan whose side effects converge when they are run twice,
+// e.g. applying the same images, values or configs again. Other jobs, such as workflow trigger, jira, meego,
+// jenkins, sql, offline service and the release jobs, can not tell how far the interrupted run went,
+// so they are failed on restart instead of being repeated.
+var rerunnableJobTypes = map[string]bool{
+	string(config.JobZadigDeploy):          true,
+	string(config.JobZadigHelmDeploy):      true,
+	string(config.JobZadigHelmChartDeploy): true,
+	string(config.JobCustomDeploy):         true,
+	string(config.JobNacos):                true,
+	string(config.JobApollo):               true,
+	string(config.JobGrafana):              true,
+	string(config.JobGuanceyunCheck):       true,
+}
+
+func getJobResumeMode(jobCtl JobCtl, jobType string) jobResumeMode {
+	if _, ok := jobCtl.(JobResumer); ok {
+		return jobResumeAttach
+	}
+	if rerunnableJobTypes[jobType] {
+		return jobResumeRerun
+	}
+	return jobResumeFail
+}
+
 func initJobCtl(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) JobCtl {
 	var jobCtl JobCtl
 	switch job.JobType {
@@ -97,6 +141,10 @@ func initJobCtl(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTas
 		jobCtl = NewJenkinsJobCtl(job, workflowCtx, ack, logger)
 	case string(config.JobSQL):
 		jobCtl = NewSQLJobCtl(job, workflowCtx, ack, logger)
+	case string(config.JobApproval):
+		jobCtl = NewApprovalJobCtl(job, workflowCtx, ack, logger)
+	case string(config.JobManualInput):
+		jobCtl = NewManualInputJobCtl(job, workflowCtx, ack, logger)
 	default:
 		jobCtl = NewFreestyleJobCtl(job, workflowCtx, ack, logger)
 	}
@@ -104,20 +152,53 @@ func initJobCtl(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTas
 }
 
 func runJob(ctx context.Context, job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) {
-	// should skip passed job when workflow task be restarted
-	if job.Status == config.StatusPassed {
+	// should skip passed job when workflow task be restarted, a resumed task skips all the finished jobs
+	if job.Status == config.StatusPassed || (workflowCtx.Resumed && jobStatusFinished(job.Status)) {
 		return
 	}
-	// render global variables for every job.
-	workflowCtx.GlobalContextEach(func(k, v string) bool {
-		b, _ := json.Marshal(job)
-		v = strings.Trim(v, "\n")
-		replacedString := strings.ReplaceAll(string(b), k, v)
-		if err := json.Unmarshal([]byte(replacedString), &job); err != nil {
-			logger.Errorf("unmarshal job error: %v", err)
+	if job.Matrix != nil {
+		runMatrixJob(ctx, job, workflowCtx, logger, ack, func(ctx context.Context) {
+			runJobWithRetry(ctx, job, workflowCtx, logger, ack)
+		})
+		return
+	}
+	runJobWithRetry(ctx, job, workflowCtx, logger, ack)
+}
+
+// runJobWithRetry runs the job and retries it according to its retry policy.
+func runJobWithRetry(ctx context.Context, job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) {
+	// job was started by the previous aslan process, try to re-attach it instead of running it again
+	if workflowCtx.Resumed && jobStatusInProgress(job.Status) {
+		resumeJob(ctx, job, workflowCtx, logger, ack)
+	} else {
+		if !checkJobCondition(job, workflowCtx, logger, ack) {
+			return
+		}
+		// render global variables for every job.
+		workflowCtx.GlobalContextEach(func(k, v string) bool {
+			b, _ := json.Marshal(job)
+			v = strings.Trim(v, "\n")
+			replacedString := strings.ReplaceAll(string(b), k, v)
+			if err := json.Unmarshal([]byte(replacedString), &job); err != nil {
+				logger.Errorf("unmarshal job error: %v", err)
+			}
+			return true
+		})
+		runJobAttempt(ctx, job, workflowCtx, logger, ack)
+	}
+
+	for shouldRetryJob(ctx, job) {
+		if !waitJobRetry(ctx, job, logger) {
+			return
 		}
-		return true
-	})
+		archiveJobAttempt(job, workflowCtx, logger)
+		ack()
+		runJobAttempt(ctx, job, workflowCtx, logger, ack)
+	}
+}
+
+// runJobAttempt runs the job once, every attempt has its own kubernetes job or vm job.
+func runJobAttempt(ctx context.Context, job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) {
 	job.Status = config.StatusPrepare
 	job.StartTime = time.Now().Unix()
 	job.K8sJobName = getJobName(workflowCtx.WorkflowName, workflowCtx.TaskID)
@@ -146,17 +227,132 @@ func runJob(ctx context.Context, job *commonmodels.JobTask, workflowCtx *commonm
 	jobCtl.Run(ctx)
 }
 
-func RunJobs(ctx context.Context, jobs []*commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, logger *zap.SugaredLogger, ack func()) {
+func resumeJob(ctx context.Context, job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) {
+	logger.Infof("resume job: %s,status: %s", job.Name, job.Status)
+	jobCtl := initJobCtl(job, workflowCtx, logger, ack)
+	if getJobResumeMode(jobCtl, job.JobType) == jobResumeRerun {
+		logger.Infof("job %s was interrupted by aslan restart, run it again", job.Name)
+		runJobAttempt(ctx, job, workflowCtx, logger, ack)
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			errMsg := fmt.Sprintf("job: %s panic: %v", job.Name, err)
+			logger.Errorf(errMsg)
+			debug.PrintStack()
+			job.Status = config.StatusFailed
+			job.Error = errMsg
+		}
+		job.EndTime = time.Now().Unix()
+		logger.Infof("finish resumed job: %s,status: %s", job.Name, job.Status)
+		ack()
+		if err := jobCtl.SaveInfo(ctx); err != nil {
+			logger.Errorf("update job info: %s into db error: %v", job.Name, err)
+		}
+	}()
+
+	if getJobResumeMode(jobCtl, job.JobType) == jobResumeFail {
+		logError(job, fmt.Sprintf("job %s was interrupted by aslan restart and can not be resumed, job type: %s", job.Name, job.JobType), logger)
+		return
+	}
+	if err := jobCtl.(JobResumer).Resume(ctx); err != nil {
+		logError(job, fmt.Sprintf("job executor lost after aslan restart: %v", err), logger)
+	}
+}
+
+// checkJobCondition skips the job if its when expression is false, it returns true if the job should run.
+func checkJobCondition(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) bool {
+	if job.When == "" || workflowCtx.EvaluateCondition == nil {
+		return true
+	}
+	run, err := workflowCtx.EvaluateCondition(job.When)
+	if err == nil && run {
+		return true
+	}
+	job.StartTime = time.Now().Unix()
+	job.EndTime = job.StartTime
+	if err != nil {
+		logError(job, err.Error(), logger)
+	} else {
+		logger.Infof("skip job: %s, condition %s is false", job.Name, job.When)
+		job.Status = config.StatusSkipped
+		job.SkipReason = ConditionSkipReason(job.When)
+	}
+	ack()
+	return false
+}
+
+// ConditionSkipReason is shown on the job or stage skipped by its when expression.
+func ConditionSkipReason(when string) string {
+	return fmt.Sprintf("skipped because condition [%s] is false", when)
+}
+
+// FailureSkipReason is shown on the job skipped because a previous job failed.
+const FailureSkipReason = "skipped because a previous job failed"
+
+// FailureTracker records whether a job failed among the stages run together. After a failure, the jobs without
+// a when expression are skipped and the others are checked against their condition, see Job.When.
+type FailureTracker struct {
+	failed int32
+	parent *FailureTracker
+}
+
+// Scope returns a tracker which ignores the failures recorded so far, it is used for the stages with a when
+// expression. Failures recorded on the returned tracker are recorded on t as well.
+func (t *FailureTracker) Scope() *FailureTracker {
+	return &FailureTracker{parent: t}
+}
+
+func (t *FailureTracker) Fail() {
+	for f := t; f != nil; f = f.parent {
+		atomic.StoreInt32(&f.failed, 1)
+	}
+}
+
+func (t *FailureTracker) Failed() bool {
+	return t != nil && atomic.LoadInt32(&t.failed) == 1
+}
+
+// skipAfterFailure skips the job if a previous job failed and its when expression does not say otherwise,
+// no job is started after the task was cancelled. It returns true if the job was skipped.
+func skipAfterFailure(ctx context.Context, job *commonmodels.JobTask, failure *FailureTracker, ack func()) bool {
+	if !failure.Failed() || (job.When != "" && ctx.Err() == nil) {
+		return false
+	}
+	if job.Status == "" {
+		job.Status = config.StatusSkipped
+		job.SkipReason = FailureSkipReason
+		job.StartTime = time.Now().Unix()
+		job.EndTime = job.StartTime
+		ack()
+	}
+	return true
+}
+
+// RunJob runs a single job, it is used when jobs are scheduled by their dependencies instead of stages.
+func RunJob(ctx context.Context, job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) {
+	runJob(ctx, job, workflowCtx, logger, ack)
+}
+
+// RunJobs runs the jobs of a stage, failure is shared by the stages run together.
+func RunJobs(ctx context.Context, jobs []*commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, failure *FailureTracker, logger *zap.SugaredLogger, ack func()) {
+	if failure == nil {
+		failure = &FailureTracker{}
+	}
 	if concurrency == 1 {
 		for _, job := range jobs {
+			// after a job failed, only the jobs with a when expression are checked, e.g. failure()
+			if skipAfterFailure(ctx, job, failure, ack) {
+				continue
+			}
 			runJob(ctx, job, workflowCtx, logger, ack)
 			if jobStatusFailed(job.Status) {
-				return
+				failure.Fail()
 			}
 		}
 		return
 	}
-	jobPool := NewPool(ctx, jobs, workflowCtx, concurrency, logger, ack)
+	jobPool := NewPool(ctx, jobs, workflowCtx, concurrency, failure, logger, ack)
 	jobPool.Run()
 }
 
@@ -180,12 +376,14 @@ type Pool struct {
 	ack         func()
 	ctx         context.Context
 	wg          sync.WaitGroup
+	failure     *FailureTracker
 }
 
 // NewPool initializes a new pool with the given tasks and
 // at the given concurrency.
-func NewPool(ctx context.Context, jobs []*commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, logger *zap.SugaredLogger, ack func()) *Pool {
+func NewPool(ctx context.Context, jobs []*commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, failure *FailureTracker, logger *zap.SugaredLogger, ack func()) *Pool {
 	return &Pool{
+		failure:     failure,
 		Jobs:        jobs,
 		concurrency: concurrency,
 		workflowCtx: workflowCtx,
@@ -217,7 +415,13 @@ func (p *Pool) Run() {
 // The work loop for any single goroutine.
 func (p *Pool) work() {
 	for job := range p.jobsChan {
-		runJob(p.ctx, job, p.workflowCtx, p.logger, p.ack)
+		// jobs waiting for a worker are skipped after a job failed, like the serial stages
+		if !skipAfterFailure(p.ctx, job, p.failure, p.ack) {
+			runJob(p.ctx, job, p.workflowCtx, p.logger, p.ack)
+			if jobStatusFailed(job.Status) {
+				p.failure.Fail()
+			}
+		}
 		p.wg.Done()
 	}
 }
@@ -256,6 +460,122 @@ func jobStatusFailed(status config.Status) bool {
 	return false
 }
 
+// jobStatusFinished returns true if the job has already finished, it is not run again when the task is resumed.
+func jobStatusFinished(status config.Status) bool {
+	switch status {
+	case config.StatusPassed, config.StatusFailed, config.StatusTimeout, config.StatusSkipped, config.StatusReject, config.StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// taskLost returns true if the task has been taken over by another aslan replica, the executors of its jobs
+// are re-attached by the new owner and must not be cleaned by this replica.
+func taskLost(workflowCtx *commonmodels.WorkflowTaskCtx) bool {
+	return workflowCtx != nil && workflowCtx.Lost != nil && workflowCtx.Lost()
+}
+
+func jobStatusInProgress(status config.Status) bool {
+	switch status {
+	case config.StatusCreated, config.StatusPrepare, config.StatusDistributed, config.StatusRunning, config.StatusDebugBefore, config.StatusDebugAfter, config.StatusWaitingApprove:
+		return true
+	}
+	return false
+}
+
+// jobRetryPolicy returns the retry policy of the job, a job created with only the retry count retries immediately.
+func jobRetryPolicy(job *commonmodels.JobTask) *commonmodels.JobRetryPolicy {
+	if job.RetryPolicy != nil {
+		return job.RetryPolicy
+	}
+	if job.Retry > 0 {
+		return &commonmodels.JobRetryPolicy{MaxAttempts: job.Retry + 1}
+	}
+	return nil
+}
+
+// shouldRetryJob only retries failed and timeout jobs, rejected and cancelled jobs are stopped by users.
+func shouldRetryJob(ctx context.Context, job *commonmodels.JobTask) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	policy := jobRetryPolicy(job)
+	if policy == nil || int64(len(job.Attempts))+1 >= policy.MaxAttempts {
+		return false
+	}
+	if job.Status != config.StatusFailed && job.Status != config.StatusTimeout {
+		return false
+	}
+	if len(policy.RetryOn) == 0 {
+		return true
+	}
+	for _, status := range policy.RetryOn {
+		if status == job.Status {
+			return true
+		}
+	}
+	return false
+}
+
+// retryInterval returns the time to wait before the next retry when the job has been retried for retried times,
+// it never exceeds MaxJobRetryInterval.
+func retryInterval(policy *commonmodels.JobRetryPolicy, retried int) time.Duration {
+	maxInterval := time.Duration(commonmodels.MaxJobRetryInterval) * time.Second
+	if policy.Interval <= 0 {
+		return 0
+	}
+	if policy.Interval >= commonmodels.MaxJobRetryInterval {
+		return maxInterval
+	}
+	interval := time.Duration(policy.Interval) * time.Second
+	if policy.Backoff <= 1 {
+		return interval
+	}
+	for i := 0; i < retried; i++ {
+		// compare in float64 before converting, the product may overflow time.Duration
+		next := float64(interval) * policy.Backoff
+		if next >= float64(maxInterval) {
+			return maxInterval
+		}
+		interval = time.Duration(next)
+	}
+	return interval
+}
+
+// waitJobRetry waits for the backoff interval, it returns false if the workflow was cancelled in the meantime.
+func waitJobRetry(ctx context.Context, job *commonmodels.JobTask, logger *zap.SugaredLogger) bool {
+	interval := retryInterval(jobRetryPolicy(job), len(job.Attempts))
+	logger.Infof("job: %s %s, retry attempt %d in %s", job.Name, job.Status, len(job.Attempts)+2, interval)
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(interval):
+		return true
+	}
+}
+
+// archiveJobAttempt saves the result of the finished attempt and resets the job for the next attempt.
+func archiveJobAttempt(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger) {
+	attempt := &commonmodels.JobTaskAttempt{
+		Attempt:    int64(len(job.Attempts)) + 1,
+		K8sJobName: job.K8sJobName,
+		Status:     job.Status,
+		Error:      job.Error,
+		StartTime:  job.StartTime,
+		EndTime:    job.EndTime,
+	}
+	logFile := commonmodels.JobAttemptLogName(job.Name, attempt.Attempt)
+	if err := copyContainerLog(workflowCtx.WorkflowName, job.Name, logFile, workflowCtx.TaskID); err != nil {
+		logger.Warnf("save log of job %s attempt %d error: %v", job.Name, attempt.Attempt, err)
+	} else {
+		attempt.LogFile = logFile
+	}
+	job.Attempts = append(job.Attempts, attempt)
+	job.Error = ""
+	job.Outputs = nil
+	job.EndTime = 0
+}
+
 func logError(job *commonmodels.JobTask, msg string, logger *zap.SugaredLogger) {
 	logger.Error(msg)
 	job.Status = config.StatusFailed
@@ -0,0 +1,102 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+)
+
+type ApprovalJobCtl struct {
+	job         *commonmodels.JobTask
+	workflowCtx *commonmodels.WorkflowTaskCtx
+	logger      *zap.SugaredLogger
+	jobTaskSpec *commonmodels.JobTaskApprovalSpec
+	ack         func()
+}
+
+func NewApprovalJobCtl(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, ack func(), logger *zap.SugaredLogger) *ApprovalJobCtl {
+	jobTaskSpec := &commonmodels.JobTaskApprovalSpec{}
+	if err := commonmodels.IToi(job.Spec, jobTaskSpec); err != nil {
+		logger.Error(err)
+	}
+	job.Spec = jobTaskSpec
+	return &ApprovalJobCtl{
+		job:         job,
+		workflowCtx: workflowCtx,
+		logger:      logger,
+		ack:         ack,
+		jobTaskSpec: jobTaskSpec,
+	}
+}
+
+func (c *ApprovalJobCtl) Clean(ctx context.Context) {}
+
+func (c *ApprovalJobCtl) Run(ctx context.Context) {
+	approval := c.jobTaskSpec.Approval
+	if approval == nil {
+		logError(c.job, "approval data not found", c.logger)
+		return
+	}
+	// keep the original start time of a resumed approval, so the timeout is not extended by aslan restart
+	if !c.workflowCtx.Resumed || approval.StartTime == 0 {
+		approval.StartTime = time.Now().Unix()
+	}
+	c.job.Status = config.StatusWaitingApprove
+	// workflowCtx.SetStatus contain ack() function, so we don't need to call ack() here
+	c.workflowCtx.SetStatus(config.StatusWaitingApprove)
+	defer c.workflowCtx.SetStatus(config.StatusRunning)
+
+	target := &ApprovalTarget{
+		Key:  JobApproveKey(c.workflowCtx.WorkflowName, c.workflowCtx.TaskID, c.job.Name),
+		Kind: "任务名称",
+		Name: c.job.Name,
+	}
+	status, err := WaitForApproval(ctx, approval, target, c.workflowCtx, c.logger, c.ack)
+	approval.EndTime = time.Now().Unix()
+	approval.Status = status
+	c.job.Status = status
+	if err != nil {
+		c.job.Error = err.Error()
+	}
+}
+
+// Resume waits for the approval again, the decisions made before aslan restarted are kept in the job spec.
+func (c *ApprovalJobCtl) Resume(ctx context.Context) error {
+	c.Run(ctx)
+	return nil
+}
+
+func (c *ApprovalJobCtl) SaveInfo(ctx context.Context) error {
+	return mongodb.NewJobInfoColl().Create(context.TODO(), &commonmodels.JobInfo{
+		Type:                c.job.JobType,
+		WorkflowName:        c.workflowCtx.WorkflowName,
+		WorkflowDisplayName: c.workflowCtx.WorkflowDisplayName,
+		TaskID:              c.workflowCtx.TaskID,
+		ProductName:         c.workflowCtx.ProjectName,
+		StartTime:           c.job.StartTime,
+		EndTime:             c.job.EndTime,
+		Duration:            c.job.EndTime - c.job.StartTime,
+		Status:              string(c.job.Status),
+	})
+}
@@ -127,6 +127,22 @@ func (c *DeployJobCtl) run(ctx context.Context) error {
 		logError(c.job, msg, c.logger)
 		return errors.New(msg)
 	}
+	if c.jobTaskSpec.VerifyImageSignature && env.Production {
+		images := make([]string, 0, len(c.jobTaskSpec.ServiceAndImages))
+		for _, serviceImage := range c.jobTaskSpec.ServiceAndImages {
+			images = append(images, serviceImage.Image)
+		}
+		verified, err := verifyImageSignatures(ctx, images)
+		if err != nil {
+			msg := fmt.Sprintf("verify image signature error: %v", err)
+			logError(c.job, msg, c.logger)
+			return errors.New(msg)
+		}
+		// deploy the verified digests instead of the tags
+		for _, serviceImage := range c.jobTaskSpec.ServiceAndImages {
+			serviceImage.Image = verified[serviceImage.Image]
+		}
+	}
 
 	c.namespace = env.Namespace
 	c.jobTaskSpec.ClusterID = env.ClusterID
@@ -42,6 +42,7 @@ import (
 	kubeclient "github.com/koderover/zadig/pkg/shared/kube/client"
 	"github.com/koderover/zadig/pkg/tool/dockerhost"
 	krkubeclient "github.com/koderover/zadig/pkg/tool/kube/client"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
 	"github.com/koderover/zadig/pkg/tool/kube/informer"
 	"github.com/koderover/zadig/pkg/tool/kube/updater"
 )
@@ -134,9 +135,7 @@ func (c *FreestyleJobCtl) prepare(ctx context.Context) error {
 	return nil
 }
 
-func (c *FreestyleJobCtl) run(ctx context.Context) error {
-	// get kube client
-	hubServerAddr := config.HubServerAddress()
+func (c *FreestyleJobCtl) initKubeClients() error {
 	switch c.jobTaskSpec.Properties.ClusterID {
 	case setting.LocalClusterID:
 		c.jobTaskSpec.Properties.Namespace = zadigconfig.Namespace()
@@ -147,9 +146,8 @@ func (c *FreestyleJobCtl) run(ctx context.Context) error {
 	default:
 		c.jobTaskSpec.Properties.Namespace = setting.AttachedClusterNamespace
 
-		crClient, clientset, restConfig, apiServer, err := GetK8sClients(hubServerAddr, c.jobTaskSpec.Properties.ClusterID)
+		crClient, clientset, restConfig, apiServer, err := GetK8sClients(config.HubServerAddress(), c.jobTaskSpec.Properties.ClusterID)
 		if err != nil {
-			logError(c.job, err.Error(), c.logger)
 			return err
 		}
 		c.kubeclient = crClient
@@ -157,6 +155,29 @@ func (c *FreestyleJobCtl) run(ctx context.Context) error {
 		c.restConfig = restConfig
 		c.apiServer = apiServer
 	}
+	return nil
+}
+
+func (c *FreestyleJobCtl) initInformer() error {
+	clientSet, err := kubeclient.GetKubeClientSet(config.HubServerAddress(), c.jobTaskSpec.Properties.ClusterID)
+	if err != nil {
+		return errors.Wrap(err, "get kube client set")
+	}
+	informer, err := informer.NewInformer(c.jobTaskSpec.Properties.ClusterID, c.jobTaskSpec.Properties.Namespace, clientSet)
+	if err != nil {
+		return errors.Wrap(err, "get informer")
+	}
+	c.informer = informer
+	return nil
+}
+
+func (c *FreestyleJobCtl) run(ctx context.Context) error {
+	// get kube client
+	hubServerAddr := config.HubServerAddress()
+	if err := c.initKubeClients(); err != nil {
+		logError(c.job, err.Error(), c.logger)
+		return err
+	}
 
 	// decide which docker host to use.
 	// TODO: do not use code in warpdrive moudule, should move to a public place
@@ -236,6 +257,14 @@ func (c *FreestyleJobCtl) run(ctx context.Context) error {
 		return errors.New(msg)
 	}
 
+	if keyID := getSigningKeyID(c.jobTaskSpec); keyID != "" {
+		if err := createSigningKeySecret(c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, jobLabel, keyID, c.kubeclient); err != nil {
+			msg := fmt.Sprintf("create signing key secret error: %v", err)
+			logError(c.job, msg, c.logger)
+			return errors.New(msg)
+		}
+	}
+
 	if err := updater.CreateJob(job, c.kubeclient); err != nil {
 		msg := fmt.Sprintf("create job error: %v", err)
 		logError(c.job, msg, c.logger)
@@ -243,19 +272,49 @@ func (c *FreestyleJobCtl) run(ctx context.Context) error {
 	}
 
 	// set informer when job and cm have been created
-	clientSet, err := kubeclient.GetKubeClientSet(config.HubServerAddress(), c.jobTaskSpec.Properties.ClusterID)
-	if err != nil {
-		return errors.Wrap(err, "get kube client set")
-	}
-	informer, err := informer.NewInformer(c.jobTaskSpec.Properties.ClusterID, c.jobTaskSpec.Properties.Namespace, clientSet)
-	if err != nil {
-		return errors.Wrap(err, "get informer")
+	if err := c.initInformer(); err != nil {
+		return err
 	}
-	c.informer = informer
 	c.logger.Infof("succeed to create job %s", c.job.K8sJobName)
 	return nil
 }
 
+// Resume re-attaches the job to the kubernetes job or vm job created before aslan restarted.
+func (c *FreestyleJobCtl) Resume(ctx context.Context) error {
+	if c.job.Infrastructure == setting.JobVMInfrastructure {
+		vmJob, err := vmmongodb.NewVMJobColl().FindByOpts(vmmongodb.VMJobFindOption{
+			ProjectName:  c.workflowCtx.ProjectName,
+			WorkflowName: c.workflowCtx.WorkflowName,
+			TaskID:       c.workflowCtx.TaskID,
+			JobName:      c.job.Name,
+		})
+		if err != nil {
+			return fmt.Errorf("vm job %s not found: %v", c.job.Name, err)
+		}
+		vmJobID := vmJob.ID.Hex()
+		c.vmJobWait(ctx, vmJobID)
+		c.vmComplete(ctx, vmJobID)
+		return nil
+	}
+
+	if c.job.K8sJobName == "" {
+		return fmt.Errorf("kubernetes job of %s has not been created", c.job.Name)
+	}
+	if err := c.initKubeClients(); err != nil {
+		return err
+	}
+	if _, found, err := getter.GetJob(c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, c.kubeclient); err != nil || !found {
+		return fmt.Errorf("kubernetes job %s/%s not found, err: %v", c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, err)
+	}
+	if err := c.initInformer(); err != nil {
+		return err
+	}
+	c.logger.Infof("succeed to re-attach job %s", c.job.K8sJobName)
+	c.wait(ctx)
+	c.complete(ctx)
+	return nil
+}
+
 func (c *FreestyleJobCtl) runVMJob(ctx context.Context) (string, error) {
 	jobCtxBytes, err := yaml.Marshal(BuildJobExcutorContext(c.jobTaskSpec, c.job, c.workflowCtx, c.logger))
 	if err != nil {
@@ -321,6 +380,10 @@ func (c *FreestyleJobCtl) vmJobWait(ctx context.Context, jobID string) {
 	}
 
 	c.job.Status, c.job.Error = waitVMJobEndByCheckStatus(ctx, jobID, timeout, c.job, c.ack, c.logger)
+	// the vm job is still watched by the new owner of the task, it is not cancelled
+	if taskLost(c.workflowCtx) {
+		return
+	}
 
 	switch c.job.Status {
 	case config.StatusCancelled:
@@ -339,6 +402,11 @@ func (c *FreestyleJobCtl) vmJobWait(ctx context.Context, jobID string) {
 }
 
 func (c *FreestyleJobCtl) complete(ctx context.Context) {
+	// the new owner of the task completes and cleans the kubernetes job, its config map and secret
+	if taskLost(c.workflowCtx) {
+		c.logger.Infof("task has been taken over by another replica, leave job %s to the new owner", c.job.K8sJobName)
+		return
+	}
 	jobLabel := &JobLabel{
 		JobType: string(c.job.JobType),
 		JobName: c.job.K8sJobName,
@@ -353,6 +421,11 @@ func (c *FreestyleJobCtl) complete(ctx context.Context) {
 			if err := ensureDeleteConfigMap(c.jobTaskSpec.Properties.Namespace, jobLabel, c.kubeclient); err != nil {
 				c.logger.Error(err)
 			}
+			if getSigningKeyID(c.jobTaskSpec) != "" {
+				if err := updater.DeleteSecretWithName(c.jobTaskSpec.Properties.Namespace, getSigningKeySecretName(c.job.K8sJobName), c.kubeclient); err != nil {
+					c.logger.Error(err)
+				}
+			}
 		}()
 	}()
 
@@ -361,6 +434,7 @@ func (c *FreestyleJobCtl) complete(ctx context.Context) {
 		c.logger.Error(err)
 		c.job.Status, c.job.Error = config.StatusFailed, errors.Wrap(err, "get job outputs").Error()
 	}
+	recordSupplyChainArtifacts(c.job, c.jobTaskSpec, c.workflowCtx, c.logger)
 
 	if err := saveContainerLog(c.jobTaskSpec.Properties.Namespace, c.jobTaskSpec.Properties.ClusterID, c.workflowCtx.WorkflowName, c.job.Name, c.workflowCtx.TaskID, jobLabel, c.kubeclient); err != nil {
 		c.logger.Error(err)
@@ -377,6 +451,11 @@ func (c *FreestyleJobCtl) complete(ctx context.Context) {
 }
 
 func (c *FreestyleJobCtl) vmComplete(ctx context.Context, jobID string) {
+	// the new owner of the task completes and deletes the vm job
+	if taskLost(c.workflowCtx) {
+		c.logger.Infof("task has been taken over by another replica, leave vm job %s to the new owner", jobID)
+		return
+	}
 	defer func() {
 		go func() {
 			if err := vmmongodb.NewVMJobColl().DeleteByID(jobID, string(c.job.Status)); err != nil {
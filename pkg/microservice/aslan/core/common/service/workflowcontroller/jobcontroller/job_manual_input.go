@@ -0,0 +1,124 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	approvalservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/approval"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/instantmessage"
+	"github.com/koderover/zadig/pkg/types/job"
+)
+
+type ManualInputJobCtl struct {
+	job         *commonmodels.JobTask
+	workflowCtx *commonmodels.WorkflowTaskCtx
+	logger      *zap.SugaredLogger
+	jobTaskSpec *commonmodels.JobTaskManualInputSpec
+	ack         func()
+}
+
+func NewManualInputJobCtl(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, ack func(), logger *zap.SugaredLogger) *ManualInputJobCtl {
+	jobTaskSpec := &commonmodels.JobTaskManualInputSpec{}
+	if err := commonmodels.IToi(job.Spec, jobTaskSpec); err != nil {
+		logger.Error(err)
+	}
+	job.Spec = jobTaskSpec
+	return &ManualInputJobCtl{
+		job:         job,
+		workflowCtx: workflowCtx,
+		logger:      logger,
+		ack:         ack,
+		jobTaskSpec: jobTaskSpec,
+	}
+}
+
+func (c *ManualInputJobCtl) Clean(ctx context.Context) {}
+
+func (c *ManualInputJobCtl) Run(ctx context.Context) {
+	if c.jobTaskSpec.Timeout == 0 {
+		c.jobTaskSpec.Timeout = 60
+	}
+	c.job.Status = config.StatusWaitingApprove
+	// workflowCtx.SetStatus contain ack() function, so we don't need to call ack() here
+	c.workflowCtx.SetStatus(config.StatusWaitingApprove)
+	defer c.workflowCtx.SetStatus(config.StatusRunning)
+
+	inputKey := JobApproveKey(c.workflowCtx.WorkflowName, c.workflowCtx.TaskID, c.job.Name)
+	inputWithL := &approvalservice.ManualInputWithLock{Input: c.jobTaskSpec}
+	approvalservice.GlobalManualInputMap.SetManualInput(inputKey, inputWithL)
+	defer func() {
+		approvalservice.GlobalManualInputMap.DeleteManualInput(inputKey)
+		c.ack()
+	}()
+	if !c.workflowCtx.Resumed {
+		if err := instantmessage.NewWeChatClient().SendWorkflowTaskAproveNotifications(c.workflowCtx.WorkflowName, c.workflowCtx.TaskID); err != nil {
+			c.logger.Errorf("send manual input notification failed, error: %v", err)
+		}
+	}
+
+	timeoutDuration := time.Duration(c.jobTaskSpec.Timeout)*time.Minute - time.Since(time.Unix(c.job.StartTime, 0))
+	timeout := time.After(timeoutDuration)
+	for {
+		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			c.job.Status = config.StatusCancelled
+			return
+		case <-timeout:
+			c.job.Status = config.StatusTimeout
+			c.job.Error = "manual input timeout"
+			return
+		default:
+			if !inputWithL.IsSubmitted() {
+				continue
+			}
+			// the inputs are outputs of the job, later jobs can refer to them like other job outputs
+			for _, field := range c.jobTaskSpec.Fields {
+				c.workflowCtx.GlobalContextSet(job.GetJobOutputKey(c.job.Key, field.Name), field.Value)
+			}
+			c.job.Status = config.StatusPassed
+			return
+		}
+	}
+}
+
+// Resume waits for the inputs again, the timeout is counted from the original start time of the job.
+func (c *ManualInputJobCtl) Resume(ctx context.Context) error {
+	c.Run(ctx)
+	return nil
+}
+
+func (c *ManualInputJobCtl) SaveInfo(ctx context.Context) error {
+	return mongodb.NewJobInfoColl().Create(context.TODO(), &commonmodels.JobInfo{
+		Type:                c.job.JobType,
+		WorkflowName:        c.workflowCtx.WorkflowName,
+		WorkflowDisplayName: c.workflowCtx.WorkflowDisplayName,
+		TaskID:              c.workflowCtx.TaskID,
+		ProductName:         c.workflowCtx.ProjectName,
+		StartTime:           c.job.StartTime,
+		EndTime:             c.job.EndTime,
+		Duration:            c.job.EndTime - c.job.StartTime,
+		Status:              string(c.job.Status),
+	})
+}
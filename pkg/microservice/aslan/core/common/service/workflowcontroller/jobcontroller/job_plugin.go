@@ -33,6 +33,7 @@ import (
 	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	"github.com/koderover/zadig/pkg/setting"
 	krkubeclient "github.com/koderover/zadig/pkg/tool/kube/client"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
 	"github.com/koderover/zadig/pkg/tool/kube/updater"
 )
 
@@ -89,8 +90,25 @@ func (c *PluginJobCtl) Run(ctx context.Context) {
 	c.complete(ctx)
 }
 
-func (c *PluginJobCtl) run(ctx context.Context) error {
-	// get kube client
+// Resume re-attaches the kubernetes job created before aslan restarted.
+func (c *PluginJobCtl) Resume(ctx context.Context) error {
+	c.prepare(ctx)
+	if c.job.K8sJobName == "" {
+		return fmt.Errorf("kubernetes job of %s has not been created", c.job.Name)
+	}
+	if err := c.initKubeClients(); err != nil {
+		return err
+	}
+	if _, found, err := getter.GetJob(c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, c.kubeclient); err != nil || !found {
+		return fmt.Errorf("kubernetes job %s/%s not found, err: %v", c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, err)
+	}
+	c.logger.Infof("succeed to re-attach job %s", c.job.K8sJobName)
+	c.wait(ctx)
+	c.complete(ctx)
+	return nil
+}
+
+func (c *PluginJobCtl) initKubeClients() error {
 	hubServerAddr := config.HubServerAddress()
 	switch c.jobTaskSpec.Properties.ClusterID {
 	case setting.LocalClusterID:
@@ -112,6 +130,14 @@ func (c *PluginJobCtl) run(ctx context.Context) error {
 		c.restConfig = restConfig
 		c.apiServer = apiServer
 	}
+	return nil
+}
+
+func (c *PluginJobCtl) run(ctx context.Context) error {
+	// get kube client
+	if err := c.initKubeClients(); err != nil {
+		return err
+	}
 
 	jobLabel := &JobLabel{
 		JobType: string(c.job.JobType),
@@ -165,6 +191,11 @@ func (c *PluginJobCtl) wait(ctx context.Context) {
 }
 
 func (c *PluginJobCtl) complete(ctx context.Context) {
+	// the new owner of the task completes and cleans the kubernetes job
+	if taskLost(c.workflowCtx) {
+		c.logger.Infof("task has been taken over by another replica, leave job %s to the new owner", c.job.K8sJobName)
+		return
+	}
 	jobLabel := &JobLabel{
 		JobType: string(c.job.JobType),
 		JobName: c.job.K8sJobName,
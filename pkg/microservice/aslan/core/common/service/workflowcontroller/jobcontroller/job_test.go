@@ -0,0 +1,225 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+var (
+	_ JobResumer = &FreestyleJobCtl{}
+	_ JobResumer = &PluginJobCtl{}
+	_ JobResumer = &ApprovalJobCtl{}
+	_ JobResumer = &ManualInputJobCtl{}
+)
+
+type fakeJobCtl struct{}
+
+func (f *fakeJobCtl) Run(ctx context.Context)            {}
+func (f *fakeJobCtl) Clean(ctx context.Context)          {}
+func (f *fakeJobCtl) SaveInfo(ctx context.Context) error { return nil }
+
+type fakeResumableJobCtl struct {
+	fakeJobCtl
+}
+
+func (f *fakeResumableJobCtl) Resume(ctx context.Context) error { return nil }
+
+func TestGetJobResumeMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		jobCtl  JobCtl
+		jobType config.JobType
+		want    jobResumeMode
+	}{
+		{name: "resumer is re-attached", jobCtl: &fakeResumableJobCtl{}, jobType: config.JobZadigBuild, want: jobResumeAttach},
+		{name: "resumer wins over rerun", jobCtl: &fakeResumableJobCtl{}, jobType: config.JobZadigDeploy, want: jobResumeAttach},
+		{name: "deploy is run again", jobCtl: &fakeJobCtl{}, jobType: config.JobZadigDeploy, want: jobResumeRerun},
+		{name: "helm deploy is run again", jobCtl: &fakeJobCtl{}, jobType: config.JobZadigHelmDeploy, want: jobResumeRerun},
+		{name: "nacos is run again", jobCtl: &fakeJobCtl{}, jobType: config.JobNacos, want: jobResumeRerun},
+		{name: "workflow trigger is failed", jobCtl: &fakeJobCtl{}, jobType: config.JobWorkflowTrigger, want: jobResumeFail},
+		{name: "jenkins is failed", jobCtl: &fakeJobCtl{}, jobType: config.JobJenkins, want: jobResumeFail},
+		{name: "sql is failed", jobCtl: &fakeJobCtl{}, jobType: config.JobSQL, want: jobResumeFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getJobResumeMode(tt.jobCtl, string(tt.jobType)))
+		})
+	}
+}
+
+func TestJobStatusInProgress(t *testing.T) {
+	for _, status := range []config.Status{config.StatusCreated, config.StatusPrepare, config.StatusRunning, config.StatusWaitingApprove} {
+		assert.True(t, jobStatusInProgress(status), status)
+	}
+	for _, status := range []config.Status{config.StatusPassed, config.StatusFailed, config.StatusCancelled, config.StatusTimeout, config.StatusSkipped} {
+		assert.False(t, jobStatusInProgress(status), status)
+	}
+}
+
+func TestFailureTracker(t *testing.T) {
+	var nilTracker *FailureTracker
+	assert.False(t, nilTracker.Failed())
+
+	failure := &FailureTracker{}
+	scope := failure.Scope()
+	assert.False(t, scope.Failed())
+
+	failure.Fail()
+	assert.True(t, failure.Failed())
+	// a scope ignores the failures recorded before it was created
+	assert.False(t, scope.Failed())
+	assert.False(t, failure.Scope().Failed())
+
+	failure = &FailureTracker{}
+	scope = failure.Scope()
+	scope.Fail()
+	assert.True(t, scope.Failed())
+	assert.True(t, failure.Failed())
+}
+
+func TestSkipAfterFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ack := func() {}
+	failure := &FailureTracker{}
+
+	job := &commonmodels.JobTask{Name: "build"}
+	assert.False(t, skipAfterFailure(ctx, job, failure, ack))
+
+	failure.Fail()
+	assert.True(t, skipAfterFailure(ctx, job, failure, ack))
+	assert.Equal(t, config.StatusSkipped, job.Status)
+	assert.Equal(t, FailureSkipReason, job.SkipReason)
+
+	job = &commonmodels.JobTask{Name: "notify", When: "failure()"}
+	assert.False(t, skipAfterFailure(ctx, job, failure, ack))
+
+	cancel()
+	assert.True(t, skipAfterFailure(ctx, job, failure, ack))
+}
+
+func TestRunJobsResumed(t *testing.T) {
+	workflowCtx := &commonmodels.WorkflowTaskCtx{Resumed: true}
+	ack := func() {}
+
+	for _, status := range []config.Status{config.StatusPassed, config.StatusFailed, config.StatusTimeout, config.StatusSkipped, config.StatusReject, config.StatusCancelled} {
+		job := &commonmodels.JobTask{Name: "build", Status: status}
+		runJob(context.Background(), job, workflowCtx, log.NopSugaredLogger(), ack)
+		assert.Equal(t, status, job.Status)
+		assert.Equal(t, int64(0), job.StartTime)
+	}
+
+	// the failed job is not run again, and the jobs after it are skipped like before the restart
+	failed := &commonmodels.JobTask{Name: "build", Status: config.StatusFailed}
+	pending := &commonmodels.JobTask{Name: "deploy"}
+	failure := &FailureTracker{}
+	RunJobs(context.Background(), []*commonmodels.JobTask{failed, pending}, workflowCtx, 1, failure, log.NopSugaredLogger(), ack)
+	assert.True(t, failure.Failed())
+	assert.Equal(t, config.StatusFailed, failed.Status)
+	assert.Equal(t, config.StatusSkipped, pending.Status)
+	assert.Equal(t, FailureSkipReason, pending.SkipReason)
+}
+
+func TestTaskLost(t *testing.T) {
+	assert.False(t, taskLost(nil))
+	assert.False(t, taskLost(&commonmodels.WorkflowTaskCtx{}))
+	assert.False(t, taskLost(&commonmodels.WorkflowTaskCtx{Lost: func() bool { return false }}))
+	assert.True(t, taskLost(&commonmodels.WorkflowTaskCtx{Lost: func() bool { return true }}))
+}
+
+func TestCompleteLostTask(t *testing.T) {
+	workflowCtx := &commonmodels.WorkflowTaskCtx{Lost: func() bool { return true }}
+	job := &commonmodels.JobTask{Name: "build", K8sJobName: "build-1-abcde", Status: config.StatusCancelled}
+
+	// the controllers have no kubernetes clients, complete panics if it touches the job left to the new owner
+	freestyle := &FreestyleJobCtl{job: job, workflowCtx: workflowCtx, logger: log.NopSugaredLogger(), jobTaskSpec: &commonmodels.JobTaskFreestyleSpec{}}
+	freestyle.complete(context.Background())
+	freestyle.vmComplete(context.Background(), "vm-job")
+	plugin := &PluginJobCtl{job: job, workflowCtx: workflowCtx, logger: log.NopSugaredLogger(), jobTaskSpec: &commonmodels.JobTaskPluginSpec{}}
+	plugin.complete(context.Background())
+	assert.Equal(t, config.StatusCancelled, job.Status)
+}
+
+func TestShouldRetryJob(t *testing.T) {
+	policy := &commonmodels.JobRetryPolicy{MaxAttempts: 3}
+	tests := []struct {
+		name     string
+		status   config.Status
+		policy   *commonmodels.JobRetryPolicy
+		retry    int64
+		attempts int
+		want     bool
+	}{
+		{name: "failed", status: config.StatusFailed, policy: policy, want: true},
+		{name: "timeout", status: config.StatusTimeout, policy: policy, want: true},
+		{name: "passed", status: config.StatusPassed, policy: policy},
+		{name: "rejected", status: config.StatusReject, policy: policy},
+		{name: "cancelled", status: config.StatusCancelled, policy: policy},
+		{name: "rejected even if asked", status: config.StatusReject, policy: &commonmodels.JobRetryPolicy{MaxAttempts: 3, RetryOn: []config.Status{config.StatusReject}}},
+		{name: "not in retry on", status: config.StatusTimeout, policy: &commonmodels.JobRetryPolicy{MaxAttempts: 3, RetryOn: []config.Status{config.StatusFailed}}},
+		{name: "below the attempt limit", status: config.StatusFailed, policy: policy, attempts: 1, want: true},
+		{name: "at the attempt limit", status: config.StatusFailed, policy: policy, attempts: 2},
+		{name: "no policy", status: config.StatusFailed},
+		{name: "legacy retry count", status: config.StatusFailed, retry: 1, want: true},
+		{name: "legacy retry count exhausted", status: config.StatusFailed, retry: 1, attempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &commonmodels.JobTask{Name: "build", Status: tt.status, RetryPolicy: tt.policy, Retry: tt.retry}
+			for i := 0; i < tt.attempts; i++ {
+				job.Attempts = append(job.Attempts, &commonmodels.JobTaskAttempt{Attempt: int64(i) + 1, Status: config.StatusFailed})
+			}
+			assert.Equal(t, tt.want, shouldRetryJob(context.Background(), job))
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, shouldRetryJob(ctx, &commonmodels.JobTask{Status: config.StatusFailed, RetryPolicy: policy}))
+}
+
+func TestRetryInterval(t *testing.T) {
+	maxInterval := time.Duration(commonmodels.MaxJobRetryInterval) * time.Second
+	tests := []struct {
+		name    string
+		policy  *commonmodels.JobRetryPolicy
+		retried int
+		want    time.Duration
+	}{
+		{name: "immediately", policy: &commonmodels.JobRetryPolicy{}, retried: 3, want: 0},
+		{name: "fixed interval", policy: &commonmodels.JobRetryPolicy{Interval: 10}, retried: 3, want: 10 * time.Second},
+		{name: "backoff below 1 is fixed", policy: &commonmodels.JobRetryPolicy{Interval: 10, Backoff: 0.5}, retried: 3, want: 10 * time.Second},
+		{name: "first retry", policy: &commonmodels.JobRetryPolicy{Interval: 10, Backoff: 2}, retried: 0, want: 10 * time.Second},
+		{name: "backoff", policy: &commonmodels.JobRetryPolicy{Interval: 10, Backoff: 2}, retried: 3, want: 80 * time.Second},
+		{name: "clamped", policy: &commonmodels.JobRetryPolicy{Interval: 10, Backoff: 2}, retried: 20, want: maxInterval},
+		{name: "no overflow", policy: &commonmodels.JobRetryPolicy{Interval: 3000, Backoff: 1e300}, retried: 5, want: maxInterval},
+		{name: "interval above the limit", policy: &commonmodels.JobRetryPolicy{Interval: 1 << 40}, retried: 0, want: maxInterval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, retryInterval(tt.policy, tt.retried))
+		})
+	}
+}
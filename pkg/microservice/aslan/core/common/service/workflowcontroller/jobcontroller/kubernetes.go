@@ -63,6 +63,7 @@ import (
 	s3tool "github.com/koderover/zadig/pkg/tool/s3"
 	commontypes "github.com/koderover/zadig/pkg/types"
 	"github.com/koderover/zadig/pkg/types/job"
+	steptypes "github.com/koderover/zadig/pkg/types/step"
 	"github.com/koderover/zadig/pkg/util"
 )
 
@@ -430,7 +431,15 @@ func buildJob(jobType, jobImage, jobName, clusterID, currentNamespace string, re
 	if jobTask.BreakpointAfter {
 		jobExecutorBootingScript += fmt.Sprintf("touch %sdebug/breakpoint_after;", ZadigContextDir)
 	}
-	jobExecutorBootingScript += jobExecutorBinaryFile
+	builders := getDaemonlessBuilders(jobTaskSpec)
+	if len(builders) > 0 {
+		// the builder sidecars keep running until the exit file shows up, create it however the executor exits,
+		// the heartbeat stops the sidecars if the whole container is killed
+		jobExecutorBootingScript += fmt.Sprintf("(while true; do mkdir -p %[3]s; touch %[4]s; sleep 10; done) & %[1]s; code=$?; touch %[2]s; exit $code",
+			jobExecutorBinaryFile, steptypes.BuilderExitFile, steptypes.BuilderShareDir, steptypes.BuilderHeartbeatFile)
+	} else {
+		jobExecutorBootingScript += jobExecutorBinaryFile
+	}
 
 	labels := getJobLabels(&JobLabel{
 		JobType: jobType,
@@ -524,6 +533,10 @@ func buildJob(jobType, jobImage, jobName, clusterID, currentNamespace string, re
 			SubPath:   jobTaskSpec.Properties.Cache.NFSProperties.Subpath,
 		})
 	}
+	if getSigningKeyID(jobTaskSpec) != "" {
+		setSigningKeyVolume(job, jobName)
+	}
+	setDaemonlessBuilders(job, builders)
 	ensureVolumeMounts(job)
 	return job, nil
 }
@@ -621,6 +634,93 @@ func setJobShareStorages(job *batchv1.Job, workflowCtx *commonmodels.WorkflowTas
 	}
 }
 
+// getDaemonlessBuilders returns the builders, buildkit or kaniko, used by the docker build steps of the job.
+func getDaemonlessBuilders(jobTaskSpec *commonmodels.JobTaskFreestyleSpec) []string {
+	resp := make([]string, 0)
+	for _, stepTask := range jobTaskSpec.Steps {
+		if stepTask.StepType != config.StepDockerBuild {
+			continue
+		}
+		stepSpec := &steptypes.StepDockerBuildSpec{}
+		if err := commonmodels.IToi(stepTask.Spec, stepSpec); err != nil {
+			log.Errorf("failed to parse docker build step spec: %s", err)
+			continue
+		}
+		if stepSpec.IsDaemonless() && !util.InStringArray(stepSpec.Builder, resp) {
+			resp = append(resp, stepSpec.Builder)
+		}
+	}
+	return resp
+}
+
+// builderLivenessCheck exits the sidecar when the heartbeat of the job container is older than the timeout,
+// the job container starts before the sidecars so a missing heartbeat is only possible right after it started.
+var builderLivenessCheck = fmt.Sprintf(`if [ -e %[1]s ] && [ $(( $(date +%%s) - $(stat -c %%Y %[1]s) )) -gt %[2]d ]; then echo "job container is gone"; exit 1; fi`,
+	steptypes.BuilderHeartbeatFile, steptypes.BuilderHeartbeatTimeout)
+
+// setDaemonlessBuilders adds the builder sidecars, they share the zadig context volume with the job container,
+// where the job executor drops the build requests and the exit file.
+func setDaemonlessBuilders(job *batchv1.Job, builders []string) {
+	podSpec := &job.Spec.Template.Spec
+	contextMount := corev1.VolumeMount{
+		Name:      "zadig-context",
+		MountPath: ZadigContextDir,
+	}
+	waitExit := fmt.Sprintf("while [ ! -e %s ]; do %s; sleep 1; done", steptypes.BuilderExitFile, builderLivenessCheck)
+
+	for _, builder := range builders {
+		switch builder {
+		case steptypes.DockerBuilderBuildKit:
+			podSpec.Containers[0].Env = append(podSpec.Containers[0].Env, corev1.EnvVar{
+				Name:  "BUILDKIT_HOST",
+				Value: steptypes.BuildKitAddr,
+			})
+			if job.Spec.Template.Annotations == nil {
+				job.Spec.Template.Annotations = map[string]string{}
+			}
+			job.Spec.Template.Annotations["container.apparmor.security.beta.kubernetes.io/buildkitd"] = "unconfined"
+			podSpec.Containers = append(podSpec.Containers, corev1.Container{
+				ImagePullPolicy: corev1.PullIfNotPresent,
+				Name:            "buildkitd",
+				Image:           config.BuildKitImage(),
+				Command:         []string{"/bin/sh", "-c"},
+				Args: []string{fmt.Sprintf("mkdir -p -m 777 %s; rootlesskit buildkitd --oci-worker-no-process-sandbox --addr %s & %s",
+					steptypes.BuilderShareDir, steptypes.BuildKitAddr, waitExit)},
+				VolumeMounts: []corev1.VolumeMount{contextMount},
+				SecurityContext: &corev1.SecurityContext{
+					RunAsUser:  int64Ptr(1000),
+					RunAsGroup: int64Ptr(1000),
+					SeccompProfile: &corev1.SeccompProfile{
+						Type: corev1.SeccompProfileTypeUnconfined,
+					},
+				},
+			})
+		case steptypes.DockerBuilderKaniko:
+			// run the build scripts dropped by the job executor one by one, see the kaniko build of the docker build step
+			script := fmt.Sprintf(`mkdir -p -m 777 %[1]s
+while [ ! -e %[2]s ]; do
+  for f in %[1]s/*.sh; do
+    [ -e "$f" ] || continue
+    mv "$f" "$f.running"
+    /busybox/sh "$f.running" > "${f%%.sh}.log" 2>&1
+    echo $? > "${f%%.sh}.exit"
+    rm -f "$f.running"
+  done
+  %[3]s
+  sleep 1
+done`, steptypes.KanikoRequestDir, steptypes.BuilderExitFile, builderLivenessCheck)
+			podSpec.Containers = append(podSpec.Containers, corev1.Container{
+				ImagePullPolicy: corev1.PullIfNotPresent,
+				Name:            "kaniko",
+				Image:           config.KanikoImage(),
+				Command:         []string{"/busybox/sh", "-c"},
+				Args:            []string{script},
+				VolumeMounts:    []corev1.VolumeMount{contextMount},
+			})
+		}
+	}
+}
+
 func ensureVolumeMounts(job *batchv1.Job) {
 	for i := range job.Spec.Template.Spec.Containers {
 		mountPathMap := make(map[string]bool)
@@ -1169,6 +1269,30 @@ func saveContainerLog(namespace, clusterID, workflowName, jobName string, taskID
 	return nil
 }
 
+// copyContainerLog copies the saved log of a job to a new name in the same task, so it is not overwritten when the job is retried.
+func copyContainerLog(workflowName, jobName, newName string, taskID int64) error {
+	store, err := commonrepo.NewS3StorageColl().FindDefault()
+	if err != nil {
+		return fmt.Errorf("failed to get default s3 storage: %s", err)
+	}
+	if store.Subfolder != "" {
+		store.Subfolder = fmt.Sprintf("%s/%s/%d/%s", store.Subfolder, strings.ToLower(workflowName), taskID, "log")
+	} else {
+		store.Subfolder = fmt.Sprintf("%s/%d/%s", strings.ToLower(workflowName), taskID, "log")
+	}
+	forcedPathStyle := true
+	if store.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	s3client, err := s3tool.NewClient(store.Endpoint, store.Ak, store.Sk, store.Region, store.Insecure, forcedPathStyle)
+	if err != nil {
+		return fmt.Errorf("copyContainerLog s3 create client error: %v", err)
+	}
+	oldKey := GetObjectPath(store.Subfolder, strings.Replace(strings.ToLower(jobName), "_", "-", -1)+".log")
+	newKey := GetObjectPath(store.Subfolder, strings.Replace(strings.ToLower(newName), "_", "-", -1)+".log")
+	return s3client.CopyObject(store.Bucket, oldKey, newKey)
+}
+
 func GetObjectPath(subFolder, name string) string {
 	// target should not be started with /
 	if subFolder != "" {
@@ -0,0 +1,45 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	steptypes "github.com/koderover/zadig/pkg/types/step"
+)
+
+func TestSetDaemonlessBuilders(t *testing.T) {
+	job := &batchv1.Job{}
+	job.Spec.Template.Spec.Containers = []corev1.Container{{Name: "job"}}
+	setDaemonlessBuilders(job, []string{steptypes.DockerBuilderBuildKit, steptypes.DockerBuilderKaniko})
+
+	containers := job.Spec.Template.Spec.Containers
+	assert.Len(t, containers, 3)
+	for _, container := range containers[1:] {
+		script := strings.Join(container.Args, " ")
+		// the sidecars stop when the exit file shows up or the heartbeat of the job container is stale
+		assert.Contains(t, script, steptypes.BuilderExitFile, container.Name)
+		assert.Contains(t, script, builderLivenessCheck, container.Name)
+	}
+	assert.Contains(t, builderLivenessCheck, steptypes.BuilderHeartbeatFile)
+	assert.Contains(t, builderLivenessCheck, "-gt 60")
+}
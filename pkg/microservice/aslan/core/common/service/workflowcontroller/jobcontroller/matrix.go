@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+// matrixGroupMap keeps the running state of the job tasks expanded from the same job matrix,
+// key is workflowName-taskID-group.
+var matrixGroupMap sync.Map
+
+type matrixGroup struct {
+	// sem limits the running combinations, nil means no limit
+	sem    chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	failedCell string
+}
+
+func matrixGroupKey(workflowName string, taskID int64, group string) string {
+	return fmt.Sprintf("%s-%d-%s", workflowName, taskID, group)
+}
+
+func loadMatrixGroup(ctx context.Context, job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx) *matrixGroup {
+	key := matrixGroupKey(workflowCtx.WorkflowName, workflowCtx.TaskID, job.Matrix.Group)
+	if value, ok := matrixGroupMap.Load(key); ok {
+		return value.(*matrixGroup)
+	}
+	group := &matrixGroup{}
+	if job.Matrix.MaxParallel > 0 {
+		group.sem = make(chan struct{}, job.Matrix.MaxParallel)
+	}
+	group.ctx, group.cancel = context.WithCancel(ctx)
+	value, loaded := matrixGroupMap.LoadOrStore(key, group)
+	if loaded {
+		group.cancel()
+	}
+	return value.(*matrixGroup)
+}
+
+// CleanMatrixGroups releases the matrix state of a finished workflow task.
+func CleanMatrixGroups(workflowName string, taskID int64) {
+	prefix := fmt.Sprintf("%s-%d-", workflowName, taskID)
+	matrixGroupMap.Range(func(key, value interface{}) bool {
+		if strings.HasPrefix(key.(string), prefix) {
+			value.(*matrixGroup).cancel()
+			matrixGroupMap.Delete(key)
+		}
+		return true
+	})
+}
+
+func (g *matrixGroup) failed() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.failedCell
+}
+
+// fail records the first failed combination and stops the others if fail-fast is set.
+func (g *matrixGroup) fail(job *commonmodels.JobTask) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.failedCell != "" {
+		return
+	}
+	g.failedCell = job.Name
+	if job.Matrix.FailFast {
+		g.cancel()
+	}
+}
+
+// runMatrixJob runs a combination of a job matrix within the max-parallel limit of its matrix, when fail-fast is
+// set the combinations not started yet are skipped and the running ones are stopped after a combination failed.
+func runMatrixJob(ctx context.Context, job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func(), run func(ctx context.Context)) {
+	group := loadMatrixGroup(ctx, job, workflowCtx)
+	if group.sem != nil {
+		select {
+		case group.sem <- struct{}{}:
+			defer func() { <-group.sem }()
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	if failedCell := group.failed(); failedCell != "" && job.Matrix.FailFast {
+		logger.Infof("skip job: %s, matrix job %s failed", job.Name, failedCell)
+		job.Status = config.StatusSkipped
+		job.SkipReason = fmt.Sprintf("skipped by fail-fast because %s failed", failedCell)
+		job.StartTime = time.Now().Unix()
+		job.EndTime = job.StartTime
+		ack()
+		return
+	}
+
+	run(group.ctx)
+
+	// the combination was stopped by fail-fast rather than by the user
+	if job.Status == config.StatusCancelled && ctx.Err() == nil {
+		job.Status = config.StatusFailed
+		job.Error = fmt.Sprintf("cancelled by fail-fast because %s failed", group.failed())
+		ack()
+		return
+	}
+	if jobStatusFailed(job.Status) {
+		group.fail(job)
+	}
+}
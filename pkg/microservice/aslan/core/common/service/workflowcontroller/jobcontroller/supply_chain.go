@@ -0,0 +1,284 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	crClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/tool/kube/updater"
+	"github.com/koderover/zadig/pkg/types/job"
+	steptypes "github.com/koderover/zadig/pkg/types/step"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+const (
+	cosignExe              = "/app/cosign"
+	signingKeySecretSuffix = "-signing-key"
+	signingKeyVolumeName   = "signing-key"
+)
+
+// recordSupplyChainArtifacts saves the references written by the supply chain step of the job, they are kept on
+// the workflow task and in the image supply chain records looked up by deploy jobs and delivery versions.
+func recordSupplyChainArtifacts(jobTask *commonmodels.JobTask, jobTaskSpec *commonmodels.JobTaskFreestyleSpec, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger) {
+	for _, stepTask := range jobTaskSpec.Steps {
+		if stepTask.StepType != config.StepSupplyChain {
+			continue
+		}
+		stepSpec := &steptypes.StepSupplyChainSpec{}
+		if err := commonmodels.IToi(stepTask.Spec, stepSpec); err != nil {
+			logger.Errorf("failed to parse supply chain step spec: %s", err)
+			return
+		}
+		output := func(key string) string {
+			value, _ := workflowCtx.GlobalContextGet(job.GetJobOutputKey(jobTask.Key, key))
+			return value
+		}
+		// the digest is only written when the step succeeded
+		if output(steptypes.SupplyChainOutputDigest) == "" {
+			return
+		}
+
+		jobInfo := map[string]string{}
+		if err := commonmodels.IToi(jobTask.JobInfo, &jobInfo); err != nil {
+			logger.Warnf("failed to parse job info of %s: %s", jobTask.Name, err)
+		}
+		artifact := &commonmodels.SupplyChainArtifact{
+			Image:         output(IMAGEKEY),
+			Digest:        output(steptypes.SupplyChainOutputDigest),
+			Signature:     output(steptypes.SupplyChainOutputSignature),
+			SBOM:          output(steptypes.SupplyChainOutputSBOM),
+			SBOMFormat:    stepSpec.SBOMFormat,
+			Attestation:   output(steptypes.SupplyChainOutputAttestation),
+			SigningKeyID:  stepSpec.SigningKeyID,
+			ProjectName:   workflowCtx.ProjectName,
+			WorkflowName:  workflowCtx.WorkflowName,
+			TaskID:        workflowCtx.TaskID,
+			JobName:       jobTask.Name,
+			ServiceName:   jobInfo["service_name"],
+			ServiceModule: jobInfo["service_module"],
+			CreateTime:    time.Now().Unix(),
+		}
+		if err := commonrepo.NewSupplyChainArtifactColl().Create(artifact); err != nil {
+			logger.Errorf("failed to save supply chain artifacts of %s: %s", artifact.Image, err)
+		}
+		if workflowCtx.SupplyChainArtifactAdd != nil {
+			workflowCtx.SupplyChainArtifactAdd(artifact)
+		}
+		return
+	}
+}
+
+// getSigningKeyID returns the signing key used by the supply chain step of the job, it is empty if no key is needed.
+func getSigningKeyID(jobTaskSpec *commonmodels.JobTaskFreestyleSpec) string {
+	for _, stepTask := range jobTaskSpec.Steps {
+		if stepTask.StepType != config.StepSupplyChain {
+			continue
+		}
+		stepSpec := &steptypes.StepSupplyChainSpec{}
+		if err := commonmodels.IToi(stepTask.Spec, stepSpec); err != nil || !stepSpec.NeedKey() {
+			return ""
+		}
+		return stepSpec.SigningKeyID
+	}
+	return ""
+}
+
+func getSigningKeySecretName(jobName string) string {
+	return jobName + signingKeySecretSuffix
+}
+
+// createSigningKeySecret saves the signing key into a secret of the job, so the private key never shows up in the
+// step spec saved in the workflow task and the job configmap. The secret is deleted along with the job.
+func createSigningKeySecret(namespace, jobName string, jobLabel *JobLabel, keyID string, kubeClient crClient.Client) error {
+	key, err := commonrepo.NewSigningKeyColl().Find(keyID)
+	if err != nil {
+		return fmt.Errorf("find signing key %s error: %v", keyID, err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getSigningKeySecretName(jobName),
+			Namespace: namespace,
+			Labels:    getJobLabels(jobLabel),
+		},
+		Data: map[string][]byte{
+			steptypes.SigningKeyFile:         []byte(key.PrivateKey),
+			steptypes.SigningKeyPasswordFile: []byte(key.Password),
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+	return updater.UpdateOrCreateSecret(secret, kubeClient)
+}
+
+// setSigningKeyVolume mounts the signing key secret of the job into the job container.
+func setSigningKeyVolume(job *batchv1.Job, jobName string) {
+	job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, corev1.Volume{
+		Name: signingKeyVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: getSigningKeySecretName(jobName),
+			},
+		},
+	})
+	job.Spec.Template.Spec.Containers[0].VolumeMounts = append(job.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      signingKeyVolumeName,
+		MountPath: steptypes.SigningKeyDir,
+		ReadOnly:  true,
+	})
+}
+
+// verifyImageSignatures runs cosign verify on the images with the public keys of the signing keys managed by zadig,
+// an image passes if any of the keys verifies it. The images are returned pinned to the verified digests, so a tag
+// pushed again after the verification is never deployed.
+func verifyImageSignatures(ctx context.Context, images []string) (map[string]string, error) {
+	keys, err := commonrepo.NewSigningKeyColl().List()
+	if err != nil {
+		return nil, fmt.Errorf("list signing keys error: %v", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing key to verify the images")
+	}
+	registries, err := commonrepo.NewRegistryNamespaceColl().FindAll(&commonrepo.FindRegOps{})
+	if err != nil {
+		return nil, fmt.Errorf("list registries error: %v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "verify-image")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	keyFiles := make([]string, 0, len(keys))
+	for _, key := range keys {
+		keyFile := filepath.Join(tmpDir, key.ID.Hex()+".pub")
+		if err := os.WriteFile(keyFile, []byte(key.PublicKey), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write public key of %s: %v", key.Name, err)
+		}
+		keyFiles = append(keyFiles, keyFile)
+	}
+
+	resp := make(map[string]string, len(images))
+	for _, image := range images {
+		envs, err := cosignRegistryEnvs(tmpDir, getMatchedRegistries(image, registries))
+		if err != nil {
+			return nil, err
+		}
+		digest, err := verifyImageSignature(ctx, image, keyFiles, envs)
+		if err != nil {
+			return nil, err
+		}
+		resp[image] = pinImageDigest(image, digest)
+	}
+	return resp, nil
+}
+
+func verifyImageSignature(ctx context.Context, image string, keyFiles, envs []string) (string, error) {
+	var lastErr error
+	for _, keyFile := range keyFiles {
+		var stdout, stderr bytes.Buffer
+		// the images are signed without uploading to the transparency log
+		cmd := exec.CommandContext(ctx, cosignExe, "verify", "--key", keyFile, "--insecure-ignore-tlog=true", "--output", "json", image)
+		cmd.Env = envs
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			lastErr = fmt.Errorf("%s %s", err, strings.TrimSpace(stderr.String()))
+			continue
+		}
+		return parseVerifiedDigest(stdout.Bytes())
+	}
+	return "", fmt.Errorf("image %s is not signed by any signing key: %v", image, lastErr)
+}
+
+// cosignRegistryEnvs writes the docker config of the registries of the image for cosign, the ECR registries are
+// logged in by the ECR credential helper built in cosign.
+func cosignRegistryEnvs(dir string, registries []*commonmodels.RegistryNamespace) ([]string, error) {
+	envs := os.Environ()
+	auths := map[string]interface{}{}
+	for _, reg := range registries {
+		if reg.AccessKey == "" {
+			continue
+		}
+		host := strings.TrimPrefix(strings.TrimPrefix(reg.RegAddr, "https://"), "http://")
+		switch reg.RegProvider {
+		case config.RegistryTypeAWS:
+			envs = append(envs, "AWS_ACCESS_KEY_ID="+reg.AccessKey, "AWS_SECRET_ACCESS_KEY="+reg.SecretKey, "AWS_REGION="+reg.Region)
+		case config.RegistryTypeSWR:
+			auth := fmt.Sprintf("%s@%s:%s", reg.Region, reg.AccessKey, util.ComputeHmacSha256(reg.AccessKey, reg.SecretKey))
+			auths[host] = map[string]string{"auth": base64.StdEncoding.EncodeToString([]byte(auth))}
+		default:
+			auths[host] = map[string]string{"auth": base64.StdEncoding.EncodeToString([]byte(reg.AccessKey + ":" + reg.SecretKey))}
+		}
+	}
+	content, err := json.Marshal(map[string]interface{}{"auths": auths})
+	if err != nil {
+		return nil, err
+	}
+	configDir, err := os.MkdirTemp(dir, "docker")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "config.json"), content, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write docker config: %v", err)
+	}
+	return append(envs, "DOCKER_CONFIG="+configDir), nil
+}
+
+// parseVerifiedDigest reads the manifest digest from the verified signature payloads printed by cosign verify.
+func parseVerifiedDigest(output []byte) (string, error) {
+	payloads := make([]struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}, 0)
+	if err := json.Unmarshal(output, &payloads); err != nil {
+		return "", fmt.Errorf("failed to parse cosign verify output: %v", err)
+	}
+	for _, payload := range payloads {
+		if digest := payload.Critical.Image.DockerManifestDigest; digest != "" {
+			return digest, nil
+		}
+	}
+	return "", fmt.Errorf("no verified digest in cosign verify output")
+}
+
+// pinImageDigest pins the image to the digest, the tag is kept for readability and ignored by the container runtime.
+func pinImageDigest(image, digest string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	return image + "@" + digest
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	steptypes "github.com/koderover/zadig/pkg/types/step"
+)
+
+func TestParseVerifiedDigest(t *testing.T) {
+	output := `[{"critical":{"identity":{"docker-reference":"koderover.io/app"},"image":{"docker-manifest-digest":"sha256:abc"},"type":"cosign container image signature"},"optional":null}]`
+	digest, err := parseVerifiedDigest([]byte(output))
+	assert.NoError(t, err)
+	assert.Equal(t, "sha256:abc", digest)
+
+	_, err = parseVerifiedDigest([]byte(`[]`))
+	assert.Error(t, err)
+	_, err = parseVerifiedDigest([]byte(`Verification for koderover.io/app:v1`))
+	assert.Error(t, err)
+}
+
+func TestPinImageDigest(t *testing.T) {
+	assert.Equal(t, "koderover.io/app:v1@sha256:abc", pinImageDigest("koderover.io/app:v1", "sha256:abc"))
+	assert.Equal(t, "koderover.io:5000/app@sha256:abc", pinImageDigest("koderover.io:5000/app", "sha256:abc"))
+	// a digest in the image is replaced by the verified one
+	assert.Equal(t, "koderover.io/app:v1@sha256:abc", pinImageDigest("koderover.io/app:v1@sha256:def", "sha256:abc"))
+}
+
+func TestSigningKeySecret(t *testing.T) {
+	spec := &commonmodels.JobTaskFreestyleSpec{
+		Steps: []*commonmodels.StepTask{
+			{StepType: config.StepShell},
+			{StepType: config.StepSupplyChain, Spec: &steptypes.StepSupplyChainSpec{SBOMFormat: steptypes.SBOMFormatSPDX, SigningKeyID: "key"}},
+		},
+	}
+	// no key is mounted if the step neither signs nor attests
+	assert.Empty(t, getSigningKeyID(spec))
+	spec.Steps[1].Spec = &steptypes.StepSupplyChainSpec{Sign: true, SigningKeyID: "key"}
+	assert.Equal(t, "key", getSigningKeyID(spec))
+
+	job := &batchv1.Job{}
+	job.Spec.Template.Spec.Containers = []corev1.Container{{Name: "job"}}
+	setSigningKeyVolume(job, "build-1")
+	assert.Equal(t, "build-1-signing-key", job.Spec.Template.Spec.Volumes[0].Secret.SecretName)
+	assert.Equal(t, steptypes.SigningKeyDir, job.Spec.Template.Spec.Containers[0].VolumeMounts[0].MountPath)
+	assert.True(t, job.Spec.Template.Spec.Containers[0].VolumeMounts[0].ReadOnly)
+}
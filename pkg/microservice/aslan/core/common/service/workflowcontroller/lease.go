@@ -0,0 +1,407 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/msg_queue"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	approvalservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/approval"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller/jobcontroller"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+const (
+	// a task whose heartbeat is older than workflowTaskLeaseTimeout will be taken over by another aslan replica
+	workflowTaskLeaseTimeout      = 30 * time.Second
+	workflowTaskHeartbeatInterval = 10 * time.Second
+	// approvals and cancels forwarded by other replicas are delivered in workflowTaskMessageInterval
+	workflowTaskMessageInterval = time.Second
+)
+
+// leaseRepo is the storage of the workflow task leases, it is replaced by an in-memory one in tests.
+type leaseRepo interface {
+	ClaimWaiting(args *commonmodels.WorkflowQueue, owner string, heartbeat int64) (bool, error)
+	RenewLease(workflowName string, taskID int64, owner string, heartbeat int64) (bool, error)
+	ListExpired(expireBefore int64) ([]*commonmodels.WorkflowQueue, error)
+	TakeOver(args *commonmodels.WorkflowQueue, owner string, heartbeat int64) (bool, error)
+	List(opt *commonrepo.ListWorfklowQueueOption) ([]*commonmodels.WorkflowQueue, error)
+	Delete(args *commonmodels.WorkflowQueue) error
+	FindTask(workflowName string, taskID int64) (*commonmodels.WorkflowTask, error)
+}
+
+type mongoLeaseRepo struct {
+	*commonrepo.WorkflowQueueColl
+}
+
+func (r *mongoLeaseRepo) FindTask(workflowName string, taskID int64) (*commonmodels.WorkflowTask, error) {
+	return commonrepo.NewworkflowTaskv4Coll().Find(workflowName, taskID)
+}
+
+func newLeaseRepo() leaseRepo {
+	return &mongoLeaseRepo{WorkflowQueueColl: commonrepo.NewWorkflowQueueColl()}
+}
+
+// ownedTaskMap records the workflow tasks run by this aslan replica, key is the same as cancelChannelMap
+var ownedTaskMap sync.Map
+
+type ownedTask struct {
+	WorkflowName string
+	TaskID       int64
+	lost         int32
+}
+
+// Lost returns true if the task has been taken over by another replica.
+func (t *ownedTask) Lost() bool {
+	return t != nil && atomic.LoadInt32(&t.lost) == 1
+}
+
+// cancelMessage is sent to the replica running the task when the task is cancelled on another replica.
+type cancelMessage struct {
+	WorkflowName string `json:"workflow_name"`
+	TaskID       int64  `json:"task_id"`
+	Owner        string `json:"owner"`
+}
+
+type approveMessage struct {
+	WorkflowName string `json:"workflow_name"`
+	TaskID       int64  `json:"task_id"`
+	StageName    string `json:"stage_name"`
+	// JobName is set instead of StageName for the approval jobs and the manual input jobs, Inputs is only set for the latter
+	JobName  string            `json:"job_name,omitempty"`
+	Inputs   map[string]string `json:"inputs"`
+	UserName string            `json:"user_name"`
+	UserID   string            `json:"user_id"`
+	Comment  string            `json:"comment"`
+	Approve  bool              `json:"approve"`
+}
+
+var (
+	leaseOwner     string
+	leaseOwnerOnce sync.Once
+)
+
+// LeaseOwner returns the identity of this aslan replica used in workflow queue leases.
+func LeaseOwner() string {
+	leaseOwnerOnce.Do(func() {
+		leaseOwner = config.PodName()
+		if leaseOwner == "" {
+			leaseOwner, _ = os.Hostname()
+		}
+	})
+	return leaseOwner
+}
+
+// WorkflowTaskLeaseKeeper renews the leases of the tasks run by this replica and takes over the tasks
+// whose owner stopped renewing their leases.
+func WorkflowTaskLeaseKeeper() {
+	for {
+		time.Sleep(workflowTaskHeartbeatInterval)
+
+		renewLeases()
+		takeOverExpiredTasks()
+	}
+}
+
+// WorkflowTaskMessageDeliverer delivers the approvals and cancels forwarded by other replicas.
+func WorkflowTaskMessageDeliverer() {
+	for {
+		time.Sleep(workflowTaskMessageInterval)
+
+		deliverApprovals()
+		deliverCancels()
+	}
+}
+
+// ReleaseWorkflowTaskLeases is called when aslan is shutting down, the tasks run by this replica are handed over
+// to the other replicas, or to this replica after it restarted, without waiting for their leases to expire.
+func ReleaseWorkflowTaskLeases() {
+	ownedTaskMap.Range(func(key, value interface{}) bool {
+		// stop saving the progress of the task, the new owner continues from what has been saved
+		atomic.StoreInt32(&value.(*ownedTask).lost, 1)
+		return true
+	})
+	if err := commonrepo.NewWorkflowQueueColl().ReleaseLeases(LeaseOwner()); err != nil {
+		log.Errorf("release workflow task leases of %s error: %v", LeaseOwner(), err)
+	}
+}
+
+func renewLeases() {
+	repo := newLeaseRepo()
+	ownedTaskMap.Range(func(key, value interface{}) bool {
+		renewLease(repo, key.(string), value.(*ownedTask))
+		return true
+	})
+}
+
+func renewLease(repo leaseRepo, cancelKey string, task *ownedTask) {
+	owned, err := repo.RenewLease(task.WorkflowName, task.TaskID, LeaseOwner(), time.Now().Unix())
+	if err != nil {
+		log.Errorf("renew lease of workflow task %s:%d error: %v", task.WorkflowName, task.TaskID, err)
+		return
+	}
+	if !owned {
+		handleLostLease(repo, cancelKey, task)
+	}
+}
+
+// handleLostLease stops the local controller if the task was cancelled by another replica or taken over.
+// A task that finished normally, or is running its final stages after being cancelled, also has no queue item,
+// in that case nothing needs to be done.
+func handleLostLease(repo leaseRepo, cancelKey string, task *ownedTask) {
+	t, err := repo.FindTask(task.WorkflowName, task.TaskID)
+	if err != nil {
+		log.Errorf("find workflow task %s:%d error: %v", task.WorkflowName, task.TaskID, err)
+		return
+	}
+	switch {
+	case t.Status == config.StatusCancelled:
+	case statusFinished(t.Status):
+		return
+	default:
+		queues, err := repo.List(&commonrepo.ListWorfklowQueueOption{WorkflowName: task.WorkflowName})
+		if err != nil {
+			log.Errorf("list workflow queue of %s error: %v", task.WorkflowName, err)
+			return
+		}
+		takenOver := false
+		for _, q := range queues {
+			if q.TaskID == task.TaskID && q.Owner != LeaseOwner() {
+				log.Warnf("lease of workflow task %s:%d was taken over by %s, stop running it", task.WorkflowName, task.TaskID, q.Owner)
+				atomic.StoreInt32(&task.lost, 1)
+				takenOver = true
+				break
+			}
+		}
+		if !takenOver {
+			return
+		}
+	}
+	value, ok := cancelChannelMap.Load(cancelKey)
+	if !ok {
+		return
+	}
+	if f, ok := value.(context.CancelFunc); ok {
+		f()
+	}
+}
+
+func takeOverExpiredTasks() {
+	logger := log.SugaredLogger()
+	tasks := takeOverExpiredLeases(newLeaseRepo(), logger)
+	if len(tasks) == 0 {
+		return
+	}
+	sysSetting, err := commonrepo.NewSystemSettingColl().Get()
+	if err != nil {
+		logger.Errorf("get system stettings error: %v", err)
+		return
+	}
+	for _, task := range tasks {
+		go NewWorkflowController(task, logger).Resume(context.Background(), int(sysSetting.BuildConcurrency))
+	}
+}
+
+// takeOverExpiredLeases takes over the tasks whose owner stopped renewing their leases, it returns the unfinished
+// tasks taken over by this replica, which should be resumed.
+func takeOverExpiredLeases(repo leaseRepo, logger *zap.SugaredLogger) []*commonmodels.WorkflowTask {
+	queues, err := repo.ListExpired(time.Now().Add(-workflowTaskLeaseTimeout).Unix())
+	if err != nil {
+		logger.Errorf("list expired workflow queue error: %v", err)
+		return nil
+	}
+	tasks := make([]*commonmodels.WorkflowTask, 0)
+	for _, q := range queues {
+		taken, err := repo.TakeOver(q, LeaseOwner(), time.Now().Unix())
+		if err != nil {
+			logger.Errorf("take over workflow task %s:%d error: %v", q.WorkflowName, q.TaskID, err)
+			continue
+		}
+		if !taken {
+			continue
+		}
+		task, err := repo.FindTask(q.WorkflowName, q.TaskID)
+		if err != nil {
+			logger.Errorf("find workflow task %s:%d error: %v", q.WorkflowName, q.TaskID, err)
+			continue
+		}
+		// the previous owner finished the task but did not remove it from the queue
+		if statusFinished(task.Status) || task.Status == config.StatusCancelled {
+			if err := repo.Delete(q); err != nil {
+				logger.Errorf("remove queue task: %s:%d error: %v", q.WorkflowName, q.TaskID, err)
+			}
+			continue
+		}
+		logger.Infof("take over workflow task %s:%d from %s, status: %s", q.WorkflowName, q.TaskID, q.Owner, task.Status)
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// forwardApproval validates the approval against the stored task and saves it for the replica running the task.
+func forwardApproval(workflowName, stageName, userName, userID, comment string, taskID int64, approve bool) error {
+	task, err := commonrepo.NewworkflowTaskv4Coll().Find(workflowName, taskID)
+	if err != nil {
+		return fmt.Errorf("workflow %s ID %d not found: %v", workflowName, taskID, err)
+	}
+	var stage *commonmodels.StageTask
+	for _, s := range task.Stages {
+		if s.Name == stageName {
+			stage = s
+			break
+		}
+	}
+	if stage == nil || stage.Status != config.StatusWaitingApprove || stage.Approval == nil || stage.Approval.NativeApproval == nil {
+		return fmt.Errorf("workflow %s ID %d stage %s do not need approve", workflowName, taskID, stageName)
+	}
+	// DoApproval on the copy loaded from db checks the authority of the user without changing the running task
+	if err := (&approvalservice.ApproveWithLock{Approval: stage.Approval.NativeApproval}).DoApproval(userName, userID, comment, approve); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(&approveMessage{
+		WorkflowName: workflowName,
+		TaskID:       taskID,
+		StageName:    stageName,
+		UserName:     userName,
+		UserID:       userID,
+		Comment:      comment,
+		Approve:      approve,
+	})
+	if err != nil {
+		return err
+	}
+	return commonrepo.NewMsgQueueCommonColl().Create(&msg_queue.MsgQueueCommon{
+		Payload:   string(payload),
+		QueueType: setting.TopicApprove,
+	})
+}
+
+func deliverApprovals() {
+	msgs, err := commonrepo.NewMsgQueueCommonColl().List(&commonrepo.ListMsgQueueCommonOption{QueueType: setting.TopicApprove})
+	if err != nil {
+		log.Errorf("list approve messages error: %v", err)
+		return
+	}
+	for _, msg := range msgs {
+		m := new(approveMessage)
+		if err := json.Unmarshal([]byte(msg.Payload), m); err != nil {
+			log.Errorf("unmarshal approve message error: %v", err)
+			_ = commonrepo.NewMsgQueueCommonColl().Delete(msg.ID)
+			continue
+		}
+		if m.JobName != "" {
+			deliverJobApproval(msg, m)
+			continue
+		}
+		approveKey := jobcontroller.StageApproveKey(m.WorkflowName, m.TaskID, m.StageName)
+		approveWithL, ok := approvalservice.GlobalApproveMap.GetApproval(approveKey)
+		if !ok {
+			// drop the message if nobody is waiting for it anymore, otherwise leave it to the owner
+			task, err := commonrepo.NewworkflowTaskv4Coll().Find(m.WorkflowName, m.TaskID)
+			if err == nil && task.Status == config.StatusWaitingApprove {
+				continue
+			}
+			_ = commonrepo.NewMsgQueueCommonColl().Delete(msg.ID)
+			continue
+		}
+		if err := approveWithL.DoApproval(m.UserName, m.UserID, m.Comment, m.Approve); err != nil {
+			log.Warnf("deliver approval of %s error: %v", approveKey, err)
+		}
+		if err := commonrepo.NewMsgQueueCommonColl().Delete(msg.ID); err != nil {
+			log.Errorf("delete approve message error: %v", err)
+		}
+	}
+}
+
+// forwardCancel asks the owner of the task to stop running it.
+func forwardCancel(workflowName string, taskID int64, owner string) error {
+	payload, err := json.Marshal(&cancelMessage{
+		WorkflowName: workflowName,
+		TaskID:       taskID,
+		Owner:        owner,
+	})
+	if err != nil {
+		return err
+	}
+	return commonrepo.NewMsgQueueCommonColl().Create(&msg_queue.MsgQueueCommon{
+		Payload:   string(payload),
+		QueueType: setting.TopicTaskCancel,
+	})
+}
+
+func deliverCancels() {
+	msgs, err := commonrepo.NewMsgQueueCommonColl().List(&commonrepo.ListMsgQueueCommonOption{QueueType: setting.TopicTaskCancel})
+	if err != nil {
+		log.Errorf("list cancel messages error: %v", err)
+		return
+	}
+	for _, msg := range msgs {
+		m := new(cancelMessage)
+		if err := json.Unmarshal([]byte(msg.Payload), m); err != nil {
+			log.Errorf("unmarshal cancel message error: %v", err)
+			_ = commonrepo.NewMsgQueueCommonColl().Delete(msg.ID)
+			continue
+		}
+		if m.Owner != LeaseOwner() {
+			// the owner is gone, the task has been stopped by the lease check of the replica running it
+			if time.Since(msg.ID.Timestamp()) > workflowTaskLeaseTimeout {
+				_ = commonrepo.NewMsgQueueCommonColl().Delete(msg.ID)
+			}
+			continue
+		}
+		if value, ok := cancelChannelMap.Load(fmt.Sprintf("%s-%d", m.WorkflowName, m.TaskID)); ok {
+			if f, ok := value.(context.CancelFunc); ok {
+				f()
+			}
+		}
+		if err := commonrepo.NewMsgQueueCommonColl().Delete(msg.ID); err != nil {
+			log.Errorf("delete cancel message error: %v", err)
+		}
+	}
+}
+
+// queueOwner returns the replica running the task, it is empty if the task has not been started.
+func queueOwner(workflowName string, taskID int64) string {
+	queues, err := commonrepo.NewWorkflowQueueColl().List(&commonrepo.ListWorfklowQueueOption{WorkflowName: workflowName})
+	if err != nil {
+		log.Errorf("list workflow queue of %s error: %v", workflowName, err)
+		return ""
+	}
+	for _, q := range queues {
+		if q.TaskID == taskID {
+			return q.Owner
+		}
+	}
+	return ""
+}
+
+func statusFinished(status config.Status) bool {
+	return status == config.StatusPassed || status == config.StatusFailed || status == config.StatusTimeout || status == config.StatusReject
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+// fakeLeaseRepo keeps the queue in memory and updates it with the same conditions as WorkflowQueueColl.
+type fakeLeaseRepo struct {
+	sync.Mutex
+	queues []*commonmodels.WorkflowQueue
+	tasks  []*commonmodels.WorkflowTask
+}
+
+func (r *fakeLeaseRepo) find(workflowName string, taskID int64) *commonmodels.WorkflowQueue {
+	for _, q := range r.queues {
+		if q.WorkflowName == workflowName && q.TaskID == taskID {
+			return q
+		}
+	}
+	return nil
+}
+
+func (r *fakeLeaseRepo) ClaimWaiting(args *commonmodels.WorkflowQueue, owner string, heartbeat int64) (bool, error) {
+	r.Lock()
+	defer r.Unlock()
+	q := r.find(args.WorkflowName, args.TaskID)
+	if q == nil || q.CreateTime != args.CreateTime || q.Status != config.StatusWaiting {
+		return false, nil
+	}
+	q.Status, q.Owner, q.HeartbeatTime = config.StatusQueued, owner, heartbeat
+	return true, nil
+}
+
+func (r *fakeLeaseRepo) RenewLease(workflowName string, taskID int64, owner string, heartbeat int64) (bool, error) {
+	r.Lock()
+	defer r.Unlock()
+	q := r.find(workflowName, taskID)
+	if q == nil || q.Owner != owner {
+		return false, nil
+	}
+	q.HeartbeatTime = heartbeat
+	return true, nil
+}
+
+func (r *fakeLeaseRepo) ListExpired(expireBefore int64) ([]*commonmodels.WorkflowQueue, error) {
+	r.Lock()
+	defer r.Unlock()
+	resp := make([]*commonmodels.WorkflowQueue, 0)
+	for _, q := range r.queues {
+		if q.Status == config.StatusWaiting || q.Status == config.StatusBlocked || q.Status == config.QueueItemPending {
+			continue
+		}
+		if q.HeartbeatTime < expireBefore {
+			item := *q
+			resp = append(resp, &item)
+		}
+	}
+	return resp, nil
+}
+
+func (r *fakeLeaseRepo) TakeOver(args *commonmodels.WorkflowQueue, owner string, heartbeat int64) (bool, error) {
+	r.Lock()
+	defer r.Unlock()
+	q := r.find(args.WorkflowName, args.TaskID)
+	if q == nil || q.CreateTime != args.CreateTime || q.HeartbeatTime != args.HeartbeatTime {
+		return false, nil
+	}
+	q.Owner, q.HeartbeatTime = owner, heartbeat
+	return true, nil
+}
+
+func (r *fakeLeaseRepo) List(opt *commonrepo.ListWorfklowQueueOption) ([]*commonmodels.WorkflowQueue, error) {
+	r.Lock()
+	defer r.Unlock()
+	resp := make([]*commonmodels.WorkflowQueue, 0)
+	for _, q := range r.queues {
+		if q.WorkflowName == opt.WorkflowName {
+			item := *q
+			resp = append(resp, &item)
+		}
+	}
+	return resp, nil
+}
+
+func (r *fakeLeaseRepo) Delete(args *commonmodels.WorkflowQueue) error {
+	r.Lock()
+	defer r.Unlock()
+	for i, q := range r.queues {
+		if q.WorkflowName == args.WorkflowName && q.TaskID == args.TaskID {
+			r.queues = append(r.queues[:i], r.queues[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *fakeLeaseRepo) FindTask(workflowName string, taskID int64) (*commonmodels.WorkflowTask, error) {
+	for _, t := range r.tasks {
+		if t.WorkflowName == workflowName && t.TaskID == taskID {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("workflow task %s:%d not found", workflowName, taskID)
+}
+
+// watchCancel registers a running task like workflowCtl.Run does, it returns the key and a function
+// which tells whether the task has been cancelled.
+func watchCancel(t *testing.T, workflowName string, taskID int64) (string, func() bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelKey := fmt.Sprintf("%s-%d", workflowName, taskID)
+	cancelChannelMap.Store(cancelKey, cancel)
+	t.Cleanup(func() {
+		cancelChannelMap.Delete(cancelKey)
+		cancel()
+	})
+	return cancelKey, func() bool { return ctx.Err() != nil }
+}
+
+func TestClaimWaitingTask(t *testing.T) {
+	task := &commonmodels.WorkflowTask{WorkflowName: "build", TaskID: 1, CreateTime: 100, Status: config.StatusWaiting}
+	repo := &fakeLeaseRepo{queues: []*commonmodels.WorkflowQueue{ConvertTaskToQueue(task)}}
+
+	claimed, err := claimWaitingTask(repo, task)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	assert.Equal(t, config.StatusQueued, task.Status)
+	assert.Equal(t, LeaseOwner(), repo.queues[0].Owner)
+	assert.NotZero(t, repo.queues[0].HeartbeatTime)
+
+	// another replica picked the same waiting task
+	other := &commonmodels.WorkflowTask{WorkflowName: "build", TaskID: 1, CreateTime: 100, Status: config.StatusWaiting}
+	claimed, err = claimWaitingTask(repo, other)
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+	assert.Equal(t, config.StatusWaiting, other.Status)
+}
+
+func TestRenewLease(t *testing.T) {
+	log.Init(&log.Config{Level: "error"})
+
+	task := &commonmodels.WorkflowTask{WorkflowName: "build", TaskID: 1, Status: config.StatusRunning}
+	repo := &fakeLeaseRepo{
+		queues: []*commonmodels.WorkflowQueue{{WorkflowName: "build", TaskID: 1, Status: config.StatusRunning, Owner: LeaseOwner(), HeartbeatTime: 1}},
+		tasks:  []*commonmodels.WorkflowTask{task},
+	}
+	cancelKey, cancelled := watchCancel(t, "build", 1)
+	lease := &ownedTask{WorkflowName: "build", TaskID: 1}
+
+	renewLease(repo, cancelKey, lease)
+	assert.Greater(t, repo.queues[0].HeartbeatTime, int64(1))
+	assert.False(t, lease.Lost())
+	assert.False(t, cancelled())
+
+	// the lease expired and was taken over by another replica
+	repo.queues[0].Owner = "replica-b"
+	renewLease(repo, cancelKey, lease)
+	assert.True(t, lease.Lost())
+	assert.True(t, cancelled())
+}
+
+func TestHandleLostLease(t *testing.T) {
+	log.Init(&log.Config{Level: "error"})
+
+	tests := []struct {
+		name          string
+		status        config.Status
+		queues        []*commonmodels.WorkflowQueue
+		wantLost      bool
+		wantCancelled bool
+	}{
+		{
+			name:          "taken over by another replica",
+			status:        config.StatusRunning,
+			queues:        []*commonmodels.WorkflowQueue{{WorkflowName: "build", TaskID: 1, Owner: "replica-b"}},
+			wantLost:      true,
+			wantCancelled: true,
+		},
+		{
+			name:          "cancelled on another replica",
+			status:        config.StatusCancelled,
+			wantCancelled: true,
+		},
+		{
+			name:   "finished normally",
+			status: config.StatusPassed,
+		},
+		{
+			name:   "running final stages after the queue item was removed",
+			status: config.StatusRunning,
+		},
+		{
+			name:   "still owned",
+			status: config.StatusRunning,
+			queues: []*commonmodels.WorkflowQueue{{WorkflowName: "build", TaskID: 1, Owner: LeaseOwner()}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeLeaseRepo{
+				queues: tt.queues,
+				tasks:  []*commonmodels.WorkflowTask{{WorkflowName: "build", TaskID: 1, Status: tt.status}},
+			}
+			cancelKey, cancelled := watchCancel(t, "build", 1)
+			lease := &ownedTask{WorkflowName: "build", TaskID: 1}
+
+			handleLostLease(repo, cancelKey, lease)
+			assert.Equal(t, tt.wantLost, lease.Lost())
+			assert.Equal(t, tt.wantCancelled, cancelled())
+		})
+	}
+}
+
+func TestTakeOverExpiredLeases(t *testing.T) {
+	now := time.Now().Unix()
+	repo := &fakeLeaseRepo{
+		queues: []*commonmodels.WorkflowQueue{
+			{WorkflowName: "expired", TaskID: 1, Status: config.StatusRunning, Owner: "replica-b", HeartbeatTime: 1},
+			{WorkflowName: "renewed", TaskID: 1, Status: config.StatusRunning, Owner: "replica-b", HeartbeatTime: now},
+			{WorkflowName: "finished", TaskID: 1, Status: config.StatusRunning, Owner: "replica-b", HeartbeatTime: 1},
+			{WorkflowName: "waiting", TaskID: 1, Status: config.StatusWaiting},
+		},
+		tasks: []*commonmodels.WorkflowTask{
+			{WorkflowName: "expired", TaskID: 1, Status: config.StatusRunning},
+			{WorkflowName: "renewed", TaskID: 1, Status: config.StatusRunning},
+			{WorkflowName: "finished", TaskID: 1, Status: config.StatusPassed},
+			{WorkflowName: "waiting", TaskID: 1, Status: config.StatusWaiting},
+		},
+	}
+
+	tasks := takeOverExpiredLeases(repo, log.NopSugaredLogger())
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, "expired", tasks[0].WorkflowName)
+	}
+	assert.Equal(t, LeaseOwner(), repo.find("expired", 1).Owner)
+	assert.Equal(t, "replica-b", repo.find("renewed", 1).Owner)
+	// the finished task left in the queue is removed instead of being resumed
+	assert.Nil(t, repo.find("finished", 1))
+	assert.Equal(t, "", repo.find("waiting", 1).Owner)
+
+	// the lease has been renewed by the take over, the task is not taken over twice
+	assert.Empty(t, takeOverExpiredLeases(repo, log.NopSugaredLogger()))
+}
@@ -104,32 +104,28 @@ func Push(t *commonmodels.WorkflowTask) error {
 func InitWorkflowController() {
 	InitQueue()
 	go WorfklowTaskSender()
+	go WorkflowTaskLeaseKeeper()
+	go WorkflowTaskMessageDeliverer()
 }
 
+// InitQueue cleans up the messages left by the previous aslan process and resumes the unfinished tasks.
+// Tasks are resumed through their queue leases so a task is never run twice: leases released by replicas
+// which shut down gracefully, and leases left by a crashed process with the same identity as this one,
+// are taken over at once, other tasks are taken over by WorkflowTaskLeaseKeeper after their leases expire.
 func InitQueue() error {
 	log := log.SugaredLogger()
 
-	// 从数据库查找未完成的任务
-	// status = created, running
-	tasks, err := commonrepo.NewworkflowTaskv4Coll().InCompletedTasks()
-	if err != nil {
-		log.Errorf("find [InCompletedTasks] error: %v", err)
-		return err
-	}
-
-	for _, task := range tasks {
-		// 如果 Queue 重新初始化, 取消所有 running tasks
-		if err := CancelWorkflowTask(setting.DefaultTaskRevoker, task.WorkflowName, task.TaskID, log); err != nil {
-			log.Errorf("[CancelRunningTask] error: %v", err)
-			continue
-		}
-	}
-
 	// clear all cancel pipeline task msgs when aslan restart
-	err = commonrepo.NewMsgQueueCommonColl().DeleteByQueueType(setting.TopicCancel)
+	err := commonrepo.NewMsgQueueCommonColl().DeleteByQueueType(setting.TopicCancel)
 	if err != nil {
 		log.Warnf("remove cancel msgs error: %v", err)
 	}
+
+	if err := commonrepo.NewWorkflowQueueColl().ReleaseLeases(LeaseOwner()); err != nil {
+		log.Errorf("release workflow task leases of %s error: %v", LeaseOwner(), err)
+		return err
+	}
+	takeOverExpiredTasks()
 	return nil
 }
 
@@ -151,31 +147,14 @@ func WorfklowTaskSender() {
 		if err != nil || len(waitingTasks) == 0 {
 			continue
 		}
+		limits, waitingTasks, orphans := QueueLimitsFromSettings(sysSetting, waitingTasks, commonrepo.NewWorkflowV4Coll().Find)
+		for _, task := range orphans {
+			Remove(task)
+		}
 		var t *commonmodels.WorkflowQueue
-		for _, task := range waitingTasks {
-			workflow, err := commonrepo.NewWorkflowV4Coll().Find(task.WorkflowName)
-			if err != nil {
-				log.Errorf("WorkflowV4 Queue: find workflow %s error: %v", task.WorkflowName, err)
-				Remove(task)
-				continue
-			}
-			// no concurrency limit, run task
-			if workflow.ConcurrencyLimit == -1 {
-				t = task
-				break
-			}
-			resp, err := RunningWorkflowTasks(task.WorkflowName)
-			if err != nil {
-				log.Errorf("WorkflowV4 Queue: find running workflow %s error: %v", task.WorkflowName, err)
-				continue
-			}
-			resp2, err := WaitForApproveWorkflowTasks(task.WorkflowName)
-			if err != nil {
-				log.Errorf("WorkflowV4 Queue: find waiting approve workflow %s error: %v", task.WorkflowName, err)
-				continue
-			}
-			if len(resp)+len(resp2) < workflow.ConcurrencyLimit {
-				t = task
+		for _, position := range ScheduleWaitingTasks(waitingTasks, ActiveTasks(), limits) {
+			if position.BlockedReason == "" {
+				t = position.WorkflowQueue
 				break
 			}
 		}
@@ -300,16 +279,30 @@ func updateQueueAndRunTask(t *commonmodels.WorkflowQueue, jobConcurrency int) er
 		logger.Errorf("%s:%d get workflow task error: %v", t.WorkflowName, t.TaskID, err)
 		return fmt.Errorf("%s:%d get workflow task error: %v", t.WorkflowName, t.TaskID, err)
 	}
-	workflowTask.Status = config.StatusQueued
-	if success := UpdateQueue(workflowTask); !success {
-		logger.Errorf("%s:%d update t status error", t.WorkflowName, t.TaskID)
-		return fmt.Errorf("%s:%d update t status error", t.WorkflowName, t.TaskID)
+	claimed, err := claimWaitingTask(newLeaseRepo(), workflowTask)
+	if err != nil {
+		logger.Errorf("%s:%d update t status error: %v", t.WorkflowName, t.TaskID, err)
+		return fmt.Errorf("%s:%d update t status error: %v", t.WorkflowName, t.TaskID, err)
+	}
+	if !claimed {
+		return fmt.Errorf("%s:%d has been claimed by another replica", t.WorkflowName, t.TaskID)
 	}
 	ctx := context.Background()
 	go NewWorkflowController(workflowTask, logger).Run(ctx, jobConcurrency)
 	return nil
 }
 
+// claimWaitingTask marks the waiting task as queued and owned by this replica, other aslan replicas may pick
+// the same waiting task, only the one claimed it can run the task.
+func claimWaitingTask(repo leaseRepo, workflowTask *commonmodels.WorkflowTask) (bool, error) {
+	claimed, err := repo.ClaimWaiting(ConvertTaskToQueue(workflowTask), LeaseOwner(), time.Now().Unix())
+	if err != nil || !claimed {
+		return false, err
+	}
+	workflowTask.Status = config.StatusQueued
+	return true, nil
+}
+
 func UpdateQueue(task *commonmodels.WorkflowTask) bool {
 	if err := commonrepo.NewWorkflowQueueColl().Update(ConvertTaskToQueue(task)); err != nil {
 		return false
@@ -328,6 +321,7 @@ func ConvertTaskToQueue(task *commonmodels.WorkflowTask) *commonmodels.WorkflowQ
 		TaskCreator:         task.TaskCreator,
 		TaskRevoker:         task.TaskRevoker,
 		CreateTime:          task.CreateTime,
+		PriorityClass:       task.PriorityClass,
 	}
 }
 
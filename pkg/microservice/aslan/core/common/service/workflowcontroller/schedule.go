@@ -0,0 +1,198 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+// QueueLimits are the concurrency limits applied when scheduling the waiting tasks.
+type QueueLimits struct {
+	// WorkflowConcurrency is the global limit of the running and queued tasks
+	WorkflowConcurrency int
+	// ProjectConcurrency is the limit of the active tasks of each project, 0 means no limit
+	ProjectConcurrency        map[string]int
+	DefaultProjectConcurrency int
+	// WorkflowLimits is the ConcurrencyLimit of each workflow, -1 means no limit
+	WorkflowLimits map[string]int
+}
+
+func (l *QueueLimits) projectLimit(projectName string) int {
+	if limit, ok := l.ProjectConcurrency[projectName]; ok {
+		return limit
+	}
+	return l.DefaultProjectConcurrency
+}
+
+// QueuePosition is a waiting task with its position in the queue, BlockedReason is empty if the task can be run now.
+type QueuePosition struct {
+	*commonmodels.WorkflowQueue
+	Position      int                       `json:"position"`
+	BlockedReason config.QueueBlockedReason `json:"blocked_reason,omitempty"`
+}
+
+// ReleasePriorityAllowed tells whether the tasks of the project can use the release priority class.
+func ReleasePriorityAllowed(sysSetting *commonmodels.SystemSetting, projectName string) bool {
+	return sets.NewString(sysSetting.ReleasePriorityProjects...).Has(projectName)
+}
+
+// TaskPriorityClass resolves the priority class of a new task, the class of the workflow (which may have been
+// overridden by the trigger) wins over the class derived from the task creator. The release class is ignored
+// unless the project is allowed to use it.
+func TaskPriorityClass(workflowClass config.PriorityClass, taskCreator string, releaseAllowed bool) config.PriorityClass {
+	if workflowClass.Valid() && (workflowClass != config.PriorityClassRelease || releaseAllowed) {
+		return workflowClass
+	}
+	switch taskCreator {
+	case setting.CronTaskCreator:
+		return config.PriorityClassCron
+	case setting.WebhookTaskCreator, setting.GeneralHookTaskCreator, setting.JiraHookTaskCreator,
+		setting.MeegoHookTaskCreator, setting.WorkflowTriggerTaskCreator:
+		return config.PriorityClassWebhook
+	default:
+		return config.PriorityClassManual
+	}
+}
+
+// QueuePriorityClass returns the priority class of a queue item.
+func QueuePriorityClass(t *commonmodels.WorkflowQueue) config.PriorityClass {
+	if t.PriorityClass.Valid() {
+		return t.PriorityClass
+	}
+	// tasks created before the priority classes were introduced
+	return TaskPriorityClass("", t.TaskCreator, false)
+}
+
+// ScheduleWaitingTasks orders the waiting tasks and tells whether each of them can be run under the limits.
+// Tasks with a higher priority class go first. Among tasks of the same class, the project with fewer active
+// tasks goes first so that one busy project can not starve the others, then the earlier task goes first.
+// The tasks which can be run are counted as active when deciding the tasks behind them.
+func ScheduleWaitingTasks(waiting, active []*commonmodels.WorkflowQueue, limits *QueueLimits) []*QueuePosition {
+	globalCount := 0
+	projectCount := make(map[string]int)
+	workflowCount := make(map[string]int)
+	for _, t := range active {
+		// waiting for approval does not occupy the global concurrency, but it counts in the project and the workflow
+		if t.Status == config.StatusRunning || t.Status == config.StatusQueued {
+			globalCount++
+		}
+		projectCount[t.ProjectName]++
+		workflowCount[t.WorkflowName]++
+	}
+
+	remaining := make([]*commonmodels.WorkflowQueue, len(waiting))
+	copy(remaining, waiting)
+	resp := make([]*QueuePosition, 0, len(waiting))
+	for len(remaining) > 0 {
+		sort.SliceStable(remaining, func(i, j int) bool {
+			pi, pj := QueuePriorityClass(remaining[i]).Weight(), QueuePriorityClass(remaining[j]).Weight()
+			if pi != pj {
+				return pi > pj
+			}
+			ci, cj := projectCount[remaining[i].ProjectName], projectCount[remaining[j].ProjectName]
+			if ci != cj {
+				return ci < cj
+			}
+			return remaining[i].CreateTime < remaining[j].CreateTime
+		})
+		t := remaining[0]
+		remaining = remaining[1:]
+
+		position := &QueuePosition{WorkflowQueue: t, Position: len(resp) + 1}
+		workflowLimit, ok := limits.WorkflowLimits[t.WorkflowName]
+		if !ok {
+			workflowLimit = -1
+		}
+		projectLimit := limits.projectLimit(t.ProjectName)
+		switch {
+		case workflowLimit != -1 && workflowCount[t.WorkflowName] >= workflowLimit:
+			position.BlockedReason = config.QueueBlockedByWorkflowLimit
+		case projectLimit > 0 && projectCount[t.ProjectName] >= projectLimit:
+			position.BlockedReason = config.QueueBlockedByProjectQuota
+		case globalCount >= limits.WorkflowConcurrency:
+			position.BlockedReason = config.QueueBlockedByGlobalLimit
+		default:
+			globalCount++
+			projectCount[t.ProjectName]++
+			workflowCount[t.WorkflowName]++
+		}
+		resp = append(resp, position)
+	}
+	return resp
+}
+
+// QueueLimitsFromSettings builds the limits of the waiting tasks, the tasks whose workflow can not be found by
+// findWorkflow are returned separately.
+func QueueLimitsFromSettings(sysSetting *commonmodels.SystemSetting, waiting []*commonmodels.WorkflowQueue, findWorkflow func(name string) (*commonmodels.WorkflowV4, error)) (limits *QueueLimits, valid, orphans []*commonmodels.WorkflowQueue) {
+	limits = &QueueLimits{
+		WorkflowConcurrency:       int(sysSetting.WorkflowConcurrency),
+		ProjectConcurrency:        make(map[string]int),
+		DefaultProjectConcurrency: int(sysSetting.DefaultProjectConcurrency),
+		WorkflowLimits:            make(map[string]int),
+	}
+	for _, p := range sysSetting.ProjectConcurrency {
+		limits.ProjectConcurrency[p.ProjectName] = int(p.WorkflowConcurrency)
+	}
+
+	valid = make([]*commonmodels.WorkflowQueue, 0, len(waiting))
+	for _, task := range waiting {
+		if _, ok := limits.WorkflowLimits[task.WorkflowName]; !ok {
+			workflow, err := findWorkflow(task.WorkflowName)
+			if err != nil {
+				log.Errorf("WorkflowV4 Queue: find workflow %s error: %v", task.WorkflowName, err)
+				orphans = append(orphans, task)
+				continue
+			}
+			limits.WorkflowLimits[task.WorkflowName] = workflow.ConcurrencyLimit
+		}
+		valid = append(valid, task)
+	}
+	return limits, valid, orphans
+}
+
+// ActiveTasks returns the tasks occupying the concurrency: queued, running or waiting for approval.
+func ActiveTasks() []*commonmodels.WorkflowQueue {
+	tasks := make([]*commonmodels.WorkflowQueue, 0)
+	for _, t := range ListTasks() {
+		if t.Status == config.StatusRunning || t.Status == config.StatusQueued || t.Status == config.StatusWaitingApprove {
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks
+}
+
+// WaitingTaskPositions returns the positions of all the waiting tasks.
+func WaitingTaskPositions() ([]*QueuePosition, error) {
+	sysSetting, err := commonrepo.NewSystemSettingColl().Get()
+	if err != nil {
+		return nil, err
+	}
+	waiting, err := commonrepo.NewWorkflowQueueColl().List(&commonrepo.ListWorfklowQueueOption{Status: config.StatusWaiting})
+	if err != nil {
+		return nil, err
+	}
+	limits, waiting, _ := QueueLimitsFromSettings(sysSetting, waiting, commonrepo.NewWorkflowV4Coll().Find)
+	return ScheduleWaitingTasks(waiting, ActiveTasks(), limits), nil
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+func TestTaskPriorityClass(t *testing.T) {
+	tests := []struct {
+		name           string
+		workflowClass  config.PriorityClass
+		taskCreator    string
+		releaseAllowed bool
+		want           config.PriorityClass
+	}{
+		{name: "manual run", taskCreator: "alice", want: config.PriorityClassManual},
+		{name: "cron", taskCreator: setting.CronTaskCreator, want: config.PriorityClassCron},
+		{name: "webhook", taskCreator: setting.WebhookTaskCreator, want: config.PriorityClassWebhook},
+		{name: "workflow trigger", taskCreator: setting.WorkflowTriggerTaskCreator, want: config.PriorityClassWebhook},
+		{name: "workflow class wins", workflowClass: config.PriorityClassCron, taskCreator: "alice", want: config.PriorityClassCron},
+		{name: "invalid workflow class", workflowClass: "urgent", taskCreator: setting.CronTaskCreator, want: config.PriorityClassCron},
+		{name: "allowed release", workflowClass: config.PriorityClassRelease, taskCreator: setting.CronTaskCreator, releaseAllowed: true, want: config.PriorityClassRelease},
+		{name: "release not allowed", workflowClass: config.PriorityClassRelease, taskCreator: setting.CronTaskCreator, want: config.PriorityClassCron},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, TaskPriorityClass(tt.workflowClass, tt.taskCreator, tt.releaseAllowed))
+		})
+	}
+}
+
+func TestReleasePriorityAllowed(t *testing.T) {
+	sysSetting := &commonmodels.SystemSetting{ReleasePriorityProjects: []string{"release-train"}}
+
+	assert.True(t, ReleasePriorityAllowed(sysSetting, "release-train"))
+	assert.False(t, ReleasePriorityAllowed(sysSetting, "other"))
+	assert.False(t, ReleasePriorityAllowed(&commonmodels.SystemSetting{}, "release-train"))
+}
+
+func TestScheduleWaitingTasks(t *testing.T) {
+	queueItem := func(project, workflow string, taskID int64, class config.PriorityClass, createTime int64) *commonmodels.WorkflowQueue {
+		return &commonmodels.WorkflowQueue{
+			ProjectName:   project,
+			WorkflowName:  workflow,
+			TaskID:        taskID,
+			TaskCreator:   "alice",
+			PriorityClass: class,
+			Status:        config.StatusWaiting,
+			CreateTime:    createTime,
+		}
+	}
+	type scheduled struct {
+		WorkflowName  string
+		TaskID        int64
+		BlockedReason config.QueueBlockedReason
+	}
+
+	tests := []struct {
+		name    string
+		waiting []*commonmodels.WorkflowQueue
+		active  []*commonmodels.WorkflowQueue
+		limits  *QueueLimits
+		want    []scheduled
+	}{
+		{
+			name: "higher priority class goes first",
+			waiting: []*commonmodels.WorkflowQueue{
+				queueItem("a", "wa", 1, config.PriorityClassCron, 1),
+				queueItem("a", "wa", 2, config.PriorityClassRelease, 2),
+				queueItem("a", "wa", 3, config.PriorityClassWebhook, 3),
+			},
+			limits: &QueueLimits{WorkflowConcurrency: 10},
+			want:   []scheduled{{"wa", 2, ""}, {"wa", 3, ""}, {"wa", 1, ""}},
+		},
+		{
+			name: "legacy tasks use the class of the creator",
+			waiting: []*commonmodels.WorkflowQueue{
+				{ProjectName: "a", WorkflowName: "wa", TaskID: 1, TaskCreator: setting.CronTaskCreator, CreateTime: 1},
+				{ProjectName: "a", WorkflowName: "wa", TaskID: 2, TaskCreator: "alice", CreateTime: 2},
+			},
+			limits: &QueueLimits{WorkflowConcurrency: 10},
+			want:   []scheduled{{"wa", 2, ""}, {"wa", 1, ""}},
+		},
+		{
+			name: "fair share among projects of the same class",
+			waiting: []*commonmodels.WorkflowQueue{
+				queueItem("a", "wa", 1, config.PriorityClassManual, 1),
+				queueItem("a", "wa", 2, config.PriorityClassManual, 2),
+				queueItem("b", "wb", 1, config.PriorityClassManual, 3),
+			},
+			active: []*commonmodels.WorkflowQueue{
+				{ProjectName: "a", WorkflowName: "wa", TaskID: 0, Status: config.StatusRunning},
+			},
+			limits: &QueueLimits{WorkflowConcurrency: 10},
+			want:   []scheduled{{"wb", 1, ""}, {"wa", 1, ""}, {"wa", 2, ""}},
+		},
+		{
+			name: "global limit",
+			waiting: []*commonmodels.WorkflowQueue{
+				queueItem("a", "wa", 1, config.PriorityClassManual, 1),
+				queueItem("b", "wb", 1, config.PriorityClassManual, 2),
+			},
+			active: []*commonmodels.WorkflowQueue{
+				{ProjectName: "c", WorkflowName: "wc", Status: config.StatusRunning},
+				// waiting for approval does not occupy the global concurrency
+				{ProjectName: "c", WorkflowName: "wc", Status: config.StatusWaitingApprove},
+			},
+			limits: &QueueLimits{WorkflowConcurrency: 2},
+			want:   []scheduled{{"wa", 1, ""}, {"wb", 1, config.QueueBlockedByGlobalLimit}},
+		},
+		{
+			name: "project quota",
+			waiting: []*commonmodels.WorkflowQueue{
+				queueItem("a", "wa", 1, config.PriorityClassRelease, 1),
+				queueItem("b", "wb", 1, config.PriorityClassManual, 2),
+				queueItem("c", "wc", 1, config.PriorityClassManual, 3),
+			},
+			active: []*commonmodels.WorkflowQueue{
+				{ProjectName: "a", WorkflowName: "wa2", Status: config.StatusWaitingApprove},
+				{ProjectName: "c", WorkflowName: "wc2", Status: config.StatusRunning},
+			},
+			limits: &QueueLimits{
+				WorkflowConcurrency:       10,
+				ProjectConcurrency:        map[string]int{"a": 1, "b": 0},
+				DefaultProjectConcurrency: 2,
+			},
+			want: []scheduled{{"wa", 1, config.QueueBlockedByProjectQuota}, {"wb", 1, ""}, {"wc", 1, ""}},
+		},
+		{
+			name: "workflow limit",
+			waiting: []*commonmodels.WorkflowQueue{
+				queueItem("a", "wa", 1, config.PriorityClassManual, 1),
+				queueItem("a", "wa", 2, config.PriorityClassManual, 2),
+				queueItem("a", "wb", 1, config.PriorityClassManual, 3),
+			},
+			limits: &QueueLimits{
+				WorkflowConcurrency: 10,
+				WorkflowLimits:      map[string]int{"wa": 1, "wb": -1},
+			},
+			want: []scheduled{{"wa", 1, ""}, {"wa", 2, config.QueueBlockedByWorkflowLimit}, {"wb", 1, ""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			positions := ScheduleWaitingTasks(tt.waiting, tt.active, tt.limits)
+			got := make([]scheduled, 0, len(positions))
+			for i, position := range positions {
+				assert.Equal(t, i+1, position.Position)
+				got = append(got, scheduled{position.WorkflowName, position.TaskID, position.BlockedReason})
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestQueueLimitsFromSettings(t *testing.T) {
+	log.Init(&log.Config{Level: "error"})
+
+	sysSetting := &commonmodels.SystemSetting{
+		WorkflowConcurrency:       5,
+		DefaultProjectConcurrency: 2,
+		ProjectConcurrency: []*commonmodels.ProjectConcurrency{
+			{ProjectName: "a", WorkflowConcurrency: 3},
+			{ProjectName: "b", WorkflowConcurrency: 0},
+		},
+	}
+	waiting := []*commonmodels.WorkflowQueue{
+		{ProjectName: "a", WorkflowName: "wa", TaskID: 1},
+		{ProjectName: "a", WorkflowName: "wa", TaskID: 2},
+		{ProjectName: "b", WorkflowName: "deleted", TaskID: 1},
+		{ProjectName: "c", WorkflowName: "wc", TaskID: 1},
+	}
+	lookups := map[string]int{}
+	findWorkflow := func(name string) (*commonmodels.WorkflowV4, error) {
+		lookups[name]++
+		switch name {
+		case "wa":
+			return &commonmodels.WorkflowV4{Name: name, ConcurrencyLimit: 1}, nil
+		case "wc":
+			return &commonmodels.WorkflowV4{Name: name, ConcurrencyLimit: -1}, nil
+		default:
+			return nil, errors.New("not found")
+		}
+	}
+
+	limits, valid, orphans := QueueLimitsFromSettings(sysSetting, waiting, findWorkflow)
+
+	assert.Equal(t, 5, limits.WorkflowConcurrency)
+	assert.Equal(t, map[string]int{"a": 3, "b": 0}, limits.ProjectConcurrency)
+	assert.Equal(t, map[string]int{"wa": 1, "wc": -1}, limits.WorkflowLimits)
+	assert.Equal(t, 3, limits.projectLimit("a"))
+	assert.Equal(t, 0, limits.projectLimit("b"))
+	assert.Equal(t, 2, limits.projectLimit("c"))
+	assert.Equal(t, []*commonmodels.WorkflowQueue{waiting[0], waiting[1], waiting[3]}, valid)
+	assert.Equal(t, []*commonmodels.WorkflowQueue{waiting[2]}, orphans)
+	// each workflow is looked up once
+	assert.Equal(t, 1, lookups["wa"])
+}
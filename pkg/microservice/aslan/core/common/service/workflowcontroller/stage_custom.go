@@ -35,11 +35,13 @@ type CustomStageCtl struct {
 	workflowCtx *commonmodels.WorkflowTaskCtx
 	logger      *zap.SugaredLogger
 	ack         func()
+	failure     *jobcontroller.FailureTracker
 }
 
-func NewCustomStageCtl(stage *commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) *CustomStageCtl {
+func NewCustomStageCtl(stage *commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, failure *jobcontroller.FailureTracker, logger *zap.SugaredLogger, ack func()) *CustomStageCtl {
 	return &CustomStageCtl{
 		stage:       stage,
+		failure:     failure,
 		logger:      logger,
 		workflowCtx: workflowCtx,
 		ack:         ack,
@@ -56,7 +58,7 @@ func (c *CustomStageCtl) Run(ctx context.Context, concurrency int) {
 		}
 
 	}
-	jobcontroller.RunJobs(ctx, c.stage.Jobs, c.workflowCtx, workerConcurrency, c.logger, c.ack)
+	jobcontroller.RunJobs(ctx, c.stage.Jobs, c.workflowCtx, workerConcurrency, c.failure, c.logger, c.ack)
 }
 
 func (c *CustomStageCtl) AfterRun() {
@@ -0,0 +1,101 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/setting"
+)
+
+var (
+	// WorkflowTaskStatusKey is the final status of the main stages, available to the jobs of the final stages.
+	WorkflowTaskStatusKey = fmt.Sprintf(setting.RenderValueTemplate, "workflow.task.status")
+	// WorkflowTaskFailedJobKey is the name of the first failed job of the main stages.
+	WorkflowTaskFailedJobKey = fmt.Sprintf(setting.RenderValueTemplate, "workflow.task.failed_job")
+)
+
+func splitFinalStages(stages []*commonmodels.StageTask) (mainStages, finalStages []*commonmodels.StageTask) {
+	for _, stage := range stages {
+		if stage.RunOn == config.StageRunOnMain {
+			mainStages = append(mainStages, stage)
+		} else {
+			finalStages = append(finalStages, stage)
+		}
+	}
+	return
+}
+
+func finalStageMatched(runOn config.StageRunOn, status config.Status) bool {
+	switch runOn {
+	case config.StageRunOnAlways:
+		return true
+	case config.StageRunOnCancel:
+		return status == config.StatusCancelled
+	case config.StageRunOnFailure:
+		return status == config.StatusFailed || status == config.StatusTimeout || status == config.StatusReject
+	}
+	return false
+}
+
+// runFinalStages runs the final stages matching the status of the main stages. They run even if the task was
+// cancelled, and their status changes the workflow status only when they fail.
+func (c *workflowCtl) runFinalStages(stages []*commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int) {
+	status := c.workflowTask.Status
+	matched := make([]*commonmodels.StageTask, 0)
+	for _, stage := range stages {
+		if finalStageMatched(stage.RunOn, status) {
+			matched = append(matched, stage)
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+
+	c.setGlobalContext(WorkflowTaskStatusKey, string(status))
+	c.setGlobalContext(WorkflowTaskFailedJobKey, c.failedJobName())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if status == config.StatusCancelled {
+		// the task has been cancelled in db, keep the status until the final stages finished
+		c.finalizing = true
+	} else {
+		c.workflowTask.Status = config.StatusRunning
+		cancelChannelMap.Store(fmt.Sprintf("%s-%d", c.workflowTask.WorkflowName, c.workflowTask.TaskID), cancel)
+	}
+	c.ack()
+	c.logger.Infof("run final stages of workflow: %s, status: %s", c.workflowTask.WorkflowName, status)
+
+	RunStages(ctx, matched, workflowCtx, concurrency, c.logger, c.ack)
+	c.finalizing = false
+	updateworkflowStatus(c.workflowTask)
+}
+
+func (c *workflowCtl) failedJobName() string {
+	for _, stage := range c.workflowTask.Stages {
+		for _, job := range stage.Jobs {
+			if statusFailed(job.Status) {
+				return job.Name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+func TestSplitFinalStages(t *testing.T) {
+	build := &commonmodels.StageTask{Name: "build"}
+	deploy := &commonmodels.StageTask{Name: "deploy", RunOn: config.StageRunOnMain}
+	notify := &commonmodels.StageTask{Name: "notify", RunOn: config.StageRunOnFailure}
+	cleanup := &commonmodels.StageTask{Name: "cleanup", RunOn: config.StageRunOnAlways}
+
+	mainStages, finalStages := splitFinalStages([]*commonmodels.StageTask{build, deploy, notify, cleanup})
+	assert.Equal(t, []*commonmodels.StageTask{build, deploy}, mainStages)
+	assert.Equal(t, []*commonmodels.StageTask{notify, cleanup}, finalStages)
+
+	mainStages, finalStages = splitFinalStages([]*commonmodels.StageTask{build, deploy})
+	assert.Equal(t, []*commonmodels.StageTask{build, deploy}, mainStages)
+	assert.Empty(t, finalStages)
+}
+
+func TestFinalStageMatched(t *testing.T) {
+	tests := []struct {
+		runOn     config.StageRunOn
+		passed    bool
+		failed    bool
+		cancelled bool
+	}{
+		{runOn: config.StageRunOnMain},
+		{runOn: config.StageRunOnFailure, failed: true},
+		{runOn: config.StageRunOnCancel, cancelled: true},
+		{runOn: config.StageRunOnAlways, passed: true, failed: true, cancelled: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.runOn), func(t *testing.T) {
+			assert.Equal(t, tt.passed, finalStageMatched(tt.runOn, config.StatusPassed))
+			assert.Equal(t, tt.failed, finalStageMatched(tt.runOn, config.StatusFailed))
+			assert.Equal(t, tt.cancelled, finalStageMatched(tt.runOn, config.StatusCancelled))
+		})
+	}
+	// timeout and reject count as failures
+	assert.True(t, finalStageMatched(config.StageRunOnFailure, config.StatusTimeout))
+	assert.True(t, finalStageMatched(config.StageRunOnFailure, config.StatusReject))
+}
+
+func TestRunFinalStages(t *testing.T) {
+	// the final stages have a when expression which is false, so they are skipped once they are run
+	finalStages := func() []*commonmodels.StageTask {
+		return []*commonmodels.StageTask{
+			{Name: "notify", RunOn: config.StageRunOnFailure, When: "false", Jobs: []*commonmodels.JobTask{{Name: "notify"}}},
+			{Name: "rollback", RunOn: config.StageRunOnCancel, When: "false", Jobs: []*commonmodels.JobTask{{Name: "rollback"}}},
+		}
+	}
+	tests := []struct {
+		name       string
+		status     config.Status
+		wantRun    []bool
+		wantStatus config.Status
+	}{
+		{name: "passed", status: config.StatusPassed, wantRun: []bool{false, false}, wantStatus: config.StatusPassed},
+		{name: "failed", status: config.StatusFailed, wantRun: []bool{true, false}, wantStatus: config.StatusFailed},
+		{name: "cancelled", status: config.StatusCancelled, wantRun: []bool{false, true}, wantStatus: config.StatusCancelled},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mainStage := &commonmodels.StageTask{Name: "build", Status: tt.status, Jobs: []*commonmodels.JobTask{{Name: "build", Status: tt.status}}}
+			stages := finalStages()
+			task := &commonmodels.WorkflowTask{
+				WorkflowName:  "workflow",
+				TaskID:        int64(i),
+				Status:        tt.status,
+				Stages:        append([]*commonmodels.StageTask{mainStage}, stages...),
+				GlobalContext: map[string]string{},
+			}
+			c := &workflowCtl{workflowTask: task, logger: log.NopSugaredLogger(), ack: func() {}}
+			workflowCtx := &commonmodels.WorkflowTaskCtx{
+				WorkflowName:      "workflow",
+				TaskID:            task.TaskID,
+				EvaluateCondition: func(when string) (bool, error) { return false, nil },
+			}
+			t.Cleanup(func() { cancelChannelMap.Delete(fmt.Sprintf("workflow-%d", task.TaskID)) })
+
+			c.runFinalStages(stages, workflowCtx, 1)
+
+			for j, stage := range stages {
+				if tt.wantRun[j] {
+					assert.Equal(t, config.StatusSkipped, stage.Status, stage.Name)
+				} else {
+					assert.Equal(t, config.Status(""), stage.Status, stage.Name)
+				}
+			}
+			assert.Equal(t, tt.wantStatus, task.Status)
+			assert.False(t, c.finalizing)
+			status, ok := c.getGlobalContext(WorkflowTaskStatusKey)
+			if tt.status == config.StatusPassed {
+				assert.False(t, ok)
+				return
+			}
+			assert.Equal(t, string(tt.status), status)
+			failedJob, _ := c.getGlobalContext(WorkflowTaskFailedJobKey)
+			assert.Equal(t, "build", failedJob)
+		})
+	}
+}
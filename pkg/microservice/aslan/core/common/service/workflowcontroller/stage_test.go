@@ -0,0 +1,75 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+func TestRunStagesResumed(t *testing.T) {
+	failedStage := &commonmodels.StageTask{
+		Name:      "build",
+		Status:    config.StatusFailed,
+		StartTime: 1,
+		EndTime:   2,
+		Jobs:      []*commonmodels.JobTask{{Name: "build", Status: config.StatusFailed, StartTime: 1, EndTime: 2}},
+	}
+	pendingStage := &commonmodels.StageTask{
+		Name: "deploy",
+		Jobs: []*commonmodels.JobTask{{Name: "deploy"}},
+	}
+	conditionStage := &commonmodels.StageTask{
+		Name: "release",
+		When: "success()",
+		Jobs: []*commonmodels.JobTask{{Name: "release"}},
+	}
+	workflowCtx := &commonmodels.WorkflowTaskCtx{
+		Resumed: true,
+		EvaluateCondition: func(when string) (bool, error) {
+			return when == "failure()", nil
+		},
+	}
+
+	RunStages(context.Background(), []*commonmodels.StageTask{failedStage, pendingStage, conditionStage}, workflowCtx, 1, log.NopSugaredLogger(), func() {})
+
+	// the failed stage is not run again
+	assert.Equal(t, config.StatusFailed, failedStage.Status)
+	assert.Equal(t, int64(1), failedStage.StartTime)
+	assert.Equal(t, config.StatusFailed, failedStage.Jobs[0].Status)
+	assert.Equal(t, int64(1), failedStage.Jobs[0].StartTime)
+	// the failure recorded before the restart still stops the stages without a when expression
+	assert.Equal(t, config.Status(""), pendingStage.Status)
+	assert.Equal(t, config.Status(""), pendingStage.Jobs[0].Status)
+	assert.Equal(t, config.StatusSkipped, conditionStage.Status)
+	assert.Equal(t, config.StatusSkipped, conditionStage.Jobs[0].Status)
+}
+
+func TestStatusCompleted(t *testing.T) {
+	for _, status := range []config.Status{config.StatusPassed, config.StatusFailed, config.StatusTimeout, config.StatusSkipped, config.StatusReject, config.StatusCancelled} {
+		assert.True(t, statusCompleted(status), status)
+	}
+	for _, status := range []config.Status{"", config.StatusCreated, config.StatusPrepare, config.StatusRunning, config.StatusWaitingApprove} {
+		assert.False(t, statusCompleted(status), status)
+	}
+}
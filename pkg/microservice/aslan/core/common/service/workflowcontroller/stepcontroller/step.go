@@ -82,13 +82,19 @@ func instantiateStepCtl(step *commonmodels.StepTask, workflowCtx *commonmodels.W
 	case config.StepArchive:
 		stepCtl, err = NewArchiveCtl(step, logger)
 	case config.StepJunitReport:
-		stepCtl, err = NewJunitReportCtl(step, logger)
+		stepCtl, err = NewJunitReportCtl(step, workflowCtx, jobName, logger)
 	case config.StepTarArchive:
 		stepCtl, err = NewTarArchiveCtl(step, logger)
 	case config.StepSonarCheck:
 		stepCtl, err = NewSonarCheckCtl(step, logger)
 	case config.StepDistributeImage:
 		stepCtl, err = NewDistributeCtl(step, workflowCtx, jobName, logger)
+	case config.StepCacheRestore, config.StepCacheSave:
+		stepCtl, err = NewCacheCtl(step, workflowCtx, jobName, logger)
+	case config.StepSupplyChain:
+		stepCtl, err = NewSupplyChainCtl(step, logger)
+	case config.StepVulnerabilityScan:
+		stepCtl, err = NewVulnerabilityScanCtl(step, workflowCtx, jobName, logger)
 	case config.StepDebugBefore, config.StepDebugAfter:
 		stepCtl, err = NewDebugCtl()
 	default:
@@ -31,6 +31,7 @@ import (
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/s3"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/testreport"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/log"
 	s3tool "github.com/koderover/zadig/pkg/tool/s3"
@@ -41,10 +42,12 @@ import (
 type junitReportCtl struct {
 	step            *commonmodels.StepTask
 	junitReportSpec *step.StepJunitReportSpec
+	workflowCtx     *commonmodels.WorkflowTaskCtx
+	jobName         string
 	log             *zap.SugaredLogger
 }
 
-func NewJunitReportCtl(stepTask *commonmodels.StepTask, log *zap.SugaredLogger) (*junitReportCtl, error) {
+func NewJunitReportCtl(stepTask *commonmodels.StepTask, workflowCtx *commonmodels.WorkflowTaskCtx, jobName string, log *zap.SugaredLogger) (*junitReportCtl, error) {
 	yamlString, err := yaml.Marshal(stepTask.Spec)
 	if err != nil {
 		return nil, fmt.Errorf("marshal junit report spec error: %v", err)
@@ -54,7 +57,7 @@ func NewJunitReportCtl(stepTask *commonmodels.StepTask, log *zap.SugaredLogger)
 		return nil, fmt.Errorf("unmarshal junit report spec error: %v", err)
 	}
 	stepTask.Spec = junitReportSpec
-	return &junitReportCtl{junitReportSpec: junitReportSpec, log: log, step: stepTask}, nil
+	return &junitReportCtl{junitReportSpec: junitReportSpec, workflowCtx: workflowCtx, jobName: jobName, log: log, step: stepTask}, nil
 }
 
 func (s *junitReportCtl) PreRun(ctx context.Context) error {
@@ -73,6 +76,9 @@ func (s *junitReportCtl) AfterRun(ctx context.Context) error {
 	if s.junitReportSpec.TestName == "" {
 		return nil
 	}
+	if err := s.saveTestResult(); err != nil {
+		s.log.Warnf("failed to save test result of job %s: %s", s.jobName, err)
+	}
 	var testTaskStat *commonmodels.TestTaskStat
 	var isNew bool
 	testTaskStat, _ = commonrepo.NewTestTaskStatColl().FindTestTaskStat(&commonrepo.TestTaskStatOption{Name: s.junitReportSpec.TestName})
@@ -133,3 +139,21 @@ func (s *junitReportCtl) AfterRun(ctx context.Context) error {
 	}
 	return nil
 }
+
+func (s *junitReportCtl) saveTestResult() error {
+	report, err := testreport.DownloadTestResult(s.junitReportSpec.S3DestDir)
+	if err != nil {
+		return err
+	}
+	return testreport.SaveTestResult(&testreport.TestJobInfo{
+		ProjectName:  s.workflowCtx.ProjectName,
+		WorkflowName: s.workflowCtx.WorkflowName,
+		TaskID:       s.workflowCtx.TaskID,
+		JobName:      s.jobName,
+		TestName:     s.junitReportSpec.TestName,
+		RepoName:     s.junitReportSpec.RepoName,
+		Branch:       s.junitReportSpec.Branch,
+		PR:           s.junitReportSpec.PR,
+		Commit:       s.junitReportSpec.CommitID,
+	}, report)
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stepcontroller
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+type supplyChainCtl struct {
+	step            *commonmodels.StepTask
+	supplyChainSpec *step.StepSupplyChainSpec
+	log             *zap.SugaredLogger
+}
+
+func NewSupplyChainCtl(stepTask *commonmodels.StepTask, log *zap.SugaredLogger) (*supplyChainCtl, error) {
+	yamlString, err := yaml.Marshal(stepTask.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal supply chain spec error: %v", err)
+	}
+	supplyChainSpec := &step.StepSupplyChainSpec{}
+	if err := yaml.Unmarshal(yamlString, &supplyChainSpec); err != nil {
+		return nil, fmt.Errorf("unmarshal supply chain spec error: %v", err)
+	}
+	stepTask.Spec = supplyChainSpec
+	return &supplyChainCtl{supplyChainSpec: supplyChainSpec, log: log, step: stepTask}, nil
+}
+
+// PreRun makes sure the signing key still exists when the job starts, the key itself is mounted into the job from
+// a secret created by the job controller, so it never shows up in the step spec.
+func (s *supplyChainCtl) PreRun(ctx context.Context) error {
+	if !s.supplyChainSpec.NeedKey() {
+		return nil
+	}
+	if _, err := commonrepo.NewSigningKeyColl().Find(s.supplyChainSpec.SigningKeyID); err != nil {
+		return fmt.Errorf("find signing key %s error: %v", s.supplyChainSpec.SigningKeyID, err)
+	}
+	return nil
+}
+
+func (s *supplyChainCtl) AfterRun(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stepcontroller
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/vulnerability"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+type vulnerabilityScanCtl struct {
+	step                  *commonmodels.StepTask
+	vulnerabilityScanSpec *step.StepVulnerabilityScanSpec
+	workflowCtx           *commonmodels.WorkflowTaskCtx
+	jobName               string
+	log                   *zap.SugaredLogger
+}
+
+func NewVulnerabilityScanCtl(stepTask *commonmodels.StepTask, workflowCtx *commonmodels.WorkflowTaskCtx, jobName string, log *zap.SugaredLogger) (*vulnerabilityScanCtl, error) {
+	yamlString, err := yaml.Marshal(stepTask.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal vulnerability scan spec error: %v", err)
+	}
+	vulnerabilityScanSpec := &step.StepVulnerabilityScanSpec{}
+	if err := yaml.Unmarshal(yamlString, &vulnerabilityScanSpec); err != nil {
+		return nil, fmt.Errorf("unmarshal vulnerability scan spec error: %v", err)
+	}
+	stepTask.Spec = vulnerabilityScanSpec
+	return &vulnerabilityScanCtl{vulnerabilityScanSpec: vulnerabilityScanSpec, workflowCtx: workflowCtx, jobName: jobName, log: log, step: stepTask}, nil
+}
+
+func (s *vulnerabilityScanCtl) PreRun(ctx context.Context) error {
+	if s.vulnerabilityScanSpec.S3Storage == nil {
+		modelS3, err := commonrepo.NewS3StorageColl().FindDefault()
+		if err != nil {
+			return err
+		}
+		s.vulnerabilityScanSpec.S3Storage = modelS3toS3(modelS3)
+	}
+	s.vulnerabilityScanSpec.S3DestDir = vulnerability.ReportDir(s.workflowCtx.WorkflowName, s.workflowCtx.TaskID, s.jobName)
+	if s.vulnerabilityScanSpec.DB == nil {
+		s.vulnerabilityScanSpec.DB = vulnerability.GetDBSettings()
+	}
+	if s.vulnerabilityScanSpec.Target == step.VulnerabilityScanTargetImage && s.vulnerabilityScanSpec.DockerRegistry == nil {
+		// the image is rendered by now, pick the credentials of its registry
+		registries, err := commonrepo.NewRegistryNamespaceColl().FindAll(&commonrepo.FindRegOps{})
+		if err != nil {
+			return fmt.Errorf("list registries error: %v", err)
+		}
+		s.vulnerabilityScanSpec.DockerRegistry = vulnerability.FindImageRegistry(s.vulnerabilityScanSpec.Image, registries)
+	}
+	s.step.Spec = s.vulnerabilityScanSpec
+	return nil
+}
+
+// AfterRun saves the findings of the report uploaded by the step, nothing is saved if the scanning didn't finish.
+func (s *vulnerabilityScanCtl) AfterRun(ctx context.Context) error {
+	result := &commonmodels.VulnerabilityScanResult{
+		ProjectName:  s.workflowCtx.ProjectName,
+		WorkflowName: s.workflowCtx.WorkflowName,
+		TaskID:       s.workflowCtx.TaskID,
+		JobName:      s.jobName,
+		Target:       s.vulnerabilityScanSpec.Target,
+		Image:        s.vulnerabilityScanSpec.Image,
+	}
+	if err := vulnerability.SaveScanResult(result, s.vulnerabilityScanSpec.QualityGate); err != nil {
+		s.log.Warnf("failed to save vulnerability scan result of job %s: %s", s.jobName, err)
+	}
+	return nil
+}
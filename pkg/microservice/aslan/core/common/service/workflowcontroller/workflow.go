@@ -47,8 +47,15 @@ type workflowCtl struct {
 	workflowTask       *commonmodels.WorkflowTask
 	globalContextMutex sync.RWMutex
 	clusterIDMutex     sync.RWMutex
+	supplyChainMutex   sync.Mutex
 	logger             *zap.SugaredLogger
 	ack                func()
+	// resumed means the task was started by a previous aslan process and is re-adopted after restart
+	resumed bool
+	// lease is set while the task is run by this replica, see WorkflowTaskLeaseKeeper
+	lease *ownedTask
+	// finalizing is true while the final stages of a cancelled task are running
+	finalizing bool
 }
 
 func NewWorkflowController(workflowTask *commonmodels.WorkflowTask, logger *zap.SugaredLogger) *workflowCtl {
@@ -67,6 +74,7 @@ func CancelWorkflowTask(userName, workflowName string, taskID int64, logger *zap
 		return err
 	}
 
+	owner := queueOwner(workflowName, taskID)
 	// try to remove task from queue first.
 	q := ConvertTaskToQueue(t)
 	if err := Remove(q); err != nil {
@@ -98,8 +106,14 @@ func CancelWorkflowTask(userName, workflowName string, taskID int64, logger *zap
 
 	value, ok := cancelChannelMap.Load(fmt.Sprintf("%s-%d", workflowName, taskID))
 	if !ok {
-		logger.Errorf("no mactched task found, id: %d, workflow name: %s", taskID, workflowName)
-		return nil
+		if owner == "" || owner == LeaseOwner() {
+			logger.Infof("task is not running, id: %d, workflow name: %s", taskID, workflowName)
+			return nil
+		}
+		// the replica running the task also stops it when it fails to renew the lease of the removed queue item,
+		// the message makes it stop at once
+		logger.Infof("task is running on replica %s, id: %d, workflow name: %s", owner, taskID, workflowName)
+		return forwardCancel(workflowName, taskID, owner)
 	}
 	if f, ok := value.(context.CancelFunc); ok {
 		f()
@@ -113,6 +127,13 @@ func (c *workflowCtl) setWorkflowStatus(status config.Status) {
 	c.ack()
 }
 
+// Resume continues a task that was running when aslan restarted, finished stages and jobs are skipped,
+// running jobs are re-attached to their kubernetes or vm executors.
+func (c *workflowCtl) Resume(ctx context.Context, concurrency int) {
+	c.resumed = true
+	c.Run(ctx, concurrency)
+}
+
 func (c *workflowCtl) Run(ctx context.Context, concurrency int) {
 	if c.workflowTask.GlobalContext == nil {
 		c.workflowTask.GlobalContext = make(map[string]string)
@@ -125,13 +146,19 @@ func (c *workflowCtl) Run(ctx context.Context, concurrency int) {
 	defer removeWorkflowTaskInMap(c.workflowTask.WorkflowName, c.workflowTask.TaskID)
 
 	c.workflowTask.Status = config.StatusRunning
-	c.workflowTask.StartTime = time.Now().Unix()
+	if !c.resumed || c.workflowTask.StartTime == 0 {
+		c.workflowTask.StartTime = time.Now().Unix()
+	}
 	c.ack()
 	c.logger.Infof("start workflow: %s,status: %s", c.workflowTask.WorkflowName, c.workflowTask.Status)
 	defer func() {
 		c.workflowTask.EndTime = time.Now().Unix()
 		c.logger.Infof("finish workflow: %s,status: %s", c.workflowTask.WorkflowName, c.workflowTask.Status)
 		c.ack()
+		// the replica which took over the task is responsible for the share storage
+		if c.lease.Lost() {
+			return
+		}
 		// clean share storage after workflow finished
 		go c.CleanShareStorage()
 	}()
@@ -140,6 +167,10 @@ func (c *workflowCtl) Run(ctx context.Context, concurrency int) {
 	cancelKey := fmt.Sprintf("%s-%d", c.workflowTask.WorkflowName, c.workflowTask.TaskID)
 	cancelChannelMap.Store(cancelKey, cancel)
 	defer cancelChannelMap.Delete(cancelKey)
+	defer jobcontroller.CleanMatrixGroups(c.workflowTask.WorkflowName, c.workflowTask.TaskID)
+	c.lease = &ownedTask{WorkflowName: c.workflowTask.WorkflowName, TaskID: c.workflowTask.TaskID}
+	ownedTaskMap.Store(cancelKey, c.lease)
+	defer ownedTaskMap.Delete(cancelKey)
 
 	workflowCtx := &commonmodels.WorkflowTaskCtx{
 		WorkflowName:                c.workflowTask.WorkflowName,
@@ -160,16 +191,32 @@ func (c *workflowCtl) Run(ctx context.Context, concurrency int) {
 		GlobalContextEach:           c.globalContextEach,
 		ClusterIDAdd:                c.addCluterID,
 		SetStatus:                   c.setWorkflowStatus,
+		EvaluateCondition:           c.evaluateCondition,
+		Resumed:                     c.resumed,
+		Lost:                        c.lease.Lost,
+		SupplyChainArtifactAdd:      c.addSupplyChainArtifact,
 	}
-	defer jobcontroller.CleanWorkflowJobs(ctx, c.workflowTask, workflowCtx, c.logger, c.ack)
+	defer func() {
+		// jobs of a task taken over by another replica are still watched by the new owner
+		if c.lease.Lost() {
+			return
+		}
+		jobcontroller.CleanWorkflowJobs(ctx, c.workflowTask, workflowCtx, c.logger, c.ack)
+	}()
 	if err := scmnotify.NewService().UpdateWebhookCommentForWorkflowV4(c.workflowTask, c.logger); err != nil {
 		log.Warnf("Failed to update comment for custom workflow %s, taskID: %d the error is: %s", c.workflowTask.WorkflowName, c.workflowTask.TaskID, err)
 	}
 	if err := scmnotify.NewService().UpdateGitCheckForWorkflowV4(c.workflowTask.WorkflowArgs, c.workflowTask.TaskID, c.logger); err != nil {
 		log.Warnf("Failed to update github check status for custom workflow %s, taskID: %d the error is: %s", c.workflowTask.WorkflowName, c.workflowTask.TaskID, err)
 	}
-	RunStages(ctx, c.workflowTask.Stages, workflowCtx, concurrency, c.logger, c.ack)
+	mainStages, finalStages := splitFinalStages(c.workflowTask.Stages)
+	if workflowHasJobNeeds(mainStages) {
+		RunDAG(ctx, mainStages, workflowCtx, concurrency, c.logger, c.ack)
+	} else {
+		RunStages(ctx, mainStages, workflowCtx, concurrency, c.logger, c.ack)
+	}
 	updateworkflowStatus(c.workflowTask)
+	c.runFinalStages(finalStages, workflowCtx, concurrency)
 }
 
 func updateworkflowStatus(workflow *commonmodels.WorkflowTask) {
@@ -211,6 +258,11 @@ func updateworkflowStatus(workflow *commonmodels.WorkflowTask) {
 }
 
 func (c *workflowCtl) updateWorkflowTask() {
+	// another replica owns the task now, ACKs of this replica should not override its progress
+	if c.lease.Lost() {
+		c.logger.Infof("%s:%d task has been taken over by another replica, ACK dropped", c.workflowTask.WorkflowName, c.workflowTask.TaskID)
+		return
+	}
 	taskInColl, err := commonrepo.NewworkflowTaskv4Coll().Find(c.workflowTask.WorkflowName, c.workflowTask.TaskID)
 	if err != nil {
 		c.logger.Errorf("find workflow task v4 %s failed,error: %v", c.workflowTask.WorkflowName, err)
@@ -237,6 +289,10 @@ func (c *workflowCtl) updateWorkflowTask() {
 	if err := commonrepo.NewworkflowTaskv4Coll().Update(c.workflowTask.ID.Hex(), c.workflowTask); err != nil {
 		c.logger.Errorf("update workflow task v4 failed,error: %v", err)
 	}
+	// the task is done only after its final stages finished
+	if c.finalizing {
+		return
+	}
 
 	if c.workflowTask.Status == config.StatusPassed || c.workflowTask.Status == config.StatusFailed || c.workflowTask.Status == config.StatusTimeout || c.workflowTask.Status == config.StatusCancelled || c.workflowTask.Status == config.StatusReject {
 		c.logger.Infof("%s:%d:%v task done", c.workflowTask.WorkflowName, c.workflowTask.TaskID, c.workflowTask.Status)
@@ -311,6 +367,12 @@ func (c *workflowCtl) addCluterID(clusterID string) {
 	c.workflowTask.ClusterIDMap[clusterID] = true
 }
 
+func (c *workflowCtl) addSupplyChainArtifact(artifact *commonmodels.SupplyChainArtifact) {
+	c.supplyChainMutex.Lock()
+	defer c.supplyChainMutex.Unlock()
+	c.workflowTask.SupplyChainArtifacts = append(c.workflowTask.SupplyChainArtifacts, artifact)
+}
+
 // mongo do not support dot in keys.
 const (
 	split = "@?"
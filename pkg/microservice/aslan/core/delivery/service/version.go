@@ -896,7 +896,12 @@ func buildArtifactTaskArgs(projectName, envName string, imagesMap *sync.Map) *co
 // insert delivery distribution data for single chart, include image and chart
 func insertDeliveryDistributions(result *task.ServicePackageResult, chartVersion string, deliveryVersion *commonmodels.DeliveryVersion, args *DeliveryVersionChartData) error {
 	for _, image := range result.ImageData {
-		err := commonrepo.NewDeliveryDistributeColl().Insert(&commonmodels.DeliveryDistribute{
+		// images not built by zadig have no supply chain artifacts
+		supplyChain, err := commonrepo.NewSupplyChainArtifactColl().FindLatestByImage(image.ImageUrl)
+		if err != nil {
+			supplyChain = nil
+		}
+		err = commonrepo.NewDeliveryDistributeColl().Insert(&commonmodels.DeliveryDistribute{
 			ReleaseID:      deliveryVersion.ID,
 			ServiceName:    image.ImageName, // image name
 			ChartName:      result.ServiceName,
@@ -904,6 +909,7 @@ func insertDeliveryDistributions(result *task.ServicePackageResult, chartVersion
 			RegistryName:   image.ImageUrl,
 			Namespace:      commonservice.ExtractRegistryNamespace(image.ImageUrl),
 			CreatedAt:      time.Now().Unix(),
+			SupplyChain:    supplyChain,
 		})
 		if err != nil {
 			log.Errorf("failed to insert image distribute data, chartName: %s, err: %s", result.ServiceName, err)
@@ -0,0 +1,190 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/environment/service"
+	"github.com/koderover/zadig/pkg/setting"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// @Summary Get Env Idle Policy
+// @Description Get the policy that puts the environment to sleep when it is idle
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	name 		path		string							true	"env name"
+// @Param 	projectName	query		string							true	"project name"
+// @Success 200 		{object}    service.EnvIdlePolicyResp
+// @Router /api/aslan/environment/environments/{name}/sleep/idle [get]
+func GetEnvIdlePolicy(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	envName := c.Param("name")
+	if envName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("name can not be null!")
+		return
+	}
+
+	permitted := false
+
+	if ctx.Resources.IsSystemAdmin {
+		permitted = true
+	} else if projectAuthInfo, ok := ctx.Resources.ProjectAuthInfo[projectName]; ok {
+		if projectAuthInfo.IsProjectAdmin {
+			permitted = true
+		}
+
+		if projectAuthInfo.Env.View {
+			permitted = true
+		}
+
+		collaborationAuthorizedView, err := internalhandler.CheckPermissionGivenByCollaborationMode(ctx.UserID, projectName, types.ResourceTypeEnvironment, types.EnvActionView)
+		if err == nil && collaborationAuthorizedView {
+			permitted = true
+		}
+	}
+
+	if !permitted {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.GetEnvIdlePolicy(projectName, envName, ctx.Logger)
+}
+
+// @Summary Update Env Idle Policy
+// @Description Update the policy that puts the environment to sleep when it is idle
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	name 		path		string							true	"env name"
+// @Param 	projectName	query		string							true	"project name"
+// @Param 	body 		body 		service.EnvIdlePolicyArg 		true 	"body"
+// @Success 200
+// @Router /api/aslan/environment/environments/{name}/sleep/idle [put]
+func UpdateEnvIdlePolicy(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	envName := c.Param("name")
+	if envName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("name can not be null!")
+		return
+	}
+
+	data, err := c.GetRawData()
+	if err != nil {
+		log.Errorf("UpdateEnvIdlePolicy c.GetRawData() err : %v", err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(data))
+	internalhandler.InsertDetailedOperationLog(c, ctx.UserName, projectName, setting.OperationSceneEnv, "更新", "环境闲置睡眠", envName, string(data), ctx.Logger, envName)
+
+	arg := new(service.EnvIdlePolicyArg)
+	if err := c.BindJSON(arg); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+
+	permitted := false
+
+	if ctx.Resources.IsSystemAdmin {
+		permitted = true
+	} else if projectAuthInfo, ok := ctx.Resources.ProjectAuthInfo[projectName]; ok {
+		if projectAuthInfo.IsProjectAdmin {
+			permitted = true
+		}
+
+		if projectAuthInfo.Env.EditConfig {
+			permitted = true
+		}
+
+		collaborationAuthorizedEdit, err := internalhandler.CheckPermissionGivenByCollaborationMode(ctx.UserID, projectName, types.ResourceTypeEnvironment, types.EnvActionEditConfig)
+		if err == nil && collaborationAuthorizedEdit {
+			permitted = true
+		}
+	}
+
+	if !permitted {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Err = service.UpdateEnvIdlePolicy(projectName, envName, arg, ctx.Logger)
+}
+
+// @Summary Wake Env
+// @Description Called by the wake proxy of a sleeping environment to wake it up, the token identifies the environment
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	token 		path		string							true	"wake token"
+// @Success 200 		{object}    service.EnvWakeResp
+// @Router /api/aslan/environment/wake/{token} [post]
+func WakeEnv(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	token := c.Param("token")
+	if token == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("token can not be null!")
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.WakeEnvByToken(token, ctx.Logger)
+}
+
+func EnvIdleSleepCronJob(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	service.EnvIdleSleepCronJob(ctx.Logger)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/gin-gonic/gin"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/environment/service"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+// @Summary Get Env Preview Policy
+// @Description Get the policy that creates a preview environment for each pull request in the project
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	projectName	query		string							true	"project name"
+// @Success 200 		{object}    models.EnvPreviewPolicy
+// @Router /api/aslan/environment/environments/preview/policy [get]
+func GetEnvPreviewPolicy(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	if !hasEnvPermission(ctx, projectName, false) {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.GetEnvPreviewPolicy(projectName)
+}
+
+// @Summary Update Env Preview Policy
+// @Description Update the policy that creates a preview environment for each pull request in the project, the base environment must have environment sharing enabled
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	projectName	query		string							true	"project name"
+// @Param 	body 		body 		models.EnvPreviewPolicy 		true 	"body"
+// @Success 200
+// @Router /api/aslan/environment/environments/preview/policy [put]
+func UpdateEnvPreviewPolicy(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	data, err := c.GetRawData()
+	if err != nil {
+		log.Errorf("UpdateEnvPreviewPolicy c.GetRawData() err : %v", err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(data))
+	internalhandler.InsertOperationLog(c, ctx.UserName, projectName, "更新", "预览环境策略", "", string(data), ctx.Logger)
+
+	policy := new(commonmodels.EnvPreviewPolicy)
+	if err := c.BindJSON(policy); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+
+	if !ctx.Resources.IsSystemAdmin {
+		if _, ok := ctx.Resources.ProjectAuthInfo[projectName]; !ok {
+			ctx.UnAuthorized = true
+			return
+		}
+		if !ctx.Resources.ProjectAuthInfo[projectName].IsProjectAdmin {
+			ctx.UnAuthorized = true
+			return
+		}
+	}
+
+	ctx.Err = service.UpdateEnvPreviewPolicy(projectName, policy, ctx.UserName)
+}
+
+// @Summary List Env Previews
+// @Description List the preview environments created for the pull requests in the project
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	projectName	query		string							true	"project name"
+// @Success 200 		{array} 	models.EnvPreview
+// @Router /api/aslan/environment/environments/preview [get]
+func ListEnvPreviews(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	if !hasEnvPermission(ctx, projectName, false) {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.ListEnvPreviews(projectName)
+}
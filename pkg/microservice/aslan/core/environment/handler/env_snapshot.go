@@ -0,0 +1,492 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+
+	"github.com/gin-gonic/gin"
+
+	commonutil "github.com/koderover/zadig/pkg/microservice/aslan/core/common/util"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/environment/service"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// @Summary Create Env Snapshot
+// @Description Archive the environment into a new version of its snapshots in the object storage
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	name 		path		string							true	"env name"
+// @Param 	projectName	query		string							true	"project name"
+// @Param 	body 		body 		service.EnvSnapshotArg 			true 	"body"
+// @Success 200 		{object}    models.EnvSnapshot
+// @Router /api/aslan/environment/environments/{name}/snapshots [post]
+func CreateEnvSnapshot(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	envName := c.Param("name")
+	if envName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("name can not be null!")
+		return
+	}
+
+	arg := new(service.EnvSnapshotArg)
+	if err := c.ShouldBindJSON(arg); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+
+	internalhandler.InsertOperationLog(c, ctx.UserName, projectName, "新增", "环境快照", envName, "", ctx.Logger)
+
+	if !hasEnvPermission(ctx, projectName, true) {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.CreateEnvSnapshot(projectName, envName, false, arg, ctx.UserName, ctx.Logger)
+}
+
+// @Summary List Env Snapshots
+// @Description List the snapshots of the environment, the latest version first
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	name 		path		string							true	"env name"
+// @Param 	projectName	query		string							true	"project name"
+// @Success 200 		{array} 	models.EnvSnapshot
+// @Router /api/aslan/environment/environments/{name}/snapshots [get]
+func ListEnvSnapshots(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	envName := c.Param("name")
+	if envName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("name can not be null!")
+		return
+	}
+
+	if !hasEnvPermission(ctx, projectName, false) {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.ListEnvSnapshots(projectName, envName, false)
+}
+
+// @Summary Restore Env Snapshot
+// @Description Create a new test environment from the snapshot of a test or a production environment, the cluster, the namespace, the registry and the ingress domains can be remapped
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	id 			path		string							true	"snapshot id"
+// @Param 	projectName	query		string							true	"project name"
+// @Param 	body 		body 		service.EnvSnapshotRestoreArg 	true 	"body"
+// @Success 200
+// @Router /api/aslan/environment/environments/snapshots/{id}/restore [post]
+func RestoreEnvSnapshot(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	arg := new(service.EnvSnapshotRestoreArg)
+	if err := c.ShouldBindJSON(arg); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+
+	internalhandler.InsertOperationLog(c, ctx.UserName, projectName, "恢复", "环境快照", arg.EnvName, "", ctx.Logger)
+
+	if !ctx.Resources.IsSystemAdmin {
+		if _, ok := ctx.Resources.ProjectAuthInfo[projectName]; !ok {
+			ctx.UnAuthorized = true
+			return
+		}
+		if !ctx.Resources.ProjectAuthInfo[projectName].IsProjectAdmin &&
+			!ctx.Resources.ProjectAuthInfo[projectName].Env.Create {
+			ctx.UnAuthorized = true
+			return
+		}
+	}
+
+	if !hasEnvSnapshotPermission(ctx, projectName, c.Param("id")) {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Err = service.RestoreEnvSnapshot(projectName, c.Param("id"), false, ctx.UserName, ctx.RequestID, arg, ctx.Logger)
+}
+
+// @Summary Create Production Env Snapshot
+// @Description Archive the production environment into a new version of its snapshots in the object storage
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	name 		path		string							true	"env name"
+// @Param 	projectName	query		string							true	"project name"
+// @Param 	body 		body 		service.EnvSnapshotArg 			true 	"body"
+// @Success 200 		{object}    models.EnvSnapshot
+// @Router /api/aslan/environment/production/environments/{name}/snapshots [post]
+func CreateProductionEnvSnapshot(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	envName := c.Param("name")
+	if envName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("name can not be null!")
+		return
+	}
+
+	arg := new(service.EnvSnapshotArg)
+	if err := c.ShouldBindJSON(arg); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+
+	internalhandler.InsertOperationLog(c, ctx.UserName, projectName, "新增", "生产环境快照", envName, "", ctx.Logger)
+
+	if !hasProductionEnvPermission(ctx, projectName, true) {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	if err := commonutil.CheckZadigXLicenseStatus(); err != nil {
+		ctx.Err = err
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.CreateEnvSnapshot(projectName, envName, true, arg, ctx.UserName, ctx.Logger)
+}
+
+// @Summary List Production Env Snapshots
+// @Description List the snapshots of the production environment, the latest version first
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	name 		path		string							true	"env name"
+// @Param 	projectName	query		string							true	"project name"
+// @Success 200 		{array} 	models.EnvSnapshot
+// @Router /api/aslan/environment/production/environments/{name}/snapshots [get]
+func ListProductionEnvSnapshots(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	envName := c.Param("name")
+	if envName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("name can not be null!")
+		return
+	}
+
+	if !hasProductionEnvPermission(ctx, projectName, false) {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	if err := commonutil.CheckZadigXLicenseStatus(); err != nil {
+		ctx.Err = err
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.ListEnvSnapshots(projectName, envName, true)
+}
+
+// @Summary Restore Production Env Snapshot
+// @Description Create a new production environment from the snapshot of a test or a production environment, the cluster, the namespace, the registry and the ingress domains can be remapped
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	id 			path		string							true	"snapshot id"
+// @Param 	projectName	query		string							true	"project name"
+// @Param 	body 		body 		service.EnvSnapshotRestoreArg 	true 	"body"
+// @Success 200
+// @Router /api/aslan/environment/production/environments/snapshots/{id}/restore [post]
+func RestoreProductionEnvSnapshot(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	arg := new(service.EnvSnapshotRestoreArg)
+	if err := c.ShouldBindJSON(arg); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+
+	internalhandler.InsertOperationLog(c, ctx.UserName, projectName, "恢复", "生产环境快照", arg.EnvName, "", ctx.Logger)
+
+	if !ctx.Resources.IsSystemAdmin {
+		if _, ok := ctx.Resources.ProjectAuthInfo[projectName]; !ok {
+			ctx.UnAuthorized = true
+			return
+		}
+		if !ctx.Resources.ProjectAuthInfo[projectName].IsProjectAdmin &&
+			!ctx.Resources.ProjectAuthInfo[projectName].ProductionEnv.Create {
+			ctx.UnAuthorized = true
+			return
+		}
+	}
+
+	if err := commonutil.CheckZadigXLicenseStatus(); err != nil {
+		ctx.Err = err
+		return
+	}
+
+	if !hasEnvSnapshotPermission(ctx, projectName, c.Param("id")) {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Err = service.RestoreEnvSnapshot(projectName, c.Param("id"), true, ctx.UserName, ctx.RequestID, arg, ctx.Logger)
+}
+
+// @Summary Import Env Snapshot
+// @Description Create a new test environment from a snapshot archive, the restore args are passed in the arg form field
+// @Tags 	environment
+// @Accept 	multipart/form-data
+// @Produce json
+// @Param 	projectName	query		string							true	"project name"
+// @Param 	file 		formData 	file 							true 	"snapshot archive"
+// @Param 	arg 		formData 	string 							true 	"service.EnvSnapshotRestoreArg in json"
+// @Success 200
+// @Router /api/aslan/environment/environments/snapshots/import [post]
+func ImportEnvSnapshot(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	arg, archive, err := getEnvSnapshotImportArgs(c)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+	defer archive.Close()
+
+	internalhandler.InsertOperationLog(c, ctx.UserName, projectName, "导入", "环境快照", arg.EnvName, "", ctx.Logger)
+
+	if !ctx.Resources.IsSystemAdmin {
+		if _, ok := ctx.Resources.ProjectAuthInfo[projectName]; !ok {
+			ctx.UnAuthorized = true
+			return
+		}
+		if !ctx.Resources.ProjectAuthInfo[projectName].IsProjectAdmin &&
+			!ctx.Resources.ProjectAuthInfo[projectName].Env.Create {
+			ctx.UnAuthorized = true
+			return
+		}
+	}
+
+	ctx.Err = service.ImportEnvSnapshot(projectName, archive, false, ctx.UserName, ctx.RequestID, arg, ctx.Logger)
+}
+
+// @Summary Import Production Env Snapshot
+// @Description Create a new production environment from a snapshot archive, the restore args are passed in the arg form field
+// @Tags 	environment
+// @Accept 	multipart/form-data
+// @Produce json
+// @Param 	projectName	query		string							true	"project name"
+// @Param 	file 		formData 	file 							true 	"snapshot archive"
+// @Param 	arg 		formData 	string 							true 	"service.EnvSnapshotRestoreArg in json"
+// @Success 200
+// @Router /api/aslan/environment/production/environments/snapshots/import [post]
+func ImportProductionEnvSnapshot(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	arg, archive, err := getEnvSnapshotImportArgs(c)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+	defer archive.Close()
+
+	internalhandler.InsertOperationLog(c, ctx.UserName, projectName, "导入", "生产环境快照", arg.EnvName, "", ctx.Logger)
+
+	if !ctx.Resources.IsSystemAdmin {
+		if _, ok := ctx.Resources.ProjectAuthInfo[projectName]; !ok {
+			ctx.UnAuthorized = true
+			return
+		}
+		if !ctx.Resources.ProjectAuthInfo[projectName].IsProjectAdmin &&
+			!ctx.Resources.ProjectAuthInfo[projectName].ProductionEnv.Create {
+			ctx.UnAuthorized = true
+			return
+		}
+	}
+
+	if err := commonutil.CheckZadigXLicenseStatus(); err != nil {
+		ctx.Err = err
+		return
+	}
+
+	ctx.Err = service.ImportEnvSnapshot(projectName, archive, true, ctx.UserName, ctx.RequestID, arg, ctx.Logger)
+}
+
+// getEnvSnapshotImportArgs reads the snapshot archive in the file form field and the restore args in the arg form field
+func getEnvSnapshotImportArgs(c *gin.Context) (*service.EnvSnapshotRestoreArg, multipart.File, error) {
+	arg := new(service.EnvSnapshotRestoreArg)
+	if err := json.Unmarshal([]byte(c.PostForm("arg")), arg); err != nil {
+		return nil, nil, fmt.Errorf("invalid arg, err: %w", err)
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return nil, nil, fmt.Errorf("snapshot archive is required, err: %w", err)
+	}
+	archive, err := fileHeader.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	return arg, archive, nil
+}
+
+// hasEnvSnapshotPermission checks the view permission of the environments the snapshot is taken of, since the restored
+// environment can be of a different kind
+func hasEnvSnapshotPermission(ctx *internalhandler.Context, projectName, snapshotID string) bool {
+	snapshot, err := service.GetEnvSnapshot(projectName, snapshotID)
+	if err != nil {
+		// the error is returned by the restore
+		return true
+	}
+	if snapshot.Production {
+		return hasProductionEnvPermission(ctx, projectName, false)
+	}
+	return hasEnvPermission(ctx, projectName, false)
+}
+
+// hasProductionEnvPermission checks the view or the edit config permission of the production environments in the project
+func hasProductionEnvPermission(ctx *internalhandler.Context, projectName string, edit bool) bool {
+	if ctx.Resources.IsSystemAdmin {
+		return true
+	}
+
+	projectAuthInfo, ok := ctx.Resources.ProjectAuthInfo[projectName]
+	if !ok {
+		return false
+	}
+	if projectAuthInfo.IsProjectAdmin {
+		return true
+	}
+
+	action := types.ProductionEnvActionView
+	if edit {
+		if projectAuthInfo.ProductionEnv.EditConfig {
+			return true
+		}
+		action = types.ProductionEnvActionEditConfig
+	} else if projectAuthInfo.ProductionEnv.View {
+		return true
+	}
+
+	authorized, err := internalhandler.CheckPermissionGivenByCollaborationMode(ctx.UserID, projectName, types.ResourceTypeEnvironment, action)
+	return err == nil && authorized
+}
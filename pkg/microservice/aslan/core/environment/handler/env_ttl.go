@@ -0,0 +1,286 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/environment/service"
+	"github.com/koderover/zadig/pkg/setting"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// @Summary Get Env TTL Policy
+// @Description Get the policy that reclaims the environment when it expires
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	name 		path		string							true	"env name"
+// @Param 	projectName	query		string							true	"project name"
+// @Success 200 		{object}    service.EnvTTLPolicyResp
+// @Router /api/aslan/environment/environments/{name}/ttl [get]
+func GetEnvTTLPolicy(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	envName := c.Param("name")
+	if envName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("name can not be null!")
+		return
+	}
+
+	if !hasEnvPermission(ctx, projectName, false) {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.GetEnvTTLPolicy(projectName, envName)
+}
+
+// @Summary Update Env TTL Policy
+// @Description Update the policy that reclaims the environment when it expires, the environment expires ttl hours from now on
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	name 		path		string							true	"env name"
+// @Param 	projectName	query		string							true	"project name"
+// @Param 	body 		body 		service.EnvTTLPolicyArg 		true 	"body"
+// @Success 200
+// @Router /api/aslan/environment/environments/{name}/ttl [put]
+func UpdateEnvTTLPolicy(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	envName := c.Param("name")
+	if envName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("name can not be null!")
+		return
+	}
+
+	data, err := c.GetRawData()
+	if err != nil {
+		log.Errorf("UpdateEnvTTLPolicy c.GetRawData() err : %v", err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(data))
+	internalhandler.InsertDetailedOperationLog(c, ctx.UserName, projectName, setting.OperationSceneEnv, "更新", "环境过期回收", envName, string(data), ctx.Logger, envName)
+
+	arg := new(service.EnvTTLPolicyArg)
+	if err := c.BindJSON(arg); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+
+	if !hasEnvPermission(ctx, projectName, true) {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Err = service.UpdateEnvTTLPolicy(projectName, envName, ctx.UserName, arg, ctx.Logger)
+}
+
+// @Summary Extend Env TTL
+// @Description Extend the expiry of the environment, an environment asleep after the expiry is woken up
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	name 		path		string							true	"env name"
+// @Param 	projectName	query		string							true	"project name"
+// @Param 	hours		query		int								false	"hours to extend, the ttl hours of the policy by default"
+// @Success 200 		{object}    service.EnvTTLPolicyResp
+// @Router /api/aslan/environment/environments/{name}/ttl/extend [post]
+func ExtendEnvTTL(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	envName := c.Param("name")
+	if envName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("name can not be null!")
+		return
+	}
+
+	hours := 0
+	if c.Query("hours") != "" {
+		hours, err = strconv.Atoi(c.Query("hours"))
+		if err != nil || hours <= 0 {
+			ctx.Err = e.ErrInvalidParam.AddDesc("hours must be a positive integer")
+			return
+		}
+	}
+
+	internalhandler.InsertOperationLog(c, ctx.UserName, projectName, "延长", "环境有效期", envName, "", ctx.Logger)
+
+	if !hasEnvPermission(ctx, projectName, true) {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.ExtendEnvTTL(projectName, envName, hours, ctx.Logger)
+}
+
+// @Summary Get Env TTL Extend Page
+// @Description Opened by the extend link in the expiry reminders, the page asks to confirm the extension
+// @Tags 	environment
+// @Produce html
+// @Param 	token 		path		string							true	"extend token"
+// @Success 200
+// @Router /api/aslan/environment/ttl/extend/{token} [get]
+func GetEnvTTLExtendPage(c *gin.Context) {
+	token := c.Param("token")
+	env, err := service.GetEnvTTLByToken(token)
+	if err != nil {
+		c.Data(http.StatusNotFound, "text/html; charset=utf-8", []byte("链接已失效"))
+		return
+	}
+
+	page, err := service.RenderEnvTTLExtendPage(env)
+	if err != nil {
+		c.Data(http.StatusInternalServerError, "text/html; charset=utf-8", []byte(err.Error()))
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+}
+
+// @Summary Extend Env TTL By Token
+// @Description Called when the extension is confirmed on the page of the extend link, the token identifies the environment
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	token 		path		string							true	"extend token"
+// @Success 200 		{object}    service.EnvTTLPolicyResp
+// @Router /api/aslan/environment/ttl/extend/{token} [post]
+func ExtendEnvTTLByToken(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	token := c.Param("token")
+	if token == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("token can not be null!")
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.ExtendEnvTTLByToken(token, ctx.Logger)
+}
+
+// @Summary Get Env Reclaim Report
+// @Description List the environments reclaimed after they expired and the resources they had requested
+// @Tags 	environment
+// @Accept 	json
+// @Produce json
+// @Param 	projectName	query		string							true	"project name"
+// @Param 	startTime	query		int								false	"start time"
+// @Param 	endTime		query		int								false	"end time"
+// @Success 200 		{object}    service.EnvReclaimReport
+// @Router /api/aslan/environment/environments/reclaim/report [get]
+func GetEnvReclaimReport(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be null!")
+		return
+	}
+
+	startTime, _ := strconv.ParseInt(c.Query("startTime"), 10, 64)
+	endTime, _ := strconv.ParseInt(c.Query("endTime"), 10, 64)
+
+	if !hasEnvPermission(ctx, projectName, false) {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.GetEnvReclaimReport(projectName, startTime, endTime)
+}
+
+// hasEnvPermission checks the view or the edit config permission of the test environments in the project
+func hasEnvPermission(ctx *internalhandler.Context, projectName string, edit bool) bool {
+	if ctx.Resources.IsSystemAdmin {
+		return true
+	}
+
+	projectAuthInfo, ok := ctx.Resources.ProjectAuthInfo[projectName]
+	if !ok {
+		return false
+	}
+	if projectAuthInfo.IsProjectAdmin {
+		return true
+	}
+
+	action := types.EnvActionView
+	if edit {
+		if projectAuthInfo.Env.EditConfig {
+			return true
+		}
+		action = types.EnvActionEditConfig
+	} else if projectAuthInfo.Env.View {
+		return true
+	}
+
+	authorized, err := internalhandler.CheckPermissionGivenByCollaborationMode(ctx.UserID, projectName, types.ResourceTypeEnvironment, action)
+	return err == nil && authorized
+}
@@ -773,3 +773,29 @@ func OpenAPIRestartService(c *gin.Context) {
 	internalhandler.InsertDetailedOperationLog(c, ctx.UserName, projectName, setting.OperationSceneEnv, "OpenAPI"+"重启", "环境-服务", fmt.Sprintf("环境名称:%s,服务名称:%s", envName, serviceName), "", ctx.Logger)
 	ctx.Err = service.OpenAPIRestartService(projectName, envName, serviceName, ctx.Logger)
 }
+
+func OpenAPIGetEnvDriftReport(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	projectName, envName, err := generalOpenAPIRequestValidate(c)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.GetEnvDriftReport(projectName, envName, false, ctx.Logger)
+}
+
+func OpenAPIGetProductionEnvDriftReport(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	projectName, envName, err := generalOpenAPIRequestValidate(c)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.GetEnvDriftReport(projectName, envName, true, ctx.Logger)
+}
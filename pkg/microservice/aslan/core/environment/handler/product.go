@@ -35,6 +35,7 @@ func CleanProductCronJob(c *gin.Context) {
 	defer func() { internalhandler.JSONResponse(c, ctx) }()
 
 	service.CleanProductCronJob(ctx.RequestID, ctx.Logger)
+	service.ReclaimExpiredEnvs(ctx.RequestID, ctx.Logger)
 }
 
 type getInitProductResponse struct {
@@ -62,6 +62,24 @@ func (*Router) Inject(router *gin.RouterGroup) {
 	cron := router.Group("cron")
 	{
 		cron.GET("/cleanproduct", CleanProductCronJob)
+		cron.GET("/idlesleep", EnvIdleSleepCronJob)
+	}
+
+	// ---------------------------------------------------------------------------------------
+	// 闲置环境唤醒接口，由睡眠环境的唤醒代理调用
+	// ---------------------------------------------------------------------------------------
+	wake := router.Group("wake")
+	{
+		wake.POST("/:token", WakeEnv)
+	}
+
+	// ---------------------------------------------------------------------------------------
+	// 环境有效期延长接口，由到期提醒中的链接调用
+	// ---------------------------------------------------------------------------------------
+	ttl := router.Group("ttl")
+	{
+		ttl.GET("/extend/:token", GetEnvTTLExtendPage)
+		ttl.POST("/extend/:token", ExtendEnvTTLByToken)
 	}
 
 	// ---------------------------------------------------------------------------------------
@@ -178,6 +196,10 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		production.POST("/environments/:name/analysis", RunProductionAnalysis)
 		production.GET("/environments/:name/analysis/cron", GetProductionEnvAnalysisCron)
 		production.PUT("/environments/:name/analysis/cron", UpsertProductionEnvAnalysisCron)
+		production.POST("/environments/:name/drift", RunProductionEnvDriftDetection)
+		production.GET("/environments/:name/drift", GetProductionEnvDriftReport)
+		production.GET("/environments/:name/drift/config", GetProductionEnvDriftConfig)
+		production.PUT("/environments/:name/drift/config", UpdateProductionEnvDriftConfig)
 		production.PUT("/environments/:name/k8s/globalVariables", UpdateProductionEnvK8sProductGlobalVariables)
 		production.POST("/environments/:name/k8s/globalVariables/preview", PreviewProductionEnvGlobalVariables)
 
@@ -208,6 +230,10 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		production.POST("/environments/:name/sleep", ProductionEnvSleep)
 		production.GET("/environments/:name/sleep/cron", GetProductionEnvSleepCron)
 		production.PUT("/environments/:name/sleep/cron", UpsertProductionEnvSleepCron)
+		production.POST("/environments/:name/snapshots", CreateProductionEnvSnapshot)
+		production.GET("/environments/:name/snapshots", ListProductionEnvSnapshots)
+		production.POST("/environments/snapshots/:id/restore", RestoreProductionEnvSnapshot)
+		production.POST("/environments/snapshots/import", ImportProductionEnvSnapshot)
 
 		production.GET("/environments/:name/version/:serviceName", ListProductionEnvServiceVersions)
 		production.GET("/environments/:name/version/:serviceName/revision/:revision", GetProductionEnvServiceVersionYaml)
@@ -281,10 +307,27 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		environments.GET("/:name/analysis/cron", GetEnvAnalysisCron)
 		environments.PUT("/:name/analysis/cron", UpsertEnvAnalysisCron)
 		environments.GET("/analysis/history", GetEnvAnalysisHistory)
+		environments.POST("/:name/drift", RunEnvDriftDetection)
+		environments.GET("/:name/drift", GetEnvDriftReport)
+		environments.GET("/:name/drift/config", GetEnvDriftConfig)
+		environments.PUT("/:name/drift/config", UpdateEnvDriftConfig)
 
 		environments.POST("/:name/sleep", EnvSleep)
 		environments.GET("/:name/sleep/cron", GetEnvSleepCron)
 		environments.PUT("/:name/sleep/cron", UpsertEnvSleepCron)
+		environments.GET("/:name/sleep/idle", GetEnvIdlePolicy)
+		environments.PUT("/:name/sleep/idle", UpdateEnvIdlePolicy)
+		environments.GET("/:name/ttl", GetEnvTTLPolicy)
+		environments.PUT("/:name/ttl", UpdateEnvTTLPolicy)
+		environments.POST("/:name/ttl/extend", ExtendEnvTTL)
+		environments.GET("/reclaim/report", GetEnvReclaimReport)
+		environments.GET("/preview", ListEnvPreviews)
+		environments.GET("/preview/policy", GetEnvPreviewPolicy)
+		environments.PUT("/preview/policy", UpdateEnvPreviewPolicy)
+		environments.POST("/:name/snapshots", CreateEnvSnapshot)
+		environments.GET("/:name/snapshots", ListEnvSnapshots)
+		environments.POST("/snapshots/:id/restore", RestoreEnvSnapshot)
+		environments.POST("/snapshots/import", ImportEnvSnapshot)
 
 		environments.GET("/:name/version/:serviceName", ListEnvServiceVersions)
 		environments.GET("/:name/version/:serviceName/revision/:revision", GetEnvServiceVersionYaml)
@@ -344,6 +387,7 @@ func (*OpenAPIRouter) Inject(router *gin.RouterGroup) {
 		common.PUT("/:name/services", OpenAPIUpdateYamlServices)
 		common.GET("/:name/variable", OpenAPIGetEnvGlobalVariables)
 		common.PUT("/:name/variable", OpenAPIUpdateGlobalVariables)
+		common.GET("/:name/drift", OpenAPIGetEnvDriftReport)
 
 		common.POST("/:name/service/:serviceName/restart", OpenAPIRestartService)
 	}
@@ -369,6 +413,7 @@ func (*OpenAPIRouter) Inject(router *gin.RouterGroup) {
 		production.PUT("/:name/services", OpenAPIUpdateProductionYamlServices)
 		production.GET("/:name/variable", OpenAPIGetProductionEnvGlobalVariables)
 		production.PUT("/:name/variable", OpenAPIUpdateProductionGlobalVariables)
+		production.GET("/:name/drift", OpenAPIGetProductionEnvDriftReport)
 
 		production.POST("/:name/service/:serviceName/restart", OpenAPIRestartService)
 	}
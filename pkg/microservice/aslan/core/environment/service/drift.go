@@ -0,0 +1,623 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configbase "github.com/koderover/zadig/pkg/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/msg_queue"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/imnotify"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/kube"
+	commonutil "github.com/koderover/zadig/pkg/microservice/aslan/core/common/util"
+	"github.com/koderover/zadig/pkg/setting"
+	kubeclient "github.com/koderover/zadig/pkg/shared/kube/client"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	helmtool "github.com/koderover/zadig/pkg/tool/helmclient"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
+	"github.com/koderover/zadig/pkg/tool/kube/serializer"
+	"github.com/koderover/zadig/pkg/tool/kube/updater"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+// driftIgnoredPaths are the fields filled or maintained by the cluster, they are never compared
+var driftIgnoredPaths = sets.NewString(
+	"status",
+	"metadata.namespace",
+	"metadata.managedFields",
+	"metadata.resourceVersion",
+	"metadata.uid",
+	"metadata.generation",
+	"metadata.creationTimestamp",
+	"metadata.deletionTimestamp",
+	"metadata.selfLink",
+	"metadata.ownerReferences",
+	"metadata.finalizers",
+	"metadata.annotations.kubectl.kubernetes.io/last-applied-configuration",
+	"metadata.annotations.deployment.kubernetes.io/revision",
+)
+
+type EnvDriftConfigArg struct {
+	Enable bool                      `json:"enable"`
+	Cron   string                    `json:"cron"`
+	Mode   config.DriftDetectionMode `json:"mode"`
+}
+
+type EnvDriftReportResp struct {
+	Drifted   bool                           `json:"drifted"`
+	CheckTime int64                          `json:"check_time"`
+	Services  []*commonmodels.EnvDriftReport `json:"services"`
+}
+
+func GetEnvDriftConfig(projectName, envName string, production *bool, logger *zap.SugaredLogger) (*EnvDriftConfigArg, error) {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{
+		EnvName:    envName,
+		Name:       projectName,
+		Production: production,
+	})
+	if err != nil {
+		return nil, e.ErrGetEnvConfigs.AddErr(fmt.Errorf("failed to get environment %s/%s, err: %w", projectName, envName, err))
+	}
+
+	resp := &EnvDriftConfigArg{
+		Mode: config.DriftDetectionModeNotify,
+	}
+	if env.DriftDetectionConfig != nil && env.DriftDetectionConfig.Mode != "" {
+		resp.Mode = env.DriftDetectionConfig.Mode
+	}
+
+	cron, err := commonrepo.NewCronjobColl().GetByName(getEnvDriftCronName(projectName, envName), config.EnvDriftCronjob)
+	if err != nil {
+		if err != mongo.ErrNoDocuments && err != mongo.ErrNilDocument {
+			logger.Errorf("failed to get env drift cron job of %s/%s, err: %s", projectName, envName, err)
+			return nil, e.ErrGetCronjob.AddErr(err)
+		}
+		return resp, nil
+	}
+
+	resp.Enable = cron.Enabled
+	resp.Cron = cron.Cron
+	return resp, nil
+}
+
+func UpdateEnvDriftConfig(projectName, envName string, production *bool, arg *EnvDriftConfigArg, logger *zap.SugaredLogger) error {
+	if arg.Mode != config.DriftDetectionModeNotify && arg.Mode != config.DriftDetectionModeAutoHeal {
+		return e.ErrUpdateEnvDriftConfig.AddDesc(fmt.Sprintf("invalid drift detection mode: %s", arg.Mode))
+	}
+	if arg.Enable && arg.Cron == "" {
+		return e.ErrUpdateEnvDriftConfig.AddDesc("cron can not be empty")
+	}
+
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{
+		EnvName:    envName,
+		Name:       projectName,
+		Production: production,
+	})
+	if err != nil {
+		return e.ErrUpdateEnvDriftConfig.AddErr(fmt.Errorf("failed to get environment %s/%s, err: %w", projectName, envName, err))
+	}
+
+	err = commonrepo.NewProductColl().UpdateDriftDetectionConfig(envName, projectName, &commonmodels.DriftDetectionConfig{Mode: arg.Mode})
+	if err != nil {
+		return e.ErrUpdateEnvDriftConfig.AddErr(fmt.Errorf("failed to update environment %s/%s, err: %w", projectName, envName, err))
+	}
+
+	return upsertEnvDriftCron(env, arg, logger)
+}
+
+func upsertEnvDriftCron(env *commonmodels.Product, arg *EnvDriftConfigArg, logger *zap.SugaredLogger) error {
+	name := getEnvDriftCronName(env.ProductName, env.EnvName)
+	cron, err := commonrepo.NewCronjobColl().GetByName(name, config.EnvDriftCronjob)
+	if err != nil {
+		if err != mongo.ErrNoDocuments && err != mongo.ErrNilDocument {
+			return e.ErrUpdateEnvDriftConfig.AddErr(fmt.Errorf("failed to get cron job %s, err: %w", name, err))
+		}
+		if !arg.Enable {
+			return nil
+		}
+		cron = &commonmodels.Cronjob{
+			Name: name,
+			Type: config.EnvDriftCronjob,
+			EnvArgs: &commonmodels.EnvArgs{
+				Name:        name,
+				ProductName: env.ProductName,
+				EnvName:     env.EnvName,
+				Production:  env.Production,
+			},
+		}
+	}
+
+	origEnabled := cron.Enabled
+	cron.Enabled = arg.Enable
+	cron.Cron = arg.Cron
+	if err := commonrepo.NewCronjobColl().Upsert(cron); err != nil {
+		logger.Errorf("failed to upsert env drift cron job %s, err: %s", name, err)
+		return e.ErrUpsertCronjob.AddErr(err)
+	}
+
+	var payload *commonservice.CronjobPayload
+	switch {
+	case arg.Enable:
+		payload = &commonservice.CronjobPayload{
+			Name:    name,
+			JobType: config.EnvDriftCronjob,
+			Action:  setting.TypeEnableCronjob,
+			JobList: []*commonmodels.Schedule{cronJobToSchedule(cron)},
+		}
+	case origEnabled:
+		payload = &commonservice.CronjobPayload{
+			Name:       name,
+			JobType:    config.EnvDriftCronjob,
+			Action:     setting.TypeEnableCronjob,
+			DeleteList: []string{cron.ID.Hex()},
+		}
+	default:
+		return nil
+	}
+
+	pl, _ := json.Marshal(payload)
+	err = commonrepo.NewMsgQueueCommonColl().Create(&msg_queue.MsgQueueCommon{
+		Payload:   string(pl),
+		QueueType: setting.TopicCronjob,
+	})
+	if err != nil {
+		log.Errorf("Failed to publish to nsq topic: %s, the error is: %v", setting.TopicCronjob, err)
+		return e.ErrUpsertCronjob.AddDesc(err.Error())
+	}
+	return nil
+}
+
+func getEnvDriftCronName(projectName, envName string) string {
+	return fmt.Sprintf("%s-%s-%s", envName, projectName, config.EnvDriftCronjob)
+}
+
+// GetEnvDriftReport returns the drift reports saved by the latest check of the environment
+func GetEnvDriftReport(projectName, envName string, production bool, logger *zap.SugaredLogger) (*EnvDriftReportResp, error) {
+	reports, err := commonrepo.NewEnvDriftReportColl().ListByEnv(projectName, envName, production)
+	if err != nil {
+		logger.Errorf("failed to list drift reports of %s/%s, err: %s", projectName, envName, err)
+		return nil, e.ErrGetEnvDriftReport.AddErr(err)
+	}
+	return buildEnvDriftReportResp(reports), nil
+}
+
+func buildEnvDriftReportResp(reports []*commonmodels.EnvDriftReport) *EnvDriftReportResp {
+	resp := &EnvDriftReportResp{Services: reports}
+	for _, report := range reports {
+		resp.Drifted = resp.Drifted || report.Drifted
+		if report.CheckTime > resp.CheckTime {
+			resp.CheckTime = report.CheckTime
+		}
+	}
+	return resp
+}
+
+// DetectEnvDrift renders the expected manifests of every service in the environment and compares them with the live
+// objects in the cluster. When it is triggered by the cron job, the drifted services are notified, and re-applied as
+// well if the environment is in auto heal mode.
+func DetectEnvDrift(projectName, envName string, production *bool, triggerName string, logger *zap.SugaredLogger) (*EnvDriftReportResp, error) {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{
+		EnvName:    envName,
+		Name:       projectName,
+		Production: production,
+	})
+	if err != nil {
+		return nil, e.ErrDetectEnvDrift.AddErr(fmt.Errorf("failed to get environment %s/%s, err: %w", projectName, envName, err))
+	}
+	if env.IsSleeping() {
+		return nil, e.ErrDetectEnvDrift.AddDesc("environment is sleeping")
+	}
+
+	kubeClient, err := kubeclient.GetKubeClient(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return nil, e.ErrDetectEnvDrift.AddErr(err)
+	}
+
+	var helmClient *helmtool.HelmClient
+	var releaseNameMap map[string]string
+	for _, svc := range env.GetSvcList() {
+		if svc.Type != setting.HelmDeployType && svc.Type != setting.HelmChartDeployType {
+			continue
+		}
+		helmClient, err = helmtool.NewClientFromNamespace(env.ClusterID, env.Namespace)
+		if err != nil {
+			return nil, e.ErrDetectEnvDrift.AddErr(err)
+		}
+		releaseNameMap, err = commonutil.GetServiceNameToReleaseNameMap(env)
+		if err != nil {
+			return nil, e.ErrDetectEnvDrift.AddErr(err)
+		}
+		break
+	}
+
+	heal := false
+	if triggerName == setting.CronTaskCreator && env.DriftDetectionConfig != nil {
+		heal = env.DriftDetectionConfig.Mode == config.DriftDetectionModeAutoHeal
+	}
+
+	checkTime := time.Now().Unix()
+	reports := make([]*commonmodels.EnvDriftReport, 0)
+	for _, svc := range env.GetSvcList() {
+		if svc.Type == setting.PMDeployType || !commonutil.ServiceDeployed(svc.ServiceName, env.ServiceDeployStrategy) {
+			continue
+		}
+
+		report := &commonmodels.EnvDriftReport{
+			ProductName: env.ProductName,
+			EnvName:     env.EnvName,
+			Production:  env.Production,
+			ServiceName: svc.ServiceName,
+			Resources:   make([]*commonmodels.DriftResource, 0),
+			CheckTime:   checkTime,
+		}
+		reports = append(reports, report)
+
+		var manifest string
+		switch svc.Type {
+		case setting.HelmDeployType, setting.HelmChartDeployType:
+			report.ReleaseName = releaseNameMap[svc.ServiceName]
+			if svc.Type == setting.HelmChartDeployType {
+				report.ReleaseName = svc.ReleaseName
+			}
+			release, err := helmClient.GetRelease(report.ReleaseName)
+			if err != nil {
+				if errors.Is(err, driver.ErrReleaseNotFound) {
+					report.Drifted = true
+				}
+				report.Error = fmt.Sprintf("failed to get release %s, err: %s", report.ReleaseName, err)
+				continue
+			}
+			manifest = release.Manifest
+		default:
+			manifest, err = kube.RenderEnvService(env, svc.GetServiceRender(), svc)
+			if err != nil {
+				report.Error = fmt.Sprintf("failed to render service yaml, err: %s", err)
+				continue
+			}
+		}
+
+		expectedObjects, err := detectServiceDrift(env, manifest, report, kubeClient)
+		if err != nil {
+			report.Error = err.Error()
+			continue
+		}
+		if heal && report.Drifted {
+			if err := healServiceDrift(env, svc, expectedObjects, report, kubeClient); err != nil {
+				logger.Errorf("failed to heal drift of service %s in %s/%s, err: %s", svc.ServiceName, projectName, envName, err)
+				report.Error = fmt.Sprintf("failed to heal drifted resources, err: %s", err)
+			} else {
+				report.Healed = true
+			}
+		}
+	}
+
+	if err := commonrepo.NewEnvDriftReportColl().ReplaceByEnv(env.ProductName, env.EnvName, env.Production, reports); err != nil {
+		logger.Errorf("failed to save drift reports of %s/%s, err: %s", projectName, envName, err)
+		return nil, e.ErrDetectEnvDrift.AddErr(err)
+	}
+
+	resp := buildEnvDriftReportResp(reports)
+	if triggerName == setting.CronTaskCreator && resp.Drifted {
+		util.Go(func() {
+			if err := envDriftNotification(env, reports); err != nil {
+				log.Errorf("failed to send env drift notification, err: %s", err)
+			}
+		})
+	}
+	return resp, nil
+}
+
+// detectServiceDrift fills the drifted resources of the manifest into the report, the expected objects of the drifted
+// resources are returned so they can be re-applied
+func detectServiceDrift(env *commonmodels.Product, manifest string, report *commonmodels.EnvDriftReport, kubeClient client.Client) (map[*commonmodels.DriftResource]*unstructured.Unstructured, error) {
+	hpaTargets, err := listHPATargets(env.Namespace, kubeClient)
+	if err != nil {
+		return nil, err
+	}
+
+	drifted := make(map[*commonmodels.DriftResource]*unstructured.Unstructured)
+	for _, item := range util.SplitManifests(manifest) {
+		expected, err := serializer.NewDecoder().YamlToUnstructured([]byte(item))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode manifest, err: %s", err)
+		}
+		if expected.GetKind() == "" || expected.GetName() == "" {
+			continue
+		}
+		if isNamespacedResource(expected, kubeClient) {
+			expected.SetNamespace(env.Namespace)
+		}
+		normalizeDriftExpected(expected, hpaTargets)
+
+		driftResource := &commonmodels.DriftResource{
+			Kind:   expected.GetKind(),
+			Name:   expected.GetName(),
+			Fields: make([]*commonmodels.DriftField, 0),
+		}
+
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(expected.GroupVersionKind())
+		found, err := getter.GetResourceInCache(expected.GetNamespace(), expected.GetName(), live, kubeClient)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s/%s, err: %s", expected.GetKind(), expected.GetName(), err)
+		}
+		if !found {
+			driftResource.Missing = true
+		} else {
+			compareDriftFields("", expected.Object, live.Object, &driftResource.Fields)
+		}
+
+		if driftResource.Missing || len(driftResource.Fields) > 0 {
+			report.Drifted = true
+			report.Resources = append(report.Resources, driftResource)
+			drifted[driftResource] = expected
+		}
+	}
+	return drifted, nil
+}
+
+// listHPATargets returns the workloads scaled by the HorizontalPodAutoscalers in the namespace, in the form of kind/name
+func listHPATargets(namespace string, kubeClient client.Client) (sets.String, error) {
+	hpas, err := getter.ListUnstructuredResourceInCache(namespace, nil, nil, schema.GroupVersionKind{
+		Group:   "autoscaling",
+		Version: "v1",
+		Kind:    "HorizontalPodAutoscaler",
+	}, kubeClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list HorizontalPodAutoscalers, err: %s", err)
+	}
+	targets := sets.NewString()
+	for _, hpa := range hpas {
+		kind, _, _ := unstructured.NestedString(hpa.Object, "spec", "scaleTargetRef", "kind")
+		name, _, _ := unstructured.NestedString(hpa.Object, "spec", "scaleTargetRef", "name")
+		targets.Insert(kind + "/" + name)
+	}
+	return targets, nil
+}
+
+// normalizeDriftExpected removes the fields of the expected object which are not kept as they are in the cluster, so
+// that they are neither regarded as drift nor patched back when healing:
+// the replicas of a workload scaled by a HorizontalPodAutoscaler are maintained by the autoscaler, and the stringData
+// of a Secret is merged into its data by the api server.
+func normalizeDriftExpected(expected *unstructured.Unstructured, hpaTargets sets.String) {
+	if hpaTargets.Has(expected.GetKind() + "/" + expected.GetName()) {
+		unstructured.RemoveNestedField(expected.Object, "spec", "replicas")
+	}
+
+	if expected.GetKind() != setting.Secret {
+		return
+	}
+	stringData, found, err := unstructured.NestedStringMap(expected.Object, "stringData")
+	if err != nil || !found {
+		return
+	}
+	data, _, err := unstructured.NestedStringMap(expected.Object, "data")
+	if err != nil {
+		return
+	}
+	if data == nil {
+		data = make(map[string]string, len(stringData))
+	}
+	// stringData wins over data for the same key
+	for key, value := range stringData {
+		data[key] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	unstructured.RemoveNestedField(expected.Object, "stringData")
+	_ = unstructured.SetNestedStringMap(expected.Object, data, "data")
+}
+
+func isNamespacedResource(u *unstructured.Unstructured, kubeClient client.Client) bool {
+	gvk := u.GroupVersionKind()
+	mapping, err := kubeClient.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return true
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace
+}
+
+// compareDriftFields compares the fields set in the expected object with the live one. The fields only existing in
+// the live object are defaulted or added by the cluster, so they are not regarded as drift.
+func compareDriftFields(path string, expected, live interface{}, fields *[]*commonmodels.DriftField) {
+	if driftIgnoredPaths.Has(path) {
+		return
+	}
+
+	switch expectedValue := expected.(type) {
+	case map[string]interface{}:
+		liveValue, ok := live.(map[string]interface{})
+		if !ok {
+			if len(expectedValue) > 0 {
+				appendDriftField(path, expected, live, fields)
+			}
+			return
+		}
+		keys := make([]string, 0, len(expectedValue))
+		for key := range expectedValue {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			compareDriftFields(joinDriftPath(path, key), expectedValue[key], liveValue[key], fields)
+		}
+	case []interface{}:
+		liveValue, ok := live.([]interface{})
+		if !ok || len(liveValue) != len(expectedValue) {
+			if len(expectedValue) > 0 || len(liveValue) > 0 {
+				appendDriftField(path, expected, live, fields)
+			}
+			return
+		}
+		for i := range expectedValue {
+			compareDriftFields(fmt.Sprintf("%s[%d]", path, i), expectedValue[i], liveValue[i], fields)
+		}
+	case nil:
+		return
+	default:
+		if !driftScalarEqual(path, expected, live) {
+			appendDriftField(path, expected, live, fields)
+		}
+	}
+}
+
+func driftScalarEqual(path string, expected, live interface{}) bool {
+	if reflect.DeepEqual(expected, live) || fmt.Sprint(expected) == fmt.Sprint(live) {
+		return true
+	}
+	// quantities like cpu: 1000m are normalized by the api server
+	if strings.Contains(path, "resources.") {
+		expectedQuantity, err := resource.ParseQuantity(fmt.Sprint(expected))
+		if err != nil {
+			return false
+		}
+		liveQuantity, err := resource.ParseQuantity(fmt.Sprint(live))
+		if err != nil {
+			return false
+		}
+		return expectedQuantity.Cmp(liveQuantity) == 0
+	}
+	return false
+}
+
+func joinDriftPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func appendDriftField(path string, expected, live interface{}, fields *[]*commonmodels.DriftField) {
+	*fields = append(*fields, &commonmodels.DriftField{
+		Path:     path,
+		Expected: driftValueString(expected),
+		Live:     driftValueString(live),
+	})
+}
+
+func driftValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// healServiceDrift creates the missing resources and merge patches the expected manifest onto the drifted ones, fields
+// added by the cluster or by zadig like the predefined labels are kept
+func healServiceDrift(env *commonmodels.Product, svc *commonmodels.ProductService, expectedObjects map[*commonmodels.DriftResource]*unstructured.Unstructured, report *commonmodels.EnvDriftReport, kubeClient client.Client) error {
+	for _, driftResource := range report.Resources {
+		expected := expectedObjects[driftResource]
+		if expected == nil {
+			continue
+		}
+
+		if driftResource.Missing {
+			if svc.Type == setting.K8SDeployType {
+				expected.SetLabels(kube.MergeLabels(kube.GetPredefinedLabels(env.ProductName, svc.ServiceName), expected.GetLabels()))
+			}
+			if err := updater.CreateOrPatchUnstructured(expected, kubeClient); err != nil {
+				return fmt.Errorf("failed to create %s/%s, err: %s", expected.GetKind(), expected.GetName(), err)
+			}
+			continue
+		}
+
+		patchBytes, err := expected.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		if err := updater.PatchUnstructured(expected, patchBytes, types.MergePatchType, kubeClient); err != nil {
+			return fmt.Errorf("failed to patch %s/%s, err: %s", expected.GetKind(), expected.GetName(), err)
+		}
+	}
+	return nil
+}
+
+func envDriftNotification(env *commonmodels.Product, reports []*commonmodels.EnvDriftReport) error {
+	driftedServices := make([]string, 0)
+	for _, report := range reports {
+		if !report.Drifted {
+			continue
+		}
+		resources := make([]string, 0, len(report.Resources))
+		for _, driftResource := range report.Resources {
+			resources = append(resources, fmt.Sprintf("%s/%s", driftResource.Kind, driftResource.Name))
+		}
+		line := fmt.Sprintf("- %s: %s", report.ServiceName, strings.Join(resources, ", "))
+		if report.Healed {
+			line += " (已自动修复)"
+		}
+		driftedServices = append(driftedServices, line)
+	}
+
+	title := fmt.Sprintf("%s / %s 环境配置漂移", env.ProductName, env.EnvName)
+	envDetailURL := fmt.Sprintf("%s/v1/projects/detail/%s/envs/detail?envName=%s", configbase.SystemAddress(), env.ProductName, env.EnvName)
+	content := fmt.Sprintf("**检测时间：%s** \n%s \n\n[点击查看更多信息](%s)", time.Now().Format("2006-01-02 15:04:05"), strings.Join(driftedServices, "\n"), envDetailURL)
+
+	imnotifyClient := imnotify.NewIMNotifyClient()
+	for _, notifyConfig := range env.NotificationConfigs {
+		found := false
+		for _, event := range notifyConfig.Events {
+			if event == commonmodels.NotificationEventDriftDetected {
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		var err error
+		switch imnotify.IMNotifyType(notifyConfig.WebHookType) {
+		case imnotify.IMNotifyTypeDingDing:
+			err = imnotifyClient.SendDingDingMessage(notifyConfig.WebHookURL, title, fmt.Sprintf("### %s \n%s", title, content), nil, false)
+		case imnotify.IMNotifyTypeLark:
+			err = imnotifyClient.SendFeishuMessageOfSingleType(title, notifyConfig.WebHookURL, content)
+		case imnotify.IMNotifyTypeWeChat:
+			err = imnotifyClient.SendWeChatWorkMessage(imnotify.WeChatTextTypeMarkdown, notifyConfig.WebHookURL, fmt.Sprintf("### %s \n%s", title, content))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
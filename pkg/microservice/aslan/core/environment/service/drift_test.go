@@ -0,0 +1,193 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/tool/kube/serializer"
+)
+
+var testDriftDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  labels:
+    app: nginx
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.25
+        resources:
+          limits:
+            cpu: "1"
+            memory: 1Gi
+`
+
+var testDriftSecret = `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+data:
+  user: cm9vdA==
+  password: b2xk
+stringData:
+  password: new
+`
+
+var _ = Describe("Testing env drift", func() {
+
+	decode := func(manifest string) *unstructured.Unstructured {
+		u, err := serializer.NewDecoder().YamlToUnstructured([]byte(manifest))
+		Expect(err).ShouldNot(HaveOccurred())
+		return u
+	}
+	compare := func(expected, live *unstructured.Unstructured) []*commonmodels.DriftField {
+		fields := make([]*commonmodels.DriftField, 0)
+		compareDriftFields("", expected.Object, live.Object, &fields)
+		return fields
+	}
+
+	Describe("test compareDriftFields", func() {
+		It("should ignore the fields maintained by the cluster", func() {
+			expected := decode(testDriftDeployment)
+			live := decode(testDriftDeployment)
+			live.SetNamespace("test")
+			live.SetResourceVersion("100")
+			live.SetUID("uid")
+			live.SetAnnotations(map[string]string{"deployment.kubernetes.io/revision": "3"})
+			live.Object["status"] = map[string]interface{}{"replicas": int64(2)}
+			expected.SetAnnotations(map[string]string{"deployment.kubernetes.io/revision": "1"})
+			Expect(compare(expected, live)).To(BeEmpty())
+		})
+
+		It("should ignore the fields only existing in the live object", func() {
+			expected := decode(testDriftDeployment)
+			live := decode(testDriftDeployment)
+			Expect(unstructured.SetNestedField(live.Object, "RollingUpdate", "spec", "strategy", "type")).To(Succeed())
+			Expect(unstructured.SetNestedField(live.Object, "Always", "spec", "template", "spec", "restartPolicy")).To(Succeed())
+			Expect(compare(expected, live)).To(BeEmpty())
+		})
+
+		It("should report the changed scalars with their paths", func() {
+			expected := decode(testDriftDeployment)
+			live := decode(testDriftDeployment)
+			Expect(unstructured.SetNestedField(live.Object, int64(5), "spec", "replicas")).To(Succeed())
+			Expect(unstructured.SetNestedField(live.Object, "debug", "metadata", "labels", "app")).To(Succeed())
+			fields := compare(expected, live)
+			Expect(fields).To(Equal([]*commonmodels.DriftField{
+				{Path: "metadata.labels.app", Expected: "nginx", Live: "debug"},
+				{Path: "spec.replicas", Expected: "2", Live: "5"},
+			}))
+		})
+
+		It("should report the changed lists", func() {
+			expected := decode(testDriftDeployment)
+			live := decode(testDriftDeployment)
+			containers, _, _ := unstructured.NestedSlice(live.Object, "spec", "template", "spec", "containers")
+			container := containers[0].(map[string]interface{})
+			container["image"] = "nginx:latest"
+			containers = append(containers, map[string]interface{}{"name": "sidecar"})
+			Expect(unstructured.SetNestedSlice(live.Object, containers[:1], "spec", "template", "spec", "containers")).To(Succeed())
+			Expect(compare(expected, live)).To(Equal([]*commonmodels.DriftField{
+				{Path: "spec.template.spec.containers[0].image", Expected: "nginx:1.25", Live: "nginx:latest"},
+			}))
+
+			Expect(unstructured.SetNestedSlice(live.Object, containers, "spec", "template", "spec", "containers")).To(Succeed())
+			fields := compare(expected, live)
+			Expect(fields).To(HaveLen(1))
+			Expect(fields[0].Path).To(Equal("spec.template.spec.containers"))
+		})
+
+		It("should report the fields removed from the live object", func() {
+			expected := decode(testDriftDeployment)
+			live := decode(testDriftDeployment)
+			unstructured.RemoveNestedField(live.Object, "metadata", "labels")
+			Expect(compare(expected, live)).To(Equal([]*commonmodels.DriftField{
+				{Path: "metadata.labels", Expected: `{"app":"nginx"}`, Live: ""},
+			}))
+		})
+	})
+
+	Describe("test driftScalarEqual", func() {
+		It("should compare the scalars", func() {
+			Expect(driftScalarEqual("spec.replicas", int64(2), int64(2))).To(BeTrue())
+			Expect(driftScalarEqual("spec.replicas", int64(2), float64(2))).To(BeTrue())
+			Expect(driftScalarEqual("spec.replicas", "2", int64(2))).To(BeTrue())
+			Expect(driftScalarEqual("spec.replicas", int64(2), int64(3))).To(BeFalse())
+			Expect(driftScalarEqual("metadata.name", "a", nil)).To(BeFalse())
+		})
+
+		It("should compare the normalized quantities of the resources", func() {
+			path := "spec.template.spec.containers[0].resources.limits.cpu"
+			Expect(driftScalarEqual(path, "1000m", "1")).To(BeTrue())
+			Expect(driftScalarEqual(path, "0.5", "500m")).To(BeTrue())
+			Expect(driftScalarEqual(path, "1", "2")).To(BeFalse())
+			Expect(driftScalarEqual(path, "invalid", "1")).To(BeFalse())
+			Expect(driftScalarEqual("spec.template.spec.containers[0].resources.limits.memory", "1024Mi", "1Gi")).To(BeTrue())
+			Expect(driftScalarEqual("metadata.labels.cpu", "1000m", "1")).To(BeFalse())
+		})
+	})
+
+	Describe("test normalizeDriftExpected", func() {
+		It("should remove the replicas of the workloads scaled by HPA", func() {
+			expected := decode(testDriftDeployment)
+			normalizeDriftExpected(expected, sets.NewString("Deployment/other"))
+			_, found, _ := unstructured.NestedFieldNoCopy(expected.Object, "spec", "replicas")
+			Expect(found).To(BeTrue())
+
+			normalizeDriftExpected(expected, sets.NewString("Deployment/nginx"))
+			_, found, _ = unstructured.NestedFieldNoCopy(expected.Object, "spec", "replicas")
+			Expect(found).To(BeFalse())
+
+			live := decode(testDriftDeployment)
+			Expect(unstructured.SetNestedField(live.Object, int64(8), "spec", "replicas")).To(Succeed())
+			Expect(compare(expected, live)).To(BeEmpty())
+		})
+
+		It("should merge the stringData of a Secret into its data", func() {
+			expected := decode(testDriftSecret)
+			normalizeDriftExpected(expected, sets.NewString())
+			_, found, _ := unstructured.NestedFieldNoCopy(expected.Object, "stringData")
+			Expect(found).To(BeFalse())
+			data, _, _ := unstructured.NestedStringMap(expected.Object, "data")
+			Expect(data).To(Equal(map[string]string{"user": "cm9vdA==", "password": "bmV3"}))
+
+			live := decode(testDriftSecret)
+			unstructured.RemoveNestedField(live.Object, "stringData")
+			Expect(unstructured.SetNestedStringMap(live.Object, map[string]string{"user": "cm9vdA==", "password": "bmV3"}, "data")).To(Succeed())
+			Expect(compare(expected, live)).To(BeEmpty())
+		})
+
+		It("should keep the Secret without stringData", func() {
+			expected := decode(testDriftSecret)
+			unstructured.RemoveNestedField(expected.Object, "stringData")
+			normalizeDriftExpected(expected, sets.NewString())
+			data, _, _ := unstructured.NestedStringMap(expected.Object, "data")
+			Expect(data).To(Equal(map[string]string{"user": "cm9vdA==", "password": "b2xk"}))
+		})
+	})
+})
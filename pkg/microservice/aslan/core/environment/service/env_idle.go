@@ -0,0 +1,800 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configbase "github.com/koderover/zadig/pkg/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/setting"
+	kubeclient "github.com/koderover/zadig/pkg/shared/kube/client"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
+	"github.com/koderover/zadig/pkg/tool/kube/updater"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+const (
+	wakeProxyName = "zadig-wake-proxy"
+	wakeProxyPort = 8080
+	// wakeProxyOriginSpecAnnotation keeps the original spec of an ingress while its backends point to the wake proxy
+	wakeProxyOriginSpecAnnotation = "zadig-wake-proxy-origin-spec"
+	wakeProxyPath                 = "/__zadig_wake"
+
+	envWakeTimeout = 10 * time.Minute
+
+	ingressNginxSelector       = "app.kubernetes.io/name=ingress-nginx"
+	ingressNginxMetricsPort    = "10254"
+	ingressNginxRequestsMetric = "nginx_ingress_controller_requests"
+
+	EnvWakeStatusWaking = "waking"
+	EnvWakeStatusReady  = "ready"
+)
+
+// wakingEnvs holds the environments being woken up by the wake proxy, so the requests coming in meanwhile do not
+// trigger the wake path again
+var wakingEnvs sync.Map
+
+type EnvIdlePolicyArg struct {
+	Enable        bool `json:"enable"`
+	IdleHours     int  `json:"idle_hours"`
+	WakeOnRequest bool `json:"wake_on_request"`
+}
+
+type EnvIdlePolicyResp struct {
+	*EnvIdlePolicyArg
+	LastActiveTime int64 `json:"last_active_time"`
+	Sleeping       bool  `json:"sleeping"`
+}
+
+type EnvWakeResp struct {
+	Status string `json:"status"`
+}
+
+func GetEnvIdlePolicy(projectName, envName string, logger *zap.SugaredLogger) (*EnvIdlePolicyResp, error) {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{
+		EnvName:    envName,
+		Name:       projectName,
+		Production: util.GetBoolPointer(false),
+	})
+	if err != nil {
+		return nil, e.ErrGetEnvConfigs.AddErr(fmt.Errorf("failed to get environment %s/%s, err: %w", projectName, envName, err))
+	}
+
+	resp := &EnvIdlePolicyResp{
+		EnvIdlePolicyArg: &EnvIdlePolicyArg{},
+		Sleeping:         env.IsSleeping(),
+	}
+	if env.IdlePolicy != nil {
+		resp.Enable = env.IdlePolicy.Enable
+		resp.IdleHours = env.IdlePolicy.IdleHours
+		resp.WakeOnRequest = env.IdlePolicy.WakeOnRequest
+	}
+
+	state, err := commonrepo.NewEnvIdleStateColl().Find(projectName, envName)
+	if err == nil {
+		resp.LastActiveTime = state.LastActiveTime
+	} else if err != mongo.ErrNoDocuments {
+		logger.Warnf("failed to get idle state of %s/%s, err: %s", projectName, envName, err)
+	}
+	return resp, nil
+}
+
+// UpdateEnvIdlePolicy sets the idle policy of a test environment, the idle time is counted from now on
+func UpdateEnvIdlePolicy(projectName, envName string, arg *EnvIdlePolicyArg, logger *zap.SugaredLogger) error {
+	if arg.Enable && arg.IdleHours <= 0 {
+		return e.ErrUpdateEnvConfigs.AddDesc("idle hours must be greater than 0")
+	}
+
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{
+		EnvName:    envName,
+		Name:       projectName,
+		Production: util.GetBoolPointer(false),
+	})
+	if err != nil {
+		return e.ErrUpdateEnvConfigs.AddErr(fmt.Errorf("failed to get environment %s/%s, err: %w", projectName, envName, err))
+	}
+
+	policy := &commonmodels.EnvIdlePolicy{
+		Enable:        arg.Enable,
+		IdleHours:     arg.IdleHours,
+		WakeOnRequest: arg.WakeOnRequest,
+		WakeToken:     strings.ReplaceAll(uuid.New().String(), "-", ""),
+	}
+	if env.IdlePolicy != nil && env.IdlePolicy.WakeToken != "" {
+		policy.WakeToken = env.IdlePolicy.WakeToken
+	}
+	if err := commonrepo.NewProductColl().UpdateIdlePolicy(envName, projectName, policy); err != nil {
+		return e.ErrUpdateEnvConfigs.AddErr(fmt.Errorf("failed to update idle policy of %s/%s, err: %w", projectName, envName, err))
+	}
+	env.IdlePolicy = policy
+
+	if err := resetEnvIdleState(env); err != nil {
+		logger.Errorf("failed to reset idle state of %s/%s, err: %s", projectName, envName, err)
+	}
+
+	// the wake proxy of a sleeping environment follows the new policy at once
+	if env.IsSleeping() {
+		if policy.WakeOnRequest {
+			err = enableEnvWakeProxy(env)
+		} else {
+			err = disableEnvWakeProxy(env)
+		}
+		if err != nil {
+			return e.ErrUpdateEnvConfigs.AddErr(fmt.Errorf("failed to update wake proxy of %s/%s, err: %w", projectName, envName, err))
+		}
+	}
+	return nil
+}
+
+func resetEnvIdleState(env *commonmodels.Product) error {
+	state, err := commonrepo.NewEnvIdleStateColl().Find(env.ProductName, env.EnvName)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			return err
+		}
+		state = &commonmodels.EnvIdleState{
+			ProductName: env.ProductName,
+			EnvName:     env.EnvName,
+		}
+	}
+	state.LastActiveTime = time.Now().Unix()
+	return commonrepo.NewEnvIdleStateColl().Upsert(state)
+}
+
+// EnvIdleSleepCronJob puts the test environments to sleep when they have had no ingress traffic and no deployment
+// for longer than the idle hours of their idle policies
+func EnvIdleSleepCronJob(logger *zap.SugaredLogger) {
+	envs, err := commonrepo.NewProductColl().List(&commonrepo.ProductListOptions{
+		Production: util.GetBoolPointer(false),
+	})
+	if err != nil {
+		logger.Errorf("failed to list environments, err: %s", err)
+		return
+	}
+
+	for _, env := range envs {
+		if env.IdlePolicy == nil || !env.IdlePolicy.Enable || env.IdlePolicy.IdleHours <= 0 {
+			continue
+		}
+		if env.Status != setting.ProductStatusSuccess && env.Status != setting.ProductStatusUnstable {
+			continue
+		}
+
+		idle, err := checkEnvIdle(env, logger)
+		if err != nil {
+			logger.Errorf("failed to check idle state of %s/%s, err: %s", env.ProductName, env.EnvName, err)
+			continue
+		}
+		if !idle {
+			continue
+		}
+
+		logger.Infof("environment %s/%s has been idle for %d hours, put it to sleep", env.ProductName, env.EnvName, env.IdlePolicy.IdleHours)
+		if err := EnvSleep(env.ProductName, env.EnvName, true, false, logger); err != nil {
+			logger.Errorf("failed to put idle environment %s/%s to sleep, err: %s", env.ProductName, env.EnvName, err)
+		}
+	}
+}
+
+// checkEnvIdle updates the last active time of the environment with its ingress traffic and deployments, and tells
+// whether the environment has been idle for long enough. The environment is never idle if its traffic can not be read.
+func checkEnvIdle(env *commonmodels.Product, logger *zap.SugaredLogger) (bool, error) {
+	now := time.Now().Unix()
+	state, err := commonrepo.NewEnvIdleStateColl().Find(env.ProductName, env.EnvName)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			return false, err
+		}
+		state = &commonmodels.EnvIdleState{
+			ProductName:    env.ProductName,
+			EnvName:        env.EnvName,
+			LastActiveTime: now,
+		}
+	}
+
+	// the counters are cumulative, any change including a reset of the controller means there was traffic
+	requestCount, err := getEnvIngressRequestCount(env)
+	metricsRead := err == nil
+	if !metricsRead {
+		logger.Warnf("failed to get ingress request count of %s/%s, skip putting it to sleep, err: %s", env.ProductName, env.EnvName, err)
+	} else if requestCount != state.RequestCount {
+		state.RequestCount = requestCount
+		state.LastActiveTime = now
+	}
+
+	for _, svc := range env.GetSvcList() {
+		if svc.UpdateTime > state.LastActiveTime {
+			state.LastActiveTime = svc.UpdateTime
+		}
+	}
+
+	if err := commonrepo.NewEnvIdleStateColl().Upsert(state); err != nil {
+		return false, err
+	}
+	// without the traffic the environment can not be told idle, it may be in use without any deployment
+	if !metricsRead {
+		return false, nil
+	}
+	return now-state.LastActiveTime >= int64(env.IdlePolicy.IdleHours)*3600, nil
+}
+
+// getEnvIngressRequestCount sums the request counters of the environment's namespace exposed by the ingress-nginx
+// controllers in the cluster
+func getEnvIngressRequestCount(env *commonmodels.Product) (float64, error) {
+	clientset, err := kubeclient.GetKubeClientSet(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return 0, err
+	}
+
+	pods, err := clientset.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{LabelSelector: ingressNginxSelector})
+	if err != nil {
+		return 0, err
+	}
+
+	var count float64
+	scraped := false
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		data, err := clientset.CoreV1().Pods(pod.Namespace).ProxyGet("http", pod.Name, ingressNginxMetricsPort, "metrics", nil).DoRaw(context.TODO())
+		if err != nil {
+			log.Warnf("failed to get metrics of ingress controller %s/%s, err: %s", pod.Namespace, pod.Name, err)
+			continue
+		}
+		count += sumIngressRequests(data, env.Namespace)
+		scraped = true
+	}
+	if !scraped {
+		return 0, fmt.Errorf("no ingress-nginx controller metrics found")
+	}
+	return count, nil
+}
+
+func sumIngressRequests(metrics []byte, namespace string) float64 {
+	var sum float64
+	namespaceLabel := fmt.Sprintf(`namespace="%s"`, namespace)
+	scanner := bufio.NewScanner(bytes.NewReader(metrics))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, ingressNginxRequestsMetric+"{") {
+			continue
+		}
+		end := strings.LastIndex(line, "}")
+		if end < 0 {
+			continue
+		}
+		// controller_namespace also ends with namespace="...", so the label has to start the label set or follow a comma
+		labelSet := line[len(ingressNginxRequestsMetric)+1 : end]
+		if !strings.HasPrefix(labelSet, namespaceLabel) && !strings.Contains(labelSet, ","+namespaceLabel) {
+			continue
+		}
+		fields := strings.Fields(line[end+1:])
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		sum += value
+	}
+	return sum
+}
+
+// WakeEnvByToken is called by the wake proxy of a sleeping environment, it wakes the environment up and tells
+// whether the environment is ready to serve
+func WakeEnvByToken(token string, logger *zap.SugaredLogger) (*EnvWakeResp, error) {
+	env, err := commonrepo.NewProductColl().FindByWakeToken(token)
+	if err != nil {
+		return nil, e.ErrEnvSleep.AddDesc("environment not found")
+	}
+	if env.IdlePolicy == nil || !env.IdlePolicy.WakeOnRequest {
+		return nil, e.ErrEnvSleep.AddDesc("wake on request is not enabled")
+	}
+
+	if env.IsSleeping() {
+		key := fmt.Sprintf("%s/%s", env.ProductName, env.EnvName)
+		if _, loaded := wakingEnvs.LoadOrStore(key, struct{}{}); !loaded {
+			logger.Infof("wake up environment %s/%s on request", env.ProductName, env.EnvName)
+			util.Go(func() {
+				if err := EnvSleep(env.ProductName, env.EnvName, false, env.Production, log.SugaredLogger()); err != nil {
+					log.Errorf("failed to wake up environment %s/%s, err: %s", env.ProductName, env.EnvName, err)
+					wakingEnvs.Delete(key)
+				}
+			})
+		}
+		return &EnvWakeResp{Status: EnvWakeStatusWaking}, nil
+	}
+
+	active, err := envWakeProxyActive(env)
+	if err != nil {
+		return nil, e.ErrEnvSleep.AddErr(err)
+	}
+	if active {
+		return &EnvWakeResp{Status: EnvWakeStatusWaking}, nil
+	}
+	return &EnvWakeResp{Status: EnvWakeStatusReady}, nil
+}
+
+// onEnvSleepStatusChanged puts the wake proxy in front of the ingresses when an environment with wake on request
+// goes to sleep, and takes it away once the workloads are ready after the environment wakes up
+func onEnvSleepStatusChanged(env *commonmodels.Product, sleeping bool, logger *zap.SugaredLogger) {
+	if env.IdlePolicy == nil {
+		return
+	}
+
+	if sleeping {
+		if env.IdlePolicy.WakeOnRequest {
+			if err := enableEnvWakeProxy(env); err != nil {
+				logger.Errorf("failed to enable wake proxy of %s/%s, err: %s", env.ProductName, env.EnvName, err)
+			}
+		}
+		return
+	}
+
+	if err := resetEnvIdleState(env); err != nil {
+		logger.Errorf("failed to reset idle state of %s/%s, err: %s", env.ProductName, env.EnvName, err)
+	}
+	util.Go(func() {
+		defer wakingEnvs.Delete(fmt.Sprintf("%s/%s", env.ProductName, env.EnvName))
+
+		if err := waitEnvWorkloadsReady(env, envWakeTimeout); err != nil {
+			log.Warnf("workloads of %s/%s are not ready, err: %s", env.ProductName, env.EnvName, err)
+		}
+		if err := disableEnvWakeProxy(env); err != nil {
+			log.Errorf("failed to disable wake proxy of %s/%s, err: %s", env.ProductName, env.EnvName, err)
+		}
+	})
+}
+
+func waitEnvWorkloadsReady(env *commonmodels.Product, timeout time.Duration) error {
+	kubeClient, err := kubeclient.GetKubeClient(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		ready, err := envWorkloadsReady(env.Namespace, kubeClient)
+		if err != nil {
+			log.Warnf("failed to check workloads of %s/%s, err: %s", env.ProductName, env.EnvName, err)
+		} else if ready {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			return fmt.Errorf("timeout after %s", timeout)
+		}
+	}
+}
+
+func envWorkloadsReady(namespace string, kubeClient client.Client) (bool, error) {
+	deployments, err := getter.ListDeployments(namespace, nil, kubeClient)
+	if err != nil {
+		return false, err
+	}
+	for _, deployment := range deployments {
+		if deployment.Name == wakeProxyName || deployment.Spec.Replicas == nil {
+			continue
+		}
+		if deployment.Status.ReadyReplicas < *deployment.Spec.Replicas {
+			return false, nil
+		}
+	}
+
+	statefulSets, err := getter.ListStatefulSets(namespace, nil, kubeClient)
+	if err != nil {
+		return false, err
+	}
+	for _, sts := range statefulSets {
+		if sts.Spec.Replicas != nil && sts.Status.ReadyReplicas < *sts.Spec.Replicas {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+type wakeProxyRenderArgs struct {
+	EnvName   string
+	WakeURL   string
+	ZadigHost string
+	Port      int
+	WakePath  string
+}
+
+const wakeProxyNginxConf = `server {
+    listen {{.Port}};
+
+    location = {{.WakePath}} {
+        proxy_pass {{.WakeURL}};
+        proxy_set_header Host {{.ZadigHost}};
+        proxy_ssl_server_name on;
+    }
+
+    location = /index.html {
+        internal;
+        root /usr/share/nginx/html;
+        add_header Cache-Control "no-store" always;
+        add_header Retry-After 10 always;
+    }
+
+    location / {
+        return 503;
+    }
+
+    error_page 503 /index.html;
+}
+`
+
+const wakeProxyPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{.EnvName}}</title>
+</head>
+<body style="font-family: sans-serif; text-align: center; padding-top: 20vh;">
+  <h2>环境 {{.EnvName}} 正在唤醒，请稍候...</h2>
+  <p>The environment {{.EnvName}} is waking up, this page will be refreshed once it is ready.</p>
+  <script>
+    function wake() {
+      fetch("{{.WakePath}}", {method: "POST"}).then(function (resp) {
+        var contentType = resp.headers.get("content-type") || "";
+        // the request does not reach the wake proxy anymore, the environment is serving
+        if (contentType.indexOf("application/json") < 0) {
+          location.reload();
+          return;
+        }
+        return resp.json().then(function (data) {
+          if (resp.ok && data.status === "{{.Ready}}") {
+            location.reload();
+          } else {
+            setTimeout(wake, 3000);
+          }
+        });
+      }).catch(function () {
+        setTimeout(wake, 3000);
+      });
+    }
+    wake();
+  </script>
+</body>
+</html>
+`
+
+func renderWakeProxyTemplate(tpl string, args *wakeProxyRenderArgs) (string, error) {
+	t, err := template.New(wakeProxyName).Parse(strings.ReplaceAll(tpl, "{{.Ready}}", EnvWakeStatusReady))
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, args); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// enableEnvWakeProxy deploys the wake proxy in the environment's namespace and points the backends of all the
+// ingresses to it, the original specs are kept in the annotations of the ingresses
+func enableEnvWakeProxy(env *commonmodels.Product) error {
+	if env.IdlePolicy == nil || env.IdlePolicy.WakeToken == "" {
+		return fmt.Errorf("wake token of %s/%s is empty", env.ProductName, env.EnvName)
+	}
+
+	kubeClient, err := kubeclient.GetKubeClient(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return err
+	}
+	clientset, err := kubeclient.GetKubeClientSet(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return err
+	}
+	version, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return err
+	}
+
+	if err := deployWakeProxy(env, kubeClient, clientset); err != nil {
+		return err
+	}
+
+	lessThan122 := kubeclient.VersionLessThan122(version)
+	ingresses, err := getter.ListIngresses(env.Namespace, kubeClient, lessThan122)
+	if err != nil {
+		return err
+	}
+	for i := range ingresses.Items {
+		ingress := &ingresses.Items[i]
+		annotations := ingress.GetAnnotations()
+		if _, ok := annotations[wakeProxyOriginSpecAnnotation]; ok {
+			continue
+		}
+
+		spec, ok := ingress.Object["spec"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		originSpec, err := json.Marshal(spec)
+		if err != nil {
+			return err
+		}
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[wakeProxyOriginSpecAnnotation] = string(originSpec)
+		ingress.SetAnnotations(annotations)
+		setIngressBackends(spec, lessThan122)
+
+		if err := updater.UpdateOrCreateUnstructured(ingress, kubeClient); err != nil {
+			return fmt.Errorf("failed to point ingress %s to the wake proxy, err: %s", ingress.GetName(), err)
+		}
+	}
+	return nil
+}
+
+func deployWakeProxy(env *commonmodels.Product, kubeClient client.Client, clientset *kubernetes.Clientset) error {
+	systemAddress, err := url.Parse(configbase.SystemAddress())
+	if err != nil {
+		return fmt.Errorf("invalid system address %s, err: %s", configbase.SystemAddress(), err)
+	}
+	args := &wakeProxyRenderArgs{
+		EnvName:   env.EnvName,
+		WakeURL:   fmt.Sprintf("%s/api/aslan/environment/wake/%s", strings.TrimSuffix(configbase.SystemAddress(), "/"), env.IdlePolicy.WakeToken),
+		ZadigHost: systemAddress.Host,
+		Port:      wakeProxyPort,
+		WakePath:  wakeProxyPath,
+	}
+	nginxConf, err := renderWakeProxyTemplate(wakeProxyNginxConf, args)
+	if err != nil {
+		return err
+	}
+	page, err := renderWakeProxyTemplate(wakeProxyPage, args)
+	if err != nil {
+		return err
+	}
+
+	proxyLabels := map[string]string{wakeProxyName: "true"}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      wakeProxyName,
+			Namespace: env.Namespace,
+			Labels:    proxyLabels,
+		},
+		Data: map[string]string{
+			"default.conf": nginxConf,
+			"index.html":   page,
+		},
+	}
+	oldCM, found, err := getter.GetConfigMap(env.Namespace, wakeProxyName, kubeClient)
+	if err != nil {
+		return err
+	}
+	if found {
+		cm.ResourceVersion = oldCM.ResourceVersion
+		err = updater.UpdateConfigMap(env.Namespace, cm, clientset)
+	} else {
+		err = updater.CreateConfigMap(cm, kubeClient)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply wake proxy configmap, err: %s", err)
+	}
+
+	replicas := int32(1)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      wakeProxyName,
+			Namespace: env.Namespace,
+			Labels:    proxyLabels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: proxyLabels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: proxyLabels,
+					// the pod is restarted when the configmap changes
+					Annotations: map[string]string{wakeProxyName + "-config": fmt.Sprintf("%d", time.Now().Unix())},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "nginx",
+							Image: config.WakeProxyImage(),
+							Ports: []corev1.ContainerPort{{ContainerPort: wakeProxyPort}},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "config", MountPath: "/etc/nginx/conf.d/default.conf", SubPath: "default.conf"},
+								{Name: "config", MountPath: "/usr/share/nginx/html/index.html", SubPath: "index.html"},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: wakeProxyName},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if err := updater.CreateOrPatchDeployment(deployment, kubeClient); err != nil {
+		return fmt.Errorf("failed to apply wake proxy deployment, err: %s", err)
+	}
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      wakeProxyName,
+			Namespace: env.Namespace,
+			Labels:    proxyLabels,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: proxyLabels,
+			Ports: []corev1.ServicePort{
+				{Name: "http", Port: 80, TargetPort: intstr.FromInt(wakeProxyPort)},
+			},
+		},
+	}
+	if err := updater.CreateOrPatchService(svc, kubeClient); err != nil {
+		return fmt.Errorf("failed to apply wake proxy service, err: %s", err)
+	}
+	return nil
+}
+
+func setIngressBackends(spec map[string]interface{}, lessThan122 bool) {
+	backend := func() map[string]interface{} {
+		if lessThan122 {
+			return map[string]interface{}{"serviceName": wakeProxyName, "servicePort": int64(80)}
+		}
+		return map[string]interface{}{
+			"service": map[string]interface{}{
+				"name": wakeProxyName,
+				"port": map[string]interface{}{"number": int64(80)},
+			},
+		}
+	}
+
+	if _, ok := spec["defaultBackend"]; ok {
+		spec["defaultBackend"] = backend()
+	}
+	if _, ok := spec["backend"]; ok {
+		spec["backend"] = backend()
+	}
+	rules, _ := spec["rules"].([]interface{})
+	for _, rule := range rules {
+		ruleMap, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		paths, _, _ := unstructured.NestedSlice(ruleMap, "http", "paths")
+		for _, path := range paths {
+			if pathMap, ok := path.(map[string]interface{}); ok {
+				pathMap["backend"] = backend()
+			}
+		}
+		if len(paths) > 0 {
+			_ = unstructured.SetNestedSlice(ruleMap, paths, "http", "paths")
+		}
+	}
+}
+
+// disableEnvWakeProxy restores the ingresses pointing to the wake proxy and removes the wake proxy
+func disableEnvWakeProxy(env *commonmodels.Product) error {
+	kubeClient, err := kubeclient.GetKubeClient(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return err
+	}
+	clientset, err := kubeclient.GetKubeClientSet(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return err
+	}
+	version, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return err
+	}
+
+	ingresses, err := getter.ListIngresses(env.Namespace, kubeClient, kubeclient.VersionLessThan122(version))
+	if err != nil {
+		return err
+	}
+	for i := range ingresses.Items {
+		ingress := &ingresses.Items[i]
+		annotations := ingress.GetAnnotations()
+		originSpec, ok := annotations[wakeProxyOriginSpecAnnotation]
+		if !ok {
+			continue
+		}
+		delete(annotations, wakeProxyOriginSpecAnnotation)
+		ingress.SetAnnotations(annotations)
+
+		// the ingress may have been re-deployed while sleeping, then it is not pointing to the wake proxy any more
+		spec, _ := ingress.Object["spec"].(map[string]interface{})
+		if ingressUsesWakeProxy(spec) {
+			restored := make(map[string]interface{})
+			if err := json.Unmarshal([]byte(originSpec), &restored); err != nil {
+				return fmt.Errorf("failed to restore ingress %s, err: %s", ingress.GetName(), err)
+			}
+			ingress.Object["spec"] = restored
+		}
+
+		if err := updater.UpdateOrCreateUnstructured(ingress, kubeClient); err != nil {
+			return fmt.Errorf("failed to restore ingress %s, err: %s", ingress.GetName(), err)
+		}
+	}
+
+	selector := labels.SelectorFromSet(map[string]string{wakeProxyName: "true"})
+	if err := updater.DeleteDeployments(env.Namespace, selector, clientset); err != nil {
+		return err
+	}
+	if err := updater.DeleteServices(env.Namespace, selector, clientset); err != nil {
+		return err
+	}
+	return updater.DeleteConfigMaps(env.Namespace, selector, clientset)
+}
+
+func ingressUsesWakeProxy(spec map[string]interface{}) bool {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(data), fmt.Sprintf(`"%s"`, wakeProxyName))
+}
+
+func envWakeProxyActive(env *commonmodels.Product) (bool, error) {
+	kubeClient, err := kubeclient.GetKubeClient(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return false, err
+	}
+	_, found, err := getter.GetDeployment(env.Namespace, wakeProxyName, kubeClient)
+	return found, err
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var testIngressNginxMetrics = `# HELP nginx_ingress_controller_requests The total number of client requests
+# TYPE nginx_ingress_controller_requests counter
+nginx_ingress_controller_requests{controller_class="k8s.io/ingress-nginx",controller_namespace="ingress-nginx",controller_pod="c1",host="a.example.com",ingress="web",method="GET",namespace="dev",path="/",service="web",status="200"} 12
+nginx_ingress_controller_requests{controller_class="k8s.io/ingress-nginx",controller_namespace="ingress-nginx",controller_pod="c1",host="a.example.com",ingress="web",method="POST",namespace="dev",path="/",service="web",status="500"} 3
+nginx_ingress_controller_requests{namespace="dev",ingress="api",status="200"} 5
+nginx_ingress_controller_requests{controller_class="k8s.io/ingress-nginx",controller_namespace="dev",controller_pod="c1",ingress="web",namespace="prod",status="200"} 100
+nginx_ingress_controller_requests{controller_namespace="ingress-nginx",namespace="dev-2",status="200"} 7
+nginx_ingress_controller_requests_total{namespace="dev",status="200"} 1000
+nginx_ingress_controller_request_duration_seconds_count{namespace="dev",status="200"} 1000
+nginx_ingress_controller_requests{namespace="dev",status="200"} invalid
+`
+
+var _ = Describe("Testing env idle", func() {
+
+	Describe("test sumIngressRequests", func() {
+		It("should sum the requests of the namespace", func() {
+			Expect(sumIngressRequests([]byte(testIngressNginxMetrics), "dev")).To(Equal(float64(20)))
+			Expect(sumIngressRequests([]byte(testIngressNginxMetrics), "prod")).To(Equal(float64(100)))
+			Expect(sumIngressRequests([]byte(testIngressNginxMetrics), "dev-2")).To(Equal(float64(7)))
+		})
+
+		It("should not match the namespace of the controller", func() {
+			Expect(sumIngressRequests([]byte(testIngressNginxMetrics), "ingress-nginx")).To(Equal(float64(0)))
+		})
+
+		It("should return 0 without metrics", func() {
+			Expect(sumIngressRequests(nil, "dev")).To(Equal(float64(0)))
+		})
+	})
+
+	Describe("test setIngressBackends", func() {
+		wakeProxyBackend := map[string]interface{}{
+			"service": map[string]interface{}{
+				"name": wakeProxyName,
+				"port": map[string]interface{}{"number": int64(80)},
+			},
+		}
+		legacyWakeProxyBackend := map[string]interface{}{"serviceName": wakeProxyName, "servicePort": int64(80)}
+		paths := func(spec map[string]interface{}, rule int) []interface{} {
+			rules := spec["rules"].([]interface{})
+			paths, _, _ := unstructured.NestedSlice(rules[rule].(map[string]interface{}), "http", "paths")
+			return paths
+		}
+
+		It("should point all the backends to the wake proxy", func() {
+			spec := map[string]interface{}{
+				"defaultBackend": map[string]interface{}{
+					"service": map[string]interface{}{"name": "web", "port": map[string]interface{}{"name": "http"}},
+				},
+				"rules": []interface{}{
+					map[string]interface{}{
+						"host": "a.example.com",
+						"http": map[string]interface{}{
+							"paths": []interface{}{
+								map[string]interface{}{
+									"path":     "/",
+									"pathType": "Prefix",
+									"backend": map[string]interface{}{
+										"service": map[string]interface{}{"name": "web", "port": map[string]interface{}{"number": int64(8080)}},
+									},
+								},
+								map[string]interface{}{
+									"path":     "/api",
+									"pathType": "Prefix",
+									"backend": map[string]interface{}{
+										"resource": map[string]interface{}{"kind": "StorageBucket", "name": "static"},
+									},
+								},
+							},
+						},
+					},
+					// a rule without http paths is kept as it is
+					map[string]interface{}{"host": "b.example.com"},
+				},
+			}
+			Expect(ingressUsesWakeProxy(spec)).To(BeFalse())
+
+			setIngressBackends(spec, false)
+			Expect(spec["defaultBackend"]).To(Equal(wakeProxyBackend))
+			Expect(spec).NotTo(HaveKey("backend"))
+			for _, path := range paths(spec, 0) {
+				Expect(path.(map[string]interface{})["backend"]).To(Equal(wakeProxyBackend))
+			}
+			Expect(paths(spec, 0)[1].(map[string]interface{})["path"]).To(Equal("/api"))
+			Expect(spec["rules"].([]interface{})[1]).To(Equal(map[string]interface{}{"host": "b.example.com"}))
+			Expect(ingressUsesWakeProxy(spec)).To(BeTrue())
+		})
+
+		It("should use the legacy backends before kubernetes 1.22", func() {
+			spec := map[string]interface{}{
+				"backend": map[string]interface{}{"serviceName": "web", "servicePort": int64(80)},
+				"rules": []interface{}{
+					map[string]interface{}{
+						"http": map[string]interface{}{
+							"paths": []interface{}{
+								map[string]interface{}{
+									"path":    "/",
+									"backend": map[string]interface{}{"serviceName": "web", "servicePort": "http"},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			setIngressBackends(spec, true)
+			Expect(spec["backend"]).To(Equal(legacyWakeProxyBackend))
+			Expect(spec).NotTo(HaveKey("defaultBackend"))
+			Expect(paths(spec, 0)[0].(map[string]interface{})["backend"]).To(Equal(legacyWakeProxyBackend))
+			Expect(ingressUsesWakeProxy(spec)).To(BeTrue())
+		})
+	})
+})
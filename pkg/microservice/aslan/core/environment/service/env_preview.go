@@ -0,0 +1,294 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	templaterepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb/template"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/setting"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+const (
+	defaultMaxEnvPreviews = 5
+	envPreviewRepoMaxLen  = 20
+)
+
+var envPreviewNameRegExp = regexp.MustCompile("[^a-z0-9-]+")
+
+func GetEnvPreviewPolicy(projectName string) (*commonmodels.EnvPreviewPolicy, error) {
+	policy, err := commonrepo.NewEnvPreviewPolicyColl().Find(projectName)
+	if err == mongo.ErrNoDocuments {
+		return &commonmodels.EnvPreviewPolicy{ProductName: projectName, MaxEnvs: defaultMaxEnvPreviews}, nil
+	}
+	if err != nil {
+		return nil, e.ErrGetEnvPreviewPolicy.AddErr(err)
+	}
+	return policy, nil
+}
+
+func UpdateEnvPreviewPolicy(projectName string, policy *commonmodels.EnvPreviewPolicy, userName string) error {
+	if policy.Enabled {
+		if policy.MaxEnvs <= 0 {
+			return e.ErrUpdateEnvPreviewPolicy.AddDesc("max_envs must be greater than 0")
+		}
+		baseEnv, err := findTestEnv(projectName, policy.BaseEnv)
+		if err != nil {
+			return e.ErrUpdateEnvPreviewPolicy.AddErr(fmt.Errorf("failed to get base environment %s, err: %w", policy.BaseEnv, err))
+		}
+		if !baseEnv.ShareEnv.Enable || !baseEnv.ShareEnv.IsBase {
+			return e.ErrUpdateEnvPreviewPolicy.AddDesc(fmt.Sprintf("environment sharing is not enabled in the base environment %s", policy.BaseEnv))
+		}
+		workflow, err := commonrepo.NewWorkflowV4Coll().Find(policy.WorkflowName)
+		if err != nil || workflow.Project != projectName {
+			return e.ErrUpdateEnvPreviewPolicy.AddDesc(fmt.Sprintf("workflow %s not found in project %s", policy.WorkflowName, projectName))
+		}
+		serviceNames := sets.NewString()
+		for _, serviceFolders := range policy.ServiceFolders {
+			if serviceFolders.ServiceName == "" || len(serviceFolders.Folders) == 0 {
+				return e.ErrUpdateEnvPreviewPolicy.AddDesc("service name and folders can not be empty")
+			}
+			if serviceNames.Has(serviceFolders.ServiceName) {
+				return e.ErrUpdateEnvPreviewPolicy.AddDesc(fmt.Sprintf("folders of service %s are duplicated", serviceFolders.ServiceName))
+			}
+			serviceNames.Insert(serviceFolders.ServiceName)
+		}
+	}
+
+	policy.ProductName = projectName
+	policy.UpdateBy = userName
+	policy.UpdateTime = time.Now().Unix()
+	if err := commonrepo.NewEnvPreviewPolicyColl().Upsert(policy); err != nil {
+		return e.ErrUpdateEnvPreviewPolicy.AddErr(err)
+	}
+	return nil
+}
+
+func ListEnvPreviews(projectName string) ([]*commonmodels.EnvPreview, error) {
+	previews, err := commonrepo.NewEnvPreviewColl().List(&commonrepo.EnvPreviewFindOption{ProductName: projectName})
+	if err != nil {
+		return nil, e.ErrListEnvPreviews.AddErr(err)
+	}
+	return previews, nil
+}
+
+// BuildEnvPreviewInfo returns the preview environment posted to the pull request, the requests with the routing header
+// are routed to the services in the preview environment, the others fall back to the base environment
+func BuildEnvPreviewInfo(policy *commonmodels.EnvPreviewPolicy, preview *commonmodels.EnvPreview, deleted bool) *commonmodels.EnvPreviewInfo {
+	return &commonmodels.EnvPreviewInfo{
+		ProductName: preview.ProductName,
+		EnvName:     preview.EnvName,
+		Services:    preview.Services,
+		AccessURL:   policy.AccessURL,
+		Header:      zadigMatchXEnv,
+		Deleted:     deleted,
+	}
+}
+
+// EnsureEnvPreview creates the preview environment of the pull request off the base environment with the given services
+// if it does not exist yet, otherwise only the latest commit is recorded
+func EnsureEnvPreview(policy *commonmodels.EnvPreviewPolicy, arg *commonmodels.EnvPreview, requestID string, log *zap.SugaredLogger) (*commonmodels.EnvPreview, error) {
+	coll := commonrepo.NewEnvPreviewColl()
+	preview, err := coll.Find(&commonrepo.EnvPreviewFindOption{
+		ProductName:   policy.ProductName,
+		Source:        arg.Source,
+		RepoNamespace: arg.RepoNamespace,
+		RepoName:      arg.RepoName,
+		PrID:          arg.PrID,
+	})
+	if err == nil {
+		preview.CommitID = arg.CommitID
+		preview.UpdateTime = time.Now().Unix()
+		if err := coll.Update(preview); err != nil {
+			return nil, e.ErrCreateEnvPreview.AddErr(err)
+		}
+		return preview, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, e.ErrCreateEnvPreview.AddErr(err)
+	}
+
+	count, err := coll.Count(policy.ProductName)
+	if err != nil {
+		return nil, e.ErrCreateEnvPreview.AddErr(err)
+	}
+	if count >= int64(policy.MaxEnvs) {
+		return nil, e.ErrCreateEnvPreview.AddDesc(fmt.Sprintf("the number of preview environments in project %s reaches the limit %d", policy.ProductName, policy.MaxEnvs))
+	}
+
+	baseEnv, err := findTestEnv(policy.ProductName, policy.BaseEnv)
+	if err != nil {
+		return nil, e.ErrCreateEnvPreview.AddErr(fmt.Errorf("failed to get base environment %s, err: %w", policy.BaseEnv, err))
+	}
+	templateProduct, err := templaterepo.NewProductColl().Find(policy.ProductName)
+	if err != nil {
+		return nil, e.ErrCreateEnvPreview.AddErr(fmt.Errorf("failed to find project %s, err: %w", policy.ProductName, err))
+	}
+
+	preview = arg
+	preview.ProductName = policy.ProductName
+	preview.EnvName = genEnvPreviewName(arg.RepoName, arg.PrID)
+	preview.BaseEnv = policy.BaseEnv
+	preview.CreateTime = time.Now().Unix()
+	preview.UpdateTime = preview.CreateTime
+	product := buildEnvPreviewProduct(baseEnv, templateProduct.Revision, templateProduct.IsOpensource, preview)
+	if len(product.Services) == 0 {
+		return nil, e.ErrCreateEnvPreview.AddDesc(fmt.Sprintf("none of the services %v is deployed in the base environment %s", arg.Services, policy.BaseEnv))
+	}
+
+	// the record is created first, so the duplicated deliveries of the same event can not create the environment twice
+	created, err := createEnvPreviewInSlot(policy, preview)
+	if err != nil {
+		return nil, e.ErrCreateEnvPreview.AddErr(err)
+	}
+	if !created {
+		return nil, e.ErrCreateEnvPreview.AddDesc(fmt.Sprintf("the number of preview environments in project %s reaches the limit %d", policy.ProductName, policy.MaxEnvs))
+	}
+	if err := CreateProduct(setting.WebhookTaskCreator, requestID, &ProductCreateArg{product, nil}, log); err != nil {
+		if err := coll.Delete(preview.ID); err != nil {
+			log.Errorf("failed to delete preview environment record %s/%s, err: %s", preview.ProductName, preview.EnvName, err)
+		}
+		return nil, err
+	}
+	return preview, nil
+}
+
+// createEnvPreviewInSlot creates the record of the preview environment in a free slot of the project, the unique index of
+// the slots keeps the number of the preview environments within the limit, false is returned if all the slots are taken
+func createEnvPreviewInSlot(policy *commonmodels.EnvPreviewPolicy, preview *commonmodels.EnvPreview) (bool, error) {
+	coll := commonrepo.NewEnvPreviewColl()
+	for slot := 0; slot < policy.MaxEnvs; slot++ {
+		preview.Slot = slot
+		err := coll.Create(preview)
+		if err == nil {
+			return true, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return false, err
+		}
+		// the environment of the pull request is created by another delivery of the same event
+		if _, err := coll.Find(&commonrepo.EnvPreviewFindOption{
+			ProductName:   preview.ProductName,
+			Source:        preview.Source,
+			RepoNamespace: preview.RepoNamespace,
+			RepoName:      preview.RepoName,
+			PrID:          preview.PrID,
+		}); err == nil {
+			return false, fmt.Errorf("preview environment %s is being created", preview.EnvName)
+		}
+	}
+	return false, nil
+}
+
+// DeleteEnvPreviews deletes the preview environments of the pull request, the deleted ones are returned
+func DeleteEnvPreviews(opt *commonrepo.EnvPreviewFindOption, requestID string, log *zap.SugaredLogger) ([]*commonmodels.EnvPreview, error) {
+	coll := commonrepo.NewEnvPreviewColl()
+	previews, err := coll.List(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]*commonmodels.EnvPreview, 0, len(previews))
+	for _, preview := range previews {
+		if _, err := findTestEnv(preview.ProductName, preview.EnvName); err == nil {
+			if err := DeleteProduct(setting.WebhookTaskCreator, preview.EnvName, preview.ProductName, requestID, true, log); err != nil {
+				log.Errorf("failed to delete preview environment %s/%s, err: %s", preview.ProductName, preview.EnvName, err)
+				continue
+			}
+		}
+		if err := coll.Delete(preview.ID); err != nil {
+			log.Errorf("failed to delete preview environment record %s/%s, err: %s", preview.ProductName, preview.EnvName, err)
+			continue
+		}
+		deleted = append(deleted, preview)
+	}
+	return deleted, nil
+}
+
+func genEnvPreviewName(repoName string, prID int) string {
+	repo := strings.Trim(envPreviewNameRegExp.ReplaceAllString(strings.ToLower(repoName), "-"), "-")
+	if len(repo) > envPreviewRepoMaxLen {
+		repo = strings.Trim(repo[:envPreviewRepoMaxLen], "-")
+	}
+	return fmt.Sprintf("pr-%s-%d", repo, prID)
+}
+
+// buildEnvPreviewProduct builds the sub environment of the base environment with the services of the preview only,
+// the requests to the other services are routed to the base environment
+func buildEnvPreviewProduct(baseEnv *commonmodels.Product, revision int64, isOpenSource bool, preview *commonmodels.EnvPreview) *commonmodels.Product {
+	services := sets.NewString(preview.Services...)
+	product := &commonmodels.Product{
+		ProductName:           baseEnv.ProductName,
+		Revision:              revision,
+		Enabled:               false,
+		EnvName:               preview.EnvName,
+		UpdateBy:              setting.WebhookTaskCreator,
+		IsPublic:              true,
+		ClusterID:             baseEnv.ClusterID,
+		Namespace:             commonservice.GetProductEnvNamespace(preview.EnvName, baseEnv.ProductName, ""),
+		Source:                baseEnv.Source,
+		IsOpenSource:          isOpenSource,
+		RegistryID:            baseEnv.RegistryID,
+		DefaultValues:         baseEnv.DefaultValues,
+		YamlData:              baseEnv.YamlData,
+		GlobalVariables:       baseEnv.GlobalVariables,
+		ServiceDeployStrategy: baseEnv.ServiceDeployStrategy,
+		Services:              make([][]*commonmodels.ProductService, 0),
+		ShareEnv: commonmodels.ProductShareEnv{
+			Enable:  true,
+			IsBase:  false,
+			BaseEnv: baseEnv.EnvName,
+		},
+	}
+
+	deployed := make([]string, 0)
+	for _, svcGroup := range baseEnv.Services {
+		group := make([]*commonmodels.ProductService, 0)
+		for _, svc := range svcGroup {
+			if !services.Has(svc.ServiceName) {
+				continue
+			}
+			svc.Error = ""
+			svc.UpdateTime = 0
+			// the release names of the helm services are generated again for the new environment
+			if product.Source == setting.SourceFromHelm && svc.FromZadig() {
+				svc.ReleaseName = ""
+			}
+			group = append(group, svc)
+			deployed = append(deployed, svc.ServiceName)
+		}
+		if len(group) > 0 {
+			product.Services = append(product.Services, group)
+		}
+	}
+	if product.Source == setting.SourceFromHelm {
+		product.ServiceRenders = product.GetAllSvcRenders()
+	}
+	preview.Services = deployed
+	return product
+}
@@ -0,0 +1,37 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Testing env preview", func() {
+
+	Describe("test genEnvPreviewName", func() {
+		It("should generate the name from the repository and the pull request", func() {
+			Expect(genEnvPreviewName("app", 12)).To(Equal("pr-app-12"))
+			Expect(genEnvPreviewName("My_App.Web", 3)).To(Equal("pr-my-app-web-3"))
+		})
+
+		It("should truncate the long repository name", func() {
+			Expect(genEnvPreviewName("a-very-long-repository-name", 1)).To(Equal("pr-a-very-long-reposito-1"))
+			Expect(genEnvPreviewName("abcdefghijklmnopqrs-t", 1)).To(Equal("pr-abcdefghijklmnopqrs-1"))
+		})
+	})
+})
@@ -0,0 +1,511 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	templatemodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/template"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	templaterepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb/template"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	s3service "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/s3"
+	commontypes "github.com/koderover/zadig/pkg/microservice/aslan/core/common/types"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/crypto"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/util"
+	fsutil "github.com/koderover/zadig/pkg/util/fs"
+)
+
+const (
+	// envSnapshotFormatVersion is increased when the bundle is changed incompatibly
+	envSnapshotFormatVersion = 1
+	envSnapshotFile          = "snapshot.json"
+	envSnapshotS3Base        = "env-snapshots"
+)
+
+// EnvSnapshotBundle is the content of the snapshot archive, it holds everything needed to create the environment
+// again: the service revisions, the variables and helm values, the images and the common env configs
+type EnvSnapshotBundle struct {
+	FormatVersion         int                              `json:"format_version"`
+	ProductName           string                           `json:"product_name"`
+	EnvName               string                           `json:"env_name"`
+	Production            bool                             `json:"production"`
+	Source                string                           `json:"source"`
+	ClusterID             string                           `json:"cluster_id"`
+	Namespace             string                           `json:"namespace"`
+	RegistryID            string                           `json:"registry_id"`
+	DefaultValues         string                           `json:"default_values"`
+	YamlData              *templatemodels.CustomYaml       `json:"yaml_data"`
+	GlobalVariables       []*commontypes.GlobalVariableKV  `json:"global_variables"`
+	ServiceDeployStrategy map[string]string                `json:"service_deploy_strategy"`
+	Services              [][]*commonmodels.ProductService `json:"services"`
+	EnvConfigs            []*EnvSnapshotConfig             `json:"env_configs"`
+	CreateTime            int64                            `json:"create_time"`
+}
+
+type EnvSnapshotConfig struct {
+	Type     config.CommonEnvCfgType `json:"type"`
+	Name     string                  `json:"name"`
+	YamlData string                  `json:"yaml_data"`
+	// Encrypted is true for the secrets, they are encrypted with the aes key of the system
+	Encrypted bool `json:"encrypted"`
+}
+
+type EnvSnapshotArg struct {
+	Description string `json:"description"`
+}
+
+type EnvSnapshotRestoreArg struct {
+	EnvName    string `json:"env_name"`
+	Namespace  string `json:"namespace"`
+	ClusterID  string `json:"cluster_id"`
+	RegistryID string `json:"registry_id"`
+	// RegistryMapping replaces the registry prefixes in the images and the values, e.g. old.registry.com/team -> new.registry.com/team
+	RegistryMapping map[string]string `json:"registry_mapping"`
+	// DomainMapping replaces the ingress domains in the common env configs and the values
+	DomainMapping map[string]string `json:"domain_mapping"`
+}
+
+// CreateEnvSnapshot archives the environment into a new version of its snapshots in the object storage
+func CreateEnvSnapshot(projectName, envName string, production bool, arg *EnvSnapshotArg, userName string, log *zap.SugaredLogger) (*commonmodels.EnvSnapshot, error) {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{
+		Name:       projectName,
+		EnvName:    envName,
+		Production: util.GetBoolPointer(production),
+	})
+	if err != nil {
+		return nil, e.ErrCreateEnvSnapshot.AddErr(fmt.Errorf("failed to find environment %s/%s, err: %w", projectName, envName, err))
+	}
+	if env.Source == setting.SourceFromExternal || env.Source == setting.SourceFromPM {
+		return nil, e.ErrCreateEnvSnapshot.AddDesc("snapshots are only supported for k8s yaml and helm environments")
+	}
+
+	bundle := &EnvSnapshotBundle{
+		FormatVersion:         envSnapshotFormatVersion,
+		ProductName:           env.ProductName,
+		EnvName:               env.EnvName,
+		Production:            env.Production,
+		Source:                env.Source,
+		ClusterID:             env.ClusterID,
+		Namespace:             env.Namespace,
+		RegistryID:            env.RegistryID,
+		DefaultValues:         env.DefaultValues,
+		YamlData:              env.YamlData,
+		GlobalVariables:       env.GlobalVariables,
+		ServiceDeployStrategy: env.ServiceDeployStrategy,
+		Services:              env.Services,
+		CreateTime:            time.Now().Unix(),
+	}
+	bundle.EnvConfigs, err = getEnvSnapshotConfigs(env)
+	if err != nil {
+		return nil, e.ErrCreateEnvSnapshot.AddErr(err)
+	}
+
+	storage, err := s3service.FindDefaultS3()
+	if err != nil {
+		return nil, e.ErrCreateEnvSnapshot.AddErr(fmt.Errorf("failed to find default object storage, err: %w", err))
+	}
+
+	version, err := commonrepo.NewEnvSnapshotColl().GetLatestVersion(projectName, envName, production)
+	if err != nil {
+		return nil, e.ErrCreateEnvSnapshot.AddErr(err)
+	}
+	version++
+
+	objectKey := storage.GetObjectPath(fmt.Sprintf("%s/%s/%s/%d.tar.gz", envSnapshotS3Base, projectName, envName, version))
+	if production {
+		objectKey = storage.GetObjectPath(fmt.Sprintf("%s/%s/production/%s/%d.tar.gz", envSnapshotS3Base, projectName, envName, version))
+	}
+	if err := uploadEnvSnapshotBundle(bundle, storage, objectKey); err != nil {
+		log.Errorf("failed to upload snapshot of %s/%s, err: %s", projectName, envName, err)
+		return nil, e.ErrCreateEnvSnapshot.AddErr(err)
+	}
+
+	snapshot := &commonmodels.EnvSnapshot{
+		ProductName: projectName,
+		EnvName:     envName,
+		Production:  production,
+		Version:     version,
+		Source:      env.Source,
+		ClusterID:   env.ClusterID,
+		Namespace:   env.Namespace,
+		ObjectKey:   objectKey,
+		Description: arg.Description,
+		CreatedBy:   userName,
+		CreateTime:  bundle.CreateTime,
+	}
+	if !storage.ID.IsZero() {
+		snapshot.StorageID = storage.ID.Hex()
+	}
+	if err := commonrepo.NewEnvSnapshotColl().Create(snapshot); err != nil {
+		return nil, e.ErrCreateEnvSnapshot.AddErr(err)
+	}
+	return snapshot, nil
+}
+
+// getEnvSnapshotConfigs collects the latest version of the common env configs, the secrets are encrypted
+func getEnvSnapshotConfigs(env *commonmodels.Product) ([]*EnvSnapshotConfig, error) {
+	resources, err := commonrepo.NewEnvResourceColl().ListLatestResource(&commonrepo.QueryEnvResourceOption{
+		ProductName: env.ProductName,
+		EnvName:     env.EnvName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list env configs, err: %w", err)
+	}
+
+	configs := make([]*EnvSnapshotConfig, 0, len(resources))
+	for _, resource := range resources {
+		latest, err := commonrepo.NewEnvResourceColl().Find(&commonrepo.QueryEnvResourceOption{
+			ProductName: env.ProductName,
+			EnvName:     env.EnvName,
+			Name:        resource.ID.Name,
+			Type:        resource.ID.Type,
+			Active:      true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find %s %s, err: %w", resource.ID.Type, resource.ID.Name, err)
+		}
+
+		cfg := &EnvSnapshotConfig{
+			Type:     config.CommonEnvCfgType(latest.Type),
+			Name:     latest.Name,
+			YamlData: latest.YamlData,
+		}
+		if cfg.Type == config.CommonEnvCfgTypeSecret {
+			cfg.YamlData, err = crypto.AesEncrypt(latest.YamlData)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encrypt secret %s, err: %w", latest.Name, err)
+			}
+			cfg.Encrypted = true
+		}
+		configs = append(configs, cfg)
+	}
+	return configs, nil
+}
+
+func newEnvSnapshotS3Client(storage *s3service.S3) (*s3tool.Client, error) {
+	forcedPathStyle := true
+	if storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	return s3tool.NewClient(storage.Endpoint, storage.Ak, storage.Sk, storage.Region, storage.Insecure, forcedPathStyle)
+}
+
+func uploadEnvSnapshotBundle(bundle *EnvSnapshotBundle, storage *s3service.S3, objectKey string) error {
+	tmpDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	content, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	bundleDir := filepath.Join(tmpDir, "bundle")
+	if err := os.MkdirAll(bundleDir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(bundleDir, envSnapshotFile), content, 0644); err != nil {
+		return err
+	}
+
+	tarball := filepath.Join(tmpDir, "snapshot.tar.gz")
+	if err := fsutil.Tar(os.DirFS(bundleDir), tarball); err != nil {
+		return fmt.Errorf("failed to archive snapshot, err: %w", err)
+	}
+
+	client, err := newEnvSnapshotS3Client(storage)
+	if err != nil {
+		return fmt.Errorf("failed to create s3 client, err: %w", err)
+	}
+	return client.Upload(storage.Bucket, tarball, objectKey)
+}
+
+func downloadEnvSnapshotBundle(snapshot *commonmodels.EnvSnapshot) (*EnvSnapshotBundle, error) {
+	var (
+		storage *s3service.S3
+		err     error
+	)
+	if snapshot.StorageID != "" {
+		storage, err = s3service.FindS3ById(snapshot.StorageID)
+	} else {
+		storage, err = s3service.FindDefaultS3()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find object storage, err: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	client, err := newEnvSnapshotS3Client(storage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client, err: %w", err)
+	}
+	tarball := filepath.Join(tmpDir, "snapshot.tar.gz")
+	if err := client.Download(storage.Bucket, snapshot.ObjectKey, tarball); err != nil {
+		return nil, fmt.Errorf("failed to download snapshot, err: %w", err)
+	}
+	return readEnvSnapshotBundle(tarball, filepath.Join(tmpDir, "bundle"))
+}
+
+// readEnvSnapshotBundle extracts the snapshot archive into the bundle dir and reads the bundle in it
+func readEnvSnapshotBundle(tarball, bundleDir string) (*EnvSnapshotBundle, error) {
+	// the files in the archive are extracted into the dir without creating it
+	if err := os.MkdirAll(bundleDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := fsutil.Untar(tarball, bundleDir); err != nil {
+		return nil, fmt.Errorf("failed to extract snapshot, err: %w", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(bundleDir, envSnapshotFile))
+	if err != nil {
+		return nil, err
+	}
+	bundle := new(EnvSnapshotBundle)
+	if err := json.Unmarshal(content, bundle); err != nil {
+		return nil, fmt.Errorf("invalid snapshot, err: %w", err)
+	}
+	if bundle.FormatVersion > envSnapshotFormatVersion {
+		return nil, fmt.Errorf("snapshot format version %d is not supported", bundle.FormatVersion)
+	}
+	return bundle, nil
+}
+
+func ListEnvSnapshots(projectName, envName string, production bool) ([]*commonmodels.EnvSnapshot, error) {
+	snapshots, err := commonrepo.NewEnvSnapshotColl().List(projectName, envName, production)
+	if err != nil {
+		return nil, e.ErrListEnvSnapshots.AddErr(err)
+	}
+	return snapshots, nil
+}
+
+// GetEnvSnapshot finds the snapshot in the project, it is used to check the permission of the environment it is taken of
+func GetEnvSnapshot(projectName, snapshotID string) (*commonmodels.EnvSnapshot, error) {
+	snapshot, err := commonrepo.NewEnvSnapshotColl().GetByID(snapshotID)
+	if err != nil || snapshot.ProductName != projectName {
+		return nil, e.ErrRestoreEnvSnapshot.AddDesc("snapshot not found")
+	}
+	return snapshot, nil
+}
+
+// RestoreEnvSnapshot creates a new environment from the snapshot in the same project, the new environment is a
+// production one if production is true whatever the environment of the snapshot is, the cluster, the namespace, the
+// registry and the ingress domains can be remapped
+func RestoreEnvSnapshot(projectName, snapshotID string, production bool, userName, requestID string, arg *EnvSnapshotRestoreArg, log *zap.SugaredLogger) error {
+	if arg.EnvName == "" {
+		return e.ErrRestoreEnvSnapshot.AddDesc("env name can not be empty")
+	}
+
+	snapshot, err := GetEnvSnapshot(projectName, snapshotID)
+	if err != nil {
+		return err
+	}
+
+	bundle, err := downloadEnvSnapshotBundle(snapshot)
+	if err != nil {
+		log.Errorf("failed to download snapshot %s, err: %s", snapshotID, err)
+		return e.ErrRestoreEnvSnapshot.AddErr(err)
+	}
+	return restoreEnvSnapshotBundle(projectName, bundle, production, userName, requestID, arg, log)
+}
+
+// ImportEnvSnapshot creates a new environment from a snapshot archive uploaded instead of the one in the object
+// storage, the archive must be taken of an environment in the same project, and the secrets in it can only be
+// decrypted when the aes key of the system is the same as the one it is taken with
+func ImportEnvSnapshot(projectName string, archive io.Reader, production bool, userName, requestID string, arg *EnvSnapshotRestoreArg, log *zap.SugaredLogger) error {
+	if arg.EnvName == "" {
+		return e.ErrRestoreEnvSnapshot.AddDesc("env name can not be empty")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return e.ErrRestoreEnvSnapshot.AddErr(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tarball := filepath.Join(tmpDir, "snapshot.tar.gz")
+	file, err := os.Create(tarball)
+	if err != nil {
+		return e.ErrRestoreEnvSnapshot.AddErr(err)
+	}
+	_, err = io.Copy(file, archive)
+	file.Close()
+	if err != nil {
+		return e.ErrRestoreEnvSnapshot.AddErr(fmt.Errorf("failed to save snapshot archive, err: %w", err))
+	}
+
+	bundle, err := readEnvSnapshotBundle(tarball, filepath.Join(tmpDir, "bundle"))
+	if err != nil {
+		return e.ErrRestoreEnvSnapshot.AddErr(err)
+	}
+	if bundle.ProductName != projectName {
+		return e.ErrRestoreEnvSnapshot.AddDesc(fmt.Sprintf("snapshot is taken of project %s", bundle.ProductName))
+	}
+	return restoreEnvSnapshotBundle(projectName, bundle, production, userName, requestID, arg, log)
+}
+
+func restoreEnvSnapshotBundle(projectName string, bundle *EnvSnapshotBundle, production bool, userName, requestID string, arg *EnvSnapshotRestoreArg, log *zap.SugaredLogger) error {
+	templateProduct, err := templaterepo.NewProductColl().Find(projectName)
+	if err != nil {
+		return e.ErrRestoreEnvSnapshot.AddErr(fmt.Errorf("failed to find project %s, err: %w", projectName, err))
+	}
+
+	envConfigs := make([]*commonmodels.CreateUpdateCommonEnvCfgArgs, 0, len(bundle.EnvConfigs))
+	for _, cfg := range bundle.EnvConfigs {
+		yamlData := cfg.YamlData
+		if cfg.Encrypted {
+			yamlData, err = crypto.AesDecrypt(cfg.YamlData)
+			if err != nil {
+				return e.ErrRestoreEnvSnapshot.AddErr(fmt.Errorf("failed to decrypt %s %s, err: %w", cfg.Type, cfg.Name, err))
+			}
+		}
+		envConfigs = append(envConfigs, &commonmodels.CreateUpdateCommonEnvCfgArgs{
+			EnvName:          arg.EnvName,
+			ProductName:      projectName,
+			Name:             cfg.Name,
+			YamlData:         yamlData,
+			CommonEnvCfgType: cfg.Type,
+		})
+	}
+
+	product := &commonmodels.Product{
+		ProductName:           projectName,
+		Revision:              templateProduct.Revision,
+		Enabled:               false,
+		EnvName:               arg.EnvName,
+		UpdateBy:              userName,
+		IsPublic:              true,
+		ClusterID:             bundle.ClusterID,
+		Namespace:             commonservice.GetProductEnvNamespace(arg.EnvName, projectName, arg.Namespace),
+		Source:                bundle.Source,
+		IsOpenSource:          templateProduct.IsOpensource,
+		RegistryID:            bundle.RegistryID,
+		Production:            production,
+		DefaultValues:         bundle.DefaultValues,
+		YamlData:              bundle.YamlData,
+		GlobalVariables:       bundle.GlobalVariables,
+		ServiceDeployStrategy: bundle.ServiceDeployStrategy,
+		Services:              bundle.Services,
+		EnvConfigs:            envConfigs,
+	}
+	if arg.ClusterID != "" {
+		product.ClusterID = arg.ClusterID
+	}
+	if arg.RegistryID != "" {
+		product.RegistryID = arg.RegistryID
+	}
+	for _, svcGroup := range product.Services {
+		for _, svc := range svcGroup {
+			svc.Error = ""
+			svc.UpdateTime = 0
+			// the release names of the helm services are generated again for the new environment
+			if product.Source == setting.SourceFromHelm && svc.FromZadig() {
+				svc.ReleaseName = ""
+			}
+		}
+	}
+	remapEnvSnapshot(product, arg)
+	if product.Source == setting.SourceFromHelm {
+		product.ServiceRenders = product.GetAllSvcRenders()
+	}
+
+	return CreateProduct(userName, requestID, &ProductCreateArg{product, nil}, log)
+}
+
+// remapEnvSnapshot replaces the registries and the ingress domains in the images, the values, the variables and the
+// common env configs of the environment restored from a snapshot
+func remapEnvSnapshot(product *commonmodels.Product, arg *EnvSnapshotRestoreArg) {
+	mapping := make(map[string]string)
+	for from, to := range arg.DomainMapping {
+		mapping[from] = to
+	}
+	for from, to := range arg.RegistryMapping {
+		mapping[from] = to
+	}
+	if len(mapping) == 0 {
+		return
+	}
+
+	// the longer prefixes are replaced first, so a registry and one of its namespaces can be mapped separately
+	froms := make([]string, 0, len(mapping))
+	for from := range mapping {
+		if from != "" {
+			froms = append(froms, from)
+		}
+	}
+	sort.Slice(froms, func(i, j int) bool { return len(froms[i]) > len(froms[j]) })
+	pairs := make([]string, 0, len(froms)*2)
+	for _, from := range froms {
+		pairs = append(pairs, from, mapping[from])
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	remapVariable := func(kv *commontypes.ServiceVariableKV) {
+		if value, ok := kv.Value.(string); ok {
+			kv.Value = replacer.Replace(value)
+		}
+	}
+	remapCustomYaml := func(customYaml *templatemodels.CustomYaml) {
+		if customYaml == nil {
+			return
+		}
+		customYaml.YamlContent = replacer.Replace(customYaml.YamlContent)
+		for _, kv := range customYaml.RenderVariableKVs {
+			remapVariable(&kv.ServiceVariableKV)
+		}
+	}
+
+	product.DefaultValues = replacer.Replace(product.DefaultValues)
+	remapCustomYaml(product.YamlData)
+	for _, kv := range product.GlobalVariables {
+		remapVariable(&kv.ServiceVariableKV)
+	}
+	for _, svcGroup := range product.Services {
+		for _, svc := range svcGroup {
+			for _, container := range svc.Containers {
+				container.Image = replacer.Replace(container.Image)
+			}
+			if svc.Render != nil {
+				svc.Render.OverrideValues = replacer.Replace(svc.Render.OverrideValues)
+				remapCustomYaml(svc.Render.OverrideYaml)
+			}
+		}
+	}
+	for _, cfg := range product.EnvConfigs {
+		cfg.YamlData = replacer.Replace(cfg.YamlData)
+	}
+}
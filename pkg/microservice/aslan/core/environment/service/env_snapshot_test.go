@@ -0,0 +1,166 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	templatemodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/template"
+	commontypes "github.com/koderover/zadig/pkg/microservice/aslan/core/common/types"
+	fsutil "github.com/koderover/zadig/pkg/util/fs"
+)
+
+func newTestSnapshotProduct() *commonmodels.Product {
+	return &commonmodels.Product{
+		DefaultValues: "image: old.io/team/web:v1\nhost: web.dev.example.com",
+		YamlData: &templatemodels.CustomYaml{
+			YamlContent: "registry: old.io",
+		},
+		GlobalVariables: []*commontypes.GlobalVariableKV{
+			{ServiceVariableKV: commontypes.ServiceVariableKV{Key: "host", Value: "api.dev.example.com"}},
+			{ServiceVariableKV: commontypes.ServiceVariableKV{Key: "replicas", Value: 2}},
+		},
+		Services: [][]*commonmodels.ProductService{
+			{
+				{
+					ServiceName: "web",
+					Containers: []*commonmodels.Container{
+						{Name: "web", Image: "old.io/team/web:v1"},
+						{Name: "sidecar", Image: "old.io/base/proxy:v2"},
+					},
+					Render: &templatemodels.ServiceRender{
+						OverrideValues: `[{"Key":"image","Value":"old.io/team/web:v1"}]`,
+						OverrideYaml: &templatemodels.CustomYaml{
+							YamlContent: "host: web.dev.example.com",
+							RenderVariableKVs: []*commontypes.RenderVariableKV{
+								{ServiceVariableKV: commontypes.ServiceVariableKV{Key: "image", Value: "old.io/team/web:v1"}},
+							},
+						},
+					},
+				},
+			},
+		},
+		EnvConfigs: []*commonmodels.CreateUpdateCommonEnvCfgArgs{
+			{Name: "web", YamlData: "host: web.dev.example.com"},
+		},
+	}
+}
+
+var _ = Describe("Testing env snapshot", func() {
+
+	Describe("test remapEnvSnapshot", func() {
+		It("should replace the registries and the domains everywhere", func() {
+			product := newTestSnapshotProduct()
+			remapEnvSnapshot(product, &EnvSnapshotRestoreArg{
+				RegistryMapping: map[string]string{"old.io": "new.io"},
+				DomainMapping:   map[string]string{"dev.example.com": "test.example.com"},
+			})
+
+			Expect(product.DefaultValues).To(Equal("image: new.io/team/web:v1\nhost: web.test.example.com"))
+			Expect(product.YamlData.YamlContent).To(Equal("registry: new.io"))
+			Expect(product.GlobalVariables[0].Value).To(Equal("api.test.example.com"))
+			Expect(product.GlobalVariables[1].Value).To(Equal(2))
+
+			svc := product.Services[0][0]
+			Expect(svc.Containers[0].Image).To(Equal("new.io/team/web:v1"))
+			Expect(svc.Containers[1].Image).To(Equal("new.io/base/proxy:v2"))
+			Expect(svc.Render.OverrideValues).To(Equal(`[{"Key":"image","Value":"new.io/team/web:v1"}]`))
+			Expect(svc.Render.OverrideYaml.YamlContent).To(Equal("host: web.test.example.com"))
+			Expect(svc.Render.OverrideYaml.RenderVariableKVs[0].Value).To(Equal("new.io/team/web:v1"))
+			Expect(product.EnvConfigs[0].YamlData).To(Equal("host: web.test.example.com"))
+		})
+
+		It("should replace the longer prefixes first", func() {
+			product := newTestSnapshotProduct()
+			remapEnvSnapshot(product, &EnvSnapshotRestoreArg{
+				RegistryMapping: map[string]string{
+					"old.io":      "new.io",
+					"old.io/team": "team.io/web",
+				},
+			})
+
+			Expect(product.Services[0][0].Containers[0].Image).To(Equal("team.io/web/web:v1"))
+			Expect(product.Services[0][0].Containers[1].Image).To(Equal("new.io/base/proxy:v2"))
+		})
+
+		It("should ignore the empty prefixes", func() {
+			product := newTestSnapshotProduct()
+			remapEnvSnapshot(product, &EnvSnapshotRestoreArg{
+				RegistryMapping: map[string]string{"": "new.io"},
+			})
+
+			Expect(product).To(Equal(newTestSnapshotProduct()))
+		})
+
+		It("should keep the environment without mappings", func() {
+			product := newTestSnapshotProduct()
+			product.YamlData = nil
+			product.Services[0][0].Render = nil
+			remapEnvSnapshot(product, &EnvSnapshotRestoreArg{})
+
+			Expect(product.DefaultValues).To(Equal("image: old.io/team/web:v1\nhost: web.dev.example.com"))
+			Expect(product.Services[0][0].Containers[0].Image).To(Equal("old.io/team/web:v1"))
+		})
+	})
+
+	Describe("test readEnvSnapshotBundle", func() {
+		var tmpDir string
+
+		BeforeEach(func() {
+			var err error
+			tmpDir, err = os.MkdirTemp("", "")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpDir)
+		})
+
+		writeArchive := func(bundle *EnvSnapshotBundle) string {
+			content, err := json.Marshal(bundle)
+			Expect(err).NotTo(HaveOccurred())
+			srcDir := filepath.Join(tmpDir, "src")
+			Expect(os.MkdirAll(srcDir, 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(srcDir, envSnapshotFile), content, 0644)).To(Succeed())
+			tarball := filepath.Join(tmpDir, "snapshot.tar.gz")
+			Expect(fsutil.Tar(os.DirFS(srcDir), tarball)).To(Succeed())
+			return tarball
+		}
+
+		It("should read the bundle in the archive", func() {
+			tarball := writeArchive(&EnvSnapshotBundle{FormatVersion: envSnapshotFormatVersion, ProductName: "demo", EnvName: "dev"})
+
+			bundle, err := readEnvSnapshotBundle(tarball, filepath.Join(tmpDir, "bundle"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bundle.ProductName).To(Equal("demo"))
+			Expect(bundle.EnvName).To(Equal("dev"))
+		})
+
+		It("should reject the bundle of a newer format", func() {
+			tarball := writeArchive(&EnvSnapshotBundle{FormatVersion: envSnapshotFormatVersion + 1})
+
+			_, err := readEnvSnapshotBundle(tarball, filepath.Join(tmpDir, "bundle"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,558 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+
+	configbase "github.com/koderover/zadig/pkg/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/imnotify"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/shared/client/user"
+	kubeclient "github.com/koderover/zadig/pkg/shared/kube/client"
+	"github.com/koderover/zadig/pkg/tool/dingtalk"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
+	"github.com/koderover/zadig/pkg/tool/lark"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+type EnvTTLPolicyArg struct {
+	Enable      bool   `json:"enable"`
+	TTLHours    int    `json:"ttl_hours"`
+	RemindHours int    `json:"remind_hours"`
+	GraceHours  int    `json:"grace_hours"`
+	Owner       string `json:"owner"`
+	// OwnerIMAppID is the lark or dingtalk app used to send the reminders to the owner directly
+	OwnerIMAppID string `json:"owner_im_app_id"`
+}
+
+type EnvTTLPolicyResp struct {
+	*EnvTTLPolicyArg
+	ExpireTime int64 `json:"expire_time"`
+	SleepTime  int64 `json:"sleep_time"`
+}
+
+type EnvReclaimReport struct {
+	Records       []*commonmodels.EnvReclaimRecord `json:"records"`
+	CPURequest    int64                            `json:"cpu_request"`
+	MemoryRequest int64                            `json:"memory_request"`
+}
+
+func findTestEnv(projectName, envName string) (*commonmodels.Product, error) {
+	return commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{
+		EnvName:    envName,
+		Name:       projectName,
+		Production: util.GetBoolPointer(false),
+	})
+}
+
+func GetEnvTTLPolicy(projectName, envName string) (*EnvTTLPolicyResp, error) {
+	env, err := findTestEnv(projectName, envName)
+	if err != nil {
+		return nil, e.ErrGetEnvConfigs.AddErr(fmt.Errorf("failed to get environment %s/%s, err: %w", projectName, envName, err))
+	}
+
+	resp := &EnvTTLPolicyResp{EnvTTLPolicyArg: &EnvTTLPolicyArg{}}
+	if env.TTLPolicy != nil {
+		resp.Enable = true
+		resp.TTLHours = env.TTLPolicy.TTLHours
+		resp.RemindHours = env.TTLPolicy.RemindHours
+		resp.GraceHours = env.TTLPolicy.GraceHours
+		resp.Owner = env.TTLPolicy.Owner
+		resp.OwnerIMAppID = env.TTLPolicy.OwnerIMAppID
+		resp.ExpireTime = env.TTLPolicy.ExpireTime
+		resp.SleepTime = env.TTLPolicy.SleepTime
+	}
+	return resp, nil
+}
+
+// UpdateEnvTTLPolicy sets the TTL of a test environment, the environment expires TTL hours from now on
+func UpdateEnvTTLPolicy(projectName, envName, userName string, arg *EnvTTLPolicyArg, logger *zap.SugaredLogger) error {
+	if arg.Enable && arg.TTLHours <= 0 {
+		return e.ErrUpdateEnvTTLPolicy.AddDesc("ttl hours must be greater than 0")
+	}
+	if arg.RemindHours < 0 || arg.GraceHours < 0 {
+		return e.ErrUpdateEnvTTLPolicy.AddDesc("remind hours and grace hours can not be negative")
+	}
+
+	if arg.Enable && arg.OwnerIMAppID != "" {
+		imApp, err := commonrepo.NewIMAppColl().GetByID(context.Background(), arg.OwnerIMAppID)
+		if err != nil {
+			return e.ErrUpdateEnvTTLPolicy.AddErr(fmt.Errorf("failed to get im app %s, err: %w", arg.OwnerIMAppID, err))
+		}
+		if imApp.Type != setting.IMLark && imApp.Type != setting.IMDingTalk {
+			return e.ErrUpdateEnvTTLPolicy.AddDesc(fmt.Sprintf("im app of type %s can not send messages to the owner", imApp.Type))
+		}
+	}
+
+	env, err := findTestEnv(projectName, envName)
+	if err != nil {
+		return e.ErrUpdateEnvTTLPolicy.AddErr(fmt.Errorf("failed to get environment %s/%s, err: %w", projectName, envName, err))
+	}
+
+	var policy *commonmodels.EnvTTLPolicy
+	if arg.Enable {
+		policy = &commonmodels.EnvTTLPolicy{
+			TTLHours:     arg.TTLHours,
+			RemindHours:  arg.RemindHours,
+			GraceHours:   arg.GraceHours,
+			Owner:        arg.Owner,
+			OwnerIMAppID: arg.OwnerIMAppID,
+		}
+		if policy.Owner == "" {
+			policy.Owner = userName
+		}
+		initEnvTTLPolicy(policy)
+		if env.TTLPolicy != nil && env.TTLPolicy.ExtendToken != "" {
+			policy.ExtendToken = env.TTLPolicy.ExtendToken
+		}
+	}
+
+	if err := commonrepo.NewProductColl().UpdateTTLPolicy(envName, projectName, policy); err != nil {
+		return e.ErrUpdateEnvTTLPolicy.AddErr(fmt.Errorf("failed to update ttl policy of %s/%s, err: %w", projectName, envName, err))
+	}
+
+	// the environment put to sleep by the expiry is not going to be reclaimed any more
+	if env.TTLPolicy != nil && env.TTLPolicy.SleepTime > 0 && env.IsSleeping() {
+		if err := EnvSleep(projectName, envName, false, false, logger); err != nil {
+			return e.ErrUpdateEnvTTLPolicy.AddErr(fmt.Errorf("failed to wake up environment %s/%s, err: %w", projectName, envName, err))
+		}
+	}
+	return nil
+}
+
+// initEnvTTLPolicy starts the TTL of a new policy or of a policy copied from another environment
+func initEnvTTLPolicy(policy *commonmodels.EnvTTLPolicy) {
+	policy.ExpireTime = time.Now().Unix() + int64(policy.TTLHours)*3600
+	policy.Reminded = false
+	policy.SleepTime = 0
+	policy.ExtendToken = newEnvExtendToken()
+}
+
+// newEnvExtendToken generates a new token for the extend link, the links sent before stop working once it is replaced
+func newEnvExtendToken() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// ExtendEnvTTL extends the expiry of a test environment by the given hours, or by the TTL hours of its policy when
+// the hours are not given, an environment asleep in the grace period is woken up
+func ExtendEnvTTL(projectName, envName string, hours int, logger *zap.SugaredLogger) (*EnvTTLPolicyResp, error) {
+	env, err := findTestEnv(projectName, envName)
+	if err != nil {
+		return nil, e.ErrExtendEnvTTL.AddErr(fmt.Errorf("failed to get environment %s/%s, err: %w", projectName, envName, err))
+	}
+	return extendEnvTTL(env, hours, logger)
+}
+
+// GetEnvTTLByToken returns the environment of the extend link in the reminders, it is shown on the confirm page
+func GetEnvTTLByToken(token string) (*commonmodels.Product, error) {
+	env, err := commonrepo.NewProductColl().FindByExtendToken(token)
+	if err != nil || env.TTLPolicy == nil {
+		return nil, e.ErrExtendEnvTTL.AddDesc("environment not found or the link has expired")
+	}
+	return env, nil
+}
+
+var envTTLExtendPage = template.Must(template.New("extend").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>延长环境有效期</title>
+</head>
+<body style="font-family: sans-serif; text-align: center; padding-top: 60px;">
+<h3>{{.ProductName}} / {{.EnvName}}</h3>
+<p>负责人：{{.Owner}}</p>
+<p>{{if .Expired}}环境已到期并进入睡眠，将于 {{.DeleteTime}} 被删除{{else}}到期时间：{{.ExpireTime}}{{end}}</p>
+<button id="extend" onclick="extend()">延长 {{.TTLHours}} 小时</button>
+<p id="result"></p>
+<script>
+function extend() {
+  document.getElementById("extend").disabled = true;
+  fetch(window.location.href, {method: "POST"}).then(function (resp) {
+    document.getElementById("result").innerText = resp.ok ? "有效期已延长" : "延长失败，链接可能已失效";
+  }).catch(function () {
+    document.getElementById("result").innerText = "延长失败，请稍后重试";
+    document.getElementById("extend").disabled = false;
+  });
+}
+</script>
+</body>
+</html>
+`))
+
+// RenderEnvTTLExtendPage renders the page opened by the extend link in the reminders, the environment is only extended
+// when it is confirmed on the page so that the link prefetched by the IM clients does not extend it
+func RenderEnvTTLExtendPage(env *commonmodels.Product) ([]byte, error) {
+	policy := env.TTLPolicy
+	data := map[string]interface{}{
+		"ProductName": env.ProductName,
+		"EnvName":     env.EnvName,
+		"Owner":       policy.Owner,
+		"TTLHours":    policy.TTLHours,
+		"Expired":     policy.SleepTime > 0,
+		"ExpireTime":  time.Unix(policy.ExpireTime, 0).Format("2006-01-02 15:04:05"),
+		"DeleteTime":  time.Unix(policy.SleepTime+int64(policy.GraceHours)*3600, 0).Format("2006-01-02 15:04:05"),
+	}
+	buf := &bytes.Buffer{}
+	if err := envTTLExtendPage.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExtendEnvTTLByToken is called when the extension is confirmed on the page of the extend link in the reminders
+func ExtendEnvTTLByToken(token string, logger *zap.SugaredLogger) (*EnvTTLPolicyResp, error) {
+	env, err := GetEnvTTLByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return extendEnvTTL(env, 0, logger)
+}
+
+func extendEnvTTL(env *commonmodels.Product, hours int, logger *zap.SugaredLogger) (*EnvTTLPolicyResp, error) {
+	policy := env.TTLPolicy
+	if policy == nil {
+		return nil, e.ErrExtendEnvTTL.AddDesc("ttl is not enabled for the environment")
+	}
+	if hours <= 0 {
+		hours = policy.TTLHours
+	}
+
+	wasExpired := policy.SleepTime > 0
+	now := time.Now().Unix()
+	if policy.ExpireTime < now {
+		policy.ExpireTime = now
+	}
+	policy.ExpireTime += int64(hours) * 3600
+	policy.Reminded = false
+	policy.SleepTime = 0
+	if err := commonrepo.NewProductColl().UpdateTTLPolicy(env.EnvName, env.ProductName, policy); err != nil {
+		return nil, e.ErrExtendEnvTTL.AddErr(fmt.Errorf("failed to update ttl policy of %s/%s, err: %w", env.ProductName, env.EnvName, err))
+	}
+	logger.Infof("ttl of environment %s/%s is extended to %s", env.ProductName, env.EnvName, time.Unix(policy.ExpireTime, 0).Format("2006-01-02 15:04:05"))
+
+	if wasExpired && env.IsSleeping() {
+		if err := EnvSleep(env.ProductName, env.EnvName, false, false, logger); err != nil {
+			return nil, e.ErrExtendEnvTTL.AddErr(fmt.Errorf("failed to wake up environment %s/%s, err: %w", env.ProductName, env.EnvName, err))
+		}
+	}
+
+	return &EnvTTLPolicyResp{
+		EnvTTLPolicyArg: &EnvTTLPolicyArg{
+			Enable:       true,
+			TTLHours:     policy.TTLHours,
+			RemindHours:  policy.RemindHours,
+			GraceHours:   policy.GraceHours,
+			Owner:        policy.Owner,
+			OwnerIMAppID: policy.OwnerIMAppID,
+		},
+		ExpireTime: policy.ExpireTime,
+	}, nil
+}
+
+// ReclaimExpiredEnvs reminds the owners of the test environments about to expire, puts the expired ones to sleep and
+// deletes them once the grace period is over
+func ReclaimExpiredEnvs(requestID string, log *zap.SugaredLogger) {
+	envs, err := commonrepo.NewProductColl().List(&commonrepo.ProductListOptions{
+		Production: util.GetBoolPointer(false),
+	})
+	if err != nil {
+		log.Errorf("[ReclaimExpiredEnvs] failed to list environments, err: %s", err)
+		return
+	}
+
+	for _, env := range envs {
+		policy := env.TTLPolicy
+		if policy == nil || policy.ExpireTime == 0 {
+			continue
+		}
+		if env.Status == setting.ProductStatusCreating || env.Status == setting.ProductStatusDeleting {
+			continue
+		}
+
+		now := time.Now().Unix()
+		switch {
+		case policy.SleepTime > 0:
+			if now >= policy.SleepTime+int64(policy.GraceHours)*3600 {
+				reclaimEnv(env, requestID, log)
+			}
+		case now >= policy.ExpireTime:
+			expireEnv(env, log)
+		case policy.RemindHours > 0 && !policy.Reminded && now >= policy.ExpireTime-int64(policy.RemindHours)*3600:
+			remindEnvExpiry(env, log)
+		}
+	}
+}
+
+func remindEnvExpiry(env *commonmodels.Product, log *zap.SugaredLogger) {
+	policy := env.TTLPolicy
+	title := fmt.Sprintf("%s / %s 环境即将到期", env.ProductName, env.EnvName)
+	content := fmt.Sprintf("**负责人：%s** \n**到期时间：%s** \n环境到期后将进入睡眠，并在 %d 小时后被删除，如需继续使用请延长有效期。",
+		policy.Owner, time.Unix(policy.ExpireTime, 0).Format("2006-01-02 15:04:05"), policy.GraceHours)
+
+	// the token is saved before sending so that the link in the reminder works
+	policy.Reminded = true
+	policy.ExtendToken = newEnvExtendToken()
+	if err := commonrepo.NewProductColl().UpdateTTLPolicy(env.EnvName, env.ProductName, policy); err != nil {
+		log.Errorf("failed to update ttl policy of %s/%s, err: %s", env.ProductName, env.EnvName, err)
+		return
+	}
+
+	if err := sendEnvTTLNotification(env, title, content); err != nil {
+		log.Errorf("failed to send expiry reminder of %s/%s, err: %s", env.ProductName, env.EnvName, err)
+	}
+	sendEnvTTLOwnerMessage(env, title, content, log)
+}
+
+func expireEnv(env *commonmodels.Product, log *zap.SugaredLogger) {
+	policy := env.TTLPolicy
+	log.Infof("environment %s/%s expired, put it to sleep", env.ProductName, env.EnvName)
+	// the environment is reclaimed after the grace period even if it can not be put to sleep
+	if !env.IsSleeping() {
+		if err := EnvSleep(env.ProductName, env.EnvName, true, false, log); err != nil {
+			log.Errorf("failed to put expired environment %s/%s to sleep, err: %s", env.ProductName, env.EnvName, err)
+		}
+	}
+
+	policy.SleepTime = time.Now().Unix()
+	policy.ExtendToken = newEnvExtendToken()
+	if err := commonrepo.NewProductColl().UpdateTTLPolicy(env.EnvName, env.ProductName, policy); err != nil {
+		log.Errorf("failed to update ttl policy of %s/%s, err: %s", env.ProductName, env.EnvName, err)
+		return
+	}
+
+	title := fmt.Sprintf("%s / %s 环境已到期", env.ProductName, env.EnvName)
+	content := fmt.Sprintf("**负责人：%s** \n环境已进入睡眠，将于 %s 被删除，如需继续使用请延长有效期。",
+		policy.Owner, time.Unix(policy.SleepTime+int64(policy.GraceHours)*3600, 0).Format("2006-01-02 15:04:05"))
+	if err := sendEnvTTLNotification(env, title, content); err != nil {
+		log.Errorf("failed to send expiry notification of %s/%s, err: %s", env.ProductName, env.EnvName, err)
+	}
+	sendEnvTTLOwnerMessage(env, title, content, log)
+}
+
+func reclaimEnv(env *commonmodels.Product, requestID string, log *zap.SugaredLogger) {
+	record := &commonmodels.EnvReclaimRecord{
+		ProductName: env.ProductName,
+		EnvName:     env.EnvName,
+		ClusterID:   env.ClusterID,
+		Namespace:   env.Namespace,
+		Owner:       env.TTLPolicy.Owner,
+		ExpireTime:  env.TTLPolicy.ExpireTime,
+		SleepTime:   env.TTLPolicy.SleepTime,
+	}
+	// the requests are counted before the deletion since the workloads are gone afterwards
+	cpu, memory, err := envResourceRequests(env)
+	if err != nil {
+		log.Warnf("failed to count resource requests of %s/%s, err: %s", env.ProductName, env.EnvName, err)
+	}
+	record.CPURequest, record.MemoryRequest = cpu, memory
+
+	log.Infof("environment %s/%s expired %d hours ago, delete it", env.ProductName, env.EnvName, env.TTLPolicy.GraceHours)
+	if err := DeleteProduct("robot", env.EnvName, env.ProductName, requestID, true, log); err != nil {
+		log.Errorf("failed to delete expired environment %s/%s, err: %s", env.ProductName, env.EnvName, err)
+		return
+	}
+
+	record.DeleteTime = time.Now().Unix()
+	if err := commonrepo.NewEnvReclaimRecordColl().Create(record); err != nil {
+		log.Errorf("failed to save reclaim record of %s/%s, err: %s", env.ProductName, env.EnvName, err)
+	}
+}
+
+// envResourceRequests sums the cpu and memory requested by the deployments and statefulsets of the environment, the
+// replicas before the sleep are used for a sleeping environment
+func envResourceRequests(env *commonmodels.Product) (int64, int64, error) {
+	kubeClient, err := kubeclient.GetKubeClient(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	replicasOf := func(name string, replicas *int32) int64 {
+		if env.IsSleeping() {
+			if num, ok := env.PreSleepStatus[name]; ok {
+				return int64(num)
+			}
+		}
+		if replicas == nil {
+			return 1
+		}
+		return int64(*replicas)
+	}
+
+	var cpu, memory int64
+	addRequests := func(podSpec corev1.PodSpec, replicas int64) {
+		for _, container := range podSpec.Containers {
+			cpu += container.Resources.Requests.Cpu().MilliValue() * replicas
+			memory += container.Resources.Requests.Memory().Value() * replicas
+		}
+	}
+
+	deployments, err := getter.ListDeployments(env.Namespace, nil, kubeClient)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, deployment := range deployments {
+		if deployment.Name == wakeProxyName {
+			continue
+		}
+		addRequests(deployment.Spec.Template.Spec, replicasOf(deployment.Name, deployment.Spec.Replicas))
+	}
+
+	statefulSets, err := getter.ListStatefulSets(env.Namespace, nil, kubeClient)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, sts := range statefulSets {
+		addRequests(sts.Spec.Template.Spec, replicasOf(sts.Name, sts.Spec.Replicas))
+	}
+	return cpu, memory, nil
+}
+
+func envTTLExtendURL(env *commonmodels.Product) string {
+	return fmt.Sprintf("%s/api/aslan/environment/ttl/extend/%s", strings.TrimSuffix(configbase.SystemAddress(), "/"), env.TTLPolicy.ExtendToken)
+}
+
+func sendEnvTTLNotification(env *commonmodels.Product, title, content string) error {
+	extendURL := envTTLExtendURL(env)
+	buttonContent := fmt.Sprintf("延长 %d 小时", env.TTLPolicy.TTLHours)
+
+	imnotifyClient := imnotify.NewIMNotifyClient()
+	for _, notifyConfig := range env.NotificationConfigs {
+		found := false
+		for _, event := range notifyConfig.Events {
+			if event == commonmodels.NotificationEventEnvExpiring {
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		var err error
+		markdown := fmt.Sprintf("### %s \n%s \n\n[%s](%s)", title, content, buttonContent, extendURL)
+		switch imnotify.IMNotifyType(notifyConfig.WebHookType) {
+		case imnotify.IMNotifyTypeDingDing:
+			err = imnotifyClient.SendDingDingMessage(notifyConfig.WebHookURL, title, markdown, nil, false)
+		case imnotify.IMNotifyTypeLark:
+			lc := imnotify.NewLarkCard()
+			lc.SetConfig(true)
+			lc.SetHeader(imnotify.GetColorTemplateWithStatus(config.StatusTimeout), title, "plain_text")
+			lc.AddI18NElementsZhcnFeild(content, true)
+			lc.AddI18NElementsZhcnAction(buttonContent, extendURL)
+			err = imnotifyClient.SendFeishuMessage(notifyConfig.WebHookURL, lc)
+		case imnotify.IMNotifyTypeWeChat:
+			err = imnotifyClient.SendWeChatWorkMessage(imnotify.WeChatTextTypeMarkdown, notifyConfig.WebHookURL, markdown)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendEnvTTLOwnerMessage sends the reminder to the owner with the im app of the policy, the owner is looked up by
+// the phone or the email of the zadig user
+func sendEnvTTLOwnerMessage(env *commonmodels.Product, title, content string, log *zap.SugaredLogger) {
+	policy := env.TTLPolicy
+	if policy.OwnerIMAppID == "" || policy.Owner == "" {
+		return
+	}
+	if err := sendEnvTTLOwnerMessageWithIMApp(policy.OwnerIMAppID, policy.Owner, title, content, envTTLExtendURL(env), policy.TTLHours); err != nil {
+		log.Errorf("failed to send ttl message of %s/%s to owner %s, err: %s", env.ProductName, env.EnvName, policy.Owner, err)
+	}
+}
+
+func sendEnvTTLOwnerMessageWithIMApp(imAppID, owner, title, content, extendURL string, ttlHours int) error {
+	ownerInfo, err := findEnvOwner(owner)
+	if err != nil {
+		return err
+	}
+
+	imApp, err := commonrepo.NewIMAppColl().GetByID(context.Background(), imAppID)
+	if err != nil {
+		return fmt.Errorf("failed to get im app %s, err: %w", imAppID, err)
+	}
+	switch imApp.Type {
+	case setting.IMLark:
+		client := lark.NewClient(imApp.AppID, imApp.AppSecret)
+		openID, err := client.GetUserOpenIDByEmailOrMobile(lark.QueryTypeMobile, ownerInfo.Phone)
+		if err != nil {
+			if openID, err = client.GetUserOpenIDByEmailOrMobile(lark.QueryTypeEmail, ownerInfo.Email); err != nil {
+				return fmt.Errorf("failed to find the lark user, err: %w", err)
+			}
+		}
+		text := fmt.Sprintf("%s\n%s\n延长 %d 小时：%s", title, strings.ReplaceAll(content, "**", ""), ttlHours, extendURL)
+		return client.SendTextMessage(openID, text)
+	case setting.IMDingTalk:
+		client := dingtalk.NewClient(imApp.DingTalkAppKey, imApp.DingTalkAppSecret)
+		userID, err := client.GetUserIDByMobile(ownerInfo.Phone)
+		if err != nil {
+			return fmt.Errorf("failed to find the dingtalk user, err: %w", err)
+		}
+		markdown := fmt.Sprintf("### %s \n%s \n\n[延长 %d 小时](%s)", title, content, ttlHours, extendURL)
+		return client.SendMarkdownMessage([]string{userID.UserID}, title, markdown)
+	default:
+		return fmt.Errorf("im app of type %s can not send messages to the owner", imApp.Type)
+	}
+}
+
+// findEnvOwner finds the zadig user whose name or account is the owner of the policy
+func findEnvOwner(owner string) (*user.User, error) {
+	resp, err := user.New().SearchUser(&user.SearchUserArgs{Name: owner})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search user %s, err: %w", owner, err)
+	}
+	for _, u := range resp.Users {
+		if u.Name == owner || u.Account == owner {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("user %s not found", owner)
+}
+
+func GetEnvReclaimReport(projectName string, startTime, endTime int64) (*EnvReclaimReport, error) {
+	records, err := commonrepo.NewEnvReclaimRecordColl().List(&commonrepo.EnvReclaimRecordListOption{
+		ProductName: projectName,
+		StartTime:   startTime,
+		EndTime:     endTime,
+	})
+	if err != nil {
+		return nil, e.ErrGetEnvReclaimReport.AddErr(err)
+	}
+
+	resp := &EnvReclaimReport{Records: records}
+	for _, record := range records {
+		resp.CPURequest += record.CPURequest
+		resp.MemoryRequest += record.MemoryRequest
+	}
+	return resp, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+var _ = Describe("Testing env ttl", func() {
+
+	Describe("test newEnvExtendToken", func() {
+		It("should generate a different token every time", func() {
+			token := newEnvExtendToken()
+			Expect(token).To(HaveLen(32))
+			Expect(token).NotTo(Equal(newEnvExtendToken()))
+		})
+	})
+
+	Describe("test RenderEnvTTLExtendPage", func() {
+		env := &commonmodels.Product{
+			ProductName: "demo",
+			EnvName:     "<dev>",
+			TTLPolicy: &commonmodels.EnvTTLPolicy{
+				TTLHours:   24,
+				GraceHours: 48,
+				Owner:      "alice",
+				ExpireTime: 1700000000,
+			},
+		}
+
+		It("should ask to confirm the extension with a POST", func() {
+			page, err := RenderEnvTTLExtendPage(env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(page)).To(ContainSubstring("延长 24 小时"))
+			Expect(string(page)).To(ContainSubstring(`method: "POST"`))
+			Expect(string(page)).To(ContainSubstring("到期时间"))
+		})
+
+		It("should escape the environment name", func() {
+			page, err := RenderEnvTTLExtendPage(env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(page)).To(ContainSubstring("demo / &lt;dev&gt;"))
+			Expect(string(page)).NotTo(ContainSubstring("<dev>"))
+		})
+
+		It("should show the deletion time of an expired environment", func() {
+			expired := *env.TTLPolicy
+			expired.SleepTime = 1700000000
+			page, err := RenderEnvTTLExtendPage(&commonmodels.Product{ProductName: "demo", EnvName: "dev", TTLPolicy: &expired})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(page)).To(ContainSubstring("将于"))
+			Expect(string(page)).NotTo(ContainSubstring("到期时间"))
+		})
+	})
+})
@@ -588,6 +588,18 @@ func CreateProduct(user, requestID string, args *ProductCreateArg, log *zap.Suga
 	log.Infof("[%s][P:%s] CreateProduct", args.EnvName, args.ProductName)
 	creator := getCreatorBySource(args.Source)
 	args.UpdateBy = user
+
+	// the TTL of an environment copied from another one starts over as well
+	if args.TTLPolicy != nil {
+		if args.Production || args.TTLPolicy.TTLHours <= 0 {
+			args.TTLPolicy = nil
+		} else {
+			policy := *args.TTLPolicy
+			policy.Owner = user
+			initEnvTTLPolicy(&policy)
+			args.TTLPolicy = &policy
+		}
+	}
 	return creator.Create(user, requestID, args, log)
 }
 
@@ -4048,6 +4060,7 @@ func EnvSleep(productName, envName string, isEnable, isProduction bool, log *zap
 		return e.ErrEnvSleep.AddErr(wrapErr)
 	}
 
+	onEnvSleepStatusChanged(prod, isEnable, log)
 	return nil
 }
 
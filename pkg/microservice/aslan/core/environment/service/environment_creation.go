@@ -139,6 +139,7 @@ func createSingleHostProduct(templateProduct *templatemodels.Product, requestID,
 		IsExisted:       arg.IsExisted,
 		Production:      arg.Production,
 		Alias:           arg.Alias,
+		TTLPolicy:       arg.TTLPolicy,
 	}
 
 	return CreateProduct(userName, requestID, &ProductCreateArg{productObj, nil}, log)
@@ -163,6 +164,7 @@ func createSingleHelmProduct(templateProduct *templatemodels.Product, requestID,
 		ShareEnv:        arg.ShareEnv,
 		Production:      arg.Production,
 		Alias:           arg.Alias,
+		TTLPolicy:       arg.TTLPolicy,
 	}
 
 	// fill services and chart infos of product
@@ -295,6 +297,7 @@ func createSingleYamlProduct(templateProduct *templatemodels.Product, requestID,
 		ShareEnv:        arg.ShareEnv,
 		Production:      arg.Production,
 		Alias:           arg.Alias,
+		TTLPolicy:       arg.TTLPolicy,
 	}
 	if len(arg.BaseEnvName) > 0 {
 		productObj.BaseEnvName = arg.BaseEnvName
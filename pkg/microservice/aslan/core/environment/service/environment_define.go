@@ -168,6 +168,9 @@ type CreateSingleProductArg struct {
 	ShareEnv commonmodels.ProductShareEnv `json:"share_env"`
 	// New Since v1.13.0
 	EnvConfigs []*commonmodels.CreateUpdateCommonEnvCfgArgs `json:"env_configs"`
+
+	// reclaim the environment when it expires, only the ttl_hours, remind_hours and grace_hours are used
+	TTLPolicy *commonmodels.EnvTTLPolicy `json:"ttl_policy,omitempty"`
 }
 
 type UpdateMultiHelmProductArg struct {
@@ -24,6 +24,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	logservice "github.com/koderover/zadig/pkg/microservice/aslan/core/log/service"
 	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
 	e "github.com/koderover/zadig/pkg/tool/errors"
@@ -69,8 +70,18 @@ func GetWorkflowV4JobContainerLogs(c *gin.Context) {
 		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
 		return
 	}
+	jobName := c.Param("jobName")
+	// logs of the retried attempts are kept separately, attempt starts from 1
+	if attemptStr := c.Query("attempt"); attemptStr != "" {
+		attempt, err := strconv.ParseInt(attemptStr, 10, 64)
+		if err != nil || attempt < 1 {
+			ctx.Err = e.ErrInvalidParam.AddDesc("invalid attempt")
+			return
+		}
+		jobName = commonmodels.JobAttemptLogName(jobName, attempt)
+	}
 	// Use all lowercase job names to avoid subdomain errors
-	ctx.Resp, ctx.Err = logservice.GetWorkflowV4JobContainerLogs(strings.ToLower(c.Param("workflowName")), c.Param("jobName"), taskID, ctx.Logger)
+	ctx.Resp, ctx.Err = logservice.GetWorkflowV4JobContainerLogs(strings.ToLower(c.Param("workflowName")), jobName, taskID, ctx.Logger)
 }
 
 func GetTestJobContainerLogs(c *gin.Context) {
@@ -166,6 +166,7 @@ func Start(ctx context.Context) {
 }
 
 func Stop(ctx context.Context) {
+	workflowcontroller.ReleaseWorkflowTaskLeases()
 	mongotool.Close(ctx)
 	gormtool.Close()
 }
@@ -382,6 +383,13 @@ func initDatabase() {
 		commonrepo.NewDiffNoteColl(),
 		commonrepo.NewDindCleanColl(),
 		commonrepo.NewIMAppColl(),
+		commonrepo.NewWebhookApprovalResultColl(),
+		commonrepo.NewEnvDriftReportColl(),
+		commonrepo.NewEnvIdleStateColl(),
+		commonrepo.NewEnvReclaimRecordColl(),
+		commonrepo.NewEnvSnapshotColl(),
+		commonrepo.NewEnvPreviewPolicyColl(),
+		commonrepo.NewEnvPreviewColl(),
 		commonrepo.NewObservabilityColl(),
 		commonrepo.NewFavoriteColl(),
 		commonrepo.NewGithubAppColl(),
@@ -424,6 +432,13 @@ func initDatabase() {
 		commonrepo.NewWorkflowV4Coll(),
 		commonrepo.NewworkflowTaskv4Coll(),
 		commonrepo.NewWorkflowQueueColl(),
+		commonrepo.NewWorkflowCodeSettingColl(),
+		commonrepo.NewWorkflowV4RevisionColl(),
+		commonrepo.NewSigningKeyColl(),
+		commonrepo.NewSupplyChainArtifactColl(),
+		commonrepo.NewVulnerabilityScanResultColl(),
+		commonrepo.NewTestCaseResultColl(),
+		commonrepo.NewTestCoverageColl(),
 		commonrepo.NewPluginRepoColl(),
 		commonrepo.NewWorkflowViewColl(),
 		commonrepo.NewWorkflowV4TemplateColl(),
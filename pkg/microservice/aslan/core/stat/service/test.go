@@ -32,8 +32,15 @@ type testDashboard struct {
 	TotalExecCount  int   `json:"total_exec_count"`
 	Success         int   `json:"success"`
 	AverageDuration int64 `json:"average_duration"`
+	// case rankings from the test case results of the workflow testing jobs
+	SlowestCases    []*commonrepo.TestCaseStat `json:"slowest_cases"`
+	MostFailedCases []*commonrepo.TestCaseStat `json:"most_failed_cases"`
+	FlakyCases      []*commonrepo.TestCaseStat `json:"flaky_cases"`
 }
 
+// testCaseRankLimit is the number of cases in each ranking of the test dashboard
+const testCaseRankLimit = 10
+
 func GetTestDashboard(startTime, endTime int64, productName string, log *zap.SugaredLogger) (*testDashboard, error) {
 	var (
 		testDashboard  = new(testDashboard)
@@ -76,6 +83,15 @@ func GetTestDashboard(startTime, endTime int64, productName string, log *zap.Sug
 	testDashboard.TotalCaseCount = totalCaseCount
 	testDashboard.TotalExecCount = totalSuccess + totalFailure
 	testDashboard.Success = totalSuccess
+
+	projects := make([]string, 0)
+	if productName != "" {
+		projects = append(projects, productName)
+	}
+	if err := fillTestCaseRankings(testDashboard, startTime, endTime, projects); err != nil {
+		log.Errorf("Failed to list test case stats err:%s", err)
+		return nil, err
+	}
 	if testDashboard.TotalExecCount == 0 {
 		testDashboard.AverageDuration = 0
 		return testDashboard, nil
@@ -85,6 +101,31 @@ func GetTestDashboard(startTime, endTime int64, productName string, log *zap.Sug
 	return testDashboard, nil
 }
 
+func fillTestCaseRankings(testDashboard *testDashboard, startTime, endTime int64, projects []string) error {
+	rankings := []struct {
+		sortBy string
+		cases  *[]*commonrepo.TestCaseStat
+	}{
+		{commonrepo.TestCaseStatSortByDuration, &testDashboard.SlowestCases},
+		{commonrepo.TestCaseStatSortByFailure, &testDashboard.MostFailedCases},
+		{commonrepo.TestCaseStatSortByFlaky, &testDashboard.FlakyCases},
+	}
+	for _, ranking := range rankings {
+		cases, err := commonrepo.NewTestCaseResultColl().ListTestCaseStats(&commonrepo.TestCaseStatOption{
+			ProjectNames: projects,
+			StartTime:    startTime,
+			EndTime:      endTime,
+			SortBy:       ranking.sortBy,
+			Limit:        testCaseRankLimit,
+		})
+		if err != nil {
+			return err
+		}
+		*ranking.cases = cases
+	}
+	return nil
+}
+
 type ProjectsTestStatTotal struct {
 	ProjectName   string         `json:"project_name"`
 	TestStatTotal *TestStatTotal `json:"test_stat_total"`
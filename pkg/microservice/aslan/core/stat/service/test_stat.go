@@ -308,6 +308,22 @@ func GetTestAverageMeasure(startDate, endDate int64, productNames []string, log
 type testCaseStat struct {
 	Day      int64 `json:"day"`
 	TestCase int   `json:"testCase"`
+	// failed and flaky case executions from the test case results of the workflow testing jobs
+	Failure int64 `json:"failure"`
+	Flaky   int64 `json:"flaky"`
+}
+
+func (s *testCaseStat) fillFailures(productNames []string, startTime, endTime int64) error {
+	failure, flaky, err := commonmongodb.NewTestCaseResultColl().CountFailedAndFlaky(&commonmongodb.TestCaseStatOption{
+		ProjectNames: productNames,
+		StartTime:    startTime,
+		EndTime:      endTime,
+	})
+	if err != nil {
+		return err
+	}
+	s.Failure, s.Flaky = failure, flaky
+	return nil
 }
 
 func GetTestCaseMeasure(startDate, endDate int64, productNames []string, log *zap.SugaredLogger) ([]*testCaseStat, error) {
@@ -341,8 +357,14 @@ func GetTestCaseMeasure(startDate, endDate int64, productNames []string, log *za
 			Day:      time.Now().Unix(),
 			TestCase: totalTestCase,
 		}
+		if err := testCaseStat.fillFailures(productNames, startDate, endDate); err != nil {
+			log.Errorf("Failed to count failed test cases, err: %s", err)
+			return nil, err
+		}
 		testCaseStats = append(testCaseStats, testCaseStat)
 	} else {
+		// the dates are in descending order, each stat covers the days since the previous one
+		periodEnd := endDate
 		for index, testStatDate := range testStatDateKeys {
 			for _, testStat := range testStatMap[testStatDate] {
 				totalTestCase += testStat.TotalTestCount * testStat.TotalTestCase
@@ -358,8 +380,13 @@ func GetTestCaseMeasure(startDate, endDate int64, productNames []string, log *za
 					Day:      day.Unix(),
 					TestCase: totalTestCase,
 				}
+				if err := testCaseStat.fillFailures(productNames, day.Unix(), periodEnd); err != nil {
+					log.Errorf("Failed to count failed test cases, err: %s", err)
+					return nil, err
+				}
 				testCaseStats = append(testCaseStats, testCaseStat)
 				totalTestCase = 0
+				periodEnd = day.Unix() - 1
 			}
 		}
 	}
@@ -121,11 +121,20 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		capacity.POST("/clean", CleanCache)
 	}
 
+	// vulnerability database mirror of the trivy scanner
+	vulnerability := router.Group("vulnerability")
+	{
+		vulnerability.GET("/db", GetVulnerabilityDBSettings)
+		vulnerability.POST("/db", UpdateVulnerabilityDBSettings)
+	}
+
 	// workflow concurrency settings
 	concurrency := router.Group("concurrency")
 	{
 		concurrency.GET("/workflow", GetWorkflowConcurrency)
 		concurrency.POST("/workflow", UpdateWorkflowConcurrency)
+		concurrency.GET("/project", GetProjectConcurrency)
+		concurrency.POST("/project", UpdateProjectConcurrency)
 	}
 
 	// default login default login home page settings
@@ -254,6 +263,18 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		sonar.POST("/validate", ValidateSonarInformation)
 	}
 
+	// ---------------------------------------------------------------------------------------
+	// signing key API
+	// ---------------------------------------------------------------------------------------
+	signingKey := router.Group("signingKey")
+	{
+		signingKey.GET("", ListSigningKeys)
+		signingKey.GET("/:id", GetSigningKey)
+		signingKey.POST("", CreateSigningKey)
+		signingKey.PUT("/:id", UpdateSigningKey)
+		signingKey.DELETE("/:id", DeleteSigningKey)
+	}
+
 	// ---------------------------------------------------------------------------------------
 	// configuration management integration API
 	// ---------------------------------------------------------------------------------------
@@ -304,6 +325,11 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		dingtalk.POST("/:ak/webhook", DingTalkEventHandler)
 	}
 
+	approvalWebhook := router.Group("approval_webhook")
+	{
+		approvalWebhook.POST("/:id/callback", WebhookApprovalEventHandler)
+	}
+
 	pm := router.Group("project_management")
 	{
 		pm.GET("", ListProjectManagement)
@@ -0,0 +1,177 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/system/service"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+// @Summary List Signing Keys
+// @Description List the keys used to sign images and attestations, the private keys are not returned
+// @Tags 	system
+// @Accept 	json
+// @Produce json
+// @Success 200 		{array} 	commonmodels.SigningKey
+// @Router /api/aslan/system/signingKey [get]
+func ListSigningKeys(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.ListSigningKeys(ctx.Logger)
+}
+
+// @Summary Get Signing Key
+// @Description Get the signing key, the private key is not returned
+// @Tags 	system
+// @Accept 	json
+// @Produce json
+// @Param 	id			path		string							true	"id"
+// @Success 200 		{object} 	commonmodels.SigningKey
+// @Router /api/aslan/system/signingKey/{id} [get]
+func GetSigningKey(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.GetSigningKey(c.Param("id"), ctx.Logger)
+}
+
+// @Summary Create Signing Key
+// @Description Create a cosign key pair for signing images and attestations
+// @Tags 	system
+// @Accept 	json
+// @Produce json
+// @Param 	body 		body 		commonmodels.SigningKey 		true 	"body"
+// @Success 200
+// @Router /api/aslan/system/signingKey [post]
+func CreateSigningKey(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	args := new(commonmodels.SigningKey)
+	data, err := c.GetRawData()
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+	if err := json.Unmarshal(data, args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+	internalhandler.InsertOperationLog(c, ctx.UserName, "", "新增", "系统配置-签名密钥", args.Name, "", ctx.Logger)
+
+	// authorization checks
+	if !ctx.Resources.IsSystemAdmin {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Err = service.CreateSigningKey(args, ctx.UserName, ctx.Logger)
+}
+
+// @Summary Update Signing Key
+// @Description Update the signing key, the saved private key is kept if it is not provided
+// @Tags 	system
+// @Accept 	json
+// @Produce json
+// @Param 	id			path		string							true	"id"
+// @Param 	body 		body 		commonmodels.SigningKey 		true 	"body"
+// @Success 200
+// @Router /api/aslan/system/signingKey/{id} [put]
+func UpdateSigningKey(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	args := new(commonmodels.SigningKey)
+	data, err := c.GetRawData()
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+	if err := json.Unmarshal(data, args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+	internalhandler.InsertOperationLog(c, ctx.UserName, "", "更新", "系统配置-签名密钥", args.Name, "", ctx.Logger)
+
+	// authorization checks
+	if !ctx.Resources.IsSystemAdmin {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Err = service.UpdateSigningKey(c.Param("id"), args, ctx.UserName, ctx.Logger)
+}
+
+// @Summary Delete Signing Key
+// @Description Delete the signing key
+// @Tags 	system
+// @Accept 	json
+// @Produce json
+// @Param 	id			path		string							true	"id"
+// @Success 200
+// @Router /api/aslan/system/signingKey/{id} [delete]
+func DeleteSigningKey(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	// authorization checks
+	if !ctx.Resources.IsSystemAdmin {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	internalhandler.InsertOperationLog(c, ctx.UserName, "", "删除", "系统配置-签名密钥", fmt.Sprintf("id:%s", c.Param("id")), "", ctx.Logger)
+	ctx.Err = service.DeleteSigningKey(c.Param("id"), ctx.Logger)
+}
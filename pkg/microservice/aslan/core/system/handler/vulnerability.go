@@ -0,0 +1,88 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/system/service"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// @Summary Get Vulnerability DB Settings
+// @Description Get the vulnerability database mirror used by the trivy scanner
+// @Tags 	system
+// @Accept 	json
+// @Produce json
+// @Success 200 	{object} 	types.VulnerabilityDBSettings
+// @Router /api/aslan/system/vulnerability/db [get]
+func GetVulnerabilityDBSettings(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	// authorization checks
+	if !ctx.Resources.IsSystemAdmin {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	ctx.Resp, ctx.Err = service.GetVulnerabilityDBSettings(ctx.Logger)
+}
+
+// @Summary Update Vulnerability DB Settings
+// @Description Point the trivy scanner to a vulnerability database mirror, the public database is used if it is empty
+// @Tags 	system
+// @Accept 	json
+// @Produce json
+// @Param 	body 	body 		types.VulnerabilityDBSettings 	true 	"body"
+// @Success 200
+// @Router /api/aslan/system/vulnerability/db [post]
+func UpdateVulnerabilityDBSettings(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	// authorization checks
+	if !ctx.Resources.IsSystemAdmin {
+		ctx.UnAuthorized = true
+		return
+	}
+
+	args := new(types.VulnerabilityDBSettings)
+	if err := c.ShouldBindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+	internalhandler.InsertOperationLog(c, ctx.UserName, "", "更新", "漏洞数据库", args.DBRepository, "", ctx.Logger)
+
+	ctx.Err = service.UpdateVulnerabilityDBSettings(args, ctx.Logger)
+}
@@ -43,6 +43,10 @@ func UpdateSysCapStrategy(strategy *commonmodels.CapacityStrategy) error {
 	}
 
 	// 更新成功后，立即按照新的配置清理数据
+	if strategy.Target == commonmodels.WorkflowCacheRetention {
+		go handleWorkflowCacheRetention(strategy, false)
+		return nil
+	}
 	go handleWorkflowTaskRetentionCenter(strategy, false)
 
 	return nil
@@ -53,6 +57,9 @@ func GetCapacityStrategy(target commonmodels.CapacityTarget) (*commonmodels.Capa
 	if err != nil && target == commonmodels.WorkflowTaskRetention {
 		return commonmodels.DefaultWorkflowTaskRetention, nil // Return default setup
 	}
+	if err != nil && target == commonmodels.WorkflowCacheRetention {
+		return commonmodels.DefaultWorkflowCacheRetention, nil
+	}
 	return result, err
 }
 
@@ -65,6 +72,14 @@ func HandleSystemGC(dryRun bool) error {
 		return err
 	}
 
+	cacheStrategy, err := commonrepo.NewStrategyColl().GetByTarget(commonmodels.WorkflowCacheRetention)
+	if err != nil {
+		cacheStrategy = commonmodels.DefaultWorkflowCacheRetention
+	}
+	if err := handleWorkflowCacheRetention(cacheStrategy, dryRun); err != nil {
+		log.Errorf("clean workflow cache error: %v", err)
+	}
+
 	return handleWorkflowTaskRetentionCenter(strategy, dryRun)
 }
 
@@ -397,6 +412,16 @@ func validateStrategy(strategy *commonmodels.CapacityStrategy) error {
 				"can only set one positive value at a time. days: %v, items: %v",
 				retention.MaxDays, retention.MaxItems)
 		}
+	} else if strategy.Target == commonmodels.WorkflowCacheRetention {
+		retention := strategy.Retention
+		if retention == nil {
+			return errors.New("SysCap strategy: nil retention config for WorkflowCacheRetention")
+		}
+		if retention.MaxDays < 0 || retention.MaxSizeMB < 0 || (retention.MaxDays == 0 && retention.MaxSizeMB == 0) {
+			return fmt.Errorf("SysCap strategy: max days or max size value invalid, "+
+				"at least one positive value is required. days: %v, size: %vMB",
+				retention.MaxDays, retention.MaxSizeMB)
+		}
 	} else {
 		// Note: currently doesn't support other strategies yet.
 		return fmt.Errorf("SysCap strategy target is invalid - passed in value: %v", strategy.Target)
@@ -0,0 +1,124 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/s3"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+type cacheArchive struct {
+	key      string
+	size     int64
+	lastUsed time.Time
+}
+
+// handleWorkflowCacheRetention removes the caches saved by cache_save steps which have not been used for MaxDays,
+// then removes the least recently used caches until the total size is under MaxSizeMB.
+func handleWorkflowCacheRetention(strategy *commonmodels.CapacityStrategy, dryRun bool) error {
+	s3Server, err := s3.FindDefaultS3()
+	if err != nil {
+		return err
+	}
+	forcedPathStyle := true
+	if s3Server.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3tool.NewClient(s3Server.Endpoint, s3Server.Ak, s3Server.Sk, s3Server.Region, s3Server.Insecure, forcedPathStyle)
+	if err != nil {
+		return err
+	}
+	objects, err := client.ListObjectInfos(s3Server.Bucket, s3Server.GetObjectPath(step.CacheRootDir)+"/")
+	if err != nil {
+		return err
+	}
+
+	expired := expiredCacheObjects(objects, strategy.Retention, time.Now())
+	log.Infof("%d of %d cache objects will be cleaned up", len(expired), len(objects))
+	if dryRun || len(expired) == 0 {
+		return nil
+	}
+	// DeleteObjects accepts at most 1000 keys per request
+	const batch = 1000
+	for i := 0; i < len(expired); i += batch {
+		end := i + batch
+		if end > len(expired) {
+			end = len(expired)
+		}
+		if err := client.DeleteObjects(s3Server.Bucket, expired[i:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expiredCacheObjects returns the keys of the cache archives and their access markers to be removed.
+func expiredCacheObjects(objects []*s3tool.ObjectInfo, retention *commonmodels.RetentionConfig, now time.Time) []string {
+	accessTime := map[string]time.Time{}
+	for _, object := range objects {
+		dir, name := path.Split(object.Key)
+		if path.Base(dir) == step.CacheAccessDir {
+			accessTime[path.Join(path.Dir(dir), name)] = object.LastModified
+		}
+	}
+
+	archives := []*cacheArchive{}
+	var totalSize int64
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Key, step.CacheArchiveSuffix) || path.Base(path.Dir(object.Key)) == step.CacheAccessDir {
+			continue
+		}
+		lastUsed := object.LastModified
+		if t, ok := accessTime[object.Key]; ok && t.After(lastUsed) {
+			lastUsed = t
+		}
+		archives = append(archives, &cacheArchive{key: object.Key, size: object.Size, lastUsed: lastUsed})
+		totalSize += object.Size
+	}
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].lastUsed.Before(archives[j].lastUsed)
+	})
+
+	resp := []string{}
+	remove := func(archive *cacheArchive) {
+		dir, name := path.Split(archive.key)
+		resp = append(resp, archive.key, path.Join(dir, step.CacheAccessDir, name))
+		totalSize -= archive.size
+	}
+	i := 0
+	if retention.MaxDays > 0 {
+		deadline := now.AddDate(0, 0, -retention.MaxDays)
+		for ; i < len(archives) && archives[i].lastUsed.Before(deadline); i++ {
+			remove(archives[i])
+		}
+	}
+	if retention.MaxSizeMB > 0 {
+		for ; i < len(archives) && totalSize > retention.MaxSizeMB*1024*1024; i++ {
+			remove(archives[i])
+		}
+	}
+	return resp
+}
@@ -18,11 +18,14 @@ package service
 
 import (
 	"errors"
+	"fmt"
 
 	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	configbase "github.com/koderover/zadig/pkg/config"
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
 	"github.com/koderover/zadig/pkg/setting"
@@ -61,3 +64,54 @@ func UpdateWorkflowConcurrency(workflowConcurrency, buildConcurrency int64, log
 	}
 	return updater.ScaleDeployment(config.Namespace(), configbase.WarpDriveServiceName(), int(workflowConcurrency), kubeClient)
 }
+
+func GetProjectConcurrency() (*ProjectConcurrencySettings, error) {
+	configuration, err := commonrepo.NewSystemSettingColl().Get()
+	if err != nil {
+		return nil, err
+	}
+	resp := &ProjectConcurrencySettings{
+		DefaultConcurrency:      configuration.DefaultProjectConcurrency,
+		ProjectConcurrency:      configuration.ProjectConcurrency,
+		ReleasePriorityProjects: configuration.ReleasePriorityProjects,
+	}
+	if resp.ProjectConcurrency == nil {
+		resp.ProjectConcurrency = make([]*commonmodels.ProjectConcurrency, 0)
+	}
+	if resp.ReleasePriorityProjects == nil {
+		resp.ReleasePriorityProjects = make([]string, 0)
+	}
+	return resp, nil
+}
+
+// UpdateProjectConcurrency takes effect on the next scheduling of the workflow task queue, the running tasks are not affected.
+func UpdateProjectConcurrency(args *ProjectConcurrencySettings, log *zap.SugaredLogger) error {
+	if args.DefaultConcurrency < 0 {
+		return errors.New("concurrency cannot be less than 0")
+	}
+	projects := sets.NewString()
+	for _, p := range args.ProjectConcurrency {
+		if p.ProjectName == "" {
+			return errors.New("project name cannot be empty")
+		}
+		if p.WorkflowConcurrency < 0 {
+			return errors.New("concurrency cannot be less than 0")
+		}
+		if projects.Has(p.ProjectName) {
+			return fmt.Errorf("duplicated project %s", p.ProjectName)
+		}
+		projects.Insert(p.ProjectName)
+	}
+	releaseProjects := sets.NewString()
+	for _, p := range args.ReleasePriorityProjects {
+		if p == "" {
+			return errors.New("project name cannot be empty")
+		}
+		releaseProjects.Insert(p)
+	}
+	if err := commonrepo.NewSystemSettingColl().UpdateProjectConcurrencySetting(args.DefaultConcurrency, args.ProjectConcurrency, releaseProjects.List()); err != nil {
+		log.Errorf("Failed to update project concurrency settings, the error is: %s", err)
+		return err
+	}
+	return nil
+}
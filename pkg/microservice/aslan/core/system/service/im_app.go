@@ -18,6 +18,7 @@ package service
 
 import (
 	"context"
+	"net/url"
 
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -28,6 +29,7 @@ import (
 	"github.com/koderover/zadig/pkg/tool/dingtalk"
 	e "github.com/koderover/zadig/pkg/tool/errors"
 	"github.com/koderover/zadig/pkg/tool/lark"
+	"github.com/koderover/zadig/pkg/tool/wecom"
 )
 
 func ListIMApp(_type string, log *zap.SugaredLogger) ([]*commonmodels.IMApp, error) {
@@ -46,6 +48,8 @@ func CreateIMApp(args *commonmodels.IMApp, log *zap.SugaredLogger) error {
 		return createDingTalkIMApp(args, log)
 	case setting.IMLark:
 		return createLarkIMApp(args, log)
+	case setting.IMWeCom, setting.IMWebhook:
+		return createGenericIMApp(args, log)
 	default:
 		return errors.Errorf("unknown im type %s", args.Type)
 	}
@@ -105,6 +109,8 @@ func UpdateIMApp(id string, args *commonmodels.IMApp, log *zap.SugaredLogger) er
 		return updateDingTalkIMApp(id, args, log)
 	case setting.IMLark:
 		return updateLarkIMApp(id, args, log)
+	case setting.IMWeCom, setting.IMWebhook:
+		return updateGenericIMApp(id, args, log)
 	default:
 		return errors.Errorf("unknown im type %s", args.Type)
 	}
@@ -157,6 +163,51 @@ func updateLarkIMApp(id string, args *commonmodels.IMApp, log *zap.SugaredLogger
 	return nil
 }
 
+// createGenericIMApp creates the wecom or webhook im app which needs no extra resources in the im.
+func createGenericIMApp(args *commonmodels.IMApp, log *zap.SugaredLogger) error {
+	if err := ValidateIMApp(args, log); err != nil {
+		return e.ErrCreateIMApp.AddErr(errors.Wrap(err, "validate"))
+	}
+
+	_, err := mongodb.NewIMAppColl().Create(context.Background(), args)
+	if err != nil {
+		log.Errorf("create %s IM error: %v", args.Type, err)
+		return e.ErrCreateIMApp.AddErr(err)
+	}
+	return nil
+}
+
+func updateGenericIMApp(id string, args *commonmodels.IMApp, log *zap.SugaredLogger) error {
+	if err := ValidateIMApp(args, log); err != nil {
+		return e.ErrUpdateIMApp.AddErr(errors.Wrap(err, "validate"))
+	}
+
+	err := mongodb.NewIMAppColl().Update(context.Background(), id, args)
+	if err != nil {
+		log.Errorf("update %s IM error: %v", args.Type, err)
+		return e.ErrUpdateIMApp.AddErr(err)
+	}
+	return nil
+}
+
+func validateWebhookIMApp(im *commonmodels.IMApp) error {
+	if im.WebhookURL == "" {
+		return errors.New("webhook url is empty")
+	}
+	if _, err := url.ParseRequestURI(im.WebhookURL); err != nil {
+		return errors.Wrap(err, "invalid webhook url")
+	}
+	if im.WebhookStatusURL != "" {
+		if _, err := url.ParseRequestURI(im.WebhookStatusURL); err != nil {
+			return errors.Wrap(err, "invalid webhook status url")
+		}
+	}
+	if im.WebhookSecret == "" {
+		return errors.New("webhook secret is empty")
+	}
+	return nil
+}
+
 func DeleteIMApp(id string, log *zap.SugaredLogger) error {
 	err := mongodb.NewIMAppColl().DeleteByID(context.Background(), id)
 	if err != nil {
@@ -172,6 +223,10 @@ func ValidateIMApp(im *commonmodels.IMApp, log *zap.SugaredLogger) error {
 		return lark.Validate(im.AppID, im.AppSecret)
 	case setting.IMDingTalk:
 		return dingtalk.Validate(im.DingTalkAppKey, im.DingTalkAppSecret)
+	case setting.IMWeCom:
+		return wecom.Validate(im.WeComCorpID, im.WeComAgentSecret, im.WeComTemplateID)
+	case setting.IMWebhook:
+		return validateWebhookIMApp(im)
 	default:
 		return e.ErrValidateIMApp.AddDesc("invalid type")
 	}
@@ -0,0 +1,121 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+func ListSigningKeys(log *zap.SugaredLogger) ([]*commonmodels.SigningKey, error) {
+	keys, err := commonrepo.NewSigningKeyColl().List()
+	if err != nil {
+		log.Errorf("Failed to list signing keys, the error is: %s", err)
+		return nil, err
+	}
+	for _, key := range keys {
+		maskSigningKey(key)
+	}
+	return keys, nil
+}
+
+func GetSigningKey(id string, log *zap.SugaredLogger) (*commonmodels.SigningKey, error) {
+	key, err := commonrepo.NewSigningKeyColl().Find(id)
+	if err != nil {
+		log.Errorf("Failed to find signing key %s, the error is: %s", id, err)
+		return nil, err
+	}
+	maskSigningKey(key)
+	return key, nil
+}
+
+func CreateSigningKey(args *commonmodels.SigningKey, user string, log *zap.SugaredLogger) error {
+	if err := validateSigningKey(args, true); err != nil {
+		return err
+	}
+	args.UpdateBy = user
+	args.UpdateTime = time.Now().Unix()
+	if err := commonrepo.NewSigningKeyColl().Create(args); err != nil {
+		log.Errorf("Failed to create signing key %s, the error is: %s", args.Name, err)
+		return err
+	}
+	return nil
+}
+
+// UpdateSigningKey keeps the saved private key and password if they are not provided.
+func UpdateSigningKey(id string, args *commonmodels.SigningKey, user string, log *zap.SugaredLogger) error {
+	old, err := commonrepo.NewSigningKeyColl().Find(id)
+	if err != nil {
+		log.Errorf("Failed to find signing key %s, the error is: %s", id, err)
+		return err
+	}
+	if args.PrivateKey == "" {
+		args.PrivateKey = old.PrivateKey
+		if args.Password == "" {
+			args.Password = old.Password
+		}
+	}
+	if err := validateSigningKey(args, false); err != nil {
+		return err
+	}
+	args.ID = old.ID
+	args.UpdateBy = user
+	args.UpdateTime = time.Now().Unix()
+	if err := commonrepo.NewSigningKeyColl().Update(id, args); err != nil {
+		log.Errorf("Failed to update signing key %s, the error is: %s", id, err)
+		return err
+	}
+	return nil
+}
+
+func DeleteSigningKey(id string, log *zap.SugaredLogger) error {
+	if err := commonrepo.NewSigningKeyColl().Delete(id); err != nil {
+		log.Errorf("Failed to delete signing key %s, the error is: %s", id, err)
+		return err
+	}
+	return nil
+}
+
+func maskSigningKey(key *commonmodels.SigningKey) {
+	key.PrivateKey = ""
+	key.Password = ""
+}
+
+// validateSigningKey checks the key pair is PEM encoded as generated by cosign generate-key-pair.
+func validateSigningKey(key *commonmodels.SigningKey, create bool) error {
+	if key.Name == "" {
+		return e.ErrInvalidParam.AddDesc("name is required")
+	}
+	if create && key.PrivateKey == "" {
+		return e.ErrInvalidParam.AddDesc("private key is required")
+	}
+	if block, _ := pem.Decode([]byte(key.PrivateKey)); block == nil || !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+		return e.ErrInvalidParam.AddDesc("private key is not a PEM encoded private key")
+	}
+	if block, _ := pem.Decode([]byte(key.PublicKey)); block == nil || !strings.HasSuffix(block.Type, "PUBLIC KEY") {
+		return e.ErrInvalidParam.AddDesc(fmt.Sprintf("public key of %s is not a PEM encoded public key", key.Name))
+	}
+	return nil
+}
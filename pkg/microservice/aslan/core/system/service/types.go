@@ -48,6 +48,14 @@ type WorkflowConcurrencySettings struct {
 	BuildConcurrency    int64 `json:"build_concurrency"`
 }
 
+type ProjectConcurrencySettings struct {
+	// DefaultConcurrency limits the projects not listed in ProjectConcurrency, 0 means no limit
+	DefaultConcurrency int64                              `json:"default_concurrency"`
+	ProjectConcurrency []*commonmodels.ProjectConcurrency `json:"project_concurrency"`
+	// ReleasePriorityProjects are the projects allowed to run tasks in the release priority class
+	ReleasePriorityProjects []string `json:"release_priority_projects"`
+}
+
 type SonarIntegration struct {
 	ID             string `json:"id"`
 	SystemIdentity string `json:"system_identity"`
@@ -0,0 +1,44 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"go.uber.org/zap"
+
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+func GetVulnerabilityDBSettings(log *zap.SugaredLogger) (*types.VulnerabilityDBSettings, error) {
+	configuration, err := commonrepo.NewSystemSettingColl().Get()
+	if err != nil {
+		log.Errorf("failed to get system settings, error: %s", err)
+		return nil, err
+	}
+	if configuration.VulnerabilityDB == nil {
+		return &types.VulnerabilityDBSettings{}, nil
+	}
+	return configuration.VulnerabilityDB, nil
+}
+
+func UpdateVulnerabilityDBSettings(args *types.VulnerabilityDBSettings, log *zap.SugaredLogger) error {
+	if err := commonrepo.NewSystemSettingColl().UpdateVulnerabilityDBSetting(args); err != nil {
+		log.Errorf("failed to update vulnerability db settings, error: %s", err)
+		return err
+	}
+	return nil
+}
@@ -202,3 +202,19 @@ func ReportAgentJob(c *gin.Context) {
 
 	ctx.Resp, ctx.Err = service.ReportAgentJob(args, ctx.Logger)
 }
+
+func StreamAgentJob(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+
+	token := c.Query("token")
+	if token == "" {
+		ctx.Err = fmt.Errorf("invalid request: %s", "token is empty")
+		internalhandler.JSONResponse(c, ctx)
+		return
+	}
+
+	if err := service.StreamAgentJob(c, token, ctx.Logger); err != nil {
+		ctx.Err = err
+		internalhandler.JSONResponse(c, ctx)
+	}
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	vmmongodb "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb/vm"
+	"github.com/koderover/zadig/pkg/setting"
+)
+
+const (
+	AgentStreamMessageReady  = "ready"
+	AgentStreamMessageReport = "report"
+	AgentStreamMessageAck    = "ack"
+	AgentStreamMessageJob    = "job"
+	AgentStreamMessageCancel = "cancel"
+
+	agentStreamDispatchInterval = time.Second
+	agentStreamPingInterval     = 30 * time.Second
+	agentStreamWriteTimeout     = 10 * time.Second
+)
+
+// AgentStreamMessage is the message exchanged over the job stream between zadig server and the vm agent.
+// The agent sends ready and report messages, the server sends ack, job and cancel messages.
+type AgentStreamMessage struct {
+	Type      string              `json:"type"`
+	Seq       int64               `json:"seq,omitempty"`
+	FreeSlots int                 `json:"free_slots,omitempty"`
+	Report    *ReportJobArgs      `json:"report,omitempty"`
+	Resp      *ReportAgentJobResp `json:"resp,omitempty"`
+	Error     string              `json:"error,omitempty"`
+	Job       *PollingJobResp     `json:"job,omitempty"`
+	JobID     string              `json:"job_id,omitempty"`
+}
+
+var agentStreamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+type agentStreamSession struct {
+	vm        *commonmodels.PrivateKey
+	token     string
+	conn      *websocket.Conn
+	writeLock sync.Mutex
+	freeSlots int32
+	// jobs are the jobs running on the agent, the server pushes a cancel signal once one of them is cancelled
+	jobs   sync.Map
+	logger *zap.SugaredLogger
+}
+
+// StreamAgentJob keeps a websocket with the agent, over which the server pushes jobs and cancel signals and the agent
+// reports the job status and log.
+func StreamAgentJob(c *gin.Context, token string, logger *zap.SugaredLogger) error {
+	vm, err := commonrepo.NewPrivateKeyColl().Find(commonrepo.FindPrivateKeyOption{
+		Token: token,
+	})
+	if err != nil {
+		logger.Errorf("failed to find vm by token %s, error: %s", token, err)
+		return fmt.Errorf("failed to find vm by token %s, error: %s", token, err)
+	}
+	if vm.Status != setting.VMNormal {
+		return fmt.Errorf("vm %s status is %s", vm.Name, vm.Status)
+	}
+
+	conn, err := agentStreamUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.Errorf("failed to upgrade the job stream of vm %s, error: %s", vm.Name, err)
+		return nil
+	}
+	defer conn.Close()
+
+	session := &agentStreamSession{
+		vm:     vm,
+		token:  token,
+		conn:   conn,
+		logger: logger,
+	}
+	logger.Infof("vm %s connected to the job stream", vm.Name)
+
+	stopCh := make(chan struct{})
+	go session.dispatch(stopCh)
+	session.receive()
+	close(stopCh)
+
+	logger.Infof("vm %s disconnected from the job stream", vm.Name)
+	return nil
+}
+
+func (s *agentStreamSession) receive() {
+	for {
+		msg := new(AgentStreamMessage)
+		if err := s.conn.ReadJSON(msg); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.logger.Warnf("failed to read the job stream of vm %s, error: %s", s.vm.Name, err)
+			}
+			return
+		}
+
+		switch msg.Type {
+		case AgentStreamMessageReady:
+			atomic.StoreInt32(&s.freeSlots, int32(msg.FreeSlots))
+		case AgentStreamMessageReport:
+			if err := s.write(s.report(msg)); err != nil {
+				s.logger.Warnf("failed to ack the report of vm %s, error: %s", s.vm.Name, err)
+				return
+			}
+		default:
+			s.logger.Warnf("unknown job stream message type %s from vm %s", msg.Type, s.vm.Name)
+		}
+	}
+}
+
+func (s *agentStreamSession) report(msg *AgentStreamMessage) *AgentStreamMessage {
+	ack := &AgentStreamMessage{Type: AgentStreamMessageAck, Seq: msg.Seq}
+	if msg.Report == nil {
+		ack.Error = "report is empty"
+		return ack
+	}
+
+	msg.Report.Token = s.token
+	resp, err := ReportAgentJob(msg.Report, s.logger)
+	if err != nil {
+		ack.Error = err.Error()
+		return ack
+	}
+	ack.Resp = resp
+
+	switch config.Status(resp.JobStatus) {
+	case config.StatusPassed, config.StatusFailed, config.StatusCancelled, config.StatusTimeout:
+		s.jobs.Delete(resp.JobID)
+	default:
+		s.jobs.Store(resp.JobID, struct{}{})
+	}
+	return ack
+}
+
+func (s *agentStreamSession) dispatch(stopCh chan struct{}) {
+	ticker := time.NewTicker(agentStreamDispatchInterval)
+	defer ticker.Stop()
+	pingTicker := time.NewTicker(agentStreamPingInterval)
+	defer pingTicker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-pingTicker.C:
+			s.writeLock.Lock()
+			err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(agentStreamWriteTimeout))
+			s.writeLock.Unlock()
+			if err != nil {
+				s.logger.Warnf("failed to ping vm %s, error: %s", s.vm.Name, err)
+			}
+		case <-ticker.C:
+			if err := s.pushCancels(); err != nil {
+				s.logger.Warnf("failed to push cancel signals to vm %s, error: %s", s.vm.Name, err)
+				continue
+			}
+			if err := s.pushJobs(); err != nil {
+				s.logger.Warnf("failed to push jobs to vm %s, error: %s", s.vm.Name, err)
+			}
+		}
+	}
+}
+
+func (s *agentStreamSession) pushJobs() error {
+	for atomic.LoadInt32(&s.freeSlots) > 0 {
+		job, err := PollingAgentJob(s.token, 0, s.logger)
+		if err != nil {
+			return err
+		}
+		if job == nil {
+			return nil
+		}
+
+		s.jobs.Store(job.ID, struct{}{})
+		atomic.AddInt32(&s.freeSlots, -1)
+		if err := s.write(&AgentStreamMessage{Type: AgentStreamMessageJob, Job: job}); err != nil {
+			// the job has been taken by this vm, hand it back so that it can be picked up again
+			s.jobs.Delete(job.ID)
+			if releaseErr := ReleaseAgentJob(job.ID, s.vm.ID.Hex()); releaseErr != nil {
+				s.logger.Errorf("failed to release job %s of vm %s, error: %s", job.ID, s.vm.Name, releaseErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *agentStreamSession) pushCancels() error {
+	var err error
+	s.jobs.Range(func(key, value interface{}) bool {
+		jobID := key.(string)
+		job, findErr := vmmongodb.NewVMJobColl().FindByID(jobID)
+		if findErr != nil {
+			s.logger.Warnf("failed to find vm job %s, error: %s", jobID, findErr)
+			return true
+		}
+		if job.Status != string(config.StatusCancelled) && job.Status != string(config.StatusTimeout) {
+			return true
+		}
+
+		if err = s.write(&AgentStreamMessage{Type: AgentStreamMessageCancel, JobID: jobID}); err != nil {
+			return false
+		}
+		s.jobs.Delete(jobID)
+		return true
+	})
+	return err
+}
+
+func (s *agentStreamSession) write(msg *AgentStreamMessage) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	if err := s.conn.SetWriteDeadline(time.Now().Add(agentStreamWriteTimeout)); err != nil {
+		return err
+	}
+	return s.conn.WriteJSON(msg)
+}
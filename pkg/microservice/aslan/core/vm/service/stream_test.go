@@ -0,0 +1,68 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+func TestAgentStreamSessionReceive(t *testing.T) {
+	log.Init(&log.Config{Level: "error"})
+
+	session := &agentStreamSession{vm: &commonmodels.PrivateKey{Name: "vm-1"}, logger: log.SugaredLogger()}
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := agentStreamUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		session.conn = conn
+		session.receive()
+		close(done)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, conn.WriteJSON(&AgentStreamMessage{Type: AgentStreamMessageReady, FreeSlots: 2}))
+	// the report is acked with the same seq, an empty one is refused without touching the job
+	assert.NoError(t, conn.WriteJSON(&AgentStreamMessage{Type: AgentStreamMessageReport, Seq: 3}))
+	ack := new(AgentStreamMessage)
+	assert.NoError(t, conn.ReadJSON(ack))
+	assert.Equal(t, AgentStreamMessageAck, ack.Type)
+	assert.Equal(t, int64(3), ack.Seq)
+	assert.Equal(t, "report is empty", ack.Error)
+	// the ready message is handled before the report
+	assert.Equal(t, int32(2), atomic.LoadInt32(&session.freeSlots))
+
+	assert.NoError(t, conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")))
+	<-done
+	conn.Close()
+}
@@ -152,6 +152,8 @@ type ReportJobArgs struct {
 	JobLog    string `json:"job_log"`
 	JobError  string `json:"job_error"`
 	JobOutput []byte `json:"job_output"`
+	// LogOffset is the offset of JobLog in the agent job log, the agent sends it since it resumes the log by offset
+	LogOffset *int64 `json:"log_offset,omitempty"`
 }
 
 type AgentAccessCmds struct {
@@ -504,9 +504,25 @@ func PollingAgentJob(token string, retry int, logger *zap.SugaredLogger) (*Polli
 	return resp, err
 }
 
+// ReleaseAgentJob resets a job taken by the vm but never delivered to the agent to created, unless the agent has
+// reported it since.
+func ReleaseAgentJob(jobID, vmID string) error {
+	job, err := vmmongodb.NewVMJobColl().FindByID(jobID)
+	if err != nil {
+		return err
+	}
+	if job.Status != string(config.StatusPrepare) || job.VMID != vmID {
+		return nil
+	}
+	job.Status = string(config.StatusCreated)
+	job.VMID = ""
+	return vmmongodb.NewVMJobColl().Update(jobID, job)
+}
+
 type ReportAgentJobResp struct {
 	JobID     string `json:"job_id"`
 	JobStatus string `json:"job_status"`
+	LogOffset *int64 `json:"log_offset,omitempty"`
 }
 
 func ReportAgentJob(args *ReportJobArgs, logger *zap.SugaredLogger) (*ReportAgentJobResp, error) {
@@ -545,8 +561,13 @@ func ReportAgentJob(args *ReportJobArgs, logger *zap.SugaredLogger) (*ReportAgen
 		job.Outputs = outputs
 	}
 
+	jobLog := args.JobLog
+	if args.LogOffset != nil {
+		jobLog = trimReportedLog(job, *args.LogOffset, args.JobLog)
+	}
+
 	// save log to temp file and save the tmep file path to db
-	err = savaVMJobLog(job, args.JobLog, logger)
+	err = savaVMJobLog(job, jobLog, logger)
 	if err != nil {
 		logger.Errorf("failed to save job %s log, error: %s", args.JobID, err)
 		return nil, fmt.Errorf("failed to save job %s log, error: %s", args.JobID, err)
@@ -558,7 +579,27 @@ func ReportAgentJob(args *ReportJobArgs, logger *zap.SugaredLogger) (*ReportAgen
 		return nil, fmt.Errorf("failed to update job %s, error: %s", args.JobID, err)
 	}
 
-	return nil, nil
+	resp := &ReportAgentJobResp{
+		JobID:     job.ID.Hex(),
+		JobStatus: job.Status,
+	}
+	if args.LogOffset != nil {
+		resp.LogOffset = &job.LogOffset
+	}
+	return resp, nil
+}
+
+// trimReportedLog drops the part of the reported log that has been saved before, the agent resends the log from
+// its last acknowledged offset after reconnecting. A log starting after the saved offset is dropped as well, the
+// agent rewinds to the returned offset and sends the missing part again.
+func trimReportedLog(job *vmmodel.VMJob, offset int64, jobLog string) string {
+	end := offset + int64(len(jobLog))
+	if offset > job.LogOffset || end <= job.LogOffset {
+		return ""
+	}
+	jobLog = jobLog[job.LogOffset-offset:]
+	job.LogOffset = end
+	return jobLog
 }
 
 // GenerateAgentToken TODO: consider how to generate vm token
@@ -0,0 +1,47 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	vmmodel "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/vm"
+)
+
+func TestTrimReportedLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		saved     int64
+		offset    int64
+		log       string
+		expected  string
+		newOffset int64
+	}{
+		{name: "new log", saved: 5, offset: 5, log: "world", expected: "world", newOffset: 10},
+		{name: "resent log", saved: 5, offset: 0, log: "hello world", expected: " world", newOffset: 11},
+		{name: "saved log", saved: 11, offset: 0, log: "hello", expected: "", newOffset: 11},
+		{name: "log ends at the saved offset", saved: 5, offset: 0, log: "hello", expected: "", newOffset: 5},
+		{name: "log after a gap", saved: 5, offset: 8, log: "ld", expected: "", newOffset: 5},
+	}
+	for _, tt := range tests {
+		job := &vmmodel.VMJob{LogOffset: tt.saved}
+		assert.Equal(t, tt.expected, trimReportedLog(job, tt.offset, tt.log), tt.name)
+		assert.Equal(t, tt.newOffset, job.LogOffset, tt.name)
+	}
+}
@@ -349,9 +349,26 @@ func OpenAPIApproveStage(c *gin.Context) {
 		return
 	}
 
+	if args.JobName != "" {
+		ctx.Err = workflowservice.ApproveJob(args.WorkflowName, args.JobName, ctx.UserName, ctx.UserID, args.Comment, args.TaskID, args.Approve, ctx.Logger)
+		return
+	}
 	ctx.Err = workflowservice.ApproveStage(args.WorkflowName, args.StageName, ctx.UserName, ctx.UserID, args.Comment, args.TaskID, args.Approve, ctx.Logger)
 }
 
+func OpenAPISubmitManualInput(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+	args := &workflowservice.OpenAPIManualInputRequest{}
+
+	if err := c.ShouldBindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc(err.Error())
+		return
+	}
+
+	ctx.Err = workflowservice.SubmitManualInput(args.WorkflowName, args.JobName, ctx.UserName, ctx.UserID, args.Comment, args.TaskID, args.Inputs, ctx.Logger)
+}
+
 func generalRequestValidate(c *gin.Context) (string, int64, error) {
 	name := c.Param("name")
 	if name == "" {
@@ -171,6 +171,10 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		workflowV4.POST("", CreateWorkflowV4)
 		workflowV4.POST("/:name/workflowtask/field", SetWorkflowTasksCustomFields)
 		workflowV4.GET("/:name/workflowtask/field", GetWorkflowTasksCustomFields)
+		workflowV4.GET("/:name/revisions", ListWorkflowV4Revisions)
+		workflowV4.GET("/:name/revisions/:revision", GetWorkflowV4Revision)
+		workflowV4.GET("/:name/revisions/:revision/diff", DiffWorkflowV4Revisions)
+		workflowV4.POST("/:name/revisions/:revision/restore", RestoreWorkflowV4Revision)
 		workflowV4.GET("", ListWorkflowV4)
 		workflowV4.GET("/trigger", ListWorkflowV4CanTrigger)
 		workflowV4.POST("/lint", LintWorkflowV4)
@@ -218,6 +222,9 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		workflowV4.GET("/bluegreen/:envName/:serviceName", GetBlueGreenServiceK8sServiceYaml)
 		workflowV4.GET("/jenkins/:id/:jobName", GetJenkinsJobParams)
 		workflowV4.POST("/sql/validate", ValidateSQL)
+		workflowV4.GET("/code/setting", GetWorkflowCodeSetting)
+		workflowV4.PUT("/code/setting", UpdateWorkflowCodeSetting)
+		workflowV4.POST("/code/sync", SyncWorkflowCode)
 	}
 
 	// ---------------------------------------------------------------------------------------
@@ -236,8 +243,12 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		taskV4.POST("/debug/:workflowName/task/:taskID", EnableDebugWorkflowTaskV4)
 		taskV4.DELETE("/debug/:workflowName/:jobName/task/:taskID/:position", StopDebugWorkflowTaskJobV4)
 		taskV4.POST("/approve", ApproveStage)
+		taskV4.POST("/input", SubmitManualInput)
 		taskV4.GET("/workflow/:workflowName/taskId/:taskId/job/:jobName", GetWorkflowV4ArtifactFileContent)
 		taskV4.POST("/trigger", CreateWorkflowTaskV4ByBuildInTrigger)
+		taskV4.GET("/queue", ListWorkflowTaskQueue)
+		taskV4.GET("/queue/workflow/:workflowName/task/:taskID", GetWorkflowTaskQueuePosition)
+		taskV4.PUT("/queue/workflow/:workflowName/task/:taskID/priority", UpdateWorkflowTaskPriority)
 	}
 
 	// ---------------------------------------------------------------------------------------
@@ -285,6 +296,7 @@ func (*OpenAPIRouter) Inject(router *gin.RouterGroup) {
 		custom.GET("/task", OpenAPIGetWorkflowTaskV4)
 		custom.DELETE("/task", OpenAPICancelWorkflowTaskV4)
 		custom.POST("/task/approve", OpenAPIApproveStage)
+		custom.POST("/task/input", OpenAPISubmitManualInput)
 		custom.DELETE("", OpenAPIDeleteCustomWorkflowV4)
 		custom.GET("/:name/detail", OpenAPIGetCustomWorkflowV4)
 		custom.POST("/:name/task/:taskID", OpenAPIRetryCustomWorkflowTaskV4)
@@ -0,0 +1,161 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+// @Summary Get Workflow Code Setting
+// @Description Get the repo the workflows of the project are synced from
+// @Tags 	workflow
+// @Accept 	json
+// @Produce json
+// @Param 	projectName	query		string								true	"project name"
+// @Success 200 		{object} 	commonmodels.WorkflowCodeSetting
+// @Router /api/aslan/workflow/v4/code/setting [get]
+func GetWorkflowCodeSetting(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectKey := c.Query("projectName")
+	if projectKey == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName is required")
+		return
+	}
+
+	// authorization check
+	if !ctx.Resources.IsSystemAdmin {
+		if _, ok := ctx.Resources.ProjectAuthInfo[projectKey]; !ok {
+			ctx.UnAuthorized = true
+			return
+		}
+		if !ctx.Resources.ProjectAuthInfo[projectKey].IsProjectAdmin &&
+			!ctx.Resources.ProjectAuthInfo[projectKey].Workflow.View {
+			ctx.UnAuthorized = true
+			return
+		}
+	}
+
+	ctx.Resp, ctx.Err = workflow.GetWorkflowCodeSetting(projectKey, ctx.Logger)
+}
+
+// @Summary Update Workflow Code Setting
+// @Description Update the repo the workflows of the project are synced from
+// @Tags 	workflow
+// @Accept 	json
+// @Produce json
+// @Param 	projectName	query		string								true	"project name"
+// @Param 	body 		body 		commonmodels.WorkflowCodeSetting 	true 	"body"
+// @Success 200
+// @Router /api/aslan/workflow/v4/code/setting [put]
+func UpdateWorkflowCodeSetting(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectKey := c.Query("projectName")
+	if projectKey == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName is required")
+		return
+	}
+	args := new(commonmodels.WorkflowCodeSetting)
+	data := getBody(c)
+	if err := json.Unmarshal([]byte(data), args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc(err.Error())
+		return
+	}
+	internalhandler.InsertOperationLog(c, ctx.UserName, projectKey, "更新", "工作流代码库配置", projectKey, data, ctx.Logger)
+
+	// authorization check
+	if !ctx.Resources.IsSystemAdmin {
+		if _, ok := ctx.Resources.ProjectAuthInfo[projectKey]; !ok {
+			ctx.UnAuthorized = true
+			return
+		}
+		if !ctx.Resources.ProjectAuthInfo[projectKey].IsProjectAdmin {
+			ctx.UnAuthorized = true
+			return
+		}
+	}
+
+	ctx.Err = workflow.UpdateWorkflowCodeSetting(projectKey, ctx.UserName, args, ctx.Logger)
+}
+
+// @Summary Sync Workflow Code
+// @Description Sync the workflows of the project from its repo, the diffs are returned without any change if dryRun is set
+// @Tags 	workflow
+// @Accept 	json
+// @Produce json
+// @Param 	projectName	query		string								true	"project name"
+// @Param 	dryRun		query		bool								false	"dry run"
+// @Success 200 		{object} 	workflow.WorkflowCodeSyncResult
+// @Router /api/aslan/workflow/v4/code/sync [post]
+func SyncWorkflowCode(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	projectKey := c.Query("projectName")
+	if projectKey == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName is required")
+		return
+	}
+	dryRun := c.Query("dryRun") == "true"
+	if !dryRun {
+		internalhandler.InsertOperationLog(c, ctx.UserName, projectKey, "同步", "工作流代码库配置", projectKey, "", ctx.Logger)
+	}
+
+	// authorization check
+	if !ctx.Resources.IsSystemAdmin {
+		if _, ok := ctx.Resources.ProjectAuthInfo[projectKey]; !ok {
+			ctx.UnAuthorized = true
+			return
+		}
+		if !ctx.Resources.ProjectAuthInfo[projectKey].IsProjectAdmin &&
+			!(dryRun && ctx.Resources.ProjectAuthInfo[projectKey].Workflow.View) {
+			ctx.UnAuthorized = true
+			return
+		}
+	}
+
+	ctx.Resp, ctx.Err = workflow.SyncWorkflowCode(projectKey, ctx.UserName, dryRun, ctx.Logger)
+}
@@ -48,13 +48,23 @@ type listWorkflowTaskV4Resp struct {
 }
 
 type ApproveRequest struct {
-	StageName    string `json:"stage_name"`
+	StageName string `json:"stage_name"`
+	// JobName is set instead of StageName to approve an approval job
+	JobName      string `json:"job_name"`
 	WorkflowName string `json:"workflow_name"`
 	TaskID       int64  `json:"task_id"`
 	Approve      bool   `json:"approve"`
 	Comment      string `json:"comment"`
 }
 
+type ManualInputRequest struct {
+	JobName      string            `json:"job_name"`
+	WorkflowName string            `json:"workflow_name"`
+	TaskID       int64             `json:"task_id"`
+	Inputs       map[string]string `json:"inputs"`
+	Comment      string            `json:"comment"`
+}
+
 func CreateWorkflowTaskV4(c *gin.Context) {
 	ctx, err := internalhandler.NewContextWithAuthorization(c)
 	defer func() { internalhandler.JSONResponse(c, ctx) }()
@@ -519,9 +529,26 @@ func ApproveStage(c *gin.Context) {
 		return
 	}
 
+	if args.JobName != "" {
+		ctx.Err = workflow.ApproveJob(args.WorkflowName, args.JobName, ctx.UserName, ctx.UserID, args.Comment, args.TaskID, args.Approve, ctx.Logger)
+		return
+	}
 	ctx.Err = workflow.ApproveStage(args.WorkflowName, args.StageName, ctx.UserName, ctx.UserID, args.Comment, args.TaskID, args.Approve, ctx.Logger)
 }
 
+func SubmitManualInput(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+	args := &ManualInputRequest{}
+
+	if err := c.ShouldBindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc(err.Error())
+		return
+	}
+
+	ctx.Err = workflow.SubmitManualInput(args.WorkflowName, args.JobName, ctx.UserName, ctx.UserID, args.Comment, args.TaskID, args.Inputs, ctx.Logger)
+}
+
 func GetWorkflowV4ArtifactFileContent(c *gin.Context) {
 	ctx, err := internalhandler.NewContextWithAuthorization(c)
 	defer func() { internalhandler.JSONResponse(c, ctx) }()
@@ -314,7 +314,7 @@ func UpdateWorkflowV4(c *gin.Context) {
 		}
 	}
 
-	ctx.Err = workflow.UpdateWorkflowV4(c.Param("name"), ctx.UserName, args, ctx.Logger)
+	ctx.Err = workflow.UpdateWorkflowV4(c.Param("name"), ctx.UserName, c.Query("comment"), args, ctx.Logger)
 }
 
 func DeleteWorkflowV4(c *gin.Context) {
@@ -0,0 +1,203 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+type listWorkflowV4RevisionQuery struct {
+	PageSize int64 `json:"page_size"    form:"page_size,default=20"`
+	PageNum  int64 `json:"page_num"     form:"page_num,default=1"`
+}
+
+type listWorkflowV4RevisionResp struct {
+	Revisions []*commonmodels.WorkflowV4Revision `json:"revisions"`
+	Total     int64                              `json:"total"`
+}
+
+// @Summary List Workflow V4 Revisions
+// @Description List the revisions of the workflow from the latest
+// @Tags 	workflow
+// @Accept 	json
+// @Produce json
+// @Param 	name		path		string							true	"workflow name"
+// @Param 	page_num	query		int								false	"page num"
+// @Param 	page_size	query		int								false	"page size"
+// @Success 200 		{object} 	listWorkflowV4RevisionResp
+// @Router /api/aslan/workflow/v4/{name}/revisions [get]
+func ListWorkflowV4Revisions(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	args := &listWorkflowV4RevisionQuery{}
+	if err := c.ShouldBindQuery(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+	if !authorizeWorkflowV4Revision(ctx, c.Param("name"), false) {
+		return
+	}
+
+	revisions, total, err := workflow.ListWorkflowV4Revisions(c.Param("name"), args.PageNum, args.PageSize, ctx.Logger)
+	ctx.Resp, ctx.Err = listWorkflowV4RevisionResp{Revisions: revisions, Total: total}, err
+}
+
+// @Summary Get Workflow V4 Revision
+// @Description Get the definition of a revision of the workflow
+// @Tags 	workflow
+// @Accept 	json
+// @Produce json
+// @Param 	name		path		string							true	"workflow name"
+// @Param 	revision	path		int								true	"revision"
+// @Success 200 		{object} 	commonmodels.WorkflowV4Revision
+// @Router /api/aslan/workflow/v4/{name}/revisions/{revision} [get]
+func GetWorkflowV4Revision(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	revision, err := strconv.ParseInt(c.Param("revision"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid revision")
+		return
+	}
+	if !authorizeWorkflowV4Revision(ctx, c.Param("name"), false) {
+		return
+	}
+
+	ctx.Resp, ctx.Err = workflow.GetWorkflowV4Revision(c.Param("name"), revision, ctx.Logger)
+}
+
+// @Summary Diff Workflow V4 Revisions
+// @Description Diff the revision of the workflow against the base revision
+// @Tags 	workflow
+// @Accept 	json
+// @Produce json
+// @Param 	name		path		string							true	"workflow name"
+// @Param 	revision	path		int								true	"revision"
+// @Param 	base		query		int								true	"base revision"
+// @Success 200 		{object} 	workflow.WorkflowV4RevisionDiff
+// @Router /api/aslan/workflow/v4/{name}/revisions/{revision}/diff [get]
+func DiffWorkflowV4Revisions(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	revision, err := strconv.ParseInt(c.Param("revision"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid revision")
+		return
+	}
+	base, err := strconv.ParseInt(c.Query("base"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid base revision")
+		return
+	}
+	if !authorizeWorkflowV4Revision(ctx, c.Param("name"), false) {
+		return
+	}
+
+	ctx.Resp, ctx.Err = workflow.DiffWorkflowV4Revisions(c.Param("name"), base, revision, ctx.Logger)
+}
+
+// @Summary Restore Workflow V4 Revision
+// @Description Restore the workflow to the definition of the revision, it is saved as a new revision
+// @Tags 	workflow
+// @Accept 	json
+// @Produce json
+// @Param 	name		path		string							true	"workflow name"
+// @Param 	revision	path		int								true	"revision"
+// @Success 200
+// @Router /api/aslan/workflow/v4/{name}/revisions/{revision}/restore [post]
+func RestoreWorkflowV4Revision(c *gin.Context) {
+	ctx, err := internalhandler.NewContextWithAuthorization(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	if err != nil {
+		ctx.Err = fmt.Errorf("authorization Info Generation failed: err %s", err)
+		ctx.UnAuthorized = true
+		return
+	}
+
+	revision, err := strconv.ParseInt(c.Param("revision"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid revision")
+		return
+	}
+	if !authorizeWorkflowV4Revision(ctx, c.Param("name"), true) {
+		return
+	}
+
+	ctx.Err = workflow.RestoreWorkflowV4Revision(c.Param("name"), revision, ctx.UserName, ctx.Logger)
+}
+
+// authorizeWorkflowV4Revision checks the view permission, or the edit permission if edit is set, of the workflow.
+func authorizeWorkflowV4Revision(ctx *internalhandler.Context, workflowName string, edit bool) bool {
+	w, err := workflow.FindWorkflowV4Raw(workflowName, ctx.Logger)
+	if err != nil {
+		ctx.Err = err
+		return false
+	}
+	if ctx.Resources.IsSystemAdmin {
+		return true
+	}
+	if _, ok := ctx.Resources.ProjectAuthInfo[w.Project]; !ok {
+		ctx.UnAuthorized = true
+		return false
+	}
+	authInfo := ctx.Resources.ProjectAuthInfo[w.Project]
+	if authInfo.IsProjectAdmin || authInfo.Workflow.Edit || (!edit && authInfo.Workflow.View) {
+		return true
+	}
+	// check if the permission is given by collaboration mode
+	action := types.WorkflowActionView
+	if edit {
+		action = types.WorkflowActionEdit
+	}
+	permitted, err := internalhandler.GetCollaborationModePermission(ctx.UserID, w.Project, types.ResourceTypeWorkflow, w.Name, action)
+	if err != nil || !permitted {
+		ctx.UnAuthorized = true
+		return false
+	}
+	return true
+}
@@ -0,0 +1,463 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/hashicorp/go-multierror"
+	"github.com/xanzy/go-gitlab"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/scmnotify"
+	environmentservice "github.com/koderover/zadig/pkg/microservice/aslan/core/environment/service"
+	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow/job"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/shared/client/systemconfig"
+	"github.com/koderover/zadig/pkg/tool/gitee"
+	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/util"
+)
+
+type envPreviewAction string
+
+const (
+	envPreviewActionOpen   envPreviewAction = "open"
+	envPreviewActionUpdate envPreviewAction = "update"
+	envPreviewActionClose  envPreviewAction = "close"
+)
+
+// envPreviewEvent is the pull request event of any code host that the preview environments care about
+type envPreviewEvent struct {
+	action            envPreviewAction
+	pathWithNamespace string
+	targetBranch      string
+	prID              int
+	commitID          string
+	// address is the address of the code host the event is sent from, e.g. https://gitlab.example.com
+	address string
+	// changedFiles lists the files changed in the pull request with the given code host
+	changedFiles func(codehostID int) ([]string, error)
+}
+
+func TriggerEnvPreviewByGitlabEvent(event *gitlab.MergeEvent, baseURI, requestID string, log *zap.SugaredLogger) error {
+	ev := gitlabEnvPreviewEvent(event)
+	if ev == nil {
+		return nil
+	}
+	return processEnvPreviewEvent(ev, baseURI, requestID, log)
+}
+
+func TriggerEnvPreviewByGiteeEvent(event *gitee.PullRequestEvent, baseURI, requestID string, log *zap.SugaredLogger) error {
+	ev := giteeEnvPreviewEvent(event)
+	if ev == nil {
+		return nil
+	}
+	return processEnvPreviewEvent(ev, baseURI, requestID, log)
+}
+
+func TriggerEnvPreviewByGithubEvent(event *github.PullRequestEvent, baseURI, requestID string, log *zap.SugaredLogger) error {
+	ev := githubEnvPreviewEvent(event)
+	if ev == nil {
+		return nil
+	}
+	return processEnvPreviewEvent(ev, baseURI, requestID, log)
+}
+
+// gitlabEnvPreviewEvent converts the merge request event, nil is returned if the preview environments do not care about it
+func gitlabEnvPreviewEvent(event *gitlab.MergeEvent) *envPreviewEvent {
+	ev := &envPreviewEvent{
+		pathWithNamespace: event.ObjectAttributes.Target.PathWithNamespace,
+		targetBranch:      event.ObjectAttributes.TargetBranch,
+		prID:              event.ObjectAttributes.IID,
+		commitID:          event.ObjectAttributes.LastCommit.ID,
+		address:           getEnvPreviewAddress(event.ObjectAttributes.Target.WebURL),
+		changedFiles: func(codehostID int) ([]string, error) {
+			return findChangedFilesOfMergeRequest(event, codehostID)
+		},
+	}
+	switch event.ObjectAttributes.Action {
+	case "open", "reopen":
+		ev.action = envPreviewActionOpen
+	case "update":
+		// the merge request is updated without new commits, e.g. the title or the labels are changed
+		if event.ObjectAttributes.OldRev == "" {
+			return nil
+		}
+		ev.action = envPreviewActionUpdate
+	case "close", "merge":
+		ev.action = envPreviewActionClose
+	default:
+		return nil
+	}
+	return ev
+}
+
+// giteeEnvPreviewEvent converts the pull request event, nil is returned if the preview environments do not care about it
+func giteeEnvPreviewEvent(event *gitee.PullRequestEvent) *envPreviewEvent {
+	ev := &envPreviewEvent{
+		pathWithNamespace: event.PullRequest.Base.Repo.FullName,
+		targetBranch:      event.PullRequest.Base.Ref,
+		prID:              event.PullRequest.Number,
+		commitID:          event.PullRequest.Head.Sha,
+		address:           getEnvPreviewAddress(event.PullRequest.HTMLURL),
+		changedFiles: func(codehostID int) ([]string, error) {
+			return findChangedFilesOfPullRequestEvent(event, codehostID)
+		},
+	}
+	switch event.Action {
+	case "open":
+		ev.action = envPreviewActionOpen
+	case "update":
+		if event.ActionDesc != "source_branch_changed" {
+			return nil
+		}
+		ev.action = envPreviewActionUpdate
+	case "close", "merge":
+		ev.action = envPreviewActionClose
+	default:
+		return nil
+	}
+	return ev
+}
+
+// githubEnvPreviewEvent converts the pull request event, nil is returned if the preview environments do not care about it
+func githubEnvPreviewEvent(event *github.PullRequestEvent) *envPreviewEvent {
+	ev := &envPreviewEvent{
+		pathWithNamespace: event.GetRepo().GetFullName(),
+		targetBranch:      event.GetPullRequest().GetBase().GetRef(),
+		prID:              event.GetPullRequest().GetNumber(),
+		commitID:          event.GetPullRequest().GetHead().GetSHA(),
+		address:           getEnvPreviewAddress(event.GetRepo().GetHTMLURL()),
+		changedFiles: func(codehostID int) ([]string, error) {
+			return findChangedFilesOfPullRequest(event, codehostID)
+		},
+	}
+	switch event.GetAction() {
+	case "opened", "reopened":
+		ev.action = envPreviewActionOpen
+	case "synchronize":
+		ev.action = envPreviewActionUpdate
+	case "closed":
+		ev.action = envPreviewActionClose
+	default:
+		return nil
+	}
+	return ev
+}
+
+func getEnvPreviewAddress(url string) string {
+	address, err := util.GetAddress(url)
+	if err != nil {
+		return ""
+	}
+	return address
+}
+
+// fromCodehost checks whether the event is sent from the code host
+func (ev *envPreviewEvent) fromCodehost(codehostID int) bool {
+	detail, err := systemconfig.New().GetCodeHost(codehostID)
+	if err != nil {
+		return false
+	}
+	return isSameCodehostAddress(detail.Address, ev.address)
+}
+
+func isSameCodehostAddress(codehostAddress, eventAddress string) bool {
+	if eventAddress == "" {
+		return false
+	}
+	if address, err := util.GetAddress(codehostAddress); err == nil {
+		codehostAddress = address
+	}
+	return strings.EqualFold(strings.TrimSuffix(codehostAddress, "/"), eventAddress)
+}
+
+func processEnvPreviewEvent(ev *envPreviewEvent, baseURI, requestID string, log *zap.SugaredLogger) error {
+	if ev.action == envPreviewActionClose {
+		return deleteEnvPreviews(ev, baseURI, requestID, log)
+	}
+
+	policies, err := commonrepo.NewEnvPreviewPolicyColl().ListEnabled()
+	if err != nil {
+		return fmt.Errorf("failed to list preview environment policies, err: %s", err)
+	}
+
+	// the changed files are listed once for each code host
+	changedFiles := make(map[int][]string)
+	mErr := &multierror.Error{}
+	for _, policy := range policies {
+		workflow, err := commonrepo.NewWorkflowV4Coll().Find(policy.WorkflowName)
+		if err != nil || workflow.Project != policy.ProductName {
+			log.Errorf("failed to find workflow %s of the preview environment policy in project %s, err: %v", policy.WorkflowName, policy.ProductName, err)
+			continue
+		}
+		repo, builtServices := findEnvPreviewRepo(workflow, ev.pathWithNamespace, ev.fromCodehost)
+		if repo == nil {
+			continue
+		}
+
+		files, ok := changedFiles[repo.CodehostID]
+		if !ok {
+			files, err = ev.changedFiles(repo.CodehostID)
+			if err != nil {
+				log.Errorf("failed to list the files changed in pull request %s#%d, err: %s", ev.pathWithNamespace, ev.prID, err)
+				continue
+			}
+			changedFiles[repo.CodehostID] = files
+		}
+		services := matchEnvPreviewServices(policy, builtServices, files)
+		if len(services) == 0 {
+			log.Infof("none of the services of the preview environment policy in project %s is changed in pull request %s#%d", policy.ProductName, ev.pathWithNamespace, ev.prID)
+			continue
+		}
+		log.Infof("pull request %s#%d matches the preview environment policy in project %s, changed services: %v", ev.pathWithNamespace, ev.prID, policy.ProductName, services)
+
+		preview, err := environmentservice.EnsureEnvPreview(policy, &commonmodels.EnvPreview{
+			CodehostID:    repo.CodehostID,
+			Source:        repo.Source,
+			RepoOwner:     repo.RepoOwner,
+			RepoNamespace: repo.GetRepoNamespace(),
+			RepoName:      repo.RepoName,
+			PrID:          ev.prID,
+			CommitID:      ev.commitID,
+			Services:      services,
+		}, requestID, log)
+		if err != nil {
+			mErr = multierror.Append(mErr, err)
+			continue
+		}
+
+		mainRepo := &commonmodels.MainHookRepo{
+			Source:        repo.Source,
+			RepoOwner:     repo.RepoOwner,
+			RepoNamespace: repo.GetRepoNamespace(),
+			RepoName:      repo.RepoName,
+			CodehostID:    repo.CodehostID,
+			Revision:      ev.commitID,
+		}
+		notification, err := scmnotify.NewService().SendEnvPreviewComment(mainRepo, ev.prID, baseURI, preview.NotificationID, environmentservice.BuildEnvPreviewInfo(policy, preview, false), log)
+		if err != nil {
+			log.Errorf("failed to comment the preview environment %s/%s, err: %s", preview.ProductName, preview.EnvName, err)
+		} else if preview.NotificationID == "" {
+			preview.NotificationID = notification.ID.Hex()
+			if err := commonrepo.NewEnvPreviewColl().Update(preview); err != nil {
+				log.Errorf("failed to update the preview environment %s/%s, err: %s", preview.ProductName, preview.EnvName, err)
+			}
+		}
+
+		eventRepo := &types.Repository{
+			CodehostID:    repo.CodehostID,
+			RepoName:      repo.RepoName,
+			RepoOwner:     repo.RepoOwner,
+			RepoNamespace: repo.GetRepoNamespace(),
+			Branch:        ev.targetBranch,
+			PR:            ev.prID,
+			Source:        repo.Source,
+		}
+		if err := createEnvPreviewWorkflowTask(workflow, eventRepo, preview, ev, log); err != nil {
+			mErr = multierror.Append(mErr, err)
+		}
+	}
+	return mErr.ErrorOrNil()
+}
+
+func deleteEnvPreviews(ev *envPreviewEvent, baseURI, requestID string, log *zap.SugaredLogger) error {
+	idx := strings.LastIndex(ev.pathWithNamespace, "/")
+	if idx < 0 {
+		return nil
+	}
+	opt := &commonrepo.EnvPreviewFindOption{
+		RepoNamespace: ev.pathWithNamespace[:idx],
+		RepoName:      ev.pathWithNamespace[idx+1:],
+		PrID:          ev.prID,
+	}
+	candidates, err := commonrepo.NewEnvPreviewColl().List(opt)
+	if err != nil {
+		return fmt.Errorf("failed to list the preview environments of pull request %s#%d, err: %s", ev.pathWithNamespace, ev.prID, err)
+	}
+	// the same repository may be on different code hosts, only the preview environments of the code host the event is
+	// sent from are deleted
+	codehostIDs := sets.NewInt()
+	for _, candidate := range candidates {
+		if !codehostIDs.Has(candidate.CodehostID) && ev.fromCodehost(candidate.CodehostID) {
+			codehostIDs.Insert(candidate.CodehostID)
+		}
+	}
+
+	mErr := &multierror.Error{}
+	for _, codehostID := range codehostIDs.List() {
+		previews, err := environmentservice.DeleteEnvPreviews(&commonrepo.EnvPreviewFindOption{
+			CodehostID:    codehostID,
+			RepoNamespace: opt.RepoNamespace,
+			RepoName:      opt.RepoName,
+			PrID:          opt.PrID,
+		}, requestID, log)
+		if err != nil {
+			mErr = multierror.Append(mErr, fmt.Errorf("failed to delete the preview environments of pull request %s#%d, err: %s", ev.pathWithNamespace, ev.prID, err))
+			continue
+		}
+
+		for _, preview := range previews {
+			log.Infof("preview environment %s/%s is deleted since pull request %s#%d is closed", preview.ProductName, preview.EnvName, ev.pathWithNamespace, ev.prID)
+			if preview.NotificationID == "" {
+				continue
+			}
+			info := environmentservice.BuildEnvPreviewInfo(&commonmodels.EnvPreviewPolicy{}, preview, true)
+			if _, err := scmnotify.NewService().SendEnvPreviewComment(nil, ev.prID, baseURI, preview.NotificationID, info, log); err != nil {
+				log.Errorf("failed to comment the preview environment %s/%s, err: %s", preview.ProductName, preview.EnvName, err)
+			}
+		}
+	}
+	return mErr.ErrorOrNil()
+}
+
+// findEnvPreviewRepo returns the repository of the pull request in the build jobs of the workflow, and the services
+// built from it
+func findEnvPreviewRepo(workflow *commonmodels.WorkflowV4, pathWithNamespace string, fromCodehost func(codehostID int) bool) (*types.Repository, []string) {
+	var matched *types.Repository
+	services := sets.NewString()
+	for _, stage := range workflow.Stages {
+		for _, j := range stage.Jobs {
+			if j.JobType != config.JobZadigBuild {
+				continue
+			}
+			spec := &commonmodels.ZadigBuildJobSpec{}
+			if err := commonmodels.IToi(j.Spec, spec); err != nil {
+				continue
+			}
+			for _, build := range spec.ServiceAndBuilds {
+				for _, repo := range build.Repos {
+					if repo.GetRepoNamespace()+"/"+repo.RepoName != pathWithNamespace {
+						continue
+					}
+					if matched == nil {
+						if !fromCodehost(repo.CodehostID) {
+							continue
+						}
+						matched = repo
+					} else if repo.CodehostID != matched.CodehostID {
+						continue
+					}
+					services.Insert(build.ServiceName)
+				}
+			}
+		}
+	}
+	return matched, services.List()
+}
+
+// matchEnvPreviewServices returns the services whose folders in the policy match the files changed in the pull request,
+// the services without folders are changed if any file is changed
+func matchEnvPreviewServices(policy *commonmodels.EnvPreviewPolicy, services, changedFiles []string) []string {
+	folders := make(map[string][]string)
+	for _, serviceFolders := range policy.ServiceFolders {
+		folders[serviceFolders.ServiceName] = serviceFolders.Folders
+	}
+
+	changed := make([]string, 0)
+	for _, service := range services {
+		serviceFolders, ok := folders[service]
+		if !ok {
+			serviceFolders = []string{"/"}
+		}
+		for _, file := range changedFiles {
+			if MatchFolders(serviceFolders).ContainsFile(file) {
+				changed = append(changed, service)
+				break
+			}
+		}
+	}
+	return changed
+}
+
+// createEnvPreviewWorkflowTask builds the services of the preview environment from the pull request and deploys them to it
+func createEnvPreviewWorkflowTask(workflow *commonmodels.WorkflowV4, eventRepo *types.Repository, preview *commonmodels.EnvPreview, ev *envPreviewEvent, log *zap.SugaredLogger) error {
+	services := sets.NewString(preview.Services...)
+	for _, stage := range workflow.Stages {
+		for _, j := range stage.Jobs {
+			switch j.JobType {
+			case config.JobZadigBuild:
+				spec := &commonmodels.ZadigBuildJobSpec{}
+				if err := commonmodels.IToi(j.Spec, spec); err != nil {
+					return err
+				}
+				builds := make([]*commonmodels.ServiceAndBuild, 0)
+				for _, build := range spec.ServiceAndBuilds {
+					if services.Has(build.ServiceName) {
+						builds = append(builds, build)
+					}
+				}
+				spec.ServiceAndBuilds = builds
+				j.Spec = spec
+			case config.JobZadigDeploy:
+				spec := &commonmodels.ZadigDeployJobSpec{}
+				if err := commonmodels.IToi(j.Spec, spec); err != nil {
+					return err
+				}
+				spec.Env = preview.EnvName
+				spec.Production = false
+				serviceAndImages := make([]*commonmodels.ServiceAndImage, 0)
+				for _, svc := range spec.ServiceAndImages {
+					if services.Has(svc.ServiceName) {
+						serviceAndImages = append(serviceAndImages, svc)
+					}
+				}
+				spec.ServiceAndImages = serviceAndImages
+				deployServices := make([]*commonmodels.DeployService, 0)
+				for _, svc := range spec.Services {
+					if services.Has(svc.ServiceName) {
+						deployServices = append(deployServices, svc)
+					}
+				}
+				spec.Services = deployServices
+				j.Spec = spec
+			}
+		}
+	}
+
+	if err := job.MergeWebhookRepo(workflow, eventRepo); err != nil {
+		return fmt.Errorf("merge webhook repo info to workflowargs error: %v", err)
+	}
+	workflow.NotificationID = preview.NotificationID
+	workflow.HookPayload = &commonmodels.HookPayload{
+		Owner:          eventRepo.RepoOwner,
+		Repo:           eventRepo.RepoName,
+		Branch:         eventRepo.Branch,
+		IsPr:           true,
+		MergeRequestID: strconv.Itoa(ev.prID),
+		CommitID:       ev.commitID,
+		CodehostID:     eventRepo.CodehostID,
+		EventType:      EventTypePR,
+	}
+	resp, err := workflowservice.CreateWorkflowTaskV4(&workflowservice.CreateWorkflowTaskV4Args{
+		Name: setting.WebhookTaskCreator,
+	}, workflow, log)
+	if err != nil {
+		return fmt.Errorf("failed to create workflow task for the preview environment %s/%s, err: %v", preview.ProductName, preview.EnvName, err)
+	}
+	log.Infof("succeed to create task %v for the preview environment %s/%s", resp, preview.ProductName, preview.EnvName)
+	return nil
+}
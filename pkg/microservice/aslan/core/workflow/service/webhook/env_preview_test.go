@@ -0,0 +1,177 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+
+	"github.com/google/go-github/v35/github"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/xanzy/go-gitlab"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+var _ = Describe("Testing env preview", func() {
+
+	Describe("test matchEnvPreviewServices", func() {
+		policy := &commonmodels.EnvPreviewPolicy{
+			ServiceFolders: []*commonmodels.EnvPreviewServiceFolders{
+				{ServiceName: "web", Folders: []string{"web/", "common/", "!.md"}},
+				{ServiceName: "api", Folders: []string{"api/"}},
+			},
+		}
+		services := []string{"api", "web", "worker"}
+
+		It("should match the services by their folders", func() {
+			Expect(matchEnvPreviewServices(policy, services[:2], []string{"web/main.go"})).To(Equal([]string{"web"}))
+			Expect(matchEnvPreviewServices(policy, services[:2], []string{"api/main.go", "common/util.go"})).To(Equal([]string{"api", "web"}))
+		})
+
+		It("should exclude the files starting with !", func() {
+			Expect(matchEnvPreviewServices(policy, services[:2], []string{"web/README.md"})).To(BeEmpty())
+		})
+
+		It("should match the services without folders by any change", func() {
+			Expect(matchEnvPreviewServices(policy, services, []string{"docs/index.html"})).To(Equal([]string{"worker"}))
+		})
+
+		It("should match nothing without changes", func() {
+			Expect(matchEnvPreviewServices(policy, services, nil)).To(BeEmpty())
+		})
+	})
+
+	Describe("test findEnvPreviewRepo", func() {
+		workflow := &commonmodels.WorkflowV4{
+			Stages: []*commonmodels.WorkflowStage{
+				{
+					Jobs: []*commonmodels.Job{
+						{
+							JobType: config.JobZadigBuild,
+							Spec: &commonmodels.ZadigBuildJobSpec{
+								ServiceAndBuilds: []*commonmodels.ServiceAndBuild{
+									{ServiceName: "web", Repos: []*types.Repository{{CodehostID: 1, RepoOwner: "team", RepoName: "app"}}},
+									{ServiceName: "api", Repos: []*types.Repository{{CodehostID: 2, RepoOwner: "team", RepoName: "app"}}},
+									{ServiceName: "worker", Repos: []*types.Repository{{CodehostID: 2, RepoOwner: "team", RepoName: "worker"}}},
+								},
+							},
+						},
+						{
+							JobType: config.JobZadigDeploy,
+							Spec:    &commonmodels.ZadigDeployJobSpec{},
+						},
+					},
+				},
+			},
+		}
+
+		It("should only match the repository on the code host of the event", func() {
+			repo, services := findEnvPreviewRepo(workflow, "team/app", func(codehostID int) bool { return codehostID == 2 })
+			Expect(repo).NotTo(BeNil())
+			Expect(repo.CodehostID).To(Equal(2))
+			Expect(services).To(Equal([]string{"api"}))
+		})
+
+		It("should return nil if the repository is not built", func() {
+			repo, services := findEnvPreviewRepo(workflow, "team/other", func(int) bool { return true })
+			Expect(repo).To(BeNil())
+			Expect(services).To(BeEmpty())
+
+			repo, _ = findEnvPreviewRepo(workflow, "team/app", func(int) bool { return false })
+			Expect(repo).To(BeNil())
+		})
+	})
+
+	Describe("test isSameCodehostAddress", func() {
+		It("should compare the scheme and the host", func() {
+			Expect(isSameCodehostAddress("https://gitlab.example.com/", "https://gitlab.example.com")).To(BeTrue())
+			Expect(isSameCodehostAddress("https://GitLab.example.com", "https://gitlab.example.com")).To(BeTrue())
+			Expect(isSameCodehostAddress("https://gitlab.example.com", "https://gitlab.other.com")).To(BeFalse())
+			Expect(isSameCodehostAddress("https://gitlab.example.com", "")).To(BeFalse())
+		})
+	})
+
+	Describe("test githubEnvPreviewEvent", func() {
+		newEvent := func(action string) *github.PullRequestEvent {
+			return &github.PullRequestEvent{
+				Action: github.String(action),
+				Repo: &github.Repository{
+					FullName: github.String("team/app"),
+					HTMLURL:  github.String("https://github.com/team/app"),
+				},
+				PullRequest: &github.PullRequest{
+					Number: github.Int(7),
+					Base:   &github.PullRequestBranch{Ref: github.String("main")},
+					Head:   &github.PullRequestBranch{SHA: github.String("abc")},
+				},
+			}
+		}
+
+		It("should convert the pull request event", func() {
+			ev := githubEnvPreviewEvent(newEvent("opened"))
+			Expect(ev).NotTo(BeNil())
+			Expect(ev.action).To(Equal(envPreviewActionOpen))
+			Expect(ev.pathWithNamespace).To(Equal("team/app"))
+			Expect(ev.targetBranch).To(Equal("main"))
+			Expect(ev.prID).To(Equal(7))
+			Expect(ev.commitID).To(Equal("abc"))
+			Expect(ev.address).To(Equal("https://github.com"))
+		})
+
+		It("should map the actions", func() {
+			Expect(githubEnvPreviewEvent(newEvent("reopened")).action).To(Equal(envPreviewActionOpen))
+			Expect(githubEnvPreviewEvent(newEvent("synchronize")).action).To(Equal(envPreviewActionUpdate))
+			Expect(githubEnvPreviewEvent(newEvent("closed")).action).To(Equal(envPreviewActionClose))
+			Expect(githubEnvPreviewEvent(newEvent("labeled"))).To(BeNil())
+		})
+	})
+
+	Describe("test gitlabEnvPreviewEvent", func() {
+		newEvent := func(action, oldRev string) *gitlab.MergeEvent {
+			event := &gitlab.MergeEvent{}
+			Expect(json.Unmarshal([]byte(`{
+				"object_attributes": {
+					"iid": 3,
+					"target_branch": "main",
+					"action": "`+action+`",
+					"oldrev": "`+oldRev+`",
+					"last_commit": {"id": "abc"},
+					"target": {"path_with_namespace": "team/app", "web_url": "https://gitlab.example.com/team/app"}
+				}
+			}`), event)).To(Succeed())
+			return event
+		}
+
+		It("should convert the merge request event", func() {
+			ev := gitlabEnvPreviewEvent(newEvent("open", ""))
+			Expect(ev).NotTo(BeNil())
+			Expect(ev.action).To(Equal(envPreviewActionOpen))
+			Expect(ev.pathWithNamespace).To(Equal("team/app"))
+			Expect(ev.prID).To(Equal(3))
+			Expect(ev.address).To(Equal("https://gitlab.example.com"))
+		})
+
+		It("should skip the updates without new commits", func() {
+			Expect(gitlabEnvPreviewEvent(newEvent("update", ""))).To(BeNil())
+			Expect(gitlabEnvPreviewEvent(newEvent("update", "def")).action).To(Equal(envPreviewActionUpdate))
+			Expect(gitlabEnvPreviewEvent(newEvent("merge", "")).action).To(Equal(envPreviewActionClose))
+		})
+	})
+})
@@ -43,6 +43,7 @@ import (
 	commonutil "github.com/koderover/zadig/pkg/microservice/aslan/core/common/util"
 	environmentservice "github.com/koderover/zadig/pkg/microservice/aslan/core/environment/service"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/service/service"
+	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/shared/client/systemconfig"
 	e "github.com/koderover/zadig/pkg/tool/errors"
@@ -72,6 +73,13 @@ func ProcessGerritHook(payload []byte, req *http.Request, requestID string, log
 		if err != nil {
 			log.Errorf("updateServiceTemplateByGerritEvent err : %v", err)
 		}
+		mergedEvent := new(changeMergedEvent)
+		if err := json.Unmarshal(payload, mergedEvent); err == nil {
+			// gerrit projects are identified by the project name only
+			if err := workflowservice.SyncWorkflowCodeByPush("", mergedEvent.Change.Project, mergedEvent.Change.Branch, log); err != nil {
+				log.Errorf("failed to sync workflow code of %s:%s, error: %v", mergedEvent.Change.Project, mergedEvent.Change.Branch, err)
+			}
+		}
 	}
 	var wg sync.WaitGroup
 	var errorList = &multierror.Error{}
@@ -84,6 +84,7 @@ func ProcessGiteeHook(payload []byte, req *http.Request, requestID string, log *
 		if err := updateServiceTemplateByGiteeEvent(req.RequestURI, log); err != nil {
 			errorList = multierror.Append(errorList, err)
 		}
+		syncWorkflowCodeByPush(event.Repository.FullName, getBranchFromRef(event.Ref), log)
 		// build webhook
 		wg.Add(1)
 		go func() {
@@ -111,6 +112,11 @@ func ProcessGiteeHook(payload []byte, req *http.Request, requestID string, log *
 			}
 		}()
 	case *gitee.PullRequestEvent:
+		// the preview environments are deleted when the pull request is closed or merged, so it goes before the filter
+		if err := TriggerEnvPreviewByGiteeEvent(event, baseURI, requestID, log); err != nil {
+			errorList = multierror.Append(errorList, err)
+		}
+
 		if event.Action != "open" && event.Action != "update" {
 			return fmt.Errorf("action %s is skipped", event.Action)
 		}
@@ -537,6 +537,11 @@ func ProcessGithubWebHookForWorkflowV4(payload []byte, req *http.Request, reques
 
 	switch et := event.(type) {
 	case *github.PullRequestEvent:
+		// the preview environments are deleted when the pull request is closed, so it goes before the filter
+		if err := TriggerEnvPreviewByGithubEvent(et, baseURI, requestID, log); err != nil {
+			log.Errorf("TriggerEnvPreviewByGithubEvent error: %v", err)
+		}
+
 		if *et.Action != "opened" && *et.Action != "synchronize" {
 			return nil
 		}
@@ -546,6 +551,7 @@ func ProcessGithubWebHookForWorkflowV4(payload []byte, req *http.Request, reques
 			return e.ErrGithubWebHook.AddErr(err)
 		}
 	case *github.PushEvent:
+		syncWorkflowCodeByPush(et.GetRepo().GetFullName(), pushEventBranch(et), log)
 		err = TriggerWorkflowV4ByGithubEvent(et, baseURI, deliveryID, requestID, log)
 		if err != nil {
 			log.Infof("pushEventToPipelineTasks error: %v", err)
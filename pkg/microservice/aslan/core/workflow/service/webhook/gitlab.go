@@ -93,6 +93,7 @@ func ProcessGitlabHook(payload []byte, req *http.Request, requestID string, log
 		if err = updateServiceTemplateByPushEvent(changeFiles, pathWithNamespace, log); err != nil {
 			errorList = multierror.Append(errorList, err)
 		}
+		syncWorkflowCodeByPush(pathWithNamespace, getBranchFromRef(pushEvent.Ref), log)
 	case *gitlab.MergeEvent:
 		mergeEvent = event
 	case *gitlab.TagEvent:
@@ -101,6 +102,13 @@ func ProcessGitlabHook(payload []byte, req *http.Request, requestID string, log
 
 	//触发工作流webhook和测试管理webhook
 	var wg sync.WaitGroup
+	// the webhooks below are triggered concurrently, so the errors are appended with the lock held
+	var errorLock sync.Mutex
+	appendError := func(err error) {
+		errorLock.Lock()
+		defer errorLock.Unlock()
+		errorList = multierror.Append(errorList, err)
+	}
 
 	if pushEvent != nil {
 		//add webhook user
@@ -119,8 +127,8 @@ func ProcessGitlabHook(payload []byte, req *http.Request, requestID string, log
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err = TriggerWorkflowByGitlabEvent(pushEvent, baseURI, requestID, log); err != nil {
-				errorList = multierror.Append(errorList, err)
+			if err := TriggerWorkflowByGitlabEvent(pushEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
 			}
 		}()
 
@@ -128,8 +136,8 @@ func ProcessGitlabHook(payload []byte, req *http.Request, requestID string, log
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err = TriggerPipelineByGitlabEvent(pushEvent, baseURI, requestID, log); err != nil {
-				errorList = multierror.Append(errorList, err)
+			if err := TriggerPipelineByGitlabEvent(pushEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
 			}
 		}()
 
@@ -137,24 +145,24 @@ func ProcessGitlabHook(payload []byte, req *http.Request, requestID string, log
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err = TriggerTestByGitlabEvent(pushEvent, baseURI, requestID, log); err != nil {
-				errorList = multierror.Append(errorList, err)
+			if err := TriggerTestByGitlabEvent(pushEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
 			}
 		}()
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err = TriggerScanningByGitlabEvent(pushEvent, baseURI, requestID, log); err != nil {
-				errorList = multierror.Append(errorList, err)
+			if err := TriggerScanningByGitlabEvent(pushEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
 			}
 		}()
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err = TriggerWorkflowV4ByGitlabEvent(pushEvent, baseURI, requestID, log); err != nil {
-				errorList = multierror.Append(errorList, err)
+			if err := TriggerWorkflowV4ByGitlabEvent(pushEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
 			}
 		}()
 	}
@@ -164,8 +172,8 @@ func ProcessGitlabHook(payload []byte, req *http.Request, requestID string, log
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err = TriggerWorkflowByGitlabEvent(mergeEvent, baseURI, requestID, log); err != nil {
-				errorList = multierror.Append(errorList, err)
+			if err := TriggerWorkflowByGitlabEvent(mergeEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
 			}
 		}()
 
@@ -173,8 +181,8 @@ func ProcessGitlabHook(payload []byte, req *http.Request, requestID string, log
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err = TriggerPipelineByGitlabEvent(mergeEvent, baseURI, requestID, log); err != nil {
-				errorList = multierror.Append(errorList, err)
+			if err := TriggerPipelineByGitlabEvent(mergeEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
 			}
 		}()
 
@@ -182,24 +190,33 @@ func ProcessGitlabHook(payload []byte, req *http.Request, requestID string, log
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err = TriggerTestByGitlabEvent(mergeEvent, baseURI, requestID, log); err != nil {
-				errorList = multierror.Append(errorList, err)
+			if err := TriggerTestByGitlabEvent(mergeEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := TriggerScanningByGitlabEvent(mergeEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
 			}
 		}()
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err = TriggerScanningByGitlabEvent(mergeEvent, baseURI, requestID, log); err != nil {
-				errorList = multierror.Append(errorList, err)
+			if err := TriggerWorkflowV4ByGitlabEvent(mergeEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
 			}
 		}()
 
+		// preview environment webhook
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err = TriggerWorkflowV4ByGitlabEvent(mergeEvent, baseURI, requestID, log); err != nil {
-				errorList = multierror.Append(errorList, err)
+			if err := TriggerEnvPreviewByGitlabEvent(mergeEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
 			}
 		}()
 	}
@@ -209,8 +226,8 @@ func ProcessGitlabHook(payload []byte, req *http.Request, requestID string, log
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err = TriggerWorkflowByGitlabEvent(tagEvent, baseURI, requestID, log); err != nil {
-				errorList = multierror.Append(errorList, err)
+			if err := TriggerWorkflowByGitlabEvent(tagEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
 			}
 		}()
 
@@ -218,24 +235,24 @@ func ProcessGitlabHook(payload []byte, req *http.Request, requestID string, log
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err = TriggerTestByGitlabEvent(tagEvent, baseURI, requestID, log); err != nil {
-				errorList = multierror.Append(errorList, err)
+			if err := TriggerTestByGitlabEvent(tagEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
 			}
 		}()
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err = TriggerScanningByGitlabEvent(tagEvent, baseURI, requestID, log); err != nil {
-				errorList = multierror.Append(errorList, err)
+			if err := TriggerScanningByGitlabEvent(tagEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
 			}
 		}()
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err = TriggerWorkflowV4ByGitlabEvent(tagEvent, baseURI, requestID, log); err != nil {
-				errorList = multierror.Append(errorList, err)
+			if err := TriggerWorkflowV4ByGitlabEvent(tagEvent, baseURI, requestID, log); err != nil {
+				appendError(err)
 			}
 		}()
 	}
@@ -0,0 +1,37 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+
+	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
+)
+
+// syncWorkflowCodeByPush syncs the workflows defined in the pushed branch before the workflows are triggered,
+// the sync errors are recorded in the workflow code setting and never fail the webhook.
+func syncWorkflowCodeByPush(fullName, branch string, log *zap.SugaredLogger) {
+	index := strings.LastIndex(fullName, "/")
+	if index < 0 {
+		return
+	}
+	if err := workflowservice.SyncWorkflowCodeByPush(fullName[:index], fullName[index+1:], branch, log); err != nil {
+		log.Errorf("failed to sync workflow code of %s:%s, error: %v", fullName, branch, err)
+	}
+}
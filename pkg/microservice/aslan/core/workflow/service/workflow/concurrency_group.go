@@ -0,0 +1,145 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller"
+)
+
+var deployJobTypes = map[config.JobType]bool{
+	config.JobDeploy:               true,
+	config.JobCustomDeploy:         true,
+	config.JobZadigDeploy:          true,
+	config.JobZadigHelmDeploy:      true,
+	config.JobZadigHelmChartDeploy: true,
+	config.JobK8sBlueGreenDeploy:   true,
+	config.JobK8sBlueGreenRelease:  true,
+	config.JobK8sCanaryDeploy:      true,
+	config.JobK8sCanaryRelease:     true,
+	config.JobK8sGrayRelease:       true,
+	config.JobK8sGrayRollback:      true,
+	config.JobIstioRelease:         true,
+	config.JobIstioRollback:        true,
+	config.JobMseGrayRelease:       true,
+	config.JobMseGrayOffline:       true,
+	config.JobOfflineService:       true,
+}
+
+func lintConcurrencyGroup(group *commonmodels.ConcurrencyGroup) error {
+	if group == nil {
+		return nil
+	}
+	if strings.TrimSpace(group.Key) == "" {
+		return fmt.Errorf("concurrency group key should not be empty")
+	}
+	switch group.Policy {
+	case config.ConcurrencyGroupCancel, config.ConcurrencyGroupReplaceQueued:
+	default:
+		return fmt.Errorf("invalid concurrency group policy %s", group.Policy)
+	}
+	return nil
+}
+
+// renderConcurrencyGroupKey renders the hook variables of the group key, the workflow variables have been rendered
+// together with the whole workflow. Tasks not triggered by a git hook get empty hook variables.
+func renderConcurrencyGroupKey(workflow *commonmodels.WorkflowV4) string {
+	if workflow.ConcurrencyGroup == nil {
+		return ""
+	}
+	branch, pr := "", ""
+	if payload := workflow.HookPayload; payload != nil {
+		branch = payload.Branch
+		if branch == "" {
+			branch = strings.TrimPrefix(payload.Ref, "refs/heads/")
+		}
+		if payload.IsPr {
+			pr = payload.MergeRequestID
+		}
+	}
+	return strings.NewReplacer(
+		"{{.workflow.hook.branch}}", branch,
+		"{{.workflow.hook.pr}}", pr,
+	).Replace(workflow.ConcurrencyGroup.Key)
+}
+
+// hasActiveDeployJob tells whether a deploy job of the task has started, a deploy job preparing its resources
+// or waiting for its approval is about to change the environment as well.
+func hasActiveDeployJob(task *commonmodels.WorkflowTask) bool {
+	for _, stage := range task.Stages {
+		for _, job := range stage.Jobs {
+			if !deployJobTypes[config.JobType(job.JobType)] {
+				continue
+			}
+			switch job.Status {
+			case config.StatusPrepare, config.StatusRunning, config.StatusWaitingApprove:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// supersededTasks returns the tasks superseded by the new task among the unfinished tasks of the workflow.
+func supersededTasks(newTask *commonmodels.WorkflowTask, group *commonmodels.ConcurrencyGroup, tasks []*commonmodels.WorkflowTask, logger *zap.SugaredLogger) []*commonmodels.WorkflowTask {
+	resp := make([]*commonmodels.WorkflowTask, 0)
+	for _, task := range tasks {
+		if task.TaskID >= newTask.TaskID || task.ConcurrencyGroupKey != newTask.ConcurrencyGroupKey {
+			continue
+		}
+		if group.Policy == config.ConcurrencyGroupReplaceQueued && task.Status != config.StatusWaiting {
+			continue
+		}
+		if group.ProtectDeploy && hasActiveDeployJob(task) {
+			logger.Infof("task %s-%d of concurrency group %s is deploying, it is not cancelled by task %d",
+				task.WorkflowName, task.TaskID, newTask.ConcurrencyGroupKey, newTask.TaskID)
+			continue
+		}
+		resp = append(resp, task)
+	}
+	return resp
+}
+
+// supersedeWorkflowTasks cancels the older unfinished tasks in the same concurrency group as the new task.
+func supersedeWorkflowTasks(newTask *commonmodels.WorkflowTask, group *commonmodels.ConcurrencyGroup, logger *zap.SugaredLogger) {
+	if group == nil || newTask.ConcurrencyGroupKey == "" {
+		return
+	}
+	tasks, err := commonrepo.NewworkflowTaskv4Coll().FindTodoTasksByWorkflowName(newTask.WorkflowName)
+	if err != nil {
+		logger.Errorf("find unfinished tasks of workflow %s error: %v", newTask.WorkflowName, err)
+		return
+	}
+
+	for _, task := range supersededTasks(newTask, group, tasks, logger) {
+		if err := workflowcontroller.CancelWorkflowTask(newTask.TaskCreator, task.WorkflowName, task.TaskID, logger); err != nil {
+			logger.Errorf("cancel superseded task %s-%d error: %v", task.WorkflowName, task.TaskID, err)
+			continue
+		}
+		reason := fmt.Sprintf("superseded by task #%d in concurrency group %s", newTask.TaskID, newTask.ConcurrencyGroupKey)
+		if err := commonrepo.NewworkflowTaskv4Coll().UpdateSupersededBy(task.WorkflowName, task.TaskID, newTask.TaskID, reason); err != nil {
+			logger.Errorf("update superseded task %s-%d error: %v", task.WorkflowName, task.TaskID, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+var _ = Describe("Testing workflow concurrency group", func() {
+
+	Context("renderConcurrencyGroupKey", func() {
+		groupWorkflow := func(key string, payload *commonmodels.HookPayload) *commonmodels.WorkflowV4 {
+			return &commonmodels.WorkflowV4{
+				ConcurrencyGroup: &commonmodels.ConcurrencyGroup{Key: key, Policy: config.ConcurrencyGroupCancel},
+				HookPayload:      payload,
+			}
+		}
+
+		It("should return empty key without concurrency group", func() {
+			Expect(renderConcurrencyGroupKey(&commonmodels.WorkflowV4{})).To(BeEmpty())
+		})
+
+		It("should render the branch of the hook", func() {
+			workflow := groupWorkflow("deploy-{{.workflow.hook.branch}}", &commonmodels.HookPayload{Branch: "main"})
+			Expect(renderConcurrencyGroupKey(workflow)).To(Equal("deploy-main"))
+		})
+
+		It("should fall back to the ref of the hook", func() {
+			workflow := groupWorkflow("deploy-{{.workflow.hook.branch}}", &commonmodels.HookPayload{Ref: "refs/heads/feature/a"})
+			Expect(renderConcurrencyGroupKey(workflow)).To(Equal("deploy-feature/a"))
+		})
+
+		It("should render the pr only for the pr hooks", func() {
+			workflow := groupWorkflow("pr-{{.workflow.hook.pr}}", &commonmodels.HookPayload{IsPr: true, MergeRequestID: "42", Branch: "main"})
+			Expect(renderConcurrencyGroupKey(workflow)).To(Equal("pr-42"))
+
+			workflow = groupWorkflow("pr-{{.workflow.hook.pr}}", &commonmodels.HookPayload{MergeRequestID: "42", Branch: "main"})
+			Expect(renderConcurrencyGroupKey(workflow)).To(Equal("pr-"))
+		})
+
+		It("should render empty hook variables for the tasks not triggered by a hook", func() {
+			workflow := groupWorkflow("deploy-{{.workflow.hook.branch}}-{{.workflow.hook.pr}}-prod", nil)
+			Expect(renderConcurrencyGroupKey(workflow)).To(Equal("deploy---prod"))
+		})
+	})
+
+	Context("supersededTasks", func() {
+		BeforeEach(func() {
+			log.Init(&log.Config{Level: "error"})
+		})
+
+		unfinishedTask := func(taskID int64, key string, status config.Status, jobs ...*commonmodels.JobTask) *commonmodels.WorkflowTask {
+			return &commonmodels.WorkflowTask{
+				WorkflowName:        "wf",
+				TaskID:              taskID,
+				ConcurrencyGroupKey: key,
+				Status:              status,
+				Stages:              []*commonmodels.StageTask{{Name: "stage", Jobs: jobs}},
+			}
+		}
+		deployJob := func(status config.Status) *commonmodels.JobTask {
+			return &commonmodels.JobTask{Name: "deploy", JobType: string(config.JobZadigDeploy), Status: status}
+		}
+		taskIDs := func(tasks []*commonmodels.WorkflowTask) []int64 {
+			ids := make([]int64, 0, len(tasks))
+			for _, task := range tasks {
+				ids = append(ids, task.TaskID)
+			}
+			return ids
+		}
+		newTask := unfinishedTask(10, "main", config.StatusCreated)
+
+		It("should supersede the older tasks with the same key", func() {
+			tasks := []*commonmodels.WorkflowTask{
+				unfinishedTask(7, "main", config.StatusRunning),
+				unfinishedTask(8, "dev", config.StatusWaiting),
+				unfinishedTask(9, "main", config.StatusWaiting),
+				newTask,
+				unfinishedTask(11, "main", config.StatusWaiting),
+			}
+			group := &commonmodels.ConcurrencyGroup{Key: "{{.workflow.hook.branch}}", Policy: config.ConcurrencyGroupCancel}
+			Expect(taskIDs(supersededTasks(newTask, group, tasks, log.SugaredLogger()))).To(Equal([]int64{7, 9}))
+		})
+
+		It("should only supersede the waiting tasks with replace_queued", func() {
+			tasks := []*commonmodels.WorkflowTask{
+				unfinishedTask(7, "main", config.StatusRunning),
+				unfinishedTask(8, "main", config.StatusWaitingApprove),
+				unfinishedTask(9, "main", config.StatusWaiting),
+			}
+			group := &commonmodels.ConcurrencyGroup{Key: "{{.workflow.hook.branch}}", Policy: config.ConcurrencyGroupReplaceQueued}
+			Expect(taskIDs(supersededTasks(newTask, group, tasks, log.SugaredLogger()))).To(Equal([]int64{9}))
+		})
+
+		It("should keep the tasks with active deploy jobs when protecting deploy", func() {
+			tasks := []*commonmodels.WorkflowTask{
+				unfinishedTask(3, "main", config.StatusRunning, deployJob(config.StatusPrepare)),
+				unfinishedTask(4, "main", config.StatusRunning, deployJob(config.StatusRunning)),
+				unfinishedTask(5, "main", config.StatusWaitingApprove, deployJob(config.StatusWaitingApprove)),
+				unfinishedTask(6, "main", config.StatusRunning, deployJob(config.StatusPassed)),
+				unfinishedTask(7, "main", config.StatusRunning, deployJob("")),
+				unfinishedTask(8, "main", config.StatusRunning, &commonmodels.JobTask{Name: "build", JobType: string(config.JobZadigBuild), Status: config.StatusRunning}),
+			}
+			group := &commonmodels.ConcurrencyGroup{Key: "{{.workflow.hook.branch}}", Policy: config.ConcurrencyGroupCancel, ProtectDeploy: true}
+			Expect(taskIDs(supersededTasks(newTask, group, tasks, log.SugaredLogger()))).To(Equal([]int64{6, 7, 8}))
+
+			group.ProtectDeploy = false
+			Expect(taskIDs(supersededTasks(newTask, group, tasks, log.SugaredLogger()))).To(Equal([]int64{3, 4, 5, 6, 7, 8}))
+		})
+	})
+})
@@ -117,6 +117,10 @@ func InitJobCtl(job *commonmodels.Job, workflow *commonmodels.WorkflowV4) (JobCt
 		resp = &JenkinsJob{job: job, workflow: workflow}
 	case config.JobSQL:
 		resp = &SQLJob{job: job, workflow: workflow}
+	case config.JobApproval:
+		resp = &ApprovalJob{job: job, workflow: workflow}
+	case config.JobManualInput:
+		resp = &ManualInputJob{job: job, workflow: workflow}
 	default:
 		return resp, fmt.Errorf("job type not found %s", job.JobType)
 	}
@@ -242,6 +246,9 @@ func GetWorkflowOutputs(workflow *commonmodels.WorkflowV4, currentJobName string
 			case config.JobZadigDeploy:
 				jobCtl := &DeployJob{job: job, workflow: workflow}
 				resp = append(resp, jobCtl.GetOutPuts(log)...)
+			case config.JobManualInput:
+				jobCtl := &ManualInputJob{job: job, workflow: workflow}
+				resp = append(resp, jobCtl.GetOutPuts(log)...)
 			}
 		}
 	}
@@ -359,6 +366,10 @@ func MergeArgs(workflow, workflowArgs *commonmodels.WorkflowV4) error {
 			}
 		}
 		workflow.Params = renderParams(workflowArgs.Params, workflow.Params)
+		// the trigger overrides the priority class of the workflow
+		if workflowArgs.PriorityClass != "" {
+			workflow.PriorityClass = workflowArgs.PriorityClass
+		}
 	}
 	for _, stage := range workflow.Stages {
 		for _, job := range stage.Jobs {
@@ -0,0 +1,87 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+type ApprovalJob struct {
+	job      *commonmodels.Job
+	workflow *commonmodels.WorkflowV4
+	spec     *commonmodels.ApprovalJobSpec
+}
+
+func (j *ApprovalJob) Instantiate() error {
+	j.spec = &commonmodels.ApprovalJobSpec{}
+	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
+		return err
+	}
+	j.job.Spec = j.spec
+	return nil
+}
+
+func (j *ApprovalJob) SetPreset() error {
+	j.spec = &commonmodels.ApprovalJobSpec{}
+	if err := commonmodels.IToi(j.job.Spec, j.spec); err != nil {
+		return err
+	}
+	j.job.Spec = j.spec
+	return nil
+}
+
+// MergeArgs keeps the approvers of the workflow, they can not be changed when the task is created.
+func (j *ApprovalJob) MergeArgs(args *commonmodels.Job) error {
+	return nil
+}
+
+func (j *ApprovalJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
+	resp := []*commonmodels.JobTask{}
+	j.spec = &commonmodels.ApprovalJobSpec{}
+	if err := commonmodels.IToi(j.job.Spec, j.spec); err != nil {
+		return resp, err
+	}
+	j.job.Spec = j.spec
+
+	jobTask := &commonmodels.JobTask{
+		Name: j.job.Name,
+		JobInfo: map[string]string{
+			JobNameKey: j.job.Name,
+		},
+		Key:     j.job.Name,
+		JobType: string(config.JobApproval),
+		Spec: &commonmodels.JobTaskApprovalSpec{
+			Approval: j.spec.ToApproval(),
+		},
+		Timeout: 0,
+	}
+	return []*commonmodels.JobTask{jobTask}, nil
+}
+
+func (j *ApprovalJob) LintJob() error {
+	j.spec = &commonmodels.ApprovalJobSpec{}
+	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
+		return err
+	}
+	if j.spec.Type == "" {
+		return errors.New("approval type should not be empty")
+	}
+	return nil
+}
@@ -369,6 +369,10 @@ func (j *BuildJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 					ImageReleaseTag:       imageTag,
 					BuildArgs:             buildInfo.PostBuild.DockerBuild.BuildArgs,
 					DockerTemplateContent: dockefileContent,
+					Builder:               buildInfo.PostBuild.DockerBuild.Builder,
+					Platform:              buildInfo.PostBuild.DockerBuild.Platform,
+					EnableRegistryCache:   buildInfo.PostBuild.DockerBuild.EnableRegistryCache,
+					CacheImage:            buildInfo.PostBuild.DockerBuild.CacheImage,
 					DockerRegistry: &step.DockerRegistry{
 						DockerRegistryID: j.spec.DockerRegistryID,
 						Host:             registry.RegAddr,
@@ -378,7 +382,38 @@ func (j *BuildJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 					},
 				},
 			}
+			dockerBuildSpec := dockerBuildStep.Spec.(step.StepDockerBuildSpec)
+			if err := dockerBuildSpec.Validate(); err != nil {
+				return resp, fmt.Errorf("build %s: %v", build.BuildName, err)
+			}
+			if dockerBuildSpec.IsDaemonless() && jobTask.Infrastructure == setting.JobVMInfrastructure {
+				return resp, fmt.Errorf("build %s: builder %s is not supported on vm", build.BuildName, dockerBuildSpec.Builder)
+			}
 			jobTaskSpec.Steps = append(jobTaskSpec.Steps, dockerBuildStep)
+
+			// init supply chain step
+			if buildInfo.PostBuild.SupplyChain.Enabled() {
+				if jobTask.Infrastructure == setting.JobVMInfrastructure {
+					return resp, fmt.Errorf("build %s: supply chain artifacts are not supported on vm", build.BuildName)
+				}
+				supplyChainSpec, err := getSupplyChainSpec(buildInfo.PostBuild.SupplyChain, dockerBuildSpec.DockerRegistry, &step.SupplyChainBuildContext{
+					WorkflowName: j.workflow.Name,
+					TaskID:       taskID,
+					JobName:      jobTask.Name,
+					BuilderImage: getBuilderImage(jobTaskSpec.Properties.BuildOS, jobTaskSpec.Properties.ImageFrom),
+					Repos:        getSupplyChainRepos(gitStep.Spec.(step.StepGitSpec).Repos),
+				})
+				if err != nil {
+					return resp, fmt.Errorf("build %s: %v", build.BuildName, err)
+				}
+				jobTaskSpec.Steps = append(jobTaskSpec.Steps, &commonmodels.StepTask{
+					Name:     build.ServiceName + "-supply-chain",
+					JobName:  jobTask.Name,
+					StepType: config.StepSupplyChain,
+					Spec:     supplyChainSpec,
+				})
+				jobTask.Outputs = append(jobTask.Outputs, supplyChainOutputs()...)
+			}
 		}
 
 		// init archive step
@@ -624,7 +659,7 @@ func (j *BuildJob) LintJob() error {
 		}
 	}
 
-	return nil
+	return lintJobMatrix(j.spec.Matrix)
 }
 
 func (j *BuildJob) GetOutPuts(log *zap.SugaredLogger) []string {
@@ -640,16 +675,22 @@ func (j *BuildJob) GetOutPuts(log *zap.SugaredLogger) []string {
 			log.Errorf("found build %s failed, err: %s", build.BuildName, err)
 			continue
 		}
-		if buildInfo.TemplateID == "" {
-			resp = append(resp, getOutputKey(jobKey, ensureBuildInOutputs(buildInfo.Outputs))...)
-			continue
+		outputs := buildInfo.Outputs
+		if buildInfo.TemplateID != "" {
+			buildTemplate, err := commonrepo.NewBuildTemplateColl().Find(&commonrepo.BuildTemplateQueryOption{ID: buildInfo.TemplateID})
+			if err != nil {
+				log.Errorf("found build template %s failed, err: %s", buildInfo.TemplateID, err)
+				continue
+			}
+			outputs = buildTemplate.Outputs
 		}
-		buildTemplate, err := commonrepo.NewBuildTemplateColl().Find(&commonrepo.BuildTemplateQueryOption{ID: buildInfo.TemplateID})
-		if err != nil {
-			log.Errorf("found build template %s failed, err: %s", buildInfo.TemplateID, err)
-			continue
+		outputs = ensureBuildInOutputs(outputs)
+		if buildInfo.PostBuild != nil && buildInfo.PostBuild.DockerBuild != nil && buildInfo.PostBuild.SupplyChain.Enabled() {
+			outputs = append(outputs, supplyChainOutputs()...)
+		}
+		for _, key := range matrixJobKeys(j.spec.Matrix, jobKey) {
+			resp = append(resp, getOutputKey(key, outputs)...)
 		}
-		resp = append(resp, getOutputKey(jobKey, ensureBuildInOutputs(buildTemplate.Outputs))...)
 	}
 	return resp
 }
@@ -676,3 +717,62 @@ func ensureBuildInOutputs(outputs []*commonmodels.Output) []*commonmodels.Output
 	}
 	return outputs
 }
+
+func getSupplyChainSpec(supplyChain *commonmodels.SupplyChain, registry *step.DockerRegistry, buildCtx *step.SupplyChainBuildContext) (*step.StepSupplyChainSpec, error) {
+	if supplyChain.SBOMFormat != "" && supplyChain.SBOMFormat != step.SBOMFormatSPDX && supplyChain.SBOMFormat != step.SBOMFormatCycloneDX {
+		return nil, fmt.Errorf("unknown SBOM format %s", supplyChain.SBOMFormat)
+	}
+	spec := &step.StepSupplyChainSpec{
+		ImageName:      "$IMAGE",
+		DockerRegistry: registry,
+		SBOMFormat:     supplyChain.SBOMFormat,
+		Sign:           supplyChain.Sign,
+		Provenance:     supplyChain.Provenance,
+		BuildContext:   buildCtx,
+	}
+	if spec.NeedKey() {
+		if supplyChain.SigningKeyID == "" {
+			return nil, fmt.Errorf("signing key is required to sign the image or attest the provenance")
+		}
+		// the private key is mounted into the job from a secret right before the job runs
+		if _, err := commonrepo.NewSigningKeyColl().Find(supplyChain.SigningKeyID); err != nil {
+			return nil, fmt.Errorf("find signing key %s error: %v", supplyChain.SigningKeyID, err)
+		}
+		spec.SigningKeyID = supplyChain.SigningKeyID
+	}
+	return spec, nil
+}
+
+func getSupplyChainRepos(repos []*types.Repository) []*step.SupplyChainRepo {
+	resp := make([]*step.SupplyChainRepo, 0)
+	for _, repo := range repos {
+		checkoutPath := repo.RepoName
+		if repo.CheckoutPath != "" {
+			checkoutPath = repo.CheckoutPath
+		}
+		resp = append(resp, &step.SupplyChainRepo{
+			URL:      fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(repo.Address, "/"), repo.GetRepoNamespace(), repo.RepoName),
+			Path:     checkoutPath,
+			Branch:   repo.Branch,
+			CommitID: repo.CommitID,
+		})
+	}
+	return resp
+}
+
+// getBuilderImage returns the image the build job runs in, see the job controller.
+func getBuilderImage(buildOS, imageFrom string) string {
+	if imageFrom == setting.ImageFromCustom {
+		return buildOS
+	}
+	return strings.ReplaceAll(config.ReaperImage(), "${BuildOS}", buildOS)
+}
+
+func supplyChainOutputs() []*commonmodels.Output {
+	return []*commonmodels.Output{
+		{Name: step.SupplyChainOutputDigest},
+		{Name: step.SupplyChainOutputSignature},
+		{Name: step.SupplyChainOutputSBOM},
+		{Name: step.SupplyChainOutputAttestation},
+	}
+}
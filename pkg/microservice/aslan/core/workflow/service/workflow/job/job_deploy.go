@@ -276,15 +276,16 @@ func (j *DeployJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 		}
 		for serviceName, deploys := range deployServiceMap {
 			jobTaskSpec := &commonmodels.JobTaskDeploySpec{
-				Env:                envName,
-				SkipCheckRunStatus: j.spec.SkipCheckRunStatus,
-				ServiceName:        serviceName,
-				ServiceType:        setting.K8SDeployType,
-				CreateEnvType:      project.ProductFeature.CreateEnvType,
-				ClusterID:          product.ClusterID,
-				Production:         j.spec.Production,
-				DeployContents:     j.spec.DeployContents,
-				Timeout:            timeout,
+				Env:                  envName,
+				SkipCheckRunStatus:   j.spec.SkipCheckRunStatus,
+				ServiceName:          serviceName,
+				ServiceType:          setting.K8SDeployType,
+				CreateEnvType:        project.ProductFeature.CreateEnvType,
+				ClusterID:            product.ClusterID,
+				Production:           j.spec.Production,
+				DeployContents:       j.spec.DeployContents,
+				Timeout:              timeout,
+				VerifyImageSignature: j.spec.VerifyImageSignature,
 			}
 
 			for _, deploy := range deploys {
@@ -29,6 +29,7 @@ import (
 	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/util"
+	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/log"
 	"github.com/koderover/zadig/pkg/types"
 	steptypes "github.com/koderover/zadig/pkg/types/step"
@@ -76,6 +77,30 @@ func (j *FreeStyleJob) Instantiate() error {
 				return fmt.Errorf("parse archive step spec error: %v", err)
 			}
 			step.Spec = stepSpec
+		case config.StepDockerBuild:
+			stepSpec := &steptypes.StepDockerBuildSpec{}
+			if err := commonmodels.IToiYaml(step.Spec, stepSpec); err != nil {
+				return fmt.Errorf("parse docker build step spec error: %v", err)
+			}
+			if err := stepSpec.Validate(); err != nil {
+				return fmt.Errorf("docker build step %s: %v", step.Name, err)
+			}
+			if stepSpec.IsDaemonless() && j.spec.Properties.Infrastructure == setting.JobVMInfrastructure {
+				return fmt.Errorf("builder %s is not supported on vm", stepSpec.Builder)
+			}
+			step.Spec = stepSpec
+		case config.StepCacheRestore, config.StepCacheSave:
+			if j.spec.Properties.Infrastructure == setting.JobVMInfrastructure {
+				return fmt.Errorf("step type %s is not supported on vm, the vm agent caches the job workspace itself", step.StepType)
+			}
+			stepSpec := &steptypes.StepCacheSpec{}
+			if err := commonmodels.IToiYaml(step.Spec, stepSpec); err != nil {
+				return fmt.Errorf("parse cache step spec error: %v", err)
+			}
+			if stepSpec.Key == "" || len(stepSpec.Paths) == 0 {
+				return fmt.Errorf("cache step %s should have key and paths", step.Name)
+			}
+			step.Spec = stepSpec
 		default:
 			return fmt.Errorf("freestyle job step type %s not supported", step.StepType)
 		}
@@ -332,6 +357,9 @@ func (j *FreeStyleJob) LintJob() error {
 	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
 		return err
 	}
+	if err := lintJobMatrix(j.spec.Matrix); err != nil {
+		return err
+	}
 	return checkOutputNames(j.spec.Outputs)
 }
 
@@ -342,7 +370,8 @@ func (j *FreeStyleJob) GetOutPuts(log *zap.SugaredLogger) []string {
 		return resp
 	}
 
-	jobKey := j.job.Name
-	resp = append(resp, getOutputKey(jobKey, j.spec.Outputs)...)
+	for _, jobKey := range matrixJobKeys(j.spec.Matrix, j.job.Name) {
+		resp = append(resp, getOutputKey(jobKey, j.spec.Outputs)...)
+	}
 	return resp
 }
@@ -0,0 +1,119 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	approvalservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/approval"
+	"github.com/koderover/zadig/pkg/types/job"
+)
+
+type ManualInputJob struct {
+	job      *commonmodels.Job
+	workflow *commonmodels.WorkflowV4
+	spec     *commonmodels.ManualInputJobSpec
+}
+
+func (j *ManualInputJob) Instantiate() error {
+	j.spec = &commonmodels.ManualInputJobSpec{}
+	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
+		return err
+	}
+	j.job.Spec = j.spec
+	return nil
+}
+
+func (j *ManualInputJob) SetPreset() error {
+	j.spec = &commonmodels.ManualInputJobSpec{}
+	if err := commonmodels.IToi(j.job.Spec, j.spec); err != nil {
+		return err
+	}
+	j.job.Spec = j.spec
+	return nil
+}
+
+// MergeArgs keeps the fields of the workflow, the values are given when the task is running instead of being created.
+func (j *ManualInputJob) MergeArgs(args *commonmodels.Job) error {
+	return nil
+}
+
+func (j *ManualInputJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
+	resp := []*commonmodels.JobTask{}
+	j.spec = &commonmodels.ManualInputJobSpec{}
+	if err := commonmodels.IToi(j.job.Spec, j.spec); err != nil {
+		return resp, err
+	}
+	j.job.Spec = j.spec
+	for _, field := range j.spec.Fields {
+		field.Value = ""
+	}
+
+	jobTask := &commonmodels.JobTask{
+		Name: j.job.Name,
+		JobInfo: map[string]string{
+			JobNameKey: j.job.Name,
+		},
+		Key:     j.job.Name,
+		JobType: string(config.JobManualInput),
+		Spec: &commonmodels.JobTaskManualInputSpec{
+			Description: j.spec.Description,
+			Timeout:     j.spec.Timeout,
+			Users:       j.spec.Users,
+			Fields:      j.spec.Fields,
+		},
+		Timeout: 0,
+	}
+	return []*commonmodels.JobTask{jobTask}, nil
+}
+
+func (j *ManualInputJob) LintJob() error {
+	j.spec = &commonmodels.ManualInputJobSpec{}
+	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
+		return err
+	}
+	if len(j.spec.Users) == 0 {
+		return errors.New("users who submit the inputs should not be empty")
+	}
+	if len(j.spec.Fields) == 0 {
+		return errors.New("inputs should not be empty")
+	}
+	for _, field := range j.spec.Fields {
+		if !OutputNameRegex.MatchString(field.Name) {
+			return fmt.Errorf("input name %s did not match %s", field.Name, OutputNameRegexString)
+		}
+	}
+	return approvalservice.LintManualInputFields(j.spec.Fields)
+}
+
+// GetOutPuts returns the inputs of the job, they are written into the task as outputs once submitted.
+func (j *ManualInputJob) GetOutPuts(log *zap.SugaredLogger) []string {
+	resp := []string{}
+	j.spec = &commonmodels.ManualInputJobSpec{}
+	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
+		return resp
+	}
+	for _, field := range j.spec.Fields {
+		resp = append(resp, job.GetJobOutputKey(j.job.Name, field.Name))
+	}
+	return resp
+}
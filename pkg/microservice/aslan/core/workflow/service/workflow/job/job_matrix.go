@@ -0,0 +1,269 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+// maxMatrixCombinations limits the number of job tasks a job matrix can be expanded to.
+const maxMatrixCombinations = 256
+
+var (
+	matrixAxisRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	matrixCellRegexp = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// getJobMatrix returns the matrix of the freestyle, build and testing jobs, nil if the job has no matrix.
+func getJobMatrix(job *commonmodels.Job) (*commonmodels.JobMatrix, error) {
+	switch job.JobType {
+	case config.JobFreestyle, config.JobZadigBuild, config.JobZadigTesting:
+	default:
+		return nil, nil
+	}
+	spec := &struct {
+		Matrix *commonmodels.JobMatrix `json:"matrix"`
+	}{}
+	if err := commonmodels.IToi(job.Spec, spec); err != nil {
+		return nil, err
+	}
+	return spec.Matrix, nil
+}
+
+// MatrixCombinations returns the combinations of the axis values in the order of the sorted axis names,
+// the combinations matching an exclude entry are removed and the include entries are added at the end.
+func MatrixCombinations(matrix *commonmodels.JobMatrix) []map[string]string {
+	axes := make([]string, 0, len(matrix.Axes))
+	for axis := range matrix.Axes {
+		axes = append(axes, axis)
+	}
+	sort.Strings(axes)
+
+	combinations := []map[string]string{}
+	if len(axes) > 0 {
+		combinations = append(combinations, map[string]string{})
+	}
+	for _, axis := range axes {
+		next := make([]map[string]string, 0, len(combinations)*len(matrix.Axes[axis]))
+		for _, combination := range combinations {
+			for _, value := range matrix.Axes[axis] {
+				c := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					c[k] = v
+				}
+				c[axis] = value
+				next = append(next, c)
+			}
+		}
+		combinations = next
+	}
+
+	resp := make([]map[string]string, 0, len(combinations)+len(matrix.Include))
+	for _, combination := range combinations {
+		excluded := false
+		for _, exclude := range matrix.Exclude {
+			if matrixValuesMatch(combination, exclude) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			resp = append(resp, combination)
+		}
+	}
+	for _, include := range matrix.Include {
+		duplicated := false
+		for _, combination := range resp {
+			if len(combination) == len(include) && matrixValuesMatch(combination, include) {
+				duplicated = true
+				break
+			}
+		}
+		if !duplicated && len(include) > 0 {
+			resp = append(resp, include)
+		}
+	}
+	return resp
+}
+
+func matrixValuesMatch(combination, values map[string]string) bool {
+	for k, v := range values {
+		if combination[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// matrixCellName joins the values of a combination in the order of the axis names, it is used in job names and
+// output keys.
+func matrixCellName(values map[string]string) string {
+	axes := make([]string, 0, len(values))
+	for axis := range values {
+		axes = append(axes, axis)
+	}
+	sort.Strings(axes)
+	parts := make([]string, 0, len(axes))
+	for _, axis := range axes {
+		parts = append(parts, values[axis])
+	}
+	name := matrixCellRegexp.ReplaceAllString(strings.ToLower(strings.Join(parts, "-")), "-")
+	return strings.Trim(name, "-")
+}
+
+// matrixJobKeys returns the job keys of all the combinations, outputs of a combination are namespaced by its
+// cell name, e.g. the combination {GO_VERSION: 1.21, OS: linux} of job build has the cell name 1-21-linux and
+// the output {{.job.build.1-21-linux.output.IMAGE}}.
+func matrixJobKeys(matrix *commonmodels.JobMatrix, jobKey string) []string {
+	if matrix == nil {
+		return []string{jobKey}
+	}
+	resp := []string{}
+	for _, values := range MatrixCombinations(matrix) {
+		resp = append(resp, jobKey+"."+matrixCellName(values))
+	}
+	return resp
+}
+
+func lintJobMatrix(matrix *commonmodels.JobMatrix) error {
+	if matrix == nil {
+		return nil
+	}
+	if len(matrix.Axes) == 0 {
+		return fmt.Errorf("matrix axes can not be empty")
+	}
+	for axis, values := range matrix.Axes {
+		if !matrixAxisRegexp.MatchString(axis) {
+			return fmt.Errorf("matrix axis %s should be a valid env var name", axis)
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("matrix axis %s has no value", axis)
+		}
+	}
+	for _, include := range matrix.Include {
+		for axis := range include {
+			if !matrixAxisRegexp.MatchString(axis) {
+				return fmt.Errorf("matrix include key %s should be a valid env var name", axis)
+			}
+		}
+	}
+	for _, exclude := range matrix.Exclude {
+		for axis := range exclude {
+			if _, ok := matrix.Axes[axis]; !ok {
+				return fmt.Errorf("matrix exclude key %s is not an axis", axis)
+			}
+		}
+	}
+	if matrix.MaxParallel < 0 {
+		return fmt.Errorf("matrix max_parallel can not be negative")
+	}
+
+	combinations := MatrixCombinations(matrix)
+	if len(combinations) == 0 {
+		return fmt.Errorf("matrix has no combination left after exclude")
+	}
+	if len(combinations) > maxMatrixCombinations {
+		return fmt.Errorf("matrix has %d combinations, the limit is %d", len(combinations), maxMatrixCombinations)
+	}
+	cells := map[string]struct{}{}
+	for _, values := range combinations {
+		cell := matrixCellName(values)
+		if _, ok := cells[cell]; ok {
+			return fmt.Errorf("matrix combinations have the same name %s", cell)
+		}
+		cells[cell] = struct{}{}
+	}
+	return nil
+}
+
+// ExpandMatrix copies every job task of a job with a matrix once for each combination, the axis values are
+// injected as env vars and the job key is suffixed with the cell name so the outputs do not conflict.
+func ExpandMatrix(job *commonmodels.Job, jobTasks []*commonmodels.JobTask) ([]*commonmodels.JobTask, error) {
+	matrix, err := getJobMatrix(job)
+	if err != nil {
+		return nil, warpJobError(job.Name, err)
+	}
+	if matrix == nil {
+		return jobTasks, nil
+	}
+	if err := lintJobMatrix(matrix); err != nil {
+		return nil, warpJobError(job.Name, err)
+	}
+
+	resp := []*commonmodels.JobTask{}
+	for _, jobTask := range jobTasks {
+		for _, values := range MatrixCombinations(matrix) {
+			cell := matrixCellName(values)
+			newTask, err := copyMatrixJobTask(jobTask)
+			if err != nil {
+				return nil, warpJobError(job.Name, err)
+			}
+			newTask.Name = jobNameFormat(jobTask.Name + "-" + cell)
+			newTask.Key = jobTask.Key + "." + cell
+			newTask.Matrix = &commonmodels.JobTaskMatrix{
+				Group:       jobTask.Key,
+				Values:      values,
+				MaxParallel: matrix.MaxParallel,
+				FailFast:    matrix.FailFast,
+			}
+			spec := newTask.Spec.(*commonmodels.JobTaskFreestyleSpec)
+			for _, axis := range sortedMatrixKeys(values) {
+				spec.Properties.Envs = setKeyVal(spec.Properties.Envs, axis, values[axis])
+			}
+			resp = append(resp, newTask)
+		}
+	}
+	return resp, nil
+}
+
+func copyMatrixJobTask(jobTask *commonmodels.JobTask) (*commonmodels.JobTask, error) {
+	newTask := &commonmodels.JobTask{}
+	if err := commonmodels.IToi(jobTask, newTask); err != nil {
+		return nil, err
+	}
+	spec := &commonmodels.JobTaskFreestyleSpec{}
+	if err := commonmodels.IToi(jobTask.Spec, spec); err != nil {
+		return nil, fmt.Errorf("copy job task spec error: %v", err)
+	}
+	newTask.Spec = spec
+	return newTask, nil
+}
+
+func sortedMatrixKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func setKeyVal(kvs []*commonmodels.KeyVal, key, value string) []*commonmodels.KeyVal {
+	for _, kv := range kvs {
+		if kv.Key == key {
+			kv.Value = value
+			return kvs
+		}
+	}
+	return append(kvs, &commonmodels.KeyVal{Key: key, Value: value, IsCredential: false})
+}
@@ -27,6 +27,7 @@ import (
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/vulnerability"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/log"
 	"github.com/koderover/zadig/pkg/tool/sonar"
@@ -317,6 +318,15 @@ func (j *ScanningJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 			}
 			jobTaskSpec.Steps = append(jobTaskSpec.Steps, shellStep)
 		}
+		if scanningInfo.ScannerType == types.ScanningTypeTrivy && scanningInfo.TrivySetting != nil {
+			jobTaskSpec.Steps = append(jobTaskSpec.Steps, &commonmodels.StepTask{
+				Name:     scanning.Name + "-vulnerability-scan",
+				JobName:  jobTask.Name,
+				StepType: config.StepVulnerabilityScan,
+				Spec:     vulnerability.NewScanSpec(scanningInfo.TrivySetting, repoName),
+			})
+			jobTask.Outputs = append(jobTask.Outputs, vulnerabilityOutputs()...)
+		}
 		// init debug after step
 		debugAfterStep := &commonmodels.StepTask{
 			Name:     scanning.Name + "-debug-after",
@@ -352,6 +362,9 @@ func (j *ScanningJob) GetOutPuts(log *zap.SugaredLogger) []string {
 	for _, scanningInfo := range scanningInfos {
 		jobKey := strings.Join([]string{j.job.Name, scanningInfo.Name}, ".")
 		resp = append(resp, getOutputKey(jobKey, scanningInfo.Outputs)...)
+		if scanningInfo.ScannerType == types.ScanningTypeTrivy {
+			resp = append(resp, getOutputKey(jobKey, vulnerabilityOutputs())...)
+		}
 	}
 	return resp
 }
@@ -366,3 +379,12 @@ func getScanningJobVariables(repos []*types.Repository, taskID int64, project, w
 
 	return ret
 }
+
+// vulnerabilityOutputs are the job outputs written by the vulnerability scan step of a trivy scanning.
+func vulnerabilityOutputs() []*commonmodels.Output {
+	resp := []*commonmodels.Output{}
+	for _, output := range step.VulnerabilityOutputs() {
+		resp = append(resp, &commonmodels.Output{Name: output})
+	}
+	return resp
+}
@@ -382,11 +382,12 @@ func (j *TestingJob) toJobtask(testing *commonmodels.TestModule, defaultS3 *comm
 	}
 	jobTaskSpec.Steps = append(jobTaskSpec.Steps, toolInstallStep)
 	// init git clone step
+	repos := renderRepos(testing.Repos, testingInfo.Repos, jobTaskSpec.Properties.Envs)
 	gitStep := &commonmodels.StepTask{
 		Name:     testing.Name + "-git",
 		JobName:  jobTask.Name,
 		StepType: config.StepGit,
-		Spec:     step.StepGitSpec{Repos: renderRepos(testing.Repos, testingInfo.Repos, jobTaskSpec.Properties.Envs)},
+		Spec:     step.StepGitSpec{Repos: repos},
 	}
 	jobTaskSpec.Steps = append(jobTaskSpec.Steps, gitStep)
 	// init debug before step
@@ -455,18 +456,33 @@ func (j *TestingJob) toJobtask(testing *commonmodels.TestModule, defaultS3 *comm
 
 	// init junit report step
 	if len(testingInfo.TestResultPath) > 0 {
+		junitReportSpec := &step.StepJunitReportSpec{
+			ReportDir:      testingInfo.TestResultPath,
+			S3DestDir:      path.Join(j.workflow.Name, fmt.Sprint(taskID), jobTask.Name, "junit"),
+			TestName:       testing.Name,
+			DestDir:        "/tmp",
+			FileName:       "merged.xml",
+			ReportFormat:   testingInfo.TestResultFormat,
+			CoveragePath:   testingInfo.CoverageReportPath,
+			CoverageFormat: testingInfo.CoverageFormat,
+		}
+		// the results are recorded against the first repo of the testing
+		if len(repos) > 0 {
+			junitReportSpec.RepoName = repos[0].RepoName
+			junitReportSpec.RepoDir = repos[0].RepoName
+			if repos[0].CheckoutPath != "" {
+				junitReportSpec.RepoDir = repos[0].CheckoutPath
+			}
+			junitReportSpec.Branch = repos[0].Branch
+			junitReportSpec.PR = repos[0].PR
+			junitReportSpec.CommitID = repos[0].CommitID
+		}
 		junitStep := &commonmodels.StepTask{
 			Name:      config.TestJobJunitReportStepName,
 			JobName:   jobTask.Name,
 			StepType:  config.StepJunitReport,
 			Onfailure: true,
-			Spec: &step.StepJunitReportSpec{
-				ReportDir: testingInfo.TestResultPath,
-				S3DestDir: path.Join(j.workflow.Name, fmt.Sprint(taskID), jobTask.Name, "junit"),
-				TestName:  testing.Name,
-				DestDir:   "/tmp",
-				FileName:  "merged.xml",
-			},
+			Spec:      junitReportSpec,
 		}
 		jobTaskSpec.Steps = append(jobTaskSpec.Steps, junitStep)
 	}
@@ -506,6 +522,9 @@ func (j *TestingJob) LintJob() error {
 	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
 		return err
 	}
+	if err := lintJobMatrix(j.spec.Matrix); err != nil {
+		return err
+	}
 	if j.spec.Source != config.SourceFromJob {
 		return nil
 	}
@@ -534,7 +553,9 @@ func (j *TestingJob) GetOutPuts(log *zap.SugaredLogger) []string {
 	}
 	for _, testInfo := range testingInfos {
 		jobKey := strings.Join([]string{j.job.Name, testInfo.Name}, ".")
-		resp = append(resp, getOutputKey(jobKey, testInfo.Outputs)...)
+		for _, key := range matrixJobKeys(j.spec.Matrix, jobKey) {
+			resp = append(resp, getOutputKey(key, testInfo.Outputs)...)
+		}
 	}
 	return resp
 }
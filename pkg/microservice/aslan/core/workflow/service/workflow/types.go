@@ -781,13 +781,23 @@ type ViewWorkflow struct {
 }
 
 type OpenAPIApproveRequest struct {
-	StageName    string `json:"stage_name"`
+	StageName string `json:"stage_name"`
+	// JobName is set instead of StageName to approve an approval job
+	JobName      string `json:"job_name"`
 	WorkflowName string `json:"workflow_key"`
 	TaskID       int64  `json:"task_id"`
 	Approve      bool   `json:"approve"`
 	Comment      string `json:"comment"`
 }
 
+type OpenAPIManualInputRequest struct {
+	JobName      string            `json:"job_name"`
+	WorkflowName string            `json:"workflow_key"`
+	TaskID       int64             `json:"task_id"`
+	Inputs       map[string]string `json:"inputs"`
+	Comment      string            `json:"comment"`
+}
+
 type OpenAPICreateWorkflowViewReq struct {
 	ProjectName  string                       `json:"project_key"`
 	Name         string                       `json:"name"`
@@ -0,0 +1,369 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pmezard/go-difflib/difflib"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/command"
+	jobctl "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow/job"
+	"github.com/koderover/zadig/pkg/shared/client/systemconfig"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+const (
+	defaultWorkflowCodePath = ".zadig"
+
+	WorkflowCodeActionCreate    = "create"
+	WorkflowCodeActionUpdate    = "update"
+	WorkflowCodeActionUnchanged = "unchanged"
+	// WorkflowCodeActionRelease means the file of the workflow is removed from the repo,
+	// the workflow is kept and can be edited in the UI again.
+	WorkflowCodeActionRelease = "release"
+	WorkflowCodeActionInvalid = "invalid"
+)
+
+// workflowCodeSyncLock serializes the syncs of this replica, two projects may sync from the same repo.
+var workflowCodeSyncLock sync.Mutex
+
+type WorkflowCodeSyncResult struct {
+	CommitSHA string              `json:"commit_sha"`
+	Workflows []*WorkflowCodeDiff `json:"workflows"`
+}
+
+type WorkflowCodeDiff struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Action string `json:"action"`
+	// Diff is the unified diff from the stored definition to the one in the repo.
+	Diff  string `json:"diff"`
+	Error string `json:"error"`
+}
+
+func GetWorkflowCodeSetting(projectName string, logger *zap.SugaredLogger) (*commonmodels.WorkflowCodeSetting, error) {
+	resp, err := commonrepo.NewWorkflowCodeSettingColl().Find(projectName)
+	if err == mongo.ErrNoDocuments {
+		return &commonmodels.WorkflowCodeSetting{ProjectName: projectName, Path: defaultWorkflowCodePath}, nil
+	}
+	if err != nil {
+		logger.Errorf("failed to find workflow code setting of project %s: %v", projectName, err)
+		return nil, e.ErrFindWorkflow.AddErr(err)
+	}
+	return resp, nil
+}
+
+func UpdateWorkflowCodeSetting(projectName, user string, args *commonmodels.WorkflowCodeSetting, logger *zap.SugaredLogger) error {
+	args.ProjectName = projectName
+	if args.Path == "" {
+		args.Path = defaultWorkflowCodePath
+	}
+	args.Path = strings.Trim(path.Clean("/"+args.Path), "/")
+	if args.Enabled {
+		if args.RepoName == "" || args.Branch == "" {
+			return e.ErrInvalidParam.AddDesc("repo and branch are required")
+		}
+		if _, err := systemconfig.New().GetCodeHost(args.CodehostID); err != nil {
+			return e.ErrInvalidParam.AddDesc(fmt.Sprintf("failed to find codehost %d: %v", args.CodehostID, err))
+		}
+	}
+	args.UpdatedBy = user
+	args.UpdateTime = time.Now().Unix()
+	if err := commonrepo.NewWorkflowCodeSettingColl().Upsert(args); err != nil {
+		logger.Errorf("failed to update workflow code setting of project %s: %v", projectName, err)
+		return e.ErrUpsertWorkflow.AddErr(err)
+	}
+	return nil
+}
+
+// SyncWorkflowCode syncs the workflows of the project from its repo, nothing is changed if dryRun is set.
+func SyncWorkflowCode(projectName, user string, dryRun bool, logger *zap.SugaredLogger) (*WorkflowCodeSyncResult, error) {
+	codeSetting, err := commonrepo.NewWorkflowCodeSettingColl().Find(projectName)
+	if err != nil {
+		return nil, e.ErrFindWorkflow.AddDesc(fmt.Sprintf("workflow code setting of project %s not found", projectName))
+	}
+	if !codeSetting.Enabled {
+		return nil, e.ErrInvalidParam.AddDesc("workflow code is not enabled")
+	}
+	resp, err := syncWorkflowCode(codeSetting, user, dryRun, logger)
+	if err != nil {
+		return nil, e.ErrUpsertWorkflow.AddErr(err)
+	}
+	return resp, nil
+}
+
+// SyncWorkflowCodeByPush syncs the workflows of all the projects pointing at the pushed branch.
+func SyncWorkflowCodeByPush(namespace, repoName, branch string, logger *zap.SugaredLogger) error {
+	settings, err := commonrepo.NewWorkflowCodeSettingColl().ListEnabledByRepo(namespace, repoName, branch)
+	if err != nil {
+		return err
+	}
+	errs := &multierror.Error{}
+	for _, codeSetting := range settings {
+		if _, err := syncWorkflowCode(codeSetting, "webhook", false, logger); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("project %s: %v", codeSetting.ProjectName, err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func syncWorkflowCode(codeSetting *commonmodels.WorkflowCodeSetting, user string, dryRun bool, logger *zap.SugaredLogger) (*WorkflowCodeSyncResult, error) {
+	workflowCodeSyncLock.Lock()
+	defer workflowCodeSyncLock.Unlock()
+
+	commitSHA, files, err := loadWorkflowCodeFiles(codeSetting)
+	if err != nil {
+		logger.Errorf("failed to load workflow code of project %s: %v", codeSetting.ProjectName, err)
+		if !dryRun {
+			commonrepo.NewWorkflowCodeSettingColl().UpdateSyncResult(codeSetting.ProjectName, codeSetting.LastCommitSHA, err.Error(), time.Now().Unix())
+		}
+		return nil, err
+	}
+
+	resp := &WorkflowCodeSyncResult{CommitSHA: commitSHA, Workflows: make([]*WorkflowCodeDiff, 0)}
+	errs := &multierror.Error{}
+	syncedPaths := map[string]bool{}
+	for _, filePath := range sortedWorkflowCodePaths(files) {
+		syncedPaths[filePath] = true
+		diff := syncWorkflowCodeFile(codeSetting, filePath, files[filePath], commitSHA, user, dryRun, logger)
+		if diff.Error != "" {
+			errs = multierror.Append(errs, fmt.Errorf("%s: %s", filePath, diff.Error))
+		}
+		resp.Workflows = append(resp.Workflows, diff)
+	}
+
+	workflows, _, err := commonrepo.NewWorkflowV4Coll().List(&commonrepo.ListWorkflowV4Option{ProjectName: codeSetting.ProjectName}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, workflow := range workflows {
+		if workflow.CodeSource == nil || syncedPaths[workflow.CodeSource.Path] {
+			continue
+		}
+		diff := &WorkflowCodeDiff{Name: workflow.Name, Path: workflow.CodeSource.Path, Action: WorkflowCodeActionRelease}
+		if !dryRun {
+			if err := commonrepo.NewWorkflowV4Coll().SetCodeSource(workflow.Name, nil); err != nil {
+				diff.Error = err.Error()
+				errs = multierror.Append(errs, err)
+			}
+		}
+		resp.Workflows = append(resp.Workflows, diff)
+	}
+
+	if !dryRun {
+		syncError := ""
+		if errs.ErrorOrNil() != nil {
+			syncError = errs.Error()
+		}
+		if err := commonrepo.NewWorkflowCodeSettingColl().UpdateSyncResult(codeSetting.ProjectName, commitSHA, syncError, time.Now().Unix()); err != nil {
+			logger.Errorf("failed to update workflow code sync result of project %s: %v", codeSetting.ProjectName, err)
+		}
+	}
+	return resp, nil
+}
+
+// syncWorkflowCodeFile lints the workflow in the file, then creates or updates it unless dryRun is set.
+func syncWorkflowCodeFile(codeSetting *commonmodels.WorkflowCodeSetting, filePath string, content []byte, commitSHA, user string, dryRun bool, logger *zap.SugaredLogger) *WorkflowCodeDiff {
+	resp := &WorkflowCodeDiff{Path: filePath, Action: WorkflowCodeActionInvalid}
+	workflow := new(commonmodels.WorkflowV4)
+	if err := yaml.Unmarshal(content, workflow); err != nil {
+		resp.Error = fmt.Sprintf("unmarshal workflow error: %v", err)
+		return resp
+	}
+	resp.Name = workflow.Name
+	if workflow.Project == "" {
+		workflow.Project = codeSetting.ProjectName
+	}
+	if workflow.Project != codeSetting.ProjectName {
+		resp.Error = fmt.Sprintf("workflow belongs to project %s", workflow.Project)
+		return resp
+	}
+	if err := LintWorkflowV4(workflow, logger); err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+
+	existed, err := commonrepo.NewWorkflowV4Coll().Find(workflow.Name)
+	if err != nil && err != mongo.ErrNoDocuments {
+		resp.Error = err.Error()
+		return resp
+	}
+	if err == nil && existed.Project != codeSetting.ProjectName {
+		resp.Error = fmt.Sprintf("workflow %s already exists in project %s", workflow.Name, existed.Project)
+		return resp
+	}
+	if err != nil {
+		existed = nil
+	}
+
+	resp.Diff, err = workflowCodeDiff(existed, workflow, filePath)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	switch {
+	case existed == nil:
+		resp.Action = WorkflowCodeActionCreate
+	case resp.Diff != "" || existed.CodeSource == nil:
+		resp.Action = WorkflowCodeActionUpdate
+	default:
+		resp.Action = WorkflowCodeActionUnchanged
+	}
+	if dryRun || resp.Action == WorkflowCodeActionUnchanged {
+		return resp
+	}
+	comment := fmt.Sprintf("synced from %s of commit %s", filePath, commitSHA)
+
+	workflow.CodeSource = &commonmodels.WorkflowV4CodeSource{
+		CodehostID:    codeSetting.CodehostID,
+		RepoOwner:     codeSetting.RepoOwner,
+		RepoNamespace: codeSetting.GetNamespace(),
+		RepoName:      codeSetting.RepoName,
+		Branch:        codeSetting.Branch,
+		Path:          filePath,
+		CommitSHA:     commitSHA,
+		SyncTime:      time.Now().Unix(),
+	}
+	if existed == nil {
+		err = createWorkflowV4(user, comment, workflow, logger)
+	} else {
+		err = updateWorkflowV4(existed, user, comment, workflow, logger)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return resp
+}
+
+// loadWorkflowCodeFiles fetches the branch of the repo and returns the head commit and the yaml files in the workflow dir.
+func loadWorkflowCodeFiles(codeSetting *commonmodels.WorkflowCodeSetting) (string, map[string][]byte, error) {
+	detail, err := systemconfig.New().GetCodeHost(codeSetting.CodehostID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to find codehost %d: %v", codeSetting.CodehostID, err)
+	}
+	workDir := workflowCodeWorkDir(codeSetting)
+	if err := command.RunGitCmdsInDir(detail, codeSetting.RepoOwner, codeSetting.GetNamespace(), codeSetting.RepoName, codeSetting.Branch, "origin", workDir); err != nil {
+		return "", nil, fmt.Errorf("failed to fetch %s/%s:%s: %v", codeSetting.GetNamespace(), codeSetting.RepoName, codeSetting.Branch, err)
+	}
+
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = workDir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get the head commit: %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(workDir, codeSetting.Path))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read dir %s: %v", codeSetting.Path, err)
+	}
+	files := make(map[string][]byte)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(workDir, codeSetting.Path, entry.Name()))
+		if err != nil {
+			return "", nil, err
+		}
+		files[path.Join(codeSetting.Path, entry.Name())] = content
+	}
+	return strings.TrimSpace(string(out)), files, nil
+}
+
+// workflowCodeWorkDir returns the dir the repo is fetched into. It is not shared with the other features fetching
+// repos into the workspace named after the repo, and repos with the same name of different owners or codehosts
+// do not collide.
+func workflowCodeWorkDir(codeSetting *commonmodels.WorkflowCodeSetting) string {
+	return filepath.Join(config.S3StoragePath(), "workflow-code", fmt.Sprintf("%d", codeSetting.CodehostID), codeSetting.GetNamespace(), codeSetting.RepoName)
+}
+
+func sortedWorkflowCodePaths(files map[string][]byte) []string {
+	resp := make([]string, 0, len(files))
+	for filePath := range files {
+		resp = append(resp, filePath)
+	}
+	sort.Strings(resp)
+	return resp
+}
+
+// workflowCodeDiff returns the unified diff from the stored workflow to the one in the repo.
+func workflowCodeDiff(stored, incoming *commonmodels.WorkflowV4, filePath string) (string, error) {
+	from, to := "", ""
+	if stored != nil {
+		storedCopy := *stored
+		if err := jobctl.InstantiateWorkflow(&storedCopy); err != nil {
+			return "", err
+		}
+		content, err := normalizedWorkflowV4Yaml(&storedCopy)
+		if err != nil {
+			return "", err
+		}
+		from = content
+	}
+	incomingCopy := *incoming
+	content, err := normalizedWorkflowV4Yaml(&incomingCopy)
+	if err != nil {
+		return "", err
+	}
+	to = content
+	if from == to {
+		return "", nil
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: "zadig/" + incoming.Name,
+		ToFile:   filePath,
+		Context:  3,
+	})
+}
+
+// normalizedWorkflowV4Yaml returns the yaml of the workflow definition, the fields maintained by zadig are cleared.
+func normalizedWorkflowV4Yaml(workflow *commonmodels.WorkflowV4) (string, error) {
+	workflow.CreatedBy, workflow.CreateTime = "", 0
+	workflow.UpdatedBy, workflow.UpdateTime = "", 0
+	workflow.Hash = ""
+	content, err := yaml.Marshal(workflow)
+	if err != nil {
+		return "", err
+	}
+	// normalize the job specs by a round trip, the stored ones are instantiated to typed structs
+	normalized := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &normalized); err != nil {
+		return "", err
+	}
+	content, err = yaml.Marshal(normalized)
+	return string(content), err
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+var _ = Describe("Testing workflow code", func() {
+
+	Context("workflowCodeWorkDir", func() {
+		It("should not share the dir between repos of different owners or codehosts", func() {
+			base := &commonmodels.WorkflowCodeSetting{CodehostID: 1, RepoOwner: "a", RepoName: "ops"}
+			otherOwner := &commonmodels.WorkflowCodeSetting{CodehostID: 1, RepoOwner: "b", RepoName: "ops"}
+			otherCodehost := &commonmodels.WorkflowCodeSetting{CodehostID: 2, RepoOwner: "a", RepoName: "ops"}
+			subgroup := &commonmodels.WorkflowCodeSetting{CodehostID: 1, RepoOwner: "a", RepoNamespace: "a/b", RepoName: "ops"}
+
+			dirs := map[string]bool{}
+			for _, setting := range []*commonmodels.WorkflowCodeSetting{base, otherOwner, otherCodehost, subgroup} {
+				dirs[workflowCodeWorkDir(setting)] = true
+			}
+			Expect(dirs).To(HaveLen(4))
+			Expect(workflowCodeWorkDir(base)).To(HaveSuffix("workflow-code/1/a/ops"))
+		})
+	})
+})
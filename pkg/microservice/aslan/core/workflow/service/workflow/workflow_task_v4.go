@@ -17,6 +17,7 @@ limitations under the License.
 package workflow
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"math"
@@ -55,6 +56,7 @@ import (
 	larktool "github.com/koderover/zadig/pkg/tool/lark"
 	"github.com/koderover/zadig/pkg/tool/log"
 	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/tool/wecom"
 	"github.com/koderover/zadig/pkg/types"
 	jobspec "github.com/koderover/zadig/pkg/types/job"
 	"github.com/koderover/zadig/pkg/types/step"
@@ -89,6 +91,9 @@ type WorkflowTaskPreview struct {
 	Error               string                `bson:"error,omitempty"           json:"error,omitempty"`
 	IsRestart           bool                  `bson:"is_restart"                json:"is_restart"`
 	Debug               bool                  `bson:"debug"                     json:"debug"`
+	// SupersededBy is the id of the task which cancelled this task in the same concurrency group
+	SupersededBy int64  `bson:"superseded_by,omitempty"   json:"superseded_by,omitempty"`
+	CancelReason string `bson:"cancel_reason,omitempty"   json:"cancel_reason,omitempty"`
 }
 
 type StageTaskPreview struct {
@@ -296,6 +301,25 @@ func CheckWorkflowV4ApprovalInitiator(workflowName, uid string, log *zap.Sugared
 					}
 					isMobileChecked[stage.Approval.DingTalkApproval.ID] = true
 				}
+			case config.WeComApproval:
+				if stage.Approval.WeComApproval == nil {
+					continue
+				}
+				if initiator := stage.Approval.WeComApproval.DefaultApprovalInitiator; initiator == nil {
+					if isMobileChecked[stage.Approval.WeComApproval.ID] {
+						continue
+					}
+					app, err := commonrepo.NewIMAppColl().GetByID(context.Background(), stage.Approval.WeComApproval.ID)
+					if err != nil {
+						return errors.Errorf("failed to get wecom app info by id-%s", stage.Approval.WeComApproval.ID)
+					}
+					_, err = wecom.NewClient(app.WeComCorpID, app.WeComAgentSecret).GetUserIDByMobile(userInfo.Phone)
+					if err != nil {
+						return e.ErrCheckApprovalInitiator.AddDesc(fmt.Sprintf("wecom app id: %s, phone: %s, error: %v",
+							stage.Approval.WeComApproval.ID, userInfo.Phone, err))
+					}
+					isMobileChecked[stage.Approval.WeComApproval.ID] = true
+				}
 			}
 		}
 	}
@@ -346,6 +370,8 @@ func CreateWorkflowTaskV4(args *CreateWorkflowTaskV4Args, workflow *commonmodels
 		log.Errorf("cannot find workflow %s, the error is: %v", workflow.Name, err)
 		return nil, e.ErrFindWorkflow.AddDesc(err.Error())
 	}
+	// the concurrency group is a setting of the workflow, the workflow args of a run can not change it
+	workflow.ConcurrencyGroup = dbWorkflow.ConcurrencyGroup
 
 	if err := jobctl.InstantiateWorkflow(workflow); err != nil {
 		log.Errorf("instantiate workflow error: %s", err)
@@ -404,6 +430,25 @@ func CreateWorkflowTaskV4(args *CreateWorkflowTaskV4Args, workflow *commonmodels
 	workflowTask.ShareStorages = workflow.ShareStorages
 	workflowTask.IsDebug = workflow.Debug
 	workflowTask.WorkflowHash = fmt.Sprintf("%x", dbWorkflow.CalculateHash())
+	workflowTask.WorkflowRevision = dbWorkflow.Revision
+	// the workflow args of a manual run may not carry the priority class of the workflow
+	priorityClass := workflow.PriorityClass
+	if !priorityClass.Valid() {
+		priorityClass = dbWorkflow.PriorityClass
+	}
+	releaseAllowed := false
+	if priorityClass == config.PriorityClassRelease {
+		sysSetting, err := commonrepo.NewSystemSettingColl().Get()
+		if err != nil {
+			log.Errorf("get system settings error: %v", err)
+			return resp, e.ErrCreateTask.AddErr(err)
+		}
+		releaseAllowed = workflowcontroller.ReleasePriorityAllowed(sysSetting, workflow.Project)
+		if !releaseAllowed {
+			log.Warnf("project %s is not allowed to use the release priority class, workflow %s falls back to the default one", workflow.Project, workflow.Name)
+		}
+	}
+	workflowTask.PriorityClass = workflowcontroller.TaskPriorityClass(priorityClass, args.Name, releaseAllowed)
 	// set workflow params repo info, like commitid, branch etc.
 	setZadigParamRepos(workflow, log)
 	for _, stage := range workflow.Stages {
@@ -411,6 +456,8 @@ func CreateWorkflowTaskV4(args *CreateWorkflowTaskV4Args, workflow *commonmodels
 			Name:     stage.Name,
 			Parallel: stage.Parallel,
 			Approval: stage.Approval,
+			When:     stage.When,
+			RunOn:    stage.RunOn,
 		}
 		for _, job := range stage.Jobs {
 			if jobctl.JobSkiped(job) {
@@ -458,8 +505,20 @@ func CreateWorkflowTaskV4(args *CreateWorkflowTaskV4Args, workflow *commonmodels
 				log.Errorf("cannot create workflow %s, the error is: %v", workflow.Name, err)
 				return resp, e.ErrCreateTask.AddDesc(err.Error())
 			}
-			// add breakpoint_before when workflowTask is debug mode
+			jobs, err = jobctl.ExpandMatrix(job, jobs)
+			if err != nil {
+				log.Errorf("cannot create workflow %s, the error is: %v", workflow.Name, err)
+				return resp, e.ErrCreateTask.AddDesc(err.Error())
+			}
 			for _, jobTask := range jobs {
+				jobTask.OriginName = job.Name
+				jobTask.Needs = job.Needs
+				jobTask.When = job.When
+				if job.RetryPolicy != nil && job.RetryPolicy.MaxAttempts > 1 {
+					jobTask.RetryPolicy = job.RetryPolicy
+					jobTask.Retry = job.RetryPolicy.MaxAttempts - 1
+				}
+				// add breakpoint_before when workflowTask is debug mode
 				switch config.JobType(jobTask.JobType) {
 				case config.JobFreestyle, config.JobZadigTesting, config.JobZadigBuild, config.JobZadigScanning:
 					if workflowTask.IsDebug {
@@ -484,6 +543,7 @@ func CreateWorkflowTaskV4(args *CreateWorkflowTaskV4Args, workflow *commonmodels
 	workflow.MeegoHookCtls = nil
 	workflow.GeneralHookCtls = nil
 	workflowTask.WorkflowArgs = workflow
+	workflowTask.ConcurrencyGroupKey = renderConcurrencyGroupKey(workflow)
 	workflowTask.Status = config.StatusCreated
 	workflowTask.StartTime = time.Now().Unix()
 
@@ -501,6 +561,7 @@ func CreateWorkflowTaskV4(args *CreateWorkflowTaskV4Args, workflow *commonmodels
 		log.Errorf("create workflow task error: %v", err)
 		return resp, e.ErrCreateTask.AddDesc(err.Error())
 	}
+	supersedeWorkflowTasks(workflowTask, workflow.ConcurrencyGroup, log)
 	// Updating the comment in the git repository, this will not cause the function to return error if this function call fails
 	if err := scmnotify.NewService().UpdateWebhookCommentForWorkflowV4(workflowTask, log); err != nil {
 		log.Warnf("Failed to update comment for custom workflow %s, taskID: %d the error is: %s", workflowTask.WorkflowName, workflowTask.TaskID, err)
@@ -551,12 +612,38 @@ func RetryWorkflowTaskV4(workflowName string, taskID int64, logger *zap.SugaredL
 			if err != nil {
 				return errors.Errorf("job %s toJobs error: %s", job.Name, err)
 			}
+			// the job tasks of a matrix job are keyed by their combinations, like CreateWorkflowTaskV4
+			jobTasks, err = jobctl.ExpandMatrix(job, jobTasks)
+			if err != nil {
+				return errors.Errorf("job %s expand matrix error: %s", job.Name, err)
+			}
 			for _, jobTask := range jobTasks {
 				jobTaskMap[jobTask.Key] = jobTask
 			}
 		}
 	}
 
+	if err := resetStagesForRetry(task, jobTaskMap); err != nil {
+		return err
+	}
+
+	task.Status = config.StatusCreated
+	task.StartTime = time.Now().Unix()
+	if err := instantmessage.NewWeChatClient().SendWorkflowTaskNotifications(task); err != nil {
+		log.Errorf("send workflow task notification failed, error: %v", err)
+	}
+
+	if err := workflowcontroller.UpdateTask(task); err != nil {
+		log.Errorf("retry workflow task error: %v", err)
+		return e.ErrCreateTask.AddDesc(fmt.Sprintf("重试工作流任务失败: %s", err.Error()))
+	}
+
+	return nil
+}
+
+// resetStagesForRetry resets the unfinished stages and jobs of a task, the specs of the jobs to retry are
+// replaced with the ones in jobTaskMap, which is keyed by the job keys.
+func resetStagesForRetry(task *commonmodels.WorkflowTask, jobTaskMap map[string]*commonmodels.JobTask) error {
 	for i, stage := range task.Stages {
 		if stage.Status == config.StatusPassed {
 			continue
@@ -579,6 +666,8 @@ func RetryWorkflowTaskV4(workflowName string, taskID int64, logger *zap.SugaredL
 			jobTask.StartTime = 0
 			jobTask.EndTime = 0
 			jobTask.Error = ""
+			// a manual retry starts with a new automatic retry budget
+			jobTask.Attempts = nil
 			if t, ok := jobTaskMap[jobTask.Key]; ok {
 				jobTask.Spec = t.Spec
 			} else {
@@ -586,18 +675,6 @@ func RetryWorkflowTaskV4(workflowName string, taskID int64, logger *zap.SugaredL
 			}
 		}
 	}
-
-	task.Status = config.StatusCreated
-	task.StartTime = time.Now().Unix()
-	if err := instantmessage.NewWeChatClient().SendWorkflowTaskNotifications(task); err != nil {
-		log.Errorf("send workflow task notification failed, error: %v", err)
-	}
-
-	if err := workflowcontroller.UpdateTask(task); err != nil {
-		log.Errorf("retry workflow task error: %v", err)
-		return e.ErrCreateTask.AddDesc(fmt.Sprintf("重试工作流任务失败: %s", err.Error()))
-	}
-
 	return nil
 }
 
@@ -1149,6 +1226,80 @@ func CancelWorkflowTaskV4(userName, workflowName string, taskID int64, logger *z
 	return nil
 }
 
+// ListWorkflowTaskQueue lists the waiting tasks in the order they are going to be run, the positions are counted in
+// the whole queue even if the tasks are filtered by the project.
+func ListWorkflowTaskQueue(projectName string, logger *zap.SugaredLogger) ([]*workflowcontroller.QueuePosition, error) {
+	positions, err := workflowcontroller.WaitingTaskPositions()
+	if err != nil {
+		logger.Errorf("list workflow task queue error: %s", err)
+		return nil, e.ErrListTasks.AddErr(err)
+	}
+	if projectName == "" {
+		return positions, nil
+	}
+	resp := make([]*workflowcontroller.QueuePosition, 0)
+	for _, position := range positions {
+		if position.ProjectName == projectName {
+			resp = append(resp, position)
+		}
+	}
+	return resp, nil
+}
+
+func GetWorkflowTaskQueuePosition(workflowName string, taskID int64, logger *zap.SugaredLogger) (*workflowcontroller.QueuePosition, error) {
+	positions, err := workflowcontroller.WaitingTaskPositions()
+	if err != nil {
+		logger.Errorf("list workflow task queue error: %s", err)
+		return nil, e.ErrGetTask.AddErr(err)
+	}
+	for _, position := range positions {
+		if position.WorkflowName == workflowName && position.TaskID == taskID {
+			return position, nil
+		}
+	}
+	return nil, e.ErrGetTask.AddDesc(fmt.Sprintf("task %s-%d is not waiting in the queue", workflowName, taskID))
+}
+
+// UpdateWorkflowTaskPriority changes the priority class of a waiting task. Raising the priority delays the tasks of
+// the other projects, so only the system admin can do it, the others can only lower the priority.
+func UpdateWorkflowTaskPriority(workflowName string, taskID int64, priority config.PriorityClass, isSystemAdmin bool, logger *zap.SugaredLogger) error {
+	if !priority.Valid() {
+		return e.ErrUpdateTaskPriority.AddDesc(fmt.Sprintf("invalid priority class %s", priority))
+	}
+	if !isSystemAdmin {
+		waiting, err := commonrepo.NewWorkflowQueueColl().List(&commonrepo.ListWorfklowQueueOption{WorkflowName: workflowName, Status: config.StatusWaiting})
+		if err != nil {
+			logger.Errorf("list waiting tasks of workflow %s error: %s", workflowName, err)
+			return e.ErrUpdateTaskPriority.AddErr(err)
+		}
+		var current *commonmodels.WorkflowQueue
+		for _, t := range waiting {
+			if t.TaskID == taskID {
+				current = t
+			}
+		}
+		if current == nil {
+			return e.ErrUpdateTaskPriority.AddDesc("only the waiting task can change its priority")
+		}
+		if priority.Weight() > workflowcontroller.QueuePriorityClass(current).Weight() {
+			return e.ErrUpdateTaskPriority.AddDesc("only the system admin can raise the priority of a task")
+		}
+	}
+	updated, err := commonrepo.NewWorkflowQueueColl().UpdateWaitingPriority(workflowName, taskID, priority)
+	if err != nil {
+		logger.Errorf("update priority of workflow task %s-%d error: %s", workflowName, taskID, err)
+		return e.ErrUpdateTaskPriority.AddErr(err)
+	}
+	if !updated {
+		return e.ErrUpdateTaskPriority.AddDesc("only the waiting task can change its priority")
+	}
+	if err := commonrepo.NewworkflowTaskv4Coll().UpdatePriorityClass(workflowName, taskID, priority); err != nil {
+		logger.Errorf("update priority of workflow task %s-%d error: %s", workflowName, taskID, err)
+		return e.ErrUpdateTaskPriority.AddErr(err)
+	}
+	return nil
+}
+
 func GetWorkflowTaskV4(workflowName string, taskID int64, logger *zap.SugaredLogger) (*WorkflowTaskPreview, error) {
 	task, err := commonrepo.NewworkflowTaskv4Coll().Find(workflowName, taskID)
 	if err != nil {
@@ -1170,6 +1321,8 @@ func GetWorkflowTaskV4(workflowName string, taskID int64, logger *zap.SugaredLog
 		Error:               task.Error,
 		IsRestart:           task.IsRestart,
 		Debug:               task.IsDebug,
+		SupersededBy:        task.SupersededBy,
+		CancelReason:        task.CancelReason,
 	}
 	timeNow := time.Now().Unix()
 	for _, stage := range task.Stages {
@@ -1200,6 +1353,32 @@ func ApproveStage(workflowName, stageName, userName, userID, comment string, tas
 	return nil
 }
 
+func ApproveJob(workflowName, jobName, userName, userID, comment string, taskID int64, approve bool, logger *zap.SugaredLogger) error {
+	if workflowName == "" || jobName == "" || taskID == 0 {
+		errMsg := fmt.Sprintf("can not find approved workflow: %s, taskID: %d,job: %s", workflowName, taskID, jobName)
+		logger.Error(errMsg)
+		return e.ErrApproveTask.AddDesc(errMsg)
+	}
+	if err := workflowcontroller.ApproveJob(workflowName, jobName, userName, userID, comment, taskID, approve); err != nil {
+		logger.Error(err)
+		return e.ErrApproveTask.AddErr(err)
+	}
+	return nil
+}
+
+func SubmitManualInput(workflowName, jobName, userName, userID, comment string, taskID int64, inputs map[string]string, logger *zap.SugaredLogger) error {
+	if workflowName == "" || jobName == "" || taskID == 0 {
+		errMsg := fmt.Sprintf("can not find manual input workflow: %s, taskID: %d,job: %s", workflowName, taskID, jobName)
+		logger.Error(errMsg)
+		return e.ErrApproveTask.AddDesc(errMsg)
+	}
+	if err := workflowcontroller.SubmitManualInput(workflowName, jobName, userName, userID, comment, taskID, inputs); err != nil {
+		logger.Error(err)
+		return e.ErrApproveTask.AddErr(err)
+	}
+	return nil
+}
+
 func jobsToJobPreviews(jobs []*commonmodels.JobTask, context map[string]string, now int64, projectName string) []*JobTaskPreview {
 	resp := []*JobTaskPreview{}
 
@@ -1585,45 +1764,90 @@ func workflowTaskLint(workflowTask *commonmodels.WorkflowTask, logger *zap.Sugar
 
 		// deal with users in user groups
 		if stage.Approval != nil && stage.Approval.Type == config.NativeApproval && len(stage.Approval.NativeApproval.ApproveUsers) != 0 {
-			newApproveUserList := make([]*commonmodels.User, 0)
-			userSet := sets.NewString()
-			for _, approveUser := range stage.Approval.NativeApproval.ApproveUsers {
-				if approveUser.Type == "" || approveUser.Type == "user" {
-					newApproveUserList = append(newApproveUserList, approveUser)
-					userSet.Insert(approveUser.UserID)
-				}
+			approveUsers, err := expandApproveUsers(stage.Approval.NativeApproval.ApproveUsers)
+			if err != nil {
+				errMsg := fmt.Sprintf("failed to expand approve users in stage: %s, error: %s", stage.Name, err)
+				logger.Errorf(errMsg)
+				return e.ErrCreateTask.AddDesc(errMsg)
 			}
-			for _, approveUser := range stage.Approval.NativeApproval.ApproveUsers {
-				if approveUser.Type == "group" {
-					users, err := user.New().GetGroupDetailedInfo(approveUser.GroupID)
-					if err != nil {
-						errMsg := fmt.Sprintf("failed to find users for group %s in stage: %s, error: %s", approveUser.GroupName, stage.Name, err)
-						logger.Errorf(errMsg)
-						return e.ErrCreateTask.AddDesc(errMsg)
-					}
-					for _, userID := range users.UIDs {
-						if userSet.Has(userID) {
-							continue
-						}
-						userDetailedInfo, err := user.New().GetUserByID(userID)
-						if err != nil {
-							errMsg := fmt.Sprintf("failed to find user %s, error: %s", userID, err)
-							logger.Errorf(errMsg)
-							return e.ErrCreateTask.AddDesc(errMsg)
-						}
+			stage.Approval.NativeApproval.ApproveUsers = approveUsers
+		}
+		for _, job := range stage.Jobs {
+			if err := expandJobApproveUsers(job); err != nil {
+				errMsg := fmt.Sprintf("failed to expand approve users in job: %s, error: %s", job.Name, err)
+				logger.Errorf(errMsg)
+				return e.ErrCreateTask.AddDesc(errMsg)
+			}
+		}
+	}
+	return nil
+}
 
-						userSet.Insert(userID)
-						newApproveUserList = append(newApproveUserList, &commonmodels.User{
-							Type:     "user",
-							UserID:   userID,
-							UserName: userDetailedInfo.Name,
-						})
-					}
+// expandApproveUsers replaces the user groups with the users in them, duplicated users are removed.
+func expandApproveUsers(approveUsers []*commonmodels.User) ([]*commonmodels.User, error) {
+	newApproveUserList := make([]*commonmodels.User, 0)
+	userSet := sets.NewString()
+	for _, approveUser := range approveUsers {
+		if approveUser.Type == "" || approveUser.Type == "user" {
+			newApproveUserList = append(newApproveUserList, approveUser)
+			userSet.Insert(approveUser.UserID)
+		}
+	}
+	for _, approveUser := range approveUsers {
+		if approveUser.Type == "group" {
+			users, err := user.New().GetGroupDetailedInfo(approveUser.GroupID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find users for group %s, error: %s", approveUser.GroupName, err)
+			}
+			for _, userID := range users.UIDs {
+				if userSet.Has(userID) {
+					continue
 				}
+				userDetailedInfo, err := user.New().GetUserByID(userID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to find user %s, error: %s", userID, err)
+				}
+
+				userSet.Insert(userID)
+				newApproveUserList = append(newApproveUserList, &commonmodels.User{
+					Type:     "user",
+					UserID:   userID,
+					UserName: userDetailedInfo.Name,
+				})
 			}
-			stage.Approval.NativeApproval.ApproveUsers = newApproveUserList
 		}
 	}
+	return newApproveUserList, nil
+}
+
+// expandJobApproveUsers expands the user groups of the native approval jobs and the manual input jobs.
+func expandJobApproveUsers(job *commonmodels.JobTask) error {
+	switch job.JobType {
+	case string(config.JobApproval):
+		spec := &commonmodels.JobTaskApprovalSpec{}
+		if err := commonmodels.IToi(job.Spec, spec); err != nil {
+			return err
+		}
+		if spec.Approval != nil && spec.Approval.Type == config.NativeApproval && spec.Approval.NativeApproval != nil {
+			approveUsers, err := expandApproveUsers(spec.Approval.NativeApproval.ApproveUsers)
+			if err != nil {
+				return err
+			}
+			spec.Approval.NativeApproval.ApproveUsers = approveUsers
+		}
+		job.Spec = spec
+	case string(config.JobManualInput):
+		spec := &commonmodels.JobTaskManualInputSpec{}
+		if err := commonmodels.IToi(job.Spec, spec); err != nil {
+			return err
+		}
+		users, err := expandApproveUsers(spec.Users)
+		if err != nil {
+			return err
+		}
+		spec.Users = users
+		job.Spec = spec
+	}
 	return nil
 }
 
@@ -0,0 +1,106 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	jobctl "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow/job"
+)
+
+var _ = Describe("Testing workflow task v4 retry", func() {
+
+	matrixJob := func() *commonmodels.Job {
+		return &commonmodels.Job{
+			Name:    "unit-test",
+			JobType: config.JobFreestyle,
+			Spec: &commonmodels.FreestyleJobSpec{
+				Properties: &commonmodels.JobProperties{},
+				Matrix: &commonmodels.JobMatrix{
+					Axes: map[string][]string{
+						"GO_VERSION": {"1.20", "1.21"},
+						"OS":         {"linux"},
+					},
+				},
+			},
+		}
+	}
+	originJobTasks := func(envValue string) []*commonmodels.JobTask {
+		return []*commonmodels.JobTask{{
+			Name:    "unit-test",
+			Key:     "unit-test",
+			JobType: string(config.JobFreestyle),
+			Spec: &commonmodels.JobTaskFreestyleSpec{
+				Properties: commonmodels.JobProperties{
+					Envs: []*commonmodels.KeyVal{{Key: "ORIGIN", Value: envValue}},
+				},
+			},
+		}}
+	}
+	retryTask := func(jobTasks []*commonmodels.JobTask) *commonmodels.WorkflowTask {
+		return &commonmodels.WorkflowTask{
+			Status:             config.StatusFailed,
+			OriginWorkflowArgs: &commonmodels.WorkflowV4{Stages: []*commonmodels.WorkflowStage{{Name: "test"}}},
+			Stages: []*commonmodels.StageTask{{
+				Name:   "test",
+				Status: config.StatusFailed,
+				Jobs:   jobTasks,
+			}},
+		}
+	}
+
+	Context("resetStagesForRetry", func() {
+		It("should find the specs of the expanded matrix job tasks", func() {
+			ran, err := jobctl.ExpandMatrix(matrixJob(), originJobTasks("ran"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ran).To(HaveLen(2))
+			ran[0].Status = config.StatusPassed
+			ran[1].Status = config.StatusFailed
+			ran[1].Error = "exit code 1"
+			task := retryTask(ran)
+
+			retried, err := jobctl.ExpandMatrix(matrixJob(), originJobTasks("retried"))
+			Expect(err).NotTo(HaveOccurred())
+			jobTaskMap := map[string]*commonmodels.JobTask{}
+			for _, jobTask := range retried {
+				jobTaskMap[jobTask.Key] = jobTask
+			}
+
+			Expect(resetStagesForRetry(task, jobTaskMap)).To(Succeed())
+			Expect(task.Stages[0].Status).To(BeEmpty())
+			Expect(task.Stages[0].Jobs[0].Status).To(Equal(config.StatusPassed))
+			Expect(task.Stages[0].Jobs[0].Spec.(*commonmodels.JobTaskFreestyleSpec).Properties.Envs[0].Value).To(Equal("ran"))
+			Expect(task.Stages[0].Jobs[1].Status).To(BeEmpty())
+			Expect(task.Stages[0].Jobs[1].Error).To(BeEmpty())
+			Expect(task.Stages[0].Jobs[1].Spec).To(BeIdenticalTo(jobTaskMap[task.Stages[0].Jobs[1].Key].Spec))
+		})
+		It("should fail if the job tasks are not expanded", func() {
+			ran, err := jobctl.ExpandMatrix(matrixJob(), originJobTasks("ran"))
+			Expect(err).NotTo(HaveOccurred())
+			task := retryTask(ran)
+
+			jobTaskMap := map[string]*commonmodels.JobTask{}
+			for _, jobTask := range originJobTasks("retried") {
+				jobTaskMap[jobTask.Key] = jobTask
+			}
+			Expect(resetStagesForRetry(task, jobTaskMap)).To(MatchError(ContainSubstring("origin spec")))
+		})
+	})
+})
@@ -64,6 +64,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/repository"
 	commomtemplate "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/template"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/webhook"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller"
 	commontypes "github.com/koderover/zadig/pkg/microservice/aslan/core/common/types"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow/job"
 	jobctl "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow/job"
@@ -83,6 +84,10 @@ import (
 )
 
 func CreateWorkflowV4(user string, workflow *commonmodels.WorkflowV4, logger *zap.SugaredLogger) error {
+	return createWorkflowV4(user, "", workflow, logger)
+}
+
+func createWorkflowV4(user, comment string, workflow *commonmodels.WorkflowV4, logger *zap.SugaredLogger) error {
 	existedWorkflow, err := commonrepo.NewWorkflowV4Coll().Find(workflow.Name)
 	if err == nil {
 		errStr := fmt.Sprintf("与项目 [%s] 中的工作流 [%s] 标识相同", existedWorkflow.Project, existedWorkflow.DisplayName)
@@ -111,12 +116,16 @@ func CreateWorkflowV4(user string, workflow *commonmodels.WorkflowV4, logger *za
 		logger.Errorf("instantiate workflow error: %s", err)
 		return e.ErrUpsertWorkflow.AddErr(err)
 	}
+	if err := setNextWorkflowV4Revision(workflow); err != nil {
+		logger.Errorf("Failed to get the next revision of workflow %s, the error is: %s", workflow.Name, err)
+		return e.ErrUpsertWorkflow.AddErr(err)
+	}
 
 	if _, err := commonrepo.NewWorkflowV4Coll().Create(workflow); err != nil {
 		logger.Errorf("Failed to create workflow v4, the error is: %s", err)
 		return e.ErrUpsertWorkflow.AddErr(err)
 	}
-	return nil
+	return createWorkflowV4Revision(workflow, user, comment, logger)
 }
 
 func SetWorkflowTasksCustomFields(projectName, workflowName string, args *models.CustomField, logger *zap.SugaredLogger) error {
@@ -244,12 +253,20 @@ func GetWorkflowTasksCustomFields(projectName, workflowName string, logger *zap.
 	return fields, nil
 }
 
-func UpdateWorkflowV4(name, user string, inputWorkflow *commonmodels.WorkflowV4, logger *zap.SugaredLogger) error {
+func UpdateWorkflowV4(name, user, comment string, inputWorkflow *commonmodels.WorkflowV4, logger *zap.SugaredLogger) error {
 	workflow, err := commonrepo.NewWorkflowV4Coll().Find(name)
 	if err != nil {
 		logger.Errorf("Failed to find WorkflowV4: %s, the error is: %v", name, err)
 		return e.ErrFindWorkflow.AddErr(err)
 	}
+	if workflow.CodeSource != nil {
+		errStr := fmt.Sprintf("工作流由代码库 %s/%s 的 %s 管理, 请在代码库中修改", workflow.CodeSource.RepoNamespace, workflow.CodeSource.RepoName, workflow.CodeSource.Path)
+		return e.ErrUpsertWorkflow.AddDesc(errStr)
+	}
+	return updateWorkflowV4(workflow, user, comment, inputWorkflow, logger)
+}
+
+func updateWorkflowV4(workflow *commonmodels.WorkflowV4, user, comment string, inputWorkflow *commonmodels.WorkflowV4, logger *zap.SugaredLogger) error {
 	if workflow.DisplayName != inputWorkflow.DisplayName {
 		existedWorkflows, _, _ := commonrepo.NewWorkflowV4Coll().List(&commonrepo.ListWorkflowV4Option{ProjectName: workflow.Project, DisplayName: inputWorkflow.DisplayName}, 0, 0)
 		if len(existedWorkflows) > 0 {
@@ -283,6 +300,10 @@ func UpdateWorkflowV4(name, user string, inputWorkflow *commonmodels.WorkflowV4,
 	if err := createLarkApprovalDefinition(inputWorkflow); err != nil {
 		return errors.Wrap(err, "create lark approval definition")
 	}
+	if err := setNextWorkflowV4Revision(inputWorkflow); err != nil {
+		logger.Errorf("Failed to get the next revision of workflow %s, the error is: %s", inputWorkflow.Name, err)
+		return e.ErrUpsertWorkflow.AddErr(err)
+	}
 
 	if err := commonrepo.NewWorkflowV4Coll().Update(
 		workflow.ID.Hex(),
@@ -291,7 +312,7 @@ func UpdateWorkflowV4(name, user string, inputWorkflow *commonmodels.WorkflowV4,
 		logger.Errorf("update workflowV4 error: %s", err)
 		return e.ErrUpsertWorkflow.AddErr(err)
 	}
-	return nil
+	return createWorkflowV4Revision(inputWorkflow, user, comment, logger)
 }
 
 func FindWorkflowV4(encryptedKey, name string, logger *zap.SugaredLogger) (*commonmodels.WorkflowV4, error) {
@@ -337,6 +358,12 @@ func DeleteWorkflowV4(name string, logger *zap.SugaredLogger) error {
 	if err := commonrepo.NewCounterColl().Delete("WorkflowTaskV4:" + name); err != nil {
 		log.Errorf("Counter.Delete error: %s", err)
 	}
+	if err := commonrepo.NewWorkflowV4RevisionColl().DeleteByWorkflowName(name); err != nil {
+		log.Errorf("Failed to delete revisions of WorkflowV4: %s, the error is: %v", name, err)
+	}
+	if err := commonrepo.NewCounterColl().Delete(fmt.Sprintf(setting.WorkflowV4RevFmt, name)); err != nil {
+		log.Errorf("Counter.Delete error: %s", err)
+	}
 	return nil
 }
 
@@ -857,6 +884,12 @@ func LintWorkflowV4(workflow *commonmodels.WorkflowV4, logger *zap.SugaredLogger
 		logger.Error(errMsg)
 		return e.ErrUpsertWorkflow.AddDesc(errMsg)
 	}
+	if workflow.PriorityClass != "" && !workflow.PriorityClass.Valid() {
+		return e.ErrUpsertWorkflow.AddDesc(fmt.Sprintf("invalid priority class %s", workflow.PriorityClass))
+	}
+	if err := lintConcurrencyGroup(workflow.ConcurrencyGroup); err != nil {
+		return e.ErrUpsertWorkflow.AddErr(err)
+	}
 
 	project := &template.Product{}
 	// for deploy center workflow, it doesn't belongs to any project, so we use a specical project name to distinguish it.
@@ -893,6 +926,18 @@ func LintWorkflowV4(workflow *commonmodels.WorkflowV4, logger *zap.SugaredLogger
 			logger.Errorf("stage: %s approval info error: %v", stage.Name, err)
 			return e.ErrUpsertWorkflow.AddDesc(fmt.Sprintf("stage: %s approval info error: %v", stage.Name, err))
 		}
+		switch stage.RunOn {
+		case config.StageRunOnMain, config.StageRunOnFailure, config.StageRunOnCancel, config.StageRunOnAlways:
+		default:
+			logger.Errorf("stage: %s run_on %s is not supported", stage.Name, stage.RunOn)
+			return e.ErrUpsertWorkflow.AddDesc(fmt.Sprintf("stage: %s run_on %s is not supported", stage.Name, stage.RunOn))
+		}
+		if stage.When != "" {
+			if err := workflowcontroller.LintCondition(stage.When); err != nil {
+				logger.Errorf("stage: %s condition error: %v", stage.Name, err)
+				return e.ErrUpsertWorkflow.AddDesc(fmt.Sprintf("stage: %s condition error: %v", stage.Name, err))
+			}
+		}
 		if _, ok := stageNameMap[stage.Name]; !ok {
 			stageNameMap[stage.Name] = true
 		} else {
@@ -910,10 +955,94 @@ func LintWorkflowV4(workflow *commonmodels.WorkflowV4, logger *zap.SugaredLogger
 				logger.Errorf("duplicated job name: %s", job.Name)
 				return e.ErrUpsertWorkflow.AddDesc(fmt.Sprintf("duplicated job name: %s", job.Name))
 			}
+			if job.When != "" {
+				if err := workflowcontroller.LintCondition(job.When); err != nil {
+					logger.Errorf("job: %s condition error: %v", job.Name, err)
+					return e.ErrUpsertWorkflow.AddDesc(fmt.Sprintf("job: %s condition error: %v", job.Name, err))
+				}
+			}
+			if err := lintJobRetryPolicy(job.RetryPolicy); err != nil {
+				logger.Errorf("job: %s retry policy error: %v", job.Name, err)
+				return e.ErrUpsertWorkflow.AddDesc(fmt.Sprintf("job: %s retry policy error: %v", job.Name, err))
+			}
 			if err := jobctl.LintJob(job, workflow); err != nil {
 				logger.Errorf("lint job %s failed: %v", job.Name, err)
 				return e.ErrUpsertWorkflow.AddErr(err)
 			}
+			if job.JobType == config.JobApproval {
+				spec := &commonmodels.ApprovalJobSpec{}
+				if err := commonmodels.IToiYaml(job.Spec, spec); err != nil {
+					return e.ErrUpsertWorkflow.AddErr(err)
+				}
+				if err := lintApprovals(spec.ToApproval()); err != nil {
+					logger.Errorf("job: %s approval info error: %v", job.Name, err)
+					return e.ErrUpsertWorkflow.AddDesc(fmt.Sprintf("job: %s approval info error: %v", job.Name, err))
+				}
+			}
+		}
+	}
+	if err := lintFinalStages(workflow.Stages); err != nil {
+		logger.Errorf("lint final stages failed: %v", err)
+		return e.ErrUpsertWorkflow.AddDesc(err.Error())
+	}
+	if err := lintJobNeeds(workflow); err != nil {
+		logger.Errorf("lint job needs failed: %v", err)
+		return e.ErrUpsertWorkflow.AddDesc(err.Error())
+	}
+	return nil
+}
+
+func lintJobNeeds(workflow *commonmodels.WorkflowV4) error {
+	nodes := make([]*workflowcontroller.JobNode, 0)
+	for i, stage := range workflow.Stages {
+		for _, job := range stage.Jobs {
+			nodes = append(nodes, &workflowcontroller.JobNode{Name: job.Name, Needs: job.Needs, Stage: i, Parallel: stage.Parallel})
+		}
+	}
+	deps, err := workflowcontroller.JobDependencies(nodes, false)
+	if err != nil {
+		return err
+	}
+	return workflowcontroller.CheckJobCycle(nodes, deps)
+}
+
+// lintFinalStages checks that the final stages are placed after all the main stages, they only run after
+// the main stages finished.
+func lintFinalStages(stages []*commonmodels.WorkflowStage) error {
+	finalStage := ""
+	for _, stage := range stages {
+		if stage.RunOn != config.StageRunOnMain {
+			if finalStage == "" {
+				finalStage = stage.Name
+			}
+			continue
+		}
+		if finalStage != "" {
+			return errors.Errorf("final stage %s should be placed after the main stage %s", finalStage, stage.Name)
+		}
+	}
+	return nil
+}
+
+func lintJobRetryPolicy(policy *commonmodels.JobRetryPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.MaxAttempts < 1 || policy.MaxAttempts > commonmodels.MaxJobRetryAttempts {
+		return errors.Errorf("max attempts should be between 1 and %d", commonmodels.MaxJobRetryAttempts)
+	}
+	if policy.Interval < 0 || policy.Backoff < 0 {
+		return errors.New("interval and backoff should not be negative")
+	}
+	if policy.Interval > commonmodels.MaxJobRetryInterval {
+		return errors.Errorf("interval should not be greater than %d seconds", commonmodels.MaxJobRetryInterval)
+	}
+	if policy.Backoff > commonmodels.MaxJobRetryBackoff {
+		return errors.Errorf("backoff should not be greater than %d", commonmodels.MaxJobRetryBackoff)
+	}
+	for _, status := range policy.RetryOn {
+		if status != config.StatusFailed && status != config.StatusTimeout {
+			return errors.Errorf("can not retry on status %s, only failed and timeout are supported", status)
 		}
 	}
 	return nil
@@ -927,6 +1056,9 @@ func lintApprovals(approval *commonmodels.Approval) error {
 		if approval.Type == config.LarkApproval || approval.Type == config.DingTalkApproval {
 			return e.ErrLicenseInvalid.AddDesc("飞书和钉钉审批是专业版功能")
 		}
+		if approval.Type == config.WeComApproval || approval.Type == config.WebhookApproval {
+			return e.ErrLicenseInvalid.AddDesc("企业微信和 Webhook 审批是专业版功能")
+		}
 	}
 	if !approval.Enabled {
 		return nil
@@ -979,6 +1111,31 @@ func lintApprovals(approval *commonmodels.Approval) error {
 				return errors.Errorf("approval-node %d type should be AND or OR", i)
 			}
 		}
+	case config.WeComApproval:
+		if approval.WeComApproval == nil {
+			return errors.New("approval not found")
+		}
+		if approval.WeComApproval.ID == "" {
+			return errors.New("wecom approval app is not set")
+		}
+		if len(approval.WeComApproval.ApprovalNodes) == 0 {
+			return errors.New("num of approval-node is 0")
+		}
+		for i, node := range approval.WeComApproval.ApprovalNodes {
+			if len(node.ApproveUsers) == 0 {
+				return errors.Errorf("num of approval-node %d approver is 0", i)
+			}
+			if !lo.Contains([]string{"AND", "OR"}, node.Type) {
+				return errors.Errorf("approval-node %d type should be AND or OR", i)
+			}
+		}
+	case config.WebhookApproval:
+		if approval.WebhookApproval == nil {
+			return errors.New("approval not found")
+		}
+		if approval.WebhookApproval.ID == "" {
+			return errors.New("webhook approval app is not set")
+		}
 	default:
 		return errors.Errorf("invalid approval type %s", approval.Type)
 	}
@@ -986,18 +1143,37 @@ func lintApprovals(approval *commonmodels.Approval) error {
 	return nil
 }
 
-func createLarkApprovalDefinition(workflow *commonmodels.WorkflowV4) error {
+// workflowApprovals returns the approvals of the stages and the approval jobs.
+func workflowApprovals(workflow *commonmodels.WorkflowV4) []*commonmodels.Approval {
+	approvals := make([]*commonmodels.Approval, 0)
 	for _, stage := range workflow.Stages {
-		if stage.Approval == nil {
-			continue
+		if stage.Approval != nil {
+			approvals = append(approvals, stage.Approval)
 		}
-		if data := stage.Approval.LarkApproval; data != nil && data.ID != "" {
-			larkInfo, err := commonrepo.NewIMAppColl().GetByID(context.Background(), stage.Approval.LarkApproval.ID)
+		for _, job := range stage.Jobs {
+			if job.JobType != config.JobApproval {
+				continue
+			}
+			spec := &commonmodels.ApprovalJobSpec{}
+			if err := commonmodels.IToiYaml(job.Spec, spec); err != nil {
+				log.Errorf("failed to decode approval job %s, error: %v", job.Name, err)
+				continue
+			}
+			approvals = append(approvals, spec.ToApproval())
+		}
+	}
+	return approvals
+}
+
+func createLarkApprovalDefinition(workflow *commonmodels.WorkflowV4) error {
+	for _, approval := range workflowApprovals(workflow) {
+		if data := approval.LarkApproval; data != nil && data.ID != "" {
+			larkInfo, err := commonrepo.NewIMAppColl().GetByID(context.Background(), data.ID)
 			if err != nil {
-				return errors.Wrapf(err, "get lark app %s", stage.Approval.LarkApproval.ID)
+				return errors.Wrapf(err, "get lark app %s", data.ID)
 			}
 			if larkInfo.Type != string(config.LarkApproval) {
-				return errors.Errorf("lark app %s is not lark approval", stage.Approval.LarkApproval.ID)
+				return errors.Errorf("lark app %s is not lark approval", data.ID)
 			}
 
 			if larkInfo.LarkApprovalCodeList == nil {
@@ -1042,7 +1218,7 @@ func createLarkApprovalDefinition(workflow *commonmodels.WorkflowV4) error {
 				return errors.Wrap(err, "subscribe lark approval definition")
 			}
 			larkInfo.LarkApprovalCodeList[data.GetNodeTypeKey()] = approvalCode
-			if err := commonrepo.NewIMAppColl().Update(context.Background(), stage.Approval.LarkApproval.ID, larkInfo); err != nil {
+			if err := commonrepo.NewIMAppColl().Update(context.Background(), data.ID, larkInfo); err != nil {
 				return errors.Wrap(err, "update lark approval data")
 			}
 			log.Infof("create lark approval definition %s, key: %s", approvalCode, data.GetNodeTypeKey())
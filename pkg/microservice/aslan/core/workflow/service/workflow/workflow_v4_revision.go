@@ -0,0 +1,281 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	jobctl "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow/job"
+	"github.com/koderover/zadig/pkg/setting"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+const (
+	WorkflowV4ChangeAdded    = "added"
+	WorkflowV4ChangeRemoved  = "removed"
+	WorkflowV4ChangeModified = "modified"
+)
+
+type WorkflowV4RevisionDiff struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+	// Diff is the unified diff of the yaml of the two revisions.
+	Diff    string                   `json:"diff"`
+	Changes []*WorkflowV4FieldChange `json:"changes"`
+}
+
+// WorkflowV4FieldChange is a changed field of the workflow, list items with names are identified by the names,
+// e.g. stages[name=build].jobs[name=build-job].spec.docker_registry_id
+type WorkflowV4FieldChange struct {
+	Path string      `json:"path"`
+	Type string      `json:"type"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+func setNextWorkflowV4Revision(workflow *commonmodels.WorkflowV4) error {
+	revision, err := commonrepo.NewCounterColl().GetNextSeq(fmt.Sprintf(setting.WorkflowV4RevFmt, workflow.Name))
+	if err != nil {
+		return err
+	}
+	workflow.Revision = revision
+	return nil
+}
+
+// createWorkflowV4Revision saves the definition of the workflow as its current revision.
+func createWorkflowV4Revision(workflow *commonmodels.WorkflowV4, user, comment string, logger *zap.SugaredLogger) error {
+	revision := &commonmodels.WorkflowV4Revision{
+		WorkflowName: workflow.Name,
+		ProjectName:  workflow.Project,
+		Revision:     workflow.Revision,
+		Hash:         fmt.Sprintf("%x", workflow.CalculateHash()),
+		Comment:      comment,
+		CreatedBy:    user,
+		CreateTime:   time.Now().Unix(),
+		Workflow:     workflowV4RevisionSnapshot(workflow),
+	}
+	if err := commonrepo.NewWorkflowV4RevisionColl().Create(revision); err != nil {
+		logger.Errorf("Failed to create revision %d of workflow %s, the error is: %s", workflow.Revision, workflow.Name, err)
+		return e.ErrUpsertWorkflow.AddErr(err)
+	}
+	return nil
+}
+
+func ListWorkflowV4Revisions(workflowName string, pageNum, pageSize int64, logger *zap.SugaredLogger) ([]*commonmodels.WorkflowV4Revision, int64, error) {
+	resp, total, err := commonrepo.NewWorkflowV4RevisionColl().List(workflowName, pageNum, pageSize)
+	if err != nil {
+		logger.Errorf("Failed to list revisions of workflow %s, the error is: %s", workflowName, err)
+		return nil, 0, e.ErrFindWorkflow.AddErr(err)
+	}
+	return resp, total, nil
+}
+
+func GetWorkflowV4Revision(workflowName string, revision int64, logger *zap.SugaredLogger) (*commonmodels.WorkflowV4Revision, error) {
+	resp, err := commonrepo.NewWorkflowV4RevisionColl().Find(workflowName, revision)
+	if err != nil {
+		logger.Errorf("Failed to find revision %d of workflow %s, the error is: %s", revision, workflowName, err)
+		return nil, e.ErrFindWorkflow.AddErr(err)
+	}
+	if err := jobctl.InstantiateWorkflow(resp.Workflow); err != nil {
+		logger.Errorf("instantiate workflow error: %s", err)
+		return nil, e.ErrFindWorkflow.AddErr(err)
+	}
+	return resp, nil
+}
+
+func DiffWorkflowV4Revisions(workflowName string, from, to int64, logger *zap.SugaredLogger) (*WorkflowV4RevisionDiff, error) {
+	fromRevision, err := GetWorkflowV4Revision(workflowName, from, logger)
+	if err != nil {
+		return nil, err
+	}
+	toRevision, err := GetWorkflowV4Revision(workflowName, to, logger)
+	if err != nil {
+		return nil, err
+	}
+	fromYaml, err := normalizedWorkflowV4Yaml(fromRevision.Workflow)
+	if err != nil {
+		return nil, e.ErrFindWorkflow.AddErr(err)
+	}
+	toYaml, err := normalizedWorkflowV4Yaml(toRevision.Workflow)
+	if err != nil {
+		return nil, e.ErrFindWorkflow.AddErr(err)
+	}
+
+	resp := &WorkflowV4RevisionDiff{From: from, To: to, Changes: make([]*WorkflowV4FieldChange, 0)}
+	resp.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(fromYaml),
+		B:        difflib.SplitLines(toYaml),
+		FromFile: fmt.Sprintf("%s@%d", workflowName, from),
+		ToFile:   fmt.Sprintf("%s@%d", workflowName, to),
+		Context:  3,
+	})
+	if err != nil {
+		return nil, e.ErrFindWorkflow.AddErr(err)
+	}
+
+	var fromValue, toValue interface{}
+	if err := yaml.Unmarshal([]byte(fromYaml), &fromValue); err != nil {
+		return nil, e.ErrFindWorkflow.AddErr(err)
+	}
+	if err := yaml.Unmarshal([]byte(toYaml), &toValue); err != nil {
+		return nil, e.ErrFindWorkflow.AddErr(err)
+	}
+	diffYamlValues("", fromValue, toValue, &resp.Changes)
+	return resp, nil
+}
+
+// workflowV4RevisionSnapshot returns the definition of the workflow kept in a revision. The hooks and the code
+// source are not part of the definition since they are maintained by their own APIs and the code sync.
+func workflowV4RevisionSnapshot(workflow *commonmodels.WorkflowV4) *commonmodels.WorkflowV4 {
+	snapshot := *workflow
+	snapshot.ID = primitive.NilObjectID
+	snapshot.HookCtls = nil
+	snapshot.JiraHookCtls = nil
+	snapshot.MeegoHookCtls = nil
+	snapshot.GeneralHookCtls = nil
+	snapshot.CustomField = nil
+	snapshot.CodeSource = nil
+	return &snapshot
+}
+
+// RestoreWorkflowV4Revision saves the definition of the revision as a new revision of the workflow.
+func RestoreWorkflowV4Revision(workflowName string, revision int64, user string, logger *zap.SugaredLogger) error {
+	workflow, err := commonrepo.NewWorkflowV4Coll().Find(workflowName)
+	if err != nil {
+		logger.Errorf("Failed to find WorkflowV4: %s, the error is: %v", workflowName, err)
+		return e.ErrFindWorkflow.AddErr(err)
+	}
+	if workflow.CodeSource != nil {
+		return e.ErrUpsertWorkflow.AddDesc("工作流由代码库管理, 请在代码库中回滚")
+	}
+	target, err := GetWorkflowV4Revision(workflowName, revision, logger)
+	if err != nil {
+		return err
+	}
+	// revisions saved while the workflow was synced from the repo may still have the code source,
+	// restoring one of them must not bring the workflow back under the control of the repo
+	target.Workflow.CodeSource = workflow.CodeSource
+	return updateWorkflowV4(workflow, user, fmt.Sprintf("restored from revision %d", revision), target.Workflow, logger)
+}
+
+func diffYamlValues(path string, from, to interface{}, changes *[]*WorkflowV4FieldChange) {
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap {
+		keys := make([]string, 0)
+		for key := range fromMap {
+			keys = append(keys, key)
+		}
+		for key := range toMap {
+			if _, ok := fromMap[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			fromValue, fromOK := fromMap[key]
+			toValue, toOK := toMap[key]
+			switch {
+			case !fromOK:
+				*changes = append(*changes, &WorkflowV4FieldChange{Path: fieldPath, Type: WorkflowV4ChangeAdded, To: toValue})
+			case !toOK:
+				*changes = append(*changes, &WorkflowV4FieldChange{Path: fieldPath, Type: WorkflowV4ChangeRemoved, From: fromValue})
+			default:
+				diffYamlValues(fieldPath, fromValue, toValue, changes)
+			}
+		}
+		return
+	}
+
+	fromList, fromIsList := from.([]interface{})
+	toList, toIsList := to.([]interface{})
+	if fromIsList && toIsList {
+		fromNames, fromNamed := namedYamlItems(fromList)
+		toNames, toNamed := namedYamlItems(toList)
+		if !fromNamed || !toNamed {
+			for i := 0; i < len(fromList) || i < len(toList); i++ {
+				itemPath := fmt.Sprintf("%s[%d]", path, i)
+				switch {
+				case i >= len(fromList):
+					*changes = append(*changes, &WorkflowV4FieldChange{Path: itemPath, Type: WorkflowV4ChangeAdded, To: toList[i]})
+				case i >= len(toList):
+					*changes = append(*changes, &WorkflowV4FieldChange{Path: itemPath, Type: WorkflowV4ChangeRemoved, From: fromList[i]})
+				default:
+					diffYamlValues(itemPath, fromList[i], toList[i], changes)
+				}
+			}
+			return
+		}
+		toIndex := map[string]int{}
+		for i, name := range toNames {
+			toIndex[name] = i
+		}
+		fromIndex := map[string]int{}
+		for i, name := range fromNames {
+			fromIndex[name] = i
+			itemPath := fmt.Sprintf("%s[name=%s]", path, name)
+			if j, ok := toIndex[name]; ok {
+				diffYamlValues(itemPath, fromList[i], toList[j], changes)
+			} else {
+				*changes = append(*changes, &WorkflowV4FieldChange{Path: itemPath, Type: WorkflowV4ChangeRemoved, From: fromList[i]})
+			}
+		}
+		for j, name := range toNames {
+			if _, ok := fromIndex[name]; !ok {
+				*changes = append(*changes, &WorkflowV4FieldChange{Path: fmt.Sprintf("%s[name=%s]", path, name), Type: WorkflowV4ChangeAdded, To: toList[j]})
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, &WorkflowV4FieldChange{Path: path, Type: WorkflowV4ChangeModified, From: from, To: to})
+	}
+}
+
+// namedYamlItems returns the names of the items if every item is a map with a unique name.
+func namedYamlItems(items []interface{}) ([]string, bool) {
+	names := make([]string, 0, len(items))
+	seen := map[string]bool{}
+	for _, item := range items {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := itemMap["name"].(string)
+		if !ok || name == "" || seen[name] {
+			return nil, false
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, true
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v3"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+var _ = Describe("Testing workflow v4 revision", func() {
+
+	diff := func(from, to string) []*WorkflowV4FieldChange {
+		var fromValue, toValue interface{}
+		Expect(yaml.Unmarshal([]byte(from), &fromValue)).To(Succeed())
+		Expect(yaml.Unmarshal([]byte(to), &toValue)).To(Succeed())
+		changes := make([]*WorkflowV4FieldChange, 0)
+		diffYamlValues("", fromValue, toValue, &changes)
+		return changes
+	}
+
+	Context("diffYamlValues", func() {
+		It("should find no change for the same definitions", func() {
+			changes := diff("name: a\nstages:\n- name: build\n", "name: a\nstages:\n- name: build\n")
+			Expect(changes).To(BeEmpty())
+		})
+		It("should match named items by their names", func() {
+			from := `
+stages:
+- name: build
+  jobs:
+  - name: build-job
+    spec:
+      registry: a
+- name: deploy
+`
+			to := `
+stages:
+- name: deploy
+- name: build
+  jobs:
+  - name: build-job
+    spec:
+      registry: b
+- name: test
+`
+			changes := diff(from, to)
+			Expect(changes).To(HaveLen(2))
+			Expect(changes[0].Path).To(Equal("stages[name=build].jobs[name=build-job].spec.registry"))
+			Expect(changes[0].Type).To(Equal(WorkflowV4ChangeModified))
+			Expect(changes[0].From).To(Equal("a"))
+			Expect(changes[0].To).To(Equal("b"))
+			Expect(changes[1].Path).To(Equal("stages[name=test]"))
+			Expect(changes[1].Type).To(Equal(WorkflowV4ChangeAdded))
+		})
+		It("should compare unnamed items by index", func() {
+			changes := diff("params:\n- a\n- b\n", "params:\n- a\ndescription: x\n")
+			Expect(changes).To(HaveLen(2))
+			Expect(changes[0].Path).To(Equal("description"))
+			Expect(changes[0].Type).To(Equal(WorkflowV4ChangeAdded))
+			Expect(changes[1].Path).To(Equal("params[1]"))
+			Expect(changes[1].Type).To(Equal(WorkflowV4ChangeRemoved))
+		})
+	})
+
+	Context("workflowV4RevisionSnapshot", func() {
+		It("should not keep the hooks and the code source", func() {
+			workflow := &commonmodels.WorkflowV4{
+				ID:          primitive.NewObjectID(),
+				Name:        "a",
+				HookCtls:    []*commonmodels.WorkflowV4Hook{{Name: "hook"}},
+				CustomField: &commonmodels.CustomField{},
+				CodeSource:  &commonmodels.WorkflowV4CodeSource{RepoName: "ops", Path: "workflows/a.yaml"},
+			}
+			snapshot := workflowV4RevisionSnapshot(workflow)
+			Expect(snapshot.Name).To(Equal("a"))
+			Expect(snapshot.ID.IsZero()).To(BeTrue())
+			Expect(snapshot.HookCtls).To(BeNil())
+			Expect(snapshot.CustomField).To(BeNil())
+			Expect(snapshot.CodeSource).To(BeNil())
+			Expect(workflow.CodeSource).NotTo(BeNil())
+		})
+	})
+})
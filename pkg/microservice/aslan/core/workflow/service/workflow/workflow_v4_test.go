@@ -0,0 +1,79 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+var _ = Describe("Testing workflow v4 lint", func() {
+
+	Context("lintFinalStages", func() {
+		stage := func(name string, runOn config.StageRunOn) *commonmodels.WorkflowStage {
+			return &commonmodels.WorkflowStage{Name: name, RunOn: runOn}
+		}
+		It("should accept the final stages after the main stages", func() {
+			Expect(lintFinalStages(nil)).To(Succeed())
+			Expect(lintFinalStages([]*commonmodels.WorkflowStage{stage("build", config.StageRunOnMain), stage("deploy", config.StageRunOnMain)})).To(Succeed())
+			Expect(lintFinalStages([]*commonmodels.WorkflowStage{
+				stage("build", config.StageRunOnMain),
+				stage("notify", config.StageRunOnFailure),
+				stage("cleanup", config.StageRunOnAlways),
+			})).To(Succeed())
+		})
+		It("should reject a final stage before a main stage", func() {
+			err := lintFinalStages([]*commonmodels.WorkflowStage{
+				stage("build", config.StageRunOnMain),
+				stage("cleanup", config.StageRunOnAlways),
+				stage("deploy", config.StageRunOnMain),
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("final stage cleanup should be placed after the main stage deploy"))
+			Expect(lintFinalStages([]*commonmodels.WorkflowStage{stage("rollback", config.StageRunOnCancel), stage("build", config.StageRunOnMain)})).NotTo(Succeed())
+		})
+	})
+
+	Context("lintJobRetryPolicy", func() {
+		It("should accept a missing or valid policy", func() {
+			Expect(lintJobRetryPolicy(nil)).To(Succeed())
+			Expect(lintJobRetryPolicy(&commonmodels.JobRetryPolicy{MaxAttempts: 3, Interval: 10, Backoff: 2, RetryOn: []config.Status{config.StatusFailed, config.StatusTimeout}})).To(Succeed())
+			Expect(lintJobRetryPolicy(&commonmodels.JobRetryPolicy{
+				MaxAttempts: commonmodels.MaxJobRetryAttempts,
+				Interval:    commonmodels.MaxJobRetryInterval,
+				Backoff:     commonmodels.MaxJobRetryBackoff,
+			})).To(Succeed())
+		})
+		It("should limit the attempts", func() {
+			Expect(lintJobRetryPolicy(&commonmodels.JobRetryPolicy{MaxAttempts: 0})).NotTo(Succeed())
+			Expect(lintJobRetryPolicy(&commonmodels.JobRetryPolicy{MaxAttempts: commonmodels.MaxJobRetryAttempts + 1})).NotTo(Succeed())
+		})
+		It("should limit the interval and the backoff", func() {
+			Expect(lintJobRetryPolicy(&commonmodels.JobRetryPolicy{MaxAttempts: 2, Interval: -1})).NotTo(Succeed())
+			Expect(lintJobRetryPolicy(&commonmodels.JobRetryPolicy{MaxAttempts: 2, Backoff: -1})).NotTo(Succeed())
+			Expect(lintJobRetryPolicy(&commonmodels.JobRetryPolicy{MaxAttempts: 2, Interval: commonmodels.MaxJobRetryInterval + 1})).NotTo(Succeed())
+			Expect(lintJobRetryPolicy(&commonmodels.JobRetryPolicy{MaxAttempts: 2, Backoff: commonmodels.MaxJobRetryBackoff + 0.5})).NotTo(Succeed())
+		})
+		It("should only retry on failed and timeout", func() {
+			Expect(lintJobRetryPolicy(&commonmodels.JobRetryPolicy{MaxAttempts: 2, RetryOn: []config.Status{config.StatusCancelled}})).NotTo(Succeed())
+			Expect(lintJobRetryPolicy(&commonmodels.JobRetryPolicy{MaxAttempts: 2, RetryOn: []config.Status{config.StatusReject}})).NotTo(Succeed())
+		})
+	})
+})
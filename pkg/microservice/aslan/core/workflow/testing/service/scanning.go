@@ -34,6 +34,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/base"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/s3"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/scmnotify"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/vulnerability"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/webhook"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/util"
 	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
@@ -42,6 +43,7 @@ import (
 	e "github.com/koderover/zadig/pkg/tool/errors"
 	"github.com/koderover/zadig/pkg/tool/sonar"
 	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
 )
 
 func CreateScanningModule(username string, args *Scanning, log *zap.SugaredLogger) error {
@@ -53,6 +55,9 @@ func CreateScanningModule(username string, args *Scanning, log *zap.SugaredLogge
 	if err != nil {
 		return e.ErrCreateScanningModule.AddErr(err)
 	}
+	if err := validateTrivySetting(args); err != nil {
+		return e.ErrCreateScanningModule.AddErr(err)
+	}
 
 	err = commonservice.ProcessWebhook(args.AdvancedSetting.HookCtl.Items, nil, webhook.ScannerPrefix+args.Name, log)
 	if err != nil {
@@ -87,6 +92,9 @@ func UpdateScanningModule(id, username string, args *Scanning, log *zap.SugaredL
 	if err != nil {
 		return e.ErrUpdateScanningModule.AddErr(err)
 	}
+	if err := validateTrivySetting(args); err != nil {
+		return e.ErrUpdateScanningModule.AddErr(err)
+	}
 
 	if scanning.AdvancedSetting.HookCtl.Enabled {
 		err = commonservice.ProcessWebhook(args.AdvancedSetting.HookCtl.Items, scanning.AdvancedSetting.HookCtl.Items, webhook.ScannerPrefix+args.Name, log)
@@ -115,6 +123,16 @@ func UpdateScanningModule(id, username string, args *Scanning, log *zap.SugaredL
 	return nil
 }
 
+func validateTrivySetting(args *Scanning) error {
+	if args.ScannerType != types.ScanningTypeTrivy {
+		return nil
+	}
+	if args.TrivySetting == nil {
+		return fmt.Errorf("trivy setting is required for trivy scanner")
+	}
+	return args.TrivySetting.Validate()
+}
+
 func ListScanningModule(projectName string, log *zap.SugaredLogger) ([]*ListScanningRespItem, int64, error) {
 	scanningList, total, err := commonrepo.NewScanningColl().List(&commonrepo.ScanningListOption{ProjectName: projectName}, 0, 0)
 	if err != nil {
@@ -343,6 +361,20 @@ func CreateScanningTask(id string, req []*ScanningRepoInfo, notificationID, user
 		}
 	}
 
+	if scanningInfo.ScannerType == types.ScanningTypeTrivy && scanningInfo.TrivySetting != nil {
+		repoName := ""
+		if len(repos) > 0 {
+			repoName = repos[0].RepoName
+		}
+		vulnerabilityScan := vulnerability.NewScanSpec(scanningInfo.TrivySetting, repoName)
+		vulnerabilityScan.S3DestDir = vulnerability.ReportDir(scanningName, nextTaskID, scanningInfo.Name)
+		vulnerabilityScan.DB = vulnerability.GetDBSettings()
+		if vulnerabilityScan.Target == step.VulnerabilityScanTargetImage {
+			vulnerabilityScan.DockerRegistry = vulnerability.FindImageRegistry(vulnerabilityScan.Image, registries)
+		}
+		scanningTask.VulnerabilityScan = vulnerabilityScan
+	}
+
 	proxies, err := commonrepo.NewProxyColl().List(&commonrepo.ProxyArgs{})
 	if err != nil {
 		log.Errorf("failed to get proxy info to create scanning task, error: %s", err)
@@ -511,16 +543,52 @@ func GetScanningTaskInfo(scanningID string, taskID int64, log *zap.SugaredLogger
 		repo.Username = ""
 	}
 
+	var vulnerabilityResult *commonmodels.VulnerabilityScanResult
+	if scanningInfo.ScannerType == types.ScanningTypeTrivy {
+		vulnerabilityResult = getScanningVulnerabilityResult(scanningInfo, scanningName, resp, log)
+	}
+
 	return &ScanningTaskDetail{
-		Creator:    resp.TaskCreator,
-		Status:     string(resp.Status),
-		CreateTime: resp.CreateTime,
-		EndTime:    resp.EndTime,
-		RepoInfo:   scanningTaskInfo.Repos,
-		ResultLink: resultAddr,
+		Creator:       resp.TaskCreator,
+		Status:        string(resp.Status),
+		CreateTime:    resp.CreateTime,
+		EndTime:       resp.EndTime,
+		RepoInfo:      scanningTaskInfo.Repos,
+		ResultLink:    resultAddr,
+		Vulnerability: vulnerabilityResult,
 	}, nil
 }
 
+// getScanningVulnerabilityResult returns the saved result of a trivy scanning task, the report is parsed
+// and saved on the first query after the task is done since the legacy task has no step to do it.
+func getScanningVulnerabilityResult(scanningInfo *commonmodels.Scanning, scanningName string, scanningTask *task.Task, log *zap.SugaredLogger) *commonmodels.VulnerabilityScanResult {
+	result, err := commonrepo.NewVulnerabilityScanResultColl().FindByJob(scanningName, scanningTask.TaskID, scanningInfo.Name)
+	if err == nil {
+		return result
+	}
+	if err != mongo.ErrNoDocuments || (scanningTask.Status != config.StatusPassed && scanningTask.Status != config.StatusFailed) {
+		return nil
+	}
+
+	result = &commonmodels.VulnerabilityScanResult{
+		ProjectName:  scanningInfo.ProjectName,
+		WorkflowName: scanningName,
+		TaskID:       scanningTask.TaskID,
+		JobName:      scanningInfo.Name,
+	}
+	var gate *types.VulnerabilityQualityGate
+	if scanningInfo.TrivySetting != nil {
+		result.Target = scanningInfo.TrivySetting.Target
+		result.Image = scanningInfo.TrivySetting.Image
+		gate = scanningInfo.TrivySetting.QualityGate
+	}
+	if err := vulnerability.SaveScanResult(result, gate); err != nil {
+		log.Warnf("failed to save vulnerability scan result of %s/%d: %s", scanningName, scanningTask.TaskID, err)
+		return nil
+	}
+	return result
+}
+
 func CancelScanningTask(userName, scanningID string, taskID int64, typeString config.PipelineType, requestID string, log *zap.SugaredLogger) error {
 	scanningInfo, err := commonrepo.NewScanningColl().GetByID(scanningID)
 	if err != nil {
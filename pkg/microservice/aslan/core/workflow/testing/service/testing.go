@@ -54,6 +54,9 @@ func CreateTesting(username string, testing *commonmodels.Testing, log *zap.Suga
 	if err := commonutil.CheckDefineResourceParam(testing.PreTest.ResReq, testing.PreTest.ResReqSpec); err != nil {
 		return e.ErrCreateTestModule.AddDesc(err.Error())
 	}
+	if err := validateTestReportSetting(testing); err != nil {
+		return e.ErrCreateTestModule.AddDesc(err.Error())
+	}
 	err := HandleCronjob(testing, log)
 	if err != nil {
 		return e.ErrCreateTestModule.AddErr(err)
@@ -117,6 +120,9 @@ func UpdateTesting(username string, testing *commonmodels.Testing, log *zap.Suga
 	if err := commonutil.CheckDefineResourceParam(testing.PreTest.ResReq, testing.PreTest.ResReqSpec); err != nil {
 		return e.ErrUpdateTestModule.AddDesc(err.Error())
 	}
+	if err := validateTestReportSetting(testing); err != nil {
+		return e.ErrUpdateTestModule.AddDesc(err.Error())
+	}
 	err := HandleCronjob(testing, log)
 	if err != nil {
 		return e.ErrUpdateTestModule.AddErr(err)
@@ -504,3 +510,23 @@ func validateTestReportParam(pipelineName, pipelineType, taskIDStr, testName str
 
 	return nil
 }
+
+func validateTestReportSetting(testing *commonmodels.Testing) error {
+	switch testing.TestResultFormat {
+	case "", types.TestReportFormatJunit, types.TestReportFormatGoTestJSON, types.TestReportFormatTestNG:
+	default:
+		return fmt.Errorf("unsupported test result format: %s", testing.TestResultFormat)
+	}
+	if testing.CoverageReportPath == "" {
+		return nil
+	}
+	if testing.TestResultPath == "" {
+		return fmt.Errorf("coverage report is collected together with the test results, test result path is required")
+	}
+	switch testing.CoverageFormat {
+	case types.CoverageFormatCobertura, types.CoverageFormatJacoco, types.CoverageFormatLCOV:
+		return nil
+	default:
+		return fmt.Errorf("unsupported coverage format: %s", testing.CoverageFormat)
+	}
+}
@@ -45,6 +45,8 @@ type Scanning struct {
 	CheckQualityGate bool                                  `json:"check_quality_gate"`
 	Outputs          []*commonmodels.Output                `json:"outputs"`
 	NotifyCtls       []*commonmodels.NotifyCtl             `json:"notify_ctls"`
+	// TrivySetting is for trivy type only
+	TrivySetting *commonmodels.TrivySetting `json:"trivy_setting"`
 }
 
 // TODO: change the logic of create scanning
@@ -161,6 +163,8 @@ type ScanningTaskDetail struct {
 	EndTime    int64               `json:"end_time"`
 	RepoInfo   []*types.Repository `json:"repo_info"`
 	ResultLink string              `json:"result_link,omitempty"`
+	// Vulnerability is the result of trivy type scanning
+	Vulnerability *commonmodels.VulnerabilityScanResult `json:"vulnerability,omitempty"`
 }
 
 func ConvertToDBScanningModule(args *Scanning) *commonmodels.Scanning {
@@ -181,6 +185,7 @@ func ConvertToDBScanningModule(args *Scanning) *commonmodels.Scanning {
 		CheckQualityGate: args.CheckQualityGate,
 		Outputs:          args.Outputs,
 		Envs:             args.Envs,
+		TrivySetting:     args.TrivySetting,
 	}
 }
 
@@ -205,6 +210,7 @@ func ConvertDBScanningModule(scanning *commonmodels.Scanning) *Scanning {
 		CheckQualityGate: scanning.CheckQualityGate,
 		Outputs:          scanning.Outputs,
 		Envs:             scanning.Envs,
+		TrivySetting:     scanning.TrivySetting,
 	}
 }
 
@@ -67,6 +67,16 @@ func (c *Client) TriggerCleanProducts(log *zap.SugaredLogger) error {
 	return err
 }
 
+// TriggerEnvIdleSleep puts the environments idle for longer than their idle policies to sleep
+func (c *Client) TriggerEnvIdleSleep(log *zap.SugaredLogger) error {
+	url := fmt.Sprintf("%s/environment/cron/idlesleep", c.APIBase)
+	err := c.sendRequest(url)
+	if err != nil {
+		log.Errorf("trigger env idle sleep error :%v", err)
+	}
+	return err
+}
+
 // TriggerCleanCIResources trigger clean CollaborationInstance Resources
 func (c *Client) TriggerCleanCIResources(log *zap.SugaredLogger) error {
 	url := fmt.Sprintf("%s/collaboration/collaborations/cron/clean", c.APIBase)
@@ -192,6 +192,11 @@ func (h *CronjobHandler) updateCronjob(name, productName, jobType string, jobLis
 			if err != nil {
 				return err
 			}
+		case setting.EnvDriftCronjob:
+			err := h.registerEnvDriftJob(name, cron, job)
+			if err != nil {
+				return err
+			}
 		default:
 			log.Errorf("unrecognized cron job type for job id: %s", job.ID)
 		}
@@ -488,6 +493,35 @@ func (h *CronjobHandler) registerEnvAnalysisJob(name, schedule string, job *serv
 	return nil
 }
 
+func (h *CronjobHandler) registerEnvDriftJob(name, schedule string, job *service.Schedule) error {
+	if job.EnvArgs == nil {
+		return nil
+	}
+	scheduleJob, err := cronlib.NewJobModel(schedule, func() {
+		base := "environment/environments/"
+		if job.EnvArgs.Production {
+			base = "environment/production/environments/"
+		}
+		url := base + fmt.Sprintf("%s/drift?projectName=%s&triggerName=%s", job.EnvArgs.EnvName, job.EnvArgs.ProductName, setting.CronTaskCreator)
+
+		if err := h.aslanCli.ScheduleCall(url, nil, log.SugaredLogger()); err != nil {
+			log.Errorf("[%s]RunScheduledTask err: %v", name, err)
+		}
+	})
+	if err != nil {
+		log.Errorf("Failed to create job of ID: %s, the error is: %v", job.ID.Hex(), err)
+		return err
+	}
+
+	log.Infof("registering jobID: %s with cron: %s", job.ID.Hex(), schedule)
+	err = h.Scheduler.UpdateJobModel(job.ID.Hex(), scheduleJob)
+	if err != nil {
+		log.Errorf("Failed to register job of ID: %s to scheduler, the error is: %v", job.ID, err)
+		return err
+	}
+	return nil
+}
+
 func (h *CronjobHandler) registerEnvSleepJob(name, schedule string, job *service.Schedule) error {
 	if job.EnvArgs == nil {
 		return nil
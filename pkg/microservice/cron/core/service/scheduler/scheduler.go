@@ -113,6 +113,8 @@ const (
 
 	CleanProductScheduler = "CleanProductScheduler"
 
+	EnvIdleSleepScheduler = "EnvIdleSleepScheduler"
+
 	CleanCIResourcesScheduler = "CleanCIResourcesScheduler"
 
 	InitStatScheduler = "InitStatScheduler"
@@ -199,6 +201,8 @@ func (c *CronClient) Init() {
 
 	// 定时清理环境
 	c.InitCleanProductScheduler()
+	// put idle environments to sleep every 5 minutes
+	c.InitEnvIdleSleepScheduler()
 	// clean collaboration instance resource every 5 minutes
 	c.InitCleanCIResourcesScheduler()
 	// 定时初始化构建数据
@@ -235,6 +239,15 @@ func (c *CronClient) InitCleanProductScheduler() {
 	c.Schedulers[CleanProductScheduler].Start()
 }
 
+func (c *CronClient) InitEnvIdleSleepScheduler() {
+
+	c.Schedulers[EnvIdleSleepScheduler] = gocron.NewScheduler()
+
+	c.Schedulers[EnvIdleSleepScheduler].Every(5).Minutes().Do(c.AslanCli.TriggerEnvIdleSleep, c.log)
+
+	c.Schedulers[EnvIdleSleepScheduler].Start()
+}
+
 func (c *CronClient) InitCleanCIResourcesScheduler() {
 
 	c.Schedulers[CleanCIResourcesScheduler] = gocron.NewScheduler()
@@ -86,6 +86,26 @@ func RunStep(ctx context.Context, step *meta.Step, workspace, paths string, envs
 		if err != nil {
 			return err
 		}
+	case "cache_restore":
+		stepInstance, err = NewCacheRestoreStep(step.Spec, workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
+	case "cache_save":
+		stepInstance, err = NewCacheSaveStep(step.Spec, workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
+	case "supply_chain":
+		stepInstance, err = NewSupplyChainStep(step.Spec, workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
+	case "vulnerability_scan":
+		stepInstance, err = NewVulnerabilityScanStep(step.Spec, workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
 	case "debug_before":
 		stepInstance, err = NewDebugStep("before", workspace, envs, secretEnvs, updater)
 		if err != nil {
@@ -0,0 +1,505 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/microservice/jobexecutor/config"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+var cacheKeyRegexp = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+type CacheStep struct {
+	spec       *step.StepCacheSpec
+	save       bool
+	envs       []string
+	secretEnvs []string
+	workspace  string
+}
+
+func NewCacheRestoreStep(spec interface{}, workspace string, envs, secretEnvs []string) (*CacheStep, error) {
+	return newCacheStep(spec, false, workspace, envs, secretEnvs)
+}
+
+func NewCacheSaveStep(spec interface{}, workspace string, envs, secretEnvs []string) (*CacheStep, error) {
+	return newCacheStep(spec, true, workspace, envs, secretEnvs)
+}
+
+func newCacheStep(spec interface{}, save bool, workspace string, envs, secretEnvs []string) (*CacheStep, error) {
+	cacheStep := &CacheStep{save: save, workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return cacheStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &cacheStep.spec); err != nil {
+		return cacheStep, fmt.Errorf("unmarshal spec %s to cache spec failed", yamlBytes)
+	}
+	return cacheStep, nil
+}
+
+// Run never fails the job when the object storage is not available, a job without cache is only slower.
+func (s *CacheStep) Run(ctx context.Context) error {
+	start := time.Now()
+	envMap := makeEnvMap(s.envs, s.secretEnvs)
+	key, err := renderCacheKey(s.spec.Key, s.workspace, envMap)
+	if err != nil {
+		return err
+	}
+
+	forcedPathStyle := true
+	if s.spec.S3Storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3.NewClient(s.spec.S3Storage.Endpoint, s.spec.S3Storage.Ak, s.spec.S3Storage.Sk, s.spec.S3Storage.Region, s.spec.S3Storage.Insecure, forcedPathStyle)
+	if err != nil {
+		log.Warnf("failed to create s3 client for cache, err: %s", err)
+		return nil
+	}
+	cacheDir := strings.TrimLeft(path.Join(s.spec.S3Storage.Subfolder, s.spec.CacheDir), "/")
+
+	if s.save {
+		err = s.saveCache(client, cacheDir, key, envMap)
+		log.Infof("Cache save ended. Duration: %.2f seconds", time.Since(start).Seconds())
+	} else {
+		err = s.restoreCache(client, cacheDir, key, envMap)
+		log.Infof("Cache restore ended. Duration: %.2f seconds", time.Since(start).Seconds())
+	}
+	if err != nil {
+		log.Warnf("cache %s error: %s", key, err)
+	}
+	return nil
+}
+
+func (s *CacheStep) restoreCache(client *s3.Client, cacheDir, key string, envMap map[string]string) error {
+	objects, err := client.ListObjectInfos(s.spec.S3Storage.Bucket, cacheDir+"/")
+	if err != nil {
+		return err
+	}
+	objectKey := matchCacheObject(objects, cacheDir, key, s.renderRestoreKeys(envMap))
+	if objectKey == "" {
+		log.Infof("Cache not found for key: %s", key)
+		return nil
+	}
+	log.Infof("Restore cache from %s", objectKey)
+
+	archive, err := os.CreateTemp("", "cache-*"+step.CacheArchiveSuffix)
+	if err != nil {
+		return err
+	}
+	_ = archive.Close()
+	defer os.Remove(archive.Name())
+	if err := client.Download(s.spec.S3Storage.Bucket, objectKey, archive.Name()); err != nil {
+		return fmt.Errorf("download cache %s error: %s", objectKey, err)
+	}
+	if err := extractCacheArchive(archive.Name(), s.cacheRoots(envMap)); err != nil {
+		return fmt.Errorf("decompress cache %s error: %s", objectKey, err)
+	}
+	touchCacheObject(client, s.spec.S3Storage.Bucket, cacheDir, objectKey)
+	return nil
+}
+
+func (s *CacheStep) saveCache(client *s3.Client, cacheDir, key string, envMap map[string]string) error {
+	objectKey := path.Join(cacheDir, key+step.CacheArchiveSuffix)
+	objects, err := client.ListObjectInfos(s.spec.S3Storage.Bucket, objectKey)
+	if err != nil {
+		return err
+	}
+	// the key is derived from the content, an existing cache does not need to be uploaded again
+	for _, object := range objects {
+		if object.Key == objectKey {
+			log.Infof("Cache %s already exists, skip saving", key)
+			return nil
+		}
+	}
+
+	args := []string{"-czf", "", "-C", "/"}
+	for _, p := range s.spec.Paths {
+		p = s.absCachePath(replaceEnvWithValue(p, envMap), envMap)
+		if _, err := os.Stat(p); err != nil {
+			log.Warnf("cache path %s not found, skip it", p)
+			continue
+		}
+		args = append(args, strings.TrimPrefix(p, "/"))
+	}
+	if len(args) == 4 {
+		log.Infof("No cache path found, skip saving cache %s", key)
+		return nil
+	}
+
+	archive, err := os.CreateTemp("", "cache-*"+step.CacheArchiveSuffix)
+	if err != nil {
+		return err
+	}
+	_ = archive.Close()
+	defer os.Remove(archive.Name())
+	args[1] = archive.Name()
+	cmd := exec.Command("tar", args...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("compress cache %s error: %s", key, err)
+	}
+	if err := client.Upload(s.spec.S3Storage.Bucket, archive.Name(), objectKey); err != nil {
+		return fmt.Errorf("upload cache %s error: %s", objectKey, err)
+	}
+	touchCacheObject(client, s.spec.S3Storage.Bucket, cacheDir, objectKey)
+	log.Infof("Cache saved to %s", objectKey)
+	return nil
+}
+
+func (s *CacheStep) renderRestoreKeys(envMap map[string]string) []string {
+	keys := []string{}
+	for _, restoreKey := range s.spec.RestoreKeys {
+		key, err := renderCacheKey(restoreKey, s.workspace, envMap)
+		if err != nil {
+			log.Warnf("render restore key %s error: %s", restoreKey, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// cacheRoots returns the dirs a restored cache can be extracted to: the workspace and the cache paths of the step.
+func (s *CacheStep) cacheRoots(envMap map[string]string) []string {
+	roots := []string{filepath.Clean(s.workspace)}
+	for _, p := range s.spec.Paths {
+		roots = append(roots, s.absCachePath(replaceEnvWithValue(p, envMap), envMap))
+	}
+	return roots
+}
+
+func (s *CacheStep) absCachePath(p string, envMap map[string]string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home := envMap["HOME"]
+		if home == "" {
+			home = config.Home()
+		}
+		p = filepath.Join(home, strings.TrimPrefix(p, "~"))
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(s.workspace, p)
+	}
+	return filepath.Clean(p)
+}
+
+// matchCacheObject returns the archive of the exact key, or the latest archive matching the first restore key
+// that has any match.
+func matchCacheObject(objects []*s3.ObjectInfo, cacheDir, key string, restoreKeys []string) string {
+	archives := []*s3.ObjectInfo{}
+	for _, object := range objects {
+		if strings.HasSuffix(object.Key, step.CacheArchiveSuffix) && path.Dir(object.Key) == cacheDir {
+			archives = append(archives, object)
+		}
+	}
+	exact := path.Join(cacheDir, key+step.CacheArchiveSuffix)
+	for _, archive := range archives {
+		if archive.Key == exact {
+			return archive.Key
+		}
+	}
+	sort.SliceStable(archives, func(i, j int) bool {
+		return archives[i].LastModified.After(archives[j].LastModified)
+	})
+	for _, restoreKey := range restoreKeys {
+		prefix := path.Join(cacheDir, restoreKey)
+		for _, archive := range archives {
+			if strings.HasPrefix(archive.Key, prefix) {
+				return archive.Key
+			}
+		}
+	}
+	return ""
+}
+
+// touchCacheObject records the access time of a cache archive for the LRU eviction of the system GC.
+func touchCacheObject(client *s3.Client, bucket, cacheDir, objectKey string) {
+	marker, err := os.CreateTemp("", "cache-access-*")
+	if err != nil {
+		return
+	}
+	_ = marker.Close()
+	defer os.Remove(marker.Name())
+	markerKey := path.Join(cacheDir, step.CacheAccessDir, path.Base(objectKey))
+	if err := client.Upload(bucket, marker.Name(), markerKey); err != nil {
+		log.Warnf("failed to update cache access time of %s, err: %s", objectKey, err)
+	}
+}
+
+// renderCacheKey renders the envs and the hashFiles function in a cache key, hashFiles returns the sha256 of the
+// files in the workspace matching any of the glob patterns, ** matches any number of dirs.
+func renderCacheKey(key, workspace string, envMap map[string]string) (string, error) {
+	key = replaceEnvWithValue(key, envMap)
+	tmpl, err := template.New("cache-key").Funcs(template.FuncMap{
+		"hashFiles": func(patterns ...string) (string, error) {
+			return hashFiles(workspace, patterns...)
+		},
+	}).Parse(key)
+	if err != nil {
+		return "", fmt.Errorf("parse cache key %s error: %v", key, err)
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, nil); err != nil {
+		return "", fmt.Errorf("render cache key %s error: %v", key, err)
+	}
+	// the trailing - is kept so that a restore key like go- does not match golang-xxx
+	rendered := strings.TrimLeft(cacheKeyRegexp.ReplaceAllString(buf.String(), "-"), "-")
+	if rendered == "" {
+		return "", fmt.Errorf("cache key %s is empty after rendering", key)
+	}
+	return rendered, nil
+}
+
+// extractCacheArchive extracts a cache archive created by saveCache, the entries are paths relative to /.
+// The archive comes from the object storage and is not trusted: entries with absolute paths or .. are rejected,
+// and an entry is only written if it is inside one of roots, links can not point outside of them either.
+func extractCacheArchive(archivePath string, roots []string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gzipReader, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, err := cacheEntryTarget(header.Name, roots)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			log.Warnf("cache entry %s is outside of the cache paths, skip it", header.Name)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		// a link extracted before must not redirect the entry outside of the roots
+		if dir, err := filepath.EvalSymlinks(filepath.Dir(target)); err != nil || !pathInRoots(dir, resolveRoots(roots)) {
+			return fmt.Errorf("cache entry %s is redirected outside of the cache paths", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(header.Mode).Perm()|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeCacheFile(target, tarReader, header); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			linkTarget := header.Linkname
+			if !filepath.IsAbs(linkTarget) {
+				linkTarget = filepath.Join(filepath.Dir(target), linkTarget)
+			}
+			if !pathInRoots(filepath.Clean(linkTarget), roots) {
+				return fmt.Errorf("cache entry %s links to %s outside of the cache paths", header.Name, header.Linkname)
+			}
+			_ = os.Remove(target)
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			linkTarget, err := cacheEntryTarget(header.Linkname, roots)
+			if err != nil {
+				return err
+			}
+			if linkTarget == "" {
+				return fmt.Errorf("cache entry %s links to %s outside of the cache paths", header.Name, header.Linkname)
+			}
+			_ = os.Remove(target)
+			if err := os.Link(linkTarget, target); err != nil {
+				return err
+			}
+		default:
+			log.Warnf("unsupported type of cache entry %s, skip it", header.Name)
+		}
+	}
+}
+
+// cacheEntryTarget returns the path an archive entry is extracted to, it is empty if the path is outside of roots.
+func cacheEntryTarget(name string, roots []string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("cache entry %s has an absolute path", name)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part == ".." {
+			return "", fmt.Errorf("cache entry %s contains ..", name)
+		}
+	}
+	target := filepath.Join("/", name)
+	if !pathInRoots(target, roots) {
+		return "", nil
+	}
+	return target, nil
+}
+
+func pathInRoots(p string, roots []string) bool {
+	for _, root := range roots {
+		if p == root || strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveRoots adds the real paths of roots, the workspace or the home dir may be a link.
+func resolveRoots(roots []string) []string {
+	resp := append([]string{}, roots...)
+	for _, root := range roots {
+		if real, err := filepath.EvalSymlinks(root); err == nil && real != root {
+			resp = append(resp, real)
+		}
+	}
+	return resp
+}
+
+func writeCacheFile(target string, r io.Reader, header *tar.Header) error {
+	_ = os.Remove(target)
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(target, header.ModTime, header.ModTime)
+}
+
+func hashFiles(workspace string, patterns ...string) (string, error) {
+	regexps := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := globToRegexp(pattern)
+		if err != nil {
+			return "", err
+		}
+		regexps = append(regexps, re)
+	}
+
+	files := []string{}
+	err := filepath.WalkDir(workspace, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(workspace, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		for _, re := range regexps {
+			if re.MatchString(rel) {
+				files = append(files, rel)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", nil
+	}
+	sort.Strings(files)
+
+	hash := sha256.New()
+	for _, file := range files {
+		f, err := os.Open(filepath.Join(workspace, file))
+		if err != nil {
+			return "", err
+		}
+		fileHash := sha256.New()
+		_, err = io.Copy(fileHash, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		hash.Write([]byte(file))
+		hash.Write(fileHash.Sum(nil))
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	buf := strings.Builder{}
+	buf.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			buf.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			buf.WriteString(".*")
+			i++
+		case c == '*':
+			buf.WriteString("[^/]*")
+		case c == '?':
+			buf.WriteString("[^/]")
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	buf.WriteString("$")
+	return regexp.Compile(buf.String())
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/s3"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	type testcase struct {
+		pattern string
+		file    string
+		matched bool
+	}
+	testcases := []testcase{
+		{"go.sum", "go.sum", true},
+		{"go.sum", "sub/go.sum", false},
+		{"**/go.sum", "go.sum", true},
+		{"**/go.sum", "a/b/go.sum", true},
+		{"*/pom.xml", "a/pom.xml", true},
+		{"*/pom.xml", "a/b/pom.xml", false},
+		{"./package-lock.json", "package-lock.json", true},
+		{"src/**", "src/a/b.go", true},
+	}
+	for _, tc := range testcases {
+		re, err := globToRegexp(tc.pattern)
+		if err != nil {
+			t.Fatalf("compile pattern %s error: %v", tc.pattern, err)
+		}
+		if re.MatchString(tc.file) != tc.matched {
+			t.Errorf("Expected match result of <%s> for path <%s> to be <%v>", tc.pattern, tc.file, tc.matched)
+		}
+	}
+}
+
+func TestRenderCacheKey(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workspace, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "sub", "go.sum"), []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	envs := map[string]string{"GO_VERSION": "1.21"}
+
+	first, err := renderCacheKey(`go-$GO_VERSION-{{hashFiles "**/go.sum"}}`, workspace, envs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != len("go-1.21-")+64 {
+		t.Errorf("unexpected cache key %s", first)
+	}
+
+	if err := os.WriteFile(filepath.Join(workspace, "sub", "go.sum"), []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	second, err := renderCacheKey(`go-$GO_VERSION-{{hashFiles "**/go.sum"}}`, workspace, envs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("cache key should change with the file content")
+	}
+
+	prefix, err := renderCacheKey(`go-$GO_VERSION-{{hashFiles "not-exist"}}`, workspace, envs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix != "go-1.21-" {
+		t.Errorf("Expected <go-1.21-> but got <%s>", prefix)
+	}
+}
+
+func TestMatchCacheObject(t *testing.T) {
+	now := time.Now()
+	objects := []*s3.ObjectInfo{
+		{Key: "cache/p/w/j/go-old.tar.gz", LastModified: now.Add(-time.Hour)},
+		{Key: "cache/p/w/j/go-new.tar.gz", LastModified: now},
+		{Key: "cache/p/w/j/.access/go-exact.tar.gz", LastModified: now},
+		{Key: "cache/p/w/j/go-exact.tar.gz", LastModified: now.Add(-2 * time.Hour)},
+	}
+	type testcase struct {
+		key         string
+		restoreKeys []string
+		expected    string
+	}
+	testcases := []testcase{
+		{"go-exact", []string{"go-"}, "cache/p/w/j/go-exact.tar.gz"},
+		{"go-missing", []string{"go-"}, "cache/p/w/j/go-new.tar.gz"},
+		{"go-missing", []string{"maven-", "go-o"}, "cache/p/w/j/go-old.tar.gz"},
+		{"go-missing", nil, ""},
+		{"golang-missing", []string{"go-"}, "cache/p/w/j/go-new.tar.gz"},
+		{"go-missing", []string{"golang-"}, ""},
+	}
+	for _, tc := range testcases {
+		if got := matchCacheObject(objects, "cache/p/w/j", tc.key, tc.restoreKeys); got != tc.expected {
+			t.Errorf("Expected <%s> for key <%s> but got <%s>", tc.expected, tc.key, got)
+		}
+	}
+}
+
+type cacheArchiveEntry struct {
+	name     string
+	typeflag byte
+	content  string
+	linkname string
+}
+
+func writeCacheArchive(t *testing.T, entries []cacheArchiveEntry) string {
+	archive := filepath.Join(t.TempDir(), "cache.tar.gz")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gzipWriter := gzip.NewWriter(f)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Typeflag: entry.typeflag, Mode: 0644, Size: int64(len(entry.content)), Linkname: entry.linkname, ModTime: time.Now()}
+		if entry.typeflag == tar.TypeDir {
+			header.Mode = 0755
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if entry.content != "" {
+			if _, err := tarWriter.Write([]byte(entry.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+func TestExtractCacheArchive(t *testing.T) {
+	log.Init(&log.Config{Level: "error"})
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspace := filepath.Join(base, "workspace")
+	home := filepath.Join(base, "home", ".m2")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{workspace, outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	roots := []string{workspace, home}
+	rel := func(p string) string {
+		return strings.TrimPrefix(p, "/")
+	}
+
+	archive := writeCacheArchive(t, []cacheArchiveEntry{
+		{name: rel(workspace) + "/node_modules/", typeflag: tar.TypeDir},
+		{name: rel(workspace) + "/node_modules/a.js", typeflag: tar.TypeReg, content: "a"},
+		{name: rel(home) + "/settings.xml", typeflag: tar.TypeReg, content: "m2"},
+		{name: rel(workspace) + "/node_modules/b.js", typeflag: tar.TypeSymlink, linkname: "a.js"},
+		{name: rel(outside) + "/skipped", typeflag: tar.TypeReg, content: "x"},
+	})
+	if err := extractCacheArchive(archive, roots); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := os.ReadFile(filepath.Join(workspace, "node_modules", "b.js")); err != nil || string(content) != "a" {
+		t.Errorf("Expected <a> in the workspace but got <%s>, err: %v", content, err)
+	}
+	if content, err := os.ReadFile(filepath.Join(home, "settings.xml")); err != nil || string(content) != "m2" {
+		t.Errorf("Expected <m2> in the cache path but got <%s>, err: %v", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "skipped")); !os.IsNotExist(err) {
+		t.Errorf("Expected the entry outside of the cache paths to be skipped")
+	}
+
+	badArchives := map[string][]cacheArchiveEntry{
+		"absolute path": {{name: filepath.Join(workspace, "abs"), typeflag: tar.TypeReg, content: "x"}},
+		"parent path":   {{name: rel(workspace) + "/../outside/evil", typeflag: tar.TypeReg, content: "x"}},
+		"symlink outside": {
+			{name: rel(workspace) + "/link", typeflag: tar.TypeSymlink, linkname: outside},
+		},
+		"relative symlink outside": {
+			{name: rel(workspace) + "/link", typeflag: tar.TypeSymlink, linkname: "../outside"},
+		},
+		"hard link outside": {
+			{name: rel(workspace) + "/link", typeflag: tar.TypeLink, linkname: rel(outside) + "/file"},
+		},
+	}
+	for name, entries := range badArchives {
+		if err := extractCacheArchive(writeCacheArchive(t, entries), roots); err == nil {
+			t.Errorf("Expected an error for the archive with %s", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outside, "evil")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written outside of the cache paths")
+	}
+}
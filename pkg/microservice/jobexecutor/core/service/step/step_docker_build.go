@@ -67,6 +67,12 @@ func (s *DockerBuildStep) Run(ctx context.Context) error {
 	s.spec.DockerFile = replaceEnvWithValue(s.spec.DockerFile, envMap)
 	s.spec.BuildArgs = replaceEnvWithValue(s.spec.BuildArgs, envMap)
 
+	if s.spec.IsDaemonless() {
+		s.spec.ImageName = replaceEnvWithValue(s.spec.ImageName, envMap)
+		s.spec.CacheImage = replaceEnvWithValue(s.spec.CacheImage, envMap)
+		return s.runDaemonlessBuild(ctx)
+	}
+
 	if err := s.dockerLogin(); err != nil {
 		return err
 	}
@@ -0,0 +1,330 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const (
+	buildCtlExe = "/executor/buildctl"
+	kanikoExe   = "/kaniko/executor"
+
+	buildKitHostEnv = "BUILDKIT_HOST"
+)
+
+// runDaemonlessBuild builds and pushes the image with the buildkit or kaniko sidecar of the job pod,
+// no docker daemon is needed and nothing runs privileged.
+func (s *DockerBuildStep) runDaemonlessBuild(ctx context.Context) error {
+	fmt.Printf("Preparing Dockerfile.\n")
+	if err := prepareDockerfile(s.spec.Source, s.spec.DockerTemplateContent); err != nil {
+		return fmt.Errorf("failed to prepare dockerfile: %s", err)
+	}
+	if s.spec.Proxy != nil {
+		setProxy(s.spec)
+	}
+	if s.spec.WorkDir == "" {
+		s.spec.WorkDir = "."
+	}
+
+	startTimeBuild := time.Now()
+	fmt.Printf("Running %s build.\n", s.spec.Builder)
+	var err error
+	switch s.spec.Builder {
+	case step.DockerBuilderBuildKit:
+		err = s.runBuildKitBuild(ctx)
+	case step.DockerBuilderKaniko:
+		err = s.runKanikoBuild(ctx)
+	default:
+		err = fmt.Errorf("unknown docker builder %s", s.spec.Builder)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s build ended. Duration: %.2f seconds.\n", s.spec.Builder, time.Since(startTimeBuild).Seconds())
+	return nil
+}
+
+func (s *DockerBuildStep) runBuildKitBuild(ctx context.Context) error {
+	dockerConfigDir := filepath.Join(step.BuilderShareDir, "docker")
+	if err := writeDockerConfig(dockerConfigDir, s.spec.DockerRegistry); err != nil {
+		return err
+	}
+
+	addr := makeEnvMap(s.envs)[buildKitHostEnv]
+	if addr == "" {
+		addr = step.BuildKitAddr
+	}
+	envs := append(s.envs, "DOCKER_CONFIG="+dockerConfigDir)
+	if err := waitBuildKitReady(ctx, addr, envs); err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, buildCtlExe, buildKitArgs(s.spec, addr, s.workspace)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Dir = s.workspace
+	cmd.Env = envs
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run buildkit build: %s", err)
+	}
+	return nil
+}
+
+// waitBuildKitReady waits for the buildkitd sidecar which may still be starting when the step runs.
+func waitBuildKitReady(ctx context.Context, addr string, envs []string) error {
+	var out []byte
+	var err error
+	for i := 0; i < 60; i++ {
+		cmd := exec.CommandContext(ctx, buildCtlExe, "--addr", addr, "debug", "workers")
+		cmd.Env = envs
+		if out, err = cmd.CombinedOutput(); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+	return fmt.Errorf("buildkitd %s is not ready: %s %s", addr, err, out)
+}
+
+func buildKitArgs(spec *step.StepDockerBuildSpec, addr, workspace string) []string {
+	dockerfile := absPath(spec.GetDockerFile(), workspace)
+	args := []string{
+		"--addr", addr,
+		"build",
+		"--frontend", "dockerfile.v0",
+		"--local", "context=" + absPath(spec.WorkDir, workspace),
+		"--local", "dockerfile=" + filepath.Dir(dockerfile),
+		"--opt", "filename=" + filepath.Base(dockerfile),
+	}
+	if platforms := spec.Platforms(); len(platforms) > 0 {
+		args = append(args, "--opt", "platform="+strings.Join(platforms, ","))
+	}
+	buildArgs, target := parseBuildArgs(spec.BuildArgs)
+	for _, buildArg := range buildArgs {
+		args = append(args, "--opt", "build-arg:"+buildArg)
+	}
+	if target != "" {
+		args = append(args, "--opt", "target="+target)
+	}
+	if spec.IgnoreCache {
+		args = append(args, "--no-cache")
+	}
+	args = append(args, "--output", fmt.Sprintf("type=image,name=%s,push=true", spec.ImageName))
+	if spec.EnableRegistryCache {
+		cacheImage := getCacheImage(spec)
+		args = append(args,
+			"--export-cache", fmt.Sprintf("type=registry,ref=%s,mode=max", cacheImage),
+			"--import-cache", fmt.Sprintf("type=registry,ref=%s", cacheImage),
+		)
+	}
+	return args
+}
+
+// runKanikoBuild hands the build over to the kaniko sidecar: the build context, dockerfile and registry auth are
+// copied into the shared dir, then a <id>.sh script is dropped for the sidecar, which writes <id>.log and <id>.exit.
+func (s *DockerBuildStep) runKanikoBuild(ctx context.Context) error {
+	id := strconv.FormatInt(time.Now().UnixNano(), 10)
+	requestDir := filepath.Join(step.KanikoRequestDir, id)
+	if err := os.MkdirAll(filepath.Join(requestDir, "context"), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create kaniko request dir: %s", err)
+	}
+	defer os.RemoveAll(requestDir)
+
+	copyCmd := exec.Command("cp", "-a", absPath(s.spec.WorkDir, s.workspace)+"/.", filepath.Join(requestDir, "context"))
+	if out, err := copyCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to copy build context: %s %s", err, out)
+	}
+	dockerfile, err := os.ReadFile(absPath(s.spec.GetDockerFile(), s.workspace))
+	if err != nil {
+		return fmt.Errorf("failed to read dockerfile: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(requestDir, "Dockerfile"), dockerfile, 0644); err != nil {
+		return fmt.Errorf("failed to write dockerfile: %s", err)
+	}
+	if err := writeDockerConfig(requestDir, s.spec.DockerRegistry); err != nil {
+		return err
+	}
+
+	// write then rename, the sidecar only picks up complete scripts
+	script := filepath.Join(step.KanikoRequestDir, id+".sh")
+	if err := os.WriteFile(script+".tmp", []byte(kanikoScript(s.spec, requestDir)), 0755); err != nil {
+		return fmt.Errorf("failed to write kaniko script: %s", err)
+	}
+	if err := os.Rename(script+".tmp", script); err != nil {
+		return fmt.Errorf("failed to write kaniko script: %s", err)
+	}
+
+	return waitKanikoBuild(ctx, filepath.Join(step.KanikoRequestDir, id))
+}
+
+func kanikoScript(spec *step.StepDockerBuildSpec, requestDir string) string {
+	args := []string{
+		kanikoExe,
+		"--context", "dir://" + filepath.Join(requestDir, "context"),
+		"--dockerfile", filepath.Join(requestDir, "Dockerfile"),
+		"--destination", spec.ImageName,
+		"--cleanup",
+	}
+	buildArgs, target := parseBuildArgs(spec.BuildArgs)
+	for _, buildArg := range buildArgs {
+		args = append(args, "--build-arg", buildArg)
+	}
+	if target != "" {
+		args = append(args, "--target", target)
+	}
+	if platforms := spec.Platforms(); len(platforms) > 0 {
+		args = append(args, "--custom-platform", platforms[0])
+	}
+	if spec.EnableRegistryCache && !spec.IgnoreCache {
+		args = append(args, "--cache=true", "--cache-repo", imageRepo(getCacheImage(spec)))
+	}
+
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return fmt.Sprintf("export DOCKER_CONFIG=%s\nexec %s\n", shellQuote(requestDir), strings.Join(quoted, " "))
+}
+
+// waitKanikoBuild streams the log of the kaniko build until its exit file shows up.
+func waitKanikoBuild(ctx context.Context, prefix string) error {
+	var logFile *os.File
+	defer func() {
+		if logFile != nil {
+			logFile.Close()
+		}
+	}()
+	for {
+		if logFile == nil {
+			logFile, _ = os.Open(prefix + ".log")
+		}
+		if logFile != nil {
+			io.Copy(os.Stdout, logFile)
+		}
+
+		exitCode, err := os.ReadFile(prefix + ".exit")
+		if err == nil && len(strings.TrimSpace(string(exitCode))) > 0 {
+			if logFile != nil {
+				io.Copy(os.Stdout, logFile)
+			}
+			os.Remove(prefix + ".log")
+			os.Remove(prefix + ".exit")
+			if code := strings.TrimSpace(string(exitCode)); code != "0" {
+				return fmt.Errorf("failed to run kaniko build: exit code %s", code)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func writeDockerConfig(dir string, registry *step.DockerRegistry) error {
+	auths := map[string]interface{}{}
+	if registry != nil && registry.UserName != "" {
+		host := strings.TrimPrefix(strings.TrimPrefix(registry.Host, "https://"), "http://")
+		auths[host] = map[string]string{
+			"auth": base64.StdEncoding.EncodeToString([]byte(registry.UserName + ":" + registry.Password)),
+		}
+	}
+	content, err := json.Marshal(map[string]interface{}{"auths": auths})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create docker config dir: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), content, 0600); err != nil {
+		return fmt.Errorf("failed to write docker config: %s", err)
+	}
+	return nil
+}
+
+// parseBuildArgs picks the build args and the target out of the docker build flags,
+// other flags are not supported by the daemonless builders and are ignored.
+func parseBuildArgs(buildArgs string) ([]string, string) {
+	args := make([]string, 0)
+	target := ""
+	fields := strings.Fields(buildArgs)
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		switch {
+		case field == "--build-arg" && i+1 < len(fields):
+			i++
+			args = append(args, fields[i])
+		case strings.HasPrefix(field, "--build-arg="):
+			args = append(args, strings.TrimPrefix(field, "--build-arg="))
+		case field == "--target" && i+1 < len(fields):
+			i++
+			target = fields[i]
+		case strings.HasPrefix(field, "--target="):
+			target = strings.TrimPrefix(field, "--target=")
+		default:
+			fmt.Printf("Ignoring unsupported build flag %s.\n", field)
+		}
+	}
+	return args, target
+}
+
+func getCacheImage(spec *step.StepDockerBuildSpec) string {
+	if spec.CacheImage != "" {
+		return spec.CacheImage
+	}
+	return imageRepo(spec.ImageName) + ":buildcache"
+}
+
+// imageRepo strips the tag and the digest of the image.
+func imageRepo(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+func absPath(path, workspace string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(workspace, path)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
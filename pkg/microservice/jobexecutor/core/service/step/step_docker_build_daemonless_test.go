@@ -0,0 +1,69 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+func TestParseBuildArgs(t *testing.T) {
+	args, target := parseBuildArgs("--build-arg A=1 --build-arg=B=2 --network host --target release")
+	if !reflect.DeepEqual(args, []string{"A=1", "B=2"}) {
+		t.Errorf("unexpected build args %v", args)
+	}
+	if target != "release" {
+		t.Errorf("unexpected target %s", target)
+	}
+}
+
+func TestImageRepo(t *testing.T) {
+	testcases := map[string]string{
+		"koderover.io/zadig/app:v1":          "koderover.io/zadig/app",
+		"localhost:5000/app":                 "localhost:5000/app",
+		"localhost:5000/app:v1":              "localhost:5000/app",
+		"koderover.io/app@sha256:0123456789": "koderover.io/app",
+	}
+	for image, expected := range testcases {
+		if repo := imageRepo(image); repo != expected {
+			t.Errorf("Expected repo of <%s> to be <%s>, got <%s>", image, expected, repo)
+		}
+	}
+}
+
+func TestBuildKitArgs(t *testing.T) {
+	spec := &step.StepDockerBuildSpec{
+		WorkDir:             "app",
+		DockerFile:          "app/Dockerfile",
+		ImageName:           "koderover.io/zadig/app:v1",
+		BuildArgs:           "--build-arg A=1",
+		Builder:             step.DockerBuilderBuildKit,
+		Platform:            "linux/amd64, linux/arm64",
+		EnableRegistryCache: true,
+	}
+	args := strings.Join(buildKitArgs(spec, step.BuildKitAddr, "/workspace"), " ")
+	expected := "--addr tcp://127.0.0.1:1234 build --frontend dockerfile.v0 --local context=/workspace/app --local dockerfile=/workspace/app " +
+		"--opt filename=Dockerfile --opt platform=linux/amd64,linux/arm64 --opt build-arg:A=1 " +
+		"--output type=image,name=koderover.io/zadig/app:v1,push=true " +
+		"--export-cache type=registry,ref=koderover.io/zadig/app:buildcache,mode=max --import-cache type=registry,ref=koderover.io/zadig/app:buildcache"
+	if args != expected {
+		t.Errorf("unexpected buildctl args %s", args)
+	}
+}
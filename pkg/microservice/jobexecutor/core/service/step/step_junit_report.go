@@ -34,6 +34,8 @@ import (
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/log"
 	"github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/tool/testreport"
+	"github.com/koderover/zadig/pkg/types"
 	"github.com/koderover/zadig/pkg/types/step"
 )
 
@@ -69,14 +71,28 @@ func (s *JunitReportStep) Run(ctx context.Context) error {
 
 	envMap := makeEnvMap(s.envs, s.secretEnvs)
 	s.spec.ReportDir = replaceEnvWithValue(s.spec.ReportDir, envMap)
+	s.spec.CoveragePath = replaceEnvWithValue(s.spec.CoveragePath, envMap)
 
 	reportDir := filepath.Join(s.workspace, s.spec.ReportDir)
-	failedCaseCount, err := mergeGinkgoTestResults(s.spec.FileName, reportDir, s.spec.DestDir, time.Now())
+	var (
+		summaryResult *meta.TestSuite
+		err           error
+	)
+	switch s.spec.ReportFormat {
+	case types.TestReportFormatGoTestJSON, types.TestReportFormatTestNG:
+		summaryResult, err = mergeTestReports(s.spec.ReportFormat, s.spec.FileName, reportDir, s.spec.DestDir)
+	default:
+		summaryResult, err = mergeGinkgoTestResults(s.spec.FileName, reportDir, s.spec.DestDir, time.Now())
+	}
 	if err != nil {
 		return fmt.Errorf("failed to merge test result: %s", err)
 	}
 	log.Info("Finish merge ginkgo test results.")
 
+	if err := s.writeTestResult(summaryResult); err != nil {
+		return fmt.Errorf("failed to write test result: %s", err)
+	}
+
 	log.Infof("Start archive %s.", s.spec.FileName)
 	if s.spec.S3DestDir == "" || s.spec.FileName == "" {
 		return nil
@@ -113,30 +129,44 @@ func (s *JunitReportStep) Run(ctx context.Context) error {
 			return err
 		}
 	}
+	resultKey := filepath.Join(s.spec.S3DestDir, step.TestResultFileName)
+	if err := client.Upload(s.spec.S3Storage.Bucket, path.Join(s.spec.DestDir, step.TestResultFileName), resultKey); err != nil {
+		return err
+	}
 	log.Infof("Finish archive %s.", s.spec.FileName)
-	if failedCaseCount > 0 {
-		return fmt.Errorf("%d case(s) failed", failedCaseCount)
+	if summaryResult.Failures > 0 {
+		return fmt.Errorf("%d case(s) failed", summaryResult.Failures)
 	}
 	return nil
 }
 
-func mergeGinkgoTestResults(testResultFile, testResultPath, testUploadPath string, startTime time.Time) (int, error) {
-	var (
-		err           error
-		newXMLBytes   []byte
-		summaryResult = &meta.TestSuite{
-			TestCases: []meta.TestCase{},
-		}
-		failedCaseCount int
-	)
+// writeTestResult writes the per case results and the coverage into the dest dir to be uploaded with the merged report.
+func (s *JunitReportStep) writeTestResult(summaryResult *meta.TestSuite) error {
+	commitID, err := testreport.ResolveCommitID(s.spec, s.workspace)
+	if err != nil {
+		log.Warnf("Failed to get the commit of %s: %s", s.spec.RepoDir, err)
+	}
+	result := testreport.NewTestResultReport(commitID, summaryResult.TestCases)
+	if result.Coverage, err = testreport.ResolveCoverage(s.spec, s.workspace); err != nil {
+		log.Warnf("Failed to parse coverage report %s: %s", s.spec.CoveragePath, err)
+	} else if result.Coverage != nil {
+		log.Infof("Line coverage: %.2f%%, branch coverage: %.2f%%.", result.Coverage.LineRate*100, result.Coverage.BranchRate*100)
+	}
+	return testreport.WriteTestResultReport(s.spec.DestDir, result)
+}
+
+func mergeGinkgoTestResults(testResultFile, testResultPath, testUploadPath string, startTime time.Time) (*meta.TestSuite, error) {
+	summaryResult := &meta.TestSuite{
+		TestCases: []meta.TestCase{},
+	}
 
 	if len(testResultPath) == 0 {
-		return failedCaseCount, nil
+		return summaryResult, nil
 	}
 
 	files, err := ioutil.ReadDir(testResultPath)
 	if err != nil || len(files) == 0 {
-		return failedCaseCount, fmt.Errorf("test result files not found in path %s", testResultPath)
+		return summaryResult, fmt.Errorf("test result files not found in path %s", testResultPath)
 	}
 
 	// sort and process xml files by modified time
@@ -200,6 +230,69 @@ func mergeGinkgoTestResults(testResultFile, testResultPath, testUploadPath strin
 		}
 	}
 	summaryResult.Time = getSecondSince(startTime)
+	return summaryResult, writeTestSuite(summaryResult, testResultFile, testUploadPath)
+}
+
+// mergeTestReports converts the go test -json or testng reports in the path into a merged junit report.
+func mergeTestReports(format, testResultFile, testResultPath, testUploadPath string) (*meta.TestSuite, error) {
+	summaryResult := &meta.TestSuite{
+		TestCases: []meta.TestCase{},
+		SuiteType: ReploaceTestSuite,
+	}
+
+	files, err := ioutil.ReadDir(testResultPath)
+	if err != nil || len(files) == 0 {
+		return summaryResult, fmt.Errorf("test result files not found in path %s", testResultPath)
+	}
+
+	ext := ".xml"
+	if format == types.TestReportFormatGoTestJSON {
+		ext = ".json"
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ext {
+			continue
+		}
+		filePath := path.Join(testResultPath, file.Name())
+		reportBytes, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			log.Warningf("Read file [%s], error: %v", filePath, err)
+			continue
+		}
+		results, err := testreport.ParseTestCases(format, reportBytes)
+		if err != nil {
+			log.Warningf("Parse file [%s], error: %v", filePath, err)
+			continue
+		}
+
+		for _, result := range results {
+			testCase := meta.TestCase{
+				Name:      result.Name,
+				ClassName: result.Suite,
+				Time:      result.Duration,
+			}
+			switch result.Status {
+			case types.TestCaseStatusFailed:
+				testCase.Failure = &meta.Failure{Message: result.FailureMessage}
+				summaryResult.Failures++
+			case types.TestCaseStatusError:
+				testCase.Error = &meta.Error{Message: result.FailureMessage}
+				summaryResult.Errors++
+			case types.TestCaseStatusSkipped:
+				testCase.Skipped = &meta.Skipped{}
+				summaryResult.Skips++
+			}
+			if testCase.Skipped == nil {
+				summaryResult.Tests++
+			}
+			summaryResult.Time += result.Duration
+			summaryResult.TestCases = append(summaryResult.TestCases, testCase)
+		}
+	}
+	return summaryResult, writeTestSuite(summaryResult, testResultFile, testUploadPath)
+}
+
+func writeTestSuite(summaryResult *meta.TestSuite, testResultFile, testUploadPath string) error {
 	if summaryResult.SuiteType == ReploaceTestSuite {
 		summaryResult.Successes = summaryResult.Tests - summaryResult.Failures - summaryResult.Errors
 		summaryResult.Tests = summaryResult.Tests + summaryResult.Skips
@@ -207,9 +300,9 @@ func mergeGinkgoTestResults(testResultFile, testResultPath, testUploadPath strin
 		summaryResult.Successes = summaryResult.Tests - summaryResult.Failures - summaryResult.Errors - summaryResult.Skips
 	}
 	// 1. xml marshal indent
-	newXMLBytes, err = xml.MarshalIndent(summaryResult, "  ", "    ")
+	newXMLBytes, err := xml.MarshalIndent(summaryResult, "  ", "    ")
 	if err != nil {
-		return failedCaseCount, err
+		return err
 	}
 	// 2. append header
 	newXMLBytes = append([]byte(xml.Header), newXMLBytes...)
@@ -220,11 +313,11 @@ func mergeGinkgoTestResults(testResultFile, testResultPath, testUploadPath strin
 	//4. write xml bytes into file
 	err = ioutil.WriteFile(path.Join(testUploadPath, testResultFile), []byte(newXMLStr), 0644)
 	if err != nil {
-		return failedCaseCount, err
+		return err
 	}
 
 	log.Infof("merge test results files %s succeeded", testResultFile)
-	return summaryResult.Failures, nil
+	return nil
 }
 
 func getSecondSince(startTime time.Time) float64 {
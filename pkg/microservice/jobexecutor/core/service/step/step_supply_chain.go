@@ -0,0 +1,246 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const (
+	cosignExe = "/executor/cosign"
+	syftExe   = "/executor/syft"
+)
+
+type SupplyChainStep struct {
+	spec       *step.StepSupplyChainSpec
+	envs       []string
+	secretEnvs []string
+	workspace  string
+}
+
+func NewSupplyChainStep(spec interface{}, workspace string, envs, secretEnvs []string) (*SupplyChainStep, error) {
+	supplyChainStep := &SupplyChainStep{workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return supplyChainStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &supplyChainStep.spec); err != nil {
+		return supplyChainStep, fmt.Errorf("unmarshal spec %s to supply chain spec failed", yamlBytes)
+	}
+	return supplyChainStep, nil
+}
+
+func (s *SupplyChainStep) Run(ctx context.Context) error {
+	start := time.Now()
+	log.Infof("Start generating supply chain artifacts.")
+	defer func() {
+		log.Infof("Supply chain artifacts ended. Duration: %.2f seconds.", time.Since(start).Seconds())
+	}()
+
+	envMap := makeEnvMap(s.envs, s.secretEnvs)
+	image := replaceEnvWithValue(s.spec.ImageName, envMap)
+
+	tmpDir, err := os.MkdirTemp("", "supply-chain")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := writeDockerConfig(tmpDir, s.spec.DockerRegistry); err != nil {
+		return err
+	}
+	envs := append(s.envs, "DOCKER_CONFIG="+tmpDir)
+	// the signing key is mounted from the secret of the job
+	keyFile := filepath.Join(step.SigningKeyDir, step.SigningKeyFile)
+	if s.spec.NeedKey() {
+		password, err := os.ReadFile(filepath.Join(step.SigningKeyDir, step.SigningKeyPasswordFile))
+		if err != nil {
+			return fmt.Errorf("failed to read signing key password: %s", err)
+		}
+		envs = append(envs, "COSIGN_PASSWORD="+string(password))
+	}
+
+	// everything is attached to the digest, the tag may be moved by later builds
+	ref, err := s.cosign(ctx, envs, "triangulate", "--type", "digest", image)
+	if err != nil {
+		return fmt.Errorf("failed to resolve the digest of %s: %s", image, err)
+	}
+	outputs := map[string]string{step.SupplyChainOutputDigest: ref[strings.LastIndex(ref, "@")+1:]}
+	fmt.Printf("Image %s resolved to %s.\n", image, ref)
+
+	if s.spec.SBOMFormat != "" {
+		sbomFile := filepath.Join(tmpDir, "sbom.json")
+		fmt.Printf("Generating %s SBOM.\n", s.spec.SBOMFormat)
+		cmd := exec.CommandContext(ctx, syftExe, "packages", ref, "-q", "-o", syftOutput(s.spec.SBOMFormat)+"="+sbomFile)
+		cmd.Env = envs
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to generate SBOM: %s %s", err, out)
+		}
+		// the SBOM is an attestation when there is a key, otherwise it is attached unsigned
+		if s.spec.NeedKey() {
+			if _, err := s.cosign(ctx, envs, "attest", "--key", keyFile, "--type", cosignSBOMType(s.spec.SBOMFormat), "--predicate", sbomFile, "--yes", "--tlog-upload=false", ref); err != nil {
+				return fmt.Errorf("failed to attest SBOM: %s", err)
+			}
+			outputs[step.SupplyChainOutputSBOM], err = s.cosign(ctx, envs, "triangulate", "--type", "attestation", ref)
+		} else {
+			if _, err := s.cosign(ctx, envs, "attach", "sbom", "--sbom", sbomFile, "--type", s.spec.SBOMFormat, ref); err != nil {
+				return fmt.Errorf("failed to attach SBOM: %s", err)
+			}
+			outputs[step.SupplyChainOutputSBOM], err = s.cosign(ctx, envs, "triangulate", "--type", "sbom", ref)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to locate SBOM: %s", err)
+		}
+	}
+
+	if s.spec.Sign {
+		fmt.Printf("Signing %s.\n", ref)
+		if _, err := s.cosign(ctx, envs, "sign", "--key", keyFile, "--yes", "--tlog-upload=false", ref); err != nil {
+			return fmt.Errorf("failed to sign image: %s", err)
+		}
+		if outputs[step.SupplyChainOutputSignature], err = s.cosign(ctx, envs, "triangulate", "--type", "signature", ref); err != nil {
+			return fmt.Errorf("failed to locate signature: %s", err)
+		}
+	}
+
+	if s.spec.Provenance {
+		fmt.Printf("Attesting provenance of %s.\n", ref)
+		predicate, err := json.Marshal(provenancePredicate(s.spec.BuildContext, s.resolveCommits(), start, time.Now()))
+		if err != nil {
+			return err
+		}
+		predicateFile := filepath.Join(tmpDir, "provenance.json")
+		if err := os.WriteFile(predicateFile, predicate, 0644); err != nil {
+			return fmt.Errorf("failed to write provenance: %s", err)
+		}
+		if _, err := s.cosign(ctx, envs, "attest", "--key", keyFile, "--type", "slsaprovenance", "--predicate", predicateFile, "--yes", "--tlog-upload=false", ref); err != nil {
+			return fmt.Errorf("failed to attest provenance: %s", err)
+		}
+		if outputs[step.SupplyChainOutputAttestation], err = s.cosign(ctx, envs, "triangulate", "--type", "attestation", ref); err != nil {
+			return fmt.Errorf("failed to locate attestation: %s", err)
+		}
+	}
+
+	for key, value := range outputs {
+		if err := os.WriteFile(path.Join(job.JobOutputDir, key), []byte(value), 0644); err != nil {
+			return fmt.Errorf("failed to write output %s: %s", key, err)
+		}
+	}
+	return nil
+}
+
+func (s *SupplyChainStep) cosign(ctx context.Context, envs []string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, cosignExe, args...)
+	cmd.Env = envs
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s %s", err, stderr.String())
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// resolveCommits returns the checked out commits of the repos, the commits in the spec are used if they can't be read.
+func (s *SupplyChainStep) resolveCommits() map[string]string {
+	resp := map[string]string{}
+	if s.spec.BuildContext == nil {
+		return resp
+	}
+	for _, repo := range s.spec.BuildContext.Repos {
+		resp[repo.URL] = repo.CommitID
+		out, err := exec.Command("git", "-C", filepath.Join(s.workspace, repo.Path), "rev-parse", "HEAD").Output()
+		if err == nil {
+			resp[repo.URL] = strings.TrimSpace(string(out))
+		}
+	}
+	return resp
+}
+
+func syftOutput(format string) string {
+	if format == step.SBOMFormatCycloneDX {
+		return "cyclonedx-json"
+	}
+	return "spdx-json"
+}
+
+func cosignSBOMType(format string) string {
+	if format == step.SBOMFormatCycloneDX {
+		return "cyclonedx"
+	}
+	return "spdxjson"
+}
+
+// provenancePredicate builds the SLSA v0.2 provenance of the build.
+func provenancePredicate(buildCtx *step.SupplyChainBuildContext, commits map[string]string, start, end time.Time) map[string]interface{} {
+	if buildCtx == nil {
+		buildCtx = &step.SupplyChainBuildContext{}
+	}
+	materials := make([]map[string]interface{}, 0)
+	for _, repo := range buildCtx.Repos {
+		material := map[string]interface{}{"uri": repo.URL}
+		if commit := commits[repo.URL]; commit != "" {
+			material["digest"] = map[string]string{"sha1": commit}
+		}
+		materials = append(materials, material)
+	}
+	if buildCtx.BuilderImage != "" {
+		materials = append(materials, map[string]interface{}{"uri": "docker://" + buildCtx.BuilderImage})
+	}
+
+	invocation := map[string]interface{}{
+		"parameters": map[string]interface{}{
+			"workflow_name": buildCtx.WorkflowName,
+			"task_id":       buildCtx.TaskID,
+			"job_name":      buildCtx.JobName,
+		},
+	}
+	if len(buildCtx.Repos) > 0 {
+		repo := buildCtx.Repos[0]
+		configSource := map[string]interface{}{"uri": repo.URL, "entryPoint": buildCtx.WorkflowName}
+		if commit := commits[repo.URL]; commit != "" {
+			configSource["digest"] = map[string]string{"sha1": commit}
+		}
+		invocation["configSource"] = configSource
+	}
+
+	return map[string]interface{}{
+		"builder":    map[string]string{"id": buildCtx.BuilderImage},
+		"buildType":  step.ZadigBuildType,
+		"invocation": invocation,
+		"metadata": map[string]interface{}{
+			"buildInvocationId": fmt.Sprintf("%s/%d/%s", buildCtx.WorkflowName, buildCtx.TaskID, buildCtx.JobName),
+			"buildStartedOn":    start.UTC().Format(time.RFC3339),
+			"buildFinishedOn":   end.UTC().Format(time.RFC3339),
+		},
+		"materials": materials,
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"testing"
+	"time"
+
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+func TestProvenancePredicate(t *testing.T) {
+	buildCtx := &step.SupplyChainBuildContext{
+		WorkflowName: "build-app",
+		TaskID:       3,
+		JobName:      "build",
+		BuilderImage: "koderover.tencentcloudcr.com/koderover-public/build-base:focal",
+		Repos: []*step.SupplyChainRepo{
+			{URL: "https://github.com/koderover/zadig", Path: "zadig"},
+			{URL: "https://github.com/koderover/zadig-doc", Path: "zadig-doc"},
+		},
+	}
+	start := time.Unix(0, 0)
+	predicate := provenancePredicate(buildCtx, map[string]string{"https://github.com/koderover/zadig": "abc"}, start, start.Add(time.Minute))
+
+	materials := predicate["materials"].([]map[string]interface{})
+	if len(materials) != 3 {
+		t.Fatalf("unexpected materials %v", materials)
+	}
+	if digest, ok := materials[0]["digest"].(map[string]string); !ok || digest["sha1"] != "abc" {
+		t.Errorf("unexpected digest of the first repo %v", materials[0])
+	}
+	if _, ok := materials[1]["digest"]; ok {
+		t.Errorf("unexpected digest of the second repo %v", materials[1])
+	}
+	metadata := predicate["metadata"].(map[string]interface{})
+	if metadata["buildInvocationId"] != "build-app/3/build" || metadata["buildFinishedOn"] != "1970-01-01T00:01:00Z" {
+		t.Errorf("unexpected metadata %v", metadata)
+	}
+}
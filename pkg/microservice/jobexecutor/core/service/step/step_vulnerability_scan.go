@@ -0,0 +1,146 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/tool/trivy"
+	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const trivyExe = "/executor/trivy"
+
+type VulnerabilityScanStep struct {
+	spec       *step.StepVulnerabilityScanSpec
+	envs       []string
+	secretEnvs []string
+	workspace  string
+}
+
+func NewVulnerabilityScanStep(spec interface{}, workspace string, envs, secretEnvs []string) (*VulnerabilityScanStep, error) {
+	vulnerabilityScanStep := &VulnerabilityScanStep{workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return vulnerabilityScanStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &vulnerabilityScanStep.spec); err != nil {
+		return vulnerabilityScanStep, fmt.Errorf("unmarshal spec %s to vulnerability scan spec failed", yamlBytes)
+	}
+	return vulnerabilityScanStep, nil
+}
+
+func (s *VulnerabilityScanStep) Run(ctx context.Context) error {
+	start := time.Now()
+	log.Infof("Start vulnerability scanning.")
+	defer func() {
+		log.Infof("Vulnerability scanning ended. Duration: %.2f seconds.", time.Since(start).Seconds())
+	}()
+
+	envMap := makeEnvMap(s.envs, s.secretEnvs)
+	s.spec.Image = replaceEnvWithValue(s.spec.Image, envMap)
+	s.spec.ScanDir = replaceEnvWithValue(s.spec.ScanDir, envMap)
+
+	tmpDir, err := os.MkdirTemp("", "vulnerability")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	reportFile := filepath.Join(tmpDir, step.VulnerabilityReportFileName)
+	if err := trivy.Scan(ctx, trivyExe, s.spec, s.workspace, s.envs, reportFile); err != nil {
+		return err
+	}
+
+	report, err := os.ReadFile(reportFile)
+	if err != nil {
+		return fmt.Errorf("failed to read vulnerability report: %s", err)
+	}
+	findings, err := trivy.ParseReport(report)
+	if err != nil {
+		return err
+	}
+	summary := trivy.Summarize(findings)
+	violations := trivy.CheckQualityGate(s.spec.QualityGate, findings)
+	fmt.Printf("Vulnerabilities found: %d critical, %d high, %d medium, %d low, %d unknown, %d fixable.\n",
+		summary.Critical, summary.High, summary.Medium, summary.Low, summary.Unknown, summary.Fixable)
+
+	if err := s.uploadReport(reportFile); err != nil {
+		return err
+	}
+	if err := writeVulnerabilityOutputs(job.JobOutputDir, summary, violations); err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("vulnerability quality gate failed: %s", strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+func (s *VulnerabilityScanStep) uploadReport(reportFile string) error {
+	if s.spec.S3Storage == nil || s.spec.S3DestDir == "" {
+		return nil
+	}
+	forcedPathStyle := true
+	if s.spec.S3Storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3.NewClient(s.spec.S3Storage.Endpoint, s.spec.S3Storage.Ak, s.spec.S3Storage.Sk, s.spec.S3Storage.Region, s.spec.S3Storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return fmt.Errorf("failed to create s3 client to upload vulnerability report, err: %s", err)
+	}
+	key := strings.TrimLeft(path.Join(s.spec.S3Storage.Subfolder, s.spec.S3DestDir, step.VulnerabilityReportFileName), "/")
+	if err := client.Upload(s.spec.S3Storage.Bucket, reportFile, key); err != nil {
+		return fmt.Errorf("failed to upload vulnerability report: %s", err)
+	}
+	return nil
+}
+
+func writeVulnerabilityOutputs(dir string, summary *types.VulnerabilitySummary, violations []string) error {
+	gate := step.VulnerabilityQualityGatePassed
+	if len(violations) > 0 {
+		gate = step.VulnerabilityQualityGateFailed
+	}
+	outputs := map[string]string{
+		step.VulnerabilityOutputCritical:    strconv.Itoa(summary.Critical),
+		step.VulnerabilityOutputHigh:        strconv.Itoa(summary.High),
+		step.VulnerabilityOutputMedium:      strconv.Itoa(summary.Medium),
+		step.VulnerabilityOutputLow:         strconv.Itoa(summary.Low),
+		step.VulnerabilityOutputFixable:     strconv.Itoa(summary.Fixable),
+		step.VulnerabilityOutputTotal:       strconv.Itoa(summary.Total),
+		step.VulnerabilityOutputQualityGate: gate,
+	}
+	for key, value := range outputs {
+		if err := os.WriteFile(path.Join(dir, key), []byte(value), 0644); err != nil {
+			return fmt.Errorf("failed to write output %s: %s", key, err)
+		}
+	}
+	return nil
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/reaper/config"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
 )
 
 type Context struct {
@@ -140,6 +141,8 @@ type Context struct {
 	SonarServer           string `yaml:"sonar_server"`
 	SonarLogin            string `yaml:"sonar_login"`
 	SonarCheckQualityGate bool   `yaml:"sonar_check_quality_gate"`
+	// VulnerabilityScan is for trivy type only
+	VulnerabilityScan *step.StepVulnerabilityScanSpec `yaml:"vulnerability_scan"`
 }
 
 type ArtifactInfo struct {
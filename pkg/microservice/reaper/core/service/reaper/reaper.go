@@ -414,6 +414,16 @@ func (r *Reaper) Exec() (err error) {
 		log.Infof("Sonar scan ended. Duration %.2f seconds.", time.Since(startTimeRunSonar).Seconds())
 	}
 
+	if r.Ctx.ScannerFlag && r.Ctx.ScannerType == types.ScanningTypeTrivy && r.Ctx.VulnerabilityScan != nil {
+		log.Info("Executing vulnerability scanning process.")
+		startTimeRunTrivy := time.Now()
+		if err = r.runVulnerabilityScan(); err != nil {
+			err = fmt.Errorf("failed to execute vulnerability scanning process, the error is: %s", err)
+			return
+		}
+		log.Infof("Vulnerability scan ended. Duration %.2f seconds.", time.Since(startTimeRunTrivy).Seconds())
+	}
+
 	err = r.runDockerBuild()
 	return
 }
@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reaper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/microservice/reaper/internal/s3"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/trivy"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const trivyExe = "/executor/trivy"
+
+// runVulnerabilityScan scans the configured image or directory with trivy, uploads the json
+// report to the default storage so aslan can display it, and fails if the quality gate is not met.
+func (r *Reaper) runVulnerabilityScan() error {
+	spec := r.Ctx.VulnerabilityScan
+	spec.Image = r.renderUserEnv(spec.Image)
+	spec.ScanDir = r.renderUserEnv(spec.ScanDir)
+
+	tmpDir, err := os.MkdirTemp("", "vulnerability")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	reportFile := filepath.Join(tmpDir, step.VulnerabilityReportFileName)
+	if err := trivy.Scan(context.Background(), trivyExe, spec, r.ActiveWorkspace, r.getUserEnvs(), reportFile); err != nil {
+		return err
+	}
+
+	report, err := os.ReadFile(reportFile)
+	if err != nil {
+		return fmt.Errorf("failed to read vulnerability report: %s", err)
+	}
+	findings, err := trivy.ParseReport(report)
+	if err != nil {
+		return err
+	}
+	summary := trivy.Summarize(findings)
+	fmt.Printf("Vulnerabilities found: %d critical, %d high, %d medium, %d low, %d unknown, %d fixable.\n",
+		summary.Critical, summary.High, summary.Medium, summary.Low, summary.Unknown, summary.Fixable)
+
+	if r.Ctx.StorageURI != "" && spec.S3DestDir != "" {
+		store, err := s3.UnmarshalNewS3StorageFromEncrypted(r.Ctx.StorageURI, r.Ctx.AesKey)
+		if err != nil {
+			log.Errorf("failed to create s3 storage, err: %s", err)
+			return err
+		}
+		key := strings.TrimLeft(path.Join(store.Subfolder, spec.S3DestDir, step.VulnerabilityReportFileName), "/")
+		if err := s3FileUpload(store, reportFile, key); err != nil {
+			return fmt.Errorf("failed to upload vulnerability report: %s", err)
+		}
+	}
+
+	if violations := trivy.CheckQualityGate(spec.QualityGate, findings); len(violations) > 0 {
+		return fmt.Errorf("vulnerability quality gate failed: %s", strings.Join(violations, "; "))
+	}
+	return nil
+}
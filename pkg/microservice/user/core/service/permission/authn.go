@@ -31,6 +31,9 @@ import (
 const (
 	larkWebhookURLRegExp         = `^\/api\/aslan\/system\/lark\/\w+\/webhook$`
 	dingTalkWebhookURLRegExp     = `^\/api\/aslan\/system\/dingtalk\/\w+\/webhook$`
+	approvalWebhookURLRegExp     = `^\/api\/aslan\/system\/approval_webhook\/\w+\/callback$`
+	envWakeURLRegExp             = `^\/api\/aslan\/environment\/wake\/\w+$`
+	envTTLExtendURLRegExp        = `^\/api\/aslan\/environment\/ttl\/extend\/\w+$`
 	getClusterAgentYamlURLRegExp = `^\/api\/aslan\/cluster\/agent\/\w+\/agent.yaml$`
 	envWorkloadUrlRegExp         = `^\/api\/aslan\/environment\/environments\/[\w-]+\/check\/workloads\/k8services$`
 	envShareEnableURLRegExp      = `^\/api\/aslan\/environment\/environments\/[\w-]+\/check\/sharenv\/enable\/ready$`
@@ -185,6 +188,10 @@ func IsPublicURL(reqPath, method string) bool {
 		return true
 	}
 
+	if realPath == "/api/aslan/vm/agents/job/stream" && method == http.MethodGet {
+		return true
+	}
+
 	if realPath == "/api/v1/callback" {
 		return true
 	}
@@ -216,6 +223,21 @@ func IsPublicURL(reqPath, method string) bool {
 		return true
 	}
 
+	match, _ = regexp.MatchString(approvalWebhookURLRegExp, realPath)
+	if match && method == http.MethodPost {
+		return true
+	}
+
+	match, _ = regexp.MatchString(envWakeURLRegExp, realPath)
+	if match && method == http.MethodPost {
+		return true
+	}
+
+	match, _ = regexp.MatchString(envTTLExtendURLRegExp, realPath)
+	if match && method == http.MethodGet {
+		return true
+	}
+
 	match, _ = regexp.MatchString(getClusterAgentYamlURLRegExp, realPath)
 	if match && method == http.MethodGet {
 		return true
@@ -72,6 +72,7 @@ const (
 	ScanningTaskTimeout = 60 * 60 // 60 minutes
 	ScanningTypeSonar   = "sonarQube"
 	ScanningTypeOther   = "other"
+	ScanningTypeTrivy   = "trivy"
 )
 
 func (p *ScanPlugin) Init(jobname, filename string, xl *zap.SugaredLogger) {
@@ -203,6 +204,9 @@ func (p *ScanPlugin) Run(ctx context.Context, pipelineTask *task.Task, pipelineC
 		reaperContext.SonarLogin = p.Task.SonarInfo.Token
 		reaperContext.ScannerType = ScanningTypeSonar
 		reaperContext.SonarCheckQualityGate = p.Task.CheckQualityGate
+	} else if p.Task.VulnerabilityScan != nil {
+		reaperContext.ScannerType = ScanningTypeTrivy
+		reaperContext.VulnerabilityScan = p.Task.VulnerabilityScan
 	} else {
 		reaperContext.ScannerType = ScanningTypeOther
 	}
@@ -21,6 +21,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/warpdrive/config"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
 )
 
 type Scanning struct {
@@ -55,4 +56,6 @@ type Scanning struct {
 	// Script is for other type only
 	Script           string `bson:"script"                json:"script"`
 	CheckQualityGate bool   `bson:"check_quality_gate"    json:"check_quality_gate"`
+	// VulnerabilityScan is for trivy type only
+	VulnerabilityScan *step.StepVulnerabilityScanSpec `bson:"vulnerability_scan,omitempty" json:"vulnerability_scan,omitempty"`
 }
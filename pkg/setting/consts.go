@@ -29,6 +29,9 @@ const (
 	ENVAslanDBName             = "ASLAN_DB"
 	ENVHubAgentImage           = "HUB_AGENT_IMAGE"
 	ENVExecutorImage           = "EXECUTOR_IMAGE"
+	ENVBuildKitImage           = "BUILDKIT_IMAGE"
+	ENVKanikoImage             = "KANIKO_IMAGE"
+	ENVWakeProxyImage          = "WAKE_PROXY_IMAGE"
 	ENVMysqlUser               = "MYSQL_USER"
 	ENVMysqlPassword           = "MYSQL_PASSWORD"
 	ENVMysqlHost               = "MYSQL_HOST"
@@ -365,6 +368,7 @@ const (
 	WorkflowTaskFmt   = "WorkflowTask:%s"
 	WorkflowTaskV3Fmt = "WorkflowTaskV3:%s"
 	WorkflowTaskV4Fmt = "WorkflowTaskV4:%s"
+	WorkflowV4RevFmt  = "WorkflowV4Revision:%s"
 	TestTaskFmt       = "TestTask:%s"
 	ServiceTaskFmt    = "ServiceTask:%s"
 	ScanningTaskFmt   = "ScanningTask:%s"
@@ -549,9 +553,12 @@ const (
 	TestingCronjob     = "test"
 	EnvAnalysisCronjob = "env_analysis"
 	EnvSleepCronjob    = "env_sleep"
+	EnvDriftCronjob    = "env_drift"
 
 	TopicProcess      = "task.process"
 	TopicCancel       = "task.cancel"
+	TopicApprove      = "task.approve"
+	TopicTaskCancel   = "task.workflow.cancel"
 	TopicAck          = "task.ack"
 	TopicItReport     = "task.it.report"
 	TopicNotification = "task.notification"
@@ -780,6 +787,9 @@ const (
 const (
 	IMLark     = "lark"
 	IMDingTalk = "dingtalk"
+	IMWeCom    = "wecom"
+	// IMWebhook is a generic approval system which receives signed approval requests
+	IMWebhook = "webhook"
 )
 
 // lark app
@@ -0,0 +1,49 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dingtalk
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+type markdownMessageParam struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+type BatchSendMessageResponse struct {
+	ProcessQueryKey string `json:"processQueryKey"`
+}
+
+// SendMarkdownMessage sends a markdown message to the users as the app robot,
+// the robot code of an internal app is its app key
+func (c *Client) SendMarkdownMessage(userIDs []string, title, text string) error {
+	param, err := json.Marshal(markdownMessageParam{Title: title, Text: text})
+	if err != nil {
+		return errors.Wrap(err, "marshal message param")
+	}
+	_, err = c.R().SetBodyJsonMarshal(map[string]interface{}{
+		"robotCode": c.AppKey,
+		"userIds":   userIDs,
+		"msgKey":    "sampleMarkdown",
+		"msgParam":  string(param),
+	}).SetSuccessResult(&BatchSendMessageResponse{}).
+		Post("https://api.dingtalk.com/v1.0/robot/oToMessages/batchSend")
+	return err
+}
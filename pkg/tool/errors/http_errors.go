@@ -228,6 +228,19 @@ var (
 	ErrAnalysisEnvResource      = NewHTTPError(6151, "AI环境巡检失败")
 	ErrListPod                  = NewHTTPError(6152, "列出Pod失败")
 	ErrGetPodDetail             = NewHTTPError(6153, "获取Pod详情失败")
+	ErrDetectEnvDrift           = NewHTTPError(6154, "环境漂移检测失败")
+	ErrGetEnvDriftReport        = NewHTTPError(6155, "获取环境漂移报告失败")
+	ErrUpdateEnvDriftConfig     = NewHTTPError(6156, "更新环境漂移检测配置失败")
+	ErrUpdateEnvTTLPolicy       = NewHTTPError(6157, "更新环境过期回收策略失败")
+	ErrExtendEnvTTL             = NewHTTPError(6158, "延长环境有效期失败")
+	ErrGetEnvReclaimReport      = NewHTTPError(6159, "获取环境回收报告失败")
+	ErrCreateEnvSnapshot        = NewHTTPError(6182, "创建环境快照失败")
+	ErrListEnvSnapshots         = NewHTTPError(6183, "列出环境快照失败")
+	ErrRestoreEnvSnapshot       = NewHTTPError(6184, "恢复环境快照失败")
+	ErrGetEnvPreviewPolicy      = NewHTTPError(6185, "获取预览环境策略失败")
+	ErrUpdateEnvPreviewPolicy   = NewHTTPError(6186, "更新预览环境策略失败")
+	ErrListEnvPreviews          = NewHTTPError(6187, "列出预览环境失败")
+	ErrCreateEnvPreview         = NewHTTPError(6188, "创建预览环境失败")
 
 	//-----------------------------------------------------------------------------------------------
 	// it report APIs Range: 6100 - 6149
@@ -320,6 +333,9 @@ var (
 
 	// ErrGetDebugShell
 	ErrGetDebugShell = NewHTTPError(6172, "获取调试 Shell 失败")
+
+	// ErrUpdateTaskPriority ...
+	ErrUpdateTaskPriority = NewHTTPError(6173, "修改工作流任务优先级失败")
 	//-----------------------------------------------------------------------------------------------
 	// Keystore APIs Range: 6180 - 6189
 	//-----------------------------------------------------------------------------------------------
@@ -0,0 +1,56 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lark
+
+import (
+	"context"
+	"encoding/json"
+
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+	"github.com/pkg/errors"
+
+	"github.com/koderover/zadig/pkg/setting"
+)
+
+const (
+	MessageTypeText = "text"
+)
+
+// SendTextMessage sends a text message to the user with the given open id
+func (client *Client) SendTextMessage(openID, text string) error {
+	content, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return errors.Wrap(err, "marshal message content")
+	}
+	req := larkim.NewCreateMessageReqBuilder().
+		ReceiveIdType(setting.LarkUserOpenID).
+		Body(larkim.NewCreateMessageReqBodyBuilder().
+			ReceiveId(openID).
+			MsgType(MessageTypeText).
+			Content(string(content)).
+			Build()).
+		Build()
+
+	resp, err := client.Im.Message.Create(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	if !resp.Success() {
+		return resp.CodeError
+	}
+	return nil
+}
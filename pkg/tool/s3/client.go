@@ -22,6 +22,7 @@ import (
 	"mime"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -295,3 +296,34 @@ func (c *Client) ListFiles(bucketName, prefix string, recursive bool) ([]string,
 
 	return ret, nil
 }
+
+// ObjectInfo is the key, size and last modified time of an object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// ListObjectInfos lists all the objects with given prefix recursively, page by page.
+func (c *Client) ListObjectInfos(bucketName, prefix string) ([]*ObjectInfo, error) {
+	ret := make([]*ObjectInfo, 0)
+	input := &s3.ListObjectsInput{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(prefix),
+	}
+	err := c.ListObjectsPages(input, func(output *s3.ListObjectsOutput, lastPage bool) bool {
+		for _, item := range output.Contents {
+			ret = append(ret, &ObjectInfo{
+				Key:          aws.StringValue(item.Key),
+				Size:         aws.Int64Value(item.Size),
+				LastModified: aws.TimeValue(item.LastModified),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		log.Errorf("bucket [%s] listing objects with prefix [%v] failed, error: %v", bucketName, prefix, err)
+		return nil, err
+	}
+	return ret, nil
+}
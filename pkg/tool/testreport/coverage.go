@@ -0,0 +1,153 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testreport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// ParseCoverage parses a coverage report of the given format into the overall coverage.
+func ParseCoverage(format string, data []byte) (*types.CoverageSummary, error) {
+	switch format {
+	case types.CoverageFormatCobertura:
+		return ParseCobertura(data)
+	case types.CoverageFormatJacoco:
+		return ParseJacoco(data)
+	case types.CoverageFormatLCOV:
+		return ParseLCOV(data)
+	default:
+		return nil, fmt.Errorf("unsupported coverage format: %s", format)
+	}
+}
+
+type coberturaCoverage struct {
+	LineRate        float64 `xml:"line-rate,attr"`
+	BranchRate      float64 `xml:"branch-rate,attr"`
+	LinesCovered    int     `xml:"lines-covered,attr"`
+	LinesValid      int     `xml:"lines-valid,attr"`
+	BranchesCovered int     `xml:"branches-covered,attr"`
+	BranchesValid   int     `xml:"branches-valid,attr"`
+}
+
+// ParseCobertura parses the summary attributes of the root element of a cobertura report.
+func ParseCobertura(data []byte) (*types.CoverageSummary, error) {
+	coverage := &coberturaCoverage{}
+	if err := xml.Unmarshal(data, coverage); err != nil {
+		return nil, fmt.Errorf("failed to parse cobertura report: %s", err)
+	}
+	resp := &types.CoverageSummary{
+		LinesCovered:    coverage.LinesCovered,
+		LinesValid:      coverage.LinesValid,
+		LineRate:        coverage.LineRate,
+		BranchesCovered: coverage.BranchesCovered,
+		BranchesValid:   coverage.BranchesValid,
+		BranchRate:      coverage.BranchRate,
+	}
+	// some generators only write the rates
+	if coverage.LinesValid > 0 {
+		resp.LineRate = rate(coverage.LinesCovered, coverage.LinesValid)
+	}
+	if coverage.BranchesValid > 0 {
+		resp.BranchRate = rate(coverage.BranchesCovered, coverage.BranchesValid)
+	}
+	return resp, nil
+}
+
+type jacocoReport struct {
+	Counters []*jacocoCounter `xml:"counter"`
+}
+
+type jacocoCounter struct {
+	Type    string `xml:"type,attr"`
+	Missed  int    `xml:"missed,attr"`
+	Covered int    `xml:"covered,attr"`
+}
+
+// ParseJacoco parses the report level LINE and BRANCH counters of a jacoco xml report.
+func ParseJacoco(data []byte) (*types.CoverageSummary, error) {
+	report := &jacocoReport{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// jacoco reports reference an external dtd which is not needed to read the counters
+	decoder.Strict = false
+	if err := decoder.Decode(report); err != nil {
+		return nil, fmt.Errorf("failed to parse jacoco report: %s", err)
+	}
+	resp := &types.CoverageSummary{}
+	for _, counter := range report.Counters {
+		switch counter.Type {
+		case "LINE":
+			resp.LinesCovered = counter.Covered
+			resp.LinesValid = counter.Covered + counter.Missed
+			resp.LineRate = rate(resp.LinesCovered, resp.LinesValid)
+		case "BRANCH":
+			resp.BranchesCovered = counter.Covered
+			resp.BranchesValid = counter.Covered + counter.Missed
+			resp.BranchRate = rate(resp.BranchesCovered, resp.BranchesValid)
+		}
+	}
+	return resp, nil
+}
+
+// ParseLCOV sums the LF/LH and BRF/BRH records of all the source files in a lcov tracefile.
+func ParseLCOV(data []byte) (*types.CoverageSummary, error) {
+	resp := &types.CoverageSummary{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !found {
+			continue
+		}
+		var counter *int
+		switch key {
+		case "LF":
+			counter = &resp.LinesValid
+		case "LH":
+			counter = &resp.LinesCovered
+		case "BRF":
+			counter = &resp.BranchesValid
+		case "BRH":
+			counter = &resp.BranchesCovered
+		default:
+			continue
+		}
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lcov record %s: %s", scanner.Text(), err)
+		}
+		*counter += count
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read lcov report: %s", err)
+	}
+	resp.LineRate = rate(resp.LinesCovered, resp.LinesValid)
+	resp.BranchRate = rate(resp.BranchesCovered, resp.BranchesValid)
+	return resp, nil
+}
+
+func rate(covered, valid int) float64 {
+	if valid == 0 {
+		return 0
+	}
+	return float64(covered) / float64(valid)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testreport
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/microservice/reaper/core/service/meta"
+	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+// NewTestResultReport converts the cases of the merged junit report into the per case results.
+func NewTestResultReport(commit string, testCases []meta.TestCase) *types.TestResultReport {
+	result := &types.TestResultReport{
+		Commit: commit,
+		Cases:  make([]*types.TestCaseResult, 0, len(testCases)),
+	}
+	for _, testCase := range testCases {
+		result.Cases = append(result.Cases, toTestCaseResult(testCase))
+	}
+	return result
+}
+
+// WriteTestResultReport writes the result into the dest dir of the junit report step, it is uploaded along with the merged report.
+func WriteTestResultReport(destDir string, result *types.TestResultReport) error {
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(destDir, step.TestResultFileName), resultBytes, 0644)
+}
+
+// ResolveCommitID returns the commit of the junit report step, the commit checked out in the repo dir is used if it is not given.
+func ResolveCommitID(spec *step.StepJunitReportSpec, workspace string) (string, error) {
+	if spec.CommitID != "" || spec.RepoDir == "" {
+		return spec.CommitID, nil
+	}
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = filepath.Join(workspace, spec.RepoDir)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ResolveCoverage parses the coverage report of the junit report step, it returns nil if there is no coverage report.
+func ResolveCoverage(spec *step.StepJunitReportSpec, workspace string) (*types.CoverageSummary, error) {
+	if spec.CoveragePath == "" {
+		return nil, nil
+	}
+	coveragePath := spec.CoveragePath
+	if !filepath.IsAbs(coveragePath) {
+		coveragePath = filepath.Join(workspace, coveragePath)
+	}
+	data, err := os.ReadFile(coveragePath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCoverage(spec.CoverageFormat, data)
+}
+
+func toTestCaseResult(testCase meta.TestCase) *types.TestCaseResult {
+	result := &types.TestCaseResult{
+		Suite:    testCase.ClassName,
+		Name:     testCase.Name,
+		Duration: testCase.Time,
+		Status:   types.TestCaseStatusPassed,
+	}
+	switch {
+	case testCase.Failure != nil:
+		result.Status = types.TestCaseStatusFailed
+		result.FailureMessage = firstNonEmpty(testCase.Failure.Message, testCase.Failure.Text)
+	case testCase.Error != nil:
+		result.Status = types.TestCaseStatusError
+		result.FailureMessage = firstNonEmpty(testCase.Error.Message, testCase.Error.Text)
+	case testCase.Skipped != nil:
+		result.Status = types.TestCaseStatusSkipped
+	}
+	return result
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testreport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// maxFailureMessageLength keeps the failure message of a case small enough to be stored per case.
+const maxFailureMessageLength = 4096
+
+// ParseTestCases parses a test report of the given format into test case results.
+func ParseTestCases(format string, data []byte) ([]*types.TestCaseResult, error) {
+	switch format {
+	case types.TestReportFormatGoTestJSON:
+		return ParseGoTestJSON(data)
+	case types.TestReportFormatTestNG:
+		return ParseTestNG(data)
+	default:
+		return nil, fmt.Errorf("unsupported test report format: %s", format)
+	}
+}
+
+type goTestEvent struct {
+	Action  string  `json:"Action"`
+	Package string  `json:"Package"`
+	Test    string  `json:"Test"`
+	Elapsed float64 `json:"Elapsed"`
+	Output  string  `json:"Output"`
+}
+
+// ParseGoTestJSON parses the output of `go test -json`, lines which are not test events are ignored.
+func ParseGoTestJSON(data []byte) ([]*types.TestCaseResult, error) {
+	resp := make([]*types.TestCaseResult, 0)
+	outputs := make(map[string][]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		event := &goTestEvent{}
+		if err := json.Unmarshal(line, event); err != nil || event.Test == "" {
+			continue
+		}
+
+		key := event.Package + "." + event.Test
+		switch event.Action {
+		case "output":
+			if output := strings.TrimRight(event.Output, "\n"); !isGoTestStatusLine(output) {
+				outputs[key] = append(outputs[key], output)
+			}
+		case "pass", "fail", "skip":
+			result := &types.TestCaseResult{
+				Suite:    event.Package,
+				Name:     event.Test,
+				Duration: event.Elapsed,
+			}
+			switch event.Action {
+			case "pass":
+				result.Status = types.TestCaseStatusPassed
+			case "fail":
+				result.Status = types.TestCaseStatusFailed
+				result.FailureMessage = truncateMessage(strings.Join(outputs[key], "\n"))
+			case "skip":
+				result.Status = types.TestCaseStatusSkipped
+			}
+			delete(outputs, key)
+			resp = append(resp, result)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read go test output: %s", err)
+	}
+	return resp, nil
+}
+
+func isGoTestStatusLine(output string) bool {
+	trimmed := strings.TrimSpace(output)
+	return strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- ")
+}
+
+type testNGResults struct {
+	Suites []*testNGSuite `xml:"suite"`
+}
+
+type testNGSuite struct {
+	Name  string        `xml:"name,attr"`
+	Tests []*testNGTest `xml:"test"`
+}
+
+type testNGTest struct {
+	Name    string         `xml:"name,attr"`
+	Classes []*testNGClass `xml:"class"`
+}
+
+type testNGClass struct {
+	Name    string          `xml:"name,attr"`
+	Methods []*testNGMethod `xml:"test-method"`
+}
+
+type testNGMethod struct {
+	Name       string           `xml:"name,attr"`
+	Status     string           `xml:"status,attr"`
+	DurationMS float64          `xml:"duration-ms,attr"`
+	IsConfig   bool             `xml:"is-config,attr"`
+	Exception  *testNGException `xml:"exception"`
+}
+
+type testNGException struct {
+	Class   string `xml:"class,attr"`
+	Message string `xml:"message"`
+}
+
+// ParseTestNG parses testng-results.xml, configuration methods such as @BeforeClass are not test cases.
+func ParseTestNG(data []byte) ([]*types.TestCaseResult, error) {
+	results := &testNGResults{}
+	if err := xml.Unmarshal(data, results); err != nil {
+		return nil, fmt.Errorf("failed to parse testng report: %s", err)
+	}
+
+	resp := make([]*types.TestCaseResult, 0)
+	for _, suite := range results.Suites {
+		for _, test := range suite.Tests {
+			for _, class := range test.Classes {
+				for _, method := range class.Methods {
+					if method.IsConfig {
+						continue
+					}
+					result := &types.TestCaseResult{
+						Suite:    class.Name,
+						Name:     method.Name,
+						Duration: method.DurationMS / 1000,
+					}
+					switch strings.ToUpper(method.Status) {
+					case "PASS":
+						result.Status = types.TestCaseStatusPassed
+					case "FAIL":
+						result.Status = types.TestCaseStatusFailed
+					default:
+						result.Status = types.TestCaseStatusSkipped
+					}
+					if result.Status == types.TestCaseStatusFailed && method.Exception != nil {
+						message := strings.TrimSpace(method.Exception.Message)
+						if message == "" {
+							message = method.Exception.Class
+						}
+						result.FailureMessage = truncateMessage(message)
+					}
+					resp = append(resp, result)
+				}
+			}
+		}
+	}
+	return resp, nil
+}
+
+func truncateMessage(message string) string {
+	if len(message) > maxFailureMessageLength {
+		return message[:maxFailureMessageLength]
+	}
+	return message
+}
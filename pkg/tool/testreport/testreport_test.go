@@ -0,0 +1,147 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testreport
+
+import (
+	"testing"
+
+	"github.com/koderover/zadig/pkg/microservice/reaper/core/service/meta"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+const goTestJSON = `{"Action":"run","Package":"example.com/app","Test":"TestAdd"}
+{"Action":"output","Package":"example.com/app","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}
+{"Action":"output","Package":"example.com/app","Test":"TestAdd","Output":"    add_test.go:10: expected 3, got 4\n"}
+{"Action":"output","Package":"example.com/app","Test":"TestAdd","Output":"--- FAIL: TestAdd (0.01s)\n"}
+{"Action":"fail","Package":"example.com/app","Test":"TestAdd","Elapsed":0.01}
+{"Action":"pass","Package":"example.com/app","Test":"TestSub","Elapsed":0.2}
+{"Action":"skip","Package":"example.com/app","Test":"TestMul","Elapsed":0}
+{"Action":"fail","Package":"example.com/app","Elapsed":0.3}
+# example.com/app [build output]`
+
+const testNGXML = `<?xml version="1.0" encoding="UTF-8"?>
+<testng-results skipped="0" failed="1" total="2" passed="1">
+  <suite name="Suite">
+    <test name="Test">
+      <class name="com.example.CalculatorTest">
+        <test-method status="PASS" name="setUp" is-config="true" duration-ms="3"/>
+        <test-method status="PASS" name="testAdd" duration-ms="15"/>
+        <test-method status="FAIL" name="testDivide" duration-ms="2500">
+          <exception class="java.lang.AssertionError">
+            <message><![CDATA[expected [2] but found [3]]]></message>
+          </exception>
+        </test-method>
+      </class>
+    </test>
+  </suite>
+</testng-results>`
+
+func TestParseGoTestJSON(t *testing.T) {
+	cases, err := ParseGoTestJSON([]byte(goTestJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 3 {
+		t.Fatalf("expected 3 cases, got %d", len(cases))
+	}
+	if cases[0].Name != "TestAdd" || cases[0].Status != types.TestCaseStatusFailed || cases[0].FailureMessage != "    add_test.go:10: expected 3, got 4" {
+		t.Errorf("unexpected failed case %+v", cases[0])
+	}
+	if cases[1].Status != types.TestCaseStatusPassed || cases[1].Duration != 0.2 || cases[2].Status != types.TestCaseStatusSkipped {
+		t.Errorf("unexpected cases %+v %+v", cases[1], cases[2])
+	}
+}
+
+func TestParseTestNG(t *testing.T) {
+	cases, err := ParseTestNG([]byte(testNGXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 2 {
+		t.Fatalf("expected 2 cases, got %d", len(cases))
+	}
+	failed := cases[1]
+	if failed.Suite != "com.example.CalculatorTest" || failed.Status != types.TestCaseStatusFailed || failed.Duration != 2.5 || failed.FailureMessage != "expected [2] but found [3]" {
+		t.Errorf("unexpected failed case %+v", failed)
+	}
+}
+
+func TestParseCoverage(t *testing.T) {
+	tests := []struct {
+		format   string
+		report   string
+		lineRate float64
+		branches int
+	}{
+		{
+			format:   types.CoverageFormatCobertura,
+			report:   `<coverage line-rate="0.5" branch-rate="0" lines-covered="3" lines-valid="4" branches-covered="1" branches-valid="2"></coverage>`,
+			lineRate: 0.75,
+			branches: 2,
+		},
+		{
+			format: types.CoverageFormatJacoco,
+			report: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><!DOCTYPE report PUBLIC "-//JACOCO//DTD Report 1.1//EN" "report.dtd">
+<report name="app"><package name="com/example"><counter type="LINE" missed="100" covered="100"/></package>
+<counter type="LINE" missed="1" covered="9"/><counter type="BRANCH" missed="2" covered="2"/></report>`,
+			lineRate: 0.9,
+			branches: 4,
+		},
+		{
+			format:   types.CoverageFormatLCOV,
+			report:   "TN:\nSF:a.js\nLF:6\nLH:3\nBRF:2\nBRH:1\nend_of_record\nSF:b.js\nLF:4\nLH:3\nend_of_record\n",
+			lineRate: 0.6,
+			branches: 2,
+		},
+	}
+	for _, tt := range tests {
+		coverage, err := ParseCoverage(tt.format, []byte(tt.report))
+		if err != nil {
+			t.Fatalf("%s: %s", tt.format, err)
+		}
+		if coverage.LineRate != tt.lineRate || coverage.BranchesValid != tt.branches {
+			t.Errorf("%s: unexpected coverage %+v", tt.format, coverage)
+		}
+	}
+}
+
+func TestNewTestResultReport(t *testing.T) {
+	testCases := []meta.TestCase{
+		{Name: "TestAdd", ClassName: "app", Time: 0.1},
+		{Name: "TestSub", ClassName: "app", Failure: &meta.Failure{Text: "  expected 3, got 4 "}},
+		{Name: "TestMul", ClassName: "app", Error: &meta.Error{Message: "panic", Text: "stack"}},
+		{Name: "TestDiv", ClassName: "app", Skipped: &meta.Skipped{}},
+	}
+	result := NewTestResultReport("abc", testCases)
+	if result.Commit != "abc" || len(result.Cases) != 4 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	expected := []struct {
+		status  string
+		message string
+	}{
+		{types.TestCaseStatusPassed, ""},
+		{types.TestCaseStatusFailed, "expected 3, got 4"},
+		{types.TestCaseStatusError, "panic"},
+		{types.TestCaseStatusSkipped, ""},
+	}
+	for i, e := range expected {
+		if result.Cases[i].Status != e.status || result.Cases[i].FailureMessage != e.message {
+			t.Errorf("unexpected case %+v", result.Cases[i])
+		}
+	}
+}
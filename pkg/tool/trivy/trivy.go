@@ -0,0 +1,225 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trivy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+// Report is the part of the trivy json report zadig cares about.
+type Report struct {
+	Results []*Result `json:"Results"`
+}
+
+type Result struct {
+	Target          string           `json:"Target"`
+	Class           string           `json:"Class"`
+	Type            string           `json:"Type"`
+	Vulnerabilities []*Vulnerability `json:"Vulnerabilities"`
+}
+
+type Vulnerability struct {
+	VulnerabilityID  string `json:"VulnerabilityID"`
+	PkgName          string `json:"PkgName"`
+	InstalledVersion string `json:"InstalledVersion"`
+	FixedVersion     string `json:"FixedVersion"`
+	Severity         string `json:"Severity"`
+	Title            string `json:"Title"`
+	PrimaryURL       string `json:"PrimaryURL"`
+}
+
+// Scan runs the scanner and writes the json report to reportFile, the output of the scanner goes to stdout.
+func Scan(ctx context.Context, exe string, spec *step.StepVulnerabilityScanSpec, workspace string, envs []string, reportFile string) error {
+	args, err := Args(spec, workspace, reportFile)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, exe, args...)
+	cmd.Dir = workspace
+	cmd.Env = append(envs, Envs(spec)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run vulnerability scanning: %s", err)
+	}
+	return nil
+}
+
+func Args(spec *step.StepVulnerabilityScanSpec, workspace, reportFile string) ([]string, error) {
+	var args []string
+	switch spec.Target {
+	case step.VulnerabilityScanTargetImage:
+		if spec.Image == "" {
+			return nil, fmt.Errorf("image to scan is empty")
+		}
+		args = []string{"image"}
+	case step.VulnerabilityScanTargetFilesystem:
+		args = []string{"filesystem"}
+	default:
+		return nil, fmt.Errorf("unknown scanning target %s", spec.Target)
+	}
+	args = append(args, "--format", "json", "--output", reportFile, "--no-progress")
+	if len(spec.Severities) > 0 {
+		args = append(args, "--severity", strings.Join(spec.Severities, ","))
+	}
+	if spec.IgnoreUnfixed {
+		args = append(args, "--ignore-unfixed")
+	}
+	if spec.DB != nil {
+		if spec.DB.DBRepository != "" {
+			args = append(args, "--db-repository", spec.DB.DBRepository)
+		}
+		if spec.DB.JavaDBRepository != "" {
+			args = append(args, "--java-db-repository", spec.DB.JavaDBRepository)
+		}
+		if spec.DB.Insecure {
+			args = append(args, "--insecure")
+		}
+	}
+
+	if spec.Target == step.VulnerabilityScanTargetImage {
+		return append(args, spec.Image), nil
+	}
+	dir := spec.ScanDir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(workspace, dir)
+	}
+	return append(args, dir), nil
+}
+
+// Envs passes the registry credentials of the image to the scanner.
+func Envs(spec *step.StepVulnerabilityScanSpec) []string {
+	if spec.Target != step.VulnerabilityScanTargetImage || spec.DockerRegistry == nil || spec.DockerRegistry.UserName == "" {
+		return nil
+	}
+	return []string{
+		"TRIVY_USERNAME=" + spec.DockerRegistry.UserName,
+		"TRIVY_PASSWORD=" + spec.DockerRegistry.Password,
+	}
+}
+
+// ParseReport returns the findings of the report sorted by severity.
+func ParseReport(data []byte) ([]*types.VulnerabilityFinding, error) {
+	report := &Report{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("failed to parse vulnerability report: %s", err)
+	}
+	findings := make([]*types.VulnerabilityFinding, 0)
+	for _, result := range report.Results {
+		for _, vuln := range result.Vulnerabilities {
+			findings = append(findings, &types.VulnerabilityFinding{
+				CVE:              vuln.VulnerabilityID,
+				Package:          vuln.PkgName,
+				InstalledVersion: vuln.InstalledVersion,
+				FixedVersion:     vuln.FixedVersion,
+				Severity:         normalizeSeverity(vuln.Severity),
+				Target:           result.Target,
+				Title:            vuln.Title,
+				URL:              vuln.PrimaryURL,
+			})
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRank(findings[i].Severity) < severityRank(findings[j].Severity)
+	})
+	return findings, nil
+}
+
+func Summarize(findings []*types.VulnerabilityFinding) *types.VulnerabilitySummary {
+	summary := &types.VulnerabilitySummary{Total: len(findings)}
+	for _, finding := range findings {
+		switch finding.Severity {
+		case types.VulnerabilitySeverityCritical:
+			summary.Critical++
+		case types.VulnerabilitySeverityHigh:
+			summary.High++
+		case types.VulnerabilitySeverityMedium:
+			summary.Medium++
+		case types.VulnerabilitySeverityLow:
+			summary.Low++
+		default:
+			summary.Unknown++
+		}
+		if finding.Fixable() {
+			summary.Fixable++
+		}
+	}
+	return summary
+}
+
+// CheckQualityGate returns the broken rules of the gate, the gate passes if nothing is returned.
+func CheckQualityGate(gate *types.VulnerabilityQualityGate, findings []*types.VulnerabilityFinding) []string {
+	violations := make([]string, 0)
+	if gate == nil || !gate.Enabled {
+		return violations
+	}
+	for _, rule := range gate.Rules {
+		count := 0
+		for _, finding := range findings {
+			if finding.Severity != normalizeSeverity(rule.Severity) {
+				continue
+			}
+			if rule.FixableOnly && !finding.Fixable() {
+				continue
+			}
+			count++
+		}
+		if count > rule.MaxCount {
+			fixable := ""
+			if rule.FixableOnly {
+				fixable = " fixable"
+			}
+			violations = append(violations, fmt.Sprintf("%d%s %s vulnerabilities found, at most %d allowed", count, fixable, normalizeSeverity(rule.Severity), rule.MaxCount))
+		}
+	}
+	return violations
+}
+
+func normalizeSeverity(severity string) string {
+	severity = strings.ToUpper(severity)
+	if severityRank(severity) == len(severityOrder) {
+		return types.VulnerabilitySeverityUnknown
+	}
+	return severity
+}
+
+var severityOrder = []string{
+	types.VulnerabilitySeverityCritical,
+	types.VulnerabilitySeverityHigh,
+	types.VulnerabilitySeverityMedium,
+	types.VulnerabilitySeverityLow,
+	types.VulnerabilitySeverityUnknown,
+}
+
+func severityRank(severity string) int {
+	for i, s := range severityOrder {
+		if s == severity {
+			return i
+		}
+	}
+	return len(severityOrder)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trivy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const testReport = `{
+  "SchemaVersion": 2,
+  "ArtifactName": "koderover.io/zadig/app:v1",
+  "Results": [
+    {
+      "Target": "koderover.io/zadig/app:v1 (alpine 3.17.0)",
+      "Class": "os-pkgs",
+      "Type": "alpine",
+      "Vulnerabilities": [
+        {"VulnerabilityID": "CVE-2023-0002", "PkgName": "zlib", "InstalledVersion": "1.2.12", "Severity": "HIGH"},
+        {"VulnerabilityID": "CVE-2023-0001", "PkgName": "openssl", "InstalledVersion": "3.0.7", "FixedVersion": "3.0.8", "Severity": "CRITICAL"}
+      ]
+    },
+    {"Target": "app", "Class": "lang-pkgs", "Type": "gobinary"}
+  ]
+}`
+
+func TestParseReport(t *testing.T) {
+	findings, err := ParseReport([]byte(testReport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 || findings[0].CVE != "CVE-2023-0001" || findings[0].FixedVersion != "3.0.8" {
+		t.Fatalf("unexpected findings %+v", findings)
+	}
+
+	summary := Summarize(findings)
+	if summary.Critical != 1 || summary.High != 1 || summary.Fixable != 1 || summary.Total != 2 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+}
+
+func TestCheckQualityGate(t *testing.T) {
+	findings, _ := ParseReport([]byte(testReport))
+	gate := &types.VulnerabilityQualityGate{
+		Enabled: true,
+		Rules:   []*types.VulnerabilityGateRule{{Severity: "high", FixableOnly: true}},
+	}
+	if violations := CheckQualityGate(gate, findings); len(violations) != 0 {
+		t.Errorf("unexpected violations %v", violations)
+	}
+	gate.Rules = append(gate.Rules, &types.VulnerabilityGateRule{Severity: types.VulnerabilitySeverityCritical, FixableOnly: true})
+	if violations := CheckQualityGate(gate, findings); len(violations) != 1 {
+		t.Errorf("unexpected violations %v", violations)
+	}
+	gate.Enabled = false
+	if violations := CheckQualityGate(gate, findings); len(violations) != 0 {
+		t.Errorf("unexpected violations of a disabled gate %v", violations)
+	}
+}
+
+func TestArgs(t *testing.T) {
+	spec := &step.StepVulnerabilityScanSpec{
+		Target:        step.VulnerabilityScanTargetFilesystem,
+		ScanDir:       "zadig",
+		Severities:    []string{types.VulnerabilitySeverityCritical, types.VulnerabilitySeverityHigh},
+		IgnoreUnfixed: true,
+		DB:            &types.VulnerabilityDBSettings{DBRepository: "registry.local/trivy-db"},
+	}
+	args, err := Args(spec, "/workspace", "/tmp/report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "filesystem --format json --output /tmp/report.json --no-progress --severity CRITICAL,HIGH --ignore-unfixed --db-repository registry.local/trivy-db /workspace/zadig"
+	if strings.Join(args, " ") != expected {
+		t.Errorf("unexpected args %v", args)
+	}
+
+	spec.Target = step.VulnerabilityScanTargetImage
+	if _, err := Args(spec, "/workspace", "/tmp/report.json"); err == nil {
+		t.Errorf("expected an error for an empty image")
+	}
+}
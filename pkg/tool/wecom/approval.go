@@ -0,0 +1,206 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wecom
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	// ApproverAttrOr any approver of the node can approve, ApproverAttrAnd all approvers of the node should approve
+	ApproverAttrOr  = 1
+	ApproverAttrAnd = 2
+)
+
+// sp_status of an approval instance
+const (
+	ApprovalStatusPending  = 1
+	ApprovalStatusApproved = 2
+	ApprovalStatusRejected = 3
+	ApprovalStatusRevoked  = 4
+	ApprovalStatusUndone   = 6
+	ApprovalStatusDeleted  = 7
+	ApprovalStatusPaid     = 10
+)
+
+const (
+	approvalControlText     = "Text"
+	approvalControlTextarea = "Textarea"
+)
+
+type TemplateDetail struct {
+	TemplateContent *TemplateContent `json:"template_content"`
+}
+
+type TemplateContent struct {
+	Controls []*TemplateControl `json:"controls"`
+}
+
+type TemplateControl struct {
+	Property *TemplateControlProperty `json:"property"`
+}
+
+type TemplateControlProperty struct {
+	Control string `json:"control"`
+	ID      string `json:"id"`
+	Require int    `json:"require"`
+}
+
+func (c *Client) GetTemplateDetail(templateID string) (resp *TemplateDetail, err error) {
+	_, err = c.R().SetBodyJsonMarshal(map[string]string{"template_id": templateID}).
+		SetSuccessResult(&resp).
+		Post("/oa/gettemplatedetail")
+	return
+}
+
+// textControlID returns the first text control of the template, the approval form content is filled into it.
+func (t *TemplateDetail) textControlID() (string, string, error) {
+	if t.TemplateContent == nil {
+		return "", "", errors.New("template has no content")
+	}
+	for _, control := range t.TemplateContent.Controls {
+		if control.Property == nil {
+			continue
+		}
+		if control.Property.Control == approvalControlTextarea || control.Property.Control == approvalControlText {
+			return control.Property.Control, control.Property.ID, nil
+		}
+	}
+	return "", "", errors.New("template has no text control")
+}
+
+type ApprovalNode struct {
+	Attr    int      `json:"attr"`
+	UserIDs []string `json:"userid"`
+}
+
+type CreateApprovalInstanceArgs struct {
+	TemplateID    string
+	CreatorUserID string
+	ApprovalNodes []*ApprovalNode
+	FormContent   string
+	Summary       string
+}
+
+type applyEventRequest struct {
+	CreatorUserID       string          `json:"creator_userid"`
+	TemplateID          string          `json:"template_id"`
+	UseTemplateApprover int             `json:"use_template_approver"`
+	Approver            []*ApprovalNode `json:"approver"`
+	ApplyData           *applyData      `json:"apply_data"`
+	SummaryList         []*summary      `json:"summary_list"`
+}
+
+type applyData struct {
+	Contents []*applyContent `json:"contents"`
+}
+
+type applyContent struct {
+	Control string            `json:"control"`
+	ID      string            `json:"id"`
+	Value   map[string]string `json:"value"`
+}
+
+type summary struct {
+	SummaryInfo []*summaryInfo `json:"summary_info"`
+}
+
+type summaryInfo struct {
+	Text string `json:"text"`
+	Lang string `json:"lang"`
+}
+
+type CreateApprovalInstanceResponse struct {
+	SpNo string `json:"sp_no"`
+}
+
+// CreateApprovalInstance applies an approval with the given approval nodes instead of the approvers of the template.
+func (c *Client) CreateApprovalInstance(args *CreateApprovalInstanceArgs) (resp *CreateApprovalInstanceResponse, err error) {
+	template, err := c.GetTemplateDetail(args.TemplateID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get template detail")
+	}
+	control, controlID, err := template.textControlID()
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.R().
+		SetBodyJsonMarshal(&applyEventRequest{
+			CreatorUserID:       args.CreatorUserID,
+			TemplateID:          args.TemplateID,
+			UseTemplateApprover: 0,
+			Approver:            args.ApprovalNodes,
+			ApplyData: &applyData{
+				Contents: []*applyContent{
+					{
+						Control: control,
+						ID:      controlID,
+						Value:   map[string]string{"text": args.FormContent},
+					},
+				},
+			},
+			SummaryList: []*summary{
+				{SummaryInfo: []*summaryInfo{{Text: args.Summary, Lang: "zh_CN"}}},
+			},
+		}).
+		SetSuccessResult(&resp).
+		Post("/oa/applyevent")
+	return
+}
+
+type ApprovalDetail struct {
+	Info *ApprovalInstanceInfo `json:"info"`
+}
+
+type ApprovalInstanceInfo struct {
+	SpNo     string            `json:"sp_no"`
+	SpName   string            `json:"sp_name"`
+	SpStatus int               `json:"sp_status"`
+	SpRecord []*ApprovalRecord `json:"sp_record"`
+}
+
+// ApprovalRecord is the result of an approval node, the records are in the same order as the approval nodes.
+type ApprovalRecord struct {
+	SpStatus     int                     `json:"sp_status"`
+	ApproverAttr int                     `json:"approverattr"`
+	Details      []*ApprovalRecordDetail `json:"details"`
+}
+
+type ApprovalRecordDetail struct {
+	Approver *Approver `json:"approver"`
+	Speech   string    `json:"speech"`
+	SpStatus int       `json:"sp_status"`
+	SpTime   int64     `json:"sptime"`
+}
+
+type Approver struct {
+	UserID string `json:"userid"`
+}
+
+func (c *Client) GetApprovalInstance(spNo string) (resp *ApprovalInstanceInfo, err error) {
+	detail := &ApprovalDetail{}
+	_, err = c.R().SetBodyJsonMarshal(map[string]string{"sp_no": spNo}).
+		SetSuccessResult(detail).
+		Post("/oa/getapprovaldetail")
+	if err != nil {
+		return nil, err
+	}
+	if detail.Info == nil {
+		return nil, errors.Errorf("approval %s not found", spNo)
+	}
+	return detail.Info, nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wecom
+
+import (
+	"time"
+
+	"github.com/imroc/req/v3"
+	cache "github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+const apiBase = "https://qyapi.weixin.qq.com/cgi-bin"
+
+var (
+	tokenCache = cache.New(time.Hour*2, time.Minute*5)
+)
+
+type Client struct {
+	*req.Client
+	CorpID string
+	Secret string
+}
+
+func NewClient(corpID, secret string) (client *Client) {
+	client = &Client{
+		Client: req.C().
+			SetBaseURL(apiBase).
+			OnBeforeRequest(func(c *req.Client, req *req.Request) (err error) {
+				// get or refresh access token
+				token, found := tokenCache.Get(client.cacheKey())
+				if !found {
+					token, err = client.RefreshAccessToken()
+					if err != nil {
+						return errors.Wrap(err, "refresh access token")
+					}
+				}
+				req.AddQueryParam("access_token", token.(string))
+				return nil
+			}).
+			OnAfterResponse(func(client *req.Client, resp *req.Response) error {
+				if resp.Err != nil {
+					resp.Err = errors.Wrapf(resp.Err, "body: %s", resp.String())
+					return nil
+				}
+				if !resp.IsSuccessState() {
+					resp.Err = errors.Errorf("unexpected status code %d, body: %s", resp.GetStatusCode(), resp.String())
+					return nil
+				}
+				if gjson.Get(resp.String(), "errcode").Int() != 0 {
+					resp.Err = errors.Errorf("WeCom API Error %s", resp.String())
+					return nil
+				}
+				return nil
+			}),
+		CorpID: corpID,
+		Secret: secret,
+	}
+	return client
+}
+
+// the access token belongs to the app, different apps in the same corp have different tokens
+func (c *Client) cacheKey() string {
+	return c.CorpID + "/" + c.Secret
+}
+
+type TokenResponse struct {
+	ErrCode     int    `json:"errcode"`
+	ErrMsg      string `json:"errmsg"`
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func (c *Client) RefreshAccessToken() (string, error) {
+	var tokenResponse *TokenResponse
+	resp, err := req.R().SetQueryParams(map[string]string{
+		"corpid":     c.CorpID,
+		"corpsecret": c.Secret,
+	}).SetSuccessResult(&tokenResponse).Get(apiBase + "/gettoken")
+	if err != nil {
+		return "", errors.Wrap(err, "request failed")
+	}
+	if resp.IsErrorState() {
+		return "", errors.Errorf("unexpected status code %d, body: %s", resp.GetStatusCode(), resp.String())
+	}
+	if tokenResponse == nil || tokenResponse.ErrCode != 0 {
+		return "", errors.Errorf("WeCom API Error %s", resp.String())
+	}
+	tokenCache.Set(c.cacheKey(), tokenResponse.AccessToken, cache.DefaultExpiration)
+	return tokenResponse.AccessToken, nil
+}
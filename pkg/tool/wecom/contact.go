@@ -0,0 +1,28 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wecom
+
+type GetUserIDResponse struct {
+	UserID string `json:"userid"`
+}
+
+func (c *Client) GetUserIDByMobile(mobile string) (resp *GetUserIDResponse, err error) {
+	_, err = c.R().SetBodyJsonMarshal(map[string]string{"mobile": mobile}).
+		SetSuccessResult(&resp).
+		Post("/user/getuserid")
+	return
+}
@@ -0,0 +1,31 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wecom
+
+import "github.com/pkg/errors"
+
+// Validate checks the app secret and the approval template of the app.
+func Validate(corpID, secret, templateID string) error {
+	client := NewClient(corpID, secret)
+	if _, err := client.RefreshAccessToken(); err != nil {
+		return err
+	}
+	if _, err := client.GetTemplateDetail(templateID); err != nil {
+		return errors.Wrap(err, "get approval template")
+	}
+	return nil
+}
@@ -26,4 +26,5 @@ const (
 
 const (
 	ScanningTypeSonar = "sonarQube"
+	ScanningTypeTrivy = "trivy"
 )
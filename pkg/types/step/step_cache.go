@@ -0,0 +1,40 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+const (
+	// CacheRootDir is the object storage dir of all the caches saved by cache_save steps.
+	CacheRootDir = "cache"
+	// CacheArchiveSuffix is the suffix of a cache archive, the object key is <cache dir>/<key><suffix>.
+	CacheArchiveSuffix = ".tar.gz"
+	// CacheAccessDir keeps an empty marker for every cache archive, it is rewritten on each cache hit so
+	// the least recently used caches can be found by its last modified time.
+	CacheAccessDir = ".access"
+)
+
+// StepCacheSpec is the spec of both cache_restore and cache_save steps.
+type StepCacheSpec struct {
+	// Key is a template rendered with the job envs and the hashFiles function, e.g. go-{{hashFiles "**/go.sum"}}.
+	Key string `bson:"key"                        json:"key"                              yaml:"key"`
+	// RestoreKeys are the prefixes tried in order when Key is not found, the latest matching cache is restored.
+	RestoreKeys []string `bson:"restore_keys"               json:"restore_keys"                     yaml:"restore_keys"`
+	// Paths are the dirs to cache, relative paths are relative to the workspace.
+	Paths []string `bson:"paths"                      json:"paths"                            yaml:"paths"`
+	// CacheDir is the object storage dir of the caches, it is scoped by project, workflow and job.
+	CacheDir  string `bson:"cache_dir"                  json:"cache_dir"                        yaml:"cache_dir"`
+	S3Storage *S3    `bson:"s3_storage"                 json:"s3_storage"                       yaml:"s3_storage"`
+}
@@ -18,6 +18,7 @@ package step
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/koderover/zadig/pkg/setting"
 )
@@ -34,8 +35,36 @@ type StepDockerBuildSpec struct {
 	Proxy                 *Proxy          `bson:"proxy"                               json:"proxy"                                  yaml:"proxy"`
 	IgnoreCache           bool            `bson:"ignore_cache"                        json:"ignore_cache"                           yaml:"ignore_cache"`
 	DockerRegistry        *DockerRegistry `bson:"docker_registry"                     json:"docker_registry"                        yaml:"docker_registry"`
+	// Builder is one of docker, buildkit and kaniko, docker is used if it is empty.
+	Builder string `bson:"builder"                             json:"builder"                                yaml:"builder"`
+	// Platform is the comma separated target platforms, e.g. linux/amd64,linux/arm64, only buildkit supports multiple platforms.
+	Platform string `bson:"platform"                            json:"platform"                               yaml:"platform"`
+	// EnableRegistryCache exports the build cache to the registry and imports it in the next build.
+	EnableRegistryCache bool `bson:"enable_registry_cache"               json:"enable_registry_cache"                  yaml:"enable_registry_cache"`
+	// CacheImage is the image the build cache is saved to, <image repo>:buildcache is used if it is empty.
+	CacheImage string `bson:"cache_image"                         json:"cache_image"                            yaml:"cache_image"`
 }
 
+const (
+	DockerBuilderDocker   = "docker"
+	DockerBuilderBuildKit = "buildkit"
+	DockerBuilderKaniko   = "kaniko"
+
+	// BuilderShareDir is shared by the job container and the builder sidecars, it holds the registry auth,
+	// the kaniko build requests and the exit marker which stops the sidecars.
+	BuilderShareDir = "/zadig/builder"
+	// BuilderExitFile is created when the job executor exits, the sidecars exit once it exists.
+	BuilderExitFile = BuilderShareDir + "/exit"
+	// BuilderHeartbeatFile is touched by the job container periodically, the sidecars exit if it is not updated
+	// within BuilderHeartbeatTimeout seconds, e.g. the job container was killed before it created the exit file.
+	BuilderHeartbeatFile    = BuilderShareDir + "/heartbeat"
+	BuilderHeartbeatTimeout = 60
+	// BuildKitAddr is the address of the rootless buildkitd sidecar.
+	BuildKitAddr = "tcp://127.0.0.1:1234"
+	// KanikoRequestDir holds the build scripts run by the kaniko sidecar, every <id>.sh gets a <id>.log and <id>.exit.
+	KanikoRequestDir = BuilderShareDir + "/kaniko"
+)
+
 type DockerRegistry struct {
 	DockerRegistryID string `bson:"docker_registry_id"                json:"docker_registry_id"                   yaml:"docker_registry_id"`
 	Host             string `bson:"host"                              json:"host"                                 yaml:"host"`
@@ -44,6 +73,39 @@ type DockerRegistry struct {
 	Password         string `bson:"password"                          json:"password"                             yaml:"password"`
 }
 
+// IsDaemonless reports whether the image is built without a docker daemon.
+func (s *StepDockerBuildSpec) IsDaemonless() bool {
+	return s.Builder == DockerBuilderBuildKit || s.Builder == DockerBuilderKaniko
+}
+
+func (s *StepDockerBuildSpec) Platforms() []string {
+	resp := make([]string, 0)
+	for _, platform := range strings.Split(s.Platform, ",") {
+		if platform = strings.TrimSpace(platform); platform != "" {
+			resp = append(resp, platform)
+		}
+	}
+	return resp
+}
+
+// Validate checks the builder related settings.
+func (s *StepDockerBuildSpec) Validate() error {
+	switch s.Builder {
+	case "", DockerBuilderDocker:
+		if len(s.Platforms()) > 1 || s.EnableRegistryCache {
+			return fmt.Errorf("multi-platform builds and registry cache require the buildkit or kaniko builder")
+		}
+	case DockerBuilderBuildKit:
+	case DockerBuilderKaniko:
+		if len(s.Platforms()) > 1 {
+			return fmt.Errorf("kaniko can not build multi-platform images, use the buildkit builder instead")
+		}
+	default:
+		return fmt.Errorf("unknown docker builder %s", s.Builder)
+	}
+	return nil
+}
+
 func (s *StepDockerBuildSpec) GetDockerFile() string {
 	// if the source of the dockerfile is from template, we write our own dockerfile
 	if s.Source == setting.DockerfileSourceTemplate {
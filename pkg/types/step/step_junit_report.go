@@ -23,4 +23,17 @@ type StepJunitReportSpec struct {
 	FileName  string `bson:"file_name"                  json:"file_name"                         yaml:"file_name"`
 	TestName  string `bson:"test_name"                  json:"test_name"                         yaml:"test_name"`
 	S3Storage *S3    `bson:"s3_storage"                 json:"s3_storage"                        yaml:"s3_storage"`
+	// ReportFormat is the format of the files in ReportDir, junit by default
+	ReportFormat   string `bson:"report_format"   json:"report_format"   yaml:"report_format"`
+	CoveragePath   string `bson:"coverage_path"   json:"coverage_path"   yaml:"coverage_path"`
+	CoverageFormat string `bson:"coverage_format" json:"coverage_format" yaml:"coverage_format"`
+	// the tested repo, used to record the results by commit and to compare coverage with the target branch
+	RepoName string `bson:"repo_name" json:"repo_name" yaml:"repo_name"`
+	RepoDir  string `bson:"repo_dir"  json:"repo_dir"  yaml:"repo_dir"`
+	Branch   string `bson:"branch"    json:"branch"    yaml:"branch"`
+	PR       int    `bson:"pr"        json:"pr"        yaml:"pr"`
+	CommitID string `bson:"commit_id" json:"commit_id" yaml:"commit_id"`
 }
+
+// TestResultFileName is the per case results and coverage written next to the merged junit report.
+const TestResultFileName = "test-result.json"
@@ -0,0 +1,71 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+const (
+	SBOMFormatSPDX      = "spdx"
+	SBOMFormatCycloneDX = "cyclonedx"
+
+	// the job outputs written by the supply_chain step
+	SupplyChainOutputDigest      = "IMAGE_DIGEST"
+	SupplyChainOutputSignature   = "IMAGE_SIGNATURE"
+	SupplyChainOutputSBOM        = "IMAGE_SBOM"
+	SupplyChainOutputAttestation = "IMAGE_ATTESTATION"
+
+	// ZadigBuildType is the build type recorded in the provenance.
+	ZadigBuildType = "https://koderover.com/zadig/build@v1"
+
+	// SigningKeyDir is where the signing key secret of the job is mounted, the private key and its password
+	// are never put into the step spec, which is saved in the workflow task and the job configmap.
+	SigningKeyDir          = "/zadig/signing-key"
+	SigningKeyFile         = "cosign.key"
+	SigningKeyPasswordFile = "cosign.password"
+)
+
+// StepSupplyChainSpec generates the SBOM, the signature and the provenance of an image pushed by the job.
+type StepSupplyChainSpec struct {
+	ImageName      string          `bson:"image_name"                 json:"image_name"                       yaml:"image_name"`
+	DockerRegistry *DockerRegistry `bson:"docker_registry"            json:"docker_registry"                  yaml:"docker_registry"`
+	// SBOMFormat is spdx or cyclonedx, no SBOM is generated if it is empty.
+	SBOMFormat string `bson:"sbom_format"                json:"sbom_format"                      yaml:"sbom_format"`
+	Sign       bool   `bson:"sign"                       json:"sign"                             yaml:"sign"`
+	Provenance bool   `bson:"provenance"                 json:"provenance"                       yaml:"provenance"`
+	// SigningKeyID is the key used by signing and attestations, it is mounted into the job at SigningKeyDir.
+	SigningKeyID string `bson:"signing_key_id"             json:"signing_key_id"                   yaml:"signing_key_id"`
+	// BuildContext is recorded in the provenance, the commits of the repos are resolved when the step runs.
+	BuildContext *SupplyChainBuildContext `bson:"build_context"              json:"build_context"                    yaml:"build_context"`
+}
+
+type SupplyChainBuildContext struct {
+	WorkflowName string             `bson:"workflow_name"              json:"workflow_name"                    yaml:"workflow_name"`
+	TaskID       int64              `bson:"task_id"                    json:"task_id"                          yaml:"task_id"`
+	JobName      string             `bson:"job_name"                   json:"job_name"                         yaml:"job_name"`
+	BuilderImage string             `bson:"builder_image"              json:"builder_image"                    yaml:"builder_image"`
+	Repos        []*SupplyChainRepo `bson:"repos"                      json:"repos"                            yaml:"repos"`
+}
+
+type SupplyChainRepo struct {
+	URL string `bson:"url"                        json:"url"                              yaml:"url"`
+	// Path is the checkout path relative to the workspace.
+	Path     string `bson:"path"                       json:"path"                             yaml:"path"`
+	Branch   string `bson:"branch"                     json:"branch"                           yaml:"branch"`
+	CommitID string `bson:"commit_id"                  json:"commit_id"                        yaml:"commit_id"`
+}
+
+func (s *StepSupplyChainSpec) NeedKey() bool {
+	return s.Sign || s.Provenance
+}
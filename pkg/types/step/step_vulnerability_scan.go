@@ -0,0 +1,69 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"github.com/koderover/zadig/pkg/types"
+)
+
+const (
+	VulnerabilityScanTargetImage      = "image"
+	VulnerabilityScanTargetFilesystem = "filesystem"
+
+	VulnerabilityReportFileName = "vulnerability-report.json"
+
+	// the job outputs written by the vulnerability_scan step, they can be referenced in workflow conditions
+	VulnerabilityOutputCritical    = "VULNERABILITY_CRITICAL"
+	VulnerabilityOutputHigh        = "VULNERABILITY_HIGH"
+	VulnerabilityOutputMedium      = "VULNERABILITY_MEDIUM"
+	VulnerabilityOutputLow         = "VULNERABILITY_LOW"
+	VulnerabilityOutputFixable     = "VULNERABILITY_FIXABLE"
+	VulnerabilityOutputTotal       = "VULNERABILITY_TOTAL"
+	VulnerabilityOutputQualityGate = "VULNERABILITY_QUALITY_GATE"
+
+	VulnerabilityQualityGatePassed = "passed"
+	VulnerabilityQualityGateFailed = "failed"
+)
+
+// StepVulnerabilityScanSpec scans an image or a directory of the workspace with a trivy compatible scanner.
+type StepVulnerabilityScanSpec struct {
+	// Target is image or filesystem.
+	Target         string          `bson:"target"                     json:"target"                           yaml:"target"`
+	Image          string          `bson:"image"                      json:"image"                            yaml:"image"`
+	ScanDir        string          `bson:"scan_dir"                   json:"scan_dir"                         yaml:"scan_dir"`
+	DockerRegistry *DockerRegistry `bson:"docker_registry"            json:"docker_registry"                  yaml:"docker_registry"`
+	// Severities are the reported severities, all severities are reported if it is empty.
+	Severities    []string                        `bson:"severities"                 json:"severities"                       yaml:"severities"`
+	IgnoreUnfixed bool                            `bson:"ignore_unfixed"             json:"ignore_unfixed"                   yaml:"ignore_unfixed"`
+	QualityGate   *types.VulnerabilityQualityGate `bson:"quality_gate"               json:"quality_gate"                     yaml:"quality_gate"`
+	DB            *types.VulnerabilityDBSettings  `bson:"db"                         json:"db"                               yaml:"db"`
+	// the raw report is uploaded to S3DestDir/vulnerability-report.json
+	S3DestDir string `bson:"s3_dest_dir"                json:"s3_dest_dir"                      yaml:"s3_dest_dir"`
+	S3Storage *S3    `bson:"s3_storage"                 json:"s3_storage"                       yaml:"s3_storage"`
+}
+
+func VulnerabilityOutputs() []string {
+	return []string{
+		VulnerabilityOutputCritical,
+		VulnerabilityOutputHigh,
+		VulnerabilityOutputMedium,
+		VulnerabilityOutputLow,
+		VulnerabilityOutputFixable,
+		VulnerabilityOutputTotal,
+		VulnerabilityOutputQualityGate,
+	}
+}
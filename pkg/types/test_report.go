@@ -0,0 +1,62 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+const (
+	TestReportFormatJunit      = "junit"
+	TestReportFormatGoTestJSON = "go_test_json"
+	TestReportFormatTestNG     = "testng"
+)
+
+const (
+	CoverageFormatCobertura = "cobertura"
+	CoverageFormatJacoco    = "jacoco"
+	CoverageFormatLCOV      = "lcov"
+)
+
+const (
+	TestCaseStatusPassed  = "passed"
+	TestCaseStatusFailed  = "failed"
+	TestCaseStatusError   = "error"
+	TestCaseStatusSkipped = "skipped"
+)
+
+// TestCaseResult is the result of a single test case, whatever the report format is.
+type TestCaseResult struct {
+	Suite          string  `bson:"suite"           json:"suite"           yaml:"suite"`
+	Name           string  `bson:"name"            json:"name"            yaml:"name"`
+	Duration       float64 `bson:"duration"        json:"duration"        yaml:"duration"`
+	Status         string  `bson:"status"          json:"status"          yaml:"status"`
+	FailureMessage string  `bson:"failure_message" json:"failure_message" yaml:"failure_message"`
+}
+
+// CoverageSummary is the overall line and branch coverage of a coverage report, rates are in [0, 1].
+type CoverageSummary struct {
+	LinesCovered    int     `bson:"lines_covered"    json:"lines_covered"    yaml:"lines_covered"`
+	LinesValid      int     `bson:"lines_valid"      json:"lines_valid"      yaml:"lines_valid"`
+	LineRate        float64 `bson:"line_rate"        json:"line_rate"        yaml:"line_rate"`
+	BranchesCovered int     `bson:"branches_covered" json:"branches_covered" yaml:"branches_covered"`
+	BranchesValid   int     `bson:"branches_valid"   json:"branches_valid"   yaml:"branches_valid"`
+	BranchRate      float64 `bson:"branch_rate"      json:"branch_rate"      yaml:"branch_rate"`
+}
+
+// TestResultReport is written by the junit report step so that aslan can persist the results.
+type TestResultReport struct {
+	Commit   string            `json:"commit"   yaml:"commit"`
+	Cases    []*TestCaseResult `json:"cases"    yaml:"cases"`
+	Coverage *CoverageSummary  `json:"coverage" yaml:"coverage"`
+}
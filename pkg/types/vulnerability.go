@@ -0,0 +1,73 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+const (
+	VulnerabilitySeverityCritical = "CRITICAL"
+	VulnerabilitySeverityHigh     = "HIGH"
+	VulnerabilitySeverityMedium   = "MEDIUM"
+	VulnerabilitySeverityLow      = "LOW"
+	VulnerabilitySeverityUnknown  = "UNKNOWN"
+)
+
+// VulnerabilityFinding is a vulnerability found in a package of the scanned image or filesystem.
+type VulnerabilityFinding struct {
+	CVE              string `bson:"cve"               json:"cve"               yaml:"cve"`
+	Package          string `bson:"package"           json:"package"           yaml:"package"`
+	InstalledVersion string `bson:"installed_version" json:"installed_version" yaml:"installed_version"`
+	FixedVersion     string `bson:"fixed_version"     json:"fixed_version"     yaml:"fixed_version"`
+	Severity         string `bson:"severity"          json:"severity"          yaml:"severity"`
+	Target           string `bson:"target"            json:"target"            yaml:"target"`
+	Title            string `bson:"title"             json:"title"             yaml:"title"`
+	URL              string `bson:"url"               json:"url"               yaml:"url"`
+}
+
+func (f *VulnerabilityFinding) Fixable() bool {
+	return f.FixedVersion != ""
+}
+
+type VulnerabilitySummary struct {
+	Critical int `bson:"critical" json:"critical" yaml:"critical"`
+	High     int `bson:"high"     json:"high"     yaml:"high"`
+	Medium   int `bson:"medium"   json:"medium"   yaml:"medium"`
+	Low      int `bson:"low"      json:"low"      yaml:"low"`
+	Unknown  int `bson:"unknown"  json:"unknown"  yaml:"unknown"`
+	Fixable  int `bson:"fixable"  json:"fixable"  yaml:"fixable"`
+	Total    int `bson:"total"    json:"total"    yaml:"total"`
+}
+
+// VulnerabilityQualityGate fails the scanning when any of the rules is broken.
+type VulnerabilityQualityGate struct {
+	Enabled bool                     `bson:"enabled" json:"enabled" yaml:"enabled"`
+	Rules   []*VulnerabilityGateRule `bson:"rules"   json:"rules"   yaml:"rules"`
+}
+
+// VulnerabilityGateRule is broken when there are more than MaxCount findings of the severity,
+// only the findings with a fixed version are counted if FixableOnly is set.
+// e.g. {CRITICAL, true, 0} fails the scanning if any critical vulnerability has a fix.
+type VulnerabilityGateRule struct {
+	Severity    string `bson:"severity"     json:"severity"     yaml:"severity"`
+	FixableOnly bool   `bson:"fixable_only" json:"fixable_only" yaml:"fixable_only"`
+	MaxCount    int    `bson:"max_count"    json:"max_count"    yaml:"max_count"`
+}
+
+// VulnerabilityDBSettings points the scanner to the vulnerability database mirror for air-gapped installs.
+type VulnerabilityDBSettings struct {
+	DBRepository     string `bson:"db_repository"      json:"db_repository"      yaml:"db_repository"`
+	JavaDBRepository string `bson:"java_db_repository" json:"java_db_repository" yaml:"java_db_repository"`
+	Insecure         bool   `bson:"insecure"           json:"insecure"           yaml:"insecure"`
+}